@@ -0,0 +1,398 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Client makes requests to the Clox API. Client should be created using the New
+// function.
+type Client struct {
+	http     *http.Client
+	baseURL  string
+	replicas []string
+	token    []byte
+	retries  int
+	backoff  Backoff
+	timeout  time.Duration
+	transfer *http.Client
+
+	transferTimeout    time.Duration
+	hasTransferTimeout bool
+
+	rateLimitRetries int
+	rateLimitNotify  RateLimitFunc
+
+	interceptors []Interceptor
+
+	names NameCodec
+
+	logger *slog.Logger
+}
+
+// Option configures a *Client when it is created with New.
+type Option func(*Client)
+
+// WithToken sets the API token that authorizes every request. The token is not
+// copied, so the caller can wipe it once the Client is no longer used.
+func WithToken(token []byte) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient sets the *http.Client used to send every request. If not set, a
+// new *http.Client is used.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// WithRetries sets how many times a request is retried if it fails. A request is
+// retried if it fails to reach the server, or the server responds with a transient
+// failure (502, 503, or 504). A request that is not idempotent, such as an upload,
+// is only retried if it could not connect to the server. A request that failed to
+// verify the certificate of the server, or that received any other error response
+// from the API, is not retried.
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.retries = n
+	}
+}
+
+// WithTimeout sets the time limit of a request, including reading the response
+// body. A timeout of zero means no time limit.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithTransferTimeout sets the time limit of a request that uploads or downloads the
+// contents of a file, including reading the response body. It replaces the timeout
+// of WithTimeout for these requests, since they take longer the larger the file. A
+// timeout of zero means no time limit. If not set, the timeout of WithTimeout is
+// used.
+func WithTransferTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.transferTimeout = d
+		c.hasTransferTimeout = true
+	}
+}
+
+// New creates a *Client for the Clox API at baseURL. The Client is configured with
+// the opts.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: baseURL, backoff: DefaultBackoff}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.http == nil {
+		c.http = &http.Client{}
+	}
+	transferTimeout := c.timeout
+	if c.hasTransferTimeout {
+		transferTimeout = c.transferTimeout
+	}
+	c.transfer = withTimeout(c.http, transferTimeout)
+	c.http = withTimeout(c.http, c.timeout)
+
+	return c
+}
+
+// withTimeout returns a copy of the client with the timeout. If the timeout is zero,
+// the client is returned as is.
+func withTimeout(client *http.Client, d time.Duration) *http.Client {
+	if d <= 0 {
+		return client
+	}
+
+	timed := *client
+	timed.Timeout = d
+	return &timed
+}
+
+// Dirs returns the *DirService of this Client.
+func (c *Client) Dirs() *DirService {
+	return &DirService{client: c}
+}
+
+// Files returns the *FileService of this Client.
+func (c *Client) Files() *FileService {
+	return &FileService{client: c}
+}
+
+// Uploads returns the *UploadService of this Client.
+func (c *Client) Uploads() *UploadService {
+	return &UploadService{client: c}
+}
+
+// Auth returns the *AuthService of this Client.
+func (c *Client) Auth() *AuthService {
+	return &AuthService{client: c}
+}
+
+// Locks returns the *LockService of this Client.
+func (c *Client) Locks() *LockService {
+	return &LockService{client: c}
+}
+
+// Tokens returns the *TokenService of this Client.
+func (c *Client) Tokens() *TokenService {
+	return &TokenService{client: c}
+}
+
+// request is the parameters when creating a new request. The body, query, header,
+// parts, and events field is optional.
+//
+// If stream is set, it is called for the body of every attempt instead of using
+// body, and size is the length of the body it returns. The body is closed when the
+// request is sent.
+//
+// If events is set, an Event is sent for every retry. If parts is also set, the
+// transfer of each part of the body is sent as it is read.
+//
+// If transfer is set, the request uploads or downloads the contents of a file and is
+// sent with the transfer timeout of the Client.
+//
+// If file is set, the path query is the path of a file, its name is encoded with
+// the NameCodec of the Client, see WithNames.
+type request struct {
+	method   string
+	path     string
+	body     []byte
+	stream   func() io.ReadCloser
+	size     int64
+	query    map[string]string
+	header   map[string]string
+	parts    []bodyPart
+	events   EventFunc
+	transfer bool
+	file     bool
+}
+
+// newRequest creates a new *http.Request to baseURL that is configured with the
+// request. If this Client has a token, the Authorization header is set with it.
+func (c *Client) newRequest(ctx context.Context, baseURL string, r request) (*http.Request, error) {
+	// A body of length 0 that is not http.NoBody is sent as unknown length.
+	var body io.ReadCloser = http.NoBody
+	if len(r.body) > 0 {
+		body = io.NopCloser(bytes.NewReader(r.body))
+	}
+	length := int64(len(r.body))
+	if r.stream != nil {
+		body = r.stream()
+		length = r.size
+	}
+	if len(r.parts) > 0 {
+		body = newProgressReader(body, r.parts, r.events)
+	}
+
+	url := fmt.Sprintf("%s/%s", baseURL, r.path)
+	req, err := http.NewRequestWithContext(ctx, r.method, url, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.ContentLength = length
+	if len(c.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+string(c.token))
+	}
+
+	if len(r.query) > 0 {
+		q := req.URL.Query()
+		for k, v := range r.query {
+			if k == "path" && r.file {
+				v = c.encodePath(v)
+			}
+			q.Set(k, v)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+
+	for k, v := range r.header {
+		req.Header.Set(k, v)
+	}
+
+	return req, nil
+}
+
+// do creates and executes a *http.Request that is configured with the request. The
+// response is parsed into dst, and the names of the files in it are decoded, see
+// WithNames.
+//
+// If sending the request fails, it is retried up to the number of retries of this
+// Client, waiting the Backoff of the Client before each retry. A request that is
+// rate limited is retried separately, see send. A read request is
+// sent to each replica before it is retried. If every attempt fails to reach the
+// server, the error wraps ErrUnreachable. If the API
+// responds with an error (non-200 status code), it will return an *APIError.
+func (c *Client) do(ctx context.Context, dst any, r request) error {
+	res, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := parseResponse(res, dst); err != nil {
+		return err
+	}
+
+	c.decodeNames(dst)
+	return nil
+}
+
+// send creates and sends a *http.Request that is configured with the request, and
+// returns the *http.Response. The caller must close the response body.
+//
+// If sending the request fails, it is retried the same as do. If the server rate
+// limits the request, it is sent again after the delay the server asks for, up to
+// the rate limit retries of this Client. The response of the last attempt is
+// returned if every attempt is rate limited.
+//
+// A file that is not found by the encoded name in its path is requested again with
+// the path as is, see WithNames.
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := c.sendRetries(ctx, r)
+		if err == nil && c.plainFallback(r, res) {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			r.file = false
+			res, err = c.sendRetries(ctx, r)
+		}
+		if err != nil || res.StatusCode != http.StatusTooManyRequests || attempt > c.rateLimitRetries {
+			return res, err
+		}
+
+		wait, ok := retryAfter(res.Header, time.Now())
+		if !ok {
+			wait = c.backoff.Delay(attempt)
+		}
+		if wait > maxRetryAfter {
+			c.debug("not retrying rate limited api request, the delay is too long", "method", r.method, "path", r.path, "delay", wait)
+			return res, nil
+		}
+		c.debug("waiting for rate limit", "method", r.method, "path", r.path, "delay", wait, "attempt", attempt)
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		if c.rateLimitNotify != nil {
+			c.rateLimitNotify(wait, attempt)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, fmt.Errorf("waiting for rate limit: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// sendRetries sends the request, retrying it if sending fails, see send.
+func (c *Client) sendRetries(ctx context.Context, r request) (*http.Response, error) {
+	send := c.chain(r.transfer)
+	retryable := idempotent(r.method)
+
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			c.debug("retrying api request", "method", r.method, "path", r.path, "retry", attempt, "retries", c.retries, "error", err)
+			r.events.emit(Event{Kind: EventRetry, Attempt: attempt, Err: err})
+			if waitErr := c.backoff.wait(ctx, attempt); waitErr != nil {
+				return nil, errors.Join(err, waitErr)
+			}
+		}
+
+		var res *http.Response
+		for i, baseURL := range c.endpoints(r.method) {
+			if i > 0 {
+				c.debug("failing over to replica", "method", r.method, "path", r.path, "endpoint", baseURL, "error", err)
+			}
+			c.trace("sending api request", "method", r.method, "path", r.path, "endpoint", baseURL, "attempt", attempt+1)
+
+			var req *http.Request
+			req, err = c.newRequest(ctx, baseURL, r)
+			if err != nil {
+				return nil, fmt.Errorf("creating request: %w", err)
+			}
+
+			res, err = send(req)
+			if err == nil && retryable && transientStatus(res.StatusCode) {
+				err = transientError(res)
+				continue
+			}
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+
+			var bodyErr *bodyError
+			if errors.As(err, &bodyErr) {
+				return nil, bodyErr
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("sending request: %w", err)
+			}
+
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				continue
+			}
+
+			unsent, permanent := dialFailed(err), certFailed(err)
+			err = fmt.Errorf("sending request: %w: %w", ErrUnreachable, err)
+			if permanent {
+				c.debug("not retrying api request, the certificate of the server was rejected", "method", r.method, "path", r.path)
+				return nil, err
+			}
+			if !retryable && !unsent {
+				c.debug("not retrying api request, it may have reached the server", "method", r.method, "path", r.path)
+				return nil, err
+			}
+			continue
+		}
+
+		return res, nil
+	}
+
+	return nil, err
+}
+
+// parseResponse handles *http.Response from the Clox API. A successful request will
+// parse JSON body into dst.
+//
+// If the API responds with an error (non-200 status code), it will return an
+// *APIError.
+func parseResponse(r *http.Response, dst any) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("reading body: %w", err)
+	}
+
+	if r.StatusCode != 200 {
+		return parseErrorResponse(body, r.StatusCode)
+	}
+
+	err = json.Unmarshal(body, dst)
+	if err != nil {
+		return fmt.Errorf("unmarshalling body: %w", err)
+	}
+
+	if e, ok := dst.(etagger); ok && e.etag() == "" {
+		e.setETag(r.Header.Get(headerETag))
+	}
+
+	return nil
+}
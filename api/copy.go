@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CopyParams is the request body of the POST request when copying a directory or
+// file.
+type CopyParams struct {
+	// The directory the copy is created in.
+	Parent Location `json:"parent"`
+	// The name of the copy. If it is empty, the copy has the same name.
+	Name string `json:"name,omitempty"`
+}
+
+// CopiedDir is the response body of the POST request when copying a directory. It
+// contains the copy of the directory and the number of directories and files below
+// it that were copied.
+type CopiedDir struct {
+	Dir   Dir `json:"directory"`
+	Dirs  int `json:"directories_copied"`
+	Files int `json:"files_copied"`
+}
+
+// Copy calls the API to copy the file at the Location, without the contents of the
+// file leaving the server. The copy is encrypted with the same key as the file. It
+// returns the copy.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. A file with the same name in the parent directory responds with a
+// 409 status code.
+func (s *FileService) Copy(ctx context.Context, file Location, p CopyParams) (*File, error) {
+	p.Name = s.client.encodeName(p.Name)
+	jsonData, err := json.Marshal(&p)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling data: %w", err)
+	}
+
+	path, query := file.endpoint("api/copy/file")
+	respData := &File{}
+	if err := s.client.do(ctx, respData, request{
+		method: "POST",
+		path:   path,
+		body:   jsonData,
+		query:  query,
+		file:   true,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// Copy calls the API to copy the directory at the Location, with every directory and
+// file below it. A directory cannot be copied below itself.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. A directory with the same name in the parent directory responds
+// with a 409 status code.
+func (s *DirService) Copy(ctx context.Context, dir Location, p CopyParams) (*CopiedDir, error) {
+	jsonData, err := json.Marshal(&p)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling data: %w", err)
+	}
+
+	path, query := dir.endpoint("api/copy/dir")
+	respData := &CopiedDir{}
+	if err := s.client.do(ctx, respData, request{
+		method: "POST",
+		path:   path,
+		body:   jsonData,
+		query:  query,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// debugBodyLimit is how many bytes of a request or response body a DebugTransport
+// writes, the rest of the body is left out.
+const debugBodyLimit = 2048
+
+// redactedHeaders are the headers that a DebugTransport never writes the value of.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// redactedKeys are the JSON fields and form values of a body that hold a secret,
+// such as the tokens and codes of the OAuth endpoints.
+var redactedKeys = []string{
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"api_token",
+	"token",
+	"device_code",
+	"code_verifier",
+	"client_secret",
+	"password",
+}
+
+// redactedFields matches the JSON fields of a body that are redactedKeys, with a
+// string value. The value of a body that was cut off may not end.
+var redactedFields = regexp.MustCompile(`"(` + strings.Join(redactedKeys, "|") + `)"(\s*):(\s*)"(?:[^"\\]|\\.)*(?:"|\\?$)`)
+
+// redactedFormKeys are the values of a form body that are redacted, the
+// redactedKeys and the authorization code of OAuth. The code of a JSON body is the
+// code of an APIError, it is not redacted.
+var redactedFormKeys = func() map[string]bool {
+	keys := map[string]bool{"code": true}
+	for _, k := range redactedKeys {
+		keys[k] = true
+	}
+	return keys
+}()
+
+// DebugTransport is an http.RoundTripper that writes every request and response to
+// W, for debugging the requests of a Client. The method, URL, headers, status, and
+// duration are written, and the start of the bodies up to a limit. The values of the
+// headers, JSON fields, and form values that hold secrets, such as the Authorization
+// header, are redacted. A body that is not text is written as its size.
+//
+// The requests are sent with Next, if it is nil http.DefaultTransport is used.
+type DebugTransport struct {
+	Next http.RoundTripper
+	W    io.Writer
+
+	mu sync.Mutex
+}
+
+// RoundTrip sends the request with Next and writes the request and response to W.
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	var sent *captureReader
+	if req.Body != nil && req.Body != http.NoBody {
+		sent = &captureReader{ReadCloser: req.Body}
+		req.Body = sent
+	}
+
+	start := time.Now()
+	res, err := next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "> %s %s\n", req.Method, req.URL.String())
+	writeHeaders(&b, "> ", req.Header)
+	if sent != nil {
+		start, size := sent.sent()
+		writeBody(&b, "> ", req.Header.Get("Content-Type"), start, size)
+	}
+
+	if err != nil {
+		fmt.Fprintf(&b, "< error after %s: %s\n\n", elapsed.Round(time.Microsecond), err)
+		t.write(b.String())
+		return nil, err
+	}
+
+	fmt.Fprintf(&b, "< %s (%s)\n", res.Status, elapsed.Round(time.Microsecond))
+	writeHeaders(&b, "< ", res.Header)
+	if res.Body != nil && res.Body != http.NoBody {
+		peek, readErr := io.ReadAll(io.LimitReader(res.Body, debugBodyLimit))
+		size := int64(len(peek))
+		if res.ContentLength > size {
+			size = res.ContentLength
+		}
+		writeBody(&b, "< ", res.Header.Get("Content-Type"), peek, size)
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(peek), errReader{readErr}, res.Body), res.Body}
+	}
+	b.WriteString("\n")
+	t.write(b.String())
+
+	return res, nil
+}
+
+// write writes s to W. The requests of a Client can be sent at the same time, the
+// output of each is written at once.
+func (t *DebugTransport) write(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.W, s)
+}
+
+// writeHeaders writes the headers sorted by name, each line starts with prefix. The
+// values of the redactedHeaders are replaced.
+func writeHeaders(b *strings.Builder, prefix string, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range h[name] {
+			if redactedHeaders[name] {
+				v = "[REDACTED]"
+			}
+			fmt.Fprintf(b, "%s%s: %s\n", prefix, name, v)
+		}
+	}
+}
+
+// writeBody writes the start of a body of size bytes, each line starts with prefix.
+// If the body is not text only its size is written. The secrets of a JSON or form
+// body are redacted.
+func writeBody(b *strings.Builder, prefix string, contentType string, start []byte, size int64) {
+	if !textBody(contentType, start) {
+		fmt.Fprintf(b, "%s[%d bytes]\n", prefix, size)
+		return
+	}
+
+	var body string
+	if strings.Contains(contentType, "x-www-form-urlencoded") {
+		body = redactForm(string(start))
+	} else {
+		body = redactedFields.ReplaceAllString(string(start), `"$1"$2:$3"[REDACTED]"`)
+	}
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		fmt.Fprintf(b, "%s%s\n", prefix, line)
+	}
+	if size > int64(len(start)) {
+		fmt.Fprintf(b, "%s[%d more bytes]\n", prefix, size-int64(len(start)))
+	}
+}
+
+// redactForm returns the form body with the values of the redactedFormKeys
+// replaced, sorted by key. A pair that cannot be parsed, such as the last pair of a
+// body that was cut off, is left out.
+func redactForm(body string) string {
+	values, _ := url.ParseQuery(body)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		for _, v := range values[k] {
+			if redactedFormKeys[k] {
+				v = "[REDACTED]"
+			} else {
+				v = url.QueryEscape(v)
+			}
+			pairs = append(pairs, url.QueryEscape(k)+"="+v)
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// textBody checks if a body with the content type and start can be written as text.
+func textBody(contentType string, start []byte) bool {
+	if !strings.HasPrefix(contentType, "text/") &&
+		!strings.Contains(contentType, "json") &&
+		!strings.Contains(contentType, "x-www-form-urlencoded") {
+		return false
+	}
+
+	return utf8.Valid(start) || len(start) == debugBodyLimit
+}
+
+// captureReader is a request body that keeps the first debugBodyLimit bytes that
+// are read from it, and counts every byte. The body can still be read by the
+// transport after the response is received.
+type captureReader struct {
+	io.ReadCloser
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+	n   int64
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if keep := debugBodyLimit - r.buf.Len(); keep > 0 {
+		r.buf.Write(p[:min(n, keep)])
+	}
+	r.n += int64(n)
+	return n, err
+}
+
+// sent returns a copy of the start of the body that was read, and the number of
+// bytes that were read.
+func (r *captureReader) sent() ([]byte, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return bytes.Clone(r.buf.Bytes()), r.n
+}
+
+// errReader returns err from every Read, or io.EOF if err is nil.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWriteBodyRedacts(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		secrets     []string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"access_token": "at-secret","token_type":"bearer","api_token":"api-secret","code":"not_found"}`,
+			want:        `{"access_token": "[REDACTED]","token_type":"bearer","api_token":"[REDACTED]","code":"not_found"}`,
+			secrets:     []string{"at-secret", "api-secret"},
+		},
+		{
+			name:        "json escaped quote",
+			contentType: "application/json",
+			body:        `{"password":"pa\"ss-secret","name":"file"}`,
+			want:        `{"password":"[REDACTED]","name":"file"}`,
+			secrets:     []string{"ss-secret"},
+		},
+		{
+			name:        "json cut off",
+			contentType: "application/json",
+			body:        `{"name":"file","refresh_token":"rt-sec`,
+			want:        `{"name":"file","refresh_token":"[REDACTED]"`,
+			secrets:     []string{"rt-sec"},
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "grant_type=urn%3Aietf%3Aparams%3Aoauth%3Agrant-type%3Adevice_code&device_code=dc-secret&client_id=clox-cli",
+			want:        "client_id=clox-cli&device_code=[REDACTED]&grant_type=urn%3Aietf%3Aparams%3Aoauth%3Agrant-type%3Adevice_code",
+			secrets:     []string{"dc-secret"},
+		},
+		{
+			name:        "form code",
+			contentType: "application/x-www-form-urlencoded; charset=utf-8",
+			body:        "code=c-secret&client_secret=cs-secret&code_verifier=cv-secret&state=xyz",
+			want:        "client_secret=[REDACTED]&code=[REDACTED]&code_verifier=[REDACTED]&state=xyz",
+			secrets:     []string{"c-secret", "cs-secret", "cv-secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeBody(&b, "> ", tt.contentType, []byte(tt.body), int64(len(tt.body)))
+			got := b.String()
+			if got != "> "+tt.want+"\n" {
+				t.Errorf("writeBody() = %q, want %q", got, "> "+tt.want+"\n")
+			}
+			for _, s := range tt.secrets {
+				if strings.Contains(got, s) {
+					t.Errorf("writeBody() = %q, holds the secret %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestDebugTransportRedacts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"issued-secret","token_type":"bearer"}`))
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	client := &http.Client{Transport: &DebugTransport{W: &out}}
+	form := url.Values{"device_code": {"device-secret"}, "client_id": {"clox-cli"}}
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer header-secret")
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	got := out.String()
+	for _, s := range []string{"device-secret", "issued-secret", "header-secret"} {
+		if strings.Contains(got, s) {
+			t.Errorf("debug output holds the secret %q:\n%s", s, got)
+		}
+	}
+	for _, s := range []string{"> client_id=clox-cli&device_code=[REDACTED]", "> Authorization: [REDACTED]", `"token_type":"bearer"`} {
+		if !strings.Contains(got, s) {
+			t.Errorf("debug output does not hold %q:\n%s", s, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"strconv"
+)
+
+// DeletedDir is the response body of the DELETE request when deleting a directory.
+// It contains the directory that was deleted and the number of directories and
+// files below it that were deleted with it.
+type DeletedDir struct {
+	Dir   Dir `json:"directory"`
+	Dirs  int `json:"directories_deleted"`
+	Files int `json:"files_deleted"`
+}
+
+// Delete calls the API to delete the file at the Location. It returns the file that
+// was deleted.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *FileService) Delete(ctx context.Context, file Location) (*File, error) {
+	path, query := file.endpoint("api/file")
+	respData := &File{}
+	if err := s.client.do(ctx, respData, request{
+		method: "DELETE",
+		path:   path,
+		query:  query,
+		file:   true,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// Delete calls the API to delete the directory at the Location. If recursive is
+// false, only an empty directory can be deleted. Otherwise every directory and file
+// below it is deleted with it. The users root directory cannot be deleted.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. Deleting a directory that is not empty without recursive responds
+// with a 409 status code.
+func (s *DirService) Delete(ctx context.Context, dir Location, recursive bool) (*DeletedDir, error) {
+	path, query := dir.endpoint("api/dir")
+	if query == nil {
+		query = map[string]string{}
+	}
+	query["recursive"] = strconv.FormatBool(recursive)
+
+	respData := &DeletedDir{}
+	if err := s.client.do(ctx, respData, request{
+		method: "DELETE",
+		path:   path,
+		query:  query,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SkipDir is returned by a WalkFunc to skip the sub directories of the directory.
+var SkipDir = errors.New("skip this directory")
+
+// WalkFunc is called by DirService.Walk for every directory that is visited. The
+// depth of the root directory is 0.
+//
+// If the WalkFunc returns SkipDir, the sub directories of the directory are not
+// visited. Any other error stops the walk and is returned by Walk.
+type WalkFunc func(listing *DirListing, depth int) error
+
+// DirService calls the directory endpoints of the Clox API. DirService should be
+// accessed by calling Client.Dirs.
+type DirService struct {
+	client *Client
+}
+
+// Dir is a directory on the server.
+type Dir struct {
+	ID        string    `json:"id"`
+	OwnerID   string    `json:"owner_id"`
+	ParentID  string    `json:"parent_id"`
+	DirName   string    `json:"directory_name"`
+	DirPath   string    `json:"directory_path"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LastWrite time.Time `json:"last_write"`
+	ETag      string    `json:"etag,omitempty"`
+}
+
+// NewDirResponse is the response body of the POST request when creating a new
+// directory.
+type NewDirResponse = Dir
+
+// File is a file on the server.
+type File struct {
+	ID          string    `json:"id"`
+	OwnerID     string    `json:"owner_id"`
+	DirectoryID string    `json:"directory_id"`
+	Name        string    `json:"file_name"`
+	Path        string    `json:"file_path"`
+	Size        int64     `json:"file_size"`
+	ContentType string    `json:"content_type,omitempty"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	ETag        string    `json:"etag,omitempty"`
+	// Checksum is the checksum of the plain text contents of the file, see
+	// Checksummer. It is empty if the file was uploaded without one, or appended to
+	// without a new one.
+	Checksum string `json:"checksum,omitempty"`
+	// Lock is the advisory lock on the file. It is nil if the file is not locked.
+	Lock *Lock `json:"lock,omitempty"`
+}
+
+// DirListing is the response body of the GET request when listing a directory. It
+// contains the directory and its immediate sub directories and files.
+type DirListing struct {
+	Dir   Dir    `json:"directory"`
+	Dirs  []Dir  `json:"directories"`
+	Files []File `json:"files"`
+}
+
+// newDirRequestBody is the request body of the POST request when creating a new
+// directory.
+type newDirRequestBody struct {
+	Name string `json:"name"`
+}
+
+// Create calls the API to create a new directory named name. The parent is the
+// Location of the directory that the new directory will be created within. An
+// empty path will create the directory in the users root directory on the server.
+//
+// The request is sent with If-None-Match: *, if a directory with the name already
+// exists the server rejects it.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *DirService) Create(ctx context.Context, parent Location, name string) (*NewDirResponse, error) {
+	jsonData, err := json.Marshal(&newDirRequestBody{Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling data: %w", err)
+	}
+
+	path, query := parent.endpoint("api/dir")
+	respData := &NewDirResponse{}
+	if err := s.client.do(ctx, respData, request{
+		method: "POST",
+		path:   path,
+		body:   jsonData,
+		query:  query,
+		header: map[string]string{headerIfNoneMatch: AnyETag},
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// List calls the API to list the directory at the Location. An empty path lists the
+// users root directory.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *DirService) List(ctx context.Context, dir Location) (*DirListing, error) {
+	path, query := dir.endpoint("api/dir")
+	respData := &DirListing{}
+	if err := s.client.do(ctx, respData, request{
+		method: "GET",
+		path:   path,
+		query:  query,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// Lister lists a remote directory. A *DirService is a Lister that calls the API,
+// other Listers can serve the listings from somewhere else, such as a cache.
+type Lister interface {
+	List(ctx context.Context, dir Location) (*DirListing, error)
+}
+
+// Walk lists the directory at the root Location and every directory below it,
+// calling fn with the listing of each directory. Directories are visited depth
+// first, a directory is visited before its sub directories.
+//
+// Every directory below root is listed by ID. If listing a directory fails, the walk
+// stops and the error is returned.
+func (s *DirService) Walk(ctx context.Context, root Location, fn WalkFunc) error {
+	return Walk(ctx, s, root, fn)
+}
+
+// Walk is DirService.Walk with the directories listed by the Lister l.
+func Walk(ctx context.Context, l Lister, root Location, fn WalkFunc) error {
+	listing, err := l.List(ctx, root)
+	if err != nil {
+		return err
+	}
+
+	return walk(ctx, l, listing, 0, fn)
+}
+
+// walk calls fn with the listing, and then walks each of its sub directories.
+func walk(ctx context.Context, l Lister, listing *DirListing, depth int, fn WalkFunc) error {
+	if err := fn(listing, depth); err != nil {
+		if errors.Is(err, SkipDir) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, d := range listing.Dirs {
+		sub, err := l.List(ctx, ID(d.ID))
+		if err != nil {
+			return fmt.Errorf("listing '%s': %w", d.DirPath, err)
+		}
+
+		if err := walk(ctx, l, sub, depth+1, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
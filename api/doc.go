@@ -0,0 +1,20 @@
+// Package api is the Go client of the Clox API.
+//
+// The api package is used by the Clox CLI, and can be imported by any Go program
+// that needs to integrate with a Clox server. Requests are made with a *Client,
+// which is created with a base URL and configured with functional options. The
+// endpoints are grouped into services that are accessed from the Client:
+//
+//	client := api.New("https://clox.example.com",
+//		api.WithToken(token),
+//		api.WithRetries(3),
+//		api.WithBackoff(api.Backoff{Base: time.Second, Max: 30 * time.Second, Jitter: 0.2}),
+//		api.WithTimeout(30*time.Second))
+//	dir, err := client.Dirs().Create(ctx, api.Path("docs"), "reports")
+//	res, err := client.Uploads().Create(ctx, api.ID(dir.ID), api.UploadParams{...})
+//
+// Directories and files on the server are identified by a Location, which is
+// either a path (Path) or an ID (ID).
+//
+// Every error response from the server is returned as an *APIError.
+package api
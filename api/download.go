@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// FileService calls the file endpoints of the Clox API. FileService should be
+// accessed by calling Client.Files.
+type FileService struct {
+	client *Client
+}
+
+// ByteRange is an inclusive range of bytes of a file. An End of -1 is the end of
+// the file.
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// header returns the value of the Range header of this ByteRange.
+func (r ByteRange) header() string {
+	if r.End < 0 {
+		return fmt.Sprintf("bytes=%d-", r.Start)
+	}
+
+	return fmt.Sprintf("bytes=%d-%d", r.Start, r.End)
+}
+
+// String returns this ByteRange in the format start-end.
+func (r ByteRange) String() string {
+	if r.End < 0 {
+		return strconv.FormatInt(r.Start, 10) + "-"
+	}
+
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// Get calls the API to get the file at the Location. Nothing is downloaded, only
+// the file metadata is returned.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *FileService) Get(ctx context.Context, file Location) (*File, error) {
+	path, query := file.endpoint("api/file")
+	respData := &File{}
+	if err := s.client.do(ctx, respData, request{
+		method: "GET",
+		path:   path,
+		query:  query,
+		file:   true,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// Download calls the API to download the file at the Location and writes it to w.
+// The contents are written as they are stored on the server, encrypted. It returns
+// the number of bytes written.
+//
+// If the API responds with an error (non-200 status code), nothing is written and
+// it returns an *APIError.
+func (s *FileService) Download(ctx context.Context, file Location, w io.Writer) (int64, error) {
+	path, query := file.endpoint("api/download")
+	n, _, err := s.client.stream(ctx, w, request{
+		method: "GET",
+		path:   path,
+		query:  query,
+		file:   true,
+	})
+	return n, err
+}
+
+// DownloadRange calls the API to download the ByteRange of the file at the Location
+// and writes it to w. The range is of the encrypted contents of the file. If the
+// range extends past the end of the file, it is written up to the end of the file.
+// It returns the number of bytes written.
+//
+// If the API responds with an error (non-200 status code), nothing is written and
+// it returns an *APIError. A range that starts past the end of the file responds
+// with a 416 status code.
+func (s *FileService) DownloadRange(ctx context.Context, file Location, rng ByteRange, w io.Writer) (int64, error) {
+	path, query := file.endpoint("api/download")
+	n, _, err := s.client.stream(ctx, w, request{
+		method: "GET",
+		path:   path,
+		query:  query,
+		file:   true,
+		header: map[string]string{"Range": rng.header()},
+	})
+	return n, err
+}
+
+// stream sends the request as a transfer and copies the response body to w. It returns the
+// number of bytes written and the response header. A server that does not support
+// ranges responds to a range request with the whole file, which is rejected since
+// the range would be written at the wrong offset.
+func (c *Client) stream(ctx context.Context, w io.Writer, r request) (int64, http.Header, error) {
+	r.transfer = true
+	res, err := c.send(ctx, r)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+	case res.StatusCode == http.StatusOK && r.header["Range"] == "":
+	case res.StatusCode == http.StatusOK:
+		return 0, nil, fmt.Errorf("server does not support byte ranges")
+	default:
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("reading body: %w", err)
+		}
+		return 0, nil, parseErrorResponse(body, res.StatusCode)
+	}
+
+	n, err := io.Copy(w, res.Body)
+	if err != nil {
+		return n, nil, fmt.Errorf("reading body: %w", err)
+	}
+
+	return n, res.Header, nil
+}
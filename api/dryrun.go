@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// dryRunBodyLimit is the size of the largest JSON body that a DryRun writes, a
+// larger body is written as its size.
+const dryRunBodyLimit = 1024
+
+// DryRun returns an Interceptor that does not send the requests that change
+// something on the server, such as creating a directory, uploading a file, or
+// deleting one. The method, URL, and size of the body of the request are written to
+// w instead, with the body if it is a small JSON object, and a response of an empty
+// JSON object is returned as if the request succeeded. The fields of the response
+// are left empty, such as the ID of an uploaded file.
+//
+// The requests that only read, such as listing a directory, are sent, so a command
+// can still look up what it would change.
+func DryRun(w io.Writer) Interceptor {
+	return func(next Handler) Handler {
+		return func(r *http.Request) (*http.Response, error) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(r)
+			}
+
+			var body []byte
+			if r.Body != nil {
+				if r.ContentLength > 0 && r.ContentLength <= dryRunBodyLimit {
+					body, _ = io.ReadAll(r.Body)
+				}
+				r.Body.Close()
+			}
+			fmt.Fprintf(w, "Would send: %s %s%s\n", r.Method, dryRunURL(r.URL), dryRunSize(r.ContentLength))
+			if json.Valid(body) {
+				fmt.Fprintf(w, "-> Body: %s\n", body)
+			}
+
+			return &http.Response{
+				Status:        "200 OK",
+				StatusCode:    http.StatusOK,
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        http.Header{"Content-Type": {"application/json"}},
+				Body:          io.NopCloser(strings.NewReader("{}")),
+				ContentLength: 2,
+				Request:       r,
+			}, nil
+		}
+	}
+}
+
+// dryRunURL returns the path and query of u with the query unescaped, so the paths
+// of the targets can be read as is.
+func dryRunURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+
+	query, err := url.QueryUnescape(u.RawQuery)
+	if err != nil {
+		query = u.RawQuery
+	}
+
+	return u.Path + "?" + query
+}
+
+// dryRunSize returns the size of a request body of length n, it is empty if the
+// request has no body.
+func dryRunSize(n int64) string {
+	switch {
+	case n == 0:
+		return ""
+	case n < 0:
+		return " (unknown size)"
+	default:
+		return fmt.Sprintf(" (%d bytes)", n)
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// The kinds of errors the API responds with. An *APIError wraps one of these when
+// the error response is recognized, use errors.Is to check the kind of an error:
+//
+//	if errors.Is(err, api.ErrNameConflict) {
+//		// A directory or file with the name already exists.
+//	}
+//
+// The kinds of the status codes are broader, they are wrapped in addition to a more
+// specific kind. A missing directory is both an ErrInvalidPath and an ErrNotFound,
+// and a revoked token is both an ErrTokenRevoked and an ErrUnauthorized.
+//
+// An *APIError that is not recognized does not wrap any of these errors.
+var (
+	// ErrQuotaExceeded is the error when the request would exceed the users storage
+	// quota.
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	// ErrNameConflict is the error when a directory or file with the same name
+	// already exists in the directory.
+	ErrNameConflict = errors.New("name conflict")
+	// ErrInvalidPath is the error when a path is malformed or a directory in the
+	// path does not exist.
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrTokenRevoked is the error when the API token has been revoked or has
+	// expired. A new token must be configured.
+	ErrTokenRevoked = errors.New("api token revoked")
+	// ErrPreconditionFailed is the error when a conditional request is rejected,
+	// the resource changed on the server since its ETag was read.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrLocked is the error when a file is locked by another user.
+	ErrLocked = errors.New("file locked")
+	// ErrRateLimited is the error when the server rejected the request because too
+	// many requests were sent. The request can be sent again later.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrUnauthorized is the error when the server did not accept the API token
+	// (401).
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound is the error when the directory or file does not exist (404).
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is the error when the request conflicts with the current state of
+	// the directory or file (409).
+	ErrConflict = errors.New("conflict")
+	// ErrTooLarge is the error when the request body is larger than the server
+	// accepts (413).
+	ErrTooLarge = errors.New("too large")
+)
+
+// statusKinds maps the status codes of an error response to the broad kind of the
+// error.
+var statusKinds = map[int]error{
+	http.StatusUnauthorized:          ErrUnauthorized,
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusConflict:              ErrConflict,
+	http.StatusRequestEntityTooLarge: ErrTooLarge,
+	http.StatusInsufficientStorage:   ErrQuotaExceeded,
+	http.StatusTooManyRequests:       ErrRateLimited,
+}
+
+// ErrUnreachable is the error when a request could not be sent to the server, such
+// as when the network is down or the server is not running. It is not an *APIError,
+// the server never responded.
+var ErrUnreachable = errors.New("server unreachable")
+
+// errorCodes maps the machine readable error codes of an error response to the
+// kind of the error.
+var errorCodes = map[string]error{
+	"quota_exceeded":      ErrQuotaExceeded,
+	"name_conflict":       ErrNameConflict,
+	"invalid_path":        ErrInvalidPath,
+	"token_revoked":       ErrTokenRevoked,
+	"token_expired":       ErrTokenRevoked,
+	"precondition_failed": ErrPreconditionFailed,
+	"locked":              ErrLocked,
+	"rate_limited":        ErrRateLimited,
+}
+
+// ErrorResponse is the response body when the API server responds with an error.
+// Every error response from the server conforms to this structure. The Code field
+// is optional, it is a machine readable error code.
+type ErrorResponse struct {
+	Err        string `json:"error"`
+	StatusCode int    `json:"status_code"`
+	Code       string `json:"code,omitempty"`
+}
+
+// APIError is a custom error type that represents an HTTP error response from the
+// API.
+//
+// APIError satisfies the error interface. If the error response is recognized, the
+// APIError wraps the kind of error, such as ErrNameConflict.
+type APIError struct {
+	Err        string
+	StatusCode int
+	Code       string
+	kind       error
+}
+
+// The function that satisfies the error interface.
+func (e *APIError) Error() string {
+	return e.Err
+}
+
+// Unwrap returns the kind of this APIError. If the kind is not recognized it returns
+// nil.
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// parseErrorResponse will unmarshal an API error response and return it as a
+// *APIError. The JSON in the []byte is unmarshalled into an ErrorResponse. The
+// ErrorResponse is then used to construct and return a *APIError.
+//
+// If unmarshalling the []byte fails, it will still return a *APIError, but the
+// Err field will specify that parsing the API error response failed. The APIError
+// wraps the kind of the status code, such as ErrUnauthorized for an empty 401 or
+// ErrNotFound for the HTML page of a proxy. If this ever happens, most likely the
+// server is responding with invalid data or the request did not reach it.
+func parseErrorResponse(b []byte, statusCode int) error {
+	var errResp ErrorResponse
+	if err := json.Unmarshal(b, &errResp); err != nil {
+		return &APIError{
+			StatusCode: statusCode,
+			Err:        "Failed to parse API error response",
+			kind:       statusKinds[statusCode],
+		}
+	}
+
+	// Not every endpoint sets the status code in the body, such as the OAuth
+	// endpoints.
+	if errResp.StatusCode == 0 {
+		errResp.StatusCode = statusCode
+	}
+
+	return &APIError{
+		Err:        errResp.Err,
+		StatusCode: errResp.StatusCode,
+		Code:       errResp.Code,
+		kind:       classify(errResp),
+	}
+}
+
+// classify returns the kind of the error response. The specific kind is joined with
+// the kind of the status code, if they differ. If the error response is not
+// recognized it returns nil.
+func classify(r ErrorResponse) error {
+	specific, broad := specificKind(r), statusKinds[r.StatusCode]
+	switch {
+	case specific == nil:
+		return broad
+	case broad == nil || broad == specific:
+		return specific
+	default:
+		return errors.Join(specific, broad)
+	}
+}
+
+// specificKind returns the specific kind of the error response. The error code is
+// used if the server sent one, otherwise the status code and message are checked. If
+// the error response is not recognized it returns nil.
+func specificKind(r ErrorResponse) error {
+	if kind, ok := errorCodes[r.Code]; ok {
+		return kind
+	}
+
+	msg := strings.ToLower(r.Err)
+	switch r.StatusCode {
+	case http.StatusRequestEntityTooLarge:
+		if strings.Contains(msg, "quota") {
+			return ErrQuotaExceeded
+		}
+	case http.StatusConflict:
+		return ErrNameConflict
+	case http.StatusBadRequest, http.StatusNotFound:
+		if strings.Contains(msg, "path") {
+			return ErrInvalidPath
+		}
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case http.StatusLocked:
+		return ErrLocked
+	case http.StatusUnauthorized:
+		if strings.Contains(msg, "revoked") || strings.Contains(msg, "expired") {
+			return ErrTokenRevoked
+		}
+	}
+
+	return nil
+}
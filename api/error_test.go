@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestParseErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+		want       []error
+		notWant    []error
+	}{
+		{
+			name:       "empty 401",
+			statusCode: http.StatusUnauthorized,
+			want:       []error{ErrUnauthorized},
+			notWant:    []error{ErrTokenRevoked},
+		},
+		{
+			name:       "html 404",
+			body:       "<html><body><h1>404 Not Found</h1></body></html>",
+			statusCode: http.StatusNotFound,
+			want:       []error{ErrNotFound},
+			notWant:    []error{ErrInvalidPath},
+		},
+		{
+			name:       "html 413",
+			body:       "<html><body>413 Request Entity Too Large</body></html>",
+			statusCode: http.StatusRequestEntityTooLarge,
+			want:       []error{ErrTooLarge},
+			notWant:    []error{ErrQuotaExceeded},
+		},
+		{
+			name:       "html 502",
+			body:       "<html>Bad Gateway</html>",
+			statusCode: http.StatusBadGateway,
+			notWant:    []error{ErrNotFound, ErrUnauthorized},
+		},
+		{
+			name:       "json code",
+			body:       `{"error":"quota exceeded","status_code":413,"code":"quota_exceeded"}`,
+			statusCode: http.StatusRequestEntityTooLarge,
+			want:       []error{ErrQuotaExceeded, ErrTooLarge},
+		},
+		{
+			name:       "json without status code",
+			body:       `{"error":"token has been revoked"}`,
+			statusCode: http.StatusUnauthorized,
+			want:       []error{ErrTokenRevoked, ErrUnauthorized},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseErrorResponse([]byte(tt.body), tt.statusCode)
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("parseErrorResponse() = %v, want an *APIError", err)
+			}
+			if apiErr.StatusCode != tt.statusCode {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tt.statusCode)
+			}
+			for _, kind := range tt.want {
+				if !errors.Is(err, kind) {
+					t.Errorf("errors.Is(%v, %v) = false, want true", err, kind)
+				}
+			}
+			for _, kind := range tt.notWant {
+				if errors.Is(err, kind) {
+					t.Errorf("errors.Is(%v, %v) = true, want false", err, kind)
+				}
+			}
+		})
+	}
+}
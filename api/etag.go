@@ -0,0 +1,43 @@
+package api
+
+// AnyETag matches any version of a resource. It is sent in the If-None-Match header
+// when creating a resource, so the request fails if the resource already exists.
+const AnyETag = "*"
+
+// Conditional requests.
+//
+// When the server exposes ETags, every mutating request is sent with a
+// precondition so that a write never silently replaces a change made by someone
+// else. Creates are sent with If-None-Match: *, and overwrites are sent with
+// If-Match and the ETag of the version that is being replaced. If the precondition
+// fails the server responds with 412 Precondition Failed, which is an *APIError
+// that wraps ErrPreconditionFailed.
+const (
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// etagger is implemented by the response bodies of a single resource. If the
+// response has an ETag header and the body did not contain one, it is set with
+// setETag.
+type etagger interface {
+	etag() string
+	setETag(etag string)
+}
+
+func (d *Dir) etag() string {
+	return d.ETag
+}
+
+func (d *Dir) setETag(etag string) {
+	d.ETag = etag
+}
+
+func (f *File) etag() string {
+	return f.ETag
+}
+
+func (f *File) setETag(etag string) {
+	f.ETag = etag
+}
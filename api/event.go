@@ -0,0 +1,112 @@
+package api
+
+import "io"
+
+// EventKind is the kind of a transfer Event.
+type EventKind string
+
+const (
+	// EventStarted is sent when the contents of a file start transferring.
+	EventStarted EventKind = "started"
+	// EventProgress is sent as the contents of a file are transferred.
+	EventProgress EventKind = "progress"
+	// EventCompleted is sent when the server accepted a file.
+	EventCompleted EventKind = "completed"
+	// EventFailed is sent when a file failed to transfer.
+	EventFailed EventKind = "failed"
+	// EventRetry is sent when a request is retried.
+	EventRetry EventKind = "retry"
+)
+
+// Event describes the progress of a transfer. Which fields are set depends on the
+// Kind of the Event.
+type Event struct {
+	Kind EventKind
+	// The local path of the file. Not set for EventRetry.
+	Path string
+	// The name of the file on the server. Not set for EventRetry.
+	Name string
+	// The number of bytes of the file that have been transferred.
+	Bytes int64
+	// The total number of bytes of the file being transferred. This is the size of
+	// the encrypted contents for uploads.
+	Total int64
+	// The attempt number of the request, set for EventRetry.
+	Attempt int
+	// The error that caused the failure or retry, set for EventFailed and
+	// EventRetry.
+	Err error
+}
+
+// EventFunc is called with every Event of a transfer. An EventFunc is called from
+// the goroutine doing the transfer and should return quickly.
+type EventFunc func(Event)
+
+// emit calls f with e if f is not nil.
+func (f EventFunc) emit(e Event) {
+	if f != nil {
+		f(e)
+	}
+}
+
+// bodyPart is the byte range of a request body that contains the contents of a
+// file.
+type bodyPart struct {
+	path  string
+	name  string
+	start int64
+	end   int64
+}
+
+// progressReader reports the transfer of each part of a request body as it is
+// read.
+type progressReader struct {
+	r       io.ReadCloser
+	parts   []bodyPart
+	events  EventFunc
+	read    int64
+	next    int
+	started bool
+}
+
+// newProgressReader creates a *progressReader that reads r and sends events for
+// each of the parts.
+func newProgressReader(r io.ReadCloser, parts []bodyPart, events EventFunc) *progressReader {
+	return &progressReader{r: r, parts: parts, events: events}
+}
+
+// Read reads from the underlying reader. An EventStarted is sent the first time a
+// part is read, and an EventProgress is sent every time a part is read.
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+
+	for p.next < len(p.parts) {
+		part := p.parts[p.next]
+		if p.read <= part.start {
+			break
+		}
+
+		total := part.end - part.start
+		if !p.started {
+			p.started = true
+			p.events.emit(Event{Kind: EventStarted, Path: part.path, Name: part.name, Total: total})
+		}
+
+		sent := min(p.read-part.start, total)
+		p.events.emit(Event{Kind: EventProgress, Path: part.path, Name: part.name, Bytes: sent, Total: total})
+		if sent < total {
+			break
+		}
+
+		p.next++
+		p.started = false
+	}
+
+	return n, err
+}
+
+// Close closes the underlying reader.
+func (p *progressReader) Close() error {
+	return p.r.Close()
+}
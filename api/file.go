@@ -0,0 +1,309 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encrypter is the interface that wraps the Encrypt method.
+//
+// Encrypt encrypts data with key and returns the encrypted data. An Encrypter is
+// used to encrypt the contents of every file before it is uploaded, the server
+// never receives the plain text.
+type Encrypter interface {
+	Encrypt(data []byte, key []byte) ([]byte, error)
+}
+
+// UploadService calls the upload endpoints of the Clox API. UploadService should
+// be accessed by calling Client.Uploads.
+type UploadService struct {
+	client *Client
+}
+
+// UploadFileResponse is the result of a successful file upload. Each
+// UploadFileResponse corresponds to a single file. This is a single entry within
+// UploadResponse.Uploads.
+type UploadFileResponse struct {
+	ID          string    `json:"id"`
+	OwnerID     string    `json:"owner_id"`
+	DirectoryID string    `json:"directory_id"`
+	Name        string    `json:"file_name"`
+	Path        string    `json:"file_path"`
+	Size        int64     `json:"file_size"`
+	ContentType string    `json:"content_type,omitempty"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+	ETag        string    `json:"etag,omitempty"`
+	Checksum    string    `json:"checksum,omitempty"`
+}
+
+// File returns this UploadFileResponse as the File on the server.
+func (u UploadFileResponse) File() File {
+	return File{
+		ID:          u.ID,
+		OwnerID:     u.OwnerID,
+		DirectoryID: u.DirectoryID,
+		Name:        u.Name,
+		Path:        u.Path,
+		Size:        u.Size,
+		ContentType: u.ContentType,
+		UploadedAt:  u.UploadedAt,
+		ETag:        u.ETag,
+		Checksum:    u.Checksum,
+	}
+}
+
+// UploadErrorResponse is the result of a failed file upload. Each
+// UploadErrorResponse corresponds to a single file failure. This is a single
+// entry within UploadResponse.Errors.
+type UploadErrorResponse struct {
+	FileName string `json:"file_name"`
+	Size     int64  `json:"file_size"`
+	Error    string `json:"error"`
+}
+
+// UploadResponse is the response body of the POST request when uploading files.
+type UploadResponse struct {
+	Uploads []UploadFileResponse  `json:"uploads"`
+	Errors  []UploadErrorResponse `json:"errors"`
+}
+
+// FileUpload represents a file to be read, encrypted, and written to the server.
+type FileUpload struct {
+	// The local path to the file. This is the path to the file that will be
+	// uploaded.
+	Path string
+	// The file name for the encrypted file on the server. The contents of this
+	// file will be the encrypted contents of the file defined in Path.
+	Filename string
+	// Encrypted is set if the file at Path is already encrypted, such as a file
+	// staged for a later upload. It is uploaded as is.
+	Encrypted bool
+	// ContentType is the MIME type of the plain text contents of the file. If it is
+	// not set, it is detected with DetectContentType. It is required for a file
+	// that is already encrypted, or the server will not know its type.
+	ContentType string
+	// Checksum is the checksum of the plain text contents of the file, it is stored
+	// with the file as is. If it is not set, and the Encrypter of the upload is a
+	// Checksummer, it is worked out with the Checksummer. It is required for a file
+	// that is already encrypted, or the file has no checksum.
+	Checksum string
+	// IfMatch is the ETag of the file on the server that this upload replaces. If
+	// the file on the server no longer has this ETag, the server rejects the
+	// upload. It is only used with UploadParams.Overwrite.
+	IfMatch string
+}
+
+// UploadParams is the parameters needed when uploading files.
+type UploadParams struct {
+	// The file(s) metadata.
+	Uploads []FileUpload
+	// The encryption key for encrypting the files.
+	Key []byte
+	// The encryption algorithm used to encrypt.
+	Alg Encrypter
+	// The function called with the progress of each file. This is optional.
+	Events EventFunc
+	// Overwrite replaces the files on the server that have the same name. Without
+	// it the server rejects them with a name conflict.
+	Overwrite bool
+}
+
+// Create calls the API to upload files. The dir is the Location of the directory
+// that the files will be written to. An empty path will upload the files to the
+// users root directory on the server.
+//
+// Every file that is uploaded will be encrypted with the encryption key
+// (UploadParams.Key) using the encryption algorithm (UploadParams.Alg).
+//
+// The files to be uploaded are defined in UploadParams.Uploads. Each FileUpload
+// represents a file that will be read, encrypted, and uploaded. The Path is the
+// location on the local machine, and Filename is the name of the encrypted file
+// to be written to the server.
+//
+// The request body is streamed, each file is read and encrypted as it is sent. If
+// UploadParams.Alg is a StreamEncrypter, no file is held in memory. Otherwise every
+// file is read and encrypted before the request is sent, and the encrypted contents
+// are kept until it is done. If a file changes size while it is uploaded, the
+// request fails and is not retried.
+//
+// The content type of each file is detected before it is encrypted, and sent in the
+// Clox-Content-Type header of its part of the multipart body. The server cannot
+// detect it from the encrypted contents. The checksum of each file is sent the same
+// way in the Clox-Checksum header, see Checksummer.
+//
+// If UploadParams.Overwrite is set, files with the same name in the directory are
+// replaced. It is up to the caller to check that the files on the server have not
+// changed before they are replaced. The FileUpload.IfMatch of each file is sent in
+// the header of its part of the multipart body, and in the If-Match header of the
+// request when a single file is uploaded. Without UploadParams.Overwrite, the
+// request is sent with If-None-Match: *.
+//
+// If UploadParams.Events is set, it is called as each file is sent, when each file
+// is accepted or rejected by the server, and when the request is retried. A file
+// that is rejected is also in UploadResponse.Errors.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *UploadService) Create(ctx context.Context, dir Location, p UploadParams) (*UploadResponse, error) {
+	parts := []uploadPart{}
+	for i, u := range p.Uploads {
+		path := u.Path
+		filename := u.Filename
+
+		part, err := newUploadPart(u, s.client.encodeName(filename), p.Alg, p.Key)
+		if err != nil {
+			err = fmt.Errorf("reading '%s' [index: %d]: %w", path, i, err)
+			p.Events.emit(Event{Kind: EventFailed, Path: path, Name: filename, Err: err})
+			return nil, err
+		}
+
+		// An Encrypter that cannot encrypt a file as it is read needs all of
+		// it, the encrypted contents are kept until the body is sent.
+		if part.buffered(p.Alg) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				err = fmt.Errorf("reading '%s' [index: %d]: %w", path, i, err)
+				p.Events.emit(Event{Kind: EventFailed, Path: path, Name: filename, Err: err})
+				return nil, err
+			}
+
+			encData, err := p.Alg.Encrypt(data, p.Key)
+			if err != nil {
+				err = fmt.Errorf("encrypting '%s' [index: %d]: %w", path, i, err)
+				p.Events.emit(Event{Kind: EventFailed, Path: path, Name: filename, Err: err})
+				return nil, err
+			}
+			part.setData(encData)
+		}
+
+		parts = append(parts, part)
+	}
+
+	body, err := newMultipartBody(parts, p.Alg, p.Key)
+	if err != nil {
+		return nil, fmt.Errorf("creating multipart body: %w", err)
+	}
+
+	path, query := dir.endpoint("api/upload")
+	header := map[string]string{"Content-Type": body.contentType()}
+	if p.Overwrite {
+		if query == nil {
+			query = map[string]string{}
+		}
+		query["overwrite"] = "true"
+		if len(p.Uploads) == 1 && p.Uploads[0].IfMatch != "" {
+			header[headerIfMatch] = p.Uploads[0].IfMatch
+		}
+	} else {
+		header[headerIfNoneMatch] = AnyETag
+	}
+	respData := &UploadResponse{}
+	if err := s.client.do(ctx, respData, request{
+		method:   "POST",
+		path:     path,
+		stream:   body.open,
+		size:     body.size,
+		query:    query,
+		header:   header,
+		parts:    body.ranges,
+		events:   p.Events,
+		transfer: true,
+	}); err != nil {
+		for _, part := range body.ranges {
+			p.Events.emit(Event{Kind: EventFailed, Path: part.path, Name: part.name, Err: err})
+		}
+		return nil, err
+	}
+
+	if p.Events != nil {
+		emitUploadResults(p.Events, body.ranges, respData)
+	}
+
+	return respData, nil
+}
+
+// AppendParams is the parameters needed when appending to a file.
+type AppendParams struct {
+	// The encrypted data to append to the file.
+	Data []byte
+	// The size of the file on the server that the data is appended to. If the file
+	// is no longer this size, the server rejects the data.
+	Offset int64
+	// IfMatch is the ETag of the file on the server that is appended to. This is
+	// optional.
+	IfMatch string
+	// Checksum is the checksum of the plain text contents of the whole file after
+	// the data is appended, see Checksummer. If it is not set, the server drops the
+	// checksum of the file. This is optional.
+	Checksum string
+}
+
+// Append calls the API to append data to the end of the file at the Location. The
+// data is written as is, it must be encrypted so it can be appended to the
+// encrypted contents of the file, see crypto.AES.EncryptChunks.
+//
+// The offset is sent in the Upload-Offset header. If the size of the file on the
+// server does not match the offset, or the file no longer has the ETag of
+// AppendParams.IfMatch, the server rejects the request with an *APIError that wraps
+// ErrPreconditionFailed.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *UploadService) Append(ctx context.Context, file Location, p AppendParams) (*File, error) {
+	header := map[string]string{
+		"Content-Type":  "application/octet-stream",
+		"Upload-Offset": strconv.FormatInt(p.Offset, 10),
+	}
+	if p.IfMatch != "" {
+		header[headerIfMatch] = p.IfMatch
+	}
+	if p.Checksum != "" {
+		header[headerChecksum] = p.Checksum
+	}
+
+	path, query := file.endpoint("api/upload")
+	respData := &File{}
+	if err := s.client.do(ctx, respData, request{
+		method: "PATCH",
+		path:   path,
+		body:   p.Data,
+		query:  query,
+		file:   true,
+		header: header,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// quoteEscaper escapes the quotes of a filename the same as mime/multipart.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// emitUploadResults sends an EventCompleted for every uploaded file, and an
+// EventFailed for every file in the errors of the response.
+func emitUploadResults(events EventFunc, parts []bodyPart, res *UploadResponse) {
+	failed := map[string]string{}
+	for _, e := range res.Errors {
+		failed[e.FileName] = e.Error
+	}
+
+	for _, part := range parts {
+		total := part.end - part.start
+		if msg, ok := failed[part.name]; ok {
+			events.emit(Event{Kind: EventFailed, Path: part.path, Name: part.name, Total: total, Err: errors.New(msg)})
+			continue
+		}
+
+		events.emit(Event{Kind: EventCompleted, Path: part.path, Name: part.name, Bytes: total, Total: total})
+	}
+}
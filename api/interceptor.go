@@ -0,0 +1,71 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Handler sends a *http.Request and returns the *http.Response.
+type Handler func(*http.Request) (*http.Response, error)
+
+// Interceptor wraps a Handler with behaviour that runs before the request is sent
+// and after the response is received. An Interceptor must call next to send the
+// request, unless it is intentionally short-circuiting the request.
+//
+// Interceptors implement concerns shared by every endpoint, such as logging,
+// metrics, and signing, without changing the endpoint functions.
+type Interceptor func(next Handler) Handler
+
+// WithInterceptors adds interceptors to the Client. The interceptors run in the
+// order they are given, the first interceptor sees the request first and the
+// response last. Calling WithInterceptors more than once appends to the chain.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *Client) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// chain returns the Handler that sends requests through the interceptors of this
+// Client, ending with the *http.Client. If transfer is set, the *http.Client with
+// the transfer timeout is used.
+func (c *Client) chain(transfer bool) Handler {
+	h := Handler(c.http.Do)
+	if transfer {
+		h = c.transfer.Do
+	}
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		h = c.interceptors[i](h)
+	}
+
+	return h
+}
+
+// LogRequests returns an Interceptor that logs every request to logger at the debug
+// level. The method, URL, status code, and duration of the request are logged. The
+// endpoint is the base URL that served the request, which is a replica if the Client
+// failed over to one.
+func LogRequests(logger *slog.Logger) Interceptor {
+	return func(next Handler) Handler {
+		return func(r *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next(r)
+			if err != nil {
+				logger.Debug("api request failed",
+					"method", r.Method,
+					"url", r.URL.String(),
+					"duration", time.Since(start),
+					"error", err)
+				return nil, err
+			}
+
+			logger.Debug("api request",
+				"method", r.Method,
+				"url", r.URL.String(),
+				"endpoint", r.URL.Scheme+"://"+r.URL.Host,
+				"status", res.StatusCode,
+				"duration", time.Since(start))
+			return res, nil
+		}
+	}
+}
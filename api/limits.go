@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// Limits is the response body of the GET request when getting the upload limits of
+// the user. A limit of zero means there is no limit.
+type Limits struct {
+	// The maximum size of an uploaded file, in bytes.
+	MaxFileSize int64 `json:"max_file_size"`
+	// The content types that can be uploaded, such as "image/png" or "image/*". If
+	// empty, every content type can be uploaded.
+	AllowedTypes []string `json:"allowed_types"`
+	// The storage quota of the user, in bytes.
+	Quota int64 `json:"quota"`
+	// The storage used by the user, in bytes.
+	Used int64 `json:"used"`
+}
+
+// Remaining returns the storage remaining in the quota, in bytes. If there is no
+// quota it returns -1.
+func (l *Limits) Remaining() int64 {
+	if l.Quota == 0 {
+		return -1
+	}
+
+	return max(l.Quota-l.Used, 0)
+}
+
+// Allows checks if a file with the content type can be uploaded. The parameters of
+// the content type, such as the charset, are ignored.
+func (l *Limits) Allows(contentType string) bool {
+	if len(l.AllowedTypes) == 0 {
+		return true
+	}
+
+	base, _, _ := strings.Cut(contentType, ";")
+	base = strings.TrimSpace(base)
+	for _, pattern := range l.AllowedTypes {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Limits calls the API to get the upload limits of the user. Uploads can be checked
+// against the Limits before anything is encrypted or sent.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *UploadService) Limits(ctx context.Context) (*Limits, error) {
+	respData := &Limits{}
+	if err := s.client.do(ctx, respData, request{
+		method: "GET",
+		path:   "api/limits",
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
@@ -0,0 +1,46 @@
+package api
+
+// Location identifies a directory or file on the server. A Location is either a
+// path or an ID, and should be created by calling Path or ID.
+type Location struct {
+	// The path of the directory or file. An empty path is the users root directory.
+	Path string `json:"path,omitempty"`
+	// The ID of the directory or file.
+	ID string `json:"id,omitempty"`
+}
+
+// Path returns a Location that identifies a directory or file by its path.
+func Path(path string) Location {
+	return Location{Path: path}
+}
+
+// ID returns a Location that identifies a directory or file by its ID.
+func ID(id string) Location {
+	return Location{ID: id}
+}
+
+// IsID checks if this Location identifies a directory or file by its ID.
+func (l Location) IsID() bool {
+	return l.ID != ""
+}
+
+// String returns the ID of this Location, if it identifies by ID. Otherwise it
+// returns the path.
+func (l Location) String() string {
+	if l.IsID() {
+		return l.ID
+	}
+
+	return l.Path
+}
+
+// endpoint returns the URL path and query of the endpoint for this Location. A
+// Location that identifies by ID is appended to the endpoint path, otherwise the
+// path is set as the "path" query parameter.
+func (l Location) endpoint(path string) (string, map[string]string) {
+	if l.IsID() {
+		return path + "/" + l.ID, nil
+	}
+
+	return path, map[string]string{"path": l.Path}
+}
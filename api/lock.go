@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// LockService calls the lock endpoints of the Clox API. LockService should be
+// accessed by calling Client.Locks.
+//
+// Locks are advisory, the server does not stop a client that ignores them. They let
+// users that sync the same directories coordinate edits.
+type LockService struct {
+	client *Client
+}
+
+// Lock is an advisory lock on a file on the server. A Lock expires at ExpiresAt
+// unless it is acquired again before then.
+type Lock struct {
+	ID        string    `json:"id"`
+	FileID    string    `json:"file_id"`
+	FilePath  string    `json:"file_path"`
+	OwnerID   string    `json:"owner_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired checks if this Lock has expired.
+func (l *Lock) Expired() bool {
+	return !l.ExpiresAt.After(time.Now())
+}
+
+// HeldBy checks if this Lock is held by the user with the ownerID and has not
+// expired.
+func (l *Lock) HeldBy(ownerID string) bool {
+	return l.OwnerID == ownerID && !l.Expired()
+}
+
+// acquireLockRequestBody is the request body of the POST request when acquiring a
+// lock.
+type acquireLockRequestBody struct {
+	TTL int64 `json:"ttl_seconds"`
+}
+
+// Acquire calls the API to lock the file at the Location for ttl. If the file is
+// already locked by the user, the lock is extended.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. A file locked by another user responds with an *APIError that wraps
+// ErrLocked.
+func (s *LockService) Acquire(ctx context.Context, file Location, ttl time.Duration) (*Lock, error) {
+	jsonData, err := json.Marshal(&acquireLockRequestBody{TTL: int64(ttl / time.Second)})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling data: %w", err)
+	}
+
+	path, query := file.endpoint("api/lock")
+	respData := &Lock{}
+	if err := s.client.do(ctx, respData, request{
+		method: "POST",
+		path:   path,
+		body:   jsonData,
+		query:  query,
+		file:   true,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// Release calls the API to unlock the file at the Location. Only the user that
+// holds the lock can release it.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *LockService) Release(ctx context.Context, file Location) (*Lock, error) {
+	path, query := file.endpoint("api/lock")
+	respData := &Lock{}
+	if err := s.client.do(ctx, respData, request{
+		method: "DELETE",
+		path:   path,
+		query:  query,
+		file:   true,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
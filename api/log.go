@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+)
+
+// levelTrace is the level of the records that are more detailed than debug, such as
+// every attempt of a request.
+const levelTrace = slog.LevelDebug - 4
+
+// WithLogger sets the logger that the decisions of the Client are written to, such
+// as retrying a failed request, failing over to a replica, or waiting for a rate
+// limit. The decisions are written at the debug level, and every attempt of a
+// request below it. If not set, nothing is logged. To log every request, see
+// LogRequests.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// debug writes a record at the debug level to the logger of this Client, if it has
+// one.
+func (c *Client) debug(msg string, args ...any) {
+	if c.logger != nil {
+		c.logger.Debug(msg, args...)
+	}
+}
+
+// trace writes a record below the debug level to the logger of this Client, if it
+// has one.
+func (c *Client) trace(msg string, args ...any) {
+	if c.logger != nil {
+		c.logger.Log(context.Background(), levelTrace, msg, args...)
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// headerContentType is the header of a part of an upload with the content type of
+// the plain text contents of the file.
+const headerContentType = "Clox-Content-Type"
+
+// headerChecksum is the header of a part of an upload, or of an append, with the
+// checksum of the plain text contents of the file.
+const headerChecksum = "Clox-Checksum"
+
+// sniffLen is the number of bytes used to detect a content type, the same as
+// http.DetectContentType.
+const sniffLen = 512
+
+// genericTypes are the content types http.DetectContentType returns when it does
+// not recognize the data. The file extension is more specific than these.
+var genericTypes = map[string]bool{
+	"application/octet-stream": true,
+	"text/plain":               true,
+}
+
+// DetectContentType detects the content type of the plain text contents of the file
+// named name. The contents are sniffed first, if they are not recognized the
+// extension of the name is used. It always returns a valid MIME type, the default
+// is "application/octet-stream".
+//
+// Files are encrypted before they are uploaded, the server cannot detect the type
+// of a file itself.
+func DetectContentType(name string, data []byte) string {
+	sniffed := http.DetectContentType(data[:min(len(data), sniffLen)])
+	base, _, _ := strings.Cut(sniffed, ";")
+	if !genericTypes[base] {
+		return sniffed
+	}
+
+	if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+		return byExt
+	}
+
+	return sniffed
+}
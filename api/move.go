@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MoveParams is the request body of the PATCH request when moving a directory or
+// file.
+type MoveParams struct {
+	// The directory the directory or file is moved to. If it is not set, the
+	// directory or file stays in the directory it is in.
+	Parent *Location `json:"parent,omitempty"`
+	// The new name of the directory or file. If it is empty, the name is kept.
+	Name string `json:"name,omitempty"`
+}
+
+// Move calls the API to move the file at the Location, without the contents of the
+// file leaving the server. It returns the file as it is after the move.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. A file with the same name in the parent directory responds with a
+// 409 status code.
+func (s *FileService) Move(ctx context.Context, file Location, p MoveParams) (*File, error) {
+	p.Name = s.client.encodeName(p.Name)
+	jsonData, err := json.Marshal(&p)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling data: %w", err)
+	}
+
+	path, query := file.endpoint("api/file")
+	respData := &File{}
+	if err := s.client.do(ctx, respData, request{
+		method: "PATCH",
+		path:   path,
+		body:   jsonData,
+		query:  query,
+		file:   true,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// Move calls the API to move the directory at the Location, with everything below
+// it. It returns the directory as it is after the move. A directory cannot be moved
+// below itself.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. A directory with the same name in the parent directory responds
+// with a 409 status code.
+func (s *DirService) Move(ctx context.Context, dir Location, p MoveParams) (*Dir, error) {
+	jsonData, err := json.Marshal(&p)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling data: %w", err)
+	}
+
+	path, query := dir.endpoint("api/dir")
+	respData := &Dir{}
+	if err := s.client.do(ctx, respData, request{
+		method: "PATCH",
+		path:   path,
+		body:   jsonData,
+		query:  query,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// Rename calls the API to rename the file at the Location, in the directory it is
+// in. It returns the file as it is after the rename.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. A file with the name in the same directory responds with an
+// *APIError that wraps ErrNameConflict.
+func (s *FileService) Rename(ctx context.Context, file Location, name string) (*File, error) {
+	return s.Move(ctx, file, MoveParams{Name: name})
+}
+
+// Rename calls the API to rename the directory at the Location, in the directory it
+// is in. It returns the directory as it is after the rename.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. A directory with the name in the same directory responds with an
+// *APIError that wraps ErrNameConflict.
+func (s *DirService) Rename(ctx context.Context, dir Location, name string) (*Dir, error) {
+	return s.Move(ctx, dir, MoveParams{Name: name})
+}
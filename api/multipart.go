@@ -0,0 +1,288 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+)
+
+// StreamEncrypter is an Encrypter that can encrypt a file as it is read. When the
+// Encrypter of an upload is a StreamEncrypter, the files are streamed into the
+// request body and never held in memory.
+//
+// EncryptStream reads r until EOF, encrypts it with key, and writes it to w. It
+// returns the number of plain text bytes read. EncryptedSize returns the size of n
+// bytes of plain text after it is encrypted with EncryptStream.
+type StreamEncrypter interface {
+	Encrypter
+	EncryptStream(w io.Writer, r io.Reader, key []byte) (int64, error)
+	EncryptedSize(n int64) int64
+}
+
+// Checksummer is an Encrypter that works out the checksum of a file before it is
+// uploaded. The checksum is stored with the file on the server, so the contents can
+// be verified after they are downloaded and decrypted.
+//
+// Checksum reads r until EOF and returns the checksum of the plain text read. It
+// must not reveal the contents to the server, such as a digest encrypted with key.
+type Checksummer interface {
+	Checksum(r io.Reader, key []byte) (string, error)
+}
+
+// checksum returns the checksum of the file u, read from r, with the Checksummer
+// alg. If u already has a checksum, is already encrypted, or alg is not a
+// Checksummer, it returns the checksum of u.
+func checksum(u FileUpload, r io.Reader, alg any, key []byte) (string, error) {
+	cs, ok := alg.(Checksummer)
+	if u.Checksum != "" || u.Encrypted || !ok {
+		return u.Checksum, nil
+	}
+
+	return cs.Checksum(r, key)
+}
+
+// uploadPart is a file in the multipart body of an upload.
+type uploadPart struct {
+	path   string
+	name   string
+	header textproto.MIMEHeader
+	// plain is the size of the file on the file system.
+	plain int64
+	// size is the size of the contents of the part, after it is encrypted.
+	size int64
+	// data is the encrypted contents of the file, if the Encrypter of the upload
+	// cannot encrypt it as it is read. Otherwise it is nil and the file is read as
+	// the body is sent.
+	data []byte
+	// raw is set if the file is already encrypted and is sent as it is.
+	raw bool
+}
+
+// multipartBody is the multipart body of an upload. The files are read, and
+// encrypted, as the body is read, so it can be sent more than once when the request
+// is retried.
+type multipartBody struct {
+	boundary string
+	parts    []uploadPart
+	alg      Encrypter
+	key      []byte
+	// size is the length of the whole body.
+	size int64
+	// ranges are the byte ranges of the contents of each file in the body.
+	ranges []bodyPart
+}
+
+// errFileChanged is the error when a file changed size after its part of an upload
+// was prepared.
+var errFileChanged = errors.New("file changed while it was uploaded")
+
+// bodyError is an error of reading a local file while the request body is sent.
+// The request is not retried, sending it again will fail the same way.
+type bodyError struct {
+	err error
+}
+
+func (e *bodyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *bodyError) Unwrap() error {
+	return e.err
+}
+
+// newUploadPart prepares the part of the upload u, the file is sent with the name
+// it is stored with on the server. The file is opened to check that it can be read,
+// and to detect its content type from u.Filename. The checksum of the file is
+// worked out with the key, see Checksummer. If alg is not a StreamEncrypter, the
+// encrypted contents must be set with setData before the body is sent.
+func newUploadPart(u FileUpload, name string, alg Encrypter, key []byte) (uploadPart, error) {
+	f, err := os.Open(u.Path)
+	if err != nil {
+		return uploadPart{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return uploadPart{}, err
+	}
+
+	part := uploadPart{path: u.Path, name: u.Filename, plain: info.Size(), raw: u.Encrypted}
+
+	contentType := u.ContentType
+	if contentType == "" && !u.Encrypted {
+		head := make([]byte, sniffLen)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return uploadPart{}, err
+		}
+		contentType = DetectContentType(u.Filename, head[:n])
+	}
+
+	sum, err := checksum(u, io.NewSectionReader(f, 0, info.Size()), alg, key)
+	if err != nil {
+		return uploadPart{}, err
+	}
+	part.header = partHeader(name, contentType, sum, u.IfMatch)
+
+	if stream, ok := alg.(StreamEncrypter); ok && !u.Encrypted {
+		part.size = stream.EncryptedSize(info.Size())
+	} else {
+		part.size = info.Size()
+	}
+
+	return part, nil
+}
+
+// buffered checks if the encrypted contents of this uploadPart must be held in
+// memory, the Encrypter alg cannot encrypt it as it is read.
+func (p *uploadPart) buffered(alg Encrypter) bool {
+	_, ok := alg.(StreamEncrypter)
+	return !ok && !p.raw
+}
+
+// setData sets the encrypted contents of this uploadPart.
+func (p *uploadPart) setData(data []byte) {
+	p.data = data
+	p.size = int64(len(data))
+}
+
+// newMultipartBody creates the multipart body of the parts. The length of the body,
+// and the range of each file in it, is worked out before anything is sent.
+func newMultipartBody(parts []uploadPart, alg Encrypter, key []byte) (*multipartBody, error) {
+	b := &multipartBody{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+		parts:    parts,
+		alg:      alg,
+		key:      key,
+	}
+
+	counter := &countingWriter{}
+	writer := multipart.NewWriter(counter)
+	if err := writer.SetBoundary(b.boundary); err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		if _, err := writer.CreatePart(part.header); err != nil {
+			return nil, err
+		}
+		start := counter.n
+		counter.n += part.size
+		b.ranges = append(b.ranges, bodyPart{path: part.path, name: part.name, start: start, end: counter.n})
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	b.size = counter.n
+
+	return b, nil
+}
+
+// contentType returns the Content-Type header of this multipartBody.
+func (b *multipartBody) contentType() string {
+	return "multipart/form-data; boundary=" + b.boundary
+}
+
+// open returns a reader of this multipartBody. The body is written into an io.Pipe
+// by another goroutine as it is read. If a file cannot be read, reading fails with
+// a *bodyError. Closing the reader stops the goroutine.
+func (b *multipartBody) open() io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(b.write(w))
+	}()
+
+	return r
+}
+
+// write writes this multipartBody to w.
+func (b *multipartBody) write(w io.Writer) error {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(b.boundary); err != nil {
+		return err
+	}
+
+	for i, part := range b.parts {
+		partWriter, err := writer.CreatePart(part.header)
+		if err != nil {
+			return err
+		}
+		if err := b.writePart(partWriter, part); err != nil {
+			return &bodyError{err: fmt.Errorf("reading '%s' [index: %d]: %w", part.path, i, err)}
+		}
+	}
+
+	return writer.Close()
+}
+
+// writePart writes the contents of the part to w. The file is read and encrypted
+// as it is written, unless its encrypted contents are already in the part.
+func (b *multipartBody) writePart(w io.Writer, part uploadPart) error {
+	if part.data != nil {
+		_, err := w.Write(part.data)
+		return err
+	}
+
+	f, err := os.Open(part.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Only the size the part was prepared with is read, the length of the body is
+	// already set.
+	r := &io.LimitedReader{R: f, N: part.plain}
+	var n int64
+	if part.raw {
+		n, err = io.Copy(w, r)
+	} else {
+		n, err = b.alg.(StreamEncrypter).EncryptStream(w, r, b.key)
+	}
+	if err != nil {
+		return err
+	}
+
+	if n != part.plain {
+		return errFileChanged
+	}
+	if extra, _ := f.Read(make([]byte, 1)); extra > 0 {
+		return errFileChanged
+	}
+
+	return nil
+}
+
+// partHeader returns the header of the part of the multipart body with the
+// filename. If contentType is set, it is sent in the Clox-Content-Type header of the
+// part. If sum is set, it is sent in the Clox-Checksum header of the part. If
+// ifMatch is set, it is sent in the If-Match header of the part.
+func partHeader(filename string, contentType string, sum string, ifMatch string) textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file_uploads"; filename="%s"`,
+		escapeQuotes(filename)))
+	h.Set("Content-Type", "application/octet-stream")
+	if contentType != "" {
+		h.Set(headerContentType, contentType)
+	}
+	if sum != "" {
+		h.Set(headerChecksum, sum)
+	}
+	if ifMatch != "" {
+		h.Set(headerIfMatch, ifMatch)
+	}
+
+	return h
+}
+
+// countingWriter counts the bytes written to it and discards them.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.n += int64(len(b))
+	return len(b), nil
+}
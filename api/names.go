@@ -0,0 +1,128 @@
+package api
+
+import (
+	"net/http"
+	"path"
+)
+
+// NameCodec encrypts the names of files before they are sent to the server, and
+// decrypts the names the server responds with.
+//
+// Encode returns the name of a file as it is stored on the server. Decode returns the
+// name of a file from the name it is stored with. A name that was not encoded, such
+// as the name of a file that was uploaded before names were encrypted, must be
+// decoded as is.
+type NameCodec interface {
+	Encode(name string) string
+	Decode(name string) string
+}
+
+// WithNames sets the NameCodec that the names of files are encoded with. The names of
+// the files that are uploaded, copied, moved, or renamed are encoded, and so are the
+// names in the paths of the files that are requested. The names of the files in every
+// response, such as a directory listing, are decoded. The names of directories are
+// not encoded.
+//
+// A file that is requested by path, and not found, is requested again with the path
+// as is, so the files that were uploaded before the names were encoded are still
+// found. The server only sees the encoded names, a search matches the names as they
+// are stored, see FileService.Search.
+func WithNames(codec NameCodec) Option {
+	return func(c *Client) {
+		c.names = codec
+	}
+}
+
+// encodeName returns the name of a file as it is stored on the server, see
+// NameCodec.
+func (c *Client) encodeName(name string) string {
+	if c.names == nil {
+		return name
+	}
+
+	return c.names.Encode(name)
+}
+
+// decodeName returns the name of a file from the name it is stored on the server
+// with, see NameCodec.
+func (c *Client) decodeName(name string) string {
+	if c.names == nil {
+		return name
+	}
+
+	return c.names.Decode(name)
+}
+
+// encodePath returns the path of a file with its name encoded, see encodeName.
+func (c *Client) encodePath(p string) string {
+	dir, name := path.Split(p)
+	return dir + c.encodeName(name)
+}
+
+// decodePath returns the path of a file with its name decoded, see decodeName.
+func (c *Client) decodePath(p string) string {
+	dir, name := path.Split(p)
+	return dir + c.decodeName(name)
+}
+
+// encodesPath checks if the path query of the request is sent with an encoded name.
+func (c *Client) encodesPath(r request) bool {
+	if !r.file || c.names == nil {
+		return false
+	}
+
+	p, ok := r.query["path"]
+	return ok && c.encodePath(p) != p
+}
+
+// plainFallback checks if the request should be sent again with the path as is,
+// the file was not found by its encoded name.
+func (c *Client) plainFallback(r request, res *http.Response) bool {
+	return res.StatusCode == http.StatusNotFound && c.encodesPath(r)
+}
+
+// decodeNames decodes the names of the files in dst, a response body of the API.
+func (c *Client) decodeNames(dst any) {
+	if c.names == nil {
+		return
+	}
+
+	switch v := dst.(type) {
+	case *File:
+		c.decodeFile(v)
+	case *DirListing:
+		for i := range v.Files {
+			c.decodeFile(&v.Files[i])
+		}
+	case *SearchResponse:
+		for i := range v.Files {
+			c.decodeFile(&v.Files[i])
+		}
+	case *VersionsResponse:
+		c.decodeFile(&v.File)
+	case *UploadResponse:
+		for i := range v.Uploads {
+			v.Uploads[i].Name = c.decodeName(v.Uploads[i].Name)
+			v.Uploads[i].Path = c.decodePath(v.Uploads[i].Path)
+		}
+		for i := range v.Errors {
+			v.Errors[i].FileName = c.decodeName(v.Errors[i].FileName)
+		}
+	case *UploadFileResponse:
+		v.Name = c.decodeName(v.Name)
+		v.Path = c.decodePath(v.Path)
+	case *UploadSession:
+		v.FileName = c.decodeName(v.FileName)
+	case *Lock:
+		v.FilePath = c.decodePath(v.FilePath)
+	}
+}
+
+// decodeFile decodes the name and path of the file, and the path of its lock.
+func (c *Client) decodeFile(f *File) {
+	f.Name = c.decodeName(f.Name)
+	f.Path = c.decodePath(f.Path)
+	if f.Lock != nil {
+		f.Lock.FilePath = c.decodePath(f.Lock.FilePath)
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// deviceCodeGrantType is the OAuth 2.0 grant type of the device authorization flow
+// (RFC 8628).
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// The errors of the device authorization flow that end polling.
+var (
+	// ErrDeviceCodeExpired is the error when the user did not authorize the device
+	// before the device code expired.
+	ErrDeviceCodeExpired = errors.New("device code expired")
+	// ErrAccessDenied is the error when the user denied the authorization request.
+	ErrAccessDenied = errors.New("access denied")
+)
+
+// AuthService calls the OAuth 2.0 endpoints of the Clox API. AuthService should be
+// accessed by calling Client.Auth. The Client does not need a token to use the
+// AuthService.
+type AuthService struct {
+	client *Client
+}
+
+// DeviceCode is the response body of the POST request when starting the device
+// authorization flow.
+type DeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	// The lifetime of the device code in seconds.
+	ExpiresIn int `json:"expires_in"`
+	// The minimum number of seconds to wait between polling requests.
+	Interval int `json:"interval"`
+}
+
+// deviceTokenResponse is the response body of the POST request when the user has
+// authorized the device.
+type deviceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// StartDeviceAuth calls the API to start the device authorization flow for the
+// client ID. The user must visit the verification URI and enter the user code of
+// the DeviceCode, while PollDeviceToken waits for the authorization.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *AuthService) StartDeviceAuth(ctx context.Context, clientID string) (*DeviceCode, error) {
+	form := url.Values{"client_id": {clientID}}
+
+	respData := &DeviceCode{}
+	if err := s.client.do(ctx, respData, formRequest("oauth/device/code", form)); err != nil {
+		return nil, err
+	}
+
+	if respData.Interval <= 0 {
+		respData.Interval = 5
+	}
+
+	return respData, nil
+}
+
+// PollDeviceToken polls the API until the user authorizes the device, and returns
+// the API token. Polling waits the interval of the DeviceCode between requests,
+// and slows down when the server asks it to.
+//
+// If the device code expires it returns ErrDeviceCodeExpired, and if the user
+// denies the request it returns ErrAccessDenied. Any other error response is
+// returned as an *APIError.
+func (s *AuthService) PollDeviceToken(ctx context.Context, clientID string, code *DeviceCode) (string, error) {
+	form := url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {code.DeviceCode},
+		"client_id":   {clientID},
+	}
+
+	interval := time.Duration(code.Interval) * time.Second
+	expires := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+
+		if code.ExpiresIn > 0 && time.Now().After(expires) {
+			return "", ErrDeviceCodeExpired
+		}
+
+		respData := &deviceTokenResponse{}
+		err := s.client.do(ctx, respData, formRequest("oauth/token", form))
+		if err == nil {
+			return respData.AccessToken, nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			return "", err
+		}
+
+		switch apiErr.Err {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "expired_token":
+			return "", ErrDeviceCodeExpired
+		case "access_denied":
+			return "", ErrAccessDenied
+		default:
+			return "", fmt.Errorf("polling device token: %w", err)
+		}
+	}
+}
+
+// formRequest returns a POST request with the form as a URL encoded body.
+func formRequest(path string, form url.Values) request {
+	return request{
+		method: "POST",
+		path:   path,
+		body:   []byte(form.Encode()),
+		header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// PreviewSize is the size of a preview rendition of a file.
+type PreviewSize string
+
+// The preview sizes the server renders.
+const (
+	PreviewSmall  PreviewSize = "small"
+	PreviewMedium PreviewSize = "medium"
+	PreviewLarge  PreviewSize = "large"
+)
+
+// ParsePreviewSize parses the name of a PreviewSize.
+func ParsePreviewSize(s string) (PreviewSize, error) {
+	switch size := PreviewSize(s); size {
+	case PreviewSmall, PreviewMedium, PreviewLarge:
+		return size, nil
+	default:
+		return "", fmt.Errorf("unknown preview size '%s', must be small, medium, or large", s)
+	}
+}
+
+// Preview calls the API to download the preview rendition of the file at the
+// Location and writes it to w, such as a thumbnail of an image or the first page of
+// a document. It returns the media type of the preview.
+//
+// The server can only render files that are stored unencrypted. If the file has no
+// preview, the API responds with a 404 or 415 status code.
+//
+// If the API responds with an error (non-200 status code), nothing is written and
+// it returns an *APIError.
+func (s *FileService) Preview(ctx context.Context, file Location, size PreviewSize, w io.Writer) (string, error) {
+	path, query := file.endpoint("api/preview")
+	if query == nil {
+		query = map[string]string{}
+	}
+	query["size"] = string(size)
+
+	_, header, err := s.client.stream(ctx, w, request{
+		method: "GET",
+		path:   path,
+		query:  query,
+		file:   true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return header.Get("Content-Type"), nil
+}
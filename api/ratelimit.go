@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryAfter is the longest a Client waits to send a request that was rate
+// limited. If the server asks to wait longer, the request is not retried.
+const maxRetryAfter = 5 * time.Minute
+
+// RateLimitFunc is called when the server rate limits a request, before the Client
+// waits to send it again. The wait is how long the Client waits, and the attempt is
+// the number of the retry.
+type RateLimitFunc func(wait time.Duration, attempt int)
+
+// WithRateLimit sets how many times a request is retried when the server rate
+// limits it (429). The Client waits for the delay of the Retry-After header of the
+// response, or the Backoff of the Client if it is not set. If notify is not nil, it
+// is called before each wait.
+//
+// These retries are counted separately from WithRetries, a rate limited request
+// was not processed by the server and is safe to send again.
+func WithRateLimit(retries int, notify RateLimitFunc) Option {
+	return func(c *Client) {
+		c.rateLimitRetries = retries
+		c.rateLimitNotify = notify
+	}
+}
+
+// retryAfter returns the delay of the Retry-After header, which is either a number
+// of seconds or an HTTP date. If the header is not set or invalid, it returns false.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,23 @@
+package api
+
+import "net/http"
+
+// WithReplicas sets the base URLs of the replicas of the Clox API. If a read request
+// fails to reach the base URL of the Client, it is sent to each replica in order
+// until one responds. Requests that write are only sent to the base URL, a replica
+// may not accept them.
+func WithReplicas(baseURLs ...string) Option {
+	return func(c *Client) {
+		c.replicas = append(c.replicas, baseURLs...)
+	}
+}
+
+// endpoints returns the base URLs that a request with the method can be sent to, in
+// the order they are tried.
+func (c *Client) endpoints(method string) []string {
+	if method != http.MethodGet && method != http.MethodHead {
+		return []string{c.baseURL}
+	}
+
+	return append([]string{c.baseURL}, c.replicas...)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Backoff is the delay before each retry of a request. The first retry waits Base,
+// and the delay doubles with every retry up to Max. The delay is moved by a random
+// fraction of up to Jitter, so clients that failed at the same time do not all
+// retry at the same time.
+type Backoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+// DefaultBackoff is the Backoff of a Client that is not configured with
+// WithBackoff.
+var DefaultBackoff = Backoff{Base: 500 * time.Millisecond, Max: 10 * time.Second, Jitter: 0.2}
+
+// WithBackoff sets the Backoff between the retries of a request.
+func WithBackoff(b Backoff) Option {
+	return func(c *Client) {
+		c.backoff = b
+	}
+}
+
+// Delay returns the time to wait before the retry, the first retry is 1.
+func (b Backoff) Delay(retry int) time.Duration {
+	d := b.Base
+	for i := 1; i < retry && d < b.Max; i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+
+	if b.Jitter > 0 {
+		d += time.Duration(float64(d) * b.Jitter * (2*rand.Float64() - 1))
+	}
+
+	return d
+}
+
+// wait waits the delay of the retry. If the ctx is canceled first, it returns the
+// error of the ctx.
+func (b Backoff) wait(ctx context.Context, retry int) error {
+	t := time.NewTimer(b.Delay(retry))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// idempotent checks if a request with the method has the same effect when it is
+// sent more than once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// transientStatus checks if a response with the status code is a failure of a
+// gateway or an overloaded server, that may succeed when sent again.
+func transientStatus(code int) bool {
+	return code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable ||
+		code == http.StatusGatewayTimeout
+}
+
+// transientError reads and closes the body of the transient response, and returns
+// it as an *APIError.
+func transientError(res *http.Response) error {
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	return parseErrorResponse(body, res.StatusCode)
+}
+
+// dialFailed checks if err is a failure to connect to the server. The request was
+// never sent, so it is safe to send again even if it is not idempotent.
+func dialFailed(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// certFailed checks if err is a failure to verify the certificate of the server.
+// Sending the request again fails the same way.
+func certFailed(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	return errors.As(err, &certErr)
+}
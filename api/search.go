@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"strconv"
+)
+
+// SearchParams are the parameters of a search for files by name.
+type SearchParams struct {
+	// The pattern the names of the files must match. The server matches names that
+	// contain the pattern, case-insensitive, a '*' matches any characters.
+	Pattern string
+	// The directory to search within, with every directory below it. An empty path
+	// searches every directory of the user.
+	Dir Location
+	// The maximum number of files returned. If it is 0, the server limit is used.
+	Limit int
+}
+
+// SearchResponse is the response body of the GET request when searching for files.
+type SearchResponse struct {
+	Files []File `json:"files"`
+	// Truncated reports whether more files matched than the limit of the search.
+	Truncated bool `json:"truncated"`
+}
+
+// Search calls the API to search for the files with a name that matches the
+// pattern of the SearchParams. The server matches the names as they are stored, the
+// files with names that are encoded by the NameCodec of the Client are not found by
+// their names, see WithNames.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *FileService) Search(ctx context.Context, p SearchParams) (*SearchResponse, error) {
+	path, query := p.Dir.endpoint("api/search")
+	if query == nil {
+		query = map[string]string{}
+	}
+	query["q"] = p.Pattern
+	if p.Limit > 0 {
+		query["limit"] = strconv.Itoa(p.Limit)
+	}
+
+	respData := &SearchResponse{}
+	if err := s.client.do(ctx, respData, request{
+		method: "GET",
+		path:   path,
+		query:  query,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
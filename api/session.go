@@ -0,0 +1,380 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultPartSize is the size of the plain text of every part of a resumable upload,
+// except the last one.
+const DefaultPartSize = 8 << 20
+
+// partRetries is the number of times a part of a resumable upload is sent again
+// after it failed.
+const partRetries = 3
+
+// PartEncrypter is an Encrypter that can encrypt part of a file on its own. It is
+// used by resumable uploads, the parts of a file are encrypted as they are sent and
+// a part that is sent again is encrypted again.
+//
+// EncryptAt encrypts data that starts at offset off of the plain text of the file.
+// The encrypted parts joined together must be the same as the whole file encrypted.
+// EncryptedSize returns the size of n bytes of plain text after it is encrypted, the
+// encrypted part at off starts at EncryptedSize(off) unless off is 0.
+type PartEncrypter interface {
+	Encrypter
+	EncryptAt(data []byte, key []byte, off int64) ([]byte, error)
+	EncryptedSize(n int64) int64
+}
+
+// SessionParams is the request body of the POST request when creating an upload
+// session.
+type SessionParams struct {
+	// The directory the file is written to.
+	Dir Location `json:"directory"`
+	// The name of the file on the server.
+	FileName string `json:"file_name"`
+	// The size of the encrypted contents of the file.
+	Size int64 `json:"file_size"`
+	// The MIME type of the plain text contents of the file.
+	ContentType string `json:"content_type,omitempty"`
+	// The checksum of the plain text contents of the file, see Checksummer.
+	Checksum string `json:"checksum,omitempty"`
+	// Overwrite replaces the file on the server with the same name when the session
+	// is completed.
+	Overwrite bool `json:"overwrite,omitempty"`
+	// IfMatch is the ETag of the file on the server that the upload replaces. It is
+	// sent in the If-Match header.
+	IfMatch string `json:"-"`
+}
+
+// UploadSession is a resumable upload of a single file. The encrypted contents of
+// the file are sent in parts, the file is written to the server when the session is
+// completed. A session that is not completed expires on the server.
+type UploadSession struct {
+	ID       string `json:"id"`
+	FileName string `json:"file_name"`
+	Size     int64  `json:"file_size"`
+	// Parts are the numbers of the parts the server received, starting at 0.
+	Parts     []int     `json:"parts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UploadedPart is the response body of the PUT request when uploading a part of an
+// upload session.
+type UploadedPart struct {
+	Part int   `json:"part"`
+	Size int64 `json:"size"`
+}
+
+// CreateSession calls the API to start an upload session. It returns the new
+// session, without any parts.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *UploadService) CreateSession(ctx context.Context, p SessionParams) (*UploadSession, error) {
+	p.FileName = s.client.encodeName(p.FileName)
+	jsonData, err := json.Marshal(&p)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling data: %w", err)
+	}
+
+	header := map[string]string{}
+	if p.IfMatch != "" {
+		header[headerIfMatch] = p.IfMatch
+	} else if !p.Overwrite {
+		header[headerIfNoneMatch] = AnyETag
+	}
+
+	respData := &UploadSession{}
+	if err := s.client.do(ctx, respData, request{
+		method: "POST",
+		path:   "api/upload/sessions",
+		body:   jsonData,
+		header: header,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// Session calls the API to get the upload session with the id, with the parts the
+// server received.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. A session that expired responds with a 404 status code.
+func (s *UploadService) Session(ctx context.Context, id string) (*UploadSession, error) {
+	respData := &UploadSession{}
+	if err := s.client.do(ctx, respData, request{
+		method: "GET",
+		path:   "api/upload/sessions/" + id,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// UploadPart calls the API to send part n of the upload session with the id. The
+// data is written as is, it must be encrypted. The offset of the part in the
+// encrypted contents is sent in the Upload-Offset header. A part that is sent again
+// replaces the part the server received.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *UploadService) UploadPart(ctx context.Context, id string, n int, offset int64, data []byte) (*UploadedPart, error) {
+	respData := &UploadedPart{}
+	if err := s.client.do(ctx, respData, request{
+		method: "PUT",
+		path:   fmt.Sprintf("api/upload/sessions/%s/parts/%d", id, n),
+		body:   data,
+		header: map[string]string{
+			"Content-Type":  "application/octet-stream",
+			"Upload-Offset": strconv.FormatInt(offset, 10),
+		},
+		transfer: true,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// CompleteSession calls the API to complete the upload session with the id. The
+// parts are joined and written to the server as the file. It returns the file.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. A session that is missing parts responds with a 409 status code.
+func (s *UploadService) CompleteSession(ctx context.Context, id string) (*UploadFileResponse, error) {
+	respData := &UploadFileResponse{}
+	if err := s.client.do(ctx, respData, request{
+		method: "POST",
+		path:   "api/upload/sessions/" + id + "/complete",
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// AbortSession calls the API to delete the upload session with the id, and the
+// parts the server received. It returns the session that was deleted.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *UploadService) AbortSession(ctx context.Context, id string) (*UploadSession, error) {
+	respData := &UploadSession{}
+	if err := s.client.do(ctx, respData, request{
+		method: "DELETE",
+		path:   "api/upload/sessions/" + id,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// ResumableParams is the parameters needed when uploading a file in parts.
+type ResumableParams struct {
+	// The file to upload. A file that is already encrypted cannot be uploaded in
+	// parts.
+	Upload FileUpload
+	// The encryption key for encrypting the file.
+	Key []byte
+	// The encryption algorithm used to encrypt each part.
+	Alg PartEncrypter
+	// PartSize is the size of the plain text of each part. It must be a multiple of
+	// the chunk size of Alg. If it is 0, DefaultPartSize is used.
+	PartSize int64
+	// Overwrite replaces the file on the server with the same name.
+	Overwrite bool
+	// SessionID is the ID of an earlier session of the same upload. If it is set,
+	// only the parts the server has not received are sent. If the session expired,
+	// or it is for a file of another size, a new session is started.
+	SessionID string
+	// Started is called with a new session before any part is sent, so it can be
+	// saved to resume the upload. This is optional.
+	Started func(*UploadSession)
+	// The function called with the progress of the file. This is optional.
+	Events EventFunc
+}
+
+// Resumable uploads a large file in parts with an upload session. Each part is read
+// from the file, encrypted, and sent in its own request, only one part is held in
+// memory at a time. A part that fails to send, because the server is unreachable or
+// responds with a 5xx status code, is sent again up to 3 times.
+//
+// If the upload fails, the session is kept on the server. Calling Resumable again
+// with the ID of the session in ResumableParams.SessionID sends only the parts the
+// server has not received. The file must not change in between, the parts already
+// sent are not checked.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *UploadService) Resumable(ctx context.Context, dir Location, p ResumableParams) (*UploadFileResponse, error) {
+	u := p.Upload
+	if u.Encrypted {
+		return nil, fmt.Errorf("uploading '%s': an encrypted file cannot be uploaded in parts", u.Path)
+	}
+
+	partSize := p.PartSize
+	if partSize == 0 {
+		partSize = DefaultPartSize
+	}
+
+	f, err := os.Open(u.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading '%s': %w", u.Path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading '%s': %w", u.Path, err)
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("uploading '%s': an empty file cannot be uploaded in parts", u.Path)
+	}
+	size := p.Alg.EncryptedSize(info.Size())
+
+	sess, err := s.session(ctx, p, size)
+	if errors.Is(err, errNoSession) {
+		contentType := u.ContentType
+		if contentType == "" {
+			head := make([]byte, sniffLen)
+			n, _ := f.ReadAt(head, 0)
+			contentType = DetectContentType(u.Filename, head[:n])
+		}
+
+		sum, sumErr := checksum(u, io.NewSectionReader(f, 0, info.Size()), p.Alg, p.Key)
+		if sumErr != nil {
+			return nil, fmt.Errorf("reading '%s': %w", u.Path, sumErr)
+		}
+
+		sess, err = s.CreateSession(ctx, SessionParams{
+			Dir:         dir,
+			FileName:    u.Filename,
+			Size:        size,
+			ContentType: contentType,
+			Checksum:    sum,
+			Overwrite:   p.Overwrite,
+			IfMatch:     u.IfMatch,
+		})
+		if err == nil && p.Started != nil {
+			p.Started(sess)
+		}
+	}
+	if err != nil {
+		p.Events.emit(Event{Kind: EventFailed, Path: u.Path, Name: u.Filename, Total: size, Err: err})
+		return nil, err
+	}
+
+	received := map[int]bool{}
+	for _, n := range sess.Parts {
+		received[n] = true
+	}
+
+	p.Events.emit(Event{Kind: EventStarted, Path: u.Path, Name: u.Filename, Total: size})
+	buf := make([]byte, partSize)
+	for n := 0; int64(n)*partSize < info.Size(); n++ {
+		off := int64(n) * partSize
+		end := min(off+partSize, info.Size())
+		if !received[n] {
+			if err := s.sendPart(ctx, f, buf[:end-off], sess.ID, n, off, p); err != nil {
+				p.Events.emit(Event{Kind: EventFailed, Path: u.Path, Name: u.Filename, Total: size, Err: err})
+				return nil, err
+			}
+		}
+		p.Events.emit(Event{Kind: EventProgress, Path: u.Path, Name: u.Filename, Bytes: p.Alg.EncryptedSize(end), Total: size})
+	}
+
+	res, err := s.CompleteSession(ctx, sess.ID)
+	if err != nil {
+		p.Events.emit(Event{Kind: EventFailed, Path: u.Path, Name: u.Filename, Total: size, Err: err})
+		return nil, err
+	}
+	p.Events.emit(Event{Kind: EventCompleted, Path: u.Path, Name: u.Filename, Bytes: size, Total: size})
+
+	return res, nil
+}
+
+// errNoSession is the error when there is no earlier upload session to resume.
+var errNoSession = errors.New("no upload session")
+
+// session returns the earlier upload session of the ResumableParams, if the server
+// still has it and it is for a file of the same size. Otherwise it returns
+// errNoSession.
+func (s *UploadService) session(ctx context.Context, p ResumableParams, size int64) (*UploadSession, error) {
+	if p.SessionID == "" {
+		return nil, errNoSession
+	}
+
+	sess, err := s.Session(ctx, p.SessionID)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, errNoSession
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if sess.Size != size || sess.FileName != p.Upload.Filename {
+		return nil, errNoSession
+	}
+
+	return sess, nil
+}
+
+// sendPart reads part n of the file at off into buf, encrypts it, and sends it. If
+// sending the part fails, it is sent again up to partRetries times.
+func (s *UploadService) sendPart(ctx context.Context, f *os.File, buf []byte, id string, n int, off int64, p ResumableParams) error {
+	read, err := f.ReadAt(buf, off)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("reading '%s' [part: %d]: %w", p.Upload.Path, n, err)
+	}
+	if read < len(buf) {
+		return fmt.Errorf("reading '%s' [part: %d]: %w", p.Upload.Path, n, errFileChanged)
+	}
+
+	data, err := p.Alg.EncryptAt(buf, p.Key, off)
+	if err != nil {
+		return fmt.Errorf("encrypting '%s' [part: %d]: %w", p.Upload.Path, n, err)
+	}
+
+	offset := int64(0)
+	if off > 0 {
+		offset = p.Alg.EncryptedSize(off)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			p.Events.emit(Event{Kind: EventRetry, Path: p.Upload.Path, Name: p.Upload.Filename, Attempt: attempt, Err: err})
+			if waitErr := s.client.backoff.wait(ctx, attempt); waitErr != nil {
+				return errors.Join(err, waitErr)
+			}
+		}
+
+		_, err = s.UploadPart(ctx, id, n, offset, data)
+		if err == nil || attempt == partRetries || !retryablePart(err) {
+			return err
+		}
+	}
+}
+
+// retryablePart checks if a part that failed with err should be sent again.
+func retryablePart(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+
+	return errors.Is(err, ErrUnreachable)
+}
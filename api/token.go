@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// TokenService calls the token endpoints of the Clox API. TokenService should be
+// accessed by calling Client.Tokens.
+type TokenService struct {
+	client *Client
+}
+
+// TokenInfo is the response body of the GET request when verifying a token.
+type TokenInfo struct {
+	ID        string     `json:"id"`
+	OwnerID   string     `json:"owner_id"`
+	Username  string     `json:"username"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Verify calls the API to verify the token of the Client. Verify does not modify
+// anything on the server. The ExpiresAt field of the TokenInfo is nil if the token
+// never expires.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError. An invalid token responds with a 401 status code.
+func (s *TokenService) Verify(ctx context.Context) (*TokenInfo, error) {
+	respData := &TokenInfo{}
+	if err := s.client.do(ctx, respData, request{
+		method: "GET",
+		path:   "api/token",
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"sort"
+)
+
+// TreeNode is a directory in a Tree. The sub directories and files of a TreeNode are
+// sorted by name.
+type TreeNode struct {
+	Dir   Dir
+	Dirs  []*TreeNode
+	Files []File
+	// Listed reports whether the directory was listed. The sub directories and files
+	// of a directory that is deeper than the depth of the Tree are not listed.
+	Listed bool
+}
+
+// Size returns the total size of the files in this TreeNode, and in every TreeNode
+// below it that was listed.
+func (n *TreeNode) Size() int64 {
+	var size int64
+	for _, f := range n.Files {
+		size += f.Size
+	}
+	for _, d := range n.Dirs {
+		size += d.Size()
+	}
+
+	return size
+}
+
+// Complete reports whether this TreeNode, and every TreeNode below it, was listed.
+func (n *TreeNode) Complete() bool {
+	if !n.Listed {
+		return false
+	}
+	for _, d := range n.Dirs {
+		if !d.Complete() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Count returns the number of directories and files below this TreeNode that were
+// listed.
+func (n *TreeNode) Count() (dirs int, files int) {
+	dirs, files = len(n.Dirs), len(n.Files)
+	for _, d := range n.Dirs {
+		subDirs, subFiles := d.Count()
+		dirs += subDirs
+		files += subFiles
+	}
+
+	return dirs, files
+}
+
+// Tree lists the directory at the root Location and the directories below it, and
+// returns them as a tree of TreeNode. Only the directories up to depth levels below
+// root are listed, a depth of 1 lists root only. A depth of 0 or less lists every
+// directory.
+//
+// If listing a directory fails, it returns nil and the error.
+func (s *DirService) Tree(ctx context.Context, root Location, depth int) (*TreeNode, error) {
+	return Tree(ctx, s, root, depth)
+}
+
+// Tree is DirService.Tree with the directories listed by the Lister l.
+func Tree(ctx context.Context, l Lister, root Location, depth int) (*TreeNode, error) {
+	var tree *TreeNode
+	nodes := map[string]*TreeNode{}
+	err := Walk(ctx, l, root, func(listing *DirListing, d int) error {
+		node, ok := nodes[listing.Dir.ID]
+		if !ok {
+			node = &TreeNode{Dir: listing.Dir}
+			tree = node
+		}
+
+		node.Listed = true
+		node.Files = append(node.Files, listing.Files...)
+		sort.Slice(node.Files, func(i, j int) bool { return node.Files[i].Name < node.Files[j].Name })
+		for _, sub := range listing.Dirs {
+			child := &TreeNode{Dir: sub}
+			nodes[sub.ID] = child
+			node.Dirs = append(node.Dirs, child)
+		}
+		sort.Slice(node.Dirs, func(i, j int) bool { return node.Dirs[i].Dir.DirName < node.Dirs[j].Dir.DirName })
+
+		if depth > 0 && d+1 >= depth {
+			return SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
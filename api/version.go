@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Version is a prior revision of a file on the server. Each time a file is
+// overwritten or appended to, the contents it had are kept as a new Version. The
+// contents of a Version are encrypted the same as the file.
+type Version struct {
+	// Revision is the number of the Version, the first revision of a file is 1.
+	Revision    int       `json:"revision"`
+	Size        int64     `json:"file_size"`
+	ContentType string    `json:"content_type,omitempty"`
+	ETag        string    `json:"etag,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// VersionsResponse is the response body of the GET request when listing the
+// versions of a file.
+type VersionsResponse struct {
+	File File `json:"file"`
+	// Versions are the prior revisions of the file, newest first.
+	Versions []Version `json:"versions"`
+}
+
+// Versions calls the API to list the prior revisions of the file at the Location.
+// The current contents of the file are not a Version.
+//
+// If the API responds with an error (non-200 status code), it will return nil and
+// an *APIError.
+func (s *FileService) Versions(ctx context.Context, file Location) (*VersionsResponse, error) {
+	path, query := file.endpoint("api/versions")
+	respData := &VersionsResponse{}
+	if err := s.client.do(ctx, respData, request{
+		method: "GET",
+		path:   path,
+		query:  query,
+		file:   true,
+	}); err != nil {
+		return nil, err
+	}
+
+	return respData, nil
+}
+
+// DownloadVersion calls the API to download the revision of the file at the
+// Location and writes it to w. The contents are written as they are stored on the
+// server, encrypted. It returns the number of bytes written.
+//
+// If the API responds with an error (non-200 status code), nothing is written and
+// it returns an *APIError. A revision that does not exist responds with a 404
+// status code.
+func (s *FileService) DownloadVersion(ctx context.Context, file Location, rev int, w io.Writer) (int64, error) {
+	path, query := file.endpoint("api/download")
+	if query == nil {
+		query = map[string]string{}
+	}
+	query["rev"] = strconv.Itoa(rev)
+
+	n, _, err := s.client.stream(ctx, w, request{
+		method: "GET",
+		path:   path,
+		query:  query,
+		file:   true,
+	})
+	return n, err
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/age"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ageIdentityInfo is the HKDF info of the age identity of a profile.
+const ageIdentityInfo = "clox-cli age identity v1"
+
+// ageExt is the extension of a file in the age format.
+const ageExt = ".age"
+
+// ageIdentity returns the age identity of a profile, derived from its encryption
+// key. The identity decrypts the files downloaded in the age format, it cannot be
+// used to derive the encryption key, so an exported identity does not decrypt the
+// files on the server.
+func ageIdentity(key []byte) (*age.Identity, error) {
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(ageIdentityInfo)), secret); err != nil {
+		return nil, err
+	}
+
+	return age.NewIdentity(secret)
+}
+
+// ageRecipients returns the recipients that a file downloaded in the age format is
+// encrypted for, the age identity of the profile and the recipients in extra.
+func ageRecipients(key []byte, extra []string) ([]*age.Recipient, error) {
+	id, err := ageIdentity(key)
+	if err != nil {
+		return nil, fmt.Errorf("deriving age identity: %w", err)
+	}
+
+	recipients := []*age.Recipient{id.Recipient()}
+	for _, s := range extra {
+		r, err := age.ParseRecipient(s)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+
+	return recipients, nil
+}
+
+// writeAgeIdentity writes the identity to the file at path in the format of
+// age-keygen, so it can be passed to 'age -d -i'. Only the user can read or write
+// the file.
+func writeAgeIdentity(path string, id *age.Identity) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# created: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# public key: %s\n", id.Recipient())
+	fmt.Fprintf(&b, "%s\n", id)
+
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// writeAgeFile encrypts the data for the recipients in the age format and writes it
+// to the file at path.
+func writeAgeFile(path string, data []byte, recipients []*age.Recipient) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	w, err := age.Encrypt(f, recipients...)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// readAgeIdentities reads the age identity files at paths, the identities that
+// files uploaded in the age format are decrypted with, in addition to the age
+// identity of the profile.
+func readAgeIdentities(key []byte, paths []string) ([]*age.Identity, error) {
+	id, err := ageIdentity(key)
+	if err != nil {
+		return nil, fmt.Errorf("deriving age identity: %w", err)
+	}
+
+	ids := []*age.Identity{id}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := age.ParseIdentities(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading identity file %s: %w", p, err)
+		}
+		ids = append(ids, parsed...)
+	}
+
+	return ids, nil
+}
+
+// importAge decrypts the age files of the uploads with the identities, and encrypts
+// them with the key for the upload, the same as a queued upload. The plain text is
+// only held in memory, the encrypted files are written to dir. A file uploaded with
+// the name of the age file, such as a file matched by a pattern, loses its '.age'
+// extension.
+//
+// It returns the uploads of the encrypted files, with the age file each was read
+// from.
+func (c *UploadCommand) importAge(uploads []api.FileUpload, ids []*age.Identity, key []byte, dir string) ([]api.FileUpload, map[string]string, error) {
+	imported := make([]api.FileUpload, 0, len(uploads))
+	sources := map[string]string{}
+	for i, u := range uploads {
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, err := age.Decrypt(f, ids...)
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("decrypting %s: %w", u.Path, err)
+		}
+		data, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("decrypting %s: %w", u.Path, err)
+		}
+
+		encrypted, err := c.aes.SealEnvelope(data, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encrypting %s: %w", u.Path, err)
+		}
+		sum, err := c.aes.Checksum(bytes.NewReader(data), key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("computing checksum of %s: %w", u.Path, err)
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%d.enc", i))
+		if err := os.WriteFile(path, encrypted, 0600); err != nil {
+			return nil, nil, err
+		}
+
+		name := u.Filename
+		if name == filepath.Base(u.Path) {
+			name = strings.TrimSuffix(name, ageExt)
+		}
+		imported = append(imported, api.FileUpload{
+			Path:        path,
+			Filename:    name,
+			Encrypted:   true,
+			ContentType: api.DetectContentType(name, data),
+			Checksum:    sum,
+		})
+		sources[path] = u.Path
+	}
+
+	return imported, sources, nil
+}
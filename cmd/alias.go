@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+// splitCommandLine splits the command line of an alias into its arguments. The
+// arguments are separated by spaces, and can be quoted with single or double
+// quotes. A backslash escapes the next character outside of single quotes. A
+// leading '~/' of an argument is replaced by the users home directory.
+func splitCommandLine(s string) ([]string, error) {
+	args := []string{}
+
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	for i, a := range args {
+		args[i] = expandHome(a)
+	}
+
+	return args, nil
+}
+
+// expandHome replaces a leading '~/' of the path with the users home directory. If
+// the home directory cannot be found, the path is returned as is.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// expandAlias expands the alias that is the first argument of args. The arguments
+// after the alias are appended to the command line of the alias. If the first
+// argument is a command, or not an alias, it returns false. The aliases of the
+// settings are used if there is no saved alias with the name, see config.Settings.
+//
+// Only the first argument is expanded, an alias must come before any flag. An
+// alias is expanded once, the command line of an alias cannot use another alias.
+func (c *RootCommand) expandAlias(args []string) ([]string, bool, error) {
+	if len(args) == 0 || c.hasCommand(args[0]) {
+		return nil, false, nil
+	}
+
+	aliases, err := c.store.ReadAliases()
+	if err != nil {
+		return nil, false, fmt.Errorf("reading aliases: %w", err)
+	}
+
+	line, ok := aliases[args[0]]
+	if !ok {
+		settings, err := c.store.ReadSettings()
+		if err != nil {
+			return nil, false, fmt.Errorf("reading settings: %w", err)
+		}
+		line, ok = settings.Aliases[args[0]]
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	expanded, err := splitCommandLine(line)
+	if err != nil {
+		return nil, false, fmt.Errorf("alias '%s': %w", args[0], err)
+	}
+
+	return append(expanded, args[1:]...), true, nil
+}
+
+// The 'alias' command.
+//
+// AliasCommand groups the sub commands that manage the command aliases. It does
+// nothing on its own.
+type AliasCommand struct {
+	cmd *cobra.Command
+}
+
+// NewAliasCommand creates and returns a AliasCommand.
+func NewAliasCommand() *AliasCommand {
+	return &AliasCommand{
+		cmd: &cobra.Command{
+			Use:   "alias",
+			Short: "Manage the shortcuts of commands",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this AliasCommand.
+func (c *AliasCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'alias set' command.
+//
+// AliasSetCommand saves a named shortcut of a command line.
+type AliasSetCommand struct {
+	cmd    *cobra.Command
+	store  *config.Store
+	logger *logging.Logger
+}
+
+// NewAliasSetCommand creates and returns a AliasSetCommand.
+func NewAliasSetCommand(store *config.Store, logger *logging.Logger) *AliasSetCommand {
+	setCmd := &AliasSetCommand{store: store, logger: logger}
+
+	setCmd.cmd = &cobra.Command{
+		Use:   "set <name> <command>",
+		Short: "Save a shortcut of a command",
+		Args:  cobra.ExactArgs(2),
+		RunE:  setCmd.Run,
+	}
+
+	return setCmd
+}
+
+// Command returns the cobra.Command of this AliasSetCommand.
+func (c *AliasSetCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this AliasSetCommand.
+//
+// Run saves the command line as the alias with the name, replacing the alias if it
+// exists. The command line is everything after "clox", and must be quoted as a
+// single argument. An alias cannot have the name of a command.
+func (c *AliasSetCommand) Run(cmd *cobra.Command, args []string) error {
+	name, line := args[0], strings.TrimSpace(args[1])
+
+	if err := config.ValidateAliasName(name); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return reported(err)
+	}
+
+	for _, sub := range cmd.Root().Commands() {
+		if sub.Name() == name || sub.HasAlias(name) {
+			fmt.Fprintf(stderr, "Error: '%s' is a command and cannot be an alias\n", name)
+			return reported(nil)
+		}
+	}
+
+	if _, err := splitCommandLine(line); err != nil || line == "" {
+		if err == nil {
+			err = errors.New("empty command")
+		}
+		fmt.Fprintln(stderr, "Invalid command:", err)
+		return errUsage
+	}
+
+	aliases, err := c.store.ReadAliases()
+	if err != nil {
+		c.logger.Error("reading aliases", "error", err)
+		return reported(err)
+	}
+
+	aliases[name] = line
+	if err := c.store.WriteAliases(aliases); err != nil {
+		c.logger.Error("writing aliases", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Alias '%s' set\n", name)
+	c.logger.Printf("-> clox %s\n", line)
+
+	return nil
+}
+
+// The 'alias list' command.
+//
+// AliasListCommand prints the saved aliases.
+type AliasListCommand struct {
+	cmd    *cobra.Command
+	store  *config.Store
+	logger *logging.Logger
+	format formatFlag
+}
+
+// NewAliasListCommand creates and returns a AliasListCommand.
+//
+// The format flag (--format) is set for the AliasListCommand. This flag sets the
+// format the aliases are printed in.
+func NewAliasListCommand(store *config.Store, logger *logging.Logger) *AliasListCommand {
+	listCmd := &AliasListCommand{store: store, logger: logger}
+
+	listCmd.cmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the aliases",
+		Args:  cobra.ExactArgs(0),
+		RunE:  listCmd.Run,
+	}
+
+	listCmd.format.register(listCmd.cmd)
+
+	return listCmd
+}
+
+// Command returns the cobra.Command of this AliasListCommand.
+func (c *AliasListCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this AliasListCommand.
+//
+// Run prints the saved aliases and the aliases of the settings, see
+// config.Settings. An alias of the settings that has the name of a saved alias is
+// not printed, it is never used.
+func (c *AliasListCommand) Run(cmd *cobra.Command, args []string) error {
+	r, err := c.format.renderer()
+	if err != nil {
+		return err
+	}
+
+	aliases, err := c.store.ReadAliases()
+	if err != nil {
+		c.logger.Error("reading aliases", "error", err)
+		return reported(err)
+	}
+	settings, err := c.store.ReadSettings()
+	if err != nil {
+		c.logger.Error("reading settings", "error", err)
+		return reported(err)
+	}
+
+	sources := map[string]string{}
+	for name, line := range settings.Aliases {
+		if _, ok := aliases[name]; !ok {
+			aliases[name] = line
+			sources[name] = aliasSettings
+		}
+	}
+
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make(aliasList, len(names))
+	for i, name := range names {
+		source := sources[name]
+		if source == "" {
+			source = aliasSaved
+		}
+		result[i] = aliasEntry{Name: name, Command: aliases[name], Source: source}
+	}
+	return render(r, result, c.logger)
+}
+
+// The sources of an alias.
+const (
+	// aliasSaved is an alias saved with the 'alias set' command.
+	aliasSaved = "saved"
+	// aliasSettings is an alias of the settings file.
+	aliasSettings = "settings"
+)
+
+// aliasEntry is an alias printed by the 'alias list' command. The Command is the
+// command line after 'clox', and the Source is where the alias is defined.
+type aliasEntry struct {
+	Name    string `json:"name" table:"NAME"`
+	Command string `json:"command" table:"COMMAND"`
+	Source  string `json:"source" table:"SOURCE"`
+}
+
+// aliasList is the result of the 'alias list' command.
+type aliasList []aliasEntry
+
+// Text writes the number of aliases, followed by every alias and its command, one
+// alias per line.
+func (l aliasList) Text(w io.Writer) error {
+	fmt.Fprintf(w, "Aliases: %d\n", len(l))
+	for _, a := range l {
+		suffix := ""
+		if a.Source == aliasSettings {
+			suffix = " (settings)"
+		}
+		if _, err := fmt.Fprintf(w, "%s -> clox %s%s\n", a.Name, a.Command, suffix); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The 'alias rm' command.
+//
+// AliasRemoveCommand removes a saved alias.
+type AliasRemoveCommand struct {
+	cmd    *cobra.Command
+	store  *config.Store
+	logger *logging.Logger
+}
+
+// NewAliasRemoveCommand creates and returns a AliasRemoveCommand.
+func NewAliasRemoveCommand(store *config.Store, logger *logging.Logger) *AliasRemoveCommand {
+	removeCmd := &AliasRemoveCommand{store: store, logger: logger}
+
+	removeCmd.cmd = &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Remove an alias",
+		Args:  cobra.ExactArgs(1),
+		RunE:  removeCmd.Run,
+	}
+
+	return removeCmd
+}
+
+// Command returns the cobra.Command of this AliasRemoveCommand.
+func (c *AliasRemoveCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this AliasRemoveCommand.
+func (c *AliasRemoveCommand) Run(cmd *cobra.Command, args []string) error {
+	aliases, err := c.store.ReadAliases()
+	if err != nil {
+		c.logger.Error("reading aliases", "error", err)
+		return reported(err)
+	}
+
+	name := args[0]
+	if _, ok := aliases[name]; !ok {
+		fmt.Fprintf(stderr, "Alias '%s' not found\n", name)
+		if settings, err := c.store.ReadSettings(); err == nil && settings.Aliases[name] != "" {
+			fmt.Fprintln(stderr, "-> [HINT] The alias is defined in the settings file, remove it there")
+		}
+		return reported(nil)
+	}
+
+	delete(aliases, name)
+	if err := c.store.WriteAliases(aliases); err != nil {
+		c.logger.Error("writing aliases", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Alias '%s' removed\n", name)
+
+	return nil
+}
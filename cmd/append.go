@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/cicconee/clox-cli/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// The 'append' command.
+//
+// AppendCommand pushes the new contents of a growing local file, such as a log
+// file, to a file on the server. Only the bytes written since the last append are
+// encrypted and uploaded.
+type AppendCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+}
+
+// NewAppendCommand creates and returns a AppendCommand.
+func NewAppendCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, reauth *Reauthenticator) *AppendCommand {
+	appendCmd := &AppendCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger, reauth: reauth}
+
+	appendCmd.cmd = &cobra.Command{
+		Use:   "append <file> <remote-path>",
+		Short: "Append the new contents of a file to a file on the server",
+		Args:  cobra.ExactArgs(2),
+		RunE:  appendCmd.Run,
+	}
+
+	return appendCmd
+}
+
+// Command returns the cobra.Command of this AppendCommand.
+func (c *AppendCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *AppendCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *AppendCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *AppendCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this AppendCommand.
+//
+// Run will append the local file to the file at the remote path. If the remote file
+// does not exist, the whole local file is uploaded with chunked encryption so it
+// can be appended to later. Otherwise, only the bytes of the local file after the
+// bytes that were already appended are encrypted and appended to the remote file.
+//
+// A remote file can only be appended to if it was created with 'clox append' on
+// this machine, the number of bytes that were already appended is recorded
+// locally. If the remote file changed since the last append, or the local file is
+// smaller than what was already appended, nothing is appended.
+func (c *AppendCommand) Run(cmd *cobra.Command, args []string) error {
+	localPath, remotePath := args[0], args[1]
+	dir, name := splitRemotePath(remotePath)
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		c.logger.Error("reading file", "path", localPath, "error", err)
+		return reported(err)
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	tracker, err := loadTracker(c.store, c.aes, *c.password)
+	if err != nil {
+		c.logger.Error("loading tracked files", "error", err)
+		return reported(err)
+	}
+
+	listing, err := c.client.Dirs().List(cmd.Context(), dir)
+	if err != nil {
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	var remote *api.File
+	for i := range listing.Files {
+		if listing.Files[i].Name == name {
+			remote = &listing.Files[i]
+			break
+		}
+	}
+
+	var file api.File
+	if remote == nil {
+		file, err = c.create(cmd, c.client, dir, localPath, name, encryptKey)
+	} else {
+		file, err = c.append(cmd, c.client, tracker, *remote, data, encryptKey)
+	}
+	if err != nil {
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	tracker.Track(file.ID, file.Path, tracking.LastWrite(file), file.ETag)
+	tracker.SetAppended(file.ID, int64(len(data)))
+	if err := tracker.Save(c.store.File(trackingFile), c.aes, *c.password); err != nil {
+		c.logger.Warn("saving tracked files", "error", err)
+	}
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		idx.Add(index.FileEntry(file))
+	})
+
+	return nil
+}
+
+// create uploads the local file to the directory with chunked encryption, so it can
+// be appended to.
+func (c *AppendCommand) create(cmd *cobra.Command, client *api.Client, dir api.Location, localPath string, name string, key []byte) (api.File, error) {
+	res, err := client.Uploads().Create(cmd.Context(), dir, api.UploadParams{
+		Uploads: []api.FileUpload{{Path: localPath, Filename: name}},
+		Key:     key,
+		Alg:     &crypto.ChunkedAES{AES: c.aes},
+	})
+	if err != nil {
+		return api.File{}, err
+	}
+	if len(res.Errors) > 0 {
+		return api.File{}, fmt.Errorf("%s: %s", res.Errors[0].FileName, res.Errors[0].Error)
+	}
+
+	u := res.Uploads[0]
+	c.logger.Printf("Created: %s -> %s\n", u.ID, u.Path)
+	return u.File(), nil
+}
+
+// append appends the bytes of data that were not already appended to the remote
+// file.
+func (c *AppendCommand) append(cmd *cobra.Command, client *api.Client, tracker *tracking.Tracker, remote api.File, data []byte, key []byte) (api.File, error) {
+	rec, ok := tracker.Records[remote.ID]
+	if !ok || rec.Appended == 0 {
+		return api.File{}, fmt.Errorf("'%s' was not created with 'clox append' on this machine", remote.Path)
+	}
+	if conflict, ok := tracker.Check(remote); ok {
+		return api.File{}, fmt.Errorf("'%s' cannot be appended to: %s", remote.Path, conflict.Reason())
+	}
+
+	// Chunks can only be appended to a file that is chunked, a file that was
+	// overwritten since the last append is encrypted as a whole.
+	var header bytes.Buffer
+	headerSize := int64(crypto.MaxEnvelopeHeaderSize + crypto.MaxChunkedHeaderSize)
+	if _, err := client.Files().DownloadRange(cmd.Context(), api.ID(remote.ID), api.ByteRange{Start: 0, End: headerSize - 1}, &header); err != nil {
+		return api.File{}, err
+	}
+
+	// The chunks of a file with a data key start after its envelope header, and are
+	// encrypted with the data key.
+	chunked, chunkKey, offset := header.Bytes(), key, remote.Size
+	if crypto.IsEnvelope(chunked) {
+		dk, err := c.aes.OpenDataKey(chunked, key)
+		if err != nil {
+			return api.File{}, fmt.Errorf("reading data key: %w", err)
+		}
+		chunked, chunkKey, offset = chunked[len(dk.Header):], dk.Key, remote.Size-int64(len(dk.Header))
+	}
+	if !crypto.IsChunked(chunked) {
+		return api.File{}, fmt.Errorf("'%s' is not chunked, it was replaced since the last append", remote.Path)
+	}
+	// The chunks are encrypted with the cipher of the file, which may not be the
+	// cipher of the profile.
+	fileAES, err := c.aes.ForChunked(chunked)
+	if err != nil {
+		return api.File{}, fmt.Errorf("reading chunked header: %w", err)
+	}
+
+	appended := rec.Appended
+	if int64(len(data)) < appended {
+		return api.File{}, fmt.Errorf("the local file is smaller than the %d bytes already appended, it was truncated or replaced", appended)
+	}
+	if int64(len(data)) == appended {
+		c.logger.Printf("Up to date: %s\n", remote.Path)
+		return remote, nil
+	}
+
+	encrypted, err := fileAES.EncryptChunks(data[appended:], chunkKey, offset)
+	if err != nil {
+		return api.File{}, fmt.Errorf("encrypting file: %w", err)
+	}
+
+	file, err := client.Uploads().Append(cmd.Context(), api.ID(remote.ID), api.AppendParams{
+		Data:    encrypted,
+		Offset:  remote.Size,
+		IfMatch: remote.ETag,
+	})
+	if err != nil {
+		return api.File{}, err
+	}
+
+	c.logger.Printf("Appended: %d bytes -> %s\n", int64(len(data))-appended, file.Path)
+	return *file, nil
+}
+
+// printError prints the error of a request made by this AppendCommand. If the API
+// token was rejected, the user is offered to enter a new one instead.
+func (c *AppendCommand) printError(cmd *cobra.Command, err error, args []string) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] File: %s\n", args[0])
+		fmt.Fprintf(stderr, "-> [ARGS] Remote Path: %s\n", args[1])
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Append failed:", err)
+	}
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/hooks"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/partial"
+	"github.com/spf13/cobra"
+)
+
+// errDuplicateOutput is the error when two files of a download are written to the
+// same local path.
+var errDuplicateOutput = errors.New("another file of the download has the same name")
+
+// downloadSummary is the result of downloading more than one file.
+type downloadSummary struct {
+	Downloaded []string
+	Canceled   []string
+	Failed     []failure
+	// files are the remote files that were downloaded, and outputs the local paths
+	// they were written to.
+	files   []api.File
+	outputs []string
+}
+
+// runMany downloads the file of each ID in args, decrypts it, and writes it to the
+// directory of the output flag (-o, --output) with the name it has on the server.
+// If the output flag is not set, the files are written to the current directory.
+// The range (--range) and zip (--zip) flags, and output '-', cannot be used.
+//
+// Each file is downloaded like a single file, to a partial download that can be
+// resumed. Up to the parallel flag (--parallel) of files are downloaded at the same
+// time. If the fail fast flag (--fail-fast) is set, the first file that fails
+// cancels the downloads in flight and the files that were not started. If any file
+// fails to download, the program exits with exitPartialFailure after the result is
+// printed.
+//
+// The pre-download hook is run with the IDs before anything is downloaded, if it
+// fails the download is aborted. The post-download hook is run with the output
+// paths and the metadata of the files that were written.
+func (c *DownloadCommand) runMany(cmd *cobra.Command, args []string) error {
+	if c.rng != "" || c.zip || c.age || len(c.ageTo) > 0 || c.output == "-" {
+		fmt.Fprintln(stderr, "Only one ID can be downloaded with range (--range), zip (--zip), age (--age), or output '-'")
+		return errUsage
+	}
+
+	if err := c.transfer.validate(); err != nil {
+		fmt.Fprintln(stderr, "Invalid flag:", err)
+		return errUsage
+	}
+
+	dir := c.output
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.logger.Error("creating output directory", "path", dir, "error", err)
+		return reported(err)
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	if err := c.hooks.Run(hooks.PreDownload, args, nil); err != nil {
+		fmt.Fprintln(stderr, "Download aborted:", err)
+		return reported(err)
+	}
+
+	files := make([]*api.File, len(args))
+	outputs := make([]string, len(args))
+	var mu sync.Mutex
+	claimed := map[string]bool{}
+
+	errs := c.transfer.run(cmd.Context(), len(args), func(ctx context.Context, i int) error {
+		file, err := c.client.Files().Get(ctx, api.ID(args[i]))
+		if err != nil {
+			return err
+		}
+		if !filepath.IsLocal(file.Name) {
+			return errUnsafeName
+		}
+
+		output := filepath.Join(dir, file.Name)
+		mu.Lock()
+		taken := claimed[output]
+		claimed[output] = true
+		mu.Unlock()
+		if taken {
+			return errDuplicateOutput
+		}
+
+		if c.resume {
+			if err := partial.CanResume(output, *file); err != nil {
+				return fmt.Errorf("cannot resume: %w", err)
+			}
+		}
+
+		data, err := c.downloadResumable(ctx, c.client.Files(), file, output, encryptKey)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+		if err := partial.Remove(output); err != nil {
+			c.logger.Warn("removing partial download", "path", output+partial.Ext, "error", err)
+		}
+
+		files[i], outputs[i] = file, output
+		return nil
+	})
+
+	summary := &downloadSummary{Downloaded: []string{}, Canceled: []string{}, Failed: []failure{}}
+	for i, id := range args {
+		err := errs[i]
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) && c.reauth.Handle(cmd.Context(), apiErr, c.user, *c.password) {
+			return reported(apiErr)
+		}
+
+		switch {
+		case errors.Is(err, errCanceled):
+			summary.Canceled = append(summary.Canceled, id)
+		case err != nil:
+			summary.Failed = append(summary.Failed, failure{Path: id, Err: err})
+		default:
+			summary.Downloaded = append(summary.Downloaded, fmt.Sprintf("%s -> %s", files[i].Path, outputs[i]))
+			summary.files = append(summary.files, *files[i])
+			summary.outputs = append(summary.outputs, outputs[i])
+		}
+	}
+	summary.print(c.logger)
+
+	trackFiles(c.store, c.aes, *c.password, c.logger, summary.files...)
+
+	if len(summary.outputs) > 0 {
+		if err := c.hooks.Run(hooks.PostDownload, summary.outputs, summary.files); err != nil {
+			c.logger.Warn("running post-download hook", "error", err)
+		}
+	}
+
+	if len(summary.Failed) > 0 {
+		return exitWith(exitPartialFailure)
+	}
+
+	return nil
+}
+
+// print prints this downloadSummary in a human readable format.
+func (s *downloadSummary) print(logger *logging.Logger) {
+	logger.Printf("Downloaded: %d\n", len(s.Downloaded))
+	for _, d := range s.Downloaded {
+		logger.Println(d)
+	}
+
+	if len(s.Canceled) > 0 {
+		fmt.Fprintf(stdout, "\nCanceled: %d\n", len(s.Canceled))
+		for _, id := range s.Canceled {
+			fmt.Fprintln(stdout, id)
+		}
+		fmt.Fprintln(stdout, "-> [HINT] The download stopped at the first file that failed (--fail-fast)")
+	}
+
+	if len(s.Failed) > 0 || !logger.Quiet() {
+		fmt.Fprintf(stdout, "\nErrors: %d\n", len(s.Failed))
+	}
+	for _, f := range s.Failed {
+		fmt.Fprintf(stdout, "%s -> %s\n", f.Path, f.Err)
+	}
+}
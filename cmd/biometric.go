@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cicconee/clox-cli/internal/biometric"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// The 'biometric' command.
+//
+// BiometricCommand groups the sub commands that manage OS authenticated unlock. It
+// does nothing on its own.
+type BiometricCommand struct {
+	cmd *cobra.Command
+}
+
+// NewBiometricCommand creates and returns a BiometricCommand.
+func NewBiometricCommand() *BiometricCommand {
+	return &BiometricCommand{
+		cmd: &cobra.Command{
+			Use:   "biometric",
+			Short: "Manage Windows Hello unlock",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this BiometricCommand.
+func (c *BiometricCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'biometric enable' command.
+//
+// BiometricEnableCommand stores the password in the platform keystore, so commands
+// can be unlocked with Windows Hello instead of typing the password.
+type BiometricEnableCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	provider biometric.Provider
+	logger   *logging.Logger
+}
+
+// NewBiometricEnableCommand creates and returns a BiometricEnableCommand.
+func NewBiometricEnableCommand(store *config.Store, provider biometric.Provider, logger *logging.Logger) *BiometricEnableCommand {
+	enableCmd := &BiometricEnableCommand{store: store, provider: provider, logger: logger}
+
+	enableCmd.cmd = &cobra.Command{
+		Use:   "enable",
+		Short: "Unlock with Windows Hello",
+		Args:  cobra.ExactArgs(0),
+		RunE:  enableCmd.Run,
+	}
+
+	return enableCmd
+}
+
+// Command returns the cobra.Command of this BiometricEnableCommand.
+func (c *BiometricEnableCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *BiometricEnableCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *BiometricEnableCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this BiometricEnableCommand.
+//
+// Run stores the password of the active profile in the platform keystore. If the
+// platform does not support OS authenticated unlock, a message is printed and
+// nothing is stored.
+func (c *BiometricEnableCommand) Run(cmd *cobra.Command, args []string) error {
+	if !c.provider.Available() {
+		fmt.Fprintln(stderr, "Windows Hello is not available on this machine")
+		fmt.Fprintln(stderr, "-> [HINT] 'clox keyring enable' stores the password in the OS keyring, without OS authentication")
+		return reported(nil)
+	}
+
+	if err := c.provider.Store(c.store.Account(), *c.password); err != nil {
+		c.logger.Error("storing password in keystore", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Biometric unlock enabled for profile '%s'\n", c.store.Profile)
+	c.logger.Println("The password is still accepted if biometric unlock fails")
+
+	return nil
+}
+
+// The 'biometric disable' command.
+//
+// BiometricDisableCommand removes the password from the platform keystore.
+type BiometricDisableCommand struct {
+	cmd      *cobra.Command
+	store    *config.Store
+	provider biometric.Provider
+	logger   *logging.Logger
+}
+
+// NewBiometricDisableCommand creates and returns a BiometricDisableCommand.
+func NewBiometricDisableCommand(store *config.Store, provider biometric.Provider, logger *logging.Logger) *BiometricDisableCommand {
+	disableCmd := &BiometricDisableCommand{store: store, provider: provider, logger: logger}
+
+	disableCmd.cmd = &cobra.Command{
+		Use:   "disable",
+		Short: "Stop unlocking with Windows Hello",
+		Args:  cobra.ExactArgs(0),
+		RunE:  disableCmd.Run,
+	}
+
+	return disableCmd
+}
+
+// Command returns the cobra.Command of this BiometricDisableCommand.
+func (c *BiometricDisableCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this BiometricDisableCommand.
+//
+// Run removes the password of the active profile from the platform keystore.
+// Commands will prompt for the password again.
+func (c *BiometricDisableCommand) Run(cmd *cobra.Command, args []string) error {
+	if err := c.provider.Delete(c.store.Account()); err != nil {
+		c.logger.Error("removing password from keystore", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Biometric unlock disabled for profile '%s'\n", c.store.Profile)
+
+	return nil
+}
@@ -0,0 +1,619 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/color"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/cicconee/clox-cli/internal/terminal"
+	"github.com/spf13/cobra"
+)
+
+// The escape sequences that draw the browser.
+const (
+	escEnterScreen = "\x1b[?1049h\x1b[?25l"
+	escLeaveScreen = "\x1b[?25h\x1b[?1049l"
+	escClear       = "\x1b[H\x1b[2J"
+	escBold        = "\x1b[1m"
+	escReverse     = "\x1b[7m"
+	escReset       = "\x1b[0m"
+)
+
+// browseKeys is the help of the keys of the browser, it is the last line of the
+// screen.
+const browseKeys = "j/k move  enter open  h back  i info  d download  u upload  x delete  r refresh  q quit"
+
+// errNotTerminal is the error when the browser is not run in a terminal.
+var errNotTerminal = errors.New("standard input and output must be a terminal")
+
+// browseEntry is a directory or file in the listing of the browser, only one of
+// Dir and File is set.
+type browseEntry struct {
+	Dir  *api.Dir
+	File *api.File
+}
+
+// name returns the name of the entry, a directory ends with '/'.
+func (e browseEntry) name() string {
+	if e.Dir != nil {
+		return e.Dir.DirName + "/"
+	}
+
+	return e.File.Name
+}
+
+// The 'browse' command.
+//
+// BrowseCommand opens a full screen browser of the directories and files on the
+// Clox server. The browser is navigated with the keyboard, and the selected file
+// or directory can be downloaded, deleted, or have its metadata shown. The password
+// is only entered once for the whole browser.
+type BrowseCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	// cwd is the path of the directory that is listed, it always starts with '/'.
+	cwd string
+	// key is the encryption key of the browser, it is wiped when the browser closes.
+	key      secret.Bytes
+	entries  []browseEntry
+	selected int
+	// top is the index of the entry on the first row of the listing.
+	top  int
+	info bool
+	// status is the message of the last action, it is shown above the keys.
+	status string
+	// question is the yes or no question that is being asked, answer is called with
+	// the answer. It is empty if no question is asked.
+	question string
+	answer   func(ctx context.Context) error
+	// input is the label of the line that is being typed, submit is called with the
+	// line when enter is pressed. It is empty if no line is typed.
+	input  string
+	line   []rune
+	submit func(ctx context.Context, line string) error
+}
+
+// NewBrowseCommand creates and returns a BrowseCommand.
+func NewBrowseCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, reauth *Reauthenticator) *BrowseCommand {
+	browseCmd := &BrowseCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger, reauth: reauth}
+
+	browseCmd.cmd = &cobra.Command{
+		Use:   "browse",
+		Short: "Browse the remote directories and files in the terminal",
+		Args:  cobra.ExactArgs(0),
+		RunE:  browseCmd.Run,
+	}
+
+	return browseCmd
+}
+
+// Command returns the cobra.Command of this BrowseCommand.
+func (c *BrowseCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *BrowseCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *BrowseCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *BrowseCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this BrowseCommand.
+//
+// Run lists the users root directory on the whole screen of the terminal, and
+// reads the keys until the browser is closed with q, Escape, or Ctrl-C. The keys
+// are printed on the last line of the screen:
+//   - j, k, or the arrow keys move the selection, Page Up, Page Down, Home, and End
+//     move it a screen or to the first or last entry.
+//   - Enter or l opens the selected directory, or shows the metadata of the
+//     selected file. h or Backspace opens the parent directory.
+//   - i shows or hides the metadata of the selected entry.
+//   - d downloads the selected file into the local working directory.
+//   - u asks for the path of a local file and uploads it into the directory.
+//   - x or Delete deletes the selected file, or the selected directory and
+//     everything below it.
+//   - r lists the directory again.
+//
+// Replacing a local file and deleting from the server must be confirmed with y. A
+// failed action is shown on the status line and the browser stays open, except if
+// the server rejects the API token. Standard input and output must be a terminal,
+// use the 'shell' command to script a session.
+func (c *BrowseCommand) Run(cmd *cobra.Command, args []string) error {
+	if !color.IsTerminal(os.Stdin) || !color.IsTerminal(os.Stdout) {
+		fmt.Fprintln(stderr, "Error:", errNotTerminal)
+		fmt.Fprintln(stderr, "-> [HINT] Use 'clox shell' to run commands from a script")
+		return reported(errNotTerminal)
+	}
+
+	key, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	c.key = key
+	defer c.key.Wipe()
+
+	if err := c.load(cmd.Context(), "/", ""); err != nil {
+		return c.fail(cmd.Context(), err)
+	}
+
+	if err := c.browse(cmd.Context()); err != nil {
+		return c.fail(cmd.Context(), err)
+	}
+
+	return nil
+}
+
+// fail prints the error that closed the browser and returns it as reported. If the
+// server rejected the API token, the user is asked for a new one.
+func (c *BrowseCommand) fail(ctx context.Context, err error) error {
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) {
+		if c.reauth.Handle(ctx, apiErr, c.user, *c.password) {
+			return reported(err)
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", apiErr.StatusCode, apiErr.Err)
+		printAPIErrorHint(apiErr)
+		return reported(err)
+	}
+
+	fmt.Fprintln(stderr, "Error:", err)
+	return reported(err)
+}
+
+// browse puts the terminal into raw mode and draws the browser until it is closed.
+// The terminal is restored when it returns. It returns an error if the browser
+// cannot continue, such as the server rejecting the API token.
+func (c *BrowseCommand) browse(ctx context.Context) error {
+	state, err := terminal.MakeRaw(os.Stdin.Fd())
+	if err != nil {
+		return fmt.Errorf("opening terminal: %w", err)
+	}
+	defer terminal.Restore(os.Stdin.Fd(), state)
+
+	fmt.Fprint(os.Stdout, escEnterScreen)
+	defer fmt.Fprint(os.Stdout, escLeaveScreen)
+
+	keys := terminal.NewKeyReader(os.Stdin)
+	for {
+		c.draw()
+
+		k, err := keys.ReadKey()
+		if err != nil {
+			return fmt.Errorf("reading key: %w", err)
+		}
+
+		done, err := c.handle(ctx, k)
+		if err != nil {
+			if ctx.Err() != nil || exitCode(err) == exitAuthFailure {
+				return err
+			}
+			c.status = browseError(err)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// load lists the directory at the absolute path and selects the entry with the
+// name, or the first entry if it is not listed. The directories are listed before
+// the files, each sorted by name.
+func (c *BrowseCommand) load(ctx context.Context, dirPath string, name string) error {
+	location := api.Path(dirPath)
+	if dirPath == "/" {
+		location = api.Path("")
+	}
+	listing, err := c.client.Dirs().List(ctx, location)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(listing.Dirs, func(i, j int) bool { return listing.Dirs[i].DirName < listing.Dirs[j].DirName })
+	sort.Slice(listing.Files, func(i, j int) bool { return listing.Files[i].Name < listing.Files[j].Name })
+	entries := make([]browseEntry, 0, len(listing.Dirs)+len(listing.Files))
+	for i := range listing.Dirs {
+		entries = append(entries, browseEntry{Dir: &listing.Dirs[i]})
+	}
+	for i := range listing.Files {
+		entries = append(entries, browseEntry{File: &listing.Files[i]})
+	}
+
+	c.cwd = dirPath
+	c.entries = entries
+	c.selected, c.top = 0, 0
+	for i, e := range entries {
+		if e.name() == name {
+			c.selected = i
+		}
+	}
+
+	return nil
+}
+
+// current returns the selected entry, it is false if the directory is empty.
+func (c *BrowseCommand) current() (browseEntry, bool) {
+	if len(c.entries) == 0 {
+		return browseEntry{}, false
+	}
+
+	return c.entries[c.selected], true
+}
+
+// handle does the action of the key. It returns true if the browser is closed.
+func (c *BrowseCommand) handle(ctx context.Context, k terminal.Key) (bool, error) {
+	switch {
+	case c.question != "":
+		return false, c.handleAnswer(ctx, k)
+	case c.input != "":
+		return false, c.handleInput(ctx, k)
+	}
+
+	c.status = ""
+	switch k {
+	case 'q', terminal.KeyEscape, terminal.KeyCtrlC, terminal.KeyCtrlD:
+		return true, nil
+	case 'k', terminal.KeyUp:
+		c.move(-1)
+	case 'j', terminal.KeyDown:
+		c.move(1)
+	case terminal.KeyPageUp:
+		c.move(-c.rows())
+	case terminal.KeyPageDown:
+		c.move(c.rows())
+	case 'g', terminal.KeyHome:
+		c.move(-len(c.entries))
+	case 'G', terminal.KeyEnd:
+		c.move(len(c.entries))
+	case 'l', terminal.KeyEnter, terminal.KeyRight:
+		e, ok := c.current()
+		if !ok {
+			return false, nil
+		}
+		if e.File != nil {
+			c.info = !c.info
+			return false, nil
+		}
+		return false, c.load(ctx, e.Dir.DirPath, "")
+	case 'h', terminal.KeyBackspace, terminal.KeyLeft:
+		if c.cwd == "/" {
+			return false, nil
+		}
+		return false, c.load(ctx, path.Dir(c.cwd), path.Base(c.cwd)+"/")
+	case 'i':
+		c.info = !c.info
+	case 'r':
+		e, _ := c.current()
+		return false, c.load(ctx, c.cwd, e.name())
+	case 'd':
+		return false, c.download(ctx)
+	case 'u':
+		c.input = "Upload local file: "
+		c.line = nil
+		c.submit = c.upload
+	case 'x', terminal.KeyDelete:
+		c.delete()
+	}
+
+	return false, nil
+}
+
+// handleAnswer answers the question that is asked with the key. Only y is yes,
+// every other key is no.
+func (c *BrowseCommand) handleAnswer(ctx context.Context, k terminal.Key) error {
+	answer := c.answer
+	c.question, c.answer = "", nil
+	if k != 'y' && k != 'Y' {
+		c.status = "Cancelled"
+		return nil
+	}
+
+	return answer(ctx)
+}
+
+// handleInput edits the line that is typed with the key. Enter submits the line,
+// and Escape or Ctrl-C cancels it.
+func (c *BrowseCommand) handleInput(ctx context.Context, k terminal.Key) error {
+	switch k {
+	case terminal.KeyEnter:
+		line, submit := string(c.line), c.submit
+		c.input, c.line, c.submit = "", nil, nil
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		return submit(ctx, line)
+	case terminal.KeyEscape, terminal.KeyCtrlC:
+		c.input, c.line, c.submit = "", nil, nil
+		c.status = "Cancelled"
+	case terminal.KeyBackspace:
+		if len(c.line) > 0 {
+			c.line = c.line[:len(c.line)-1]
+		}
+	default:
+		if k >= 0 && unicode.IsPrint(rune(k)) {
+			c.line = append(c.line, rune(k))
+		}
+	}
+
+	return nil
+}
+
+// move moves the selection by n entries, it stops at the first and last entry.
+func (c *BrowseCommand) move(n int) {
+	c.selected = min(max(c.selected+n, 0), max(len(c.entries)-1, 0))
+}
+
+// download downloads the selected file into the local working directory, with the
+// name of the file. If the local file exists, the user is asked to replace it
+// first.
+func (c *BrowseCommand) download(ctx context.Context) error {
+	e, ok := c.current()
+	if !ok {
+		return nil
+	}
+	if e.File == nil {
+		c.status = "Only files can be downloaded, use 'clox pull' for a directory"
+		return nil
+	}
+	file := *e.File
+	if !filepath.IsLocal(file.Name) {
+		return fmt.Errorf("'%s': %w", file.Path, errUnsafeName)
+	}
+
+	output := file.Name
+	download := func(ctx context.Context) error {
+		if err := downloadFile(ctx, c.client.Files(), c.aes, file, output, c.key); err != nil {
+			return err
+		}
+		trackFiles(c.store, c.aes, *c.password, c.logger, file)
+		c.status = fmt.Sprintf("Downloaded: %s -> %s", file.Path, output)
+		return nil
+	}
+
+	if _, err := os.Stat(output); err == nil {
+		c.question = fmt.Sprintf("'%s' already exists, overwrite it? [y/N]", output)
+		c.answer = download
+		return nil
+	}
+
+	return download(ctx)
+}
+
+// upload uploads the local file into the listed directory, with the name of the
+// local file, and selects it.
+func (c *BrowseCommand) upload(ctx context.Context, local string) error {
+	if info, err := os.Stat(local); err != nil || info.IsDir() {
+		if err == nil {
+			err = errors.New("is a directory")
+		}
+		return fmt.Errorf("'%s': %w", local, err)
+	}
+
+	dir := api.Path(c.cwd)
+	if c.cwd == "/" {
+		dir = api.Path("")
+	}
+	uploaded, err := uploadFile(ctx, c.client.Uploads(), c.aes, dir, local, filepath.Base(local), c.key)
+	if err != nil {
+		return err
+	}
+
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		idx.Add(index.UploadEntry(*uploaded))
+	})
+	trackUploads(c.store, c.aes, *c.password, c.logger, []api.UploadFileResponse{*uploaded})
+
+	if err := c.load(ctx, c.cwd, path.Base(uploaded.Path)); err != nil {
+		return err
+	}
+	c.status = fmt.Sprintf("Uploaded: %s -> %s", local, uploaded.Path)
+
+	return nil
+}
+
+// delete asks the user to confirm that the selected file or directory is deleted
+// from the server, a directory is deleted with everything below it.
+func (c *BrowseCommand) delete() {
+	e, ok := c.current()
+	if !ok {
+		return
+	}
+
+	if e.Dir != nil {
+		c.question = fmt.Sprintf("Delete '%s' and everything below it from the server? [y/N]", e.Dir.DirPath)
+	} else {
+		c.question = fmt.Sprintf("Delete '%s' from the server? [y/N]", e.File.Path)
+	}
+	c.answer = func(ctx context.Context) error {
+		var r removed
+		if e.Dir != nil {
+			deleted, err := c.client.Dirs().Delete(ctx, api.ID(e.Dir.ID), true)
+			if err != nil {
+				return err
+			}
+			r = removed{ID: e.Dir.ID, Path: e.Dir.DirPath, Dir: true, Dirs: deleted.Dirs, Files: deleted.Files}
+		} else {
+			if _, err := c.client.Files().Delete(ctx, api.ID(e.File.ID)); err != nil {
+				return err
+			}
+			r = removed{ID: e.File.ID, Path: e.File.Path}
+		}
+		forgetRemoved(c.store, c.aes, *c.password, c.logger, []removed{r})
+
+		selected := c.selected
+		if err := c.load(ctx, c.cwd, ""); err != nil {
+			return err
+		}
+		c.move(selected)
+		c.status = fmt.Sprintf("Deleted: %s", r.Path)
+		return nil
+	}
+}
+
+// size returns the width and height of the terminal, 80 by 24 if it is not known.
+func (c *BrowseCommand) size() (int, int) {
+	width, height, err := terminal.Size(os.Stdout.Fd())
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+
+	return width, height
+}
+
+// rows returns the number of entries that fit on the screen. The screen has a
+// title, the listing, the metadata of the selected entry if it is shown, a status
+// line, and the keys.
+func (c *BrowseCommand) rows() int {
+	_, height := c.size()
+	rows := height - 3
+	if c.info {
+		rows -= len(c.metadata()) + 1
+	}
+
+	return max(rows, 1)
+}
+
+// metadata returns the metadata of the selected entry, one field per line.
+func (c *BrowseCommand) metadata() []string {
+	e, ok := c.current()
+	switch {
+	case !ok:
+		return []string{"The directory is empty"}
+	case e.Dir != nil:
+		return []string{
+			"Directory: " + e.Dir.DirPath,
+			"ID:        " + e.Dir.ID,
+			"Created:   " + formatTime(e.Dir.CreatedAt),
+			"Updated:   " + formatTime(e.Dir.UpdatedAt),
+			"Written:   " + formatTime(e.Dir.LastWrite),
+		}
+	}
+
+	f := e.File
+	lines := []string{
+		"File:      " + f.Path,
+		"ID:        " + f.ID,
+		"Size:      " + formatBytes(f.Size),
+		"Uploaded:  " + formatTime(f.UploadedAt),
+		"Updated:   " + formatTime(f.UpdatedAt),
+	}
+	if f.ContentType != "" {
+		lines = append(lines, "Type:      "+f.ContentType)
+	}
+	if f.Checksum != "" {
+		lines = append(lines, "Checksum:  "+f.Checksum)
+	}
+	if f.Lock != nil {
+		lines = append(lines, "Locked:    by "+f.Lock.Username+" until "+formatTime(f.Lock.ExpiresAt))
+	}
+
+	return lines
+}
+
+// draw draws the whole screen of the browser.
+func (c *BrowseCommand) draw() {
+	width, _ := c.size()
+	rows := c.rows()
+	if c.selected < c.top {
+		c.top = c.selected
+	}
+	if c.selected >= c.top+rows {
+		c.top = c.selected - rows + 1
+	}
+
+	var b strings.Builder
+	b.WriteString(escClear)
+	b.WriteString(escBold + fit("Clox: "+c.cwd, width) + escReset + "\n")
+
+	for row := 0; row < rows; row++ {
+		i := c.top + row
+		switch {
+		case len(c.entries) == 0 && row == 0:
+			b.WriteString("  (empty)")
+		case i < len(c.entries):
+			e := c.entries[i]
+			line := "  " + e.name()
+			if e.File != nil {
+				size := formatBytes(e.File.Size)
+				line = fit(line, max(width-len(size)-2, 0))
+				line += strings.Repeat(" ", max(width-len([]rune(line))-len(size)-1, 1)) + size
+			}
+			line = fit(line, width)
+			if i == c.selected {
+				line = escReverse + line + strings.Repeat(" ", max(width-len([]rune(line)), 0)) + escReset
+			}
+			b.WriteString(line)
+		}
+		b.WriteString("\n")
+	}
+
+	if c.info {
+		b.WriteString(strings.Repeat("-", width) + "\n")
+		for _, line := range c.metadata() {
+			b.WriteString(fit(line, width) + "\n")
+		}
+	}
+
+	switch {
+	case c.question != "":
+		b.WriteString(escBold + fit(c.question, width) + escReset)
+	case c.input != "":
+		b.WriteString(escBold + c.input + escReset + fit(string(c.line)+"_", width-len(c.input)))
+	default:
+		b.WriteString(fit(c.status, width))
+	}
+	b.WriteString("\n" + fit(browseKeys, width))
+
+	fmt.Fprint(os.Stdout, b.String())
+}
+
+// fit cuts s to the width, the end of a line that is cut is replaced with "~".
+func fit(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+
+	return string(r[:width-1]) + "~"
+}
+
+// browseError returns the status line of an action that failed.
+func browseError(err error) string {
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("API Error [%d]: %s", apiErr.StatusCode, apiErr.Err)
+	}
+
+	return fmt.Sprintf("Error: %s", err)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/cicconee/clox-cli/internal/cache"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+)
+
+// cacheFile is the name of the encrypted file of a profile that caches the remote
+// directory listings that were recently seen.
+const cacheFile = "cache.enc"
+
+// loadCache loads the cache.Cache of the active profile. If it cannot be read, it
+// is logged and an empty cache.Cache is returned, the listings can always be
+// fetched again.
+func loadCache(store *config.Store, aes *crypto.AES, password []byte, logger *logging.Logger) *cache.Cache {
+	c, err := cache.Load(store.File(cacheFile), aes, password)
+	if err != nil {
+		logger.Debug("loading cache", "error", err)
+		return cache.New()
+	}
+
+	return c
+}
+
+// saveCache saves the cache.Cache of the active profile. A failed save is logged and
+// never fails the command.
+func saveCache(store *config.Store, aes *crypto.AES, password []byte, logger *logging.Logger, c *cache.Cache) {
+	if err := c.Save(store.File(cacheFile), aes, password); err != nil {
+		logger.Debug("saving cache", "error", err)
+	}
+}
+
+// clearCache removes the cache.Cache of the active profile, after a command changed
+// the remote directories. A failed removal is logged and never fails the command.
+func clearCache(store *config.Store, logger *logging.Logger) {
+	if err := os.Remove(store.File(cacheFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("removing cache", "error", err)
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/cicconee/clox-cli/internal/color"
+)
+
+// stdout and stderr are the standard output and standard error of the commands. The
+// lines written to them are highlighted if color is enabled, see configureColor.
+var (
+	stdout = color.NewWriter(os.Stdout, highlight)
+	stderr = color.NewWriter(os.Stderr, highlight)
+)
+
+// configureColor enables the color of stdout and stderr in the mode, each is
+// colored on its own, such as when only standard output is piped.
+func configureColor(mode color.Mode) {
+	stdout.SetEnabled(mode.Enabled(os.Stdout))
+	stderr.SetEnabled(mode.Enabled(os.Stderr))
+}
+
+var (
+	// errorLabel matches the label of an error, such as 'API Error [404]:'.
+	errorLabel = regexp.MustCompile(`^(API Error \[\d+\]:|Error:|Invalid [^:]*:)`)
+	// tagLabel matches the tag of a detail line, such as '-> [HINT]'.
+	tagLabel = regexp.MustCompile(`^-> \[([A-Z]+)\]`)
+	// fieldLabel matches the label of a field, such as 'Downloaded:' or '-> ID:'.
+	fieldLabel = regexp.MustCompile(`^(-> )?([A-Z][A-Za-z ]*):(\s|$)`)
+)
+
+// highlight returns the line with its label highlighted:
+//   - The label of an error is red, see errorLabel.
+//   - The tag of a hint or warning is yellow, other tags are bold.
+//   - A count or list of things that succeeded, such as 'Uploaded:', is green. Things
+//     that failed are red, and things that were skipped or conflict are yellow.
+//   - The label of any other field is cyan.
+func highlight(line string) string {
+	if loc := errorLabel.FindStringIndex(line); loc != nil {
+		return color.Error(line[:loc[1]]) + line[loc[1]:]
+	}
+
+	if m := tagLabel.FindStringSubmatchIndex(line); m != nil {
+		tag := line[:m[1]]
+		switch line[m[2]:m[3]] {
+		case "HINT", "WARN", "REASON":
+			tag = color.Warning(tag)
+		default:
+			tag = color.Bold(tag)
+		}
+		return tag + line[m[1]:]
+	}
+
+	m := fieldLabel.FindStringSubmatchIndex(line)
+	if m == nil {
+		return line
+	}
+	start, end := m[4], m[5]+1
+	label := line[m[4]:m[5]]
+	value := strings.TrimSpace(line[end:])
+	var styled string
+	switch {
+	case label == "Errors" || label == "Failed" || label == "MISMATCHED":
+		styled = color.Field(line[start:end])
+		if value != "0" {
+			styled = color.Error(line[start:end])
+		}
+	case label == "Skipped" || label == "Canceled" || label == "Conflict" || label == "Conflicts":
+		styled = color.Warning(line[start:end])
+	case m[2] < 0 && strings.HasSuffix(label, "ed") && !strings.Contains(label, " ") && label != "Unchanged":
+		styled = color.Success(line[start:end])
+	default:
+		styled = color.Field(line[start:end])
+	}
+
+	return line[:start] + styled + line[end:]
+}
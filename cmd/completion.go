@@ -0,0 +1,433 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/cache"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout is how long completing a remote path or ID may wait for the
+// server. The shell is blocked until the completion returns.
+const completionTimeout = 3 * time.Second
+
+// completionFunc is the function that completes the arguments or a flag of a
+// cobra.Command.
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// completionKind is the kind of remote entry that is completed.
+type completionKind int
+
+const (
+	completeDirs completionKind = iota
+	completeFiles
+	completeAll
+)
+
+// matches reports whether the entry is of this completionKind.
+func (k completionKind) matches(e index.Entry) bool {
+	switch k {
+	case completeDirs:
+		return e.Dir
+	case completeFiles:
+		return !e.Dir
+	default:
+		return true
+	}
+}
+
+// Completer completes the paths and IDs of the remote directories and files in the
+// shell. The entries are read from the local index, if it has not been built the
+// directory being completed is listed from the cache, or by calling the API.
+//
+// The index and API token are encrypted with the users password, and a completion
+// cannot prompt for it. Remote entries are only completed when the password can be
+// unlocked without a prompt, such as with biometric unlock. Otherwise nothing is
+// completed.
+type Completer struct {
+	store  *config.Store
+	aes    *crypto.AES
+	logger *logging.Logger
+	unlock func(*config.User) (secret.Bytes, bool)
+}
+
+// NewCompleter creates and returns a Completer. The unlock function returns the
+// password of the user without prompting for it, or false if it cannot. The
+// Completer wipes the password once it is used.
+func NewCompleter(store *config.Store, aes *crypto.AES, logger *logging.Logger, unlock func(*config.User) (secret.Bytes, bool)) *Completer {
+	return &Completer{store: store, aes: aes, logger: logger, unlock: unlock}
+}
+
+// Register sets the completions of the remote arguments and flags of the commands
+// below root, and of the profiles and aliases that are configured.
+func (c *Completer) Register(root *cobra.Command) {
+	c.args(root, "download", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if zip, _ := cmd.Flags().GetBool("zip"); zip {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return c.paths(cmd, completeDirs, toComplete)
+		}
+		return c.ids(cmd, completeFiles, toComplete, args...)
+	})
+	c.args(root, "preview", c.argIDs(0, completeFiles))
+	c.args(root, "append", c.argPaths(1, completeFiles))
+	c.args(root, "lock", c.argPaths(0, completeFiles))
+	c.args(root, "unlock", c.argPaths(0, completeFiles))
+	c.args(root, "rm", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return c.paths(cmd, completeAll, toComplete)
+	})
+
+	c.flag(root, "lock", "id", c.argIDs(-1, completeFiles))
+	c.flag(root, "unlock", "id", c.argIDs(-1, completeFiles))
+	c.flag(root, "rm", "id", c.argIDs(-1, completeAll))
+	for _, name := range []string{"mv", "cp"} {
+		c.args(root, name, c.sourceAndDest())
+		c.flag(root, name, "id", c.argIDs(-1, completeAll))
+		c.flag(root, name, "dest-id", c.argIDs(-1, completeDirs))
+	}
+	c.args(root, "rename", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if id, _ := cmd.Flags().GetString("id"); id != "" || len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return c.paths(cmd, completeAll, toComplete)
+	})
+	c.flag(root, "rename", "id", c.argIDs(-1, completeAll))
+	c.args(root, "tree", c.argPaths(0, completeDirs))
+	c.flag(root, "tree", "id", c.argIDs(-1, completeDirs))
+	c.args(root, "stat", c.argPaths(0, completeAll))
+	c.flag(root, "stat", "id", c.argIDs(-1, completeAll))
+	c.args(root, "sync", c.argPaths(1, completeDirs))
+	c.args(root, "pull", c.argPaths(0, completeDirs))
+	c.args(root, "versions", c.argPaths(0, completeFiles))
+	c.args(root, "versions get", c.argPaths(0, completeFiles))
+	c.args(root, "verify", c.argPaths(0, completeDirs))
+	for _, name := range []string{"upload", "push", "mkdir", "search"} {
+		c.flag(root, name, "path", c.argPaths(-1, completeDirs))
+		c.flag(root, name, "id", c.argIDs(-1, completeDirs))
+	}
+
+	for _, name := range []string{"use", "profile use"} {
+		c.args(root, name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return c.profiles(toComplete)
+		})
+	}
+	if err := root.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return c.profiles(toComplete)
+	}); err != nil {
+		c.logger.Debug("registering flag completion", "command", root.Name(), "flag", "profile", "error", err)
+	}
+	c.args(root, "alias rm", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return c.aliases(toComplete)
+	})
+}
+
+// profiles completes the names of the configured profiles that start with
+// toComplete.
+func (c *Completer) profiles(toComplete string) ([]string, cobra.ShellCompDirective) {
+	profiles, err := c.store.Profiles()
+	if err != nil {
+		cobra.CompDebugln("reading profiles: "+err.Error(), false)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	completions := []string{}
+	for _, p := range profiles {
+		if strings.HasPrefix(p, toComplete) {
+			completions = append(completions, p)
+		}
+	}
+
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// aliases completes the names of the aliases that start with toComplete. Each name
+// is described by the command it expands to.
+func (c *Completer) aliases(toComplete string) ([]string, cobra.ShellCompDirective) {
+	aliases, err := c.store.ReadAliases()
+	if err != nil {
+		cobra.CompDebugln("reading aliases: "+err.Error(), false)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	completions := []string{}
+	for name, command := range aliases {
+		if strings.HasPrefix(name, toComplete) {
+			completions = append(completions, name+"\t"+command)
+		}
+	}
+	sort.Strings(completions)
+
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// args sets fn as the completion of the arguments of the command with the name.
+func (c *Completer) args(root *cobra.Command, name string, fn completionFunc) {
+	if cmd, _, err := root.Find(strings.Fields(name)); err == nil && cmd != root {
+		cmd.ValidArgsFunction = fn
+	}
+}
+
+// flag sets fn as the completion of the flag of the command with the name.
+func (c *Completer) flag(root *cobra.Command, name string, flag string, fn completionFunc) {
+	if cmd, _, err := root.Find(strings.Fields(name)); err == nil && cmd != root {
+		if err := cmd.RegisterFlagCompletionFunc(flag, fn); err != nil {
+			c.logger.Debug("registering flag completion", "command", name, "flag", flag, "error", err)
+		}
+	}
+}
+
+// sourceAndDest returns a completion of the source and destination arguments of a
+// command. The source is any remote path, unless the id flag is set, and the
+// destination is a remote directory.
+func (c *Completer) sourceAndDest() completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if id, _ := cmd.Flags().GetString("id"); id == "" && len(args) == 0 {
+			return c.paths(cmd, completeAll, toComplete)
+		}
+		return c.paths(cmd, completeDirs, toComplete)
+	}
+}
+
+// argPaths returns a completion of remote paths for the argument at position pos.
+// Other arguments are completed as local files. A pos of -1 is used for flags.
+func (c *Completer) argPaths(pos int, kind completionKind) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if pos >= 0 && len(args) != pos {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return c.paths(cmd, kind, toComplete)
+	}
+}
+
+// argIDs returns a completion of remote IDs for the argument at position pos. Other
+// arguments are not completed. A pos of -1 is used for flags.
+func (c *Completer) argIDs(pos int, kind completionKind) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if pos >= 0 && len(args) != pos {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return c.ids(cmd, kind, toComplete)
+	}
+}
+
+// paths completes the paths of the remote entries of the kind that start with
+// toComplete. A toComplete without a leading '/' is completed the same way.
+func (c *Completer) paths(cmd *cobra.Command, kind completionKind, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := "/" + strings.TrimPrefix(toComplete, "/")
+
+	completions := []string{}
+	for _, e := range c.entries(cmd, path.Dir(prefix), false) {
+		if !kind.matches(e) || !strings.HasPrefix(e.Path, prefix) {
+			continue
+		}
+
+		p := e.Path
+		if !strings.HasPrefix(toComplete, "/") {
+			p = strings.TrimPrefix(p, "/")
+		}
+		completions = append(completions, p)
+	}
+	sort.Strings(completions)
+
+	// Entries of a stale index can share a path, each path is completed once.
+	unique := completions[:0]
+	for i, p := range completions {
+		if i == 0 || p != completions[i-1] {
+			unique = append(unique, p)
+		}
+	}
+
+	return unique, cobra.ShellCompDirectiveNoFileComp
+}
+
+// ids completes the IDs of the remote entries of the kind that start with
+// toComplete. Each ID is described by its path. The IDs that were already given,
+// such as the earlier arguments, are not completed again.
+func (c *Completer) ids(cmd *cobra.Command, kind completionKind, toComplete string, given ...string) ([]string, cobra.ShellCompDirective) {
+	seen := map[string]bool{}
+	for _, id := range given {
+		seen[id] = true
+	}
+
+	entries := []index.Entry{}
+	for _, e := range c.entries(cmd, "/", true) {
+		if kind.matches(e) && strings.HasPrefix(e.ID, toComplete) && !seen[e.ID] {
+			seen[e.ID] = true
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	completions := make([]string, len(entries))
+	for i, e := range entries {
+		completions[i] = e.ID + "\t" + e.Path
+	}
+
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// entries returns the remote entries to complete. If the local index is built, all
+// of its entries are returned. Otherwise the listing of the directory at dir is
+// served from the cache, or listed by calling the API if it is not cached or the
+// command being completed has the refresh flag (--refresh) set. If cached is set, the
+// entries of every other fresh listing in the cache are returned as well. Any error
+// is written to the completion debug log and no entries are returned.
+func (c *Completer) entries(cmd *cobra.Command, dir string, cached bool) []index.Entry {
+	user := &config.User{}
+	if err := c.store.ReadConfigFile(user); err != nil {
+		cobra.CompDebugln("reading config file: "+err.Error(), false)
+		return nil
+	}
+
+	password, ok := c.unlock(user)
+	if !ok {
+		cobra.CompDebugln("password cannot be unlocked without a prompt", false)
+		return nil
+	}
+	defer password.Wipe()
+
+	idx, err := index.Load(c.store.File(indexFile), c.aes, password)
+	if err == nil {
+		entries := make([]index.Entry, 0, len(idx.Entries))
+		for _, e := range idx.Entries {
+			entries = append(entries, e)
+		}
+		return entries
+	}
+	cobra.CompDebugln("loading index: "+err.Error(), false)
+
+	token, err := apiToken(user, c.aes, password)
+	if err != nil {
+		cobra.CompDebugln("decrypting api token: "+err.Error(), false)
+		return nil
+	}
+	defer token.Wipe()
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), completionTimeout)
+	defer cancel()
+
+	refresh, _ := cmd.Flags().GetBool("refresh")
+	var opts []api.Option
+	if codec, _, err := newNameCodec(c.store, user, c.aes, password, c.logger); err == nil && codec != nil {
+		opts = append(opts, api.WithNames(codec))
+	}
+	client, err := newUserAPIClient(c.store, user, token, c.logger, opts...)
+	if err != nil {
+		cobra.CompDebugln("creating api client: "+err.Error(), false)
+		return nil
+	}
+
+	listings := loadCache(c.store, c.aes, password, c.logger)
+	lister := &cache.Lister{
+		Cache:   listings,
+		Dirs:    client.Dirs(),
+		Refresh: refresh,
+	}
+	listing, err := lister.List(ctx, api.Path(strings.TrimSuffix(dir, "/")))
+	if err != nil {
+		cobra.CompDebugln("listing directory: "+err.Error(), false)
+		return nil
+	}
+	saveCache(c.store, c.aes, password, c.logger, listings)
+
+	toComplete := []api.DirListing{*listing}
+	if cached {
+		toComplete = append(toComplete, listings.Fresh()...)
+	}
+
+	entries := []index.Entry{}
+	for _, l := range toComplete {
+		for _, d := range l.Dirs {
+			entries = append(entries, index.DirEntry(d))
+		}
+		for _, f := range l.Files {
+			entries = append(entries, index.FileEntry(f))
+		}
+	}
+
+	return entries
+}
+
+// CompletionCommand is the command that prints the completion script of a shell.
+type CompletionCommand struct {
+	cmd *cobra.Command
+}
+
+// NewCompletionCommand creates and returns a CompletionCommand. It replaces the
+// completion command of cobra, the shells are bash, zsh, and fish.
+func NewCompletionCommand() *CompletionCommand {
+	completionCmd := &CompletionCommand{}
+
+	completionCmd.cmd = &cobra.Command{
+		Use:   "completion <bash|zsh|fish>",
+		Short: "Print the shell completion script",
+		Long: `Print the completion script of the shell to standard output.
+
+Remote paths and IDs are completed from the local index, see 'clox index rebuild',
+or the cached directory listings. A directory that is not cached is listed from the
+server. Remote entries are only completed if the password can be unlocked without a
+prompt, such as with 'clox session unlock' or 'clox keyring enable'.
+
+Bash:
+  source <(clox completion bash)
+  # or, for every session:
+  clox completion bash > /etc/bash_completion.d/clox
+
+Zsh:
+  clox completion zsh > "${fpath[1]}/_clox"
+
+Fish:
+  clox completion fish > ~/.config/fish/completions/clox.fish`,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		RunE:      completionCmd.Run,
+	}
+
+	return completionCmd
+}
+
+// Command returns the cobra.Command of this CompletionCommand.
+func (c *CompletionCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this CompletionCommand.
+//
+// Run writes the completion script of the shell in the arguments to standard output.
+// The scripts include the descriptions of the completions.
+func (c *CompletionCommand) Run(cmd *cobra.Command, args []string) error {
+	var err error
+	switch args[0] {
+	case "bash":
+		err = cmd.Root().GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		err = cmd.Root().GenZshCompletion(os.Stdout)
+	case "fish":
+		err = cmd.Root().GenFishCompletion(os.Stdout, true)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return reported(err)
+	}
+
+	return nil
+}
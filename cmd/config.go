@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// The 'config' command.
+//
+// ConfigCommand groups the sub commands that maintain the configuration file of a
+// profile. It does nothing on its own.
+type ConfigCommand struct {
+	cmd *cobra.Command
+}
+
+// NewConfigCommand creates and returns a ConfigCommand.
+func NewConfigCommand() *ConfigCommand {
+	return &ConfigCommand{
+		cmd: &cobra.Command{
+			Use:   "config",
+			Short: "Maintain the configuration file of the CLI",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this ConfigCommand.
+func (c *ConfigCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'config upgrade-keys' command.
+//
+// ConfigUpgradeKeysCommand encrypts the private key of the active profile again in
+// the current format, see security.PrivateKeyVersion.
+type ConfigUpgradeKeysCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	logger   *logging.Logger
+}
+
+// NewConfigUpgradeKeysCommand creates and returns a ConfigUpgradeKeysCommand.
+func NewConfigUpgradeKeysCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, logger *logging.Logger) *ConfigUpgradeKeysCommand {
+	upgradeCmd := &ConfigUpgradeKeysCommand{store: store, keys: keys, aes: aes, logger: logger}
+
+	upgradeCmd.cmd = &cobra.Command{
+		Use:   "upgrade-keys",
+		Short: "Store the private key in the current format",
+		Args:  cobra.ExactArgs(0),
+		RunE:  upgradeCmd.Run,
+	}
+
+	return upgradeCmd
+}
+
+// Command returns the cobra.Command of this ConfigUpgradeKeysCommand.
+func (c *ConfigUpgradeKeysCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ConfigUpgradeKeysCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *ConfigUpgradeKeysCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this ConfigUpgradeKeysCommand.
+//
+// Run encrypts the private key of the active profile again as a PKCS #8 key, in a
+// PEM block that declares the version of the format, and the cipher and KDF it is
+// encrypted with, see config.User.UpgradeKeys. The secrets encrypted with the
+// password are encrypted again with the KDF and cipher of the profile. Keys in the
+// older format are still read, the command only has to be run once per profile, and
+// changing the password upgrades the keys as well.
+//
+// Before the configuration file is rewritten atomically, it is copied to a backup
+// named after the time of the upgrade. If the key is already in the current format,
+// nothing is written.
+func (c *ConfigUpgradeKeysCommand) Run(cmd *cobra.Command, args []string) error {
+	from, err := c.user.KeyVersion()
+	if errors.Is(err, config.ErrHardwareKey) {
+		printHardwareKey(c.store.Profile)
+		return reported(err)
+	}
+	if err != nil {
+		c.logger.Error("reading private key version", "error", err)
+		return reported(err)
+	}
+
+	upgraded, err := c.user.UpgradeKeys(c.keys, c.aes, *c.password)
+	if err != nil {
+		c.logger.Error("upgrading keys", "error", err)
+		return reported(err)
+	}
+	if !upgraded {
+		fmt.Fprintf(stdout, "The keys of profile '%s' are already in the current format (version %d)\n", c.store.Profile, from)
+		return nil
+	}
+
+	backup, err := c.store.BackupConfigFile("upgrade-keys-" + time.Now().Format("20060102T150405"))
+	if err != nil {
+		c.logger.Error("backing up config file", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Keys of profile '%s' upgraded from version %d to version %d\n", c.store.Profile, from, security.PrivateKeyVersion)
+	c.logger.Printf("-> [HINT] The keys in the old format were saved to %s, delete it once the upgraded keys work\n", backup)
+
+	return nil
+}
+
+// The 'config authenticate' command.
+//
+// ConfigAuthenticateCommand authenticates the configuration file of the active
+// profile when it has no MAC, such as a file written before the files were
+// authenticated, see config.User.Authenticate.
+type ConfigAuthenticateCommand struct {
+	cmd       *cobra.Command
+	user      *config.User
+	password  *secret.Bytes
+	integrity error
+	store     *config.Store
+	keys      *security.Keys
+	aes       *crypto.AES
+	logger    *logging.Logger
+}
+
+// NewConfigAuthenticateCommand creates and returns a ConfigAuthenticateCommand.
+func NewConfigAuthenticateCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, logger *logging.Logger) *ConfigAuthenticateCommand {
+	authCmd := &ConfigAuthenticateCommand{store: store, keys: keys, aes: aes, logger: logger}
+
+	authCmd.cmd = &cobra.Command{
+		Use:   "authenticate",
+		Short: "Authenticate a configuration file that has no MAC",
+		Args:  cobra.ExactArgs(0),
+		RunE:  authCmd.Run,
+	}
+
+	return authCmd
+}
+
+// Command returns the cobra.Command of this ConfigAuthenticateCommand.
+func (c *ConfigAuthenticateCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ConfigAuthenticateCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *ConfigAuthenticateCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *ConfigAuthenticateCommand) SetIntegrity(err error) {
+	c.integrity = err
+}
+
+// Run is the RunE function of the cobra.Command in this ConfigAuthenticateCommand.
+//
+// Run only authenticates a configuration file that has no MAC, see config.ErrNoMAC.
+// A file without a MAC may have been modified, so the fingerprints of its keys are
+// printed and the user must confirm they are the keys of the profile before it is
+// authenticated. The file is then written with a MAC, and every command verifies it
+// from now on. A file that is already authenticated is not changed, and a file whose
+// MAC does not match is never authenticated.
+func (c *ConfigAuthenticateCommand) Run(cmd *cobra.Command, args []string) error {
+	switch {
+	case c.integrity == nil:
+		fmt.Fprintf(stdout, "The configuration file of profile '%s' is already authenticated\n", c.store.Profile)
+		return nil
+	case errors.Is(c.integrity, config.ErrNoMAC):
+	case errors.Is(c.integrity, config.ErrConfigModified):
+		fmt.Fprintf(stderr, "The configuration file of profile '%s' was modified outside of the CLI\n", c.store.Profile)
+		fmt.Fprintln(stderr, "-> [HINT] Its MAC does not match, restore a backup of config.json you trust or run 'clox init -f'")
+		return reported(nil)
+	default:
+		c.logger.Error("verifying config file", "error", c.integrity)
+		return reported(c.integrity)
+	}
+
+	pub, err := c.user.RSAPublicKey(c.keys)
+	if err != nil {
+		c.logger.Error("decoding public key", "error", err)
+		return reported(err)
+	}
+	pubFingerprint, err := security.PublicKeyFingerprint(pub)
+	if err != nil {
+		c.logger.Error("computing public key fingerprint", "error", err)
+		return reported(err)
+	}
+	encFingerprint, err := c.user.EncryptKeyFingerprint()
+	if err != nil {
+		c.logger.Error("computing encryption key fingerprint", "error", err)
+		return reported(err)
+	}
+
+	fmt.Fprintf(stdout, "The configuration file of profile '%s' has no MAC, it may have been modified outside of the CLI\n", c.store.Profile)
+	fmt.Fprintf(stdout, "Public Key: %s (%d bits)\n", pubFingerprint, pub.N.BitLen())
+	fmt.Fprintf(stdout, "Encryption Key: %s\n", encFingerprint)
+	fmt.Fprintln(stdout, "Only authenticate it if these are the fingerprints of the profile, see 'clox keys fingerprint' on another machine")
+	if !prompt.Confirm("Authenticate the configuration file?") {
+		return reported(nil)
+	}
+
+	if err := c.user.Authenticate(c.aes, *c.password); err != nil {
+		c.logger.Error("authenticating config file", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	fmt.Fprintf(stdout, "Configuration file of profile '%s' authenticated\n", c.store.Profile)
+	return nil
+}
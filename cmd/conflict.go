@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/tracking"
+)
+
+// trackingFile is the name of the encrypted file of a profile that records the
+// state of the remote files when the local copies were fetched or uploaded.
+const trackingFile = "tracking.enc"
+
+// loadTracker loads the tracking.Tracker of the active profile.
+func loadTracker(store *config.Store, aes *crypto.AES, password []byte) (*tracking.Tracker, error) {
+	return tracking.Load(store.File(trackingFile), aes, password)
+}
+
+// trackUploads records the uploaded files in the tracking.Tracker of the active
+// profile. A failed update is logged and never fails the command.
+func trackUploads(store *config.Store, aes *crypto.AES, password []byte, logger *logging.Logger, uploaded []api.UploadFileResponse) {
+	files := []api.File{}
+	for _, u := range uploaded {
+		files = append(files, u.File())
+	}
+
+	trackFiles(store, aes, password, logger, files...)
+}
+
+// trackFiles records the files in the tracking.Tracker of the active profile, the
+// local copies are now the same as the files on the server. A failed update is
+// logged and never fails the command.
+func trackFiles(store *config.Store, aes *crypto.AES, password []byte, logger *logging.Logger, files ...api.File) {
+	if len(files) == 0 {
+		return
+	}
+
+	t, err := loadTracker(store, aes, password)
+	if err != nil {
+		logger.Warn("loading tracked files", "error", err)
+		return
+	}
+
+	for _, f := range files {
+		t.Track(f.ID, f.Path, tracking.LastWrite(f), f.ETag)
+	}
+
+	if err := t.Save(store.File(trackingFile), aes, password); err != nil {
+		logger.Warn("saving tracked files", "error", err)
+	}
+}
+
+// lockedFiles checks if any file in the listing is locked.
+func lockedFiles(listing *api.DirListing) bool {
+	for _, f := range listing.Files {
+		if f.Lock != nil && !f.Lock.Expired() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveConflicts checks the uploads that would overwrite a file in the listing. An
+// upload that conflicts with the file on the server is only kept if the user
+// confirms it, or force is set. An upload of a file that is locked by a user other
+// than self is always refused. It returns the uploads to write and the local paths
+// of the uploads that were refused.
+//
+// Every upload that overwrites a file is sent with the ETag of the file in the
+// listing, so the server rejects it if the file changes before it is written.
+func resolveConflicts(t *tracking.Tracker, listing *api.DirListing, uploads []api.FileUpload, force bool, self string) ([]api.FileUpload, []string) {
+	remote := map[string]api.File{}
+	for _, f := range listing.Files {
+		remote[f.Name] = f
+	}
+
+	keep := []api.FileUpload{}
+	refused := []string{}
+	for _, u := range uploads {
+		f, ok := remote[u.Filename]
+		if !ok {
+			keep = append(keep, u)
+			continue
+		}
+		u.IfMatch = f.ETag
+
+		if f.Lock != nil && !f.Lock.Expired() && !f.Lock.HeldBy(self) {
+			fmt.Fprintf(stdout, "Locked: %s\n", f.Path)
+			fmt.Fprintf(stdout, "-> [REASON] locked by %s until %s\n", f.Lock.Username,
+				f.Lock.ExpiresAt.Local().Format(time.RFC1123))
+			refused = append(refused, u.Path)
+			continue
+		}
+
+		conflict, ok := t.Check(f)
+		if !ok || force {
+			keep = append(keep, u)
+			continue
+		}
+
+		fmt.Fprintf(stdout, "Conflict: %s\n", f.Path)
+		fmt.Fprintf(stdout, "-> [REASON] %s\n", conflict.Reason())
+		if prompt.Confirm("Overwrite the file on the server?") {
+			keep = append(keep, u)
+			continue
+		}
+		refused = append(refused, u.Path)
+	}
+
+	return keep, refused
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// The 'cp' command.
+//
+// CopyCommand copies a file or directory on the server to another directory. The
+// contents never leave the server, nothing is downloaded or uploaded.
+type CopyCommand struct {
+	cmd       *cobra.Command
+	user      *config.User
+	password  *secret.Bytes
+	client    *api.Client
+	store     *config.Store
+	aes       *crypto.AES
+	logger    *logging.Logger
+	reauth    *Reauthenticator
+	id        string
+	destID    string
+	rename    string
+	recursive bool
+}
+
+// NewCopyCommand creates and returns a CopyCommand.
+//
+// The id flag (-i, --id) is set for the CopyCommand. This flag allows users to copy
+// a file or directory by its ID instead of its path.
+//
+// The destination id flag (--dest-id) is set for the CopyCommand. This flag allows
+// users to set the destination directory by its ID instead of its path.
+//
+// The rename flag (--rename) is set for the CopyCommand. This flag sets the name of
+// the copy.
+//
+// The recursive flag (-r, --recursive) is set for the CopyCommand. This flag allows
+// directories to be copied, with everything below them.
+func NewCopyCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger, reauth *Reauthenticator) *CopyCommand {
+	copyCmd := &CopyCommand{store: store, aes: aes, logger: logger, reauth: reauth}
+
+	copyCmd.cmd = &cobra.Command{
+		Use:   "cp [<source>] [<destination>]",
+		Short: "Copy a file or directory to another directory on the server",
+		Args:  cobra.MaximumNArgs(2),
+		RunE:  copyCmd.Run,
+	}
+
+	copyCmd.cmd.Flags().StringVarP(&copyCmd.id, "id", "i", "", "The ID of the file or directory to copy")
+	copyCmd.cmd.Flags().StringVar(&copyCmd.destID, "dest-id", "", "The ID of the directory to copy to")
+	copyCmd.cmd.Flags().StringVar(&copyCmd.rename, "rename", "", "The name of the copy")
+	copyCmd.cmd.Flags().BoolVarP(&copyCmd.recursive, "recursive", "r", false, "Copy directories and everything below them")
+
+	return copyCmd
+}
+
+// Command returns the cobra.Command of this CopyCommand.
+func (c *CopyCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *CopyCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *CopyCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *CopyCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this CopyCommand.
+//
+// Run copies the source into the destination directory. The source is a file if the
+// server has a file at it, otherwise it is a directory. A directory is only copied
+// if the recursive flag (-r, --recursive) is set. If the rename flag (--rename) is
+// set, the copy is given the new name.
+//
+// The copies are added to the local index, if it is built.
+func (c *CopyCommand) Run(cmd *cobra.Command, args []string) error {
+	src, dest, err := sourceAndDest(args, c.id, c.destID)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return reported(err)
+	}
+
+	if c.rename != "" {
+		if err := validateName(c.rename); err != nil {
+			fmt.Fprintln(stderr, "Invalid rename (--rename):", err)
+			return errUsage
+		}
+	}
+
+	file, listing, err := resolveTarget(cmd.Context(), c.client, src)
+	if err != nil {
+		c.printError(cmd, err, src, dest)
+		return reported(err)
+	}
+
+	params := api.CopyParams{Parent: dest, Name: c.rename}
+
+	if file != nil {
+		copied, err := c.client.Files().Copy(cmd.Context(), api.ID(file.ID), params)
+		if err != nil {
+			c.printError(cmd, err, src, dest)
+			return reported(err)
+		}
+
+		updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+			idx.Add(index.FileEntry(*copied))
+		})
+
+		c.logger.Printf("Copied: %s -> %s\n", file.Path, copied.Path)
+		c.logger.Printf("-> ID: %s\n", copied.ID)
+		return nil
+	}
+
+	if !c.recursive {
+		fmt.Fprintf(stderr, "Error: '%s' is a directory, use --recursive (-r) to copy it\n", listing.Dir.DirPath)
+		return reported(nil)
+	}
+
+	copied, err := c.client.Dirs().Copy(cmd.Context(), api.ID(listing.Dir.ID), params)
+	if err != nil {
+		c.printError(cmd, err, src, dest)
+		return reported(err)
+	}
+
+	c.indexTree(cmd.Context(), c.client, copied.Dir)
+
+	c.logger.Printf("Copied: %s -> %s\n", listing.Dir.DirPath, copied.Dir.DirPath)
+	c.logger.Printf("-> ID: %s\n", copied.Dir.ID)
+	c.logger.Printf("-> Directories: %d\n", copied.Dirs)
+	c.logger.Printf("-> Files: %d\n", copied.Files)
+
+	return nil
+}
+
+// indexTree adds the copied directory, and everything below it, to the local index.
+// The copies are only listed if the index is built. A failed listing is logged and
+// never fails the command, the index can always be rebuilt.
+func (c *CopyCommand) indexTree(ctx context.Context, client *api.Client, dir api.Dir) {
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		idx.Add(index.DirEntry(dir))
+		err := client.Dirs().Walk(ctx, api.ID(dir.ID), func(listing *api.DirListing, depth int) error {
+			for _, d := range listing.Dirs {
+				idx.Add(index.DirEntry(d))
+			}
+			for _, f := range listing.Files {
+				idx.Add(index.FileEntry(f))
+			}
+			return nil
+		})
+		if err != nil {
+			c.logger.Debug("listing copied directory", "error", err)
+		}
+	})
+}
+
+// printError prints the error of a request made by this CopyCommand. If the API
+// token was rejected, the user is offered to enter a new one instead.
+func (c *CopyCommand) printError(cmd *cobra.Command, err error, src api.Location, dest api.Location) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] Source: %s\n", src)
+		fmt.Fprintf(stderr, "-> [ARGS] Destination: %s\n", dest)
+		if c.rename != "" {
+			fmt.Fprintf(stderr, "-> [FLAG] Rename: %s\n", c.rename)
+		}
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Copy failed:", err)
+	}
+}
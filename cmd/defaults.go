@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// exclusiveFlags are the flags that cannot be set together with the flag they are
+// keyed by, such as the path (-p, --path) and id (-i, --id) flags. The default of a
+// flag is not used if the flag it excludes is set on the command line.
+var exclusiveFlags = map[string]string{
+	"path": "id",
+	"id":   "path",
+}
+
+// applyDefaults sets the flags of the command that are not set on the command line
+// to the defaults of the command in the settings, see config.Defaults. A flag that
+// is set to a default is not marked as changed, the same as if the default was the
+// default of the flag.
+//
+// If the settings file cannot be read, it is logged and no default is used. It
+// returns an error if a default is not a flag of the command, or its value is
+// invalid.
+func (c *RootCommand) applyDefaults(cmd *cobra.Command) error {
+	settings, err := c.store.ReadSettings()
+	if err != nil {
+		c.logger.Warn("reading settings file", "error", err)
+		return nil
+	}
+
+	name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	defaults := settings.Defaults[name]
+	flags := make([]string, 0, len(defaults))
+	for flag := range defaults {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+
+	for _, flag := range flags {
+		f := cmd.Flags().Lookup(flag)
+		if f == nil {
+			return fmt.Errorf("'%s' has no flag --%s", name, flag)
+		}
+		if f.Changed || cmd.Flags().Changed(exclusiveFlags[flag]) {
+			continue
+		}
+
+		values, err := defaultValues(defaults[flag])
+		if err != nil {
+			return fmt.Errorf("--%s of '%s': %w", flag, name, err)
+		}
+		for _, v := range values {
+			if err := f.Value.Set(v); err != nil {
+				return fmt.Errorf("--%s of '%s': %w", flag, name, err)
+			}
+		}
+		c.logger.Debug("using default flag", "command", name, "flag", flag, "value", f.Value.String())
+	}
+
+	return nil
+}
+
+// defaultValues returns the values a default sets its flag to, one for each time
+// the flag is given. A list sets the flag once for every value in it.
+func defaultValues(v any) ([]string, error) {
+	switch v := v.(type) {
+	case string, bool, float64:
+		return []string{fmt.Sprint(v)}, nil
+	case []any:
+		values := []string{}
+		for _, e := range v {
+			value, err := defaultValues(e)
+			if err != nil || len(value) != 1 {
+				return nil, errors.New("a list can only hold strings, numbers, and booleans")
+			}
+			values = append(values, value...)
+		}
+		return values, nil
+	default:
+		return nil, errors.New("must be a string, number, boolean, or a list of them")
+	}
+}
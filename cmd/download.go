@@ -0,0 +1,689 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/age"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/hooks"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/partial"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// errRangeNotSatisfiable is the error when a byte range starts past the end of a
+// file.
+var errRangeNotSatisfiable = errors.New("range starts past the end of the file")
+
+// parseByteRange parses a byte range in the format start-end or start-. Both start
+// and end are inclusive plain text offsets.
+func parseByteRange(s string) (api.ByteRange, error) {
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok || startStr == "" {
+		return api.ByteRange{}, fmt.Errorf("'%s' must be in format <start>-<end> or <start>-", s)
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return api.ByteRange{}, fmt.Errorf("invalid start '%s'", startStr)
+	}
+
+	if endStr == "" {
+		return api.ByteRange{Start: start, End: -1}, nil
+	}
+
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil || end < start {
+		return api.ByteRange{}, fmt.Errorf("invalid end '%s'", endStr)
+	}
+
+	return api.ByteRange{Start: start, End: end}, nil
+}
+
+// decryptFile decrypts the contents of a file downloaded from the server with the
+// key. Files that were appended to are chunked, every other file is encrypted as a
+// whole.
+func decryptFile(aes *crypto.AES, data []byte, key []byte) ([]byte, error) {
+	data, key, err := aes.OpenEnvelope(data, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if crypto.IsChunked(data) {
+		return aes.DecryptChunks(data, key)
+	}
+
+	return aes.Decrypt(data, key)
+}
+
+// decryptStream decrypts the contents of a file read from r with the key, the same
+// as decryptFile, and writes them to w. A chunked file is decrypted and written one
+// chunk at a time as it is read, so it is never held in memory. Any other file is
+// read whole before it is decrypted. If a chunk fails to decrypt, the chunks before
+// it were already written to w.
+func decryptStream(aes *crypto.AES, r io.Reader, key []byte, w io.Writer) error {
+	br := bufio.NewReaderSize(r, crypto.ChunkSize)
+	header, err := br.Peek(crypto.MaxEnvelopeHeaderSize + crypto.MaxChunkedHeaderSize)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if crypto.IsEnvelope(header) {
+		dk, err := aes.OpenDataKey(header, key)
+		if err != nil {
+			return fmt.Errorf("decrypting file: %w", err)
+		}
+		defer dk.Key.Wipe()
+		header, key = header[len(dk.Header):], dk.Key
+		if _, err := br.Discard(len(dk.Header)); err != nil {
+			return err
+		}
+	}
+
+	if !crypto.IsChunked(header) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return err
+		}
+		data, err = aes.Decrypt(data, key)
+		if err != nil {
+			return fmt.Errorf("decrypting file: %w", err)
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	dr, err := aes.NewDecryptReader(br, key)
+	if err != nil {
+		return fmt.Errorf("decrypting file: %w", err)
+	}
+	if _, err := io.Copy(w, dr); err != nil {
+		return fmt.Errorf("decrypting file: %w", err)
+	}
+
+	return nil
+}
+
+// verifyChecksum checks the decrypted contents of the file against the checksum the
+// file was uploaded with, see crypto.AES.VerifyChecksum. A file without a checksum
+// is not verified. If the contents do not match, the error wraps
+// crypto.ErrChecksumMismatch.
+func verifyChecksum(aes *crypto.AES, file api.File, data []byte, key []byte) error {
+	if file.Checksum == "" {
+		return nil
+	}
+
+	digest := sha256.Sum256(data)
+	return verifyDigest(aes, file, digest[:], key)
+}
+
+// verifyDigest checks the SHA-256 digest of the decrypted contents of the file, see
+// verifyChecksum.
+func verifyDigest(aes *crypto.AES, file api.File, digest []byte, key []byte) error {
+	if file.Checksum == "" {
+		return nil
+	}
+
+	if err := aes.VerifyChecksum(file.Checksum, digest, key); err != nil {
+		return fmt.Errorf("verifying checksum: %w", err)
+	}
+
+	return nil
+}
+
+// errUnsafeName is the error when the name of a remote file cannot be written below
+// the local directory it is downloaded to.
+var errUnsafeName = errors.New("name is not a safe local path")
+
+// downloadFile downloads the whole file and decrypts it with the key, and writes it
+// to output. Any parent directory of output that is missing is created. The contents
+// are verified with the checksum of the file before they are written, see
+// verifyChecksum.
+func downloadFile(ctx context.Context, files *api.FileService, aes *crypto.AES, file api.File, output string, key []byte) error {
+	var buf bytes.Buffer
+	if _, err := files.Download(ctx, api.ID(file.ID), &buf); err != nil {
+		return err
+	}
+
+	data, err := decryptFile(aes, buf.Bytes(), key)
+	if err != nil {
+		return fmt.Errorf("decrypting file: %w", err)
+	}
+	if err := verifyChecksum(aes, file, data, key); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(output, data, 0644)
+}
+
+// The 'download' command.
+//
+// DownloadCommand downloads and decrypts a file from the Clox server.
+type DownloadCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	hooks    *hooks.Runner
+	reauth   *Reauthenticator
+	output   string
+	rng      string
+	zip      bool
+	resume   bool
+	age      bool
+	ageTo    []string
+	transfer transferFlags
+	progress progressFlag
+	// events are the transfer events of the progress flag, they are nil if the
+	// progress is not printed.
+	events api.EventFunc
+}
+
+// NewDownloadCommand creates and returns a DownloadCommand.
+//
+// The output flag (-o, --output) is set for the DownloadCommand. This flag sets the
+// local path the file is written to. A '-' writes the file to standard output.
+//
+// The range flag (--range) is set for the DownloadCommand. This flag downloads only
+// the bytes from start to end of the file, in the format <start>-<end> or <start>-.
+//
+// The zip flag (--zip) is set for the DownloadCommand. This flag downloads a
+// directory and everything below it as a single zip archive.
+//
+// The resume flag (--resume) is set for the DownloadCommand. This flag only
+// continues an interrupted download, it fails if the download cannot be resumed.
+//
+// The parallel (--parallel) and fail fast (--fail-fast) flags are set for the
+// DownloadCommand. When more than one ID is given, these flags set how many files
+// are downloaded at the same time, and stop every download at the first file that
+// fails.
+//
+// The age flag (--age) is set for the DownloadCommand. This flag writes the file in
+// the age format, encrypted for the age identity of the profile, so it can be
+// decrypted with the age tool. The recipient flag (--recipient) is set for the
+// DownloadCommand. This flag is an age recipient to encrypt the file for as well, it
+// can be set more than once and implies the age flag.
+//
+// The progress flag (--progress) is set for the DownloadCommand. This flag prints
+// the progress of every file that is downloaded to standard error, in the JSON format
+// it is a line of JSON for every event, see jsonEvents.
+func NewDownloadCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, hooks *hooks.Runner, reauth *Reauthenticator) *DownloadCommand {
+	downloadCmd := &DownloadCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger, hooks: hooks, reauth: reauth}
+
+	downloadCmd.cmd = &cobra.Command{
+		Use:   "download <id> [<id>...] | --zip <dir-path|id>",
+		Short: "Download files from the server",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  downloadCmd.Run,
+	}
+
+	downloadCmd.cmd.Flags().StringVarP(&downloadCmd.output, "output", "o", "", "The path to write the file, '-' for standard output")
+	downloadCmd.cmd.Flags().StringVar(&downloadCmd.rng, "range", "", "Download only the bytes <start>-<end> of the file")
+	downloadCmd.cmd.Flags().BoolVar(&downloadCmd.zip, "zip", false, "Download a directory as a zip archive")
+	downloadCmd.cmd.Flags().BoolVar(&downloadCmd.resume, "resume", false, "Continue an interrupted download, fail if it cannot be resumed")
+	downloadCmd.cmd.Flags().BoolVar(&downloadCmd.age, "age", false, "Write the file encrypted in the age format")
+	downloadCmd.cmd.Flags().StringSliceVar(&downloadCmd.ageTo, "recipient", nil, "An age recipient to encrypt the file for")
+	downloadCmd.transfer.register(downloadCmd.cmd)
+	downloadCmd.progress.register(downloadCmd.cmd)
+
+	return downloadCmd
+}
+
+// Command returns the cobra.Command of this DownloadCommand.
+func (c *DownloadCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *DownloadCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *DownloadCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *DownloadCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this DownloadCommand.
+//
+// Run will download the file with the ID, decrypt it, and write it to the output
+// flag (-o, --output). If the output flag is not set, the file is written to the
+// current directory with the name it has on the server.
+//
+// A whole file written to the output is downloaded to a partial download first, with
+// the extension '.clox-partial'. If the download is interrupted, running it again
+// verifies what was written and resumes where it stopped. See downloadResumable.
+// If the resume flag (--resume) is set, the download fails instead of starting
+// over when there is no partial download, or the file changed on the server since.
+//
+// If the range flag (--range) is set, only the bytes in the range are written. The
+// range is of the decrypted file, only the encrypted chunks that cover it are
+// downloaded. Files that are not chunked, or were appended to, cannot be fetched in
+// part and are downloaded whole before the range is taken. If the output flag is
+// not set, the range is written to standard output.
+//
+// If the output is '-', the whole file is written to standard output as it is
+// downloaded. See downloadStream.
+//
+// A whole file is verified with the checksum it was uploaded with after it is
+// decrypted, see verifyChecksum. If it does not match, the program exits with
+// exitChecksumMismatch, and the file is not written unless the output is '-'. A
+// range is not verified.
+//
+// If the age flag (--age) is set, the decrypted file is encrypted again in the age
+// format before it is written, for the age identity of the profile and the
+// recipients of the recipient flag (--recipient). If the output flag is not set, the
+// name of the file has the extension '.age'. The identity of the profile is exported
+// with 'clox keys export --format age'. A file in the age format is not a local copy
+// of the file, it is not tracked. The age flag cannot be used with the range or zip
+// flags.
+//
+// If the zip flag (--zip) is set, the argument is a directory instead. See runZip.
+// If more than one ID is given, see runMany.
+//
+// The pre-download hook is run with the ID before anything is downloaded, if it
+// fails the download is aborted. The post-download hook is run with the output path
+// and the file metadata after the file is written.
+//
+// If the output file already exists, overwriting it is confirmed first, see
+// prompt.ConfirmOverwrite.
+func (c *DownloadCommand) Run(cmd *cobra.Command, args []string) error {
+	id := args[0]
+
+	events, err := c.progress.events()
+	if err != nil {
+		return err
+	}
+	c.events = events
+
+	if len(args) > 1 {
+		return c.runMany(cmd, args)
+	}
+
+	if c.resume && (c.rng != "" || c.zip || c.output == "-") {
+		fmt.Fprintln(stderr, "The resume flag (--resume) cannot be used with range (--range), zip (--zip), or output '-'")
+		return errUsage
+	}
+
+	c.age = c.age || len(c.ageTo) > 0
+	if c.age && (c.rng != "" || c.zip) {
+		fmt.Fprintln(stderr, "The age flag (--age) cannot be used with range (--range) or zip (--zip)")
+		return errUsage
+	}
+
+	if c.zip {
+		if c.rng != "" {
+			fmt.Fprintln(stderr, "Only one flag can be set: range (--range) or zip (--zip)")
+			return errUsage
+		}
+		return c.runZip(cmd, args)
+	}
+
+	var rng *api.ByteRange
+	if c.rng != "" {
+		r, err := parseByteRange(c.rng)
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid range (--range):", err)
+			return errUsage
+		}
+		rng = &r
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	var recipients []*age.Recipient
+	if c.age {
+		recipients, err = ageRecipients(encryptKey, c.ageTo)
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid recipient (--recipient):", err)
+			return errUsage
+		}
+	}
+
+	if err := c.hooks.Run(hooks.PreDownload, []string{id}, nil); err != nil {
+		fmt.Fprintln(stderr, "Download aborted:", err)
+		return reported(err)
+	}
+
+	file, err := c.client.Files().Get(cmd.Context(), api.ID(id))
+	if err != nil {
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	output := c.output
+	if output == "" && rng != nil {
+		output = "-"
+	}
+	if output == "" {
+		output = file.Name
+		if c.age {
+			output += ageExt
+		}
+	}
+
+	if c.resume {
+		if err := partial.CanResume(output, *file); err != nil {
+			fmt.Fprintf(stderr, "Cannot resume (--resume): %s\n", err)
+			fmt.Fprintf(stderr, "-> [ARGS] ID: %s\n", id)
+			fmt.Fprintf(stderr, "-> [FLAG] Output: %s\n", output)
+			fmt.Fprintln(stderr, "-> [HINT] Download without --resume to start over")
+			return reported(err)
+		}
+	}
+	if output != "-" && !prompt.ConfirmOverwrite(output) {
+		return reported(nil)
+	}
+
+	var data []byte
+	switch {
+	case rng != nil:
+		data, err = c.downloadRange(cmd.Context(), c.client.Files(), api.ID(id), *rng, encryptKey)
+	case output == "-" && c.age:
+		err = c.downloadAge(cmd.Context(), c.client.Files(), *file, encryptKey, recipients, os.Stdout)
+	case output == "-":
+		err = c.downloadStream(cmd.Context(), c.client.Files(), *file, encryptKey, os.Stdout)
+	default:
+		data, err = c.downloadResumable(cmd.Context(), c.client.Files(), file, output, encryptKey)
+	}
+	if err != nil {
+		c.printError(cmd, err, args)
+		if errors.Is(err, crypto.ErrChecksumMismatch) {
+			return exitWith(exitChecksumMismatch)
+		}
+		return reported(err)
+	}
+
+	if rng != nil && output == "-" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			c.logger.Error("writing file", "error", err)
+			return reported(err)
+		}
+	} else if output != "-" && c.age {
+		if err := writeAgeFile(output, data, recipients); err != nil {
+			c.logger.Error("writing file", "path", output, "error", err)
+			return reported(err)
+		}
+		c.logger.Printf("Downloaded: %s -> %s\n", file.Path, output)
+
+		if err := partial.Remove(output); err != nil {
+			c.logger.Warn("removing partial download", "path", output+partial.Ext, "error", err)
+		}
+	} else if output != "-" {
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			c.logger.Error("writing file", "path", output, "error", err)
+			return reported(err)
+		}
+		c.logger.Printf("Downloaded: %s -> %s\n", file.Path, output)
+
+		if err := partial.Remove(output); err != nil {
+			c.logger.Warn("removing partial download", "path", output+partial.Ext, "error", err)
+		}
+	}
+
+	// Only a whole file is a local copy that can be compared with the server.
+	if rng == nil && output != "-" && !c.age {
+		trackFiles(c.store, c.aes, *c.password, c.logger, *file)
+	}
+
+	if err := c.hooks.Run(hooks.PostDownload, []string{output}, file); err != nil {
+		c.logger.Warn("running post-download hook", "error", err)
+	}
+
+	return nil
+}
+
+// download downloads the whole file and decrypts it with the key.
+func (c *DownloadCommand) download(ctx context.Context, files *api.FileService, file api.Location, key []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := files.Download(ctx, file, &buf); err != nil {
+		return nil, err
+	}
+
+	data, err := decryptFile(c.aes, buf.Bytes(), key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting file: %w", err)
+	}
+
+	return data, nil
+}
+
+// downloadStream downloads the whole file, decrypts it with the key, and writes it
+// to w. A chunked file is decrypted and written one chunk at a time as it is
+// downloaded, so it is never held in memory. Any other file is downloaded whole
+// before it is decrypted.
+//
+// If a chunk fails to decrypt, the chunks before it were already written to w. The
+// contents are verified with the checksum of the file after they are all written,
+// see verifyChecksum.
+func (c *DownloadCommand) downloadStream(ctx context.Context, files *api.FileService, file api.File, key []byte, w io.Writer) error {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	progress := newProgressWriter(pw, c.events, "-", file, 0)
+	go func() {
+		_, err := files.Download(ctx, api.ID(file.ID), progress)
+		pw.CloseWithError(err)
+	}()
+
+	h := sha256.New()
+	err := decryptStream(c.aes, pr, key, io.MultiWriter(w, h))
+	if err == nil {
+		err = verifyDigest(c.aes, file, h.Sum(nil), key)
+	}
+	progress.finish(err)
+
+	return err
+}
+
+// downloadAge downloads the whole file, decrypts it with the key, and writes it to w
+// encrypted for the recipients in the age format, as it is downloaded. See
+// downloadStream.
+func (c *DownloadCommand) downloadAge(ctx context.Context, files *api.FileService, file api.File, key []byte, recipients []*age.Recipient, w io.Writer) error {
+	aw, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return err
+	}
+	if err := c.downloadStream(ctx, files, file, key, aw); err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
+
+// downloadResumable downloads the whole file and decrypts it with the key. The file
+// is written to a partial download next to the output as it is downloaded. If the
+// download is interrupted, the next download of the file to the same output resumes
+// after the part that was written, as long as the file did not change on the server.
+//
+// When the download is complete, the size of the partial download is compared with
+// the size of the file on the server before it is decrypted, decrypting it also
+// authenticates every byte. The decrypted contents are verified with the checksum
+// of the file, see verifyChecksum. The partial download is kept if the download
+// fails, and removed if it is not intact.
+func (c *DownloadCommand) downloadResumable(ctx context.Context, files *api.FileService, file *api.File, output string, key []byte) (data []byte, err error) {
+	p, err := partial.Open(output, *file)
+	if err != nil {
+		return nil, fmt.Errorf("opening partial download: %w", err)
+	}
+	defer p.Close()
+
+	offset := p.Offset()
+	progress := newProgressWriter(p, c.events, output, *file, offset)
+	defer func() { progress.finish(err) }()
+	switch {
+	case offset >= file.Size:
+	case offset > 0:
+		c.logger.Info("resuming download", "path", p.Path(), "offset", offset)
+		_, err = files.DownloadRange(ctx, api.ID(file.ID), api.ByteRange{Start: offset, End: -1}, progress)
+	default:
+		_, err = files.Download(ctx, api.ID(file.ID), progress)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := p.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading partial download: %w", err)
+	}
+	if int64(len(encrypted)) != file.Size {
+		if err := partial.Remove(output); err != nil {
+			c.logger.Warn("removing partial download", "path", p.Path(), "error", err)
+		}
+		return nil, fmt.Errorf("verifying download: %d bytes written, the file has %d", len(encrypted), file.Size)
+	}
+
+	data, err = decryptFile(c.aes, encrypted, key)
+	if err != nil {
+		if err := partial.Remove(output); err != nil {
+			c.logger.Warn("removing partial download", "path", p.Path(), "error", err)
+		}
+		return nil, fmt.Errorf("decrypting file: %w", err)
+	}
+	if err := verifyChecksum(c.aes, *file, data, key); err != nil {
+		if err := partial.Remove(output); err != nil {
+			c.logger.Warn("removing partial download", "path", p.Path(), "error", err)
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// downloadRange downloads the encrypted chunks of the file that cover the range and
+// decrypts them with the key. If the file is not chunked, or the chunks are not
+// where they are expected, the whole file is downloaded instead.
+func (c *DownloadCommand) downloadRange(ctx context.Context, files *api.FileService, file api.Location, rng api.ByteRange, key []byte) ([]byte, error) {
+	var header bytes.Buffer
+	headerSize := int64(crypto.MaxEnvelopeHeaderSize + crypto.MaxChunkedHeaderSize)
+	if _, err := files.DownloadRange(ctx, file, api.ByteRange{Start: 0, End: headerSize - 1}, &header); err != nil {
+		return nil, err
+	}
+
+	// The chunks of a file with a data key start after its envelope header, and are
+	// encrypted with the data key.
+	chunked, chunkKey, skip := header.Bytes(), key, int64(0)
+	if crypto.IsEnvelope(chunked) {
+		dk, err := c.aes.OpenDataKey(chunked, key)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting file: %w", err)
+		}
+		chunked, chunkKey, skip = chunked[len(dk.Header):], dk.Key, int64(len(dk.Header))
+	}
+
+	var data []byte
+	if !crypto.IsChunked(chunked) {
+		c.logger.Warn("file is not chunked, downloading the whole file")
+	} else {
+		// A range is decrypted with the cipher of the file, which may not be the
+		// cipher of the profile.
+		fileAES, err := c.aes.ForChunked(chunked)
+		if err != nil {
+			return nil, fmt.Errorf("reading chunked header: %w", err)
+		}
+
+		ctStart, ctEnd, ptStart := fileAES.ChunkRange(rng.Start, rng.End)
+
+		var buf bytes.Buffer
+		fetch := api.ByteRange{Start: skip + ctStart, End: ctEnd}
+		if ctEnd >= 0 {
+			fetch.End = skip + ctEnd
+		}
+		_, err = files.DownloadRange(ctx, file, fetch, &buf)
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == 416 {
+			return nil, errRangeNotSatisfiable
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data, err = fileAES.DecryptChunksAt(buf.Bytes(), chunkKey, ctStart)
+		if err != nil {
+			c.logger.Warn("file was appended to, downloading the whole file", "error", err)
+		} else {
+			return sliceRange(data, rng, ptStart)
+		}
+	}
+
+	data, err := c.download(ctx, files, file, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return sliceRange(data, rng, 0)
+}
+
+// sliceRange returns the bytes of the range from data. The data starts at offset
+// base of the file.
+func sliceRange(data []byte, rng api.ByteRange, base int64) ([]byte, error) {
+	start := rng.Start - base
+	if start >= int64(len(data)) {
+		return nil, errRangeNotSatisfiable
+	}
+
+	end := int64(len(data))
+	if rng.End >= 0 {
+		end = min(rng.End-base+1, end)
+	}
+
+	return data[start:end], nil
+}
+
+// printError prints the error of a request made by this DownloadCommand. If the API
+// token was rejected, the user is offered to enter a new one instead.
+func (c *DownloadCommand) printError(cmd *cobra.Command, err error, args []string) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		if c.zip {
+			fmt.Fprintf(stderr, "-> [ARGS] Directory: %s\n", args[0])
+		} else {
+			fmt.Fprintf(stderr, "-> [ARGS] ID: %s\n", args[0])
+		}
+		if c.rng != "" {
+			fmt.Fprintf(stderr, "-> [FLAG] Range: %s\n", c.rng)
+		}
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Download failed:", err)
+		if errors.Is(err, crypto.ErrChecksumMismatch) {
+			fmt.Fprintf(stderr, "-> [ARGS] ID: %s\n", args[0])
+			fmt.Fprintln(stderr, "-> [HINT] The file was changed or corrupted after it was uploaded, run 'clox verify' to check the other files")
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// encryptedExt is the extension of a file encrypted with 'clox encrypt'.
+const encryptedExt = ".clox"
+
+// openInput opens the file at path to read, '-' is standard input.
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return os.Open(path)
+}
+
+// writeOutput calls write with the file at path, '-' is standard output. The file is
+// written to a temporary file next to it, and renamed to path only if write
+// succeeds, so a file that fails to encrypt or decrypt is never left partially
+// written. An existing file is replaced. Only the user can read or write the file.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// The 'encrypt' command.
+//
+// EncryptCommand encrypts a local file with the encryption key of the active
+// profile, the same as a file that is uploaded. Nothing is sent to the server.
+type EncryptCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	output   string
+}
+
+// NewEncryptCommand creates and returns an EncryptCommand.
+//
+// The output flag (-o, --output) is set for the EncryptCommand. This flag sets the
+// path the encrypted file is written to. A '-' writes it to standard output. If it
+// is not set, the file is written next to the file with the '.clox' extension added.
+func NewEncryptCommand(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger) *EncryptCommand {
+	encryptCmd := &EncryptCommand{keys: keys, aes: aes, rsa: rsa, logger: logger}
+
+	encryptCmd.cmd = &cobra.Command{
+		Use:   "encrypt <file>",
+		Short: "Encrypt a local file with the encryption key",
+		Args:  cobra.ExactArgs(1),
+		RunE:  encryptCmd.Run,
+	}
+
+	encryptCmd.cmd.Flags().StringVarP(&encryptCmd.output, "output", "o", "", "The path to write the encrypted file, '-' for standard output")
+
+	return encryptCmd
+}
+
+// Command returns the cobra.Command of this EncryptCommand.
+func (c *EncryptCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *EncryptCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *EncryptCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this EncryptCommand.
+//
+// Run encrypts the file with a new data key, wrapped with the encryption key of the
+// active profile, in chunks with the cipher of the profile. The file is read and
+// encrypted one chunk at a time, so it is never held in memory. The encrypted file
+// is in the same format as a file on the server, it can be uploaded as is or
+// decrypted with 'clox decrypt'. A '-' reads the file from standard input.
+func (c *EncryptCommand) Run(cmd *cobra.Command, args []string) error {
+	input := args[0]
+	output := c.output
+	if output == "" {
+		if input == "-" {
+			output = "-"
+		} else {
+			output = input + encryptedExt
+		}
+	}
+	if output != "-" && sameFile(input, output) {
+		fmt.Fprintln(stderr, "Error: the output is the file being encrypted")
+		fmt.Fprintf(stderr, "-> [FLAG] Output: %s\n", output)
+		return errUsage
+	}
+	if output != "-" && !prompt.ConfirmOverwrite(output) {
+		return reported(nil)
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	in, err := openInput(input)
+	if err != nil {
+		c.logger.Error("opening file", "path", input, "error", err)
+		return reported(err)
+	}
+	defer in.Close()
+
+	chunked := &crypto.ChunkedAES{AES: c.aes}
+	err = writeOutput(output, func(w io.Writer) error {
+		_, err := chunked.EncryptStream(w, in, encryptKey)
+		return err
+	})
+	if err != nil {
+		c.logger.Error("encrypting file", "path", input, "error", err)
+		return reported(err)
+	}
+
+	if output != "-" {
+		c.logger.Printf("Encrypted: %s -> %s\n", input, output)
+	}
+
+	return nil
+}
+
+// The 'decrypt' command.
+//
+// DecryptCommand decrypts a local file that was encrypted with the encryption key of
+// the active profile, such as a file encrypted with 'clox encrypt', or the contents
+// of a file on the server that were copied by other means. Nothing is sent to the
+// server.
+type DecryptCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	output   string
+}
+
+// NewDecryptCommand creates and returns a DecryptCommand.
+//
+// The output flag (-o, --output) is set for the DecryptCommand. This flag sets the
+// path the decrypted file is written to. A '-' writes it to standard output. If it
+// is not set, the file is written next to the file without its '.clox' extension,
+// a file without the extension requires the flag.
+func NewDecryptCommand(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger) *DecryptCommand {
+	decryptCmd := &DecryptCommand{keys: keys, aes: aes, rsa: rsa, logger: logger}
+
+	decryptCmd.cmd = &cobra.Command{
+		Use:   "decrypt <file>",
+		Short: "Decrypt a local file with the encryption key",
+		Args:  cobra.ExactArgs(1),
+		RunE:  decryptCmd.Run,
+	}
+
+	decryptCmd.cmd.Flags().StringVarP(&decryptCmd.output, "output", "o", "", "The path to write the decrypted file, '-' for standard output")
+
+	return decryptCmd
+}
+
+// Command returns the cobra.Command of this DecryptCommand.
+func (c *DecryptCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *DecryptCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *DecryptCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this DecryptCommand.
+//
+// Run decrypts the file with the encryption key of the active profile. Every format
+// that a file on the server is encrypted in is decrypted, a chunked file is
+// decrypted one chunk at a time. The decrypted file is only written if all of it is
+// authentic. A '-' reads the file from standard input, the contents written to
+// standard output before a chunk fails to decrypt cannot be taken back.
+func (c *DecryptCommand) Run(cmd *cobra.Command, args []string) error {
+	input := args[0]
+	output := c.output
+	if output == "" {
+		switch {
+		case input == "-":
+			output = "-"
+		case strings.HasSuffix(input, encryptedExt) && len(input) > len(encryptedExt):
+			output = strings.TrimSuffix(input, encryptedExt)
+		default:
+			fmt.Fprintf(stderr, "Error: the file does not have the '%s' extension, the output cannot be named after it\n", encryptedExt)
+			fmt.Fprintf(stderr, "-> [ARGS] File: %s\n", input)
+			fmt.Fprintln(stderr, "-> [HINT] Set the output with -o <path>, or '-o -' for standard output")
+			return reported(nil)
+		}
+	}
+	if output != "-" && sameFile(input, output) {
+		fmt.Fprintln(stderr, "Error: the output is the file being decrypted")
+		fmt.Fprintf(stderr, "-> [FLAG] Output: %s\n", output)
+		return errUsage
+	}
+	if output != "-" && !prompt.ConfirmOverwrite(output) {
+		return reported(nil)
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	in, err := openInput(input)
+	if err != nil {
+		c.logger.Error("opening file", "path", input, "error", err)
+		return reported(err)
+	}
+	defer in.Close()
+
+	err = writeOutput(output, func(w io.Writer) error {
+		return decryptStream(c.aes, in, encryptKey, w)
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		fmt.Fprintf(stderr, "-> [ARGS] File: %s\n", input)
+		fmt.Fprintln(stderr, "-> [HINT] The file may not be encrypted with the encryption key of this profile, or it was modified")
+		return reported(err)
+	}
+
+	if output != "-" {
+		c.logger.Printf("Decrypted: %s -> %s\n", input, output)
+	}
+
+	return nil
+}
+
+// sameFile checks if the paths a and b are the same file. A path that does not exist
+// is not the same as any file.
+func sameFile(a string, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(ai, bi)
+}
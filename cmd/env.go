@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// The environment variables that configure the Clox CLI, so it can run in automation
+// without prompting. They take precedence over prompting, the password is still
+// verified against the configuration of the profile.
+const (
+	// envAPIToken is the API token that is sent instead of the token of the profile.
+	envAPIToken = "CLOX_API_TOKEN"
+	// envPassword is the password of the profile.
+	envPassword = "CLOX_PASSWORD"
+	// envServerURL is the base URL of the Clox API, instead of the server of the
+	// profile. The server flag (--server) takes precedence over it.
+	envServerURL = "CLOX_SERVER_URL"
+)
+
+// apiToken returns the API token of the user. The token of CLOX_API_TOKEN is used if
+// it is set, otherwise the token of the user is decrypted with the password. The
+// token must be wiped after use.
+func apiToken(user *config.User, aes *crypto.AES, password []byte) (secret.Bytes, error) {
+	if token := os.Getenv(envAPIToken); token != "" {
+		return secret.FromString(token), nil
+	}
+
+	return user.APIToken(aes, password)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cicconee/clox-cli/api"
+)
+
+// printAPIErrorHint prints a hint that explains how to resolve an API error. If the
+// kind of the error is not recognized, nothing is printed.
+func printAPIErrorHint(err error) {
+	var hint string
+	switch {
+	case errors.Is(err, api.ErrQuotaExceeded):
+		hint = "Storage quota exceeded, delete files or increase the quota"
+	case errors.Is(err, api.ErrNameConflict):
+		hint = "A directory or file with that name already exists"
+	case errors.Is(err, api.ErrInvalidPath):
+		hint = "The path is invalid or a parent directory does not exist"
+	case errors.Is(err, api.ErrPreconditionFailed):
+		hint = "The file changed on the server, fetch it again before overwriting"
+	case errors.Is(err, api.ErrLocked):
+		hint = "The file is locked by another user, wait for them to run 'clox unlock'"
+	case errors.Is(err, api.ErrRateLimited):
+		hint = "The server is rate limiting requests, wait a moment and try again"
+	case errors.Is(err, api.ErrTooLarge):
+		hint = "The request is larger than the server accepts, split the file or upload it in parts"
+	case errors.Is(err, api.ErrNotFound):
+		hint = "The directory or file does not exist, check the path or ID"
+	case errors.Is(err, api.ErrConflict):
+		hint = "The directory or file changed on the server, fetch it again and retry"
+	case errors.Is(err, api.ErrUnauthorized):
+		hint = "The API token was not accepted, run 'clox token verify' to check it"
+	default:
+		return
+	}
+
+	fmt.Fprintf(stderr, "-> [HINT] %s\n", hint)
+}
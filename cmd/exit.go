@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/cicconee/clox-cli/api"
+)
+
+// The exit codes of the Clox CLI.
+const (
+	// exitFailure is the exit code when a command failed for any other reason than
+	// the ones below.
+	exitFailure = 1
+	// exitUsage is the exit code when the command line is invalid, such as an
+	// unknown flag, a missing argument, or flags that cannot be set together.
+	exitUsage = 2
+	// exitPartialFailure is the exit code when a batch operation completed, but
+	// some of the items in the batch failed.
+	exitPartialFailure = 3
+	// exitChecksumMismatch is the exit code when the contents of a downloaded file
+	// do not match the checksum it was uploaded with.
+	exitChecksumMismatch = 4
+	// exitAPIError is the exit code when the API responded with an error, such as a
+	// file that does not exist.
+	exitAPIError = 5
+	// exitAuthFailure is the exit code when the password is wrong, or the server did
+	// not accept the API token.
+	exitAuthFailure = 6
+	// exitNetwork is the exit code when the server could not be reached, or did not
+	// respond in time.
+	exitNetwork = 7
+)
+
+// reportedError is the error of a command that already printed why it failed to
+// standard error. Execute does not print it again, it only exits with the exit
+// code of the error, see exitCode.
+type reportedError struct {
+	err  error
+	code int
+}
+
+// Error returns the message of the error that was printed.
+func (e *reportedError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+
+	return e.err.Error()
+}
+
+// Unwrap returns the error that was printed, it is nil if the command only set the
+// exit code.
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// errUsage is the error of a command that printed why its command line is invalid.
+var errUsage = &reportedError{code: exitUsage}
+
+// reported returns the error of a command that printed why it failed. The exit code
+// is of the class of err, see exitCode. A nil err is a failure (exitFailure).
+func reported(err error) error {
+	return &reportedError{err: err}
+}
+
+// exitWith returns the error of a command that printed why it failed, and exits with
+// the code.
+func exitWith(code int) error {
+	return &reportedError{code: code}
+}
+
+// exitCode returns the exit code of the error of a command. The code of a
+// reportedError is used if it is set, otherwise the code is of the class of the
+// error:
+//   - An API error that rejected the API token is exitAuthFailure.
+//   - Any other API error, or a target the server has no file or directory at, is
+//     exitAPIError.
+//   - An error that never reached the server, or timed out, is exitNetwork.
+//   - Every other error is exitFailure.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var reportedErr *reportedError
+	if errors.As(err, &reportedErr) && reportedErr.code != 0 {
+		return reportedErr.code
+	}
+
+	var apiErr *api.APIError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden || errors.Is(err, api.ErrTokenRevoked) {
+			return exitAuthFailure
+		}
+		return exitAPIError
+	case errors.Is(err, errNotFound):
+		return exitAPIError
+	case errors.Is(err, api.ErrUnreachable), errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return exitNetwork
+	default:
+		return exitFailure
+	}
+}
+
+// failure is an item of a batch operation that failed, such as a file or directory.
+// The Path identifies the item.
+type failure struct {
+	Path string
+	Err  error
+}
+
+// MarshalJSON will marshal this failure into JSON, with the message of its error.
+func (f failure) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Path  string `json:"path"`
+		Error string `json:"error"`
+	}{f.Path, f.Err.Error()})
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/cicconee/clox-cli/internal/ignore"
+	"github.com/spf13/cobra"
+)
+
+// filterFlags are the include (--include) and exclude (--exclude) flags of a
+// command that transfers a batch of files. Both flags can be set more than once.
+type filterFlags struct {
+	include []string
+	exclude []string
+}
+
+// register sets the include and exclude flags for the cmd.
+func (f *filterFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&f.include, "include", nil, "Only transfer the files that match the glob pattern, can be set more than once")
+	cmd.Flags().StringArrayVar(&f.exclude, "exclude", nil, "Skip the files that match the glob pattern, can be set more than once")
+}
+
+// filter returns the ignore.Filter of the flags. If a pattern is malformed, it
+// returns an error.
+func (f *filterFlags) filter() (*ignore.Filter, error) {
+	return ignore.NewFilter(f.include, f.exclude)
+}
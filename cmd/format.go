@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// formatFlag is the format flag (--format) of a command that prints a result. The
+// result is rendered with an output.Renderer in the format, see output.New.
+type formatFlag struct {
+	format string
+}
+
+// register sets the format flag (--format) for the command, the formats are
+// completed in the shell.
+func (f *formatFlag) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.format, "format", output.Text,
+		"The format of the output: text, table, json, yaml, or a Go template such as '{{.ID}}'")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{output.Text, output.Table, output.JSON, output.YAML}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// renderer returns the output.Renderer of the format. If the format is invalid, it
+// is printed and a usage error is returned, it must be called before the command
+// does anything.
+func (f *formatFlag) renderer() (*output.Renderer, error) {
+	r, err := output.New(f.format)
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid format (--format):", err)
+		return nil, errUsage
+	}
+
+	return r, nil
+}
+
+// render writes the result v to standard output with the renderer r. A result
+// rendered as text or a table is highlighted, see highlight. A failure is logged and
+// returned.
+func render(r *output.Renderer, v any, logger *logging.Logger) error {
+	w := io.Writer(os.Stdout)
+	if r.IsText() || r.Format() == output.Table {
+		w = stdout
+	}
+	if err := r.Render(w, v); err != nil {
+		logger.Error("rendering output", "format", r.Format(), "error", err)
+		return reported(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// indexFile is the name of the encrypted index file of a profile.
+const indexFile = "index.enc"
+
+// updateIndex loads the index of the active profile, applies update, and saves it.
+// If the index has not been built, nothing is done. A failed update is logged and
+// never fails the command, the index can always be rebuilt.
+//
+// The remote directories changed, so the cached listings are removed as well.
+func updateIndex(store *config.Store, aes *crypto.AES, password []byte, logger *logging.Logger, update func(*index.Index)) {
+	clearCache(store, logger)
+
+	path := store.File(indexFile)
+	idx, err := index.Load(path, aes, password)
+	if err != nil {
+		if !errors.Is(err, index.ErrNoIndex) {
+			logger.Debug("loading index", "error", err)
+		}
+		return
+	}
+
+	update(idx)
+	if err := idx.Save(path, aes, password); err != nil {
+		logger.Debug("saving index", "error", err)
+	}
+}
+
+// The 'index' command.
+//
+// IndexCommand groups the sub commands that manage the local index. It does nothing
+// on its own.
+type IndexCommand struct {
+	cmd *cobra.Command
+}
+
+// NewIndexCommand creates and returns a IndexCommand.
+func NewIndexCommand() *IndexCommand {
+	return &IndexCommand{
+		cmd: &cobra.Command{
+			Use:   "index",
+			Short: "Manage the local index of the remote files",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this IndexCommand.
+func (c *IndexCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'index rebuild' command.
+//
+// IndexRebuildCommand lists the entire remote directory tree and writes it to the
+// encrypted local index.
+type IndexRebuildCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	aes      *crypto.AES
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+}
+
+// NewIndexRebuildCommand creates and returns a IndexRebuildCommand.
+func NewIndexRebuildCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger, reauth *Reauthenticator) *IndexRebuildCommand {
+	rebuildCmd := &IndexRebuildCommand{store: store, aes: aes, logger: logger, reauth: reauth}
+
+	rebuildCmd.cmd = &cobra.Command{
+		Use:   "rebuild",
+		Short: "Rebuild the local index from the server",
+		Args:  cobra.ExactArgs(0),
+		RunE:  rebuildCmd.Run,
+	}
+
+	return rebuildCmd
+}
+
+// Command returns the cobra.Command of this IndexRebuildCommand.
+func (c *IndexRebuildCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *IndexRebuildCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *IndexRebuildCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *IndexRebuildCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this IndexRebuildCommand.
+//
+// Run walks the remote directory tree, starting at the users root directory, and
+// replaces the local index with every directory and file found. The index is
+// encrypted with the password.
+func (c *IndexRebuildCommand) Run(cmd *cobra.Command, args []string) error {
+	idx := index.New()
+	start := time.Now()
+	if err := idx.Rebuild(cmd.Context(), c.client.Dirs(), api.Path("")); err != nil {
+		switch e := err.(type) {
+		case *api.APIError:
+			if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+				return reported(e)
+			}
+			fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+			printAPIErrorHint(e)
+		default:
+			c.logger.Error("rebuilding index", "error", err)
+		}
+		return reported(err)
+	}
+
+	if err := idx.Save(c.store.File(indexFile), c.aes, *c.password); err != nil {
+		c.logger.Error("saving index", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Index rebuilt: %d entries in %s\n", len(idx.Entries), time.Since(start).Round(time.Millisecond))
+
+	return nil
+}
+
+// The 'find' command.
+//
+// FindCommand searches the local index for directories and files by name, without
+// calling the API.
+type FindCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	aes      *crypto.AES
+	logger   *logging.Logger
+	dirs     bool
+	files    bool
+	format   formatFlag
+}
+
+// NewFindCommand creates and returns a FindCommand.
+//
+// The dirs flag (-d, --dirs) and files flag (-f, --files) are set for the
+// FindCommand. These flags limit the results to directories or files.
+//
+// The format flag (--format) is set for the FindCommand. This flag sets the format
+// the results are printed in.
+func NewFindCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger) *FindCommand {
+	findCmd := &FindCommand{store: store, aes: aes, logger: logger}
+
+	findCmd.cmd = &cobra.Command{
+		Use:   "find <pattern>",
+		Short: "Search the local index by name",
+		Args:  cobra.ExactArgs(1),
+		RunE:  findCmd.Run,
+	}
+
+	findCmd.cmd.Flags().BoolVarP(&findCmd.dirs, "dirs", "d", false, "Only show directories")
+	findCmd.cmd.Flags().BoolVarP(&findCmd.files, "files", "f", false, "Only show files")
+	findCmd.format.register(findCmd.cmd)
+
+	return findCmd
+}
+
+// Command returns the cobra.Command of this FindCommand.
+func (c *FindCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *FindCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *FindCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this FindCommand.
+//
+// Run prints every entry in the local index with a name matching the pattern. The
+// pattern is a glob if it contains a glob character, otherwise names containing the
+// pattern match. If the index is stale, a notice is printed to standard error after
+// the results.
+func (c *FindCommand) Run(cmd *cobra.Command, args []string) error {
+	r, err := c.format.renderer()
+	if err != nil {
+		return err
+	}
+
+	idx, err := index.Load(c.store.File(indexFile), c.aes, *c.password)
+	if err != nil {
+		if errors.Is(err, index.ErrNoIndex) {
+			fmt.Fprintln(stderr, "Index not built")
+			fmt.Fprintln(stderr, "Run 'clox index rebuild' to build the index")
+			return reported(nil)
+		}
+
+		c.logger.Error("loading index", "error", err)
+		return reported(err)
+	}
+
+	result := findResult{}
+	for _, e := range idx.Find(args[0]) {
+		if (c.dirs && !e.Dir) || (c.files && e.Dir) {
+			continue
+		}
+
+		kind := "file"
+		if e.Dir {
+			kind = "directory"
+		}
+		result = append(result, findEntry{Type: kind, ID: e.ID, Path: e.Path})
+	}
+	if err := render(r, result, c.logger); err != nil {
+		return err
+	}
+
+	if idx.Stale() {
+		fmt.Fprintf(stderr, "\nIndex is stale (built %s ago), run 'clox index rebuild' to refresh\n",
+			idx.Age().Round(time.Minute))
+	}
+
+	return nil
+}
+
+// findEntry is a directory or file in the local index that matched.
+type findEntry struct {
+	Type string `json:"type" table:"TYPE"`
+	ID   string `json:"id" table:"ID"`
+	Path string `json:"path" table:"PATH"`
+}
+
+// findResult is the result of the 'find' command.
+type findResult []findEntry
+
+// Text writes every entry that matched, one per line, as an 'f' for a file or a 'd'
+// for a directory, its ID, and its full path.
+func (r findResult) Text(w io.Writer) error {
+	for _, e := range r {
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", e.Type[:1], e.ID, e.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
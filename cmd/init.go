@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/cicconee/clox-cli/api"
 	"github.com/cicconee/clox-cli/internal/config"
 	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
 	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
 	"github.com/cicconee/clox-cli/internal/security"
 	"github.com/spf13/cobra"
 )
@@ -15,29 +22,69 @@ import (
 //
 // InitCommand will create the user configuration and write it to the config file.
 type InitCommand struct {
-	cmd   *cobra.Command
-	store *config.Store
-	keys  *security.Keys
-	aes   *crypto.AES
-	rsa   *crypto.RSA
-	force bool
+	cmd      *cobra.Command
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	force    bool
+	oauth    bool
+	replicas []string
+	kdf      kdfFlags
+	cipher   string
+	names    bool
+	privKey  string
+	pubKey   string
 }
 
+// oauthClientID is the OAuth 2.0 client ID of the Clox CLI.
+const oauthClientID = "clox-cli"
+
 // NewInitCommand creates and returns a InitCommand.
 //
 // A force flag '-f', is set for the InitCommand. This flag allows users to overwrite
-// their current configuration if already set.
-func NewInitCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA) *InitCommand {
-	initCmd := &InitCommand{store: store, keys: keys, aes: aes, rsa: rsa}
+// their current configuration if already set. The overwrite is confirmed first,
+// unless the yes flag (--yes) is set, as the keys of the configuration are lost.
+//
+// An oauth flag '--oauth', is set for the InitCommand. This flag obtains the API
+// token by authorizing the CLI in the browser, instead of pasting a token.
+//
+// A replica flag '--replica', is set for the InitCommand. This flag sets the URL of
+// a replica server that read requests fail over to, it can be set more than once.
+//
+// The kdf flags '--kdf', '--kdf-time', '--kdf-memory', and '--kdf-threads' are set
+// for the InitCommand. These flags tune the key derivation function of the password,
+// see kdfFlags.
+//
+// A cipher flag '--cipher', is set for the InitCommand. This flag selects the cipher
+// that new files are encrypted with, aes-256-gcm or xchacha20-poly1305.
+//
+// An encrypt names flag '--encrypt-names', is set for the InitCommand. This flag
+// encrypts the names of the uploaded files, see 'clox names enable'.
+//
+// A private key flag '--private-key', is set for the InitCommand. This flag is the
+// path of an existing RSA private key to use instead of generating a key pair. A
+// public key flag '--public-key', is set for the InitCommand. This flag is the path
+// of the public key of the private key, it is checked to match.
+func NewInitCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger) *InitCommand {
+	initCmd := &InitCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger}
 
 	initCmd.cmd = &cobra.Command{
 		Use:   "init",
 		Short: "Set up the Clox CLI",
 		Args:  cobra.ExactArgs(0),
-		Run:   initCmd.Run,
+		RunE:  initCmd.Run,
 	}
 
 	initCmd.cmd.Flags().BoolVarP(&initCmd.force, "force", "f", false, "Overwrites current configuration")
+	initCmd.cmd.Flags().BoolVar(&initCmd.oauth, "oauth", false, "Obtain the API token with the OAuth device flow")
+	initCmd.cmd.Flags().StringArrayVar(&initCmd.replicas, "replica", nil, "The URL of a replica server, can be set more than once")
+	initCmd.kdf.register(initCmd.cmd)
+	initCmd.cmd.Flags().StringVar(&initCmd.cipher, "cipher", crypto.AESGCM, "The cipher of new files: aes-256-gcm, or xchacha20-poly1305 for hardware without AES instructions")
+	initCmd.cmd.Flags().BoolVar(&initCmd.names, "encrypt-names", false, "Encrypt the names of uploaded files")
+	initCmd.cmd.Flags().StringVar(&initCmd.privKey, "private-key", "", "The path of an existing PEM encoded RSA private key to use")
+	initCmd.cmd.Flags().StringVar(&initCmd.pubKey, "public-key", "", "The path of the PEM encoded public key of the private key")
 
 	return initCmd
 }
@@ -47,48 +94,251 @@ func (c *InitCommand) Command() *cobra.Command {
 	return c.cmd
 }
 
-// Run is the Run function of the cobra.Command in this InitCommand.
+// Run is the RunE function of the cobra.Command in this InitCommand.
 //
 // Run will create a user and write it to the configuration file. If the
 // configuration directory does not exist it will create it. If the user is already
 // configured, it will print a message stating Clox CLI is already set up.
-func (c *InitCommand) Run(cmd *cobra.Command, args []string) {
+//
+// The URL of the Clox server is prompted for, an empty answer is the default
+// server. If the server flag (--server) is set, it is used instead of prompting.
+// The server is written to the configuration file and used by every command of the
+// profile. The TLS flags (--ca-file, --client-cert, --client-key, and
+// --insecure-skip-verify) are written the same way.
+//
+// The password encrypts the API token and private key with the key derivation
+// function of the kdf flags, it is written to the configuration file so the
+// parameters can be raised later without breaking the profile. A weak password is
+// refused unless the allow weak password flag (--allow-weak-password) is set, see
+// prompt.PasswordProblems.
+//
+// If the oauth flag (--oauth) is set, the API token is obtained with the OAuth
+// device authorization flow. A code and URL is printed for the user to authorize
+// the CLI, and the server is polled until the token is issued. The token is then
+// encrypted and stored the same as a pasted token. If CLOX_API_TOKEN is set, it is
+// stored instead of prompting for a token.
+//
+// If the private key flag (--private-key) is set, the RSA private key in the file is
+// used instead of a generated key pair. The key is PEM encoded, in the PKCS #1 or
+// PKCS #8 format, and must not be encrypted, see security.ParsePrivateKey. It is
+// encrypted with the password the same as a generated key. If the public key flag
+// (--public-key) is set, the public key in the file must be the key of the private
+// key. The files are read before anything is prompted for.
+//
+// Recovery codes are generated for the profile, see config.User.GenerateRecoveryCodes,
+// and printed once. Any of them sets a new password if the password is lost, see
+// 'clox recover'.
+//
+// With the password stdin (--password-stdin) and no input (--no-input) flags, the
+// password is read from standard input and the default server is used if the server
+// flag is not set, so the CLI can be initialized by a script.
+func (c *InitCommand) Run(cmd *cobra.Command, args []string) error {
 	dirExists, err := c.store.DirExists()
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		c.logger.Error("checking config directory", "error", err)
+		return reported(err)
 	}
 	if !dirExists {
 		err := c.store.WriteDir()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			c.logger.Error("writing config directory", "error", err)
+			return reported(err)
 		}
 	}
 
 	user := &config.User{}
 	err = c.store.ReadConfigFile(user)
 	if err == nil && !c.force {
-		fmt.Println("Clox CLI already configured")
-		fmt.Println("Run 'clox init -f' to force initialize")
-		os.Exit(0)
+		fmt.Fprintln(stdout, "Clox CLI already configured")
+		fmt.Fprintln(stdout, "Run 'clox init -f' to force initialize")
+		return nil
+	}
+	if err == nil {
+		fmt.Fprintf(stdout, "The configuration of profile '%s' will be replaced, files uploaded with the current keys cannot be decrypted without a backup of them\n", c.store.Profile)
+		if !prompt.Confirm("Overwrite the configuration?") {
+			return reported(nil)
+		}
+	}
+
+	kdf, err := c.kdf.kdf()
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid kdf flag:", err)
+		return errUsage
+	}
+	if err := crypto.ValidateCipher(c.cipher); err != nil {
+		fmt.Fprintln(stderr, "Invalid cipher flag:", err)
+		return errUsage
+	}
+
+	priv, err := c.readKeyPair()
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid key pair (--private-key, --public-key):", err)
+		return errUsage
+	}
+
+	replicas := []string{}
+	for _, r := range c.replicas {
+		u, err := validateServerURL(r)
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid replica URL:", err)
+			return errUsage
+		}
+		replicas = append(replicas, u)
+	}
+
+	server := c.store.Server
+	if server == "" {
+		server, err = validateServerURL(prompt.ConfigureServerURL(defaultServer))
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid server URL:", err)
+			return errUsage
+		}
 	}
 
-	user, err = config.NewUser(
-		c.keys,
-		c.aes,
-		c.rsa,
-		prompt.ConfigurePassowrd(),
-		prompt.ConfigureAPIToken())
+	password, err := prompt.ConfigurePassowrd()
+	var weak *prompt.WeakPasswordError
+	if errors.As(err, &weak) {
+		fmt.Fprintln(stderr, "Password is too weak:")
+		for _, p := range weak.Problems {
+			fmt.Fprintf(stderr, "  - %s\n", p)
+		}
+		fmt.Fprintln(stderr, "-> [HINT] Choose a longer, less predictable password, or set --allow-weak-password in a test environment")
+		return reported(nil)
+	}
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintln(stderr, "Cannot read the password:", err)
+		fmt.Fprintln(stderr, "-> [HINT] Pass the password with --password-stdin")
+		return reported(err)
+	}
+	defer password.Wipe()
+
+	token := secret.FromString(os.Getenv(envAPIToken))
+	switch {
+	case len(token) > 0:
+		// The token of the environment is stored as is.
+	case c.oauth:
+		token, err = c.deviceToken(cmd.Context(), server)
+		if err != nil {
+			c.logger.Error("obtaining api token", "error", err)
+			return reported(err)
+		}
+	default:
+		token, err = prompt.ConfigureAPIToken()
+		if err != nil {
+			fmt.Fprintln(stderr, "Cannot read the API token:", err)
+			fmt.Fprintf(stderr, "-> [HINT] Set the API token with %s, or use the oauth flag (--oauth)\n", envAPIToken)
+			return reported(err)
+		}
+	}
+	defer token.Wipe()
+
+	c.aes.KDF = kdf
+	c.aes.Cipher = c.cipher
+	if priv != nil {
+		user, err = config.NewUserWithKey(c.keys, c.aes, c.rsa, priv, password, token)
+	} else {
+		user, err = config.NewUser(c.keys, c.aes, c.rsa, password, token)
+	}
+	if err != nil {
+		c.logger.Error("creating user", "error", err)
+		return reported(err)
+	}
+	user.SetServer(server)
+	user.SetKDF(kdf)
+	user.SetCipher(c.cipher)
+	tlsConfig, err := absTLS(c.store.TLS)
+	if err != nil {
+		c.logger.Error("resolving tls files", "error", err)
+		return reported(err)
+	}
+	user.SetTLS(tlsConfig)
+	for _, r := range replicas {
+		user.AddReplica(r)
+	}
+	if c.names {
+		if err := enableNames(user, c.keys, c.aes, c.rsa, password); err != nil {
+			c.logger.Error("generating names key", "error", err)
+			return reported(err)
+		}
+	}
+	codes, err := user.GenerateRecoveryCodes(c.keys, c.aes, c.rsa, password)
+	if err != nil {
+		c.logger.Error("generating recovery codes", "error", err)
+		return reported(err)
 	}
 	if err := c.store.WriteConfigFile(user); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	fmt.Fprintln(stdout, "Success")
+	printRecoveryCodes(codes)
+	return nil
+}
+
+// readKeyPair reads the private key of the private key flag (--private-key) and
+// checks it against the public key of the public key flag (--public-key). If the
+// private key flag is not set, it returns nil and a key pair is generated.
+func (c *InitCommand) readKeyPair() (*rsa.PrivateKey, error) {
+	if c.privKey == "" {
+		if c.pubKey != "" {
+			return nil, errors.New("the public key flag (--public-key) requires the private key flag (--private-key)")
+		}
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(c.privKey)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := security.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.privKey, err)
+	}
+
+	if c.pubKey != "" {
+		data, err := os.ReadFile(c.pubKey)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := security.ParsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.pubKey, err)
+		}
+		if !priv.PublicKey.Equal(pub) {
+			return nil, errors.New("the public key is not the key of the private key")
+		}
+	}
+
+	return priv, nil
+}
+
+// deviceToken obtains an API token with the OAuth device authorization flow. The
+// user code and verification URL are printed, and the server is polled until the
+// user authorizes the CLI. The token must be wiped after use.
+func (c *InitCommand) deviceToken(ctx context.Context, server string) (secret.Bytes, error) {
+	httpClient, err := newHTTPClient(c.store.TLS, "", c.store.DebugHTTP)
+	if err != nil {
+		return nil, fmt.Errorf("configuring transport: %w", err)
+	}
+	auth := newAPIClient(server, nil, c.logger, api.WithHTTPClient(httpClient)).Auth()
+
+	code, err := auth.StartDeviceAuth(ctx, oauthClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyURL := code.VerificationURI
+	if code.VerificationURIComplete != "" {
+		verifyURL = code.VerificationURIComplete
+	}
+	fmt.Fprintf(stdout, "Open %s in your browser and enter the code: %s\n", verifyURL, code.UserCode)
+	fmt.Fprintf(stdout, "Waiting for authorization (expires in %s)...\n", time.Duration(code.ExpiresIn)*time.Second)
+
+	token, err := auth.PollDeviceToken(ctx, oauthClientID, code)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Println("Success")
-	os.Exit(0)
+	return secret.FromString(token), nil
 }
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/spf13/cobra"
+)
+
+// kdfFlags are the kdf (--kdf), kdf time (--kdf-time), kdf memory (--kdf-memory),
+// and kdf threads (--kdf-threads) flags of a command that configures a profile. They
+// tune the key derivation function that the password encrypts the secrets with.
+type kdfFlags struct {
+	name    string
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// register sets the kdf flags for the cmd.
+func (f *kdfFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.name, "kdf", crypto.Argon2id, "The key derivation function of the password: argon2id or pbkdf2-sha256")
+	cmd.Flags().Uint32Var(&f.time, "kdf-time", 0, "The passes of argon2id, or the iterations of pbkdf2-sha256 (default of the kdf if 0)")
+	cmd.Flags().Uint32Var(&f.memory, "kdf-memory", 0, "The memory of argon2id in KiB (default of the kdf if 0)")
+	cmd.Flags().Uint8Var(&f.threads, "kdf-threads", 0, "The threads of argon2id (default of the kdf if 0)")
+}
+
+// kdf returns the crypto.KDF of the flags. The parameters that are not set are the
+// defaults of the kdf, see crypto.NewKDF. If the kdf is not supported or a parameter
+// is invalid, an error is returned.
+func (f *kdfFlags) kdf() (crypto.KDF, error) {
+	k, err := crypto.NewKDF(f.name)
+	if err != nil {
+		return crypto.KDF{}, err
+	}
+
+	if f.time != 0 {
+		k.Time = f.time
+	}
+	if f.memory != 0 {
+		k.Memory = f.memory
+	}
+	if f.threads != 0 {
+		k.Threads = f.threads
+	}
+
+	return k, k.Validate()
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/keyring"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// The 'keyring' command.
+//
+// KeyringCommand groups the sub commands that manage storing the password in the OS
+// keyring. It does nothing on its own.
+type KeyringCommand struct {
+	cmd *cobra.Command
+}
+
+// NewKeyringCommand creates and returns a KeyringCommand.
+func NewKeyringCommand() *KeyringCommand {
+	return &KeyringCommand{
+		cmd: &cobra.Command{
+			Use:   "keyring",
+			Short: "Manage storing the password in the OS keyring",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this KeyringCommand.
+func (c *KeyringCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'keyring enable' command.
+//
+// KeyringEnableCommand stores the password in the OS keyring, so commands are
+// unlocked without prompting for the password while the user is logged in.
+type KeyringEnableCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	keyring  keyring.Keyring
+	logger   *logging.Logger
+}
+
+// NewKeyringEnableCommand creates and returns a KeyringEnableCommand.
+func NewKeyringEnableCommand(store *config.Store, ring keyring.Keyring, logger *logging.Logger) *KeyringEnableCommand {
+	enableCmd := &KeyringEnableCommand{store: store, keyring: ring, logger: logger}
+
+	enableCmd.cmd = &cobra.Command{
+		Use:   "enable",
+		Short: "Store the password in the OS keyring",
+		Args:  cobra.ExactArgs(0),
+		RunE:  enableCmd.Run,
+	}
+
+	return enableCmd
+}
+
+// Command returns the cobra.Command of this KeyringEnableCommand.
+func (c *KeyringEnableCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *KeyringEnableCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *KeyringEnableCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this KeyringEnableCommand.
+//
+// Run stores the password of the active profile in the OS keyring. If the platform
+// has no OS keyring, a message is printed and nothing is stored.
+func (c *KeyringEnableCommand) Run(cmd *cobra.Command, args []string) error {
+	if !c.keyring.Available() {
+		fmt.Fprintln(stderr, "The OS keyring is not available on this machine")
+		fmt.Fprintln(stderr, "-> [HINT] On Linux, install secret-tool (libsecret)")
+		return reported(nil)
+	}
+
+	if err := c.keyring.Set(c.store.Account(), *c.password); err != nil {
+		c.logger.Error("storing password in keyring", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Keyring unlock enabled for profile '%s'\n", c.store.Profile)
+	c.logger.Println("Anyone logged in as this OS user can run commands without the password")
+
+	return nil
+}
+
+// The 'keyring disable' command.
+//
+// KeyringDisableCommand removes the password from the OS keyring.
+type KeyringDisableCommand struct {
+	cmd     *cobra.Command
+	store   *config.Store
+	keyring keyring.Keyring
+	logger  *logging.Logger
+}
+
+// NewKeyringDisableCommand creates and returns a KeyringDisableCommand.
+func NewKeyringDisableCommand(store *config.Store, ring keyring.Keyring, logger *logging.Logger) *KeyringDisableCommand {
+	disableCmd := &KeyringDisableCommand{store: store, keyring: ring, logger: logger}
+
+	disableCmd.cmd = &cobra.Command{
+		Use:   "disable",
+		Short: "Remove the password from the OS keyring",
+		Args:  cobra.ExactArgs(0),
+		RunE:  disableCmd.Run,
+	}
+
+	return disableCmd
+}
+
+// Command returns the cobra.Command of this KeyringDisableCommand.
+func (c *KeyringDisableCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this KeyringDisableCommand.
+//
+// Run removes the password of the active profile from the OS keyring. Commands will
+// prompt for the password again.
+func (c *KeyringDisableCommand) Run(cmd *cobra.Command, args []string) error {
+	if err := c.keyring.Delete(c.store.Account()); err != nil {
+		c.logger.Error("removing password from keyring", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Keyring unlock disabled for profile '%s'\n", c.store.Profile)
+
+	return nil
+}
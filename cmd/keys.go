@@ -0,0 +1,564 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/securefile"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// The 'keys' command.
+//
+// KeysCommand groups the sub commands that manage the RSA key pair of a profile. It
+// does nothing on its own.
+type KeysCommand struct {
+	cmd *cobra.Command
+}
+
+// NewKeysCommand creates and returns a KeysCommand.
+func NewKeysCommand() *KeysCommand {
+	return &KeysCommand{
+		cmd: &cobra.Command{
+			Use:   "keys",
+			Short: "Manage the key pair of the CLI",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this KeysCommand.
+func (c *KeysCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'keys rotate' command.
+//
+// KeysRotateCommand replaces the RSA key pair of the active profile. The encryption
+// key is kept, so the files on the server do not need to be encrypted again.
+type KeysRotateCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+}
+
+// NewKeysRotateCommand creates and returns a KeysRotateCommand.
+func NewKeysRotateCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger) *KeysRotateCommand {
+	rotateCmd := &KeysRotateCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger}
+
+	rotateCmd.cmd = &cobra.Command{
+		Use:   "rotate",
+		Short: "Replace the key pair with a new key pair",
+		Args:  cobra.ExactArgs(0),
+		RunE:  rotateCmd.Run,
+	}
+
+	return rotateCmd
+}
+
+// Command returns the cobra.Command of this KeysRotateCommand.
+func (c *KeysRotateCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *KeysRotateCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *KeysRotateCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this KeysRotateCommand.
+//
+// Run generates a new RSA key pair, and encrypts the encryption key with the new
+// public key and the new private key with the password, see
+// config.User.RotateKeys. Before the configuration file is rewritten atomically,
+// it is copied to a backup named after the time of the rotation, so the old key
+// pair is not lost if the new one is. The backup is protected by the password,
+// like the configuration file.
+//
+// The encryption key is decrypted with the new key pair before anything is
+// written, if it does not match, the configuration file is left unchanged. A key
+// pair on a hardware token cannot be rotated by the CLI.
+func (c *KeysRotateCommand) Run(cmd *cobra.Command, args []string) error {
+	if c.user.HasHardwareKey() {
+		printHardwareKey(c.store.Profile)
+		return reported(nil)
+	}
+
+	encKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encKey.Wipe()
+
+	if err := c.user.RotateKeys(c.keys, c.aes, c.rsa, *c.password); err != nil {
+		c.logger.Error("rotating keys", "error", err)
+		return reported(err)
+	}
+
+	rotated, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil || !equalKeys(encKey, rotated) {
+		c.logger.Error("verifying rotated keys, the configuration file was not changed", "error", err)
+		return reported(err)
+	}
+	rotated.Wipe()
+
+	backup, err := c.store.BackupConfigFile("keys-" + time.Now().Format("20060102T150405"))
+	if err != nil {
+		c.logger.Error("backing up config file", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Keys rotated for profile '%s'\n", c.store.Profile)
+	fmt.Fprintf(stdout, "-> [HINT] The old keys were saved to %s, delete it once the new keys work\n", backup)
+
+	return nil
+}
+
+// printHardwareKey prints that the private key of the profile is on a hardware token,
+// and cannot be read by the CLI.
+func printHardwareKey(profile string) {
+	fmt.Fprintf(stderr, "The private key of profile '%s' is stored on a hardware token\n", profile)
+	fmt.Fprintln(stderr, "-> [HINT] Generate a new key in another slot of the token and run 'clox keys piv --slot <slot>', or use 'clox recover' to move the key back to the configuration")
+}
+
+// equalKeys checks if the keys a and b are the same.
+func equalKeys(a []byte, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// The 'keys export' command.
+//
+// KeysExportCommand writes the key pair and encryption key of the active profile to
+// a backup file, so the files on the server can be decrypted on another machine.
+type KeysExportCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	format   string
+}
+
+// NewKeysExportCommand creates and returns a KeysExportCommand.
+//
+// The format flag (--format) is set for the KeysExportCommand. This flag is the
+// format of the file, 'clox' for a backup of the keys or 'age' for the age identity
+// of the profile.
+func NewKeysExportCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger) *KeysExportCommand {
+	exportCmd := &KeysExportCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger}
+
+	exportCmd.cmd = &cobra.Command{
+		Use:   "export <file>",
+		Short: "Export the keys to a password protected backup file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  exportCmd.Run,
+	}
+
+	exportCmd.cmd.Flags().StringVar(&exportCmd.format, "format", "clox", "The format of the file: clox or age")
+	exportCmd.cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"clox", "age"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return exportCmd
+}
+
+// Command returns the cobra.Command of this KeysExportCommand.
+func (c *KeysExportCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *KeysExportCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *KeysExportCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this KeysExportCommand.
+//
+// Run writes the private key, public key, encryption key, and names key of the
+// active profile to the file, see config.KeyBackup. The file is encrypted with the
+// password, it is needed to import the backup. An existing file is never
+// overwritten. The API token is not exported, nor is a private key on a hardware
+// token.
+//
+// If the format flag (--format) is 'age', the age identity of the profile is
+// written to the file instead, in the format of age-keygen. It decrypts the files
+// downloaded with the age flag (--age) with 'age -d -i <file>', and the files
+// encrypted with 'age -r <recipient>' can be uploaded with the age flag. The
+// identity is not protected by the password, but it does not decrypt the files on
+// the server.
+func (c *KeysExportCommand) Run(cmd *cobra.Command, args []string) error {
+	switch c.format {
+	case "clox":
+	case "age":
+		return c.exportAge(args[0])
+	default:
+		fmt.Fprintf(stderr, "Invalid format (--format) '%s', must be clox or age\n", c.format)
+		return errUsage
+	}
+
+	if c.user.HasHardwareKey() {
+		printHardwareKey(c.store.Profile)
+		return reported(nil)
+	}
+
+	path := args[0]
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(stderr, "The file '%s' already exists\n", path)
+		return reported(nil)
+	}
+
+	backup, err := c.user.ExportKeys(*c.password)
+	if err != nil {
+		c.logger.Error("exporting keys", "error", err)
+		return reported(err)
+	}
+	if err := config.WriteKeyBackup(path, c.aes, *c.password, backup); err != nil {
+		c.logger.Error("writing key backup", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Keys of profile '%s' exported to %s\n", c.store.Profile, path)
+	fmt.Fprintln(stdout, "-> [HINT] Keep the file safe, with the password it decrypts every file of the profile")
+
+	return nil
+}
+
+// exportAge writes the age identity of the active profile to the file at path. An
+// existing file is never overwritten.
+func (c *KeysExportCommand) exportAge(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(stderr, "The file '%s' already exists\n", path)
+		return reported(nil)
+	}
+
+	encKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encKey.Wipe()
+	id, err := ageIdentity(encKey)
+	if err != nil {
+		c.logger.Error("deriving age identity", "error", err)
+		return reported(err)
+	}
+	if err := writeAgeIdentity(path, id); err != nil {
+		c.logger.Error("writing age identity", "path", path, "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Age identity of profile '%s' exported to %s\n", c.store.Profile, path)
+	c.logger.Printf("Recipient: %s\n", id.Recipient())
+	fmt.Fprintf(stdout, "-> [HINT] Decrypt a file downloaded with 'clox download --age' with 'age -d -i %s'\n", path)
+	fmt.Fprintln(stdout, "-> [HINT] Keep the file safe, it decrypts every file downloaded in the age format")
+
+	return nil
+}
+
+// The 'keys import' command.
+//
+// KeysImportCommand replaces the key pair and encryption key of the active profile
+// with those of a backup file written by 'clox keys export'.
+type KeysImportCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+}
+
+// NewKeysImportCommand creates and returns a KeysImportCommand.
+func NewKeysImportCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger) *KeysImportCommand {
+	importCmd := &KeysImportCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger}
+
+	importCmd.cmd = &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import the keys from a backup file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  importCmd.Run,
+	}
+
+	return importCmd
+}
+
+// Command returns the cobra.Command of this KeysImportCommand.
+func (c *KeysImportCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *KeysImportCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *KeysImportCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this KeysImportCommand.
+//
+// Run decrypts the backup file with the password of the active profile. If it
+// cannot, the user is prompted for the password the backup was exported with. The
+// keys of the backup are encrypted with the password of the active profile, see
+// config.User.ImportKeys, the API token and settings of the profile are kept.
+//
+// The keys of the profile are replaced, the files uploaded with them can only be
+// decrypted with a backup of them, so the user must confirm the import. The
+// configuration file is copied to a backup before it is rewritten atomically, the
+// same as 'clox keys rotate'. The names mapping of the profile is removed, it was
+// recorded with the replaced names key, and so are the recovery codes.
+func (c *KeysImportCommand) Run(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	backupPassword := *c.password
+	backup, err := config.ReadKeyBackup(path, c.aes, backupPassword)
+	if errors.Is(err, securefile.ErrNotExist) {
+		fmt.Fprintf(stderr, "The file '%s' does not exist\n", path)
+		return reported(nil)
+	}
+	if err != nil {
+		backupPassword, err = prompt.Secret("Backup Password")
+		if err != nil {
+			fmt.Fprintln(stderr, "Cannot read the password of the backup:", err)
+			return reported(err)
+		}
+		defer backupPassword.Wipe()
+		backup, err = config.ReadKeyBackup(path, c.aes, backupPassword)
+		if err != nil {
+			c.logger.Error("reading key backup", "error", err)
+			return reported(err)
+		}
+	}
+
+	fmt.Fprintf(stdout, "The keys of profile '%s' will be replaced, files uploaded with the current keys cannot be decrypted without a backup of them\n", c.store.Profile)
+	if !prompt.Confirm("Import the keys?") {
+		return reported(nil)
+	}
+
+	hadCodes := c.user.RecoveryCodes() > 0
+	if err := c.user.ImportKeys(c.keys, c.aes, c.rsa, backup, backupPassword, *c.password); err != nil {
+		c.logger.Error("importing keys", "error", err)
+		return reported(err)
+	}
+
+	configBackup, err := c.store.BackupConfigFile("keys-" + time.Now().Format("20060102T150405"))
+	if err != nil {
+		c.logger.Error("backing up config file", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+	if err := os.Remove(c.store.File(namesFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Warn("removing names mapping", "error", err)
+	}
+
+	c.logger.Printf("Keys imported to profile '%s'\n", c.store.Profile)
+	fmt.Fprintf(stdout, "-> [HINT] The replaced keys were saved to %s\n", configBackup)
+	if hadCodes {
+		fmt.Fprintln(stdout, "-> [HINT] The recovery codes were removed, run 'clox keys recovery-codes' to generate new ones")
+	}
+
+	return nil
+}
+
+// The 'keys piv' command.
+//
+// KeysPIVCommand moves the private key of the active profile to a PIV hardware
+// token, such as a YubiKey. The private key is never read from the configuration
+// file again, the token decrypts the encryption key.
+type KeysPIVCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	keys     *security.Keys
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	slot     string
+	module   string
+}
+
+// NewKeysPIVCommand creates and returns a KeysPIVCommand.
+//
+// A slot flag '--slot', is set for the KeysPIVCommand. This flag sets the PIV slot of
+// the RSA key on the token, the key management slot 9d by default.
+//
+// A module flag '--module', is set for the KeysPIVCommand. This flag sets the path
+// of the PKCS #11 module of the token, the OpenSC module is searched for by default.
+func NewKeysPIVCommand(store *config.Store, keys *security.Keys, rsa *crypto.RSA, logger *logging.Logger) *KeysPIVCommand {
+	pivCmd := &KeysPIVCommand{store: store, keys: keys, rsa: rsa, logger: logger}
+
+	pivCmd.cmd = &cobra.Command{
+		Use:   "piv",
+		Short: "Move the private key to a PIV hardware token",
+		Args:  cobra.ExactArgs(0),
+		RunE:  pivCmd.Run,
+	}
+
+	pivCmd.cmd.Flags().StringVar(&pivCmd.slot, "slot", security.DefaultPIVSlot, "The PIV slot of the RSA key: 9a, 9c, 9d, or 9e")
+	pivCmd.cmd.Flags().StringVar(&pivCmd.module, "module", "", "The path of the PKCS #11 module of the token")
+
+	return pivCmd
+}
+
+// Command returns the cobra.Command of this KeysPIVCommand.
+func (c *KeysPIVCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *KeysPIVCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *KeysPIVCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this KeysPIVCommand.
+//
+// Run reads the public key of the slot from the token, encrypts the encryption key
+// with it, and has the token decrypt it to check that it can, see
+// config.User.SetHardwareKey. The RSA key must already be generated or imported on
+// the token, with a tool such as ykman. Every command that decrypts or encrypts
+// files then asks for the PIN of the token, and a touch if its touch policy requires
+// one, the password is still needed for the API token.
+//
+// The configuration file is copied to a backup before it is rewritten atomically,
+// the backup still holds the private key encrypted with the password. Recovery
+// codes keep working, 'clox recover' moves the key back to the configuration file
+// if the token is lost.
+func (c *KeysPIVCommand) Run(cmd *cobra.Command, args []string) error {
+	if err := security.ValidatePIVSlot(c.slot); err != nil {
+		fmt.Fprintln(stderr, "Invalid slot flag:", err)
+		return errUsage
+	}
+
+	piv := &security.PIV{Slot: c.slot, Module: c.module}
+	if !piv.Available() {
+		fmt.Fprintln(stderr, "PIV tokens are not supported on this machine")
+		fmt.Fprintln(stderr, "-> [HINT] Install OpenSC for pkcs11-tool and its PKCS #11 module, or set the module flag (--module)")
+		return reported(nil)
+	}
+
+	kp := config.KeyProvider{Type: config.KeyProviderPIV, Slot: c.slot, Module: c.module}
+	if err := c.user.SetHardwareKey(c.keys, c.rsa, *c.password, piv, kp); err != nil {
+		c.logger.Error("moving private key to piv token", "error", err)
+		return reported(err)
+	}
+
+	backup, err := c.store.BackupConfigFile("keys-" + time.Now().Format("20060102T150405"))
+	if err != nil {
+		c.logger.Error("backing up config file", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Private key of profile '%s' moved to PIV slot %s\n", c.store.Profile, c.slot)
+	fmt.Fprintf(stdout, "-> [HINT] The old private key was saved to %s, keep it offline as a backup or delete it\n", backup)
+
+	return nil
+}
+
+// The 'keys fingerprint' command.
+//
+// KeysFingerprintCommand prints the fingerprints of the key material of the active
+// profile, so it can be compared across machines.
+type KeysFingerprintCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	keys     *security.Keys
+	logger   *logging.Logger
+}
+
+// NewKeysFingerprintCommand creates and returns a KeysFingerprintCommand.
+func NewKeysFingerprintCommand(store *config.Store, keys *security.Keys, logger *logging.Logger) *KeysFingerprintCommand {
+	fingerprintCmd := &KeysFingerprintCommand{store: store, keys: keys, logger: logger}
+
+	fingerprintCmd.cmd = &cobra.Command{
+		Use:   "fingerprint",
+		Short: "Print the fingerprints of the keys",
+		Args:  cobra.ExactArgs(0),
+		RunE:  fingerprintCmd.Run,
+	}
+
+	return fingerprintCmd
+}
+
+// Command returns the cobra.Command of this KeysFingerprintCommand.
+func (c *KeysFingerprintCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *KeysFingerprintCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *KeysFingerprintCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this KeysFingerprintCommand.
+//
+// Run prints the SHA-256 fingerprint of the public key, see
+// security.PublicKeyFingerprint, and of the encryption key as it is stored,
+// encrypted with the public key. Two machines with the same fingerprints have the
+// same key pair and encryption key. Nothing secret is printed.
+func (c *KeysFingerprintCommand) Run(cmd *cobra.Command, args []string) error {
+	pub, err := c.user.RSAPublicKey(c.keys)
+	if err != nil {
+		c.logger.Error("decoding public key", "error", err)
+		return reported(err)
+	}
+	pubFingerprint, err := security.PublicKeyFingerprint(pub)
+	if err != nil {
+		c.logger.Error("computing public key fingerprint", "error", err)
+		return reported(err)
+	}
+	encFingerprint, err := c.user.EncryptKeyFingerprint()
+	if err != nil {
+		c.logger.Error("computing encryption key fingerprint", "error", err)
+		return reported(err)
+	}
+
+	fmt.Fprintf(stdout, "Profile: %s\n", c.store.Profile)
+	fmt.Fprintf(stdout, "Public Key: %s (%d bits)\n", pubFingerprint, pub.N.BitLen())
+	fmt.Fprintf(stdout, "Encryption Key: %s\n", encFingerprint)
+	if c.user.HasHardwareKey() {
+		fmt.Fprintf(stdout, "Private Key: on the PIV token, slot %s\n", c.user.KeyProvider().Slot)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/crypto"
+)
+
+// borderlineQuota is the fraction of the remaining quota that an upload can use
+// before the user is asked to continue.
+const borderlineQuota = 0.9
+
+// limitCheck is the result of checking uploads against the limits of the server.
+type limitCheck struct {
+	// The reasons the uploads will be rejected by the server.
+	problems []string
+	// The reasons the uploads may be rejected by the server.
+	warnings []string
+}
+
+// checkLimits checks the uploads against the limits of the server. The size of each
+// file after it is encrypted is checked against the maximum file size and the
+// remaining quota, and the content type of each file is checked against the allowed
+// types. Nothing is encrypted or sent.
+func checkLimits(limits *api.Limits, uploads []api.FileUpload) (limitCheck, error) {
+	check := limitCheck{}
+
+	var total int64
+	for _, u := range uploads {
+		size, contentType, err := sniffFile(u.Path, u.Filename)
+		if err != nil {
+			return limitCheck{}, err
+		}
+
+		encSize := crypto.EncryptedSize(size)
+		total += encSize
+
+		if limits.MaxFileSize > 0 && encSize > limits.MaxFileSize {
+			check.problems = append(check.problems, fmt.Sprintf("%s is %s, the maximum file size is %s",
+				u.Path, formatBytes(encSize), formatBytes(limits.MaxFileSize)))
+		}
+
+		if !limits.Allows(contentType) {
+			check.problems = append(check.problems, fmt.Sprintf("%s is %s, which the server does not accept",
+				u.Path, contentType))
+		}
+	}
+
+	remaining := limits.Remaining()
+	switch {
+	case remaining < 0:
+	case total > remaining:
+		check.problems = append(check.problems, fmt.Sprintf("the upload is %s, only %s of the quota remains",
+			formatBytes(total), formatBytes(remaining)))
+	case float64(total) > float64(remaining)*borderlineQuota:
+		check.warnings = append(check.warnings, fmt.Sprintf("the upload is %s, it uses nearly all of the %s of the quota that remains",
+			formatBytes(total), formatBytes(remaining)))
+	}
+
+	return check, nil
+}
+
+// sniffFile returns the size and content type of the local file at path that is
+// uploaded as name. Only the start of the file is read.
+func sniffFile(path string, name string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, "", err
+	}
+
+	return fi.Size(), api.DetectContentType(name, head[:n]), nil
+}
+
+// formatBytes formats a number of bytes in a human readable format, such as 1.5 MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
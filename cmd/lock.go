@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// defaultLockTTL is the time a lock is held if the ttl flag (--ttl) is not set.
+const defaultLockTTL = 15 * time.Minute
+
+// fileLocation returns the api.Location of the file for the args and id flag of a
+// command. Exactly one of a path argument or the id flag must be set.
+func fileLocation(args []string, id string) (api.Location, error) {
+	switch {
+	case len(args) == 1 && id != "":
+		return api.Location{}, fmt.Errorf("only one can be set: <path> or id (-i, --id)")
+	case len(args) == 1:
+		return api.Path(args[0]), nil
+	case id != "":
+		return api.ID(id), nil
+	default:
+		return api.Location{}, fmt.Errorf("a <path> or id (-i, --id) must be set")
+	}
+}
+
+// printLock prints the lock in a human readable format.
+func printLock(l *api.Lock) {
+	fmt.Fprintf(stdout, "-> Path: %s\n", l.FilePath)
+	fmt.Fprintf(stdout, "-> File ID: %s\n", l.FileID)
+	fmt.Fprintf(stdout, "-> Holder: %s (%s)\n", l.Username, l.OwnerID)
+	fmt.Fprintf(stdout, "-> Expires: %s\n", l.ExpiresAt.Local().Format(time.RFC1123))
+}
+
+// The 'lock' command.
+//
+// LockCommand acquires an advisory lock on a file on the server, so other users
+// know not to overwrite it. Running it again on a locked file extends the lock.
+type LockCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	id       string
+	ttl      time.Duration
+}
+
+// NewLockCommand creates and returns a LockCommand.
+//
+// The id flag (-i, --id) is set for the LockCommand. This flag allows users to lock
+// a file by its ID instead of its path.
+//
+// The ttl flag (--ttl) is set for the LockCommand. This flag sets how long the lock
+// is held before it expires.
+func NewLockCommand(logger *logging.Logger, reauth *Reauthenticator) *LockCommand {
+	lockCmd := &LockCommand{logger: logger, reauth: reauth}
+
+	lockCmd.cmd = &cobra.Command{
+		Use:   "lock [<path>]",
+		Short: "Lock a file on the server",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  lockCmd.Run,
+	}
+
+	lockCmd.cmd.Flags().StringVarP(&lockCmd.id, "id", "i", "", "The ID of the file to lock")
+	lockCmd.cmd.Flags().DurationVar(&lockCmd.ttl, "ttl", defaultLockTTL, "How long the lock is held")
+
+	return lockCmd
+}
+
+// Command returns the cobra.Command of this LockCommand.
+func (c *LockCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *LockCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *LockCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *LockCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this LockCommand.
+//
+// Run will lock the file at the path argument, or the file with the ID of the id
+// flag (-i, --id). If the file is locked by another user, the lock is not acquired
+// and the program exits.
+func (c *LockCommand) Run(cmd *cobra.Command, args []string) error {
+	file, err := fileLocation(args, c.id)
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid arguments:", err)
+		return errUsage
+	}
+	if c.ttl < time.Second {
+		fmt.Fprintln(stderr, "Invalid ttl (--ttl): must be at least 1s")
+		return errUsage
+	}
+
+	lock, err := c.client.Locks().Acquire(cmd.Context(), file, c.ttl)
+	if err != nil {
+		printLockError(cmd, c.reauth, c.user, *c.password, c.logger, err, file)
+		return reported(err)
+	}
+
+	c.logger.Println("Locked")
+	printLock(lock)
+
+	return nil
+}
+
+// The 'unlock' command.
+//
+// UnlockCommand releases an advisory lock on a file on the server.
+type UnlockCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	id       string
+}
+
+// NewUnlockCommand creates and returns a UnlockCommand.
+//
+// The id flag (-i, --id) is set for the UnlockCommand. This flag allows users to
+// unlock a file by its ID instead of its path.
+func NewUnlockCommand(logger *logging.Logger, reauth *Reauthenticator) *UnlockCommand {
+	unlockCmd := &UnlockCommand{logger: logger, reauth: reauth}
+
+	unlockCmd.cmd = &cobra.Command{
+		Use:   "unlock [<path>]",
+		Short: "Unlock a file on the server",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  unlockCmd.Run,
+	}
+
+	unlockCmd.cmd.Flags().StringVarP(&unlockCmd.id, "id", "i", "", "The ID of the file to unlock")
+
+	return unlockCmd
+}
+
+// Command returns the cobra.Command of this UnlockCommand.
+func (c *UnlockCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *UnlockCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *UnlockCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *UnlockCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this UnlockCommand.
+//
+// Run will unlock the file at the path argument, or the file with the ID of the id
+// flag (-i, --id). Only the user that holds the lock can release it.
+func (c *UnlockCommand) Run(cmd *cobra.Command, args []string) error {
+	file, err := fileLocation(args, c.id)
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid arguments:", err)
+		return errUsage
+	}
+
+	lock, err := c.client.Locks().Release(cmd.Context(), file)
+	if err != nil {
+		printLockError(cmd, c.reauth, c.user, *c.password, c.logger, err, file)
+		return reported(err)
+	}
+
+	c.logger.Println("Unlocked")
+	c.logger.Printf("-> Path: %s\n", lock.FilePath)
+	c.logger.Printf("-> File ID: %s\n", lock.FileID)
+
+	return nil
+}
+
+// printLockError prints the error of a lock request for the file. If the API token
+// was rejected, the user is offered to enter a new one instead.
+func printLockError(cmd *cobra.Command, reauth *Reauthenticator, user *config.User, password []byte, logger *logging.Logger, err error, file api.Location) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if reauth.Handle(cmd.Context(), e, user, password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] File: %s\n", file)
+		printAPIErrorHint(e)
+	default:
+		logger.Error("sending lock request", "error", err)
+	}
+}
@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"net/http"
+	"path"
+	"strings"
 
-	"github.com/cicconee/clox-cli/internal/api"
+	"github.com/cicconee/clox-cli/api"
 	"github.com/cicconee/clox-cli/internal/config"
 	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
 	"github.com/spf13/cobra"
 )
 
@@ -17,28 +22,38 @@ import (
 type MkdirCommand struct {
 	cmd      *cobra.Command
 	user     *config.User
-	password string
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
 	aes      *crypto.AES
+	logger   *logging.Logger
+	reauth   *Reauthenticator
 	path     string
 	id       string
+	parents  bool
+	dryRun   bool
 }
 
 // NewInitCommand creates and returns a InitCommand.
 //
 // A force flag '-f', is set for the InitCommand. This flag allows users to overwrite
 // their current configuration if already set.
-func NewMkdirCommand(aes *crypto.AES) *MkdirCommand {
-	mkdirCmd := &MkdirCommand{aes: aes}
+//
+// The parents flag (-P, --parents) is set for the MkdirCommand. This flag creates
+// every directory along the path that does not exist.
+func NewMkdirCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger, reauth *Reauthenticator) *MkdirCommand {
+	mkdirCmd := &MkdirCommand{store: store, aes: aes, logger: logger, reauth: reauth}
 
 	mkdirCmd.cmd = &cobra.Command{
-		Use:   "mkdir <name>",
-		Short: "Create a new directory",
-		Args:  cobra.ExactArgs(1),
-		Run:   mkdirCmd.Run,
+		Use:   "mkdir <name> [<name>...]",
+		Short: "Create new directories",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  mkdirCmd.Run,
 	}
 
 	mkdirCmd.cmd.Flags().StringVarP(&mkdirCmd.path, "path", "p", "", "The path where the directory will be created")
 	mkdirCmd.cmd.Flags().StringVarP(&mkdirCmd.id, "id", "i", "", "The ID of the parent directory")
+	mkdirCmd.cmd.Flags().BoolVarP(&mkdirCmd.parents, "parents", "P", false, "Create any missing parent directories")
 
 	return mkdirCmd
 }
@@ -52,61 +67,228 @@ func (c *MkdirCommand) SetUser(user *config.User) {
 	c.user = user
 }
 
-func (c *MkdirCommand) SetPassword(password string) {
+func (c *MkdirCommand) SetPassword(password *secret.Bytes) {
 	c.password = password
 }
 
-// Run is the Run function of the cobra.Command in this MkdirCommand.
+func (c *MkdirCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+func (c *MkdirCommand) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// Run is the RunE function of the cobra.Command in this MkdirCommand.
 //
-// Run will create a new directory on the Clox server. The password is used to
-// decrypt the API token, and then calls the API endpoint to create a directory.
+// Run will create a new directory on the Clox server. It calls the API endpoint to
+// create a directory with the client of the command.
 //
 // If the path flag (-p, --path) is set it will create a directory by specifying
 // the path to the new directory. If the id flag (-i, --id) is set, it will create
 // a directory by specifying the ID of the parent. If no flag is set, it will create
 // the directory using an empty path. This will default to the users root directory.
-func (c *MkdirCommand) Run(cmd *cobra.Command, args []string) {
+//
+// If the parents flag (-P, --parents) is set, the name can be a path below the
+// parent directory, such as 'a/b/c'. Every directory along it that does not exist
+// is created, including the parent directory of the path flag. It is not an error
+// if the directory already exists.
+//
+// If more than one name is set, each directory is created in the same parent
+// directory and the result of each is printed after they are all created. If any
+// directory fails, the program exits with exitPartialFailure.
+//
+// If the command is a dry run (--dry-run), the requests that would create the
+// directories are printed instead of the directories that were created.
+func (c *MkdirCommand) Run(cmd *cobra.Command, args []string) error {
 	if c.path != "" && c.id != "" {
-		fmt.Println("Only one flag can be set: path (-p, --path) or id (-i, --id)")
+		fmt.Fprintln(stderr, "Only one flag can be set: path (-p, --path) or id (-i, --id)")
+		return errUsage
+	}
+
+	if len(args) > 1 {
+		return c.makeAll(cmd, c.client, args)
+	}
+	if c.parents {
+		c.makeParents(cmd, c.client, args[0])
+		return nil
+	}
+
+	res, rErr := c.client.Dirs().Create(cmd.Context(), location(c.path, c.id), args[0])
+	if rErr != nil {
+		c.printError(cmd, rErr, args[0])
+		return reported(rErr)
+	}
+	if c.dryRun {
+		return nil
+	}
+
+	printDirCreated(res, c.logger)
+
+	c.index(*res)
+
+	return nil
+}
+
+// makeParents creates the directory at the path name below the parent directory,
+// and every directory along it that does not exist. Only the directories that are
+// created are printed.
+func (c *MkdirCommand) makeParents(cmd *cobra.Command, client *api.Client, name string) {
+	dirs, base, err := c.parentDir(cmd, client)
+	if err != nil {
+		c.printError(cmd, err, name)
 		return
 	}
 
-	token, err := c.user.APIToken(c.aes, c.password)
+	target := path.Join(base, name)
+	if target == "/" {
+		fmt.Fprintln(stdout, "Error: the root directory already exists")
+		return
+	}
+
+	id, err := dirs.Ensure(target)
 	if err != nil {
-		fmt.Println("Error:", err)
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) {
+			err = apiErr
+		}
+		c.printError(cmd, err, name)
+	}
+	if c.dryRun {
 		return
 	}
 
-	// Create the HTTP client and do the request.
-	client := &http.Client{}
-	dirParams := api.NewDirParams{
-		BaseURL: "http://localhost:8081",
-		DirName: args[0],
-		Token:   token,
+	for _, d := range dirs.created {
+		d := d
+		printDirCreated(&d, c.logger)
 	}
-	var res *api.NewDirResponse
-	var rErr error
-	if c.path != "" || (c.path == "" && c.id == "") {
-		res, rErr = api.NewDirWithPath(client, c.path, dirParams)
-	} else {
-		res, rErr = api.NewDirWithID(client, c.id, dirParams)
+	if err == nil && len(dirs.created) == 0 {
+		fmt.Fprintf(stdout, "Directory Exists: %s\n", target)
+		fmt.Fprintf(stdout, "-> ID: %s\n", id)
 	}
-	if rErr != nil {
-		switch e := rErr.(type) {
-		case *api.APIError:
-			fmt.Printf("API Error [%d]: %s\n", e.StatusCode, e.Err)
-			fmt.Printf("-> [ARG] Name: %s\n", args[0])
-			fmt.Printf("-> [FLAG] Path: %s\n", c.path)
-			fmt.Printf("-> [FLAG] Parent ID: %s\n", c.id)
-		default:
-			fmt.Printf("Error: %v\n", rErr)
-		}
+
+	c.index(dirs.created...)
+}
+
+// index adds the directories that were created to the index, unless the command is
+// a dry run.
+func (c *MkdirCommand) index(dirs ...api.Dir) {
+	if c.dryRun {
 		return
 	}
 
-	fmt.Printf("API [%d]: Directory Created\n", 200)
-	fmt.Printf("-> Name: %s\n", res.DirName)
-	fmt.Printf("-> Path: %s\n", res.DirPath)
-	fmt.Printf("-> ID: %s\n", res.ID)
-	return
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		for _, d := range dirs {
+			idx.Add(index.DirEntry(d))
+		}
+	})
+}
+
+// parentDir returns a dirMaker and the path of the parent directory of the path or
+// id flag. The parent directory is listed to find its path if the id flag is set.
+func (c *MkdirCommand) parentDir(cmd *cobra.Command, client *api.Client) (*dirMaker, string, error) {
+	dirs := newDirMaker(cmd.Context(), client)
+	if c.id == "" {
+		return dirs, "/" + strings.Trim(c.path, "/"), nil
+	}
+
+	listing, err := client.Dirs().List(cmd.Context(), api.ID(c.id))
+	if err != nil {
+		return nil, "", err
+	}
+	dirs.ids[listing.Dir.DirPath] = c.id
+
+	return dirs, listing.Dir.DirPath, nil
+}
+
+// makeAll creates a directory for each name in the parent directory, and prints
+// the directories that were created and the names that failed. If the parents flag
+// (-P, --parents) is set, the directories along each name are created as well.
+func (c *MkdirCommand) makeAll(cmd *cobra.Command, client *api.Client, names []string) error {
+	var dirs *dirMaker
+	var base string
+	if c.parents {
+		var err error
+		dirs, base, err = c.parentDir(cmd, client)
+		if err != nil {
+			c.printError(cmd, err, strings.Join(names, " "))
+			return reported(err)
+		}
+	}
+
+	created := []api.Dir{}
+	failed := []failure{}
+	for _, name := range names {
+		var err error
+		if c.parents {
+			_, err = dirs.Ensure(path.Join(base, name))
+		} else {
+			var res *api.NewDirResponse
+			res, err = client.Dirs().Create(cmd.Context(), location(c.path, c.id), name)
+			if err == nil {
+				created = append(created, *res)
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) && c.reauth.Handle(cmd.Context(), apiErr, c.user, *c.password) {
+			return reported(apiErr)
+		}
+		failed = append(failed, failure{Path: name, Err: err})
+	}
+	if c.parents {
+		created = dirs.created
+	}
+
+	if !c.dryRun {
+		c.logger.Printf("Created: %d\n", len(created))
+		for _, d := range created {
+			c.logger.Printf("%s -> %s\n", d.ID, d.DirPath)
+		}
+	}
+
+	if len(failed) > 0 || !c.logger.Quiet() {
+		fmt.Fprintf(stdout, "\nErrors: %d\n", len(failed))
+	}
+	for _, e := range failed {
+		fmt.Fprintf(stdout, "%s -> %s\n", e.Path, e.Err)
+	}
+
+	c.index(created...)
+
+	if len(failed) > 0 {
+		return exitWith(exitPartialFailure)
+	}
+
+	return nil
+}
+
+// printError prints the error of a request made by this MkdirCommand when creating
+// the directory with the name. If the API token was rejected, the user is offered
+// to enter a new one instead.
+func (c *MkdirCommand) printError(cmd *cobra.Command, err error, name string) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARG] Name: %s\n", name)
+		fmt.Fprintf(stderr, "-> [FLAG] Path: %s\n", c.path)
+		fmt.Fprintf(stderr, "-> [FLAG] Parent ID: %s\n", c.id)
+		printAPIErrorHint(e)
+	default:
+		c.logger.Error("creating directory", "error", err)
+	}
+}
+
+// printDirCreated prints the directory that was created.
+func printDirCreated(dir *api.Dir, logger *logging.Logger) {
+	logger.Printf("API [%d]: Directory Created\n", 200)
+	logger.Printf("-> Name: %s\n", dir.DirName)
+	logger.Printf("-> Path: %s\n", dir.DirPath)
+	logger.Printf("-> ID: %s\n", dir.ID)
 }
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// sourceAndDest returns the api.Location of the source and destination for the args
+// and the id and destination id flags of a command. Each of the source and the
+// destination is either a path argument or its flag, the source argument comes
+// before the destination argument.
+func sourceAndDest(args []string, id string, destID string) (api.Location, api.Location, error) {
+	want := 2
+	if id != "" {
+		want--
+	}
+	if destID != "" {
+		want--
+	}
+	if len(args) != want {
+		return api.Location{}, api.Location{}, fmt.Errorf("a <source> or id (-i, --id), and a <destination> or destination id (--dest-id) must be set")
+	}
+
+	var src, dest api.Location
+	if id != "" {
+		src = api.ID(id)
+	} else {
+		src = api.Path("/" + strings.Trim(args[0], "/"))
+		args = args[1:]
+	}
+	if destID != "" {
+		dest = api.ID(destID)
+	} else {
+		dest = api.Path("/" + strings.Trim(args[0], "/"))
+	}
+
+	return src, dest, nil
+}
+
+// validateName validates that name can be the name of a directory or file on the
+// server.
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("'%s' is not a valid name", name)
+	}
+
+	return nil
+}
+
+// The 'mv' command.
+//
+// MoveCommand moves a file or directory on the server to another directory. The
+// contents never leave the server, nothing is downloaded or uploaded.
+type MoveCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	aes      *crypto.AES
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	id       string
+	destID   string
+	rename   string
+}
+
+// NewMoveCommand creates and returns a MoveCommand.
+//
+// The id flag (-i, --id) is set for the MoveCommand. This flag allows users to move
+// a file or directory by its ID instead of its path.
+//
+// The destination id flag (--dest-id) is set for the MoveCommand. This flag allows
+// users to set the destination directory by its ID instead of its path.
+//
+// The rename flag (--rename) is set for the MoveCommand. This flag changes the name
+// of the file or directory in the same operation.
+func NewMoveCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger, reauth *Reauthenticator) *MoveCommand {
+	moveCmd := &MoveCommand{store: store, aes: aes, logger: logger, reauth: reauth}
+
+	moveCmd.cmd = &cobra.Command{
+		Use:   "mv [<source>] [<destination>]",
+		Short: "Move a file or directory to another directory on the server",
+		Args:  cobra.MaximumNArgs(2),
+		RunE:  moveCmd.Run,
+	}
+
+	moveCmd.cmd.Flags().StringVarP(&moveCmd.id, "id", "i", "", "The ID of the file or directory to move")
+	moveCmd.cmd.Flags().StringVar(&moveCmd.destID, "dest-id", "", "The ID of the directory to move to")
+	moveCmd.cmd.Flags().StringVar(&moveCmd.rename, "rename", "", "The new name of the file or directory")
+
+	return moveCmd
+}
+
+// Command returns the cobra.Command of this MoveCommand.
+func (c *MoveCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *MoveCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *MoveCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *MoveCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this MoveCommand.
+//
+// Run moves the source into the destination directory. The source is a file if the
+// server has a file at it, otherwise it is a directory and everything below it is
+// moved with it. If the rename flag (--rename) is set, the source is given the new
+// name in the destination.
+//
+// The local index and the tracked files are updated with the new paths.
+func (c *MoveCommand) Run(cmd *cobra.Command, args []string) error {
+	src, dest, err := sourceAndDest(args, c.id, c.destID)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return reported(err)
+	}
+
+	if c.rename != "" {
+		if err := validateName(c.rename); err != nil {
+			fmt.Fprintln(stderr, "Invalid rename (--rename):", err)
+			return errUsage
+		}
+	}
+
+	file, listing, err := resolveTarget(cmd.Context(), c.client, src)
+	if err != nil {
+		c.printError(cmd, err, src, dest)
+		return reported(err)
+	}
+
+	params := api.MoveParams{Parent: &dest, Name: c.rename}
+
+	var id, oldPath, newPath string
+	if file != nil {
+		moved, err := c.client.Files().Move(cmd.Context(), api.ID(file.ID), params)
+		if err != nil {
+			c.printError(cmd, err, src, dest)
+			return reported(err)
+		}
+		id, oldPath, newPath = moved.ID, file.Path, moved.Path
+	} else {
+		if listing.Dir.DirPath == "/" {
+			fmt.Fprintln(stderr, "Error: the root directory cannot be moved")
+			return reported(nil)
+		}
+
+		moved, err := c.client.Dirs().Move(cmd.Context(), api.ID(listing.Dir.ID), params)
+		if err != nil {
+			c.printError(cmd, err, src, dest)
+			return reported(err)
+		}
+		id, oldPath, newPath = moved.ID, listing.Dir.DirPath, moved.DirPath
+	}
+
+	moveLocal(c.store, c.aes, *c.password, c.logger, oldPath, newPath)
+
+	c.logger.Printf("Moved: %s -> %s\n", oldPath, newPath)
+	c.logger.Printf("-> ID: %s\n", id)
+
+	return nil
+}
+
+// printError prints the error of a request made by this MoveCommand. If the API
+// token was rejected, the user is offered to enter a new one instead.
+func (c *MoveCommand) printError(cmd *cobra.Command, err error, src api.Location, dest api.Location) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] Source: %s\n", src)
+		fmt.Fprintf(stderr, "-> [ARGS] Destination: %s\n", dest)
+		if c.rename != "" {
+			fmt.Fprintf(stderr, "-> [FLAG] Rename: %s\n", c.rename)
+		}
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Move failed:", err)
+	}
+}
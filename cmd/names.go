@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/names"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// namesFile is the name of the encrypted file of a profile that maps the encrypted
+// names of the remote files to their names, see names.Mapping.
+const namesFile = "names.enc"
+
+// newNameCodec returns the names.Codec that the file names of the user are encrypted
+// with, and the names.Mapping of the active profile that it records the names in. If
+// the user does not encrypt file names, it returns nil.
+func newNameCodec(store *config.Store, user *config.User, aes *crypto.AES, password []byte, logger *logging.Logger) (*names.Codec, *names.Mapping, error) {
+	if !user.EncryptsNames() {
+		return nil, nil, nil
+	}
+
+	key, err := user.NamesKey(aes, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer key.Wipe()
+
+	mapping := loadNames(store, aes, password, logger)
+	codec, err := names.New(key, mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return codec, mapping, nil
+}
+
+// loadNames loads the names.Mapping of the active profile. If it cannot be read, it
+// is logged and an empty names.Mapping is returned, the names are decrypted again.
+func loadNames(store *config.Store, aes *crypto.AES, password []byte, logger *logging.Logger) *names.Mapping {
+	m, err := names.Load(store.File(namesFile), aes, password)
+	if err != nil {
+		logger.Debug("loading names", "error", err)
+		return names.NewMapping()
+	}
+
+	return m
+}
+
+// saveNames saves the names.Mapping of the active profile. A failed save is logged
+// and never fails the command.
+func saveNames(store *config.Store, aes *crypto.AES, password []byte, logger *logging.Logger, m *names.Mapping) {
+	if err := m.Save(store.File(namesFile), aes, password); err != nil {
+		logger.Debug("saving names", "error", err)
+	}
+}
+
+// The 'names' command.
+//
+// NamesCommand groups the sub commands that manage the encryption of the remote file
+// names. It does nothing on its own.
+type NamesCommand struct {
+	cmd *cobra.Command
+}
+
+// NewNamesCommand creates and returns a NamesCommand.
+func NewNamesCommand() *NamesCommand {
+	return &NamesCommand{
+		cmd: &cobra.Command{
+			Use:   "names",
+			Short: "Manage the encryption of remote file names",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this NamesCommand.
+func (c *NamesCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'names enable' command.
+//
+// NamesEnableCommand encrypts the names of the files that are uploaded from then
+// on, so the names stored on the server do not hint at the contents of the files.
+type NamesEnableCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+}
+
+// NewNamesEnableCommand creates and returns a NamesEnableCommand.
+func NewNamesEnableCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger) *NamesEnableCommand {
+	enableCmd := &NamesEnableCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger}
+
+	enableCmd.cmd = &cobra.Command{
+		Use:   "enable",
+		Short: "Encrypt the names of uploaded files",
+		Args:  cobra.ExactArgs(0),
+		RunE:  enableCmd.Run,
+	}
+
+	return enableCmd
+}
+
+// Command returns the cobra.Command of this NamesEnableCommand.
+func (c *NamesEnableCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *NamesEnableCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *NamesEnableCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this NamesEnableCommand.
+//
+// Run generates the names key of the active profile, encrypts it with the password,
+// and writes it to the configuration file. The names of the files that are
+// uploaded, copied, moved, or renamed are encrypted with it, and the names the
+// server responds with are decrypted, see names.Codec. The files already on the
+// server keep their names. If the names are already encrypted, nothing is changed.
+//
+// The names key is not kept anywhere else, a profile that is initialized again
+// cannot decrypt the names.
+func (c *NamesEnableCommand) Run(cmd *cobra.Command, args []string) error {
+	if c.user.EncryptsNames() {
+		fmt.Fprintf(stdout, "File names are already encrypted for profile '%s'\n", c.store.Profile)
+		return nil
+	}
+
+	if err := enableNames(c.user, c.keys, c.aes, c.rsa, *c.password); err != nil {
+		c.logger.Error("generating names key", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("File name encryption enabled for profile '%s'\n", c.store.Profile)
+	c.logger.Println("-> [HINT] Files already on the server keep their names, rename a file to encrypt its name")
+
+	return nil
+}
+
+// enableNames generates a names key and sets it as the names key of the user.
+func enableNames(user *config.User, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, password []byte) error {
+	key, err := aes.Generate()
+	if err != nil {
+		return err
+	}
+	defer key.Wipe()
+
+	return user.SetNamesKey(keys, aes, rsa, password, key)
+}
+
+// The 'names list' command.
+//
+// NamesListCommand prints the encrypted names that were seen on the server, and the
+// names they were encrypted from.
+type NamesListCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	aes      *crypto.AES
+	logger   *logging.Logger
+}
+
+// NewNamesListCommand creates and returns a NamesListCommand.
+func NewNamesListCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger) *NamesListCommand {
+	listCmd := &NamesListCommand{store: store, aes: aes, logger: logger}
+
+	listCmd.cmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the encrypted names of the remote files",
+		Args:  cobra.ExactArgs(0),
+		RunE:  listCmd.Run,
+	}
+
+	return listCmd
+}
+
+// Command returns the cobra.Command of this NamesListCommand.
+func (c *NamesListCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *NamesListCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *NamesListCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this NamesListCommand.
+//
+// Run prints every name in the local mapping of the active profile, sorted by name,
+// with the name it is stored with on the server. Names are added to the mapping as
+// the commands upload and list files, it is not complete.
+func (c *NamesListCommand) Run(cmd *cobra.Command, args []string) error {
+	if !c.user.EncryptsNames() {
+		fmt.Fprintf(stdout, "File names are not encrypted for profile '%s'\n", c.store.Profile)
+		fmt.Fprintln(stdout, "Run 'clox names enable' to encrypt the names of uploaded files")
+		return nil
+	}
+
+	m, err := names.Load(c.store.File(namesFile), c.aes, *c.password)
+	if err != nil {
+		c.logger.Error("loading names", "error", err)
+		return reported(err)
+	}
+
+	encoded := make([]string, 0, len(m.Names))
+	for e := range m.Names {
+		encoded = append(encoded, e)
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return m.Names[encoded[i]] < m.Names[encoded[j]]
+	})
+
+	fmt.Fprintf(stdout, "Names: %d\n", len(encoded))
+	for _, e := range encoded {
+		fmt.Fprintf(stdout, "  %s -> %s\n", m.Names[e], e)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// errCanceled is the error of a transfer that was stopped, or never started,
+// because another transfer of the batch failed first.
+var errCanceled = errors.New("canceled after an earlier failure")
+
+// transferFlags are the parallel (--parallel) and fail fast (--fail-fast) flags of
+// a command that downloads a batch of files.
+type transferFlags struct {
+	parallel int
+	failFast bool
+}
+
+// register sets the parallel and fail fast flags for the cmd.
+func (f *transferFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&f.parallel, "parallel", 1, "The number of files to transfer at the same time")
+	cmd.Flags().BoolVar(&f.failFast, "fail-fast", false, "Stop every transfer at the first file that fails")
+}
+
+// validate checks the values of the flags.
+func (f *transferFlags) validate() error {
+	if f.parallel < 1 {
+		return fmt.Errorf("parallel (--parallel) must be at least 1, got %d", f.parallel)
+	}
+
+	return nil
+}
+
+// run calls task for each index from 0 to n, with up to the parallel flag of them
+// running at the same time. It returns the error of each task by its index.
+//
+// If the fail fast flag is set, the context of the tasks is canceled at the first
+// task that fails. The tasks in flight are stopped, and the tasks that were not
+// started are not run; the error of both is errCanceled.
+func (f *transferFlags) run(ctx context.Context, n int, task func(ctx context.Context, i int) error) []error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+	indexes := make(chan int)
+
+	var mu sync.Mutex
+	failed := false
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(f.parallel, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				err := task(ctx, i)
+				if err == nil {
+					continue
+				}
+
+				mu.Lock()
+				if f.failFast && failed {
+					err = errCanceled
+				}
+				if f.failFast && !failed {
+					failed = true
+					cancel()
+				}
+				mu.Unlock()
+				errs[i] = err
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			errs[i] = errCanceled
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cicconee/clox-cli/internal/biometric"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/keyring"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/securefile"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/cicconee/clox-cli/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// encryptedFiles are the files of a profile that are encrypted with the password.
+var encryptedFiles = []string{indexFile, cacheFile, trackingFile, sessionsFile, namesFile}
+
+// The 'passwd' command.
+//
+// PasswdCommand changes the password of the active profile. The keys and API token
+// are kept, they are encrypted with the new password.
+type PasswdCommand struct {
+	cmd       *cobra.Command
+	user      *config.User
+	password  *secret.Bytes
+	store     *config.Store
+	keys      *security.Keys
+	aes       *crypto.AES
+	biometric biometric.Provider
+	keyring   keyring.Keyring
+	logger    *logging.Logger
+}
+
+// NewPasswdCommand creates and returns a PasswdCommand.
+//
+// The biometric provider and keyring are updated with the new password if the
+// active profile is enrolled in them.
+func NewPasswdCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, provider biometric.Provider, ring keyring.Keyring, logger *logging.Logger) *PasswdCommand {
+	passwdCmd := &PasswdCommand{
+		store:     store,
+		keys:      keys,
+		aes:       aes,
+		biometric: provider,
+		keyring:   ring,
+		logger:    logger,
+	}
+
+	passwdCmd.cmd = &cobra.Command{
+		Use:   "passwd",
+		Short: "Change the password of the CLI",
+		Args:  cobra.ExactArgs(0),
+		RunE:  passwdCmd.Run,
+	}
+
+	return passwdCmd
+}
+
+// Command returns the cobra.Command of this PasswdCommand.
+func (c *PasswdCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *PasswdCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *PasswdCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this PasswdCommand.
+//
+// Run prompts for the new password, the current password is verified before Run is
+// called. A weak new password is refused unless the allow weak password flag
+// (--allow-weak-password) is set. The API token and private key are encrypted with the new password, see
+// config.User.ChangePassword, and the configuration file is rewritten atomically.
+// The encrypted files of the profile, such as the index and the tracked files, are
+// then encrypted with the new password.
+//
+// The session of the profile is ended. If the password is stored in the keyring or
+// for biometric unlock, it is replaced with the new password.
+func (c *PasswdCommand) Run(cmd *cobra.Command, args []string) error {
+	newPassword, err := prompt.NewPassword()
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot read the new password:", err)
+		return reported(err)
+	}
+	defer newPassword.Wipe()
+	if newPassword.Equal(*c.password) {
+		fmt.Fprintln(stderr, "The new password is the same as the current password")
+		return reported(nil)
+	}
+
+	if err := c.user.ChangePassword(c.keys, c.aes, *c.password, newPassword); err != nil {
+		c.logger.Error("changing password", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	for _, name := range encryptedFiles {
+		path := c.store.File(name)
+		err := securefile.Reencrypt(path, c.aes, *c.password, newPassword)
+		if err != nil && !errors.Is(err, securefile.ErrNotExist) {
+			c.logger.Warn("encrypting file with the new password, it will be recreated", "path", path, "error", err)
+			os.Remove(path)
+		}
+	}
+
+	if err := session.Remove(session.Path(c.store.Path, c.store.Profile), session.KeyPath(c.store.Dir())); err != nil {
+		c.logger.Warn("removing session", "error", err)
+	}
+	if stored, err := c.keyring.Get(c.store.Account()); err == nil {
+		stored.Wipe()
+		if err := c.keyring.Set(c.store.Account(), newPassword); err != nil {
+			c.logger.Warn("storing new password in keyring, run 'clox keyring enable' again", "error", err)
+		}
+	}
+	if c.biometric.Enrolled(c.store.Account()) {
+		if err := c.biometric.Store(c.store.Account(), newPassword); err != nil {
+			c.logger.Warn("storing new password in keystore, run 'clox biometric enable' again", "error", err)
+		}
+	}
+
+	c.logger.Printf("Password changed for profile '%s'\n", c.store.Profile)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/plugin"
+	"github.com/spf13/cobra"
+)
+
+// PluginCommand is a sub command that runs an external plugin executable.
+//
+// PluginCommand passes every argument through to the plugin without parsing any
+// flags. The plugin is given information about the CLI through the environment.
+type PluginCommand struct {
+	cmd    *cobra.Command
+	plugin plugin.Plugin
+	store  *config.Store
+	logger *logging.Logger
+}
+
+// NewPluginCommand creates and returns a PluginCommand for the plugin.
+func NewPluginCommand(p plugin.Plugin, store *config.Store, logger *logging.Logger) *PluginCommand {
+	pluginCmd := &PluginCommand{plugin: p, store: store, logger: logger}
+
+	pluginCmd.cmd = &cobra.Command{
+		Use:                p.Name,
+		Short:              "Plugin provided by " + p.Path,
+		DisableFlagParsing: true,
+		RunE:               pluginCmd.Run,
+	}
+
+	return pluginCmd
+}
+
+// Command returns the cobra.Command of this PluginCommand.
+func (c *PluginCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this PluginCommand.
+//
+// Run executes the plugin with the arguments and exits with the exit code of the
+// plugin. The following environment variables are set for the plugin:
+//
+//   - CLOX_CONFIG_DIR: The directory of the Clox CLI configuration.
+//   - CLOX_PROFILE: The name of the active profile.
+//   - CLOX_SERVER_URL: The base URL of the Clox API.
+//   - CLOX_PLUGIN_NAME: The name the plugin was invoked as.
+func (c *PluginCommand) Run(cmd *cobra.Command, args []string) error {
+	err := c.plugin.Run(args, map[string]string{
+		"CLOX_CONFIG_DIR":  c.store.Path,
+		"CLOX_PROFILE":     c.store.Profile,
+		"CLOX_SERVER_URL":  profileServer(c.store, c.store.Profile),
+		"CLOX_PLUGIN_NAME": c.plugin.Name,
+	})
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitWith(exitErr.ExitCode())
+	}
+
+	c.logger.Error("running plugin", "plugin", c.plugin.Name, "error", err)
+	return reported(err)
+}
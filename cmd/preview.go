@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// The 'preview' command.
+//
+// PreviewCommand downloads the preview rendition the server generated for a file,
+// such as a thumbnail of an image, instead of the full file.
+type PreviewCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	size     string
+	output   string
+}
+
+// NewPreviewCommand creates and returns a PreviewCommand.
+//
+// The size flag (-s, --size) is set for the PreviewCommand. This flag selects the
+// size of the preview: small, medium, or large.
+//
+// The output flag (-o, --output) is set for the PreviewCommand. This flag sets the
+// local path the preview is written to. A '-' writes the preview to standard
+// output.
+func NewPreviewCommand(logger *logging.Logger, reauth *Reauthenticator) *PreviewCommand {
+	previewCmd := &PreviewCommand{logger: logger, reauth: reauth}
+
+	previewCmd.cmd = &cobra.Command{
+		Use:   "preview <id>",
+		Short: "Download the preview of a file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  previewCmd.Run,
+	}
+
+	previewCmd.cmd.Flags().StringVarP(&previewCmd.size, "size", "s", string(api.PreviewMedium), "The size of the preview: small, medium, or large")
+	previewCmd.cmd.Flags().StringVarP(&previewCmd.output, "output", "o", "", "The path to write the preview, '-' for standard output")
+
+	return previewCmd
+}
+
+// Command returns the cobra.Command of this PreviewCommand.
+func (c *PreviewCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *PreviewCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *PreviewCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *PreviewCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this PreviewCommand.
+//
+// Run will download the preview of the file with the ID and write it to the output
+// flag (-o, --output). If the output flag is not set, the preview is written to the
+// current directory named after the ID and size, with the extension of the media
+// type of the preview.
+//
+// The server only renders previews of files stored unencrypted, every file uploaded
+// with the Clox CLI is encrypted and has no preview.
+func (c *PreviewCommand) Run(cmd *cobra.Command, args []string) error {
+	id := args[0]
+
+	size, err := api.ParsePreviewSize(c.size)
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid size (-s, --size):", err)
+		return errUsage
+	}
+
+	var buf bytes.Buffer
+	mediaType, err := c.client.Files().Preview(cmd.Context(), api.ID(id), size, &buf)
+	if err != nil {
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) && (errors.Is(err, api.ErrNotFound) || apiErr.StatusCode == http.StatusUnsupportedMediaType) {
+			fmt.Fprintf(stderr, "No preview available [%d]: %s\n", apiErr.StatusCode, apiErr.Err)
+			fmt.Fprintln(stderr, "-> [HINT] Previews are only rendered for unencrypted images and documents")
+			return reported(err)
+		}
+
+		switch e := err.(type) {
+		case *api.APIError:
+			if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+				return reported(e)
+			}
+			fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+			fmt.Fprintf(stderr, "-> [ARGS] ID: %s\n", id)
+			fmt.Fprintf(stderr, "-> [FLAG] Size: %s\n", size)
+			printAPIErrorHint(e)
+		default:
+			c.logger.Error("downloading preview", "error", err)
+		}
+		return reported(err)
+	}
+
+	if c.output == "-" {
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			c.logger.Error("writing preview", "error", err)
+			return reported(err)
+		}
+		return nil
+	}
+
+	output := c.output
+	if output == "" {
+		output = fmt.Sprintf("%s-%s%s", id, size, previewExtension(mediaType))
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		c.logger.Error("writing preview", "path", output, "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Preview: %s (%s) -> %s\n", id, mediaType, output)
+
+	return nil
+}
+
+// previewExtension returns the file extension of the media type of a preview. If
+// the media type is not known it returns an empty string.
+func previewExtension(mediaType string) string {
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+
+	return exts[0]
+}
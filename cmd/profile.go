@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// The 'profile' command.
+//
+// ProfileCommand groups the sub commands that manage the configuration profiles.
+// Each profile is a separate account or server. It does nothing on its own.
+type ProfileCommand struct {
+	cmd *cobra.Command
+}
+
+// NewProfileCommand creates and returns a ProfileCommand.
+func NewProfileCommand() *ProfileCommand {
+	return &ProfileCommand{
+		cmd: &cobra.Command{
+			Use:   "profile",
+			Short: "Manage the configuration profiles",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this ProfileCommand.
+func (c *ProfileCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'profile list' command.
+//
+// ProfileListCommand prints the configured profiles.
+type ProfileListCommand struct {
+	cmd    *cobra.Command
+	store  *config.Store
+	logger *logging.Logger
+	format formatFlag
+}
+
+// NewProfileListCommand creates and returns a ProfileListCommand.
+//
+// The format flag (--format) is set for the ProfileListCommand. This flag sets the
+// format the profiles are printed in.
+func NewProfileListCommand(store *config.Store, logger *logging.Logger) *ProfileListCommand {
+	listCmd := &ProfileListCommand{store: store, logger: logger}
+
+	listCmd.cmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the configured profiles",
+		Args:  cobra.ExactArgs(0),
+		RunE:  listCmd.Run,
+	}
+
+	listCmd.format.register(listCmd.cmd)
+
+	return listCmd
+}
+
+// Command returns the cobra.Command of this ProfileListCommand.
+func (c *ProfileListCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this ProfileListCommand.
+//
+// Run prints every configured profile and its server, sorted by name. The active
+// profile is marked with a '*'. If the active profile is not configured, a notice
+// is printed after the profiles as text.
+func (c *ProfileListCommand) Run(cmd *cobra.Command, args []string) error {
+	r, err := c.format.renderer()
+	if err != nil {
+		return err
+	}
+
+	profiles, err := c.store.Profiles()
+	if err != nil {
+		c.logger.Error("reading profiles", "error", err)
+		return reported(err)
+	}
+
+	result := profileList{}
+	for _, p := range profiles {
+		result = append(result, profileEntry{
+			Name:   p,
+			Server: profileServer(c.store, p),
+			Active: p == c.store.Profile,
+		})
+	}
+	if err := render(r, result, c.logger); err != nil {
+		return err
+	}
+
+	if r.IsText() && !c.store.ProfileExists(c.store.Profile) {
+		fmt.Fprintf(stdout, "\nThe active profile '%s' is not configured\n", c.store.Profile)
+		fmt.Fprintln(stdout, "Run 'clox init' to configure the profile")
+	}
+
+	return nil
+}
+
+// The 'profile create' command.
+//
+// ProfileCreateCommand configures a new profile, the same as running 'clox init'
+// with the profile flag (--profile).
+type ProfileCreateCommand struct {
+	cmd   *cobra.Command
+	store *config.Store
+	init  *InitCommand
+}
+
+// NewProfileCreateCommand creates and returns a ProfileCreateCommand.
+//
+// The oauth flag '--oauth', replica flag '--replica', kdf flags, cipher flag
+// '--cipher', and encrypt names flag '--encrypt-names' are set for the
+// ProfileCreateCommand, they are the same as the flags of the 'init' command.
+func NewProfileCreateCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger) *ProfileCreateCommand {
+	createCmd := &ProfileCreateCommand{
+		store: store,
+		init:  NewInitCommand(store, keys, aes, rsa, logger),
+	}
+
+	createCmd.cmd = &cobra.Command{
+		Use:   "create <name>",
+		Short: "Configure a new profile",
+		Args:  cobra.ExactArgs(1),
+		RunE:  createCmd.Run,
+	}
+
+	createCmd.cmd.Flags().BoolVar(&createCmd.init.oauth, "oauth", false, "Obtain the API token with the OAuth device flow")
+	createCmd.cmd.Flags().StringArrayVar(&createCmd.init.replicas, "replica", nil, "The URL of a replica server, can be set more than once")
+	createCmd.init.kdf.register(createCmd.cmd)
+	createCmd.cmd.Flags().StringVar(&createCmd.init.cipher, "cipher", crypto.AESGCM, "The cipher of new files: aes-256-gcm, or xchacha20-poly1305 for hardware without AES instructions")
+	createCmd.cmd.Flags().BoolVar(&createCmd.init.names, "encrypt-names", false, "Encrypt the names of uploaded files")
+
+	return createCmd
+}
+
+// Command returns the cobra.Command of this ProfileCreateCommand.
+func (c *ProfileCreateCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this ProfileCreateCommand.
+//
+// Run configures the profile with the name, see InitCommand.Run. If the profile is
+// already configured it is not changed, 'clox --profile <name> init -f' overwrites
+// it. The active profile is not changed, run 'clox profile use <name>' to select it.
+func (c *ProfileCreateCommand) Run(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := config.ValidateProfileName(name); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return reported(err)
+	}
+
+	if c.store.ProfileExists(name) {
+		fmt.Fprintf(stderr, "Profile '%s' already exists\n", name)
+		fmt.Fprintf(stderr, "Run 'clox --profile %s init -f' to overwrite it\n", name)
+		return reported(nil)
+	}
+
+	fmt.Fprintf(stdout, "Creating profile '%s'\n", name)
+	c.store.Profile = name
+	c.init.Run(cmd, nil)
+
+	return nil
+}
+
+// profileEntry is a configured profile printed by the 'profile list' command.
+type profileEntry struct {
+	Name   string `json:"name" table:"NAME"`
+	Server string `json:"server" table:"SERVER"`
+	Active bool   `json:"active" table:"ACTIVE"`
+}
+
+// profileList is the result of the 'profile list' command.
+type profileList []profileEntry
+
+// Text writes the number of profiles, followed by every profile and its server, one
+// profile per line. The active profile is marked with a '*'.
+func (l profileList) Text(w io.Writer) error {
+	fmt.Fprintf(w, "Profiles: %d\n", len(l))
+	for _, p := range l {
+		marker := " "
+		if p.Active {
+			marker = "*"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s -> %s\n", marker, p.Name, p.Server); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/spf13/cobra"
+)
+
+// The formats of the progress flag (--progress).
+const (
+	// progressNone does not print the progress of the transfers.
+	progressNone = "none"
+	// progressJSON prints every transfer event as a line of JSON, see jsonEvents.
+	progressJSON = "json"
+)
+
+// progressInterval is how often the progress of the transfer of a file is printed
+// in the JSON format. The first and the last progress of a file are always printed.
+const progressInterval = 100 * time.Millisecond
+
+// progressFlag is the progress flag (--progress) of a command that uploads or
+// downloads files. The transfer events of the command are printed to standard error
+// in the format of the flag.
+type progressFlag struct {
+	progress string
+}
+
+// register sets the progress flag (--progress) for the command, the formats are
+// completed in the shell.
+func (f *progressFlag) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.progress, "progress", progressNone, "Print the progress of the transfers to standard error: none or json")
+	cmd.RegisterFlagCompletionFunc("progress", cobra.FixedCompletions([]string{progressNone, progressJSON}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// events returns the api.EventFunc that prints the transfer events in the format of
+// the flag, it is nil if the progress is not printed. If the format is invalid, it
+// is printed and a usage error is returned, it must be called before the command
+// does anything.
+func (f *progressFlag) events() (api.EventFunc, error) {
+	switch f.progress {
+	case progressNone:
+		return nil, nil
+	case progressJSON:
+		return jsonEvents(os.Stderr), nil
+	default:
+		fmt.Fprintf(stderr, "Invalid progress (--progress): unknown format '%s': must be none or json\n", f.progress)
+		return nil, errUsage
+	}
+}
+
+// progressEvent is an api.Event in the JSON format. The Path is the local path of
+// the file, "-" is standard output, and the Name is the name of the file on the
+// server.
+type progressEvent struct {
+	Event   api.EventKind `json:"event"`
+	Time    time.Time     `json:"time"`
+	Path    string        `json:"path,omitempty"`
+	Name    string        `json:"name,omitempty"`
+	Bytes   int64         `json:"bytes"`
+	Total   int64         `json:"total"`
+	Attempt int           `json:"attempt,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// jsonEvents returns an api.EventFunc that writes every event to w as a line of
+// JSON, see progressEvent. The progress of a file is written at most once every
+// progressInterval. It is safe for concurrent use, such as parallel downloads.
+func jsonEvents(w io.Writer) api.EventFunc {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	last := map[string]time.Time{}
+
+	return func(e api.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now()
+		key := e.Path + "\x00" + e.Name
+		switch e.Kind {
+		case api.EventProgress:
+			if now.Sub(last[key]) < progressInterval && e.Bytes < e.Total {
+				return
+			}
+			last[key] = now
+		case api.EventCompleted, api.EventFailed:
+			delete(last, key)
+		}
+
+		pe := progressEvent{
+			Event:   e.Kind,
+			Time:    now.UTC(),
+			Path:    e.Path,
+			Name:    e.Name,
+			Bytes:   e.Bytes,
+			Total:   e.Total,
+			Attempt: e.Attempt,
+		}
+		if e.Err != nil {
+			pe.Error = e.Err.Error()
+		}
+		enc.Encode(&pe)
+	}
+}
+
+// progressWriter is an io.Writer that sends the progress of the download of a file
+// as it is written to w. Create it with newProgressWriter.
+type progressWriter struct {
+	w      io.Writer
+	events api.EventFunc
+	path   string
+	name   string
+	total  int64
+	bytes  int64
+}
+
+// newProgressWriter creates a *progressWriter for the download of the file to the
+// local path. The download starts at the offset, such as a resumed download. An
+// api.EventStarted is sent if events is not nil.
+func newProgressWriter(w io.Writer, events api.EventFunc, path string, file api.File, offset int64) *progressWriter {
+	p := &progressWriter{w: w, events: events, path: path, name: file.Name, total: file.Size, bytes: offset}
+	p.emit(api.EventStarted, nil)
+
+	return p
+}
+
+// Write writes b to the underlying io.Writer and sends an api.EventProgress.
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.bytes += int64(n)
+	p.emit(api.EventProgress, nil)
+
+	return n, err
+}
+
+// finish sends an api.EventCompleted if err is nil, or an api.EventFailed with err.
+func (p *progressWriter) finish(err error) {
+	if err != nil {
+		p.emit(api.EventFailed, err)
+		return
+	}
+
+	p.emit(api.EventCompleted, nil)
+}
+
+// emit sends an event of the kind, if the events are sent.
+func (p *progressWriter) emit(kind api.EventKind, err error) {
+	if p.events == nil {
+		return
+	}
+
+	p.events(api.Event{Kind: kind, Path: p.path, Name: p.name, Bytes: p.bytes, Total: p.total, Err: err})
+}
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/hooks"
+	"github.com/cicconee/clox-cli/internal/ignore"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// The 'pull' command.
+//
+// PullCommand downloads and decrypts a directory on the Clox server, and everything
+// below it, to a local directory. The directory structure is created locally as it
+// is downloaded.
+type PullCommand struct {
+	cmd       *cobra.Command
+	user      *config.User
+	password  *secret.Bytes
+	client    *api.Client
+	store     *config.Store
+	keys      *security.Keys
+	aes       *crypto.AES
+	rsa       *crypto.RSA
+	logger    *logging.Logger
+	hooks     *hooks.Runner
+	reauth    *Reauthenticator
+	overwrite bool
+	filters   filterFlags
+	transfer  transferFlags
+}
+
+// NewPullCommand creates and returns a PullCommand.
+//
+// The overwrite flag (--overwrite) is set for the PullCommand. This flag replaces
+// the local files that already exist.
+//
+// The include (--include) and exclude (--exclude) flags are set for the
+// PullCommand. These flags are glob patterns that select the files that are
+// downloaded, both can be set more than once.
+//
+// The parallel (--parallel) and fail fast (--fail-fast) flags are set for the
+// PullCommand. These flags set how many files are downloaded at the same time, and
+// stop every download at the first file that fails.
+func NewPullCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, hooks *hooks.Runner, reauth *Reauthenticator) *PullCommand {
+	pullCmd := &PullCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger, hooks: hooks, reauth: reauth}
+
+	pullCmd.cmd = &cobra.Command{
+		Use:   "pull <remote-path|id> <local-dir>",
+		Short: "Download a directory and everything below it",
+		Args:  cobra.ExactArgs(2),
+		RunE:  pullCmd.Run,
+	}
+
+	pullCmd.cmd.Flags().BoolVar(&pullCmd.overwrite, "overwrite", false, "Replace local files that already exist")
+	pullCmd.filters.register(pullCmd.cmd)
+	pullCmd.transfer.register(pullCmd.cmd)
+
+	return pullCmd
+}
+
+// Command returns the cobra.Command of this PullCommand.
+func (c *PullCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *PullCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *PullCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *PullCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// pullSummary is the result of a pull.
+type pullSummary struct {
+	Dirs       int       `json:"dirs"`
+	Downloaded []string  `json:"downloaded"`
+	Skipped    []string  `json:"skipped"`
+	Canceled   []string  `json:"canceled"`
+	Failed     []failure `json:"failed"`
+	// files are the remote files that were downloaded.
+	files []api.File
+}
+
+// pullJob is a remote file of a pull and the local path it is written to.
+type pullJob struct {
+	file   api.File
+	output string
+}
+
+// Run is the RunE function of the cobra.Command in this PullCommand.
+//
+// Run lists the remote directory and every directory below it, and writes the
+// contents of the remote directory to the local directory. An argument that starts
+// with a '/' is a path, otherwise it is an ID. The local directory, and every
+// directory below it, is created if it does not exist.
+//
+// A local file that already exists is skipped, unless the overwrite flag
+// (--overwrite) is set. If any file fails to download, the program exits with
+// exitPartialFailure after the result is printed. The post-sync hook is run with
+// the local and remote directory, and the result is written to its standard input.
+//
+// The include (--include) and exclude (--exclude) flags are matched against the
+// path of each file relative to the remote directory. See ignore.Filter for how
+// they are combined. A directory that is excluded is not created locally.
+//
+// The files are downloaded once every local directory is created, up to the
+// parallel flag (--parallel) of them at the same time. If the fail fast flag
+// (--fail-fast) is set, the first file that fails cancels the downloads in flight
+// and the files that were not started.
+func (c *PullCommand) Run(cmd *cobra.Command, args []string) error {
+	root := argLocation(args[0])
+
+	filter, err := c.filters.filter()
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid filter (--include, --exclude):", err)
+		return errUsage
+	}
+
+	if err := c.transfer.validate(); err != nil {
+		fmt.Fprintln(stderr, "Invalid flag:", err)
+		return errUsage
+	}
+
+	localDir, err := filepath.Abs(args[1])
+	if err != nil {
+		c.logger.Error("resolving local directory", "path", args[1], "error", err)
+		return reported(err)
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	tree, err := c.client.Dirs().Tree(cmd.Context(), root, 0)
+	if err != nil {
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	summary := &pullSummary{Downloaded: []string{}, Skipped: []string{}, Canceled: []string{}, Failed: []failure{}}
+	jobs := c.pull(tree, localDir, "", filter, summary)
+	if err := c.download(cmd.Context(), c.client, jobs, encryptKey, summary); err != nil {
+		return err
+	}
+
+	c.logger.Printf("Pulled: %s -> %s\n", tree.Dir.DirPath, localDir)
+	c.logger.Printf("-> Directories: %d\n", summary.Dirs)
+	summary.print(c.logger)
+
+	trackFiles(c.store, c.aes, *c.password, c.logger, summary.files...)
+
+	if err := c.hooks.Run(hooks.PostSync, []string{localDir, tree.Dir.DirPath}, summary); err != nil {
+		c.logger.Warn("running post-sync hook", "error", err)
+	}
+
+	if len(summary.Failed) > 0 {
+		return exitWith(exitPartialFailure)
+	}
+
+	return nil
+}
+
+// pull creates the local directory dir of the node, and returns the files of the
+// node to download into it. Each of its sub directories is pulled into a local
+// directory of the same name. The rel is the path of the node relative to the
+// remote directory, the files and directories below it that are skipped by the
+// filter are not pulled.
+func (c *PullCommand) pull(node *api.TreeNode, dir string, rel string, filter *ignore.Filter, summary *pullSummary) []pullJob {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		summary.Failed = append(summary.Failed, failure{Path: node.Dir.DirPath, Err: err})
+		return nil
+	}
+	summary.Dirs++
+
+	var jobs []pullJob
+	for _, f := range node.Files {
+		if filter.Match(path.Join(rel, f.Name), false) {
+			continue
+		}
+		if !filepath.IsLocal(f.Name) {
+			summary.Failed = append(summary.Failed, failure{Path: f.Path, Err: errUnsafeName})
+			continue
+		}
+
+		output := filepath.Join(dir, f.Name)
+		if _, err := os.Lstat(output); err == nil && !c.overwrite {
+			summary.Skipped = append(summary.Skipped, output)
+			continue
+		}
+
+		jobs = append(jobs, pullJob{file: f, output: output})
+	}
+
+	for _, d := range node.Dirs {
+		sub := path.Join(rel, d.Dir.DirName)
+		if filter.Match(sub, true) {
+			continue
+		}
+		if !filepath.IsLocal(d.Dir.DirName) {
+			summary.Failed = append(summary.Failed, failure{Path: d.Dir.DirPath, Err: errUnsafeName})
+			continue
+		}
+		jobs = append(jobs, c.pull(d, filepath.Join(dir, d.Dir.DirName), sub, filter, summary)...)
+	}
+
+	return jobs
+}
+
+// download downloads the files of the jobs with the transfer flags, and adds the
+// result of each to the summary in the order of the jobs. If the API token was
+// rejected, the user is offered to enter a new one and the error is returned.
+func (c *PullCommand) download(ctx context.Context, client *api.Client, jobs []pullJob, key []byte, summary *pullSummary) error {
+	errs := c.transfer.run(ctx, len(jobs), func(ctx context.Context, i int) error {
+		return downloadFile(ctx, client.Files(), c.aes, jobs[i].file, jobs[i].output, key)
+	})
+
+	for i, job := range jobs {
+		err := errs[i]
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) && c.reauth.Handle(ctx, apiErr, c.user, *c.password) {
+			return reported(err)
+		}
+
+		switch {
+		case errors.Is(err, errCanceled):
+			summary.Canceled = append(summary.Canceled, job.file.Path)
+		case err != nil:
+			summary.Failed = append(summary.Failed, failure{Path: job.file.Path, Err: err})
+		default:
+			summary.Downloaded = append(summary.Downloaded, fmt.Sprintf("%s -> %s", job.file.Path, job.output))
+			summary.files = append(summary.files, job.file)
+		}
+	}
+
+	return nil
+}
+
+// printError prints the error of a request made by this PullCommand. If the API
+// token was rejected, the user is offered to enter a new one instead.
+func (c *PullCommand) printError(cmd *cobra.Command, err error, args []string) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] Directory: %s\n", args[0])
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Pull failed:", err)
+	}
+}
+
+// print prints this pullSummary in a human readable format.
+func (s *pullSummary) print(logger *logging.Logger) {
+	logger.Printf("\nDownloaded: %d\n", len(s.Downloaded))
+	for _, d := range s.Downloaded {
+		logger.Println(d)
+	}
+
+	if len(s.Skipped) > 0 {
+		logger.Printf("\nSkipped: %d\n", len(s.Skipped))
+		for _, p := range s.Skipped {
+			logger.Println(p)
+		}
+		logger.Println("-> [HINT] The files already exist, use --overwrite to replace them")
+	}
+
+	if len(s.Canceled) > 0 {
+		fmt.Fprintf(stdout, "\nCanceled: %d\n", len(s.Canceled))
+		for _, p := range s.Canceled {
+			fmt.Fprintln(stdout, p)
+		}
+		fmt.Fprintln(stdout, "-> [HINT] The pull stopped at the first file that failed (--fail-fast)")
+	}
+
+	if len(s.Failed) > 0 || !logger.Quiet() {
+		fmt.Fprintf(stdout, "\nErrors: %d\n", len(s.Failed))
+	}
+	for _, f := range s.Failed {
+		fmt.Fprintf(stdout, "%s -> %s\n", f.Path, f.Err)
+	}
+}
@@ -0,0 +1,430 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/hooks"
+	"github.com/cicconee/clox-cli/internal/ignore"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/partial"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/cicconee/clox-cli/internal/syncer"
+	"github.com/cicconee/clox-cli/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// The 'push' command.
+//
+// PushCommand encrypts and uploads a local directory, and everything below it, to
+// the Clox server. The directory structure is created on the server as it is
+// uploaded.
+type PushCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	hooks    *hooks.Runner
+	reauth   *Reauthenticator
+	path     string
+	id       string
+	filters  filterFlags
+	progress progressFlag
+	dryRun   bool
+}
+
+// NewPushCommand creates and returns a PushCommand.
+//
+// The path flag (-p, --path) and id flag (-i, --id) are set for the PushCommand.
+// These flags set the directory the local directory is uploaded into. If neither is
+// set, it is uploaded into the users root directory.
+//
+// The include (--include) and exclude (--exclude) flags are set for the
+// PushCommand. These flags are glob patterns that select the files that are
+// uploaded, both can be set more than once.
+//
+// The progress flag (--progress) is set for the PushCommand. This flag prints the
+// progress of every file that is uploaded to standard error, see jsonEvents.
+func NewPushCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, hooks *hooks.Runner, reauth *Reauthenticator) *PushCommand {
+	pushCmd := &PushCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger, hooks: hooks, reauth: reauth}
+
+	pushCmd.cmd = &cobra.Command{
+		Use:   "push <local-dir>",
+		Short: "Upload a local directory and everything below it",
+		Args:  cobra.ExactArgs(1),
+		RunE:  pushCmd.Run,
+	}
+
+	pushCmd.cmd.Flags().StringVarP(&pushCmd.path, "path", "p", "", "The path of the directory to upload into")
+	pushCmd.cmd.Flags().StringVarP(&pushCmd.id, "id", "i", "", "The ID of the directory to upload into")
+	pushCmd.filters.register(pushCmd.cmd)
+	pushCmd.progress.register(pushCmd.cmd)
+
+	return pushCmd
+}
+
+// Command returns the cobra.Command of this PushCommand.
+func (c *PushCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *PushCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *PushCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *PushCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+func (c *PushCommand) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// Run is the RunE function of the cobra.Command in this PushCommand.
+//
+// Run creates a directory with the name of the local directory in the directory of
+// the path or id flag, and uploads the local files into it. Every directory below the
+// local directory is created on the server, including empty ones, and the files in
+// each directory are uploaded with a single request. Directories that already exist
+// on the server are uploaded into.
+//
+// Every file that is uploaded is recorded with the SHA-256 of its contents. A local
+// file with the same hash as the last upload to the remote file at its path is not
+// uploaded again, unless the remote file changed on the server since.
+//
+// If a directory cannot be created, the files in it are skipped. If any file fails
+// or is skipped, the program exits with exitPartialFailure after the result is
+// printed. The post-sync hook is run with the local and remote directory, and the
+// result is written to its standard input.
+//
+// If the local directory has a .cloxignore file, the directories and files that
+// match its patterns are not uploaded. See ignore.Matcher for the format. The
+// include (--include) and exclude (--exclude) flags select the files further, they
+// are matched against the path of each file relative to the local directory. See
+// ignore.Filter for how they are combined.
+//
+// If the command is a dry run (--dry-run), the requests that would create the
+// directories and upload the files are printed instead of the result, and nothing
+// is recorded.
+func (c *PushCommand) Run(cmd *cobra.Command, args []string) error {
+	if c.path != "" && c.id != "" {
+		fmt.Fprintln(stderr, "Only one flag can be set: path (-p, --path) or id (-i, --id)")
+		return errUsage
+	}
+	events, err := c.progress.events()
+	if err != nil {
+		return err
+	}
+
+	localDir, err := filepath.Abs(args[0])
+	if err != nil {
+		c.logger.Error("resolving local directory", "path", args[0], "error", err)
+		return reported(err)
+	}
+	if info, err := os.Stat(localDir); err != nil || !info.IsDir() {
+		fmt.Fprintf(stderr, "Error: '%s' is not a local directory\n", args[0])
+		return reported(err)
+	}
+
+	filter, err := c.filters.filter()
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid filter (--include, --exclude):", err)
+		return errUsage
+	}
+
+	ignored, err := ignore.Load(localDir)
+	if err != nil {
+		c.logger.Error("reading ignore file", "path", filepath.Join(localDir, ignore.File), "error", err)
+		return reported(err)
+	}
+
+	tree, err := localTree(localDir, ignore.Rules{ignored, filter})
+	if err != nil {
+		c.logger.Error("reading local directory", "path", localDir, "error", err)
+		return reported(err)
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	parent, err := c.client.Dirs().List(cmd.Context(), location(c.path, c.id))
+	if err != nil {
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	root := path.Join(parent.Dir.DirPath, filepath.Base(localDir))
+	dirs := newDirMaker(cmd.Context(), c.client, parent.Dir)
+	rootID, err := dirs.Ensure(root)
+	if err != nil {
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) {
+			err = apiErr
+		}
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	tracker, err := loadTracker(c.store, c.aes, *c.password)
+	if err != nil {
+		c.logger.Error("loading tracked files", "error", err)
+		return reported(err)
+	}
+	remote, err := c.remote(cmd, c.client, rootID, len(dirs.created) > 0)
+	if err != nil {
+		return err
+	}
+
+	summary := uploadSummary{
+		Uploaded:  []api.UploadFileResponse{},
+		Failed:    []api.UploadErrorResponse{},
+		Skipped:   []string{},
+		Unchanged: []string{},
+	}
+	// hashes are the SHA-256 of the uploaded files, keyed by their remote path.
+	hashes := map[string]string{}
+
+	rels := []string{}
+	for rel := range tree {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	for _, rel := range rels {
+		uploads := tree[rel]
+		dirPath := path.Join(root, rel)
+
+		id, err := dirs.Ensure(dirPath)
+		if err != nil {
+			if err := c.reauthOrContinue(cmd, err); err != nil {
+				return err
+			}
+			summary.Failed = append(summary.Failed, api.UploadErrorResponse{FileName: dirPath, Error: err.Error()})
+			for _, u := range uploads {
+				summary.Skipped = append(summary.Skipped, u.Path)
+			}
+			continue
+		}
+
+		uploads = c.changed(uploads, rel, dirPath, remote, tracker, hashes, &summary)
+		if len(uploads) == 0 {
+			continue
+		}
+
+		dir := api.ID(id)
+		if c.dryRun {
+			dir = api.Path(dirPath)
+		}
+		res, err := c.client.Uploads().Create(cmd.Context(), dir, api.UploadParams{
+			Uploads: uploads,
+			Key:     encryptKey,
+			Alg:     &crypto.ChunkedAES{AES: c.aes},
+			Events:  events,
+		})
+		if err != nil {
+			if err := c.reauthOrContinue(cmd, err); err != nil {
+				return err
+			}
+			for _, u := range uploads {
+				summary.Failed = append(summary.Failed, api.UploadErrorResponse{
+					FileName: path.Join(dirPath, u.Filename),
+					Error:    err.Error(),
+				})
+			}
+			continue
+		}
+
+		if c.dryRun {
+			printDryRunUploads(dirPath, uploads)
+			continue
+		}
+
+		summary.Uploaded = append(summary.Uploaded, res.Uploads...)
+		for _, e := range res.Errors {
+			e.FileName = path.Join(dirPath, e.FileName)
+			summary.Failed = append(summary.Failed, e)
+		}
+	}
+
+	if c.dryRun {
+		return nil
+	}
+
+	c.logger.Printf("Pushed: %s -> %s\n", localDir, root)
+	c.logger.Printf("-> Directories Created: %d\n", len(dirs.created))
+	summary.print(c.logger)
+
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		for _, d := range dirs.created {
+			idx.Add(index.DirEntry(d))
+		}
+		for _, u := range summary.Uploaded {
+			idx.Add(index.UploadEntry(u))
+		}
+	})
+	for _, u := range summary.Uploaded {
+		tracker.Track(u.ID, u.Path, tracking.LastWrite(u.File()), u.ETag)
+		tracker.SetHash(u.ID, hashes[u.Path])
+	}
+	if err := tracker.Save(c.store.File(trackingFile), c.aes, *c.password); err != nil {
+		c.logger.Warn("saving tracked files", "error", err)
+	}
+
+	if err := c.hooks.Run(hooks.PostSync, []string{localDir, root}, summary); err != nil {
+		c.logger.Warn("running post-sync hook", "error", err)
+	}
+
+	if summary.partial() {
+		return exitWith(exitPartialFailure)
+	}
+
+	return nil
+}
+
+// remote lists the remote directory with the id and everything below it, to find
+// the files that are already uploaded. If created is set, the directory was just
+// created and is empty. If it cannot be listed, it is logged and every file is
+// uploaded. An error is only returned if the API token was rejected.
+func (c *PushCommand) remote(cmd *cobra.Command, client *api.Client, id string, created bool) (*syncer.Remote, error) {
+	empty := &syncer.Remote{Dirs: map[string]api.Dir{}, Files: map[string]api.File{}}
+	if created {
+		return empty, nil
+	}
+
+	tree, err := client.Dirs().Tree(cmd.Context(), api.ID(id), 0)
+	if err != nil {
+		if err := c.reauthOrContinue(cmd, err); err != nil {
+			return nil, err
+		}
+		c.logger.Warn("listing remote directory", "id", id, "error", err)
+		return empty, nil
+	}
+
+	return syncer.NewRemote(tree, ignore.Rules{}), nil
+}
+
+// changed returns the uploads of the local directory rel that would change the
+// files on the server. The SHA-256 of each upload is added to hashes by the remote
+// path it is uploaded to, in the remote directory at dirPath. An upload with the
+// same hash as the last upload to the remote file, which has not changed on the
+// server since, is added to the unchanged files of the summary instead.
+func (c *PushCommand) changed(uploads []api.FileUpload, rel string, dirPath string, remote *syncer.Remote, tracker *tracking.Tracker, hashes map[string]string, summary *uploadSummary) []api.FileUpload {
+	changed := []api.FileUpload{}
+	for _, u := range uploads {
+		hash, err := tracking.HashFile(u.Path)
+		if err != nil {
+			c.logger.Warn("hashing local file", "path", u.Path, "error", err)
+			changed = append(changed, u)
+			continue
+		}
+
+		if f, ok := remote.Files[path.Join(rel, u.Filename)]; ok && tracker.Unchanged(f, hash) {
+			summary.Unchanged = append(summary.Unchanged, u.Path)
+			continue
+		}
+
+		hashes[path.Join(dirPath, u.Filename)] = hash
+		changed = append(changed, u)
+	}
+
+	return changed
+}
+
+// reauthOrContinue checks if the err is an API token that was rejected. If it is,
+// the user is offered to enter a new one and an error is returned, the push must
+// stop, nothing else can be uploaded with the token.
+func (c *PushCommand) reauthOrContinue(cmd *cobra.Command, err error) error {
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) && c.reauth.Handle(cmd.Context(), apiErr, c.user, *c.password) {
+		return reported(err)
+	}
+
+	return nil
+}
+
+// printError prints the error of a request made by this PushCommand. If the API
+// token was rejected, the user is offered to enter a new one instead.
+func (c *PushCommand) printError(cmd *cobra.Command, err error, args []string) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] Directory: %s\n", args[0])
+		if c.id != "" {
+			fmt.Fprintf(stderr, "-> [FLAG] ID: %s\n", c.id)
+		} else if c.path != "" {
+			fmt.Fprintf(stderr, "-> [FLAG] Path: %s\n", c.path)
+		}
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Push failed:", err)
+	}
+}
+
+// localTree walks the local directory at root and returns the files to upload in
+// each directory below it, keyed by the path of the directory relative to root and
+// separated by slashes. The root is keyed by ".". Every directory is in the tree,
+// even if it has no files. Partial downloads, files that are not regular files, such
+// as symbolic links, and the directories and files skipped by the rule are left
+// out.
+func localTree(root string, skip ignore.Rule) (map[string][]api.FileUpload, error) {
+	tree := map[string][]api.FileUpload{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." && skip.Match(rel, true) {
+				return filepath.SkipDir
+			}
+			if _, ok := tree[rel]; !ok {
+				tree[rel] = []api.FileUpload{}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || partial.IsPartial(d.Name()) || skip.Match(rel, false) {
+			return nil
+		}
+
+		dir := path.Dir(rel)
+		tree[dir] = append(tree[dir], api.FileUpload{Path: p, Filename: d.Name()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
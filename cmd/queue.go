@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/queue"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// queueDir is the name of the upload queue directory of a profile.
+const queueDir = "queue"
+
+// The 'queue' command.
+//
+// QueueCommand groups the sub commands that manage the offline upload queue. It
+// does nothing on its own.
+type QueueCommand struct {
+	cmd *cobra.Command
+}
+
+// NewQueueCommand creates and returns a QueueCommand.
+func NewQueueCommand() *QueueCommand {
+	return &QueueCommand{
+		cmd: &cobra.Command{
+			Use:   "queue",
+			Short: "Manage uploads queued while the server was unreachable",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this QueueCommand.
+func (c *QueueCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'queue list' command.
+//
+// QueueListCommand prints the uploads in the queue.
+type QueueListCommand struct {
+	cmd    *cobra.Command
+	store  *config.Store
+	logger *logging.Logger
+	format formatFlag
+}
+
+// NewQueueListCommand creates and returns a QueueListCommand.
+//
+// The format flag (--format) is set for the QueueListCommand. This flag sets the
+// format the queued uploads are printed in.
+func NewQueueListCommand(store *config.Store, logger *logging.Logger) *QueueListCommand {
+	listCmd := &QueueListCommand{store: store, logger: logger}
+
+	listCmd.cmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the queued uploads",
+		Args:  cobra.ExactArgs(0),
+		RunE:  listCmd.Run,
+	}
+
+	listCmd.format.register(listCmd.cmd)
+
+	return listCmd
+}
+
+// Command returns the cobra.Command of this QueueListCommand.
+func (c *QueueListCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this QueueListCommand.
+//
+// Run prints the ID, local path, and destination of every queued upload.
+func (c *QueueListCommand) Run(cmd *cobra.Command, args []string) error {
+	r, err := c.format.renderer()
+	if err != nil {
+		return err
+	}
+
+	q := &queue.Queue{Dir: c.store.File(queueDir)}
+	items, err := q.Items()
+	if err != nil {
+		c.logger.Error("reading queue", "error", err)
+		return reported(err)
+	}
+
+	return render(r, queueList(items), c.logger)
+}
+
+// queueList is the result of the 'queue list' command.
+type queueList []queue.Item
+
+// Text writes the number of queued uploads, followed by the ID, local path, and
+// destination of every queued upload, one upload per line.
+func (l queueList) Text(w io.Writer) error {
+	fmt.Fprintf(w, "Queued: %d\n", len(l))
+	for _, item := range l {
+		_, err := fmt.Fprintf(w, "%s -> %s [%s/%s] (%s)\n",
+			item.ID,
+			item.Source,
+			item.Dir,
+			item.Filename,
+			item.QueuedAt.Local().Format(time.DateTime))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Table returns a row for every queued upload, with its ID, local path, destination,
+// and the time it was queued.
+func (l queueList) Table() ([]string, [][]string) {
+	rows := make([][]string, len(l))
+	for i, item := range l {
+		rows[i] = []string{item.ID, item.Source, fmt.Sprintf("%s/%s", item.Dir, item.Filename), item.QueuedAt.Local().Format(time.DateTime)}
+	}
+
+	return []string{"ID", "SOURCE", "DESTINATION", "QUEUED"}, rows
+}
+
+// The 'queue flush' command.
+//
+// QueueFlushCommand uploads every queued upload to the server.
+type QueueFlushCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	aes      *crypto.AES
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+}
+
+// NewQueueFlushCommand creates and returns a QueueFlushCommand.
+func NewQueueFlushCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger, reauth *Reauthenticator) *QueueFlushCommand {
+	flushCmd := &QueueFlushCommand{store: store, aes: aes, logger: logger, reauth: reauth}
+
+	flushCmd.cmd = &cobra.Command{
+		Use:   "flush",
+		Short: "Upload the queued files",
+		Args:  cobra.ExactArgs(0),
+		RunE:  flushCmd.Run,
+	}
+
+	return flushCmd
+}
+
+// Command returns the cobra.Command of this QueueFlushCommand.
+func (c *QueueFlushCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *QueueFlushCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *QueueFlushCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *QueueFlushCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this QueueFlushCommand.
+//
+// Run uploads the queued files, grouped by their destination directory. The files
+// are already encrypted and are uploaded as is. Every file the server accepts is
+// removed from the queue. A file the server rejects stays in the queue and its
+// error is printed.
+//
+// If the server is still unreachable, the queue is left unchanged. If any file
+// fails, the program exits with exitPartialFailure.
+func (c *QueueFlushCommand) Run(cmd *cobra.Command, args []string) error {
+	q := &queue.Queue{Dir: c.store.File(queueDir)}
+	items, err := q.Items()
+	if err != nil {
+		c.logger.Error("reading queue", "error", err)
+		return reported(err)
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(stdout, "Queue is empty")
+		return nil
+	}
+
+	// Group the items by destination, so each directory is a single request.
+	groups := map[api.Location][]queue.Item{}
+	order := []api.Location{}
+	for _, item := range items {
+		if _, ok := groups[item.Dir]; !ok {
+			order = append(order, item.Dir)
+		}
+		groups[item.Dir] = append(groups[item.Dir], item)
+	}
+
+	summary := uploadSummary{
+		Uploaded: []api.UploadFileResponse{},
+		Failed:   []api.UploadErrorResponse{},
+		Skipped:  []string{},
+	}
+	for _, dir := range order {
+		group := groups[dir]
+		uploads := []api.FileUpload{}
+		for _, item := range group {
+			uploads = append(uploads, api.FileUpload{
+				Path:        item.Payload(),
+				Filename:    item.Filename,
+				ContentType: item.ContentType,
+				Checksum:    item.Checksum,
+				Encrypted:   true,
+			})
+		}
+
+		res, err := c.client.Uploads().Create(cmd.Context(), dir, api.UploadParams{Uploads: uploads})
+		if err != nil {
+			if errors.Is(err, api.ErrUnreachable) {
+				fmt.Fprintln(stderr, "Server unreachable, the queue was not flushed")
+				return reported(err)
+			}
+			if c.reauth.Handle(cmd.Context(), err, c.user, *c.password) {
+				return reported(err)
+			}
+
+			for _, item := range group {
+				summary.Failed = append(summary.Failed, api.UploadErrorResponse{FileName: item.Filename, Error: err.Error()})
+			}
+			continue
+		}
+
+		failed := map[string]bool{}
+		for _, e := range res.Errors {
+			failed[e.FileName] = true
+		}
+		for _, item := range group {
+			if failed[item.Filename] {
+				continue
+			}
+			if err := q.Remove(item); err != nil {
+				c.logger.Warn("removing item from queue", "id", item.ID, "error", err)
+			}
+		}
+
+		summary.Uploaded = append(summary.Uploaded, res.Uploads...)
+		summary.Failed = append(summary.Failed, res.Errors...)
+	}
+
+	summary.print(c.logger)
+
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		for _, u := range summary.Uploaded {
+			idx.Add(index.UploadEntry(u))
+		}
+	})
+	trackUploads(c.store, c.aes, *c.password, c.logger, summary.Uploaded)
+
+	if summary.partial() {
+		return exitWith(exitPartialFailure)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+)
+
+// Reauthenticator renews the API token when the server rejects it. Commands that
+// call the API use a Reauthenticator to explain an expired or revoked token and
+// offer to replace it, instead of printing a generic API error.
+type Reauthenticator struct {
+	store  *config.Store
+	aes    *crypto.AES
+	logger *logging.Logger
+}
+
+// NewReauthenticator creates and returns a *Reauthenticator.
+func NewReauthenticator(store *config.Store, aes *crypto.AES, logger *logging.Logger) *Reauthenticator {
+	return &Reauthenticator{store: store, aes: aes, logger: logger}
+}
+
+// Handle checks if err is the API rejecting the token. If it is not, Handle does
+// nothing and returns false.
+//
+// If the token was rejected, the situation is explained and the user is asked to
+// enter a new API token. The new token is verified with the server, encrypted with
+// the password, and written to the configuration file of the user. Handle returns
+// true whether or not the token was replaced, the caller should stop.
+func (r *Reauthenticator) Handle(ctx context.Context, err error, user *config.User, password []byte) bool {
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if !errors.Is(err, api.ErrUnauthorized) && !errors.Is(err, api.ErrTokenRevoked) {
+		return false
+	}
+
+	fmt.Fprintf(stderr, "API Error [%d]: %s\n", apiErr.StatusCode, apiErr.Err)
+	fmt.Fprintln(stderr, "The server rejected the API token, it has expired or been revoked")
+	if !prompt.Confirm("Enter a new API token now?") {
+		fmt.Fprintln(stderr, "Run 'clox init -f' to re-authenticate")
+		return true
+	}
+
+	token, err := prompt.ConfigureAPIToken()
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot read the API token:", err)
+		return true
+	}
+	defer token.Wipe()
+	client, err := newUserAPIClient(r.store, user, token, r.logger)
+	if err != nil {
+		r.logger.Error("creating api client", "error", err)
+		return true
+	}
+	if _, err := client.Tokens().Verify(ctx); err != nil {
+		fmt.Fprintln(stderr, "The new API token was rejected:", err)
+		return true
+	}
+
+	if err := user.SetAPIToken(r.aes, password, token); err != nil {
+		r.logger.Error("encrypting api token", "error", err)
+		return true
+	}
+	if err := r.store.WriteConfigFile(user); err != nil {
+		r.logger.Error("writing config file", "error", err)
+		return true
+	}
+
+	fmt.Fprintln(stderr, "API token updated, run the command again")
+	return true
+}
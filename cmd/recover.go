@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cicconee/clox-cli/internal/biometric"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/keyring"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/cicconee/clox-cli/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// printRecoveryCodes prints the recovery codes of a profile, with a hint to store
+// them offline. They are not shown again.
+func printRecoveryCodes(codes []string) {
+	fmt.Fprintf(stdout, "\nRecovery codes: %d\n", len(codes))
+	for _, code := range codes {
+		fmt.Fprintf(stdout, "  %s\n", code)
+	}
+	fmt.Fprintln(stdout, "-> [HINT] Store the codes offline, they are not shown again. Each code can be used once with 'clox recover' if the password is lost")
+}
+
+// The 'recover' command.
+//
+// RecoverCommand sets a new password for the active profile with a recovery code,
+// when the password is lost. The files already uploaded can still be decrypted.
+type RecoverCommand struct {
+	cmd       *cobra.Command
+	store     *config.Store
+	keys      *security.Keys
+	aes       *crypto.AES
+	rsa       *crypto.RSA
+	biometric biometric.Provider
+	keyring   keyring.Keyring
+	logger    *logging.Logger
+}
+
+// NewRecoverCommand creates and returns a RecoverCommand.
+//
+// The biometric provider and keyring are updated with the new password if the
+// active profile is enrolled in them.
+func NewRecoverCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, provider biometric.Provider, ring keyring.Keyring, logger *logging.Logger) *RecoverCommand {
+	recoverCmd := &RecoverCommand{
+		store:     store,
+		keys:      keys,
+		aes:       aes,
+		rsa:       rsa,
+		biometric: provider,
+		keyring:   ring,
+		logger:    logger,
+	}
+
+	recoverCmd.cmd = &cobra.Command{
+		Use:   "recover",
+		Short: "Set a new password with a recovery code",
+		Args:  cobra.ExactArgs(0),
+		RunE:  recoverCmd.Run,
+	}
+
+	return recoverCmd
+}
+
+// Command returns the cobra.Command of this RecoverCommand.
+func (c *RecoverCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this RecoverCommand.
+//
+// Run prompts for a recovery code, the new password, and the API token, the API
+// token was encrypted with the lost password. If CLOX_API_TOKEN is set, it is
+// stored instead of prompting for a token. The password is set with the code, see
+// config.User.Recover, and the code cannot be used again. The configuration file is
+// copied to a backup before it is rewritten atomically.
+//
+// The encrypted files of the profile, such as the index and the tracked files,
+// cannot be decrypted without the lost password, they are removed and recreated by
+// the commands that use them. The session of the profile is ended. If the password
+// is stored in the keyring or for biometric unlock, it is replaced with the new
+// password.
+func (c *RecoverCommand) Run(cmd *cobra.Command, args []string) error {
+	user := &config.User{}
+	if err := c.store.ReadConfigFile(user); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(stderr, "Clox CLI not configured")
+			fmt.Fprintln(stderr, "Run 'clox init' to configure the CLI")
+			return reported(nil)
+		}
+
+		c.logger.Error("reading config file", "error", err)
+		return reported(err)
+	}
+	if user.RecoveryCodes() == 0 {
+		fmt.Fprintf(stderr, "Profile '%s' has no unused recovery codes\n", c.store.Profile)
+		fmt.Fprintln(stderr, "-> [HINT] Run 'clox init -f' to configure the profile again, files uploaded with it cannot be decrypted")
+		return reported(nil)
+	}
+	if k := user.KDF(); k != (crypto.KDF{}) {
+		if err := k.Validate(); err != nil {
+			c.logger.Error("reading config file", "error", err)
+			return reported(err)
+		}
+		c.aes.KDF = k
+	}
+
+	code, err := prompt.Secret("Recovery Code")
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot read the recovery code:", err)
+		return reported(err)
+	}
+	defer code.Wipe()
+	newPassword, err := prompt.NewPassword()
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot read the new password:", err)
+		return reported(err)
+	}
+	defer newPassword.Wipe()
+	token := secret.FromString(os.Getenv(envAPIToken))
+	if len(token) == 0 {
+		token, err = prompt.ConfigureAPIToken()
+		if err != nil {
+			fmt.Fprintln(stderr, "Cannot read the API token:", err)
+			fmt.Fprintf(stderr, "-> [HINT] Set the API token with %s\n", envAPIToken)
+			return reported(err)
+		}
+	}
+	defer token.Wipe()
+
+	err = user.Recover(c.keys, c.aes, c.rsa, code, newPassword, token)
+	if errors.Is(err, config.ErrInvalidRecoveryCode) {
+		fmt.Fprintln(stderr, "Invalid recovery code")
+		fmt.Fprintln(stderr, "-> [HINT] A code can only be used once")
+		return errUsage
+	}
+	if err != nil {
+		c.logger.Error("recovering profile", "error", err)
+		return reported(err)
+	}
+
+	backup, err := c.store.BackupConfigFile("recover-" + time.Now().Format("20060102T150405"))
+	if err != nil {
+		c.logger.Error("backing up config file", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	for _, name := range encryptedFiles {
+		path := c.store.File(name)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("removing file encrypted with the lost password", "path", path, "error", err)
+		}
+	}
+
+	if err := session.Remove(session.Path(c.store.Path, c.store.Profile), session.KeyPath(c.store.Dir())); err != nil {
+		c.logger.Warn("removing session", "error", err)
+	}
+	if stored, err := c.keyring.Get(c.store.Account()); err == nil {
+		stored.Wipe()
+		if err := c.keyring.Set(c.store.Account(), newPassword); err != nil {
+			c.logger.Warn("storing new password in keyring, run 'clox keyring enable' again", "error", err)
+		}
+	}
+	if c.biometric.Enrolled(c.store.Account()) {
+		if err := c.biometric.Store(c.store.Account(), newPassword); err != nil {
+			c.logger.Warn("storing new password in keystore, run 'clox biometric enable' again", "error", err)
+		}
+	}
+
+	c.logger.Printf("Password recovered for profile '%s'\n", c.store.Profile)
+	c.logger.Printf("Recovery codes left: %d\n", user.RecoveryCodes())
+	fmt.Fprintf(stdout, "-> [HINT] The previous configuration was saved to %s\n", backup)
+	if user.RecoveryCodes() < 2 {
+		fmt.Fprintln(stdout, "-> [HINT] Run 'clox keys recovery-codes' to generate new recovery codes")
+	}
+
+	return nil
+}
+
+// The 'keys recovery-codes' command.
+//
+// KeysRecoveryCodesCommand generates new recovery codes for the active profile, the
+// codes generated before can no longer be used.
+type KeysRecoveryCodesCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+}
+
+// NewKeysRecoveryCodesCommand creates and returns a KeysRecoveryCodesCommand.
+func NewKeysRecoveryCodesCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger) *KeysRecoveryCodesCommand {
+	codesCmd := &KeysRecoveryCodesCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger}
+
+	codesCmd.cmd = &cobra.Command{
+		Use:   "recovery-codes",
+		Short: "Generate new recovery codes",
+		Args:  cobra.ExactArgs(0),
+		RunE:  codesCmd.Run,
+	}
+
+	return codesCmd
+}
+
+// Command returns the cobra.Command of this KeysRecoveryCodesCommand.
+func (c *KeysRecoveryCodesCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *KeysRecoveryCodesCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *KeysRecoveryCodesCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this KeysRecoveryCodesCommand.
+//
+// Run replaces the recovery codes of the active profile, see
+// config.User.GenerateRecoveryCodes, and prints the new codes once. If the profile
+// has unused recovery codes, the user must confirm they are replaced.
+func (c *KeysRecoveryCodesCommand) Run(cmd *cobra.Command, args []string) error {
+	if n := c.user.RecoveryCodes(); n > 0 {
+		fmt.Fprintf(stdout, "Profile '%s' has %d unused recovery codes, they can no longer be used once replaced\n", c.store.Profile, n)
+		if !prompt.Confirm("Generate new recovery codes?") {
+			return reported(nil)
+		}
+	}
+
+	codes, err := c.user.GenerateRecoveryCodes(c.keys, c.aes, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("generating recovery codes", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Recovery codes generated for profile '%s'\n", c.store.Profile)
+	printRecoveryCodes(codes)
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+)
+
+// errNotFound is the error when the server has neither a file nor a directory at
+// a target.
+var errNotFound = errors.New("no such file or directory")
+
+// argLocation returns the api.Location of a file or directory argument. An argument
+// that starts with a '/' is a path, otherwise it is an ID.
+func argLocation(arg string) api.Location {
+	if strings.HasPrefix(arg, "/") {
+		return api.Path(arg)
+	}
+
+	return api.ID(arg)
+}
+
+// resolveTarget finds the file or directory at the target. The target is a file if
+// the server has a file at it, and the file is returned. Otherwise the directory at
+// the target is listed, and its listing is returned. If the server has neither, it
+// returns errNotFound.
+func resolveTarget(ctx context.Context, client *api.Client, target api.Location) (*api.File, *api.DirListing, error) {
+	file, err := client.Files().Get(ctx, target)
+	if err == nil {
+		return file, nil, nil
+	}
+
+	if !errors.Is(err, api.ErrNotFound) {
+		return nil, nil, err
+	}
+
+	listing, err := client.Dirs().List(ctx, target)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, nil, errNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, listing, nil
+}
+
+// moveLocal changes the path of the directory or file at oldPath, and of everything
+// below it, to be at newPath in the local index and the tracked files. A failed
+// update is logged and never fails the command.
+func moveLocal(store *config.Store, aes *crypto.AES, password []byte, logger *logging.Logger, oldPath string, newPath string) {
+	updateIndex(store, aes, password, logger, func(idx *index.Index) {
+		idx.Move(oldPath, newPath)
+	})
+
+	t, err := loadTracker(store, aes, password)
+	if err != nil {
+		logger.Warn("loading tracked files", "error", err)
+		return
+	}
+
+	t.Move(oldPath, newPath)
+	if err := t.Save(store.File(trackingFile), aes, password); err != nil {
+		logger.Warn("saving tracked files", "error", err)
+	}
+}
+
+// dirMaker creates remote directories by path. It remembers the ID of every
+// directory that it created or was told about, so each directory is only created
+// once.
+type dirMaker struct {
+	ctx    context.Context
+	client *api.Client
+	// ids maps the path of each remote directory that exists to its ID.
+	ids map[string]string
+	// created are the directories that were created.
+	created []api.Dir
+}
+
+// newDirMaker creates and returns a dirMaker that knows about the dirs.
+func newDirMaker(ctx context.Context, client *api.Client, dirs ...api.Dir) *dirMaker {
+	m := &dirMaker{ctx: ctx, client: client, ids: map[string]string{}, created: []api.Dir{}}
+	for _, d := range dirs {
+		m.ids[d.DirPath] = d.ID
+	}
+
+	return m
+}
+
+// Ensure returns the ID of the remote directory at the path. If the directory does
+// not exist, it is created, with any parent directory that is missing.
+func (m *dirMaker) Ensure(dirPath string) (string, error) {
+	if id, ok := m.ids[dirPath]; ok {
+		return id, nil
+	}
+
+	parent := api.Path("")
+	if p := path.Dir(dirPath); p != "/" {
+		id, err := m.Ensure(p)
+		if err != nil {
+			return "", err
+		}
+		parent = api.ID(id)
+		if id == "" {
+			// A dry run does not know the IDs of the directories it would create.
+			parent = api.Path(p)
+		}
+	}
+
+	dir, err := m.client.Dirs().Create(m.ctx, parent, path.Base(dirPath))
+	if errors.Is(err, api.ErrNameConflict) {
+		// The directory already exists, but was not known.
+		var listing *api.DirListing
+		listing, err = m.client.Dirs().List(m.ctx, api.Path(dirPath))
+		if err == nil {
+			dir = &listing.Dir
+		}
+	} else if err == nil {
+		m.created = append(m.created, *dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("creating directory '%s': %w", dirPath, err)
+	}
+
+	m.ids[dirPath] = dir.ID
+	return dir.ID, nil
+}
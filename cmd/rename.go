@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// The 'rename' command.
+//
+// RenameCommand renames a file or directory on the server, in the directory it is
+// in.
+type RenameCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	aes      *crypto.AES
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	id       string
+}
+
+// NewRenameCommand creates and returns a RenameCommand.
+//
+// The id flag (-i, --id) is set for the RenameCommand. This flag allows users to
+// rename a file or directory by its ID instead of its path.
+func NewRenameCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger, reauth *Reauthenticator) *RenameCommand {
+	renameCmd := &RenameCommand{store: store, aes: aes, logger: logger, reauth: reauth}
+
+	renameCmd.cmd = &cobra.Command{
+		Use:   "rename [<target>] <new-name>",
+		Short: "Rename a file or directory on the server",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE:  renameCmd.Run,
+	}
+
+	renameCmd.cmd.Flags().StringVarP(&renameCmd.id, "id", "i", "", "The ID of the file or directory to rename")
+
+	return renameCmd
+}
+
+// Command returns the cobra.Command of this RenameCommand.
+func (c *RenameCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *RenameCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *RenameCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *RenameCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this RenameCommand.
+//
+// Run gives the target the new name, the last argument. The target is a file if the
+// server has a file at it, otherwise it is a directory. If a file or directory
+// already has the new name, nothing is renamed.
+//
+// The local index and the tracked files are updated with the new path.
+func (c *RenameCommand) Run(cmd *cobra.Command, args []string) error {
+	name := args[len(args)-1]
+	target, err := fileLocation(args[:len(args)-1], c.id)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return errUsage
+	}
+	if !target.IsID() {
+		target = api.Path("/" + strings.Trim(target.Path, "/"))
+	}
+
+	if err := validateName(name); err != nil {
+		fmt.Fprintln(stderr, "Invalid name:", err)
+		return errUsage
+	}
+
+	file, listing, err := resolveTarget(cmd.Context(), c.client, target)
+	if err != nil {
+		c.printError(cmd, err, target, name, "")
+		return reported(err)
+	}
+
+	var id, oldPath, newPath string
+	if file != nil {
+		renamed, err := c.client.Files().Rename(cmd.Context(), api.ID(file.ID), name)
+		if err != nil {
+			c.printError(cmd, err, target, name, file.Path)
+			return reported(err)
+		}
+		id, oldPath, newPath = renamed.ID, file.Path, renamed.Path
+	} else {
+		if listing.Dir.DirPath == "/" {
+			fmt.Fprintln(stderr, "Error: the root directory cannot be renamed")
+			return reported(nil)
+		}
+
+		renamed, err := c.client.Dirs().Rename(cmd.Context(), api.ID(listing.Dir.ID), name)
+		if err != nil {
+			c.printError(cmd, err, target, name, listing.Dir.DirPath)
+			return reported(err)
+		}
+		id, oldPath, newPath = renamed.ID, listing.Dir.DirPath, renamed.DirPath
+	}
+
+	moveLocal(c.store, c.aes, *c.password, c.logger, oldPath, newPath)
+
+	c.logger.Printf("Renamed: %s -> %s\n", oldPath, newPath)
+	c.logger.Printf("-> ID: %s\n", id)
+
+	return nil
+}
+
+// printError prints the error of a request made by this RenameCommand. The oldPath
+// is the path of the target, if it was found. A name conflict is explained with the
+// path that is already taken. If the API token was rejected, the user is offered
+// to enter a new one instead.
+func (c *RenameCommand) printError(cmd *cobra.Command, err error, target api.Location, name string, oldPath string) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		if errors.Is(e, api.ErrNameConflict) && oldPath != "" {
+			fmt.Fprintf(stderr, "Conflict: %s\n", path.Join(path.Dir(oldPath), name))
+			fmt.Fprintf(stderr, "-> [REASON] A file or directory named '%s' already exists in %s\n", name, path.Dir(oldPath))
+			fmt.Fprintln(stderr, "-> [HINT] Choose another name, or move or delete the existing one first")
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] Target: %s\n", target)
+		fmt.Fprintf(stderr, "-> [ARGS] Name: %s\n", name)
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Rename failed:", err)
+	}
+}
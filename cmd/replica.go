@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// validateServerURL validates that the raw URL is the base URL of a Clox server. It
+// must be an absolute http or https URL. The URL is returned without a trailing
+// slash.
+func validateServerURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("'%s' must be an http or https URL", raw)
+	}
+
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// The 'replica' command.
+//
+// ReplicaCommand groups the sub commands that manage the replica servers of a
+// profile. It does nothing on its own.
+type ReplicaCommand struct {
+	cmd *cobra.Command
+}
+
+// NewReplicaCommand creates and returns a ReplicaCommand.
+func NewReplicaCommand() *ReplicaCommand {
+	return &ReplicaCommand{
+		cmd: &cobra.Command{
+			Use:   "replica",
+			Short: "Manage the replica servers that reads fail over to",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this ReplicaCommand.
+func (c *ReplicaCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'replica list' command.
+//
+// ReplicaListCommand prints the replica servers of the active profile in the order
+// they are tried.
+type ReplicaListCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+}
+
+// NewReplicaListCommand creates and returns a ReplicaListCommand.
+func NewReplicaListCommand(store *config.Store) *ReplicaListCommand {
+	listCmd := &ReplicaListCommand{store: store}
+
+	listCmd.cmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the replica servers",
+		Args:  cobra.ExactArgs(0),
+		RunE:  listCmd.Run,
+	}
+
+	return listCmd
+}
+
+// Command returns the cobra.Command of this ReplicaListCommand.
+func (c *ReplicaListCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ReplicaListCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *ReplicaListCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this ReplicaListCommand.
+func (c *ReplicaListCommand) Run(cmd *cobra.Command, args []string) error {
+	fmt.Fprintf(stdout, "Primary: %s\n", serverURL(c.store, c.user))
+
+	replicas := c.user.Replicas()
+	fmt.Fprintf(stdout, "\nReplicas: %d\n", len(replicas))
+	for i, r := range replicas {
+		fmt.Fprintf(stdout, "%d -> %s\n", i+1, r)
+	}
+
+	return nil
+}
+
+// The 'replica add' command.
+//
+// ReplicaAddCommand adds a replica server to the active profile.
+type ReplicaAddCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	logger   *logging.Logger
+}
+
+// NewReplicaAddCommand creates and returns a ReplicaAddCommand.
+func NewReplicaAddCommand(store *config.Store, logger *logging.Logger) *ReplicaAddCommand {
+	addCmd := &ReplicaAddCommand{store: store, logger: logger}
+
+	addCmd.cmd = &cobra.Command{
+		Use:   "add <url>",
+		Short: "Add a replica server",
+		Args:  cobra.ExactArgs(1),
+		RunE:  addCmd.Run,
+	}
+
+	return addCmd
+}
+
+// Command returns the cobra.Command of this ReplicaAddCommand.
+func (c *ReplicaAddCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ReplicaAddCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *ReplicaAddCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this ReplicaAddCommand.
+//
+// Run adds the URL to the replicas of the active profile and writes the
+// configuration file. Replicas are tried in the order they are added.
+func (c *ReplicaAddCommand) Run(cmd *cobra.Command, args []string) error {
+	u, err := validateServerURL(args[0])
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid URL:", err)
+		return errUsage
+	}
+
+	if !c.user.AddReplica(u) {
+		fmt.Fprintf(stdout, "Replica '%s' already added\n", u)
+		return nil
+	}
+
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Replica '%s' added\n", u)
+
+	return nil
+}
+
+// The 'replica remove' command.
+//
+// ReplicaRemoveCommand removes a replica server from the active profile.
+type ReplicaRemoveCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	logger   *logging.Logger
+}
+
+// NewReplicaRemoveCommand creates and returns a ReplicaRemoveCommand.
+func NewReplicaRemoveCommand(store *config.Store, logger *logging.Logger) *ReplicaRemoveCommand {
+	removeCmd := &ReplicaRemoveCommand{store: store, logger: logger}
+
+	removeCmd.cmd = &cobra.Command{
+		Use:   "remove <url>",
+		Short: "Remove a replica server",
+		Args:  cobra.ExactArgs(1),
+		RunE:  removeCmd.Run,
+	}
+
+	return removeCmd
+}
+
+// Command returns the cobra.Command of this ReplicaRemoveCommand.
+func (c *ReplicaRemoveCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ReplicaRemoveCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *ReplicaRemoveCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this ReplicaRemoveCommand.
+func (c *ReplicaRemoveCommand) Run(cmd *cobra.Command, args []string) error {
+	u := strings.TrimSuffix(args[0], "/")
+	if !c.user.RemoveReplica(u) {
+		fmt.Fprintf(stderr, "Replica '%s' not found\n", u)
+		return reported(nil)
+	}
+
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Replica '%s' removed\n", u)
+
+	return nil
+}
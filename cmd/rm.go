@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// errIsDir is the error when a directory is removed without the recursive flag.
+var errIsDir = errors.New("is a directory, use --recursive (-r) to remove it")
+
+// removed is a directory or file that was deleted from the server.
+type removed struct {
+	ID   string
+	Path string
+	Dir  bool
+	// The number of directories and files below a directory that were deleted with
+	// it.
+	Dirs  int
+	Files int
+}
+
+// removeError is a target that could not be deleted from the server.
+type removeError struct {
+	Target string
+	Err    error
+}
+
+// The 'rm' command.
+//
+// RemoveCommand deletes files and directories from the Clox server.
+type RemoveCommand struct {
+	cmd       *cobra.Command
+	user      *config.User
+	password  *secret.Bytes
+	client    *api.Client
+	store     *config.Store
+	aes       *crypto.AES
+	logger    *logging.Logger
+	reauth    *Reauthenticator
+	ids       []string
+	recursive bool
+	dryRun    bool
+}
+
+// NewRemoveCommand creates and returns a RemoveCommand.
+//
+// The id flag (-i, --id) is set for the RemoveCommand. This flag deletes the file or
+// directory with the ID, and can be set more than once.
+//
+// The recursive flag (-r, --recursive) is set for the RemoveCommand. This flag
+// allows directories to be deleted, with everything below them.
+func NewRemoveCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger, reauth *Reauthenticator) *RemoveCommand {
+	removeCmd := &RemoveCommand{store: store, aes: aes, logger: logger, reauth: reauth}
+
+	removeCmd.cmd = &cobra.Command{
+		Use:   "rm [<path>...]",
+		Short: "Delete files and directories from the server",
+		RunE:  removeCmd.Run,
+	}
+
+	removeCmd.cmd.Flags().StringArrayVarP(&removeCmd.ids, "id", "i", nil, "The ID of a file or directory to delete, can be set more than once")
+	removeCmd.cmd.Flags().BoolVarP(&removeCmd.recursive, "recursive", "r", false, "Delete directories and everything below them")
+
+	return removeCmd
+}
+
+// Command returns the cobra.Command of this RemoveCommand.
+func (c *RemoveCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *RemoveCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *RemoveCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *RemoveCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+func (c *RemoveCommand) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// Run is the RunE function of the cobra.Command in this RemoveCommand.
+//
+// Run deletes every path in the arguments and every ID in the id flag (-i, --id).
+// Each target is deleted on its own, a target that fails does not stop the others.
+// Every target that was deleted and every target that failed is printed. If any
+// target fails, the program exits with exitPartialFailure.
+//
+// A directory is only deleted if the recursive flag (-r, --recursive) is set. The
+// users root directory cannot be deleted.
+//
+// The user must confirm the targets are deleted before anything is deleted, unless
+// the yes flag (-y, --yes) is set, or the command is a dry run. A dry run (--dry-run)
+// prints the requests that would delete the targets, and what would be deleted.
+func (c *RemoveCommand) Run(cmd *cobra.Command, args []string) error {
+	targets := []api.Location{}
+	for _, p := range args {
+		targets = append(targets, api.Path("/"+strings.Trim(p, "/")))
+	}
+	for _, id := range c.ids {
+		targets = append(targets, api.ID(id))
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(stderr, "Nothing to delete")
+		fmt.Fprintln(stderr, "-> [HINT] Set the paths to delete, or the IDs with the id flag (-i, --id)")
+		return reported(nil)
+	}
+	if !c.dryRun && !c.confirm(targets) {
+		return reported(nil)
+	}
+
+	deleted := []removed{}
+	failed := []removeError{}
+	for _, target := range targets {
+		r, err := c.remove(cmd.Context(), c.client, target)
+		if err != nil {
+			if c.reauth.Handle(cmd.Context(), err, c.user, *c.password) {
+				return reported(err)
+			}
+			failed = append(failed, removeError{Target: target.String(), Err: err})
+			continue
+		}
+		deleted = append(deleted, r)
+	}
+
+	if c.dryRun {
+		c.logger.Printf("Would Delete: %d\n", len(deleted))
+	} else {
+		c.logger.Printf("Deleted: %d\n", len(deleted))
+	}
+	for _, r := range deleted {
+		if r.Dir && !c.dryRun {
+			c.logger.Printf("%s -> %s (%d directories, %d files)\n", r.ID, r.Path, r.Dirs, r.Files)
+			continue
+		}
+		c.logger.Printf("%s -> %s\n", r.ID, r.Path)
+	}
+
+	if len(failed) > 0 || !c.logger.Quiet() {
+		fmt.Fprintf(stdout, "\nErrors: %d\n", len(failed))
+	}
+	for _, e := range failed {
+		fmt.Fprintf(stdout, "%s -> %s\n", e.Target, e.Err)
+	}
+
+	if !c.dryRun {
+		forgetRemoved(c.store, c.aes, *c.password, c.logger, deleted)
+	}
+
+	if len(failed) > 0 {
+		return exitWith(exitPartialFailure)
+	}
+
+	return nil
+}
+
+// confirm lists the targets and asks the user to confirm they are deleted. It
+// returns true if they are.
+func (c *RemoveCommand) confirm(targets []api.Location) bool {
+	if c.recursive {
+		fmt.Fprintln(stdout, "-> [WARN] Directories are deleted with everything below them (--recursive)")
+	}
+	if len(targets) == 1 {
+		return prompt.Confirm(fmt.Sprintf("Delete '%s' from the server?", targets[0]))
+	}
+
+	fmt.Fprintln(stdout, "The following will be deleted from the server:")
+	for _, t := range targets {
+		fmt.Fprintf(stdout, "  %s\n", t)
+	}
+	return prompt.Confirm(fmt.Sprintf("Delete %d files and directories?", len(targets)))
+}
+
+// remove deletes the file or directory at the target. The target is a file if the
+// server has a file at it, otherwise it is a directory.
+func (c *RemoveCommand) remove(ctx context.Context, client *api.Client, target api.Location) (removed, error) {
+	file, listing, err := resolveTarget(ctx, client, target)
+	if err != nil {
+		return removed{}, err
+	}
+
+	if file != nil {
+		_, err := client.Files().Delete(ctx, api.ID(file.ID))
+		if err != nil {
+			return removed{}, err
+		}
+		return removed{ID: file.ID, Path: file.Path}, nil
+	}
+
+	dir := listing.Dir
+	if dir.DirPath == "/" {
+		return removed{}, errors.New("the root directory cannot be deleted")
+	}
+	if !c.recursive {
+		return removed{}, errIsDir
+	}
+
+	deleted, err := client.Dirs().Delete(ctx, api.ID(dir.ID), true)
+	if err != nil {
+		return removed{}, err
+	}
+
+	return removed{ID: dir.ID, Path: dir.DirPath, Dir: true, Dirs: deleted.Dirs, Files: deleted.Files}, nil
+}
+
+// forgetRemoved removes the deleted files and directories, and everything below the
+// directories, from the local index and the tracked files. A failed update is
+// logged and never fails the command.
+func forgetRemoved(store *config.Store, aes *crypto.AES, password []byte, logger *logging.Logger, deleted []removed) {
+	if len(deleted) == 0 {
+		return
+	}
+
+	// below reports whether the path is a deleted file or directory, or is below a
+	// deleted directory.
+	below := func(path string) bool {
+		for _, r := range deleted {
+			if path == r.Path || (r.Dir && strings.HasPrefix(path, r.Path+"/")) {
+				return true
+			}
+		}
+		return false
+	}
+
+	updateIndex(store, aes, password, logger, func(idx *index.Index) {
+		ids := []string{}
+		for id, e := range idx.Entries {
+			if below(e.Path) {
+				ids = append(ids, id)
+			}
+		}
+		idx.Remove(ids...)
+	})
+
+	t, err := loadTracker(store, aes, password)
+	if err != nil {
+		logger.Warn("loading tracked files", "error", err)
+		return
+	}
+	for id, r := range t.Records {
+		if below(r.Path) {
+			t.Untrack(id)
+		}
+	}
+	if err := t.Save(store.File(trackingFile), aes, password); err != nil {
+		logger.Warn("saving tracked files", "error", err)
+	}
+}
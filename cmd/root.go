@@ -1,17 +1,136 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path"
+	"strings"
+	"time"
 
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/biometric"
+	"github.com/cicconee/clox-cli/internal/color"
 	"github.com/cicconee/clox-cli/internal/config"
 	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/hooks"
+	"github.com/cicconee/clox-cli/internal/keyring"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/plugin"
 	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
 	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/cicconee/clox-cli/internal/session"
 	"github.com/spf13/cobra"
 )
 
+// defaultServer is the base URL of the Clox API of a profile that has no server
+// set.
+const defaultServer = "http://localhost:8081"
+
+// serverURL returns the base URL of the Clox API of the user. The server of the
+// store, set by the server flag (--server), is used instead if it is set.
+func serverURL(store *config.Store, user *config.User) string {
+	if store.Server != "" {
+		return store.Server
+	}
+	if user.Server() != "" {
+		return user.Server()
+	}
+
+	return defaultServer
+}
+
+// profileServer returns the base URL of the Clox API of the profile, see serverURL.
+// If the profile is not configured, the default server is returned.
+func profileServer(store *config.Store, profile string) string {
+	s := *store
+	s.Profile = profile
+
+	user := &config.User{}
+	if err := s.ReadConfigFile(user); err != nil {
+		user = &config.User{}
+	}
+
+	return serverURL(store, user)
+}
+
+// location returns the api.Location for the path and id flags of a command. If id
+// is set, the location identifies by ID, otherwise it identifies by path. An empty
+// path is the users root directory.
+func location(path string, id string) api.Location {
+	if id != "" {
+		return api.ID(id)
+	}
+
+	return api.Path(path)
+}
+
+// splitRemotePath splits the path of a file on the server into the api.Location of
+// its directory and its name. A file in the users root directory has an empty
+// directory path.
+func splitRemotePath(p string) (api.Location, string) {
+	dir, name := path.Split(path.Clean("/" + p))
+	dir = strings.TrimSuffix(dir, "/")
+	return api.Path(dir), name
+}
+
+const (
+	// apiRetries is how many times the commands retry a request that failed, see
+	// api.WithRetries.
+	apiRetries = 3
+	// apiRateLimitRetries is how many times the commands retry a request that the
+	// server rate limited, see api.WithRateLimit.
+	apiRateLimitRetries = 5
+)
+
+// rateLimitNotice prints that a request was rate limited, and when it is sent again.
+func rateLimitNotice(wait time.Duration, attempt int) {
+	fmt.Fprintf(stderr, "Rate limited by the server, retrying in %s (%d/%d)...\n",
+		wait.Round(time.Second), attempt, apiRateLimitRetries)
+}
+
+// newAPIClient creates the *api.Client used by the commands, for the Clox API at the
+// server URL. Every request is authorized with token, which is not copied, and
+// logged at the debug level, as are the decisions to retry it. A request that fails
+// is retried up to apiRetries times with the default backoff, and a request that is
+// rate limited up to apiRateLimitRetries times. The Client is configured with the
+// opts.
+func newAPIClient(server string, token []byte, logger *logging.Logger, opts ...api.Option) *api.Client {
+	opts = append([]api.Option{
+		api.WithToken(token),
+		api.WithRetries(apiRetries),
+		api.WithRateLimit(apiRateLimitRetries, rateLimitNotice),
+		api.WithInterceptors(api.LogRequests(logger.Logger)),
+		api.WithLogger(logger.Logger),
+	}, opts...)
+
+	return api.New(server, opts...)
+}
+
+// newUserAPIClient creates the *api.Client used by the commands of a user. It is the
+// same as newAPIClient for the server of the user, see serverURL. The requests have
+// the timeouts, TLS configuration, and proxy of the user, and read requests fail
+// over to the replicas of the user. The TLS configuration of the store is merged
+// over the TLS configuration of the user. The Client is also configured with the
+// opts.
+//
+// A timeout of the user that is invalid is the default, the RootCommand rejects
+// them before any command runs.
+func newUserAPIClient(store *config.Store, user *config.User, token []byte, logger *logging.Logger, opts ...api.Option) (*api.Client, error) {
+	httpClient, err := newHTTPClient(user.TLS().Merge(store.TLS), user.ProxyURL(), store.DebugHTTP)
+	if err != nil {
+		return nil, fmt.Errorf("configuring transport: %w", err)
+	}
+
+	t, _ := parseTimeouts(user.Timeouts())
+	opts = append(append(t.options(), api.WithHTTPClient(httpClient), api.WithReplicas(user.Replicas()...)), opts...)
+
+	return newAPIClient(serverURL(store, user), token, logger, opts...), nil
+}
+
 // Command is the interface that wraps the Command function.
 type Command interface {
 	// Command returns the cobra.Command.
@@ -28,39 +147,256 @@ type UserCommand interface {
 	SetUser(*config.User)
 
 	// SetPassword sets the password for a command that was entered in the
-	// RootCommand's PersistentPreRun function.
-	SetPassword(string)
+	// RootCommand's PersistentPreRun function. The password is wiped in the
+	// RootCommand's PersistentPostRun function, it must not be kept after.
+	SetPassword(*secret.Bytes)
+}
+
+// ClientCommand is the interface that wraps the UserCommand and SetClient functions.
+// It is a UserCommand that calls the Clox API.
+type ClientCommand interface {
+	UserCommand
+
+	// SetClient sets the *api.Client for a command that was created with the API
+	// token of the config.User in the RootCommand's PersistentPreRun function.
+	SetClient(*api.Client)
+}
+
+// DryRunCommand is the interface that wraps the ClientCommand and SetDryRun
+// functions. It is a ClientCommand that can be run without changing anything on the
+// server, see the dry run flag (--dry-run).
+type DryRunCommand interface {
+	ClientCommand
+
+	// SetDryRun sets if the command is a dry run in the RootCommand's
+	// PersistentPreRun function. The *api.Client of a dry run does not send the
+	// requests that change anything, see api.DryRun, and the command does not
+	// change any local state from their responses.
+	SetDryRun(bool)
+}
+
+// AuthenticateCommand is the interface that wraps the UserCommand and SetIntegrity
+// functions. It is a UserCommand that runs on a configuration file that failed to
+// verify, see 'clox config authenticate'.
+type AuthenticateCommand interface {
+	UserCommand
+
+	// SetIntegrity sets the error of verifying the configuration file, see
+	// config.User.VerifyIntegrity, in the RootCommand's PersistentPreRun function.
+	// The command is run whatever the error is, it must not trust the file if it is
+	// not nil.
+	SetIntegrity(error)
 }
 
 // The root command of Clox CLI.
 type RootCommand struct {
-	store   *config.Store
-	cmd     *cobra.Command
-	subCmds map[string]UserCommand
+	store     *config.Store
+	aes       *crypto.AES
+	logger    *logging.Logger
+	biometric biometric.Provider
+	keyring   keyring.Keyring
+	cmd       *cobra.Command
+	subCmds   map[*cobra.Command]UserCommand
+	logLevel  string
+	logFormat string
+	// verbose and quiet are the verbose (-v, --verbose) and quiet (-q, --quiet)
+	// flags.
+	verbose int
+	quiet   bool
+	// color and noColor are the color (--color) and no color (--no-color) flags.
+	color   string
+	noColor bool
+	timeout time.Duration
+	// passwordStdin and noInput are the password stdin (--password-stdin) and no
+	// input (--no-input) flags.
+	passwordStdin bool
+	noInput       bool
+	// yes is the yes flag (-y, --yes).
+	yes bool
+	// dryRun is the dry run flag (--dry-run).
+	dryRun bool
+	// allowWeak is the allow weak password flag (--allow-weak-password).
+	allowWeak bool
+	// cancel releases the context with the time limit of the command, if it is set.
+	cancel context.CancelFunc
+	// saveNames saves the names.Mapping of the command, if the file names of the
+	// profile are encrypted.
+	saveNames func()
+	// password and token are the password and the API token of the command, they
+	// are wiped once it has run, see PersistentPostRun.
+	password secret.Bytes
+	token    secret.Bytes
+	// ran is set once the RunE of the command is called, see trackRun. An error
+	// returned before it that was not printed is an error of the command line, such
+	// as an unknown flag or a missing argument.
+	ran bool
 }
 
 // NewRootCommand creates and returns a RootCommand.
-func NewRootCommand(store *config.Store) *RootCommand {
+//
+// The log level flag (--log-level) and log format flag (--log-format) are set as
+// persistent flags for the RootCommand. These flags configure the logger that is
+// shared by every sub command.
+//
+// The verbose flag (-v, --verbose) is set as a persistent flag for the RootCommand.
+// This flag logs the requests of the API with their timing, and the decisions to
+// retry them, the same as '--log-level debug'. Given twice (-vv), every attempt of a
+// request is logged as well, the same as '--log-level trace'.
+//
+// The quiet flag (-q, --quiet) is set as a persistent flag for the RootCommand. This
+// flag hides the messages of the commands that are not errors or results, such as
+// the files that were uploaded, and every log record below the error level.
+//
+// The color flag (--color) is set as a persistent flag for the RootCommand. This
+// flag sets when the output is colored: auto, always, or never. It replaces the
+// color of the settings file, see config.Settings. By default the output is only
+// colored if it is a terminal and NO_COLOR is not set. The no color flag
+// (--no-color) is the same as '--color never'.
+//
+// The config flag (--config) is set as a persistent flag for the RootCommand. This
+// flag sets the configuration directory, instead of CLOX_CONFIG_DIR, ~/.clox, or
+// $XDG_CONFIG_HOME/clox. It is read before the commands are created, see configFlag.
+//
+// The profile flag (--profile) is set as a persistent flag for the RootCommand. This
+// flag selects the profile for this run, instead of the profile selected with
+// 'clox use'.
+//
+// The server flag (--server) is set as a persistent flag for the RootCommand. This
+// flag sets the base URL of the Clox API for this run, instead of the server of the
+// profile. If the flag is not set, CLOX_SERVER_URL is used instead.
+//
+// The timeout flag (--timeout) is set as a persistent flag for the RootCommand. This
+// flag sets the time limit of the command, instead of the command timeout of the
+// profile. Every request in flight is canceled when the time limit is reached.
+//
+// The CA file (--ca-file), client certificate (--client-cert), client key
+// (--client-key), and insecure skip verify (--insecure-skip-verify) flags are set as
+// persistent flags for the RootCommand. These flags replace the TLS configuration of
+// the profile for this run.
+//
+// The debug http flag (--debug-http) is set as a persistent flag for the
+// RootCommand. This flag logs every request and response of the API to standard
+// error, or to the file if a path is given as --debug-http=<path>. Secrets such as
+// the API token are redacted.
+//
+// The password stdin flag (--password-stdin) is set as a persistent flag for the
+// RootCommand. This flag reads the password from the first line of standard input,
+// instead of unlocking or prompting for it.
+//
+// The no input flag (--no-input) is set as a persistent flag for the RootCommand.
+// This flag disables every prompt, a command that requires input fails instead of
+// waiting for it. Questions such as overwriting a file are answered with no.
+//
+// The yes flag (-y, --yes) is set as a persistent flag for the RootCommand. This
+// flag answers yes to every confirmation of a destructive operation, such as
+// deleting files with 'clox rm' or replacing a local file, so they can be automated.
+// It takes precedence over the no input flag.
+//
+// The dry run flag (--dry-run) is set as a persistent flag for the RootCommand. This
+// flag prints the requests that would create, upload, or delete anything on the
+// server instead of sending them, see api.DryRun. It is only supported by a
+// DryRunCommand.
+//
+// The allow weak password flag (--allow-weak-password) is set as a persistent flag
+// for the RootCommand. This flag accepts a weak password when a new password is set,
+// such as by 'clox init' and 'clox passwd', for test environments. See
+// prompt.PasswordProblems.
+//
+// The biometric provider is used to unlock commands with Windows Hello when the
+// active profile is enrolled. The keyring unlocks commands without prompting when
+// the password of the active profile is stored in it. The aes decrypts the API token
+// of the shared *api.Client.
+func NewRootCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger, provider biometric.Provider, ring keyring.Keyring) *RootCommand {
 	rootCmd := &RootCommand{
-		store:   store,
-		subCmds: map[string]UserCommand{},
+		store:     store,
+		aes:       aes,
+		logger:    logger,
+		biometric: provider,
+		keyring:   ring,
+		subCmds:   map[*cobra.Command]UserCommand{},
 	}
 
 	rootCmd.cmd = &cobra.Command{
-		Use:              "clox",
-		Short:            "The official client of the Clox API",
-		SilenceErrors:    true,
-		PersistentPreRun: rootCmd.PersistentPreRun,
+		Use:               "clox",
+		Short:             "The official client of the Clox API",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		PersistentPreRunE: rootCmd.PersistentPreRun,
+		PersistentPostRun: rootCmd.PersistentPostRun,
 	}
 
+	rootCmd.cmd.CompletionOptions.DisableDefaultCmd = true
+
+	rootCmd.cmd.PersistentFlags().StringVar(&rootCmd.logLevel, "log-level", "info", "The log level: trace, debug, info, warn, or error")
+	rootCmd.cmd.PersistentFlags().CountVarP(&rootCmd.verbose, "verbose", "v", "Log the requests of the API, -vv logs every attempt of a request")
+	rootCmd.cmd.PersistentFlags().BoolVarP(&rootCmd.quiet, "quiet", "q", false, "Only print errors and results")
+	rootCmd.cmd.PersistentFlags().StringVar(&rootCmd.logFormat, "log-format", "text", "The log format: text or json")
+	rootCmd.cmd.PersistentFlags().StringVar(&rootCmd.color, "color", string(color.Auto), "When to color the output: auto, always, or never")
+	rootCmd.cmd.PersistentFlags().BoolVar(&rootCmd.noColor, "no-color", false, "Do not color the output, the same as '--color never'")
+	rootCmd.cmd.RegisterFlagCompletionFunc("log-level", cobra.FixedCompletions([]string{"trace", "debug", "info", "warn", "error"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.cmd.RegisterFlagCompletionFunc("log-format", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.cmd.RegisterFlagCompletionFunc("color", cobra.FixedCompletions([]string{string(color.Auto), string(color.Always), string(color.Never)}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.cmd.PersistentFlags().String("config", store.Path, "The configuration directory, instead of ~/.clox or $XDG_CONFIG_HOME/clox")
+	rootCmd.cmd.PersistentFlags().StringVar(&store.Profile, "profile", store.Profile, "The profile to use, instead of the selected profile")
+	rootCmd.cmd.PersistentFlags().StringVar(&store.Server, "server", "", "The URL of the Clox server, instead of the server of the profile")
+	rootCmd.cmd.PersistentFlags().DurationVar(&rootCmd.timeout, "timeout", 0, "The time limit of the command, such as 30s or 5m")
+	rootCmd.cmd.PersistentFlags().StringVar(&store.TLS.CAFile, "ca-file", "", "The PEM file of the certificate authorities that the server is verified with")
+	rootCmd.cmd.PersistentFlags().StringVar(&store.TLS.CertFile, "client-cert", "", "The PEM file of the client certificate for mutual TLS")
+	rootCmd.cmd.PersistentFlags().StringVar(&store.TLS.KeyFile, "client-key", "", "The PEM file of the private key of the client certificate")
+	rootCmd.cmd.PersistentFlags().BoolVar(&store.TLS.InsecureSkipVerify, "insecure-skip-verify", false, "Do not verify the certificate of the server, for testing only")
+	rootCmd.cmd.PersistentFlags().StringVar(&store.DebugHTTP, "debug-http", "", "Log every API request and response to standard error, or to the file")
+	rootCmd.cmd.PersistentFlags().Lookup("debug-http").NoOptDefVal = "-"
+	rootCmd.cmd.PersistentFlags().BoolVar(&rootCmd.passwordStdin, "password-stdin", false, "Read the password from standard input")
+	rootCmd.cmd.PersistentFlags().BoolVar(&rootCmd.noInput, "no-input", false, "Fail instead of prompting for input")
+	rootCmd.cmd.PersistentFlags().BoolVarP(&rootCmd.yes, "yes", "y", false, "Answer yes to every confirmation, such as deleting files")
+	rootCmd.cmd.PersistentFlags().BoolVar(&rootCmd.dryRun, "dry-run", false, "Print the requests that would change anything instead of sending them")
+	rootCmd.cmd.PersistentFlags().BoolVar(&rootCmd.allowWeak, "allow-weak-password", false, "Accept a weak new password, for test environments")
+
 	return rootCmd
 }
 
+// level returns the log level of the verbose flag (-v, --verbose) if it is set,
+// otherwise of the log level flag (--log-level).
+func (c *RootCommand) level() (slog.Level, error) {
+	switch {
+	case c.verbose == 1:
+		return slog.LevelDebug, nil
+	case c.verbose > 1:
+		return logging.LevelTrace, nil
+	}
+
+	return logging.ParseLevel(c.logLevel)
+}
+
 // AddCommand adds a *cobra.Command to this RootCommand.
 func (c *RootCommand) AddCommand(cmd Command) {
 	c.cmd.AddCommand(cmd.Command())
 }
 
+// AddPluginCommands adds a PluginCommand to this RootCommand for every plugin. A
+// plugin with the same name as an existing command is skipped, plugins cannot
+// replace the built-in commands.
+func (c *RootCommand) AddPluginCommands(plugins []plugin.Plugin) {
+	for _, p := range plugins {
+		if c.hasCommand(p.Name) {
+			continue
+		}
+
+		c.AddCommand(NewPluginCommand(p, c.store, c.logger))
+	}
+}
+
+// hasCommand checks if this RootCommand has a sub command with the name or alias.
+func (c *RootCommand) hasCommand(name string) bool {
+	for _, cmd := range c.cmd.Commands() {
+		if cmd.Name() == name || cmd.HasAlias(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // AddCommand adds a *cobra.Command to this RootCommand and sets
 // the UserCommand in the subCmds map.
 //
@@ -70,67 +406,597 @@ func (c *RootCommand) AddCommand(cmd Command) {
 func (c *RootCommand) AddUserCommand(uc UserCommand) {
 	cmd := uc.Command()
 	c.cmd.AddCommand(cmd)
-	c.subCmds[cmd.Name()] = uc
+	c.subCmds[cmd] = uc
+}
+
+// AddGroupCommand adds the group *cobra.Command to this RootCommand, and adds every
+// Command as a sub command of the group. The group and every Command that is a
+// UserCommand is set in the subCmds map the same as AddUserCommand.
+func (c *RootCommand) AddGroupCommand(group Command, cmds ...Command) {
+	groupCmd := group.Command()
+	c.cmd.AddCommand(groupCmd)
+	if uc, ok := group.(UserCommand); ok {
+		c.subCmds[groupCmd] = uc
+	}
+	for _, sub := range cmds {
+		cmd := sub.Command()
+		groupCmd.AddCommand(cmd)
+		if uc, ok := sub.(UserCommand); ok {
+			c.subCmds[cmd] = uc
+		}
+	}
 }
 
-// PersistentPreRun is the PersistentPreRun of the cobra.Command in this
+// PersistentPreRun is the PersistentPreRunE of the cobra.Command in this
 // RootCommand.
 //
-// Every command except the 'init' command, is passed a config.User that is created
-// in this function. If creating a user returns an error, the error is printed and
-// the program exits.
+// Every UserCommand is passed a config.User that is created in this function. If
+// creating a user returns an error, the error is printed and returned, the command
+// is not run.
+//
+// Every UserCommand is passed a password. If the password stdin flag is set, it is
+// read from standard input. If CLOX_PASSWORD is set, it is the password. If the
+// active profile has a session, see 'clox session unlock', its password is used. If
+// the password of the active profile is stored in the OS keyring, it is used. If the
+// active profile is enrolled in biometric unlock, the password is released by
+// Windows Hello. Otherwise, or if biometric unlock fails, this function will prompt
+// the user for a password, unless the no input flag is set. The password is
+// validated against the password hash. If validation fails the command fails with
+// exitAuthFailure. The configuration file is then checked for changes made outside
+// of the CLI, see verifyIntegrity. An AuthenticateCommand is passed the error of the
+// check instead, and is run whatever it is.
+//
+// The secrets that are encrypted with the password are encrypted with the key
+// derivation function of the profile, see config.User.KDF. New files are encrypted
+// with the cipher of the profile, see config.User.Cipher.
+//
+// Every ClientCommand is passed the *api.Client that every request of the command is
+// sent with. The API token is decrypted with the password, if it fails the command
+// fails. If CLOX_API_TOKEN is set, it is the API token instead. The requests have
+// the timeouts of the profile, if a timeout is invalid the command fails. If the
+// profile encrypts file names, see 'clox names enable', the Client encodes them with
+// the names key, see api.WithNames.
 //
-// Every command except the 'init' command, is passed a password. This function will
-// prompt the user for a password and validate it against the password hash. If
-// validation fails the program will exit.
+// The context of the command is given the time limit of the timeout flag, or the
+// command timeout of the profile of a UserCommand.
 //
-// The 'init' command is special, as it does not rely on a config.User. Instead it
-// validates that a config.User has been configured, if it isn't, it configures one.
-func (c *RootCommand) PersistentPreRun(cmd *cobra.Command, args []string) {
-	if cmd.Name() != "init" {
+// If the dry run flag (--dry-run) is set, the command must be a DryRunCommand, any
+// other command fails with exitUsage.
+//
+// Commands that are not a UserCommand, such as the 'init' command and plugins, do
+// not rely on a config.User and are not prompted for a password.
+//
+// Before anything else, the flags that are not set are set to the defaults of the
+// command in the settings, see applyDefaults. Then the shared logger is configured
+// with the log level and log format flags, or the verbose and quiet flags, the
+// output is colored in the mode of the color flags, see colorMode, and the profile
+// and server flags are validated. If any flag is invalid the command fails with
+// exitUsage.
+func (c *RootCommand) PersistentPreRun(cmd *cobra.Command, args []string) error {
+	if err := c.applyDefaults(cmd); err != nil {
+		fmt.Fprintln(stderr, "Invalid defaults (settings.json):", err)
+		return errUsage
+	}
+
+	if c.verbose > 0 && c.quiet {
+		fmt.Fprintln(stderr, "Only one flag can be set: verbose (-v, --verbose) or quiet (-q, --quiet)")
+		return errUsage
+	}
+	if c.verbose > 0 && cmd.Flags().Changed("log-level") {
+		fmt.Fprintln(stderr, "Only one flag can be set: verbose (-v, --verbose) or log level (--log-level)")
+		return errUsage
+	}
+	level, err := c.level()
+	if err != nil {
+		c.logger.Error("parsing log level flag", "error", err)
+		return errUsage
+	}
+	format, err := logging.ParseFormat(c.logFormat)
+	if err != nil {
+		c.logger.Error("parsing log format flag", "error", err)
+		return errUsage
+	}
+	c.logger.SetQuiet(c.quiet)
+	c.logger.Configure(level, format)
+
+	if c.noColor && cmd.Flags().Changed("color") {
+		fmt.Fprintln(stderr, "Only one flag can be set: color (--color) or no color (--no-color)")
+		return errUsage
+	}
+	mode, err := c.colorMode(cmd)
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid color (--color):", err)
+		return errUsage
+	}
+	configureColor(mode)
+
+	if err := config.ValidateProfileName(c.store.Profile); err != nil {
+		fmt.Fprintln(stderr, "Invalid profile (--profile):", err)
+		return errUsage
+	}
+
+	if c.store.Server == "" {
+		c.store.Server = os.Getenv(envServerURL)
+	}
+	if c.store.Server != "" {
+		server, err := validateServerURL(c.store.Server)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid server (--server or %s): %s\n", envServerURL, err)
+			return errUsage
+		}
+		c.store.Server = server
+	}
+
+	if c.noInput {
+		prompt.DisableInput()
+	}
+	if c.yes {
+		prompt.AssumeYes()
+	}
+	if c.allowWeak {
+		prompt.AllowWeakPasswords()
+	}
+	if c.passwordStdin {
+		password, err := prompt.ReadSecret(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "Reading password from standard input (--password-stdin):", err)
+			return reported(err)
+		}
+		prompt.SetPassword(password)
+	}
+
+	dryRunCmd, dryRunOK := c.subCmds[cmd].(DryRunCommand)
+	if c.dryRun {
+		if !dryRunOK {
+			fmt.Fprintf(stderr, "Invalid dry run (--dry-run): '%s' does not support a dry run\n", cmd.CommandPath())
+			fmt.Fprintln(stderr, "-> [HINT] A dry run is supported by mkdir, upload, rm, push, and sync")
+			return errUsage
+		}
+		dryRunCmd.SetDryRun(true)
+	}
+
+	if subCmd, ok := c.subCmds[cmd]; ok {
 		user := &config.User{}
 		err := c.store.ReadConfigFile(user)
 		if err != nil {
 			if errors.Is(err, os.ErrNotExist) {
-				fmt.Println("Clox CLI not configured")
-				fmt.Println("Run 'clox init' to configure the CLI")
-				os.Exit(0)
+				fmt.Fprintln(stderr, "Clox CLI not configured")
+				fmt.Fprintln(stderr, "Run 'clox init' to configure the CLI")
+				return reported(nil)
 			}
 
-			fmt.Println("Error:", err)
-			os.Exit(1)
+			c.logger.Error("reading config file", "error", err)
+			return reported(err)
+		}
+
+		if k := user.KDF(); k != (crypto.KDF{}) {
+			if err := k.Validate(); err != nil {
+				c.logger.Error("reading config file", "error", err)
+				return reported(err)
+			}
+			c.aes.KDF = k
+		}
+		if name := user.Cipher(); name != "" {
+			if err := crypto.ValidateCipher(name); err != nil {
+				c.logger.Error("reading config file", "error", err)
+				return reported(err)
+			}
+			c.aes.Cipher = name
 		}
 
-		password := prompt.Password()
+		password, err := c.readPassword(user)
+		if err != nil {
+			fmt.Fprintln(stderr, "Cannot read the password:", err)
+			fmt.Fprintf(stderr, "-> [HINT] Pass the password with --password-stdin or %s\n", envPassword)
+			return reported(err)
+		}
+		c.password = password
 		if err := user.VerifyPassword(password); err != nil {
-			fmt.Println("Invalid password")
-			os.Exit(0)
+			fmt.Fprintln(stderr, "Invalid password")
+			return exitWith(exitAuthFailure)
+		}
+		if authCmd, ok := subCmd.(AuthenticateCommand); ok {
+			authCmd.SetIntegrity(user.VerifyIntegrity(c.aes, password))
+		} else if err := c.verifyIntegrity(user, password); err != nil {
+			return err
 		}
 
-		subCmd := c.subCmds[cmd.Name()]
 		subCmd.SetUser(user)
-		subCmd.SetPassword(password)
+		subCmd.SetPassword(&c.password)
+
+		t, err := parseTimeouts(user.Timeouts())
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid configuration:", err)
+			return reported(err)
+		}
+		if !c.cmd.PersistentFlags().Changed("timeout") {
+			c.timeout = t.command
+		}
+
+		if clientCmd, ok := subCmd.(ClientCommand); ok {
+			token, err := apiToken(user, c.aes, password)
+			if err != nil {
+				c.logger.Error("decrypting api token", "error", err)
+				return reported(err)
+			}
+			c.token = token
+			codec, mapping, err := newNameCodec(c.store, user, c.aes, password, c.logger)
+			if err != nil {
+				c.logger.Error("decrypting names key", "error", err)
+				return reported(err)
+			}
+			var opts []api.Option
+			if c.dryRun {
+				opts = append(opts, api.WithInterceptors(api.DryRun(stdout)))
+			}
+			if codec != nil {
+				opts = append(opts, api.WithNames(codec))
+				c.saveNames = func() { saveNames(c.store, c.aes, password, c.logger, mapping) }
+			}
+			client, err := newUserAPIClient(c.store, user, token, c.logger, opts...)
+			if err != nil {
+				fmt.Fprintln(stderr, "Invalid configuration:", err)
+				return reported(err)
+			}
+			if user.TLS().Merge(c.store.TLS).InsecureSkipVerify {
+				c.logger.Warn("the certificate of the server is not verified")
+			}
+			clientCmd.SetClient(client)
+		}
+	}
+
+	if c.timeout > 0 {
+		ctx, cancel := context.WithTimeout(cmd.Context(), c.timeout)
+		c.cancel = cancel
+		cmd.SetContext(ctx)
 	}
+
+	return nil
 }
 
-// Execute creates the Clox CLI commands and executes the root command.
+// PersistentPostRun is the PersistentPostRun of the cobra.Command in this
+// RootCommand.
+//
+// The names.Mapping of the command is saved, see saveNames, then the password and
+// the API token that were passed to the command are wiped. It is also called once
+// a command that failed returns, so it can be called more than once.
+func (c *RootCommand) PersistentPostRun(cmd *cobra.Command, args []string) {
+	if c.saveNames != nil {
+		c.saveNames()
+		c.saveNames = nil
+	}
+
+	c.password.Wipe()
+	c.token.Wipe()
+}
+
+// colorMode returns the color.Mode of the color flags, or of the settings file if
+// neither is set. Settings that cannot be read are logged and ignored.
+func (c *RootCommand) colorMode(cmd *cobra.Command) (color.Mode, error) {
+	switch {
+	case c.noColor:
+		return color.Never, nil
+	case cmd.Flags().Changed("color"):
+		return color.ParseMode(c.color)
+	}
+
+	settings, err := c.store.ReadSettings()
+	if err != nil {
+		c.logger.Warn("reading settings file", "error", err)
+		return color.Auto, nil
+	}
+	mode, err := color.ParseMode(settings.Color)
+	if err != nil {
+		c.logger.Warn("reading settings file", "error", err)
+		return color.Auto, nil
+	}
+
+	return mode, nil
+}
+
+// verifyIntegrity checks that the configuration file of the active profile was not
+// modified outside of the CLI, see config.User.VerifyIntegrity. If it was, an error
+// is returned, the keys in it cannot be trusted. A file without a MAC is not
+// trusted either, it is only authenticated by 'clox config authenticate'.
+func (c *RootCommand) verifyIntegrity(user *config.User, password []byte) error {
+	err := user.VerifyIntegrity(c.aes, password)
+	switch {
+	case err == nil:
+	case errors.Is(err, config.ErrNoMAC):
+		fmt.Fprintf(stderr, "The configuration file of profile '%s' is not authenticated\n", c.store.Profile)
+		fmt.Fprintln(stderr, "-> [HINT] If it was written before config files were authenticated, check its keys and run 'clox config authenticate'")
+		return reported(nil)
+	case errors.Is(err, config.ErrConfigModified):
+		fmt.Fprintf(stderr, "The configuration file of profile '%s' was modified outside of the CLI\n", c.store.Profile)
+		fmt.Fprintln(stderr, "-> [HINT] The public key or an encrypted key may have been replaced, restore a backup of config.json you trust or run 'clox init -f'")
+		return reported(nil)
+	default:
+		c.logger.Error("verifying config file", "error", err)
+		return reported(err)
+	}
+
+	return nil
+}
+
+// readPassword returns the password of the active profile. The password of the
+// password stdin flag is used if it is set, otherwise the profile is unlocked, see
+// unlock. If neither has the password, the user is prompted for it. The password
+// must be wiped after use.
+func (c *RootCommand) readPassword(user *config.User) (secret.Bytes, error) {
+	if !c.passwordStdin {
+		if password, ok := c.unlock(user); ok {
+			return password, nil
+		}
+	}
+
+	return prompt.Password()
+}
+
+// unlock returns the password of the active profile without prompting. The password
+// of CLOX_PASSWORD is used if it is set, otherwise it is read from the session, the
+// keyring, or unlocked with the biometric provider. If none has the password, it
+// returns false. The password must be wiped after use.
+func (c *RootCommand) unlock(user *config.User) (secret.Bytes, bool) {
+	if password, ok := os.LookupEnv(envPassword); ok {
+		return secret.FromString(password), true
+	}
+
+	if password, ok := c.sessionPassword(user); ok {
+		return password, true
+	}
+	if password, ok := c.keyringPassword(user); ok {
+		return password, true
+	}
+
+	return c.biometricPassword(user)
+}
+
+// sessionPassword returns the password of the session of the active profile. If
+// there is no session, it has expired, or its password no longer matches the user,
+// it returns false. A session that no longer matches is removed.
+func (c *RootCommand) sessionPassword(user *config.User) (secret.Bytes, bool) {
+	path, keyPath := session.Path(c.store.Path, c.store.Profile), session.KeyPath(c.store.Dir())
+	s, err := session.Load(path, keyPath)
+	if err != nil {
+		if errors.Is(err, session.ErrInsecure) {
+			c.logger.Warn("removed session that is not private to the user", "error", err)
+		} else if !errors.Is(err, session.ErrNotExist) {
+			c.logger.Debug("reading session", "error", err)
+		}
+		return nil, false
+	}
+
+	if err := user.VerifyPassword(s.Password); err != nil {
+		c.logger.Warn("session password is out of date, run 'clox session unlock' again")
+		s.Password.Wipe()
+		session.Remove(path, keyPath)
+		return nil, false
+	}
+
+	return s.Password, true
+}
+
+// keyringPassword returns the password of the active profile from the keyring. If no
+// password is stored, or it no longer matches the user, it returns false.
+func (c *RootCommand) keyringPassword(user *config.User) (secret.Bytes, bool) {
+	password, err := c.keyring.Get(c.store.Account())
+	if err != nil {
+		if !errors.Is(err, keyring.ErrNotFound) && !errors.Is(err, keyring.ErrUnsupported) {
+			c.logger.Debug("reading password from keyring", "error", err)
+		}
+		return nil, false
+	}
+
+	if err := user.VerifyPassword(password); err != nil {
+		c.logger.Warn("keyring password is out of date, run 'clox keyring enable' again")
+		password.Wipe()
+		return nil, false
+	}
+
+	return password, true
+}
+
+// biometricPassword unlocks the password of the active profile with the biometric
+// provider. If the profile is not enrolled, the user is not verified, or the stored
+// password no longer matches the user, it returns false.
+func (c *RootCommand) biometricPassword(user *config.User) (secret.Bytes, bool) {
+	if !c.biometric.Enrolled(c.store.Account()) {
+		return nil, false
+	}
+
+	password, err := c.biometric.Unlock(c.store.Account(), "unlock the Clox CLI")
+	if err != nil {
+		c.logger.Debug("biometric unlock failed", "error", err)
+		return nil, false
+	}
+
+	if err := user.VerifyPassword(password); err != nil {
+		c.logger.Warn("biometric password is out of date, run 'clox biometric enable' again")
+		password.Wipe()
+		return nil, false
+	}
+
+	return password, true
+}
+
+// configFlag returns the value of the config flag (--config) in args, as
+// '--config <dir>' or '--config=<dir>'. If it is set more than once the last value
+// is returned. Arguments after '--' are not flags.
+func configFlag(args []string) string {
+	var dir string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--":
+			return dir
+		case a == "--config" && i+1 < len(args):
+			dir = args[i+1]
+			i++
+		case strings.HasPrefix(a, "--config="):
+			dir = strings.TrimPrefix(a, "--config=")
+		}
+	}
+
+	return dir
+}
+
+// Execute creates the Clox CLI commands and executes the root command. The context
+// of the commands is canceled when the program is interrupted, see
+// interruptContext. If the command fails, the program exits with the exit code of
+// its error, see report.
+//
+// The configuration directory is resolved before the commands are created, see
+// config.ResolveDir, so the config flag (--config) is read from the arguments first.
 func Execute() {
-	s, err := config.NewStore()
+	logger := logging.New(os.Stderr)
+	logger.SetOutput(stdout)
+	configureColor(color.Auto)
+
+	dir, err := config.ResolveDir(configFlag(os.Args[1:]))
+	if err != nil {
+		logger.Error("resolving the configuration directory", "error", err)
+		os.Exit(1)
+	}
+
+	s, err := config.NewStore(dir)
 	if err != nil {
-		fmt.Printf("Error: Failed initializing the configuration: %v\n", err)
+		logger.Error("initializing the configuration", "error", err)
 		os.Exit(1)
 	}
 
 	aes := &crypto.AES{}
 	rsa := &crypto.RSA{}
 	keys := &security.Keys{AES: aes}
+	hookRunner := &hooks.Runner{Dir: s.HooksDir()}
+	reauth := NewReauthenticator(s, aes, logger)
 
-	root := NewRootCommand(s)
-	root.AddCommand(NewInitCommand(s, keys, aes, rsa))
-	root.AddUserCommand(NewMkdirCommand(aes))
-	root.AddUserCommand(NewUploadCommand(keys, aes, rsa))
+	root := NewRootCommand(s, aes, logger, biometric.New(s.Path), keyring.New())
+	root.AddCommand(NewInitCommand(s, keys, aes, rsa, logger))
+	root.AddCommand(NewCompletionCommand())
+	root.AddCommand(NewUseCommand(s, logger))
+	root.AddCommand(NewRecoverCommand(s, keys, aes, rsa, root.biometric, root.keyring, logger))
+	root.AddGroupCommand(NewProfileCommand(),
+		NewProfileListCommand(s, logger),
+		NewProfileCreateCommand(s, keys, aes, rsa, logger),
+		NewUseCommand(s, logger))
+	root.AddUserCommand(NewPasswdCommand(s, keys, aes, root.biometric, root.keyring, logger))
+	root.AddUserCommand(NewMkdirCommand(s, aes, logger, reauth))
+	root.AddUserCommand(NewUploadCommand(s, keys, aes, rsa, logger, hookRunner, reauth))
+	root.AddUserCommand(NewPushCommand(s, keys, aes, rsa, logger, hookRunner, reauth))
+	root.AddUserCommand(NewDownloadCommand(s, keys, aes, rsa, logger, hookRunner, reauth))
+	root.AddUserCommand(NewPullCommand(s, keys, aes, rsa, logger, hookRunner, reauth))
+	root.AddUserCommand(NewPreviewCommand(logger, reauth))
+	root.AddUserCommand(NewAppendCommand(s, keys, aes, rsa, logger, reauth))
+	root.AddUserCommand(NewLockCommand(logger, reauth))
+	root.AddUserCommand(NewUnlockCommand(logger, reauth))
+	root.AddUserCommand(NewRemoveCommand(s, aes, logger, reauth))
+	root.AddUserCommand(NewMoveCommand(s, aes, logger, reauth))
+	root.AddUserCommand(NewCopyCommand(s, aes, logger, reauth))
+	root.AddUserCommand(NewRenameCommand(s, aes, logger, reauth))
+	root.AddUserCommand(NewTreeCommand(s, aes, logger, reauth))
+	root.AddUserCommand(NewShellCommand(s, keys, aes, rsa, logger, reauth))
+	root.AddUserCommand(NewBrowseCommand(s, keys, aes, rsa, logger, reauth))
+	root.AddUserCommand(NewStatCommand(logger, reauth))
+	root.AddUserCommand(NewVerifyCommand(keys, aes, rsa, logger, reauth))
+	root.AddUserCommand(NewEncryptCommand(keys, aes, rsa, logger))
+	root.AddUserCommand(NewDecryptCommand(keys, aes, rsa, logger))
+	root.AddUserCommand(NewSyncCommand(s, keys, aes, rsa, logger, hookRunner, reauth))
+	root.AddGroupCommand(NewVersionsCommand(logger, reauth), NewVersionsGetCommand(keys, aes, rsa, logger, reauth))
+	root.AddGroupCommand(NewTokenCommand(),
+		NewTokenVerifyCommand(logger, reauth),
+		NewTokenSetCommand(s, aes, logger))
+	root.AddUserCommand(NewFindCommand(s, aes, logger))
+	root.AddUserCommand(NewSearchCommand(logger, reauth))
+	root.AddGroupCommand(NewIndexCommand(), NewIndexRebuildCommand(s, aes, logger, reauth))
+	root.AddGroupCommand(NewQueueCommand(),
+		NewQueueListCommand(s, logger),
+		NewQueueFlushCommand(s, aes, logger, reauth))
+	root.AddGroupCommand(NewReplicaCommand(),
+		NewReplicaListCommand(s),
+		NewReplicaAddCommand(s, logger),
+		NewReplicaRemoveCommand(s, logger))
+	root.AddGroupCommand(NewBiometricCommand(),
+		NewBiometricEnableCommand(s, root.biometric, logger),
+		NewBiometricDisableCommand(s, root.biometric, logger))
+	root.AddGroupCommand(NewSessionCommand(),
+		NewSessionUnlockCommand(s, logger),
+		NewSessionLockCommand(s, logger))
+	root.AddGroupCommand(NewKeyringCommand(),
+		NewKeyringEnableCommand(s, root.keyring, logger),
+		NewKeyringDisableCommand(s, root.keyring, logger))
+	root.AddGroupCommand(NewKeysCommand(),
+		NewKeysRotateCommand(s, keys, aes, rsa, logger),
+		NewKeysExportCommand(s, keys, aes, rsa, logger),
+		NewKeysImportCommand(s, keys, aes, rsa, logger),
+		NewKeysRecoveryCodesCommand(s, keys, aes, rsa, logger),
+		NewKeysPIVCommand(s, keys, rsa, logger),
+		NewKeysFingerprintCommand(s, keys, logger))
+	root.AddGroupCommand(NewConfigCommand(),
+		NewConfigUpgradeKeysCommand(s, keys, aes, logger),
+		NewConfigAuthenticateCommand(s, keys, aes, logger))
+	root.AddGroupCommand(NewNamesCommand(),
+		NewNamesEnableCommand(s, keys, aes, rsa, logger),
+		NewNamesListCommand(s, aes, logger))
+	root.AddGroupCommand(NewAliasCommand(),
+		NewAliasSetCommand(s, logger),
+		NewAliasListCommand(s, logger),
+		NewAliasRemoveCommand(s, logger))
+	root.AddPluginCommands(plugin.Discover(os.Getenv("PATH")))
+	NewCompleter(s, aes, logger, root.unlock).Register(root.cmd)
+	root.trackRun(root.cmd)
 
-	if err := root.cmd.Execute(); err != nil {
-		fmt.Printf("\n[ERROR] %v\n", err)
+	args, ok, err := root.expandAlias(os.Args[1:])
+	if err != nil {
+		logger.Error("expanding alias", "error", err)
+		os.Exit(1)
+	}
+	if ok {
+		root.cmd.SetArgs(args)
 	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	cmd, err := root.cmd.ExecuteContextC(ctx)
+	if root.cancel != nil {
+		root.cancel()
+	}
+	// A command that fails does not run the PersistentPostRun.
+	root.PersistentPostRun(cmd, nil)
+	if err != nil {
+		stop()
+		os.Exit(root.report(cmd, err))
+	}
+}
+
+// trackRun wraps the RunE of cmd and of every sub command below it, so the
+// RootCommand knows if a command was run, see report.
+func (c *RootCommand) trackRun(cmd *cobra.Command) {
+	if run := cmd.RunE; run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			c.ran = true
+			return run(cmd, args)
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		c.trackRun(sub)
+	}
+}
+
+// report prints the error of the command cmd to standard error, unless the command
+// already printed it, and returns the exit code of the error, see exitCode. An error
+// cobra returned before the command was run is an error of the command line, it
+// exits with exitUsage.
+func (c *RootCommand) report(cmd *cobra.Command, err error) int {
+	var reportedErr *reportedError
+	switch {
+	case errors.As(err, &reportedErr):
+	case !c.ran:
+		fmt.Fprintln(stderr, "Error:", err)
+		fmt.Fprintf(stderr, "Run '%s --help' for usage\n", cmd.CommandPath())
+		return exitUsage
+	default:
+		c.logger.Error("executing command", "error", err)
+	}
+
+	return exitCode(err)
 }
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// The 'search' command.
+//
+// SearchCommand searches the server for files by name. Unlike the 'find' command,
+// it does not need the local index and always sees the current files.
+type SearchCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	path     string
+	id       string
+	limit    int
+	format   formatFlag
+}
+
+// NewSearchCommand creates and returns a SearchCommand.
+//
+// The path flag (-p, --path) and id flag (-i, --id) are set for the SearchCommand.
+// These flags limit the search to a directory, and every directory below it.
+//
+// The limit flag (-n, --limit) is set for the SearchCommand. This flag sets the
+// maximum number of files printed.
+//
+// The format flag (--format) is set for the SearchCommand. This flag sets the format
+// the files are printed in.
+func NewSearchCommand(logger *logging.Logger, reauth *Reauthenticator) *SearchCommand {
+	searchCmd := &SearchCommand{logger: logger, reauth: reauth}
+
+	searchCmd.cmd = &cobra.Command{
+		Use:   "search <pattern>",
+		Short: "Search the server for files by name",
+		Args:  cobra.ExactArgs(1),
+		RunE:  searchCmd.Run,
+	}
+
+	searchCmd.cmd.Flags().StringVarP(&searchCmd.path, "path", "p", "", "The path of the directory to search within")
+	searchCmd.cmd.Flags().StringVarP(&searchCmd.id, "id", "i", "", "The ID of the directory to search within")
+	searchCmd.cmd.Flags().IntVarP(&searchCmd.limit, "limit", "n", 0, "The maximum number of files, 0 uses the server limit")
+	searchCmd.format.register(searchCmd.cmd)
+
+	return searchCmd
+}
+
+// Command returns the cobra.Command of this SearchCommand.
+func (c *SearchCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *SearchCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *SearchCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *SearchCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this SearchCommand.
+//
+// Run prints the ID and full path of every file with a name that matches the
+// pattern, one file per line, so they can be passed to other commands. Names that
+// contain the pattern match, a '*' in the pattern matches any characters. If more
+// files matched than were returned, a notice is printed to standard error after the
+// results.
+func (c *SearchCommand) Run(cmd *cobra.Command, args []string) error {
+	if c.path != "" && c.id != "" {
+		fmt.Fprintln(stderr, "Only one flag can be set: path (-p, --path) or id (-i, --id)")
+		return errUsage
+	}
+	if c.limit < 0 {
+		fmt.Fprintln(stderr, "Invalid limit (-n, --limit): must be 0 or more")
+		return errUsage
+	}
+	r, err := c.format.renderer()
+	if err != nil {
+		return err
+	}
+
+	dir := location(c.path, c.id)
+	results, err := c.client.Files().Search(cmd.Context(), api.SearchParams{
+		Pattern: args[0],
+		Dir:     dir,
+		Limit:   c.limit,
+	})
+	if err != nil {
+		switch e := err.(type) {
+		case *api.APIError:
+			if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+				return reported(e)
+			}
+			fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+			fmt.Fprintf(stderr, "-> [ARGS] Pattern: %s\n", args[0])
+			if c.id != "" {
+				fmt.Fprintf(stderr, "-> [FLAG] ID: %s\n", c.id)
+			} else if c.path != "" {
+				fmt.Fprintf(stderr, "-> [FLAG] Path: %s\n", c.path)
+			}
+			printAPIErrorHint(e)
+		default:
+			c.logger.Error("searching files", "error", err)
+		}
+		return reported(err)
+	}
+
+	if err := render(r, &searchResult{results}, c.logger); err != nil {
+		return err
+	}
+
+	if len(results.Files) == 0 {
+		fmt.Fprintf(stderr, "No files match '%s'\n", args[0])
+	}
+	if results.Truncated {
+		fmt.Fprintf(stderr, "\nMore files match, narrow the pattern or raise the limit (-n, --limit)\n")
+	}
+
+	return nil
+}
+
+// searchResult is the result of the 'search' command.
+type searchResult struct {
+	*api.SearchResponse
+}
+
+// Items returns the files that matched.
+func (r *searchResult) Items() any {
+	return fileList(r.Files)
+}
+
+// Text writes the ID and full path of every file that matched, one file per line.
+func (r *searchResult) Text(w io.Writer) error {
+	return fileList(r.Files).Text(w)
+}
+
+// fileList is a list of files printed by a command.
+type fileList []api.File
+
+// Text writes the ID and full path of every file, one file per line.
+func (l fileList) Text(w io.Writer) error {
+	for _, f := range l {
+		if _, err := fmt.Fprintf(w, "%s %s\n", f.ID, f.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Table returns a row for every file, with its ID, full path, size, and the time it
+// was last updated.
+func (l fileList) Table() ([]string, [][]string) {
+	rows := make([][]string, len(l))
+	for i, f := range l {
+		rows[i] = []string{f.ID, f.Path, formatBytes(f.Size), formatTime(f.UpdatedAt)}
+	}
+
+	return []string{"ID", "PATH", "SIZE", "UPDATED"}, rows
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// defaultSessionTTL is the time a session is kept if the ttl flag (--ttl) is not set.
+const defaultSessionTTL = time.Hour
+
+// The 'session' command.
+//
+// SessionCommand groups the sub commands that manage the password session of a
+// profile. It does nothing on its own.
+type SessionCommand struct {
+	cmd *cobra.Command
+}
+
+// NewSessionCommand creates and returns a SessionCommand.
+func NewSessionCommand() *SessionCommand {
+	return &SessionCommand{
+		cmd: &cobra.Command{
+			Use:   "session",
+			Short: "Manage the password session",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this SessionCommand.
+func (c *SessionCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'session unlock' command.
+//
+// SessionUnlockCommand starts a session for the active profile. The commands of the
+// profile are not prompted for the password until the session expires.
+type SessionUnlockCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	store    *config.Store
+	logger   *logging.Logger
+	ttl      time.Duration
+}
+
+// NewSessionUnlockCommand creates and returns a SessionUnlockCommand.
+//
+// The ttl flag (--ttl) is set for the SessionUnlockCommand. This flag sets how long
+// the session is kept before it expires.
+func NewSessionUnlockCommand(store *config.Store, logger *logging.Logger) *SessionUnlockCommand {
+	unlockCmd := &SessionUnlockCommand{store: store, logger: logger}
+
+	unlockCmd.cmd = &cobra.Command{
+		Use:   "unlock",
+		Short: "Skip the password prompt until the session expires",
+		Args:  cobra.ExactArgs(0),
+		RunE:  unlockCmd.Run,
+	}
+
+	unlockCmd.cmd.Flags().DurationVar(&unlockCmd.ttl, "ttl", defaultSessionTTL, "How long the session is kept")
+
+	return unlockCmd
+}
+
+// Command returns the cobra.Command of this SessionUnlockCommand.
+func (c *SessionUnlockCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *SessionUnlockCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *SessionUnlockCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this SessionUnlockCommand.
+//
+// Run writes the password of the active profile to its session file, see
+// session.Save. Running it during a session restarts the session with the ttl.
+func (c *SessionUnlockCommand) Run(cmd *cobra.Command, args []string) error {
+	if c.ttl < time.Second || c.ttl > session.MaxTTL {
+		fmt.Fprintf(stderr, "Invalid ttl (--ttl): must be between 1s and %s\n", session.MaxTTL)
+		return errUsage
+	}
+
+	s, err := session.Save(session.Path(c.store.Path, c.store.Profile), session.KeyPath(c.store.Dir()), *c.password, c.ttl)
+	if err != nil {
+		c.logger.Error("saving session", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Session started for profile '%s'\n", c.store.Profile)
+	c.logger.Printf("-> Expires: %s\n", s.ExpiresAt.Local().Format(time.RFC1123))
+	c.logger.Println("Run 'clox session lock' to end it")
+
+	return nil
+}
+
+// The 'session lock' command.
+//
+// SessionLockCommand ends the session of the active profile.
+type SessionLockCommand struct {
+	cmd    *cobra.Command
+	store  *config.Store
+	logger *logging.Logger
+}
+
+// NewSessionLockCommand creates and returns a SessionLockCommand.
+func NewSessionLockCommand(store *config.Store, logger *logging.Logger) *SessionLockCommand {
+	lockCmd := &SessionLockCommand{store: store, logger: logger}
+
+	lockCmd.cmd = &cobra.Command{
+		Use:   "lock",
+		Short: "End the session and prompt for the password again",
+		Args:  cobra.ExactArgs(0),
+		RunE:  lockCmd.Run,
+	}
+
+	return lockCmd
+}
+
+// Command returns the cobra.Command of this SessionLockCommand.
+func (c *SessionLockCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this SessionLockCommand.
+//
+// Run removes the session file of the active profile. Commands will prompt for the
+// password again.
+func (c *SessionLockCommand) Run(cmd *cobra.Command, args []string) error {
+	if err := session.Remove(session.Path(c.store.Path, c.store.Profile), session.KeyPath(c.store.Dir())); err != nil {
+		c.logger.Error("removing session", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Session ended for profile '%s'\n", c.store.Profile)
+
+	return nil
+}
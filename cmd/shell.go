@@ -0,0 +1,408 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/color"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// The 'shell' command.
+//
+// ShellCommand opens an interactive session with the Clox server. The commands of
+// the session operate relative to a current remote directory, and the password is
+// only entered once for the whole session.
+type ShellCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	// cwd is the path of the current remote directory, it always starts with '/'.
+	cwd string
+	// key is the encryption key of the session, it is wiped when the session ends.
+	key secret.Bytes
+}
+
+// NewShellCommand creates and returns a ShellCommand.
+func NewShellCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, reauth *Reauthenticator) *ShellCommand {
+	shellCmd := &ShellCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger, reauth: reauth}
+
+	shellCmd.cmd = &cobra.Command{
+		Use:   "shell",
+		Short: "Open an interactive session in a remote directory",
+		Args:  cobra.ExactArgs(0),
+		RunE:  shellCmd.Run,
+	}
+
+	return shellCmd
+}
+
+// Command returns the cobra.Command of this ShellCommand.
+func (c *ShellCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ShellCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *ShellCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *ShellCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// shellCommands is the help of every command of a session, in the order it is
+// printed.
+var shellCommands = [][2]string{
+	{"ls [<path>]", "List a directory, the current directory by default"},
+	{"cd [<path>]", "Change the current directory, the root directory by default"},
+	{"pwd", "Print the current directory"},
+	{"mkdir <name>...", "Create directories in the current directory"},
+	{"get <path> [<local-path>]", "Download a file, into the local working directory by default"},
+	{"put <local-path> [<name>]", "Upload a file into the current directory"},
+	{"help", "Print the commands"},
+	{"exit", "End the session, the same as end of input (Ctrl-D)"},
+}
+
+// Run is the RunE function of the cobra.Command in this ShellCommand.
+//
+// Run reads commands from standard input, one per line, until the exit command or
+// the end of input. The arguments of a command are split the same as the command
+// line of an alias, see splitCommandLine. A remote path that does not start with
+// '/' is relative to the current directory, which starts at the users root
+// directory. The prompt is only printed if standard input is a terminal, so a
+// session can be scripted.
+//
+// A command that fails prints why and the session continues. The session ends if
+// the server rejects the API token, or the command is interrupted.
+func (c *ShellCommand) Run(cmd *cobra.Command, args []string) error {
+	key, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	c.key = key
+	defer c.key.Wipe()
+	c.cwd = "/"
+
+	interactive := color.IsTerminal(os.Stdin)
+	if interactive {
+		fmt.Fprintln(stdout, "Clox shell, type 'help' for the commands")
+	}
+	for {
+		if interactive {
+			fmt.Fprintf(stdout, "clox:%s> ", c.cwd)
+		}
+		line, err := prompt.ReadLine(os.Stdin)
+		if errors.Is(err, io.EOF) {
+			if interactive {
+				fmt.Fprintln(stdout)
+			}
+			return nil
+		}
+		if err != nil {
+			c.logger.Error("reading command", "error", err)
+			return reported(err)
+		}
+
+		words, err := splitCommandLine(line)
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid command:", err)
+			continue
+		}
+		if len(words) == 0 {
+			continue
+		}
+		if words[0] == "exit" || words[0] == "quit" {
+			return nil
+		}
+
+		if err := c.exec(cmd.Context(), words[0], words[1:]); err != nil {
+			var apiErr *api.APIError
+			if errors.As(err, &apiErr) && c.reauth.Handle(cmd.Context(), apiErr, c.user, *c.password) {
+				return reported(err)
+			}
+			if cmd.Context().Err() != nil {
+				return reported(err)
+			}
+		}
+	}
+}
+
+// exec runs the command of a session with its arguments. A failure is printed and
+// returned.
+func (c *ShellCommand) exec(ctx context.Context, name string, args []string) error {
+	var err error
+	switch name {
+	case "ls":
+		err = c.ls(ctx, args)
+	case "cd":
+		err = c.cd(ctx, args)
+	case "pwd":
+		fmt.Fprintln(stdout, c.cwd)
+	case "mkdir":
+		err = c.mkdir(ctx, args)
+	case "get":
+		err = c.get(ctx, args)
+	case "put":
+		err = c.put(ctx, args)
+	case "help":
+		for _, h := range shellCommands {
+			fmt.Fprintf(stdout, "  %-28s %s\n", h[0], h[1])
+		}
+	default:
+		fmt.Fprintf(stderr, "Error: unknown command '%s'\n", name)
+		fmt.Fprintln(stderr, "-> [HINT] Type 'help' for the commands")
+		return nil
+	}
+	if err != nil {
+		c.printError(err, name, args)
+	}
+
+	return err
+}
+
+// errShellUsage is the error of a command of a session that was given the wrong
+// arguments.
+var errShellUsage = errors.New("wrong arguments")
+
+// resolve returns the absolute remote path of p, relative to the current directory.
+func (c *ShellCommand) resolve(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(c.cwd, p)
+	}
+
+	return path.Clean("/" + p)
+}
+
+// list lists the directory at the absolute path.
+func (c *ShellCommand) list(ctx context.Context, dirPath string) (*api.DirListing, error) {
+	if dirPath == "/" {
+		dirPath = ""
+	}
+
+	return c.client.Dirs().List(ctx, api.Path(dirPath))
+}
+
+// ls prints the directories and files of a directory, each sorted by name. The
+// directories end with '/'.
+func (c *ShellCommand) ls(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errShellUsage
+	}
+	dirPath := c.cwd
+	if len(args) == 1 {
+		dirPath = c.resolve(args[0])
+	}
+
+	listing, err := c.list(ctx, dirPath)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(listing.Dirs, func(i, j int) bool { return listing.Dirs[i].DirName < listing.Dirs[j].DirName })
+	sort.Slice(listing.Files, func(i, j int) bool { return listing.Files[i].Name < listing.Files[j].Name })
+	for _, d := range listing.Dirs {
+		fmt.Fprintf(stdout, "%s/\n", d.DirName)
+	}
+	for _, f := range listing.Files {
+		fmt.Fprintf(stdout, "%s  %s\n", f.Name, formatBytes(f.Size))
+	}
+
+	return nil
+}
+
+// cd changes the current directory, after checking that the directory exists.
+func (c *ShellCommand) cd(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errShellUsage
+	}
+	dirPath := "/"
+	if len(args) == 1 {
+		dirPath = c.resolve(args[0])
+	}
+
+	if _, err := c.list(ctx, dirPath); err != nil {
+		return err
+	}
+	c.cwd = dirPath
+
+	return nil
+}
+
+// mkdir creates a directory for each name in the current directory.
+func (c *ShellCommand) mkdir(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errShellUsage
+	}
+
+	parent := api.Path(c.cwd)
+	if c.cwd == "/" {
+		parent = api.Path("")
+	}
+	created := []api.Dir{}
+	var err error
+	for _, name := range args {
+		var res *api.NewDirResponse
+		res, err = c.client.Dirs().Create(ctx, parent, name)
+		if err != nil {
+			break
+		}
+		created = append(created, *res)
+		fmt.Fprintf(stdout, "Created: %s\n", res.DirPath)
+	}
+
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		for _, d := range created {
+			idx.Add(index.DirEntry(d))
+		}
+	})
+
+	return err
+}
+
+// get downloads the file at the remote path to the local path. The local path is
+// the name of the file in the local working directory by default, or below it if
+// the local path is a directory.
+func (c *ShellCommand) get(ctx context.Context, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errShellUsage
+	}
+
+	remote := c.resolve(args[0])
+	listing, err := c.list(ctx, path.Dir(remote))
+	if err != nil {
+		return err
+	}
+	var file *api.File
+	for i, f := range listing.Files {
+		if f.Name == path.Base(remote) {
+			file = &listing.Files[i]
+			break
+		}
+	}
+	if file == nil {
+		return fmt.Errorf("'%s': %w", remote, errNotFound)
+	}
+
+	output := file.Name
+	if len(args) == 2 {
+		output = args[1]
+		if info, err := os.Stat(output); err == nil && info.IsDir() {
+			output = filepath.Join(output, file.Name)
+		}
+	}
+	if !prompt.ConfirmOverwrite(output) {
+		return nil
+	}
+
+	if err := downloadFile(ctx, c.client.Files(), c.aes, *file, output, c.key); err != nil {
+		return err
+	}
+	trackFiles(c.store, c.aes, *c.password, c.logger, *file)
+	fmt.Fprintf(stdout, "Downloaded: %s -> %s\n", file.Path, output)
+
+	return nil
+}
+
+// put uploads the local file into the current directory, with the name of the
+// local file by default.
+func (c *ShellCommand) put(ctx context.Context, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errShellUsage
+	}
+
+	local := args[0]
+	name := filepath.Base(local)
+	if len(args) == 2 {
+		name = args[1]
+	}
+	if info, err := os.Stat(local); err != nil || info.IsDir() {
+		if err == nil {
+			err = errors.New("is a directory")
+		}
+		return fmt.Errorf("'%s': %w", local, err)
+	}
+
+	dir := api.Path(c.cwd)
+	if c.cwd == "/" {
+		dir = api.Path("")
+	}
+	uploaded, err := uploadFile(ctx, c.client.Uploads(), c.aes, dir, local, name, c.key)
+	if err != nil {
+		return err
+	}
+
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		idx.Add(index.UploadEntry(*uploaded))
+	})
+	trackUploads(c.store, c.aes, *c.password, c.logger, []api.UploadFileResponse{*uploaded})
+	fmt.Fprintf(stdout, "Uploaded: %s -> %s\n", local, uploaded.Path)
+
+	return nil
+}
+
+// uploadFile uploads the local file into the directory with the name, encrypted
+// with the key.
+func uploadFile(ctx context.Context, uploads *api.UploadService, aes *crypto.AES, dir api.Location, local string, name string, key []byte) (*api.UploadFileResponse, error) {
+	res, err := uploads.Create(ctx, dir, api.UploadParams{
+		Uploads: []api.FileUpload{{Path: local, Filename: name}},
+		Key:     key,
+		Alg:     &crypto.ChunkedAES{AES: aes},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Errors) > 0 {
+		return nil, errors.New(res.Errors[0].Error)
+	}
+	if len(res.Uploads) == 0 {
+		return nil, fmt.Errorf("'%s': not uploaded", local)
+	}
+
+	return &res.Uploads[0], nil
+}
+
+// printError prints the error of the command of a session with its arguments.
+func (c *ShellCommand) printError(err error, name string, args []string) {
+	var apiErr *api.APIError
+	switch {
+	case errors.Is(err, errShellUsage):
+		for _, h := range shellCommands {
+			if strings.HasPrefix(h[0], name+" ") || h[0] == name {
+				fmt.Fprintf(stderr, "Usage: %s\n", h[0])
+			}
+		}
+	case errors.As(err, &apiErr):
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", apiErr.StatusCode, apiErr.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] %s %s\n", name, strings.Join(args, " "))
+		printAPIErrorHint(apiErr)
+	default:
+		fmt.Fprintln(stderr, "Error:", err)
+	}
+}
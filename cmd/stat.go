@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/output"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// statResult is the metadata of a file or directory printed by the 'stat' command.
+// Only one of File or Dir is set. Dirs and Files are the number of directories and
+// files immediately within a directory.
+type statResult struct {
+	Type  string    `json:"type"`
+	File  *api.File `json:"file,omitempty"`
+	Dir   *api.Dir  `json:"directory,omitempty"`
+	Dirs  int       `json:"directories,omitempty"`
+	Files int       `json:"files,omitempty"`
+}
+
+// The 'stat' command.
+//
+// StatCommand prints the metadata of a single file or directory on the server.
+type StatCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	id       string
+	json     bool
+	format   formatFlag
+}
+
+// NewStatCommand creates and returns a StatCommand.
+//
+// The id flag (-i, --id) is set for the StatCommand. This flag allows users to get
+// a file or directory by its ID instead of its path.
+//
+// The format flag (--format) is set for the StatCommand. This flag sets the format
+// the metadata is printed in. The json flag (--json) is the same as '--format json'.
+func NewStatCommand(logger *logging.Logger, reauth *Reauthenticator) *StatCommand {
+	statCmd := &StatCommand{logger: logger, reauth: reauth}
+
+	statCmd.cmd = &cobra.Command{
+		Use:   "stat [<path>]",
+		Short: "Print the details of a file or directory",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  statCmd.Run,
+	}
+
+	statCmd.cmd.Flags().StringVarP(&statCmd.id, "id", "i", "", "The ID of the file or directory")
+	statCmd.cmd.Flags().BoolVar(&statCmd.json, "json", false, "Print the details as JSON, the same as '--format json'")
+	statCmd.format.register(statCmd.cmd)
+
+	return statCmd
+}
+
+// Command returns the cobra.Command of this StatCommand.
+func (c *StatCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *StatCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *StatCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *StatCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this StatCommand.
+//
+// Run prints the metadata of the file or directory at the target. The target is a
+// file if the server has a file at it, otherwise it is a directory. As text, each
+// field is printed on its own line, with the values aligned.
+func (c *StatCommand) Run(cmd *cobra.Command, args []string) error {
+	if c.json {
+		if cmd.Flags().Changed("format") {
+			fmt.Fprintln(stderr, "Only one flag can be set: json (--json) or format (--format)")
+			return errUsage
+		}
+		c.format.format = output.JSON
+	}
+	r, err := c.format.renderer()
+	if err != nil {
+		return err
+	}
+
+	target, err := fileLocation(args, c.id)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return errUsage
+	}
+	if !target.IsID() {
+		target = api.Path("/" + strings.Trim(target.Path, "/"))
+	}
+
+	file, listing, err := resolveTarget(cmd.Context(), c.client, target)
+	if err != nil {
+		switch e := err.(type) {
+		case *api.APIError:
+			if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+				return reported(e)
+			}
+			fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+			fmt.Fprintf(stderr, "-> [ARGS] Target: %s\n", target)
+			printAPIErrorHint(e)
+		default:
+			fmt.Fprintf(stderr, "Error: %s: %s\n", target, err)
+		}
+		return reported(err)
+	}
+
+	result := statResult{Type: "file", File: file}
+	if listing != nil {
+		result = statResult{Type: "directory", Dir: &listing.Dir, Dirs: len(listing.Dirs), Files: len(listing.Files)}
+	}
+
+	return render(r, &result, c.logger)
+}
+
+// Table returns the metadata of the file or directory, one field per row, with the
+// values aligned. It is the text of the result as well.
+func (r *statResult) Table() ([]string, [][]string) {
+	if r.File != nil {
+		return nil, fileStatRows(r.File)
+	}
+
+	return nil, dirStatRows(r.Dir, r.Dirs, r.Files)
+}
+
+// fileStatRows returns the metadata of the file, one field per row.
+func fileStatRows(f *api.File) [][]string {
+	rows := [][]string{
+		{"Type:", "file"},
+		{"ID:", f.ID},
+		{"Name:", f.Name},
+		{"Path:", f.Path},
+		{"Owner:", f.OwnerID},
+		{"Parent ID:", f.DirectoryID},
+		{"Size:", fmt.Sprintf("%s (%d bytes)", formatBytes(f.Size), f.Size)},
+	}
+	if f.ContentType != "" {
+		rows = append(rows, []string{"Content Type:", f.ContentType})
+	}
+	if f.ETag != "" {
+		rows = append(rows, []string{"ETag:", f.ETag})
+	}
+	rows = append(rows,
+		[]string{"Uploaded:", formatTime(f.UploadedAt)},
+		[]string{"Updated:", formatTime(f.UpdatedAt)},
+	)
+	if f.Lock != nil && !f.Lock.Expired() {
+		rows = append(rows, []string{"Locked By:", fmt.Sprintf("%s until %s", f.Lock.Username, formatTime(f.Lock.ExpiresAt))})
+	}
+
+	return rows
+}
+
+// dirStatRows returns the metadata of the directory, with the number of directories
+// and files within it, one field per row.
+func dirStatRows(d *api.Dir, dirs int, files int) [][]string {
+	rows := [][]string{
+		{"Type:", "directory"},
+		{"ID:", d.ID},
+		{"Name:", d.DirName},
+		{"Path:", d.DirPath},
+		{"Owner:", d.OwnerID},
+		{"Parent ID:", d.ParentID},
+		{"Contents:", fmt.Sprintf("%d directories, %d files", dirs, files)},
+	}
+	if d.ETag != "" {
+		rows = append(rows, []string{"ETag:", d.ETag})
+	}
+	rows = append(rows,
+		[]string{"Created:", formatTime(d.CreatedAt)},
+		[]string{"Updated:", formatTime(d.UpdatedAt)},
+		[]string{"Last Write:", formatTime(d.LastWrite)},
+	)
+
+	return rows
+}
+
+// formatTime formats t in the local time zone. A zero time is formatted as "-".
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.RFC1123)
+}
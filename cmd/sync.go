@@ -0,0 +1,533 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/hooks"
+	"github.com/cicconee/clox-cli/internal/ignore"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/cicconee/clox-cli/internal/syncer"
+	"github.com/cicconee/clox-cli/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// The 'sync' command.
+//
+// SyncCommand brings a local directory and a remote directory up to date with each
+// other. Files that changed on one side are copied to the other side, files that
+// changed on both sides are left as they are.
+type SyncCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	hooks    *hooks.Runner
+	reauth   *Reauthenticator
+	dryRun   bool
+	prefer   string
+	filters  filterFlags
+}
+
+// NewSyncCommand creates and returns a SyncCommand.
+//
+// The prefer flag (--prefer) is set for the SyncCommand. This flag resolves the
+// conflicts by keeping the 'local' or 'remote' file.
+//
+// The include (--include) and exclude (--exclude) flags are set for the
+// SyncCommand. These flags are glob patterns that select the files that are
+// synced, both can be set more than once.
+func NewSyncCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, hooks *hooks.Runner, reauth *Reauthenticator) *SyncCommand {
+	syncCmd := &SyncCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger, hooks: hooks, reauth: reauth}
+
+	syncCmd.cmd = &cobra.Command{
+		Use:   "sync <local-dir> <remote-path>",
+		Short: "Sync a local directory with a directory on the server",
+		Args:  cobra.ExactArgs(2),
+		RunE:  syncCmd.Run,
+	}
+
+	syncCmd.cmd.Flags().StringVar(&syncCmd.prefer, "prefer", "", "Resolve conflicts by keeping the 'local' or 'remote' file")
+	syncCmd.filters.register(syncCmd.cmd)
+
+	return syncCmd
+}
+
+// Command returns the cobra.Command of this SyncCommand.
+func (c *SyncCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *SyncCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *SyncCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *SyncCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+func (c *SyncCommand) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// Run is the RunE function of the cobra.Command in this SyncCommand.
+//
+// Run compares the files in the local directory with the files in the remote
+// directory, and every directory below them, and makes a plan with syncer.NewPlan.
+// New and changed local files are encrypted and uploaded, new and changed remote
+// files are downloaded and decrypted. Directories that are missing on the server,
+// including the remote directory, are created.
+//
+// Files that changed on both sides, or that are on both sides but were never
+// synced, are conflicts. A conflict is never overwritten, it is printed and the
+// program exits with exitPartialFailure. The same happens when a file fails to be
+// synced. If the prefer flag (--prefer) is set, the conflicts are resolved by
+// uploading the local file or downloading the remote file instead.
+//
+// Every file that is synced is recorded with the path of its local copy, the next
+// sync compares both sides with it. A file that is uploaded is also recorded with
+// the SHA-256 of its contents, a local file that was modified but has the same
+// contents is not uploaded again.
+//
+// If the local directory has a .cloxignore file, the directories and files that
+// match its patterns are left out on both sides. See ignore.Matcher for the format.
+// The include (--include) and exclude (--exclude) flags select the files further,
+// they are matched against the path of each file relative to the synced
+// directories. See ignore.Filter for how they are combined.
+//
+// After the files are synced, the post-sync hook is run with the local and remote
+// directory, and the summary of the sync is written to its standard input, see
+// syncSummary.
+//
+// If the command is a dry run (--dry-run), the plan of what would be uploaded and
+// downloaded is printed instead, nothing is changed on either side.
+func (c *SyncCommand) Run(cmd *cobra.Command, args []string) error {
+	localDir, err := filepath.Abs(args[0])
+	if err != nil {
+		c.logger.Error("resolving local directory", "path", args[0], "error", err)
+		return reported(err)
+	}
+	if info, err := os.Stat(localDir); err != nil || !info.IsDir() {
+		fmt.Fprintf(stderr, "Error: '%s' is not a local directory\n", args[0])
+		return reported(err)
+	}
+	remotePath := "/" + strings.Trim(args[1], "/")
+
+	resolve := syncer.Conflict
+	switch c.prefer {
+	case "":
+	case "local":
+		resolve = syncer.Upload
+	case "remote":
+		resolve = syncer.Download
+	default:
+		fmt.Fprintf(stderr, "Invalid prefer (--prefer): '%s' must be 'local' or 'remote'\n", c.prefer)
+		return errUsage
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	filter, err := c.filters.filter()
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid filter (--include, --exclude):", err)
+		return errUsage
+	}
+
+	ignored, err := ignore.Load(localDir)
+	if err != nil {
+		c.logger.Error("reading ignore file", "path", filepath.Join(localDir, ignore.File), "error", err)
+		return reported(err)
+	}
+	skip := ignore.Rules{ignored, filter}
+
+	remote, err := c.remote(cmd.Context(), c.client, remotePath, skip)
+	if err != nil {
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	local, err := syncer.Scan(localDir, skip)
+	if err != nil {
+		c.logger.Error("reading local directory", "path", localDir, "error", err)
+		return reported(err)
+	}
+
+	tracker, err := loadTracker(c.store, c.aes, *c.password)
+	if err != nil {
+		c.logger.Error("loading tracked files", "error", err)
+		return reported(err)
+	}
+
+	plan := syncer.NewPlan(local, remote, tracker)
+	plan.Resolve(resolve)
+	if c.dryRun {
+		printSyncPlan(plan)
+		return nil
+	}
+
+	s := &syncRun{
+		cmd:        c,
+		ctx:        cmd.Context(),
+		client:     c.client,
+		key:        encryptKey,
+		localDir:   localDir,
+		remote:     remote,
+		tracker:    tracker,
+		dirs:       newDirMaker(cmd.Context(), c.client, remoteDirs(remote)...),
+		uploaded:   []api.UploadFileResponse{},
+		downloaded: []api.File{},
+		synced:     []string{},
+		conflicts:  plan.Filter(syncer.Conflict),
+		failed:     []failure{},
+		unchanged:  len(plan.Unchanged),
+	}
+
+	s.download(plan.Filter(syncer.Download))
+	s.upload(plan.Filter(syncer.Upload))
+	s.save()
+	if s.stopped != nil {
+		return reported(s.stopped)
+	}
+	s.print()
+
+	if err := c.hooks.Run(hooks.PostSync, []string{localDir, remote.Dir.DirPath}, s.summary()); err != nil {
+		c.logger.Warn("running post-sync hook", "error", err)
+	}
+
+	if len(s.failed) > 0 || len(s.conflicts) > 0 {
+		return exitWith(exitPartialFailure)
+	}
+
+	return nil
+}
+
+// remote lists the remote directory at the path and everything below it. If the
+// directory does not exist, it returns an empty syncer.Remote, the directory is
+// created when the first file is uploaded. The directories and files skipped by the
+// rule are left out.
+func (c *SyncCommand) remote(ctx context.Context, client *api.Client, remotePath string, skip ignore.Rule) (*syncer.Remote, error) {
+	tree, err := client.Dirs().Tree(ctx, api.Path(remotePath), 0)
+	if errors.Is(err, api.ErrNotFound) && remotePath != "/" {
+		return &syncer.Remote{
+			Dir:   api.Dir{DirName: path.Base(remotePath), DirPath: remotePath},
+			Dirs:  map[string]api.Dir{},
+			Files: map[string]api.File{},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return syncer.NewRemote(tree, skip), nil
+}
+
+// remoteDirs returns the directories of the remote that exist on the server.
+func remoteDirs(remote *syncer.Remote) []api.Dir {
+	dirs := []api.Dir{}
+	if remote.Dir.ID != "" {
+		dirs = append(dirs, remote.Dir)
+	}
+	for _, d := range remote.Dirs {
+		dirs = append(dirs, d)
+	}
+
+	return dirs
+}
+
+// printError prints the error of a request made by this SyncCommand. If the API
+// token was rejected, the user is offered to enter a new one instead.
+func (c *SyncCommand) printError(cmd *cobra.Command, err error, args []string) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] Local: %s\n", args[0])
+		fmt.Fprintf(stderr, "-> [ARGS] Remote: %s\n", args[1])
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Sync failed:", err)
+	}
+}
+
+// printSyncPlan prints the files the plan would upload and download, and the
+// conflicts it would leave as they are.
+func printSyncPlan(plan *syncer.Plan) {
+	uploads := plan.Filter(syncer.Upload)
+	fmt.Fprintf(stdout, "Upload: %d\n", len(uploads))
+	for _, ch := range uploads {
+		fmt.Fprintf(stdout, "%s (%s)\n", ch.Path, ch.Reason)
+	}
+
+	downloads := plan.Filter(syncer.Download)
+	fmt.Fprintf(stdout, "\nDownload: %d\n", len(downloads))
+	for _, ch := range downloads {
+		fmt.Fprintf(stdout, "%s (%s)\n", ch.Path, ch.Reason)
+	}
+
+	printSyncConflicts(plan.Filter(syncer.Conflict))
+	fmt.Fprintf(stdout, "\nUnchanged: %d\n", len(plan.Unchanged))
+}
+
+// printSyncConflicts prints the conflicts of a sync, and how to resolve them.
+func printSyncConflicts(conflicts []syncer.Change) {
+	if len(conflicts) == 0 {
+		return
+	}
+
+	fmt.Fprintf(stdout, "\nConflicts: %d\n", len(conflicts))
+	for _, ch := range conflicts {
+		fmt.Fprintln(stdout, ch.Path)
+		fmt.Fprintf(stdout, "-> [REASON] %s\n", ch.Reason)
+	}
+	fmt.Fprintln(stdout, "-> [HINT] Keep one side with --prefer local or --prefer remote")
+}
+
+// syncRun is a single sync of a SyncCommand. It holds the state that is built up
+// as the files are synced.
+type syncRun struct {
+	cmd      *SyncCommand
+	ctx      context.Context
+	client   *api.Client
+	key      []byte
+	localDir string
+	remote   *syncer.Remote
+	tracker  *tracking.Tracker
+	dirs     *dirMaker
+	uploaded []api.UploadFileResponse
+	// downloaded are the remote files that were downloaded.
+	downloaded []api.File
+	synced     []string
+	conflicts  []syncer.Change
+	failed     []failure
+	unchanged  int
+	// stopped is the error that stopped the sync, the API token was rejected.
+	stopped error
+}
+
+// download downloads and decrypts the remote file of each change, and writes it to
+// the local directory.
+func (s *syncRun) download(changes []syncer.Change) {
+	for _, ch := range changes {
+		if s.stopped != nil {
+			return
+		}
+		rel := filepath.FromSlash(ch.Path)
+		if !filepath.IsLocal(rel) {
+			s.fail(ch.Path, errUnsafeName)
+			continue
+		}
+
+		output := filepath.Join(s.localDir, rel)
+		if err := downloadFile(s.ctx, s.client.Files(), s.cmd.aes, *ch.Remote, output, s.key); err != nil {
+			s.fail(ch.Path, err)
+			continue
+		}
+
+		s.tracker.Track(ch.Remote.ID, ch.Remote.Path, tracking.LastWrite(*ch.Remote), ch.Remote.ETag)
+		s.tracker.SetLocal(ch.Remote.ID, output)
+		s.cmd.logger.Printf("Downloaded: %s -> %s\n", ch.Remote.Path, output)
+		s.downloaded = append(s.downloaded, *ch.Remote)
+		s.synced = append(s.synced, ch.Path)
+	}
+}
+
+// upload encrypts the local file of each change and uploads it to the server. The
+// files are uploaded with a request per remote directory, files that replace a file
+// on the server are sent with its ETag in a request of their own.
+func (s *syncRun) upload(changes []syncer.Change) {
+	byDir := map[string][]syncer.Change{}
+	for _, ch := range changes {
+		dir := path.Dir(ch.Path)
+		byDir[dir] = append(byDir[dir], ch)
+	}
+
+	dirs := []string{}
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		if s.stopped != nil {
+			return
+		}
+		dirPath := path.Join(s.remote.Dir.DirPath, dir)
+		id, err := s.dirs.Ensure(dirPath)
+		if err != nil {
+			for _, ch := range byDir[dir] {
+				s.fail(ch.Path, err)
+			}
+			continue
+		}
+
+		var added, replaced []syncer.Change
+		for _, ch := range byDir[dir] {
+			if ch.Remote != nil {
+				replaced = append(replaced, ch)
+				continue
+			}
+			added = append(added, ch)
+		}
+
+		s.uploadBatch(id, dirPath, added, false)
+		s.uploadBatch(id, dirPath, replaced, true)
+	}
+}
+
+// uploadBatch uploads the local files of the changes to the remote directory with
+// the id and path, in a single request. If overwrite is set, the files replace the
+// files on the server with the same name.
+func (s *syncRun) uploadBatch(id string, dirPath string, changes []syncer.Change, overwrite bool) {
+	if len(changes) == 0 || s.stopped != nil {
+		return
+	}
+
+	uploads := []api.FileUpload{}
+	byName := map[string]syncer.Change{}
+	hashes := map[string]string{}
+	for _, ch := range changes {
+		u := api.FileUpload{Path: ch.Local.FullPath, Filename: path.Base(ch.Path)}
+		if ch.Remote != nil {
+			u.IfMatch = ch.Remote.ETag
+		}
+		uploads = append(uploads, u)
+		byName[u.Filename] = ch
+
+		hash, err := tracking.HashFile(u.Path)
+		if err != nil {
+			s.cmd.logger.Warn("hashing local file", "path", u.Path, "error", err)
+			continue
+		}
+		hashes[u.Filename] = hash
+	}
+
+	res, err := s.client.Uploads().Create(s.ctx, api.ID(id), api.UploadParams{
+		Uploads:   uploads,
+		Key:       s.key,
+		Alg:       &crypto.ChunkedAES{AES: s.cmd.aes},
+		Overwrite: overwrite,
+	})
+	if err != nil {
+		for _, ch := range changes {
+			s.fail(ch.Path, err)
+		}
+		return
+	}
+
+	for _, u := range res.Uploads {
+		ch := byName[u.Name]
+		s.tracker.Track(u.ID, u.Path, tracking.LastWrite(u.File()), u.ETag)
+		s.tracker.SetLocal(u.ID, ch.Local.FullPath)
+		s.tracker.SetHash(u.ID, hashes[u.Name])
+		s.cmd.logger.Printf("Uploaded: %s -> %s\n", ch.Local.FullPath, u.Path)
+		s.uploaded = append(s.uploaded, u)
+		s.synced = append(s.synced, ch.Path)
+	}
+	for _, e := range res.Errors {
+		s.fail(path.Join(path.Dir(byName[e.FileName].Path), e.FileName), errors.New(e.Error))
+	}
+}
+
+// fail records that the file at the path failed to be synced. If the API token was
+// rejected, the user is offered to enter a new one and the sync stops, nothing else
+// is downloaded or uploaded.
+func (s *syncRun) fail(rel string, err error) {
+	if s.stopped != nil {
+		return
+	}
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) && s.cmd.reauth.Handle(s.ctx, apiErr, s.cmd.user, *s.cmd.password) {
+		s.stopped = err
+		return
+	}
+
+	s.failed = append(s.failed, failure{Path: rel, Err: err})
+}
+
+// save writes the tracked files and the local index. A failed update is logged and
+// never fails the command.
+func (s *syncRun) save() {
+	c := s.cmd
+	if err := s.tracker.Save(c.store.File(trackingFile), c.aes, *c.password); err != nil {
+		c.logger.Warn("saving tracked files", "error", err)
+	}
+
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		for _, d := range s.dirs.created {
+			idx.Add(index.DirEntry(d))
+		}
+		for _, u := range s.uploaded {
+			idx.Add(index.UploadEntry(u))
+		}
+	})
+}
+
+// print prints the summary of this syncRun.
+func (s *syncRun) print() {
+	s.cmd.logger.Printf("\nSynced: %d\n", len(s.synced))
+	s.cmd.logger.Printf("Unchanged: %d\n", s.unchanged)
+
+	printSyncConflicts(s.conflicts)
+
+	if len(s.failed) > 0 {
+		fmt.Fprintf(stdout, "\nErrors: %d\n", len(s.failed))
+		for _, f := range s.failed {
+			fmt.Fprintf(stdout, "%s -> %s\n", f.Path, f.Err)
+		}
+	}
+}
+
+// syncSummary is the result of a syncRun, it is written to the post-sync hook.
+type syncSummary struct {
+	Uploaded   []api.UploadFileResponse `json:"uploaded"`
+	Downloaded []api.File               `json:"downloaded"`
+	// Conflicts are the paths of the files that changed on both sides, relative to
+	// the synced directories.
+	Conflicts []string  `json:"conflicts"`
+	Failed    []failure `json:"failed"`
+	Unchanged int       `json:"unchanged"`
+}
+
+// summary returns the syncSummary of this syncRun.
+func (s *syncRun) summary() syncSummary {
+	conflicts := []string{}
+	for _, ch := range s.conflicts {
+		conflicts = append(conflicts, ch.Path)
+	}
+
+	return syncSummary{
+		Uploaded:   s.uploaded,
+		Downloaded: s.downloaded,
+		Conflicts:  conflicts,
+		Failed:     s.failed,
+		Unchanged:  s.unchanged,
+	}
+}
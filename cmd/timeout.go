@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+)
+
+const (
+	// defaultRequestTimeout is the time limit of a request when the user has not
+	// configured one.
+	defaultRequestTimeout = time.Minute
+	// defaultTransferTimeout is the time limit of a request that uploads or downloads
+	// a file when the user has not configured one.
+	defaultTransferTimeout = time.Hour
+)
+
+// timeouts are the parsed config.Timeouts of a user.
+type timeouts struct {
+	request  time.Duration
+	transfer time.Duration
+	command  time.Duration
+}
+
+// parseTimeouts parses the config.Timeouts. A timeout that is not set is the default,
+// a command has no time limit by default. If a timeout is invalid, it returns the
+// error with the default used in its place.
+func parseTimeouts(t config.Timeouts) (timeouts, error) {
+	var firstErr error
+	parse := func(name string, value string, def time.Duration) time.Duration {
+		d, err := parseTimeout(value, def)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s timeout: %w", name, err)
+			}
+			return def
+		}
+		return d
+	}
+
+	parsed := timeouts{
+		request:  parse("request", t.Request, defaultRequestTimeout),
+		transfer: parse("transfer", t.Transfer, defaultTransferTimeout),
+		command:  parse("command", t.Command, 0),
+	}
+
+	return parsed, firstErr
+}
+
+// parseTimeout parses the duration of a timeout. If value is empty, def is returned.
+func parseTimeout(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("'%s' must be a duration such as 30s or 10m", value)
+	}
+
+	return d, nil
+}
+
+// options returns the api.Option of the request and transfer timeouts.
+func (t timeouts) options() []api.Option {
+	return []api.Option{api.WithTimeout(t.request), api.WithTransferTimeout(t.transfer)}
+}
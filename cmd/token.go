@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// The 'token' command.
+//
+// TokenCommand groups the sub commands that manage the API token. It does nothing
+// on its own.
+type TokenCommand struct {
+	cmd *cobra.Command
+}
+
+// NewTokenCommand creates and returns a TokenCommand.
+func NewTokenCommand() *TokenCommand {
+	return &TokenCommand{
+		cmd: &cobra.Command{
+			Use:   "token",
+			Short: "Manage the API token",
+		},
+	}
+}
+
+// Command returns the cobra.Command of this TokenCommand.
+func (c *TokenCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// The 'token verify' command.
+//
+// TokenVerifyCommand verifies the stored API token with the Clox server without
+// modifying anything.
+type TokenVerifyCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+}
+
+// NewTokenVerifyCommand creates and returns a TokenVerifyCommand.
+func NewTokenVerifyCommand(logger *logging.Logger, reauth *Reauthenticator) *TokenVerifyCommand {
+	verifyCmd := &TokenVerifyCommand{logger: logger, reauth: reauth}
+
+	verifyCmd.cmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the API token with the server",
+		Args:  cobra.ExactArgs(0),
+		RunE:  verifyCmd.Run,
+	}
+
+	return verifyCmd
+}
+
+// Command returns the cobra.Command of this TokenVerifyCommand.
+func (c *TokenVerifyCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *TokenVerifyCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *TokenVerifyCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *TokenVerifyCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this TokenVerifyCommand.
+//
+// Run calls the API to verify the API token of the client. The account, scopes,
+// and expiry of the token are printed.
+func (c *TokenVerifyCommand) Run(cmd *cobra.Command, args []string) error {
+	info, err := c.client.Tokens().Verify(cmd.Context())
+	if err != nil {
+		switch e := err.(type) {
+		case *api.APIError:
+			if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+				return reported(e)
+			}
+			fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+			printAPIErrorHint(e)
+		default:
+			c.logger.Error("verifying api token", "error", err)
+		}
+		return reported(err)
+	}
+
+	expires := "Never"
+	if info.ExpiresAt != nil {
+		expires = info.ExpiresAt.Local().Format(time.RFC1123)
+		if info.ExpiresAt.Before(time.Now()) {
+			expires += " (expired)"
+		}
+	}
+
+	scopes := strings.Join(info.Scopes, ", ")
+	if scopes == "" {
+		scopes = "None"
+	}
+
+	fmt.Fprintf(stdout, "API [%d]: Token Valid\n", 200)
+	fmt.Fprintf(stdout, "-> Account: %s (%s)\n", info.Username, info.OwnerID)
+	fmt.Fprintf(stdout, "-> Token ID: %s\n", info.ID)
+	fmt.Fprintf(stdout, "-> Scopes: %s\n", scopes)
+	fmt.Fprintf(stdout, "-> Expires: %s\n", expires)
+
+	return nil
+}
+
+// The 'token set' command.
+//
+// TokenSetCommand replaces the stored API token, such as after the token was rotated
+// on the server. The keys and the rest of the configuration are not changed.
+type TokenSetCommand struct {
+	cmd        *cobra.Command
+	user       *config.User
+	password   *secret.Bytes
+	store      *config.Store
+	aes        *crypto.AES
+	logger     *logging.Logger
+	tokenStdin bool
+	noVerify   bool
+}
+
+// NewTokenSetCommand creates and returns a TokenSetCommand.
+//
+// The token stdin flag (--token-stdin) is set for the TokenSetCommand. This flag
+// reads the API token from a line of standard input instead of prompting for it.
+//
+// The no verify flag (--no-verify) is set for the TokenSetCommand. This flag stores
+// the API token without verifying it with the server.
+func NewTokenSetCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger) *TokenSetCommand {
+	setCmd := &TokenSetCommand{store: store, aes: aes, logger: logger}
+
+	setCmd.cmd = &cobra.Command{
+		Use:   "set",
+		Short: "Replace the stored API token",
+		Args:  cobra.ExactArgs(0),
+		RunE:  setCmd.Run,
+	}
+
+	setCmd.cmd.Flags().BoolVar(&setCmd.tokenStdin, "token-stdin", false, "Read the API token from standard input")
+	setCmd.cmd.Flags().BoolVar(&setCmd.noVerify, "no-verify", false, "Store the API token without verifying it with the server")
+
+	return setCmd
+}
+
+// Command returns the cobra.Command of this TokenSetCommand.
+func (c *TokenSetCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *TokenSetCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *TokenSetCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+// Run is the RunE function of the cobra.Command in this TokenSetCommand.
+//
+// Run prompts for the new API token, or reads it from standard input, and verifies
+// it with the server unless the no verify flag is set. The token is encrypted with
+// the password and only the API token of the configuration file is replaced.
+//
+// If the password is also read from standard input (--password-stdin), the token
+// is read from the line after it.
+func (c *TokenSetCommand) Run(cmd *cobra.Command, args []string) error {
+	var token secret.Bytes
+	var err error
+	if c.tokenStdin {
+		token, err = prompt.ReadSecret(os.Stdin)
+		token = bytes.TrimSpace(token)
+		if err == nil && len(token) == 0 {
+			err = errors.New("token cannot be empty")
+		}
+	} else {
+		token, err = prompt.ConfigureAPIToken()
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot read the API token:", err)
+		return reported(err)
+	}
+	defer token.Wipe()
+
+	if !c.noVerify {
+		client, err := newUserAPIClient(c.store, c.user, token, c.logger)
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid configuration:", err)
+			return reported(err)
+		}
+
+		info, err := client.Tokens().Verify(cmd.Context())
+		if err != nil {
+			var apiErr *api.APIError
+			if errors.As(err, &apiErr) {
+				fmt.Fprintf(stderr, "API Error [%d]: %s\n", apiErr.StatusCode, apiErr.Err)
+				fmt.Fprintln(stderr, "The new API token was rejected, it was not stored")
+				printAPIErrorHint(apiErr)
+				return reported(err)
+			}
+			c.logger.Error("verifying api token", "error", err)
+			fmt.Fprintln(stderr, "-> [HINT] Use the no verify flag (--no-verify) to store the token without verifying it")
+			return reported(err)
+		}
+		fmt.Fprintf(stdout, "-> Account: %s (%s)\n", info.Username, info.OwnerID)
+	}
+
+	if err := c.user.SetAPIToken(c.aes, *c.password, token); err != nil {
+		c.logger.Error("encrypting api token", "error", err)
+		return reported(err)
+	}
+	if err := c.store.WriteConfigFile(c.user); err != nil {
+		c.logger.Error("writing config file", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("API token updated for profile '%s'\n", c.store.Profile)
+
+	return nil
+}
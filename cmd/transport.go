@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+)
+
+// newHTTPClient creates the *http.Client that the api.Client sends requests with.
+// The connections to the server are configured with the TLS configuration and sent
+// through the proxy, see newTransport.
+//
+// If debugHTTP is set, every request and response is written to the file at the
+// path, see api.DebugTransport. The path "-" is standard error.
+func newHTTPClient(t config.TLS, proxy string, debugHTTP string) (*http.Client, error) {
+	transport, err := newTransport(t, proxy)
+	if err != nil {
+		return nil, err
+	}
+	if debugHTTP == "" {
+		return &http.Client{Transport: transport}, nil
+	}
+
+	w := io.Writer(os.Stderr)
+	if debugHTTP != "-" {
+		f, err := os.OpenFile(debugHTTP, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("opening debug http file: %w", err)
+		}
+		w = f
+	}
+
+	return &http.Client{Transport: &api.DebugTransport{Next: transport, W: w}}, nil
+}
+
+// newTransport creates a copy of http.DefaultTransport that is configured with the
+// TLS configuration. The certificate authorities of the CA file are trusted in
+// addition to those of the system, and the client certificate is presented to the
+// server if it is set.
+//
+// If the proxy is set, every request is sent through it. Otherwise the proxy of the
+// environment is used, the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY variables.
+func newTransport(t config.TLS, proxy string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != "" {
+		u, err := parseProxyURL(proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	if t == (config.TLS{}) {
+		return transport, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		data, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("ca file '%s' has no PEM certificates", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, errors.New("the client certificate and key must be set together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = cfg
+	return transport, nil
+}
+
+// absTLS returns the TLS configuration with the paths of its files made absolute,
+// so they are found from any working directory.
+func absTLS(t config.TLS) (config.TLS, error) {
+	for _, path := range []*string{&t.CAFile, &t.CertFile, &t.KeyFile} {
+		if *path == "" {
+			continue
+		}
+
+		abs, err := filepath.Abs(*path)
+		if err != nil {
+			return config.TLS{}, err
+		}
+		*path = abs
+	}
+
+	return t, nil
+}
+
+// parseProxyURL parses the URL of a proxy. It must be an http, https, or socks5 URL.
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing proxy url: %w", err)
+	}
+
+	scheme := u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "socks5"
+	if !scheme || u.Host == "" {
+		return nil, fmt.Errorf("proxy url '%s' must be an http, https, or socks5 URL", raw)
+	}
+
+	return u, nil
+}
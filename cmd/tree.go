@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/cache"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/spf13/cobra"
+)
+
+// The 'tree' command.
+//
+// TreeCommand prints the remote directory hierarchy as a tree.
+type TreeCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	store    *config.Store
+	aes      *crypto.AES
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	id       string
+	depth    int
+	size     bool
+	refresh  bool
+}
+
+// NewTreeCommand creates and returns a TreeCommand.
+//
+// The id flag (-i, --id) is set for the TreeCommand. This flag allows users to start
+// the tree at a directory by its ID instead of its path.
+//
+// The depth flag (-d, --depth) is set for the TreeCommand. This flag limits how many
+// levels below the directory are printed.
+//
+// The size flag (-s, --size) is set for the TreeCommand. This flag prints the size
+// of every file and directory.
+//
+// The refresh flag (--refresh) is set for the TreeCommand. This flag lists every
+// directory from the server, instead of the cached listings.
+func NewTreeCommand(store *config.Store, aes *crypto.AES, logger *logging.Logger, reauth *Reauthenticator) *TreeCommand {
+	treeCmd := &TreeCommand{store: store, aes: aes, logger: logger, reauth: reauth}
+
+	treeCmd.cmd = &cobra.Command{
+		Use:   "tree [<path>]",
+		Short: "Print the remote directory hierarchy as a tree",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  treeCmd.Run,
+	}
+
+	treeCmd.cmd.Flags().StringVarP(&treeCmd.id, "id", "i", "", "The ID of the directory to start at")
+	treeCmd.cmd.Flags().IntVarP(&treeCmd.depth, "depth", "d", 0, "The number of levels to print, 0 prints every level")
+	treeCmd.cmd.Flags().BoolVarP(&treeCmd.size, "size", "s", false, "Print the size of every file and directory")
+	treeCmd.cmd.Flags().BoolVar(&treeCmd.refresh, "refresh", false, "List every directory from the server instead of the cache")
+
+	return treeCmd
+}
+
+// Command returns the cobra.Command of this TreeCommand.
+func (c *TreeCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *TreeCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *TreeCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *TreeCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this TreeCommand.
+//
+// Run lists the directory at the path, or the users root directory if no path is
+// set, and every directory below it. The directories are printed before the files,
+// each sorted by name. The number of directories and files is printed after the
+// tree.
+//
+// The size of a directory is the total size of the files below it. A directory with
+// levels below it that are deeper than the depth flag (-d, --depth) has no size.
+//
+// A directory that was listed in the last cache.MaxAge is served from the cache of
+// the profile, unless the refresh flag (--refresh) is set. The cache is cleared
+// whenever a command changes the remote directories.
+func (c *TreeCommand) Run(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 && c.id != "" {
+		fmt.Fprintln(stderr, "Only one can be set: <path> or id (-i, --id)")
+		return errUsage
+	}
+	if c.depth < 0 {
+		fmt.Fprintln(stderr, "Invalid depth (-d, --depth): must be 0 or more")
+		return errUsage
+	}
+
+	root := api.Path("")
+	if len(args) == 1 {
+		root = api.Path("/" + strings.Trim(args[0], "/"))
+	}
+	if c.id != "" {
+		root = api.ID(c.id)
+	}
+
+	listings := loadCache(c.store, c.aes, *c.password, c.logger)
+	lister := &cache.Lister{
+		Cache:   listings,
+		Dirs:    c.client.Dirs(),
+		Refresh: c.refresh,
+	}
+	tree, err := api.Tree(cmd.Context(), lister, root, c.depth)
+	saveCache(c.store, c.aes, *c.password, c.logger, listings)
+	if err != nil {
+		switch e := err.(type) {
+		case *api.APIError:
+			if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+				return reported(e)
+			}
+			fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+			fmt.Fprintf(stderr, "-> [ARGS] Directory: %s\n", root)
+			printAPIErrorHint(e)
+		default:
+			fmt.Fprintln(stderr, "Listing failed:", err)
+		}
+		return reported(err)
+	}
+
+	fmt.Fprintln(stdout, c.dirLabel(tree, tree.Dir.DirPath))
+	c.print(tree, "")
+
+	dirs, files := tree.Count()
+	fmt.Fprintf(stdout, "\n%d directories, %d files\n", dirs, files)
+
+	return nil
+}
+
+// print prints the sub directories and files of the node. The prefix is printed
+// before every line, it draws the branches of the parent directories.
+func (c *TreeCommand) print(node *api.TreeNode, prefix string) {
+	total := len(node.Dirs) + len(node.Files)
+	i := 0
+
+	// branch returns the branch of the next entry, and the prefix of the entries
+	// below it.
+	branch := func() (string, string) {
+		i++
+		if i == total {
+			return prefix + "└── ", prefix + "    "
+		}
+		return prefix + "├── ", prefix + "│   "
+	}
+
+	for _, d := range node.Dirs {
+		line, sub := branch()
+		fmt.Fprintln(stdout, line+c.dirLabel(d, d.Dir.DirName+"/"))
+		c.print(d, sub)
+	}
+	for _, f := range node.Files {
+		line, _ := branch()
+		if c.size {
+			fmt.Fprintf(stdout, "%s%s (%s)\n", line, f.Name, formatBytes(f.Size))
+			continue
+		}
+		fmt.Fprintln(stdout, line+f.Name)
+	}
+}
+
+// dirLabel returns the name of the directory node as it is printed in the tree. If
+// the size flag (-s, --size) is set, the size of a directory that was completely
+// listed is added.
+func (c *TreeCommand) dirLabel(node *api.TreeNode, name string) string {
+	if !c.size || !node.Complete() {
+		return name
+	}
+
+	return fmt.Sprintf("%s (%s)", name, formatBytes(node.Size()))
+}
@@ -1,13 +1,27 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/cicconee/clox-cli/internal/api"
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/age"
 	"github.com/cicconee/clox-cli/internal/config"
 	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/hooks"
+	"github.com/cicconee/clox-cli/internal/index"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/queue"
+	"github.com/cicconee/clox-cli/internal/resume"
+	"github.com/cicconee/clox-cli/internal/secret"
 	"github.com/cicconee/clox-cli/internal/security"
 	"github.com/spf13/cobra"
 )
@@ -18,16 +32,46 @@ import (
 // flag are optional, but they can't be used together. If no path or id flag is
 // provided, files will be uploaded to the users root directory.
 type UploadCommand struct {
-	cmd      *cobra.Command
-	user     *config.User
-	password string
-	keys     *security.Keys
-	aes      *crypto.AES
-	rsa      *crypto.RSA
-	path     string
-	id       string
+	cmd       *cobra.Command
+	user      *config.User
+	password  *secret.Bytes
+	client    *api.Client
+	store     *config.Store
+	keys      *security.Keys
+	aes       *crypto.AES
+	rsa       *crypto.RSA
+	logger    *logging.Logger
+	reauth    *Reauthenticator
+	hooks     *hooks.Runner
+	path      string
+	id        string
+	failFast  bool
+	json      bool
+	queue     bool
+	overwrite bool
+	force     bool
+	resumable bool
+	age       bool
+	ageIDs    []string
+	filters   filterFlags
+	progress  progressFlag
+	dryRun    bool
+	// events are the transfer events of the progress flag, they are nil if the
+	// progress is not printed.
+	events api.EventFunc
+	// sources are the age files of the uploads imported from the age format, by
+	// the path of the encrypted file that is uploaded.
+	sources map[string]string
 }
 
+// resumableThreshold is the size of a file above which it is uploaded in parts with
+// a resumable upload session, even if the resumable flag (--resumable) is not set.
+const resumableThreshold = 64 << 20
+
+// sessionsFile is the name of the encrypted file of a profile that records the
+// upload sessions that can be resumed.
+const sessionsFile = "uploads.enc"
+
 // NewUploadCommand creates and returns a UploadCommand.
 //
 // The path flag (-p, --path) is set for the UploadCommand. This flag allows users
@@ -38,18 +82,60 @@ type UploadCommand struct {
 //
 // If neither a path or id flag is set, the files will upload to the users root
 // directory by default. The path and id flags cannot be used together.
-func NewUploadCommand(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA) *UploadCommand {
-	uploadCmd := &UploadCommand{keys: keys, aes: aes, rsa: rsa}
+//
+// The fail fast flag (--fail-fast) is set for the UploadCommand. This flag uploads
+// the files one at a time and stops at the first file that fails.
+//
+// The json flag (--json) is set for the UploadCommand. This flag prints the result
+// as a machine-readable JSON summary.
+//
+// The queue flag (--queue) is set for the UploadCommand. This flag stages the
+// encrypted files locally if the server is unreachable, to be uploaded later with
+// 'clox queue flush'.
+//
+// The overwrite flag (--overwrite) is set for the UploadCommand. This flag replaces
+// the files on the server with the same name. The force flag (--force) is set for
+// the UploadCommand. This flag overwrites files that changed on the server without
+// asking.
+//
+// The include (--include) and exclude (--exclude) flags are set for the
+// UploadCommand. These flags are glob patterns that select the files that are
+// uploaded, both can be set more than once.
+//
+// The progress flag (--progress) is set for the UploadCommand. This flag prints the
+// progress of every file that is uploaded to standard error, see jsonEvents.
+//
+// The resumable flag (--resumable) is set for the UploadCommand. This flag uploads
+// every file in parts, an upload that is interrupted can be resumed by running it
+// again.
+//
+// The age flag (--age) is set for the UploadCommand. This flag uploads files in the
+// age format, they are decrypted with the age identity of the profile. The identity
+// flag (--identity) is set for the UploadCommand. This flag is an age identity file
+// to decrypt the files with as well, it can be set more than once and implies the
+// age flag.
+func NewUploadCommand(store *config.Store, keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, hooks *hooks.Runner, reauth *Reauthenticator) *UploadCommand {
+	uploadCmd := &UploadCommand{store: store, keys: keys, aes: aes, rsa: rsa, logger: logger, hooks: hooks, reauth: reauth}
 
 	uploadCmd.cmd = &cobra.Command{
-		Use:   "upload <file1>:<name1> [<file2>:<name2>...]",
+		Use:   "upload <file1>:<name1>|<pattern> [<file2>:<name2>|<pattern>...]",
 		Short: "Upload files to the server",
 		Args:  cobra.MinimumNArgs(1),
-		Run:   uploadCmd.Run,
+		RunE:  uploadCmd.Run,
 	}
 
 	uploadCmd.cmd.Flags().StringVarP(&uploadCmd.path, "path", "p", "", "The path to upload the files")
 	uploadCmd.cmd.Flags().StringVarP(&uploadCmd.id, "id", "i", "", "The ID of the directory to upload the files")
+	uploadCmd.cmd.Flags().BoolVar(&uploadCmd.failFast, "fail-fast", false, "Stop uploading at the first file that fails")
+	uploadCmd.cmd.Flags().BoolVar(&uploadCmd.json, "json", false, "Print the result as a JSON summary")
+	uploadCmd.cmd.Flags().BoolVar(&uploadCmd.queue, "queue", false, "Queue the files if the server is unreachable")
+	uploadCmd.cmd.Flags().BoolVar(&uploadCmd.overwrite, "overwrite", false, "Replace the files on the server with the same name")
+	uploadCmd.cmd.Flags().BoolVar(&uploadCmd.force, "force", false, "Overwrite files that changed on the server without asking")
+	uploadCmd.cmd.Flags().BoolVar(&uploadCmd.resumable, "resumable", false, "Upload the files in parts that can be resumed")
+	uploadCmd.cmd.Flags().BoolVar(&uploadCmd.age, "age", false, "Decrypt the files from the age format before they are uploaded")
+	uploadCmd.cmd.Flags().StringSliceVar(&uploadCmd.ageIDs, "identity", nil, "An age identity file to decrypt the files with")
+	uploadCmd.filters.register(uploadCmd.cmd)
+	uploadCmd.progress.register(uploadCmd.cmd)
 
 	return uploadCmd
 }
@@ -62,89 +148,684 @@ func (c *UploadCommand) SetUser(user *config.User) {
 	c.user = user
 }
 
-func (c *UploadCommand) SetPassword(password string) {
+func (c *UploadCommand) SetPassword(password *secret.Bytes) {
 	c.password = password
 }
 
-// Run is the Run function of the cobra.Command in this UploadCommand.
+func (c *UploadCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+func (c *UploadCommand) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// Run is the RunE function of the cobra.Command in this UploadCommand.
 //
 // Run will upload files to the Clox server. Users specify the file to upload and
 // the name for the file to be stored on the server. The format is <file>:<name>
 // where <file> is the path to the local file and <name> is the name to be used to
 // store the file on the server. There is no limit on how many file-name pairs can
-// be set. The password is used to decrypt the API token, and then calls the API
-// endpoint to upload files.
+// be set. It calls the API endpoint to upload files with the client of the
+// command.
+//
+// An argument without a name is a glob pattern, such as 'photos/*.jpg'. It is
+// expanded before anything is uploaded, and every file that matches is uploaded
+// with its base name. Two files cannot be uploaded with the same name.
+//
+// If the include flag (--include) is set, only the files with a name that matches
+// one of its patterns are uploaded. A file with a name that matches the exclude flag
+// (--exclude) is never uploaded, even if it matches the include flag.
 //
 // If the path flag (-p, --path) is set, it will upload files to specified directory.
 // If the id flag (-i, --id) is set, it will upload files to the directory with the
 // specified ID. If no flag is set, it will upload files using an empty path. This
 // will default to the users root directory.
-func (c *UploadCommand) Run(cmd *cobra.Command, args []string) {
+//
+// The pre-upload hook is run with the local file paths before anything is
+// uploaded, if it fails the upload is aborted. The post-upload hook is run with the
+// local file paths and the upload result after the files are uploaded.
+//
+// If any file fails to upload, the program exits with exitPartialFailure after the
+// result is printed. If the fail fast flag (--fail-fast) is set, the files after
+// the first failure are skipped.
+//
+// Before anything is encrypted, the files are checked against the limits of the
+// server: the maximum file size, the allowed content types, and the remaining quota.
+// If a file exceeds a limit the upload is aborted, if the upload nearly fills the
+// quota the user is asked to continue.
+//
+// If the queue flag (--queue) is set and the server is unreachable, the files that
+// have not been uploaded are encrypted and staged in the upload queue instead. The
+// files uploaded before the server became unreachable are reported and recorded as
+// usual, the queued files are listed in the result.
+//
+// If the overwrite flag (--overwrite) is set, a file on the server with the same
+// name is replaced. Before it is replaced, the time it was last written on the
+// server is compared with the time recorded when the local copy was fetched or
+// uploaded. If the file changed on the server since, or was never fetched, the user
+// is asked before it is overwritten and the file is skipped if they refuse. The
+// force flag (--force) skips the check. A file locked by another user is never
+// overwritten.
+//
+// Files larger than resumableThreshold, or every file if the resumable flag
+// (--resumable) is set, are uploaded one at a time in parts with a resumable upload
+// session. The session is recorded until the file is uploaded, if the upload fails
+// or is interrupted, running it again sends only the parts the server has not
+// received. A session is only resumed if the local file has not changed. Resumable
+// uploads are never queued.
+//
+// If the age flag (--age) is set, the files are in the age format. Each file is
+// decrypted in memory with the age identity of the profile, or an identity of the
+// identity flag (--identity), and encrypted for the upload, the plain text is never
+// written to disk. See importAge. A file that cannot be decrypted aborts the upload.
+// Files imported from the age format are not uploaded in parts.
+//
+// If the command is a dry run (--dry-run), the files are encrypted but the requests
+// that would upload them are printed instead of the result, with the size of the
+// encrypted body. No session is recorded and the post-upload hook is not run.
+func (c *UploadCommand) Run(cmd *cobra.Command, args []string) error {
 	if c.path != "" && c.id != "" {
-		fmt.Println("Only one flag can be set: path (-p, --path) or id (-i, --id)")
-		return
+		fmt.Fprintln(stderr, "Only one flag can be set: path (-p, --path) or id (-i, --id)")
+		return errUsage
 	}
 
-	token, err := c.user.APIToken(c.aes, c.password)
+	events, err := c.progress.events()
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		return err
+	}
+	if events != nil {
+		// The files imported from the age format are reported by the path of
+		// the age file, the same as the summary.
+		c.events = func(e api.Event) {
+			e.Path = c.source(e.Path)
+			events(e)
+		}
 	}
 
-	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, c.password)
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
 	if err != nil {
-		fmt.Println("Error: Getting Encryption Key:", err)
-		return
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	filter, err := c.filters.filter()
+	if err != nil {
+		fmt.Fprintln(stderr, "Invalid filter (--include, --exclude):", err)
+		return errUsage
 	}
 
-	// Parse the <file>:<name> args.
+	// Parse the <file>:<name> and <pattern> args.
 	uploads := []api.FileUpload{}
+	paths := []string{}
+	names := map[string]string{}
 	for i, a := range args {
-		parts := strings.Split(a, ":")
-		if len(parts) != 2 {
-			fmt.Printf("Invalid syntax [Index: %d, Input: %s]: ", i, a)
-			fmt.Println("Must be in format <file>:<name>")
-			return
+		matched, err := parseUploadArg(a)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid syntax [Index: %d, Input: %s]: ", i, a)
+			fmt.Fprintln(stderr, err)
+			return reported(err)
+		}
+
+		for _, u := range matched {
+			if filter.Match(u.Filename, false) {
+				continue
+			}
+			if prev, ok := names[u.Filename]; ok {
+				fmt.Fprintf(stderr, "Duplicate name '%s': %s and %s\n", u.Filename, prev, u.Path)
+				fmt.Fprintln(stderr, "-> [HINT] Use <file>:<name> to upload one of them with another name")
+				return errUsage
+			}
+			names[u.Filename] = u.Path
+			uploads = append(uploads, u)
+			paths = append(paths, u.Path)
+		}
+	}
+
+	if len(uploads) == 0 {
+		fmt.Fprintln(stdout, "No files to upload: every file is skipped by --include or --exclude")
+		return nil
+	}
+
+	if err := c.hooks.Run(hooks.PreUpload, paths, nil); err != nil {
+		fmt.Fprintln(stderr, "Upload aborted:", err)
+		return reported(err)
+	}
+
+	if c.age || len(c.ageIDs) > 0 {
+		ids, err := readAgeIdentities(encryptKey, c.ageIDs)
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid identity (--identity):", err)
+			return errUsage
+		}
+		dir, err := os.MkdirTemp("", "clox-age-*")
+		if err != nil {
+			c.logger.Error("creating temporary directory", "error", err)
+			return reported(err)
+		}
+		defer os.RemoveAll(dir)
+
+		uploads, c.sources, err = c.importAge(uploads, ids, encryptKey, dir)
+		if err != nil {
+			fmt.Fprintln(stderr, "Cannot import age file:", err)
+			if errors.Is(err, age.ErrIncorrectIdentity) {
+				fmt.Fprintln(stderr, "-> [HINT] Set the identity file the file was encrypted for with --identity")
+			}
+			return reported(err)
+		}
+	}
+
+	summary := uploadSummary{
+		Uploaded: []api.UploadFileResponse{},
+		Failed:   []api.UploadErrorResponse{},
+		Skipped:  []string{},
+	}
+
+	if !c.validateLimits(cmd, c.client, uploads) {
+		return reported(nil)
+	}
+
+	// Files that would overwrite a change on the server are refused before
+	// anything is uploaded.
+	if c.overwrite {
+		listing, err := c.client.Dirs().List(cmd.Context(), location(c.path, c.id))
+		if err != nil {
+			c.printError(cmd, err, args)
+			return reported(err)
+		}
+
+		tracker, err := loadTracker(c.store, c.aes, *c.password)
+		if err != nil {
+			c.logger.Error("loading tracked files", "error", err)
+			return reported(err)
+		}
+
+		// The owner of the token is only needed to tell which locks are held by
+		// someone else.
+		var self string
+		if lockedFiles(listing) {
+			info, err := c.client.Tokens().Verify(cmd.Context())
+			if err != nil {
+				c.printError(cmd, err, args)
+				return reported(err)
+			}
+			self = info.OwnerID
+		}
+
+		var refused []string
+		uploads, refused = resolveConflicts(tracker, listing, uploads, c.force, self)
+		summary.Skipped = append(summary.Skipped, refused...)
+	}
+
+	var resumable []api.FileUpload
+	resumable, uploads = c.splitResumable(uploads)
+	if len(resumable) > 0 {
+		ok, err := c.uploadResumable(cmd, c.client, resumable, encryptKey, &summary)
+		if err != nil {
+			return err
+		}
+		if !ok && c.failFast {
+			for _, u := range uploads {
+				summary.Skipped = append(summary.Skipped, u.Path)
+			}
+			uploads = nil
+		}
+	}
+
+	// Uploading with fail fast sends each file in its own request so the upload
+	// can stop at the first failure.
+	batches := [][]api.FileUpload{uploads}
+	if c.failFast {
+		batches = [][]api.FileUpload{}
+		for _, u := range uploads {
+			batches = append(batches, []api.FileUpload{u})
 		}
-		uploads = append(uploads, api.FileUpload{Path: parts[0], Filename: parts[1]})
 	}
 
-	// Create the HTTP client and do the request.
-	client := &http.Client{}
-	uploadParams := api.UploadParams{
-		BaseURL: "http://localhost:8081",
-		Token:   token,
-		Uploads: uploads,
-		Key:     encryptKey,
-		Alg:     c.aes,
+	if len(uploads) == 0 {
+		batches = nil
 	}
-	var res *api.UploadResponse
-	var rErr error
-	if c.path != "" || (c.path == "" && c.id == "") {
-		res, rErr = api.UploadWithPath(client, c.path, uploadParams)
+	var queued []api.FileUpload
+	for i, batch := range batches {
+		res, rErr := c.client.Uploads().Create(cmd.Context(), location(c.path, c.id), api.UploadParams{
+			Uploads:   batch,
+			Key:       encryptKey,
+			Alg:       &crypto.ChunkedAES{AES: c.aes},
+			Overwrite: c.overwrite,
+			Events:    c.events,
+		})
+		if rErr != nil && c.queue && errors.Is(rErr, api.ErrUnreachable) {
+			queued = uploads[i:]
+			break
+		}
+		if rErr != nil && i == 0 && len(resumable) == 0 {
+			c.printError(cmd, rErr, args)
+			return reported(rErr)
+		}
+		if rErr != nil {
+			res = &api.UploadResponse{Errors: []api.UploadErrorResponse{}}
+			for _, u := range batch {
+				res.Errors = append(res.Errors, api.UploadErrorResponse{FileName: u.Filename, Error: rErr.Error()})
+			}
+		}
+
+		if c.dryRun {
+			dir := "/" + strings.Trim(c.path, "/")
+			if c.id != "" {
+				dir = c.id
+			}
+			printDryRunUploads(dir, batch)
+			continue
+		}
+
+		summary.Uploaded = append(summary.Uploaded, res.Uploads...)
+		summary.Failed = append(summary.Failed, res.Errors...)
+		if c.failFast && len(res.Errors) > 0 {
+			for _, u := range uploads[i+1:] {
+				summary.Skipped = append(summary.Skipped, u.Path)
+			}
+			break
+		}
+	}
+
+	// The files that were not uploaded before the server became unreachable are
+	// queued, the files already uploaded are still reported and recorded.
+	if len(queued) > 0 {
+		c.logger.Warn("server unreachable, queueing uploads", "files", len(queued))
+		items, err := c.enqueue(queued, encryptKey)
+		summary.Queued = items
+		for _, u := range queued[len(items):] {
+			summary.Failed = append(summary.Failed, api.UploadErrorResponse{FileName: u.Filename, Error: err.Error()})
+		}
+	}
+
+	// The files imported from the age format are reported by the path of the age
+	// file, not the encrypted file that was uploaded.
+	for i, p := range summary.Skipped {
+		summary.Skipped[i] = c.source(p)
+	}
+
+	if c.dryRun {
+		return nil
+	}
+
+	if c.json {
+		if err := json.NewEncoder(stdout).Encode(&summary); err != nil {
+			c.logger.Error("encoding summary", "error", err)
+		}
 	} else {
-		res, rErr = api.UploadWithID(client, c.id, uploadParams)
-	}
-	if rErr != nil {
-		switch e := rErr.(type) {
-		case *api.APIError:
-			fmt.Printf("API Error [%d]: %s\n", e.StatusCode, e.Err)
-			fmt.Printf("-> [ARGS] Uploads: %v\n", args)
-			fmt.Printf("-> [FLAG] Path: %s\n", c.path)
-			fmt.Printf("-> [FLAG] Directory ID: %s\n", c.id)
-		default:
-			fmt.Printf("Error: %v\n", rErr)
+		summary.print(c.logger)
+	}
+
+	updateIndex(c.store, c.aes, *c.password, c.logger, func(idx *index.Index) {
+		for _, u := range summary.Uploaded {
+			idx.Add(index.UploadEntry(u))
+		}
+	})
+	trackUploads(c.store, c.aes, *c.password, c.logger, summary.Uploaded)
+
+	res := &api.UploadResponse{Uploads: summary.Uploaded, Errors: summary.Failed}
+	if err := c.hooks.Run(hooks.PostUpload, paths, res); err != nil {
+		c.logger.Warn("running post-upload hook", "error", err)
+	}
+
+	if summary.partial() {
+		return exitWith(exitPartialFailure)
+	}
+
+	return nil
+}
+
+// parseUploadArg parses an argument of the 'upload' command. An argument in the
+// format <file>:<name> is a single file uploaded with the name. Any other argument
+// is a glob pattern, see filepath.Glob, and every regular file that matches it is
+// uploaded with its base name. It is an error if nothing matches the pattern.
+func parseUploadArg(arg string) ([]api.FileUpload, error) {
+	if strings.Contains(arg, ":") {
+		parts := strings.Split(arg, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("must be in format <file>:<name> or <pattern>")
+		}
+		return []api.FileUpload{{Path: parts[0], Filename: parts[1]}}, nil
+	}
+
+	matches, err := filepath.Glob(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	uploads := []api.FileUpload{}
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		uploads = append(uploads, api.FileUpload{Path: m, Filename: filepath.Base(m)})
+	}
+	if len(uploads) == 0 {
+		return nil, errors.New("no files match the pattern")
+	}
+
+	return uploads, nil
+}
+
+// splitResumable splits the uploads into the files that are uploaded in parts with
+// resumable upload sessions, and the other files. A file that is already encrypted
+// is never uploaded in parts, the parts are encrypted as they are sent.
+func (c *UploadCommand) splitResumable(uploads []api.FileUpload) ([]api.FileUpload, []api.FileUpload) {
+	resumable, rest := []api.FileUpload{}, []api.FileUpload{}
+	for _, u := range uploads {
+		info, err := os.Stat(u.Path)
+		if err == nil && !u.Encrypted && info.Size() > 0 && (c.resumable || info.Size() > resumableThreshold) {
+			resumable = append(resumable, u)
+			continue
+		}
+		rest = append(rest, u)
+	}
+
+	return resumable, rest
+}
+
+// uploadResumable uploads each file in parts with a resumable upload session, and
+// adds the result to the summary. A recorded session of a file is resumed, a new
+// session is recorded before any part is sent and removed when the file is
+// uploaded. It returns false if any file failed, if the fail fast flag
+// (--fail-fast) is set the files after it are skipped. If the API token was
+// rejected, the user is offered to enter a new one and the error is returned.
+//
+// Each file is encrypted with a new data key, which is recorded with its session so
+// the parts sent by a resumed upload are encrypted with the same key.
+func (c *UploadCommand) uploadResumable(cmd *cobra.Command, client *api.Client, uploads []api.FileUpload, key []byte, summary *uploadSummary) (bool, error) {
+	sessions, err := resume.Load(c.store.File(sessionsFile), c.aes, *c.password)
+	if err != nil {
+		c.logger.Warn("loading upload sessions", "error", err)
+		sessions = resume.New()
+	}
+
+	dir := location(c.path, c.id)
+	ok := true
+	for i, u := range uploads {
+		local, err := filepath.Abs(u.Path)
+		if err != nil {
+			local = u.Path
+		}
+		info, err := os.Stat(u.Path)
+		if err != nil {
+			summary.Failed = append(summary.Failed, api.UploadErrorResponse{FileName: u.Filename, Error: err.Error()})
+			ok = false
+			continue
+		}
+
+		prev, resumed := sessions.Find(local, dir.String(), u.Filename, info.Size(), info.ModTime())
+		if resumed && prev.DataKey == nil {
+			c.logger.Debug("upload session has no data key, starting over", "id", prev.ID, "path", u.Path)
+			prev, resumed = resume.Session{}, false
+		}
+
+		// The parts of a resumed upload are encrypted with the data key the session
+		// was started with.
+		var dataKey *crypto.DataKey
+		if resumed {
+			c.logger.Debug("resuming upload session", "id", prev.ID, "path", u.Path)
+			dataKey, err = c.aes.OpenDataKey(prev.DataKey, key)
+		} else {
+			dataKey, err = c.aes.NewDataKey(key)
 		}
+		if err != nil {
+			summary.Failed = append(summary.Failed, api.UploadErrorResponse{FileName: u.Filename, Error: err.Error()})
+			ok = false
+			continue
+		}
+
+		res, err := client.Uploads().Resumable(cmd.Context(), dir, api.ResumableParams{
+			Upload:    u,
+			Key:       key,
+			Alg:       &crypto.ChunkedAES{AES: c.aes, DataKey: dataKey},
+			Overwrite: c.overwrite,
+			SessionID: prev.ID,
+			Events:    c.events,
+			Started: func(s *api.UploadSession) {
+				sessions.Add(resume.Session{
+					ID:      s.ID,
+					Local:   local,
+					Dir:     dir.String(),
+					Name:    u.Filename,
+					Size:    info.Size(),
+					ModTime: info.ModTime(),
+					Started: time.Now(),
+					DataKey: dataKey.Header,
+				})
+				c.saveSessions(sessions)
+			},
+		})
+		if err != nil {
+			var apiErr *api.APIError
+			if errors.As(err, &apiErr) && c.reauth.Handle(cmd.Context(), apiErr, c.user, *c.password) {
+				return false, reported(err)
+			}
+			summary.Failed = append(summary.Failed, api.UploadErrorResponse{FileName: u.Filename, Error: err.Error()})
+			summary.resumable++
+			ok = false
+			if c.failFast {
+				for _, s := range uploads[i+1:] {
+					summary.Skipped = append(summary.Skipped, s.Path)
+				}
+				return false, nil
+			}
+			continue
+		}
+
+		sessions.Remove(local, dir.String(), u.Filename)
+		c.saveSessions(sessions)
+		summary.Uploaded = append(summary.Uploaded, *res)
+	}
+
+	return ok, nil
+}
+
+// printDryRunUploads prints the files that the upload request of a dry run would
+// upload into the directory, by their local path.
+func printDryRunUploads(dir string, uploads []api.FileUpload) {
+	for _, u := range uploads {
+		fmt.Fprintf(stdout, "-> %s -> %s\n", u.Path, path.Join(dir, u.Filename))
+	}
+}
+
+// saveSessions writes the upload sessions. A failed write is logged, the upload
+// cannot be resumed but it is not failed.
+func (c *UploadCommand) saveSessions(sessions *resume.Manifest) {
+	if c.dryRun {
 		return
 	}
+	if err := sessions.Save(c.store.File(sessionsFile), c.aes, *c.password); err != nil {
+		c.logger.Warn("saving upload sessions", "error", err)
+	}
+}
+
+// validateLimits checks the uploads against the limits of the server before
+// anything is encrypted. If an upload will be rejected, the reasons are printed and
+// it returns false. If an upload may be rejected, the user is asked to continue.
+//
+// The check is skipped if the server does not report its limits or cannot be
+// reached, the server still enforces them.
+func (c *UploadCommand) validateLimits(cmd *cobra.Command, client *api.Client, uploads []api.FileUpload) bool {
+	limits, err := client.Uploads().Limits(cmd.Context())
+	if err != nil {
+		c.logger.Debug("getting upload limits", "error", err)
+		return true
+	}
+
+	check, err := checkLimits(limits, uploads)
+	if err != nil {
+		c.logger.Error("checking upload limits", "error", err)
+		return false
+	}
+
+	if len(check.problems) > 0 {
+		fmt.Fprintln(stderr, "Upload exceeds the server limits")
+		for _, p := range check.problems {
+			fmt.Fprintf(stderr, "-> [LIMIT] %s\n", p)
+		}
+		return false
+	}
 
-	fmt.Printf("\nUploaded: %d\n", len(res.Uploads))
-	for _, u := range res.Uploads {
-		fmt.Printf("%s -> %s\n", u.ID, u.Path)
+	for _, w := range check.warnings {
+		fmt.Fprintf(stderr, "-> [WARN] %s\n", w)
+	}
+	if len(check.warnings) > 0 && !prompt.Confirm("Continue the upload?") {
+		return false
+	}
+
+	return true
+}
+
+// printError prints the error of a request made by this UploadCommand. If the API
+// token was rejected, the user is offered to enter a new one instead.
+func (c *UploadCommand) printError(cmd *cobra.Command, err error, args []string) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] Uploads: %v\n", args)
+		fmt.Fprintf(stderr, "-> [FLAG] Path: %s\n", c.path)
+		fmt.Fprintf(stderr, "-> [FLAG] Directory ID: %s\n", c.id)
+		printAPIErrorHint(e)
+	default:
+		c.logger.Error("uploading files", "error", err)
+	}
+}
+
+// enqueue encrypts the uploads with the key and stages them in the upload queue of
+// the active profile. The uploads are staged for the directory of the path and id
+// flags. It returns the items staged before an upload fails to be staged.
+func (c *UploadCommand) enqueue(uploads []api.FileUpload, key []byte) ([]queue.Item, error) {
+	q := &queue.Queue{Dir: c.store.File(queueDir)}
+	dir := location(c.path, c.id)
+
+	items := []queue.Item{}
+	for _, u := range uploads {
+		item, err := c.queueFile(q, dir, u, key)
+		if err != nil {
+			c.logger.Error("queueing file", "path", u.Path, "error", err)
+			return items, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// queueFile stages the upload u in the queue q for the directory dir. The file is
+// encrypted with the key as it is read, an upload that is already encrypted is
+// staged as it is.
+func (c *UploadCommand) queueFile(q *queue.Queue, dir api.Location, u api.FileUpload, key []byte) (queue.Item, error) {
+	f, err := os.Open(u.Path)
+	if err != nil {
+		return queue.Item{}, err
+	}
+	defer f.Close()
+
+	if u.Encrypted {
+		return q.Add(c.source(u.Path), u.Filename, u.ContentType, u.Checksum, dir, func(w io.Writer) error {
+			_, err := io.Copy(w, f)
+			return err
+		})
+	}
+
+	// The content type and checksum are of the plain text contents, the file is
+	// read again to encrypt it.
+	head := make([]byte, 512)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return queue.Item{}, err
+	}
+	sum, err := c.aes.Checksum(f, key)
+	if err != nil {
+		return queue.Item{}, fmt.Errorf("computing checksum: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return queue.Item{}, err
 	}
 
-	fmt.Printf("\nErrors: %d\n", len(res.Errors))
-	for _, e := range res.Errors {
-		fmt.Printf("%s -> %s\n", e.FileName, e.Error)
+	chunked := &crypto.ChunkedAES{AES: c.aes}
+	return q.Add(u.Path, u.Filename, api.DetectContentType(u.Filename, head[:n]), sum, dir, func(w io.Writer) error {
+		_, err := chunked.EncryptStream(w, f, key)
+		return err
+	})
+}
+
+// source returns the age file that the upload at path was imported from, or path if
+// it was not imported from the age format.
+func (c *UploadCommand) source(path string) string {
+	if src, ok := c.sources[path]; ok {
+		return src
+	}
+
+	return path
+}
+
+// uploadSummary is the result of an upload. It is the machine-readable summary
+// printed with the json flag (--json).
+type uploadSummary struct {
+	Uploaded []api.UploadFileResponse  `json:"uploaded"`
+	Failed   []api.UploadErrorResponse `json:"failed"`
+	Skipped  []string                  `json:"skipped"`
+	// Unchanged are the local files that were not uploaded, the files on the
+	// server already have the same contents.
+	Unchanged []string `json:"unchanged,omitempty"`
+	// Queued are the files staged in the upload queue, the server was unreachable
+	// when they were uploaded.
+	Queued []queue.Item `json:"queued,omitempty"`
+	// resumable is the number of failed files that were uploaded in parts, they
+	// can be resumed.
+	resumable int
+}
+
+// partial checks if any file in this uploadSummary failed or was skipped.
+func (s *uploadSummary) partial() bool {
+	return len(s.Failed) > 0 || len(s.Skipped) > 0
+}
+
+// print prints this uploadSummary in a human readable format.
+func (s *uploadSummary) print(logger *logging.Logger) {
+	logger.Printf("\nUploaded: %d\n", len(s.Uploaded))
+	for _, u := range s.Uploaded {
+		if u.ContentType == "" {
+			logger.Printf("%s -> %s\n", u.ID, u.Path)
+			continue
+		}
+		logger.Printf("%s -> %s (%s)\n", u.ID, u.Path, u.ContentType)
+	}
+
+	if len(s.Failed) > 0 || !logger.Quiet() {
+		fmt.Fprintf(stdout, "\nErrors: %d\n", len(s.Failed))
+	}
+	for _, e := range s.Failed {
+		fmt.Fprintf(stdout, "%s -> %s\n", e.FileName, e.Error)
+	}
+	if s.resumable > 0 {
+		fmt.Fprintln(stdout, "-> [HINT] Run the same upload again to resume the files uploaded in parts")
+	}
+
+	if len(s.Skipped) > 0 {
+		logger.Printf("\nSkipped: %d\n", len(s.Skipped))
+		for _, p := range s.Skipped {
+			logger.Println(p)
+		}
+	}
+
+	if len(s.Unchanged) > 0 {
+		logger.Printf("\nUnchanged: %d\n", len(s.Unchanged))
+	}
+
+	if len(s.Queued) > 0 {
+		logger.Printf("\nQueued: %d\n", len(s.Queued))
+		for _, item := range s.Queued {
+			logger.Printf("%s -> %s\n", item.ID, item.Source)
+		}
+		logger.Println("Run 'clox queue flush' to upload the queued files")
 	}
 }
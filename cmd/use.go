@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+// The 'use' command.
+//
+// UseCommand persistently selects the profile used by every other command.
+type UseCommand struct {
+	cmd    *cobra.Command
+	store  *config.Store
+	logger *logging.Logger
+}
+
+// NewUseCommand creates and returns a UseCommand.
+func NewUseCommand(store *config.Store, logger *logging.Logger) *UseCommand {
+	useCmd := &UseCommand{store: store, logger: logger}
+
+	useCmd.cmd = &cobra.Command{
+		Use:   "use [<profile> | -]",
+		Short: "Switch the default profile",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  useCmd.Run,
+	}
+
+	return useCmd
+}
+
+// Command returns the cobra.Command of this UseCommand.
+func (c *UseCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+// Run is the RunE function of the cobra.Command in this UseCommand.
+//
+// Run selects the profile as the default profile and prints the profile that is now
+// active. If the profile is "-", the previously selected profile is selected. If no
+// profile is given, the active profile is printed.
+//
+// A profile that is not configured can still be selected, a message is printed
+// explaining that 'clox init' must be run to configure it.
+func (c *UseCommand) Run(cmd *cobra.Command, args []string) error {
+	current, previous, err := c.store.DefaultProfile()
+	if err != nil {
+		c.logger.Error("reading default profile", "error", err)
+		return reported(err)
+	}
+
+	if len(args) == 0 {
+		c.printActive(current)
+		return nil
+	}
+
+	profile := args[0]
+	if profile == "-" {
+		if previous == "" {
+			fmt.Fprintln(stderr, "No previous profile to switch to")
+			return reported(nil)
+		}
+		profile = previous
+	}
+
+	if err := config.ValidateProfileName(profile); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return reported(err)
+	}
+
+	if err := c.store.SetDefaultProfile(profile); err != nil {
+		c.logger.Error("setting default profile", "error", err)
+		return reported(err)
+	}
+
+	c.logger.Printf("Switched to profile '%s'\n", profile)
+	c.printActive(profile)
+
+	return nil
+}
+
+// printActive prints the profile, the server, and the configuration file that are
+// used by the commands.
+func (c *UseCommand) printActive(profile string) {
+	fmt.Fprintf(stdout, "-> Profile: %s\n", profile)
+	fmt.Fprintf(stdout, "-> Server: %s\n", profileServer(c.store, profile))
+	fmt.Fprintf(stdout, "-> Config: %s\n", filepath.Join(c.store.ProfileDir(profile), "config.json"))
+	if !c.store.ProfileExists(profile) {
+		fmt.Fprintln(stdout, "Profile not configured")
+		fmt.Fprintln(stdout, "Run 'clox init' to configure the profile")
+	}
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// The 'verify' command.
+//
+// VerifyCommand audits the files of a directory on the Clox server, and everything
+// below it. Every file is downloaded, decrypted, and checked against the checksum
+// it was uploaded with. Nothing is written locally.
+type VerifyCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	transfer transferFlags
+}
+
+// NewVerifyCommand creates and returns a VerifyCommand.
+//
+// The parallel (--parallel) and fail fast (--fail-fast) flags are set for the
+// VerifyCommand. These flags set how many files are verified at the same time, and
+// stop at the first file that fails.
+func NewVerifyCommand(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, reauth *Reauthenticator) *VerifyCommand {
+	verifyCmd := &VerifyCommand{keys: keys, aes: aes, rsa: rsa, logger: logger, reauth: reauth}
+
+	verifyCmd.cmd = &cobra.Command{
+		Use:   "verify [<remote-path|id>]",
+		Short: "Check the files of a directory against their checksums",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  verifyCmd.Run,
+	}
+
+	verifyCmd.transfer.register(verifyCmd.cmd)
+
+	return verifyCmd
+}
+
+// Command returns the cobra.Command of this VerifyCommand.
+func (c *VerifyCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *VerifyCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *VerifyCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *VerifyCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// verifySummary is the result of a verify.
+type verifySummary struct {
+	Verified []string
+	// Unverified are the files without a checksum, they were uploaded before files
+	// had one, or appended to.
+	Unverified []string
+	Mismatched []string
+	Canceled   []string
+	Failed     []failure
+}
+
+// Run is the RunE function of the cobra.Command in this VerifyCommand.
+//
+// Run lists the remote directory and every directory below it, and verifies each
+// file, see verifyChecksum. Without an argument, every file of the user is
+// verified. An argument that starts with a '/' is a path, otherwise it is an ID.
+//
+// A file that cannot be decrypted is failed, decrypting also authenticates the
+// contents. A file without a checksum is decrypted but not verified. If any file
+// does not match its checksum, the program exits with exitChecksumMismatch after
+// the result is printed. Otherwise, if any file failed, the program exits with
+// exitPartialFailure.
+func (c *VerifyCommand) Run(cmd *cobra.Command, args []string) error {
+	root := api.Path("")
+	if len(args) > 0 {
+		root = argLocation(args[0])
+	}
+
+	if err := c.transfer.validate(); err != nil {
+		fmt.Fprintln(stderr, "Invalid flag:", err)
+		return errUsage
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	tree, err := c.client.Dirs().Tree(cmd.Context(), root, 0)
+	if err != nil {
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	files := treeFiles(tree)
+	errs := c.transfer.run(cmd.Context(), len(files), func(ctx context.Context, i int) error {
+		return c.verify(ctx, files[i], encryptKey)
+	})
+
+	summary := &verifySummary{Verified: []string{}, Unverified: []string{}, Mismatched: []string{}, Canceled: []string{}, Failed: []failure{}}
+	for i, file := range files {
+		err := errs[i]
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) && c.reauth.Handle(cmd.Context(), apiErr, c.user, *c.password) {
+			return reported(apiErr)
+		}
+
+		switch {
+		case errors.Is(err, errCanceled):
+			summary.Canceled = append(summary.Canceled, file.Path)
+		case errors.Is(err, crypto.ErrChecksumMismatch):
+			summary.Mismatched = append(summary.Mismatched, file.Path)
+		case err != nil:
+			summary.Failed = append(summary.Failed, failure{Path: file.Path, Err: err})
+		case file.Checksum == "":
+			summary.Unverified = append(summary.Unverified, file.Path)
+		default:
+			summary.Verified = append(summary.Verified, file.Path)
+		}
+	}
+
+	c.logger.Printf("Verified: %s\n", tree.Dir.DirPath)
+	summary.print(c.logger)
+
+	switch {
+	case len(summary.Mismatched) > 0:
+		return exitWith(exitChecksumMismatch)
+	case len(summary.Failed) > 0:
+		return exitWith(exitPartialFailure)
+	}
+
+	return nil
+}
+
+// verify downloads the file, decrypts it with the key, and verifies it with its
+// checksum.
+func (c *VerifyCommand) verify(ctx context.Context, file api.File, key []byte) error {
+	var buf bytes.Buffer
+	if _, err := c.client.Files().Download(ctx, api.ID(file.ID), &buf); err != nil {
+		return err
+	}
+
+	data, err := decryptFile(c.aes, buf.Bytes(), key)
+	if err != nil {
+		return fmt.Errorf("decrypting file: %w", err)
+	}
+
+	return verifyChecksum(c.aes, file, data, key)
+}
+
+// treeFiles returns the files of the node and of every node below it.
+func treeFiles(node *api.TreeNode) []api.File {
+	files := append([]api.File{}, node.Files...)
+	for _, sub := range node.Dirs {
+		files = append(files, treeFiles(sub)...)
+	}
+
+	return files
+}
+
+// printError prints the error of a request made by this VerifyCommand. If the API
+// token was rejected, the user is offered to enter a new one instead.
+func (c *VerifyCommand) printError(cmd *cobra.Command, err error, args []string) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if c.reauth.Handle(cmd.Context(), e, c.user, *c.password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		if len(args) > 0 {
+			fmt.Fprintf(stderr, "-> [ARGS] Directory: %s\n", args[0])
+		}
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Verify failed:", err)
+	}
+}
+
+// print prints this verifySummary in a human readable format.
+func (s *verifySummary) print(logger *logging.Logger) {
+	logger.Printf("\nVerified: %d\n", len(s.Verified))
+	for _, p := range s.Verified {
+		logger.Println(p)
+	}
+
+	if len(s.Unverified) > 0 {
+		fmt.Fprintf(stdout, "\nNo checksum: %d\n", len(s.Unverified))
+		for _, p := range s.Unverified {
+			fmt.Fprintln(stdout, p)
+		}
+		fmt.Fprintln(stdout, "-> [HINT] The files were uploaded without a checksum or appended to, upload them again to add one")
+	}
+
+	if len(s.Canceled) > 0 {
+		fmt.Fprintf(stdout, "\nCanceled: %d\n", len(s.Canceled))
+		for _, p := range s.Canceled {
+			fmt.Fprintln(stdout, p)
+		}
+		fmt.Fprintln(stdout, "-> [HINT] The verify stopped at the first file that failed (--fail-fast)")
+	}
+
+	if len(s.Mismatched) > 0 {
+		fmt.Fprintf(stdout, "\nMISMATCHED: %d\n", len(s.Mismatched))
+		for _, p := range s.Mismatched {
+			fmt.Fprintln(stdout, p)
+		}
+		fmt.Fprintln(stdout, "-> [HINT] The files were changed or corrupted after they were uploaded, do not trust their contents")
+	}
+
+	if len(s.Failed) > 0 || !logger.Quiet() {
+		fmt.Fprintf(stdout, "\nErrors: %d\n", len(s.Failed))
+	}
+	for _, f := range s.Failed {
+		fmt.Fprintf(stdout, "%s -> %s\n", f.Path, f.Err)
+	}
+}
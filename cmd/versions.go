@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/config"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/logging"
+	"github.com/cicconee/clox-cli/internal/prompt"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+	"github.com/spf13/cobra"
+)
+
+// The 'versions' command.
+//
+// VersionsCommand lists the prior revisions of a file on the server. It is also the
+// group of the 'versions get' command.
+type VersionsCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	format   formatFlag
+}
+
+// NewVersionsCommand creates and returns a VersionsCommand.
+//
+// The format flag (--format) is set for the VersionsCommand. This flag sets the
+// format the revisions are printed in.
+func NewVersionsCommand(logger *logging.Logger, reauth *Reauthenticator) *VersionsCommand {
+	versionsCmd := &VersionsCommand{logger: logger, reauth: reauth}
+
+	versionsCmd.cmd = &cobra.Command{
+		Use:   "versions <path|id>",
+		Short: "List the prior revisions of a file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  versionsCmd.Run,
+	}
+
+	versionsCmd.format.register(versionsCmd.cmd)
+
+	return versionsCmd
+}
+
+// Command returns the cobra.Command of this VersionsCommand.
+func (c *VersionsCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *VersionsCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *VersionsCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *VersionsCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this VersionsCommand.
+//
+// Run prints every prior revision of the file, newest first. An argument that
+// starts with a '/' is a path, otherwise it is an ID. A revision is downloaded with
+// 'clox versions get'.
+func (c *VersionsCommand) Run(cmd *cobra.Command, args []string) error {
+	r, err := c.format.renderer()
+	if err != nil {
+		return err
+	}
+
+	res, err := c.client.Files().Versions(cmd.Context(), argLocation(args[0]))
+	if err != nil {
+		printVersionsError(cmd, c.reauth, c.user, *c.password, err, args, 0)
+		return reported(err)
+	}
+
+	return render(r, &versionsResult{res}, c.logger)
+}
+
+// versionsResult is the result of the 'versions' command.
+type versionsResult struct {
+	*api.VersionsResponse
+}
+
+// Items returns the prior revisions of the file.
+func (r *versionsResult) Items() any {
+	return versionList(r.Versions)
+}
+
+// Text writes the path and ID of the file, followed by every prior revision with
+// its size and the time it was created, one revision per line.
+func (r *versionsResult) Text(w io.Writer) error {
+	fmt.Fprintf(w, "File: %s\n", r.File.Path)
+	fmt.Fprintf(w, "-> ID: %s\n", r.File.ID)
+	fmt.Fprintf(w, "\nVersions: %d\n", len(r.Versions))
+	for _, v := range r.Versions {
+		if _, err := fmt.Fprintf(w, "%d -> %s (%s)\n", v.Revision, formatBytes(v.Size), formatTime(v.CreatedAt)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// versionList is a list of the prior revisions of a file.
+type versionList []api.Version
+
+// Table returns a row for every revision, with its number, size, and the time it
+// was created.
+func (l versionList) Table() ([]string, [][]string) {
+	rows := make([][]string, len(l))
+	for i, v := range l {
+		rows[i] = []string{strconv.Itoa(v.Revision), formatBytes(v.Size), formatTime(v.CreatedAt)}
+	}
+
+	return []string{"REVISION", "SIZE", "CREATED"}, rows
+}
+
+// The 'versions get' command.
+//
+// VersionsGetCommand downloads and decrypts a prior revision of a file on the
+// server.
+type VersionsGetCommand struct {
+	cmd      *cobra.Command
+	user     *config.User
+	password *secret.Bytes
+	client   *api.Client
+	keys     *security.Keys
+	aes      *crypto.AES
+	rsa      *crypto.RSA
+	logger   *logging.Logger
+	reauth   *Reauthenticator
+	rev      int
+	output   string
+}
+
+// NewVersionsGetCommand creates and returns a VersionsGetCommand.
+//
+// The revision flag (--rev) is set for the VersionsGetCommand. This flag is
+// required, it sets the revision that is downloaded.
+//
+// The output flag (-o, --output) is set for the VersionsGetCommand. This flag sets
+// the local path the revision is written to. A '-' writes it to standard output.
+func NewVersionsGetCommand(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, logger *logging.Logger, reauth *Reauthenticator) *VersionsGetCommand {
+	getCmd := &VersionsGetCommand{keys: keys, aes: aes, rsa: rsa, logger: logger, reauth: reauth}
+
+	getCmd.cmd = &cobra.Command{
+		Use:   "get <path|id>",
+		Short: "Download a prior revision of a file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  getCmd.Run,
+	}
+
+	getCmd.cmd.Flags().IntVar(&getCmd.rev, "rev", 0, "The revision to download")
+	getCmd.cmd.Flags().StringVarP(&getCmd.output, "output", "o", "", "The path to write the revision, '-' for standard output")
+	getCmd.cmd.MarkFlagRequired("rev")
+
+	return getCmd
+}
+
+// Command returns the cobra.Command of this VersionsGetCommand.
+func (c *VersionsGetCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *VersionsGetCommand) SetUser(user *config.User) {
+	c.user = user
+}
+
+func (c *VersionsGetCommand) SetPassword(password *secret.Bytes) {
+	c.password = password
+}
+
+func (c *VersionsGetCommand) SetClient(client *api.Client) {
+	c.client = client
+}
+
+// Run is the RunE function of the cobra.Command in this VersionsGetCommand.
+//
+// Run downloads the revision of the revision flag (--rev), decrypts it, and writes
+// it to the output flag (-o, --output). If the output flag is not set, the revision
+// is written to the current directory with the name of the file on the server and
+// the revision added, such as 'notes.txt.rev2'.
+//
+// A revision is never tracked as the local copy of the file, uploading it does not
+// skip the conflict check.
+func (c *VersionsGetCommand) Run(cmd *cobra.Command, args []string) error {
+	if c.rev < 1 {
+		fmt.Fprintln(stderr, "Invalid revision (--rev): must be 1 or more")
+		return errUsage
+	}
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	files := c.client.Files()
+	res, err := files.Versions(cmd.Context(), argLocation(args[0]))
+	if err != nil {
+		printVersionsError(cmd, c.reauth, c.user, *c.password, err, args, c.rev)
+		return reported(err)
+	}
+
+	found := false
+	for _, v := range res.Versions {
+		if v.Revision == c.rev {
+			found = true
+			break
+		}
+	}
+	if !found {
+		fmt.Fprintf(stderr, "Error: %s has no revision %d\n", res.File.Path, c.rev)
+		fmt.Fprintf(stderr, "-> [HINT] List the revisions with 'clox versions %s'\n", args[0])
+		return reported(nil)
+	}
+
+	var buf bytes.Buffer
+	if _, err := files.DownloadVersion(cmd.Context(), api.ID(res.File.ID), c.rev, &buf); err != nil {
+		printVersionsError(cmd, c.reauth, c.user, *c.password, err, args, c.rev)
+		return reported(err)
+	}
+
+	data, err := decryptFile(c.aes, buf.Bytes(), encryptKey)
+	if err != nil {
+		c.logger.Error("decrypting revision", "error", err)
+		return reported(err)
+	}
+
+	output := c.output
+	if output == "" {
+		output = res.File.Name + ".rev" + strconv.Itoa(c.rev)
+	}
+
+	if output == "-" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			c.logger.Error("writing revision", "error", err)
+			return reported(err)
+		}
+		return nil
+	}
+
+	if !prompt.ConfirmOverwrite(output) {
+		return reported(nil)
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		c.logger.Error("writing revision", "path", output, "error", err)
+		return reported(err)
+	}
+	c.logger.Printf("Downloaded: %s (revision %d) -> %s\n", res.File.Path, c.rev, output)
+
+	return nil
+}
+
+// printVersionsError prints the error of a request made by the 'versions' commands.
+// The rev is only printed if it is set. If the API token was rejected, the user is
+// offered to enter a new one instead.
+func printVersionsError(cmd *cobra.Command, reauth *Reauthenticator, user *config.User, password []byte, err error, args []string, rev int) {
+	switch e := err.(type) {
+	case *api.APIError:
+		if reauth.Handle(cmd.Context(), e, user, password) {
+			return
+		}
+		fmt.Fprintf(stderr, "API Error [%d]: %s\n", e.StatusCode, e.Err)
+		fmt.Fprintf(stderr, "-> [ARGS] File: %s\n", args[0])
+		if rev > 0 {
+			fmt.Fprintf(stderr, "-> [FLAG] Revision: %d\n", rev)
+		}
+		printAPIErrorHint(e)
+	default:
+		fmt.Fprintln(stderr, "Error:", err)
+	}
+}
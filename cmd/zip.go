@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/hooks"
+	"github.com/spf13/cobra"
+)
+
+// zipSummary is the result of downloading a directory as a zip archive. It is
+// written to the post-download hook.
+type zipSummary struct {
+	Dir   string `json:"dir"`
+	Files int    `json:"files"`
+	Dirs  int    `json:"dirs"`
+	Bytes int64  `json:"bytes"`
+}
+
+// runZip downloads the directory of the argument and everything below it, and
+// writes it to the output flag (-o, --output) as a zip archive. If the output flag
+// is not set, the archive is written to the current directory named after the
+// directory. A '-' writes the archive to standard output.
+//
+// The archive is written as the tree is walked, each file is downloaded, decrypted,
+// and written to the archive before the next one is downloaded. Only a single file
+// is held in memory at a time. If the download fails, the incomplete archive is
+// removed.
+func (c *DownloadCommand) runZip(cmd *cobra.Command, args []string) error {
+	root := argLocation(args[0])
+
+	encryptKey, err := c.user.EncryptKey(c.keys, c.rsa, *c.password)
+	if err != nil {
+		c.logger.Error("getting encryption key", "error", err)
+		return reported(err)
+	}
+	defer encryptKey.Wipe()
+
+	if err := c.hooks.Run(hooks.PreDownload, args, nil); err != nil {
+		fmt.Fprintln(stderr, "Download aborted:", err)
+		return reported(err)
+	}
+
+	listing, err := c.client.Dirs().List(cmd.Context(), root)
+	if err != nil {
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	output := c.output
+	if output == "" {
+		name := listing.Dir.DirName
+		if listing.Dir.DirPath == "/" || name == "" {
+			name = "root"
+		}
+		output = name + ".zip"
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			c.logger.Error("creating archive", "path", output, "error", err)
+			return reported(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	summary, err := c.writeZip(cmd.Context(), c.client, api.ID(listing.Dir.ID), listing.Dir.DirPath, w, encryptKey)
+	if err != nil {
+		if output != "-" {
+			os.Remove(output)
+		}
+		c.printError(cmd, err, args)
+		return reported(err)
+	}
+
+	if output != "-" {
+		c.logger.Printf("Downloaded: %s -> %s\n", summary.Dir, output)
+		c.logger.Printf("-> Files: %d\n", summary.Files)
+		c.logger.Printf("-> Directories: %d\n", summary.Dirs)
+		c.logger.Printf("-> Size: %s\n", formatBytes(summary.Bytes))
+	}
+
+	if err := c.hooks.Run(hooks.PostDownload, []string{output}, summary); err != nil {
+		c.logger.Warn("running post-download hook", "error", err)
+	}
+
+	return nil
+}
+
+// writeZip walks the directory at root, and writes every directory and decrypted
+// file below it to w as a zip archive. The rootPath is the path of root, the names
+// in the archive are relative to it.
+func (c *DownloadCommand) writeZip(ctx context.Context, client *api.Client, root api.Location, rootPath string, w io.Writer, key []byte) (zipSummary, error) {
+	summary := zipSummary{Dir: rootPath}
+	zw := zip.NewWriter(w)
+
+	err := client.Dirs().Walk(ctx, root, func(listing *api.DirListing, depth int) error {
+		dir := strings.TrimPrefix(strings.TrimPrefix(listing.Dir.DirPath, rootPath), "/")
+		if depth > 0 {
+			if _, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     dir + "/",
+				Method:   zip.Store,
+				Modified: listing.Dir.UpdatedAt,
+			}); err != nil {
+				return err
+			}
+			summary.Dirs++
+		}
+
+		for _, f := range listing.Files {
+			var buf bytes.Buffer
+			if _, err := client.Files().Download(ctx, api.ID(f.ID), &buf); err != nil {
+				return fmt.Errorf("downloading '%s': %w", f.Path, err)
+			}
+
+			data, err := decryptFile(c.aes, buf.Bytes(), key)
+			if err != nil {
+				return fmt.Errorf("decrypting '%s': %w", f.Path, err)
+			}
+
+			fw, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     path.Join(dir, f.Name),
+				Method:   zip.Deflate,
+				Modified: f.UpdatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(data); err != nil {
+				return err
+			}
+
+			summary.Files++
+			summary.Bytes += int64(len(data))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return zipSummary{}, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return zipSummary{}, err
+	}
+
+	return summary, nil
+}
@@ -5,6 +5,7 @@ go 1.21.1
 require (
 	github.com/spf13/cobra v1.8.0
 	golang.org/x/crypto v0.18.0
+	golang.org/x/sys v0.16.0
 )
 
 require (
@@ -0,0 +1,413 @@
+// Package age encrypts and decrypts files in the age format, age-encryption.org/v1,
+// so the files exported by the CLI can be decrypted with the age tool, and files
+// encrypted with it can be imported. Only the X25519 recipients of age are
+// supported, the recipients and identities that age-keygen generates.
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// version is the first line of an age file.
+	version = "age-encryption.org/v1"
+	// fileKeySize is the size of the key that the payload key is derived from, it
+	// is encrypted for every recipient.
+	fileKeySize = 16
+	// columnsPerLine is the length of the lines of a stanza body.
+	columnsPerLine = 64
+	// x25519Label is the HKDF info of the key that the file key is wrapped with for
+	// an X25519 recipient.
+	x25519Label = "age-encryption.org/v1/X25519"
+	// maxHeaderSize is the largest header that is read, so a file that is not an
+	// age file is not read into memory as a whole.
+	maxHeaderSize = 1 << 20
+)
+
+// ErrIncorrectIdentity is the error when none of the identities can decrypt the
+// file, it was not encrypted for them.
+var ErrIncorrectIdentity = errors.New("the file was not encrypted for the identity")
+
+// b64 is the base64 encoding of age, standard base64 without padding. Decoding is
+// strict, so every value has one encoding.
+var b64 = base64.RawStdEncoding.Strict()
+
+// Identity is an X25519 identity, the private key that files are decrypted with. It
+// is encoded as "AGE-SECRET-KEY-1...".
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// NewIdentity creates the Identity of the 32 byte X25519 secret.
+func NewIdentity(secret []byte) (*Identity, error) {
+	key, err := ecdh.X25519().NewPrivateKey(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{key: key}, nil
+}
+
+// ParseIdentity parses an identity encoded as "AGE-SECRET-KEY-1...".
+func ParseIdentity(s string) (*Identity, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed age identity: %w", err)
+	}
+	if hrp != "age-secret-key-" {
+		return nil, fmt.Errorf("malformed age identity: unknown type %q", hrp)
+	}
+
+	return NewIdentity(data)
+}
+
+// ParseIdentities parses an identity file, such as the file that age-keygen writes.
+// Empty lines and lines starting with "#" are ignored, every other line must be an
+// identity.
+func ParseIdentities(r io.Reader) ([]*Identity, error) {
+	var ids []*Identity
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, err := ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no identities found")
+	}
+
+	return ids, nil
+}
+
+// String returns the identity encoded as "AGE-SECRET-KEY-1...". It is a secret.
+func (i *Identity) String() string {
+	s, _ := bech32Encode("age-secret-key-", i.key.Bytes())
+	return strings.ToUpper(s)
+}
+
+// Recipient returns the Recipient of this Identity, the files encrypted for it can
+// be decrypted with this Identity.
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{key: i.key.PublicKey()}
+}
+
+// unwrap decrypts the file key of the stanza. If the stanza is not an X25519 stanza
+// for this Identity, it returns ErrIncorrectIdentity.
+func (i *Identity) unwrap(s *stanza) ([]byte, error) {
+	if s.Type != "X25519" {
+		return nil, ErrIncorrectIdentity
+	}
+	if len(s.Args) != 1 {
+		return nil, errors.New("invalid X25519 stanza")
+	}
+	share, err := b64.DecodeString(s.Args[0])
+	if err != nil || len(share) != 32 {
+		return nil, errors.New("invalid X25519 stanza")
+	}
+	if len(s.Body) != fileKeySize+16 {
+		return nil, errors.New("invalid X25519 stanza")
+	}
+
+	pub, err := ecdh.X25519().NewPublicKey(share)
+	if err != nil {
+		return nil, errors.New("invalid X25519 stanza")
+	}
+	shared, err := i.key.ECDH(pub)
+	if err != nil {
+		return nil, errors.New("invalid X25519 share")
+	}
+
+	salt := append(share, i.key.PublicKey().Bytes()...)
+	aead, err := chacha20poly1305.New(deriveKey(shared, salt, x25519Label))
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aead.Open(nil, make([]byte, aead.NonceSize()), s.Body, nil)
+	if err != nil {
+		return nil, ErrIncorrectIdentity
+	}
+
+	return fileKey, nil
+}
+
+// Recipient is an X25519 recipient, the public key that files are encrypted for. It
+// is encoded as "age1...".
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// ParseRecipient parses a recipient encoded as "age1...".
+func ParseRecipient(s string) (*Recipient, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed age recipient: %w", err)
+	}
+	if hrp != "age" {
+		return nil, fmt.Errorf("malformed age recipient: unknown type %q", hrp)
+	}
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("malformed age recipient: %w", err)
+	}
+
+	return &Recipient{key: key}, nil
+}
+
+// String returns the recipient encoded as "age1...".
+func (r *Recipient) String() string {
+	s, _ := bech32Encode("age", r.key.Bytes())
+	return s
+}
+
+// wrap encrypts the file key for this Recipient with a new ephemeral key.
+func (r *Recipient) wrap(fileKey []byte) (*stanza, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(r.key)
+	if err != nil {
+		return nil, err
+	}
+
+	share := ephemeral.PublicKey().Bytes()
+	salt := append(share[:len(share):len(share)], r.key.Bytes()...)
+	aead, err := chacha20poly1305.New(deriveKey(shared, salt, x25519Label))
+	if err != nil {
+		return nil, err
+	}
+
+	return &stanza{
+		Type: "X25519",
+		Args: []string{b64.EncodeToString(share)},
+		Body: aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil),
+	}, nil
+}
+
+// stanza is a recipient stanza of the header, the file key encrypted for one
+// recipient.
+type stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+// Encrypt writes the header of an age file encrypted for the recipients to dst, and
+// returns the writer that the plain text is written to. The writer must be closed to
+// write the last chunk of the payload, it does not close dst.
+func Encrypt(dst io.Writer, recipients ...*Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+
+	var hdr bytes.Buffer
+	hdr.WriteString(version + "\n")
+	for _, r := range recipients {
+		s, err := r.wrap(fileKey)
+		if err != nil {
+			return nil, fmt.Errorf("wrapping file key: %w", err)
+		}
+		writeStanza(&hdr, s)
+	}
+	hdr.WriteString("---")
+	mac := headerMAC(fileKey, hdr.Bytes())
+	hdr.WriteString(" " + b64.EncodeToString(mac) + "\n")
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	hdr.Write(nonce)
+	if _, err := dst.Write(hdr.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return newWriter(deriveKey(fileKey, nonce, "payload"), dst)
+}
+
+// Decrypt reads the header of the age file from src and decrypts the file key with
+// one of the identities, and returns the reader of the plain text. If none of the
+// identities can decrypt the file key, it returns ErrIncorrectIdentity.
+//
+// The reader returns an error if the payload is not authentic, the plain text read
+// before it must not be trusted until the reader returns io.EOF.
+func Decrypt(src io.Reader, identities ...*Identity) (io.Reader, error) {
+	if len(identities) == 0 {
+		return nil, errors.New("no identities")
+	}
+
+	br := bufio.NewReader(src)
+	hdr, stanzas, mac, err := readHeader(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	var fileKey []byte
+	for _, s := range stanzas {
+		for _, id := range identities {
+			key, err := id.unwrap(s)
+			if errors.Is(err, ErrIncorrectIdentity) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			fileKey = key
+			break
+		}
+		if fileKey != nil {
+			break
+		}
+	}
+	if fileKey == nil {
+		return nil, ErrIncorrectIdentity
+	}
+	if !hmac.Equal(headerMAC(fileKey, hdr), mac) {
+		return nil, errors.New("bad header MAC")
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(br, nonce); err != nil {
+		return nil, fmt.Errorf("reading payload nonce: %w", err)
+	}
+
+	return newReader(deriveKey(fileKey, nonce, "payload"), br)
+}
+
+// writeStanza writes the stanza to the header, the body wrapped at columnsPerLine.
+// The last line of the body is always shorter than columnsPerLine, it is empty if
+// the encoded body is a multiple of it.
+func writeStanza(hdr *bytes.Buffer, s *stanza) {
+	hdr.WriteString("-> " + s.Type)
+	for _, arg := range s.Args {
+		hdr.WriteString(" " + arg)
+	}
+	hdr.WriteString("\n")
+
+	body := b64.EncodeToString(s.Body)
+	for len(body) >= columnsPerLine {
+		hdr.WriteString(body[:columnsPerLine] + "\n")
+		body = body[columnsPerLine:]
+	}
+	hdr.WriteString(body + "\n")
+}
+
+// readHeader reads the header from br. It returns the header up to and including
+// "---", that the MAC is computed over, the stanzas, and the MAC.
+func readHeader(br *bufio.Reader) ([]byte, []*stanza, []byte, error) {
+	var hdr bytes.Buffer
+	readLine := func() (string, error) {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if hdr.Len()+len(line) > maxHeaderSize {
+			return "", errors.New("header too large")
+		}
+		hdr.WriteString(line)
+		return strings.TrimSuffix(line, "\n"), nil
+	}
+
+	line, err := readLine()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if line != version {
+		return nil, nil, nil, errors.New("not an age file, or an unsupported version")
+	}
+
+	var stanzas []*stanza
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if mac, ok := strings.CutPrefix(line, "--- "); ok {
+			decoded, err := b64.DecodeString(mac)
+			if err != nil || len(decoded) != sha256.Size {
+				return nil, nil, nil, errors.New("malformed header MAC")
+			}
+			// The MAC covers the header up to "---", without the space and the MAC.
+			covered := hdr.Bytes()[:hdr.Len()-len(line)-1+len("---")]
+			return covered, stanzas, decoded, nil
+		}
+
+		fields, ok := strings.CutPrefix(line, "-> ")
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("malformed header line: %q", line)
+		}
+		args := strings.Split(fields, " ")
+		for _, arg := range args {
+			if arg == "" {
+				return nil, nil, nil, fmt.Errorf("malformed stanza: %q", line)
+			}
+		}
+
+		s := &stanza{Type: args[0], Args: args[1:]}
+		for {
+			line, err := readLine()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			decoded, err := b64.DecodeString(line)
+			if err != nil || len(line) > columnsPerLine {
+				return nil, nil, nil, fmt.Errorf("malformed stanza body: %q", line)
+			}
+			s.Body = append(s.Body, decoded...)
+			if len(line) < columnsPerLine {
+				break
+			}
+		}
+		stanzas = append(stanzas, s)
+	}
+}
+
+// headerMAC returns the HMAC-SHA256 of the header, with the key derived from the
+// file key.
+func headerMAC(fileKey []byte, hdr []byte) []byte {
+	h := hmac.New(sha256.New, deriveKey(fileKey, nil, "header"))
+	h.Write(hdr)
+	return h.Sum(nil)
+}
+
+// deriveKey derives a 32 byte key from the secret with HKDF-SHA256.
+func deriveKey(secret []byte, salt []byte, info string) []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		panic("age: hkdf: " + err.Error())
+	}
+
+	return key
+}
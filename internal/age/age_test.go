@@ -0,0 +1,167 @@
+package age
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// The identity and recipient of the tests, and a file encrypted for the recipient
+// by the age tool (filippo.io/age v1.1.1) with the plain text "hello from age\n".
+const (
+	testIdentity  = "AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX"
+	testRecipient = "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"
+	testFile      = "YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBWSndVU3hUa3pCenJ2OUh5REJSaGlnOHRzRlc1MlVWUDIyaEN4dUd3YkdnCm5vUnc2L2ltckREUlN4L1FCZko1S1pQR2QwNDVDd0F4OUpacHBiVVIxejQKLS0tIDNTV3VPRmhqMnJCaXhCYnFYSkpKdDZ6VGVNcmI0bG93aXNLZmRydW4yejQKQQwlJ3OvpqvOV0vtZvM+putoJl6yq0mhVLKNVujtvaYzlERS+lZP/yasEWw8MUs="
+)
+
+func testIdentityOf(t *testing.T) *Identity {
+	t.Helper()
+	id, err := ParseIdentity(testIdentity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestParseIdentity(t *testing.T) {
+	id := testIdentityOf(t)
+	if id.String() != testIdentity {
+		t.Errorf("String() = %s, want %s", id.String(), testIdentity)
+	}
+	if id.Recipient().String() != testRecipient {
+		t.Errorf("Recipient() = %s, want %s", id.Recipient(), testRecipient)
+	}
+
+	r, err := ParseRecipient(testRecipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != testRecipient {
+		t.Errorf("ParseRecipient().String() = %s, want %s", r, testRecipient)
+	}
+
+	invalid := []string{
+		"",
+		strings.ToLower(testIdentity)[:len(testIdentity)-1] + "q",
+		testRecipient,
+	}
+	for _, s := range invalid {
+		if _, err := ParseIdentity(s); err == nil {
+			t.Errorf("ParseIdentity(%q) succeeded", s)
+		}
+	}
+}
+
+func TestDecryptKnownAnswer(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Decrypt(bytes.NewReader(data), testIdentityOf(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello from age\n" {
+		t.Errorf("Decrypt() = %q, want %q", got, "hello from age\n")
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	id := testIdentityOf(t)
+	sizes := []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 5}
+
+	for _, n := range sizes {
+		data := make([]byte, n)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		w, err := Encrypt(&buf, id.Recipient())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The header is the version, an X25519 stanza with the ephemeral share and
+		// the wrapped file key, and the MAC, followed by the 16 byte nonce and the
+		// chunks of the payload.
+		lines := strings.SplitN(buf.String(), "\n", 5)
+		if len(lines) < 5 || lines[0] != version || !strings.HasPrefix(lines[1], "-> X25519 ") || len(lines[2]) != 43 || !strings.HasPrefix(lines[3], "--- ") {
+			t.Fatalf("%d: header = %q", n, lines[:min(len(lines), 4)])
+		}
+		chunks := max(1, (n+chunkSize-1)/chunkSize)
+		if len(lines[4]) != 16+n+chunks*overhead {
+			t.Errorf("%d: payload is %d bytes, want %d", n, len(lines[4]), 16+n+chunks*overhead)
+		}
+
+		r, err := Decrypt(bytes.NewReader(buf.Bytes()), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%d: %v", n, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%d: Decrypt() did not return the plain text", n)
+		}
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	id := testIdentityOf(t)
+	var buf bytes.Buffer
+	w, err := Encrypt(&buf, id.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	other, err := NewIdentity(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(bytes.NewReader(data), other); !errors.Is(err, ErrIncorrectIdentity) {
+		t.Errorf("Decrypt() with another identity = %v, want ErrIncorrectIdentity", err)
+	}
+
+	modified := append([]byte{}, data...)
+	modified[len(modified)-1] ^= 1
+	r, err := Decrypt(bytes.NewReader(modified), id)
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	if err == nil {
+		t.Error("Decrypt() of a modified payload succeeded")
+	}
+
+	truncated := data[:len(data)-overhead]
+	r, err = Decrypt(bytes.NewReader(truncated), id)
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	if err == nil {
+		t.Error("Decrypt() of a truncated payload succeeded")
+	}
+}
@@ -0,0 +1,198 @@
+package age
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The payload of an age file is the STREAM construction: the plain text is split in
+// chunks of chunkSize, each sealed with ChaCha20-Poly1305. The nonce of a chunk is
+// its index as an 11 byte big endian counter, and a last byte that is 1 for the last
+// chunk and 0 otherwise, so chunks cannot be reordered or the payload truncated.
+
+// chunkSize is the size of the plain text of a chunk, only the last chunk is shorter.
+const chunkSize = 64 * 1024
+
+// overhead is the size of the tag of a chunk.
+const overhead = 16
+
+// errTruncated is the error when a payload ends before its last chunk.
+var errTruncated = errors.New("age: payload truncated")
+
+// incrementNonce increments the counter of the nonce. It returns an error if the
+// counter wraps, it must not be reused.
+func incrementNonce(nonce *[12]byte) error {
+	for i := len(nonce) - 2; i >= 0; i-- {
+		nonce[i]++
+		if nonce[i] != 0 {
+			return nil
+		}
+	}
+
+	return errors.New("age: stream counter overflow")
+}
+
+// writer is the io.WriteCloser of the plain text of a payload.
+type writer struct {
+	aead   cipher.AEAD
+	dst    io.Writer
+	buf    []byte
+	nonce  [12]byte
+	closed bool
+	err    error
+}
+
+// newWriter creates a writer that seals the payload with the payload key and writes
+// it to dst.
+func newWriter(key []byte, dst io.Writer) (*writer, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &writer{aead: aead, dst: dst, buf: make([]byte, 0, chunkSize+overhead)}, nil
+}
+
+// Write buffers p and writes the chunks that are full. A full chunk is written when
+// more plain text is written after it, it is the last chunk otherwise.
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, errors.New("age: write on closed writer")
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				w.err = err
+				return n - len(p), err
+			}
+		}
+
+		m := min(chunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:m]...)
+		p = p[m:]
+	}
+
+	return n, nil
+}
+
+// Close writes the last chunk. It does not close the destination.
+func (w *writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	w.err = w.flush(true)
+	return w.err
+}
+
+// flush seals the buffered plain text as a chunk and writes it.
+func (w *writer) flush(last bool) error {
+	if last {
+		w.nonce[len(w.nonce)-1] = 1
+	}
+
+	chunk := w.aead.Seal(w.buf[:0], w.nonce[:], w.buf, nil)
+	if _, err := w.dst.Write(chunk); err != nil {
+		return err
+	}
+
+	w.buf = w.buf[:0]
+	return incrementNonce(&w.nonce)
+}
+
+// reader is the io.Reader of the plain text of a payload.
+type reader struct {
+	aead   cipher.AEAD
+	src    *bufio.Reader
+	buf    []byte
+	unread []byte
+	nonce  [12]byte
+	first  bool
+	last   bool
+	err    error
+}
+
+// newReader creates a reader that opens the payload read from src with the payload
+// key.
+func newReader(key []byte, src *bufio.Reader) (*reader, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reader{aead: aead, src: src, buf: make([]byte, chunkSize+overhead), first: true}, nil
+}
+
+// Read reads the plain text of the payload. It returns an error if a chunk is not
+// authentic, or the payload is truncated or has data after its last chunk.
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.unread) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.last {
+			r.err = io.EOF
+			if _, err := r.src.Peek(1); err == nil {
+				r.err = errors.New("age: trailing data after the end of the payload")
+			}
+			continue
+		}
+
+		r.unread, r.err = r.readChunk()
+	}
+
+	n := copy(p, r.unread)
+	r.unread = r.unread[n:]
+	return n, nil
+}
+
+// readChunk reads and opens the next chunk. A chunk shorter than a full chunk, or a
+// full chunk at the end of the payload, is the last chunk.
+func (r *reader) readChunk() ([]byte, error) {
+	n, err := io.ReadFull(r.src, r.buf)
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil, errTruncated
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		r.last = true
+	case err != nil:
+		return nil, err
+	default:
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			r.last = true
+		}
+	}
+
+	if r.last {
+		r.nonce[len(r.nonce)-1] = 1
+	}
+	plain, err := r.aead.Open(r.buf[:0], r.nonce[:], r.buf[:n], nil)
+	if err != nil {
+		if !r.last {
+			return nil, errors.New("age: payload chunk is not authentic")
+		}
+		return nil, errors.New("age: last payload chunk is not authentic, or the payload is truncated")
+	}
+	if r.last && len(plain) == 0 && !r.first {
+		return nil, errors.New("age: last payload chunk is empty")
+	}
+	if err := incrementNonce(&r.nonce); err != nil {
+		return nil, err
+	}
+
+	r.first = false
+	return plain, nil
+}
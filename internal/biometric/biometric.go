@@ -0,0 +1,70 @@
+package biometric
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// service is the name the secrets are stored under in the platform keystore.
+const service = "clox-cli"
+
+var (
+	// ErrUnsupported is the error when the platform does not support OS
+	// authenticated unlock.
+	ErrUnsupported = errors.New("biometric unlock is not supported on this platform")
+	// ErrNotVerified is the error when the user failed or cancelled the OS
+	// authentication.
+	ErrNotVerified = errors.New("user not verified")
+)
+
+// Provider stores a secret in the platform keystore, gated behind OS user
+// authentication such as Windows Hello. The secret cannot be read without the user
+// being verified, not only by the Clox CLI.
+//
+// The secret of each account is stored separately. Provider should be created by
+// calling New, which returns the Provider of the current platform.
+type Provider interface {
+	// Available checks if OS user authentication can be used on this machine.
+	Available() bool
+
+	// Enrolled checks if a secret is stored for the account.
+	Enrolled(account string) bool
+
+	// Store stores the secret for the account, replacing any existing secret.
+	Store(account string, value []byte) error
+
+	// Unlock asks the user to authenticate with the OS, showing reason. If the
+	// user is verified, the secret of the account is returned, it must be wiped
+	// after use.
+	Unlock(account string, reason string) (secret.Bytes, error)
+
+	// Delete removes the secret of the account. Deleting an account that is not
+	// enrolled is not an error.
+	Delete(account string) error
+}
+
+// run runs the program with args and writes stdin to its standard input. The
+// trimmed standard output is returned. If the program fails, the error contains its
+// standard error.
+func run(stdin string, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
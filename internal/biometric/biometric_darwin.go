@@ -0,0 +1,46 @@
+//go:build darwin
+
+package biometric
+
+import "github.com/cicconee/clox-cli/internal/secret"
+
+// keychain is the macOS Provider. A keychain item can only be gated behind Touch ID
+// with an access control (kSecAccessControlBiometryCurrentSet) in the data
+// protection keychain, which requires the binary to be signed with a keychain
+// entitlement. The Clox CLI is not, and a generic password in the login keychain
+// can be read by any process of the user without Touch ID, so OS authenticated
+// unlock is not supported on macOS.
+type keychain struct{}
+
+// New returns the Provider of the current platform. OS authenticated unlock is not
+// supported on macOS, see keychain. The dir is not used on macOS.
+func New(dir string) Provider {
+	return &keychain{}
+}
+
+func (k *keychain) Available() bool {
+	return false
+}
+
+func (k *keychain) Enrolled(account string) bool {
+	return false
+}
+
+func (k *keychain) Store(account string, value []byte) error {
+	return ErrUnsupported
+}
+
+func (k *keychain) Unlock(account string, reason string) (secret.Bytes, error) {
+	return nil, ErrUnsupported
+}
+
+// Delete removes the password of the account that an earlier version of the Clox
+// CLI stored in the login keychain, if there is one.
+func (k *keychain) Delete(account string) error {
+	if _, err := run("", "security", "find-generic-password", "-a", account, "-s", service); err != nil {
+		return nil
+	}
+
+	_, err := run("", "security", "delete-generic-password", "-a", account, "-s", service)
+	return err
+}
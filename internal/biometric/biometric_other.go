@@ -0,0 +1,34 @@
+//go:build !darwin && !windows
+
+package biometric
+
+import "github.com/cicconee/clox-cli/internal/secret"
+
+// unsupported is the Provider of platforms without OS authenticated unlock.
+type unsupported struct{}
+
+// New returns the Provider of the current platform. This platform does not support
+// OS authenticated unlock, every method returns ErrUnsupported.
+func New(dir string) Provider {
+	return unsupported{}
+}
+
+func (unsupported) Available() bool {
+	return false
+}
+
+func (unsupported) Enrolled(account string) bool {
+	return false
+}
+
+func (unsupported) Store(account string, value []byte) error {
+	return ErrUnsupported
+}
+
+func (unsupported) Unlock(account string, reason string) (secret.Bytes, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupported) Delete(account string) error {
+	return nil
+}
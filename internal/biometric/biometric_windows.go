@@ -0,0 +1,214 @@
+//go:build windows
+
+package biometric
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// helloScript is the PowerShell script that signs a challenge with the Windows
+// Hello key of a credential, see KeyCredentialManager. The first argument is
+// "check", "create", "sign", or "delete", the second is the name of the credential,
+// and the third is the base64 challenge. The signature is written to the standard
+// output in base64.
+//
+// It exits 0 if it succeeded, 1 if the user was not verified, 2 if Windows Hello is
+// not available, and 3 if the credential does not exist.
+const helloScript = `
+param($mode, $name, $challenge)
+Add-Type -AssemblyName System.Runtime.WindowsRuntime
+$methods = [System.WindowsRuntimeSystemExtensions].GetMethods() | Where-Object {
+	$_.Name -eq 'AsTask' -and $_.GetParameters().Count -eq 1
+}
+$asTask = ($methods | Where-Object { $_.GetParameters()[0].ParameterType.Name -eq 'IAsyncOperation` + "`" + `1' })[0]
+$asTaskAction = ($methods | Where-Object { $_.GetParameters()[0].ParameterType.Name -eq 'IAsyncAction' })[0]
+$null = [Windows.Security.Credentials.KeyCredentialManager, Windows.Security.Credentials, ContentType = WindowsRuntime]
+$null = [Windows.Security.Cryptography.CryptographicBuffer, Windows.Security.Cryptography, ContentType = WindowsRuntime]
+function Await($op, $type) {
+	$task = $asTask.MakeGenericMethod($type).Invoke($null, @($op))
+	$task.Wait(-1) | Out-Null
+	$task.Result
+}
+$manager = [Windows.Security.Credentials.KeyCredentialManager]
+if (-not (Await ($manager::IsSupportedAsync()) ([bool]))) { exit 2 }
+if ($mode -eq 'check') { exit 0 }
+if ($mode -eq 'delete') {
+	$asTaskAction.Invoke($null, @($manager::DeleteAsync($name))).Wait(-1) | Out-Null
+	exit 0
+}
+if ($mode -eq 'create') {
+	$result = Await ($manager::RequestCreateAsync($name, [Windows.Security.Credentials.KeyCredentialCreationOption]::ReplaceExisting)) ([Windows.Security.Credentials.KeyCredentialRetrievalResult])
+} else {
+	$result = Await ($manager::OpenAsync($name)) ([Windows.Security.Credentials.KeyCredentialRetrievalResult])
+}
+if ($result.Status -eq 'NotFound') { exit 3 }
+if ($result.Status -ne 'Success') { exit 1 }
+$buffer = [Windows.Security.Cryptography.CryptographicBuffer]::DecodeFromBase64String($challenge)
+$signed = Await ($result.Credential.RequestSignAsync($buffer)) ([Windows.Security.Credentials.KeyCredentialOperationResult])
+if ($signed.Status -ne 'Success') { exit 1 }
+[Windows.Security.Cryptography.CryptographicBuffer]::EncodeToBase64String($signed.Result)
+`
+
+// challengeSize is the size of the random challenge that is signed to derive the
+// key of a secret.
+const challengeSize = 32
+
+// hello is the Windows Provider. Every account has a Windows Hello key credential,
+// a key pair whose private key never leaves the device and can only be used after
+// the user authenticates with Windows Hello. The secret is encrypted with AES-GCM
+// under the SHA-256 hash of the signature of a random challenge. The signature of a
+// Windows Hello key is deterministic (RSA PKCS #1 v1.5), so the key is derived
+// again on unlock, and the secret cannot be decrypted without the user being
+// verified.
+type hello struct {
+	dir string
+}
+
+// New returns the Provider of the current platform. On Windows secrets are
+// encrypted with a key that is released by Windows Hello, and stored in dir.
+func New(dir string) Provider {
+	return &hello{dir: dir}
+}
+
+func (h *hello) Available() bool {
+	_, err := powershell("", helloScript, "check", "", "")
+	return err == nil
+}
+
+func (h *hello) Enrolled(account string) bool {
+	_, err := os.Stat(h.path(account))
+	return err == nil
+}
+
+// Store creates a new Windows Hello key credential for the account, replacing any
+// existing one, and stores the secret encrypted with the key derived from it. The
+// user is asked to authenticate with Windows Hello.
+func (h *hello) Store(account string, value []byte) error {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+
+	gcm, err := h.cipher("create", account, challenge)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data := append(append(challenge, nonce...), gcm.Seal(nil, nonce, value, challenge)...)
+	return os.WriteFile(h.path(account), data, 0600)
+}
+
+func (h *hello) Unlock(account string, reason string) (secret.Bytes, error) {
+	data, err := os.ReadFile(h.path(account))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < challengeSize {
+		return nil, errors.New("stored secret is corrupt")
+	}
+	challenge := data[:challengeSize]
+
+	gcm, err := h.cipher("sign", account, challenge)
+	if err != nil {
+		return nil, err
+	}
+	data = data[challengeSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("stored secret is corrupt")
+	}
+
+	value, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], challenge)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting stored secret: %w", err)
+	}
+
+	return value, nil
+}
+
+func (h *hello) Delete(account string) error {
+	if _, err := powershell("", helloScript, "delete", credentialName(account), ""); err != nil {
+		return err
+	}
+
+	err := os.Remove(h.path(account))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// cipher signs the challenge with the Windows Hello key of the account and returns
+// the AES-GCM cipher of the key derived from the signature. The mode is "create" to
+// create a new key credential first, or "sign" to use the existing one.
+func (h *hello) cipher(mode string, account string, challenge []byte) (cipher.AEAD, error) {
+	out, err := powershell("", helloScript, mode, credentialName(account), base64.StdEncoding.EncodeToString(challenge))
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && (exitErr.ExitCode() == 1 || exitErr.ExitCode() == 3) {
+			return nil, ErrNotVerified
+		}
+		return nil, err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(out)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+	key := sha256.Sum256(signature)
+	for i := range signature {
+		signature[i] = 0
+	}
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// credentialName returns the name of the Windows Hello key credential of the
+// account.
+func credentialName(account string) string {
+	return service + ":" + accountID(account)
+}
+
+// path returns the path to the encrypted secret of the account.
+func (h *hello) path(account string) string {
+	return filepath.Join(h.dir, accountID(account)+".hello")
+}
+
+// accountID returns the hex SHA-256 hash of the account, so an account that is a
+// path can be used in a file name.
+func accountID(account string) string {
+	sum := sha256.Sum256([]byte(account))
+	return hex.EncodeToString(sum[:])
+}
+
+// powershell runs the script with args, writing stdin to its standard input.
+func powershell(stdin string, script string, args ...string) (string, error) {
+	block := "& {" + script + "}"
+	for _, a := range args {
+		block += " '" + strings.ReplaceAll(a, "'", "''") + "'"
+	}
+
+	return run(stdin, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", block)
+}
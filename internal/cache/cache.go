@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/securefile"
+)
+
+// MaxAge is how long a listing in a Cache is served before the directory is listed
+// again.
+const MaxAge = 5 * time.Minute
+
+// Listing is a directory listing in a Cache, and when it was listed.
+type Listing struct {
+	Listing  api.DirListing `json:"listing"`
+	ListedAt time.Time      `json:"listed_at"`
+}
+
+// Fresh checks if this Listing is younger than MaxAge.
+func (l Listing) Fresh() bool {
+	return time.Since(l.ListedAt) < MaxAge
+}
+
+// Cache holds the remote directory listings that were recently seen, and the IDs
+// of the remote directories by their path. It is used to list directories without
+// calling the API.
+//
+// The Cache is stored encrypted with the users password, the remote file names
+// never touch the disk in plain text.
+type Cache struct {
+	// Listings are the directory listings, keyed by the ID of the directory.
+	Listings map[string]Listing `json:"listings"`
+	// IDs maps the path of each remote directory that was seen to its ID.
+	IDs map[string]string `json:"ids"`
+}
+
+// New creates and returns an empty Cache.
+func New() *Cache {
+	return &Cache{Listings: map[string]Listing{}, IDs: map[string]string{}}
+}
+
+// Load reads the cache file at path and decrypts it with the password. If the file
+// does not exist, it returns an empty Cache.
+func Load(path string, aes *crypto.AES, password []byte) (*Cache, error) {
+	c := New()
+	if err := securefile.ReadJSON(path, aes, password, c); err != nil {
+		if errors.Is(err, securefile.ErrNotExist) {
+			return New(), nil
+		}
+
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Save removes the listings of this Cache that are no longer fresh, encrypts it with
+// the password, and writes it to path.
+func (c *Cache) Save(path string, aes *crypto.AES, password []byte) error {
+	for id, l := range c.Listings {
+		if !l.Fresh() {
+			delete(c.Listings, id)
+		}
+	}
+
+	return securefile.WriteJSON(path, aes, password, c)
+}
+
+// Get returns the listing of the directory at the Location, if it is in this Cache
+// and fresh. A directory by path is found by the ID it had when it was last seen.
+func (c *Cache) Get(dir api.Location) (*api.DirListing, bool) {
+	id := dir.ID
+	if !dir.IsID() {
+		id = c.IDs[cleanPath(dir.Path)]
+	}
+
+	l, ok := c.Listings[id]
+	if !ok || !l.Fresh() {
+		return nil, false
+	}
+
+	return &l.Listing, true
+}
+
+// Fresh returns the listings of this Cache that are fresh, in no particular order.
+func (c *Cache) Fresh() []api.DirListing {
+	listings := []api.DirListing{}
+	for _, l := range c.Listings {
+		if l.Fresh() {
+			listings = append(listings, l.Listing)
+		}
+	}
+
+	return listings
+}
+
+// Put adds the listing to this Cache, replacing the listing of the same directory.
+// The IDs of the directory and its sub directories are recorded by their path.
+func (c *Cache) Put(listing *api.DirListing) {
+	c.Listings[listing.Dir.ID] = Listing{Listing: *listing, ListedAt: time.Now()}
+	c.IDs[cleanPath(listing.Dir.DirPath)] = listing.Dir.ID
+	for _, d := range listing.Dirs {
+		c.IDs[cleanPath(d.DirPath)] = d.ID
+	}
+}
+
+// cleanPath returns the path of a directory as it is keyed in a Cache. The users
+// root directory is "/".
+func cleanPath(p string) string {
+	return "/" + strings.Trim(p, "/")
+}
+
+// Lister is an api.Lister that serves the listings from a Cache while they are
+// fresh. Any other directory is listed by Dirs and added to the Cache. If Refresh is
+// set, every directory is listed by Dirs.
+type Lister struct {
+	Cache   *Cache
+	Dirs    api.Lister
+	Refresh bool
+}
+
+// List returns the listing of the directory at the Location.
+func (l *Lister) List(ctx context.Context, dir api.Location) (*api.DirListing, error) {
+	if !l.Refresh {
+		if listing, ok := l.Cache.Get(dir); ok {
+			return listing, nil
+		}
+	}
+
+	listing, err := l.Dirs.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l.Cache.Put(listing)
+	return listing, nil
+}
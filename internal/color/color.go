@@ -0,0 +1,165 @@
+// Package color highlights the human readable output of the commands with ANSI
+// escape codes, such as errors in red and files that were uploaded in green.
+//
+// Color is only used when the mode allows it, see Mode.Enabled. By default it is
+// disabled when the output is not a terminal, or NO_COLOR is set.
+package color
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Mode is when the output is colored.
+type Mode string
+
+const (
+	// Auto colors the output if it is a terminal and NO_COLOR is not set.
+	Auto Mode = "auto"
+	// Always colors the output, even if it is not a terminal.
+	Always Mode = "always"
+	// Never does not color the output.
+	Never Mode = "never"
+)
+
+// ParseMode parses s into a Mode. An empty s is Auto.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case "":
+		return Auto, nil
+	case Auto, Always, Never:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown color mode '%s': must be auto, always, or never", s)
+	}
+}
+
+// Enabled checks if output written to f is colored in this Mode. In the Auto mode
+// it is colored if f is a terminal, NO_COLOR is not set, and TERM is not "dumb".
+func (m Mode) Enabled(f *os.File) bool {
+	switch m {
+	case Always:
+		return true
+	case Never:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	return IsTerminal(f)
+}
+
+// IsTerminal checks if f is a terminal, a character device such as a TTY.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// The styles of the highlighted text.
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[31m"
+	green  = "\x1b[32m"
+	yellow = "\x1b[33m"
+	cyan   = "\x1b[36m"
+)
+
+// Error returns s in the style of an error, red.
+func Error(s string) string {
+	return red + s + reset
+}
+
+// Success returns s in the style of something that succeeded, green.
+func Success(s string) string {
+	return green + s + reset
+}
+
+// Warning returns s in the style of a warning or a hint, yellow.
+func Warning(s string) string {
+	return yellow + s + reset
+}
+
+// Field returns s in the style of the name of a field, cyan.
+func Field(s string) string {
+	return cyan + s + reset
+}
+
+// Bold returns s in bold.
+func Bold(s string) string {
+	return bold + s + reset
+}
+
+// Writer is an io.Writer that highlights every line written to it before it is
+// written to the underlying io.Writer, if it is enabled. A disabled Writer writes
+// everything unchanged.
+//
+// The lines are highlighted by the highlight function, it returns the line with the
+// escape codes. A Writer is safe for concurrent use.
+type Writer struct {
+	mu        sync.Mutex
+	w         io.Writer
+	highlight func(line string) string
+	enabled   bool
+}
+
+// NewWriter creates and returns a disabled *Writer that writes to w, and highlights
+// the lines with highlight.
+func NewWriter(w io.Writer, highlight func(line string) string) *Writer {
+	return &Writer{w: w, highlight: highlight}
+}
+
+// SetEnabled sets if this Writer highlights the lines written to it.
+func (w *Writer) SetEnabled(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.enabled = enabled
+}
+
+// Enabled checks if this Writer highlights the lines written to it.
+func (w *Writer) Enabled() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enabled
+}
+
+// Write writes p to the underlying io.Writer. If this Writer is enabled, every line
+// of p is highlighted, a write that does not end with a newline is highlighted as a
+// line of its own. It returns len(p) if every byte was written.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.enabled {
+		return w.w.Write(p)
+	}
+
+	var b bytes.Buffer
+	for rest := p; len(rest) > 0; {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i]
+			rest = rest[i+1:]
+			b.WriteString(w.highlight(string(line)))
+			b.WriteByte('\n')
+			continue
+		}
+		b.WriteString(w.highlight(string(line)))
+		rest = nil
+	}
+
+	if _, err := w.w.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
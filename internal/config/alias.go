@@ -0,0 +1,68 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// aliasesFile is the name of the file that stores the aliases. The aliases are
+// stored within the Path of the Store, aliases are shared by every profile.
+const aliasesFile = "aliases.json"
+
+// Aliases are the named shortcuts of commands, keyed by name. The value is the
+// command line the alias expands to, without the leading "clox".
+type Aliases map[string]string
+
+// ValidateAliasName checks if name can be used as an alias name. An alias name can
+// only contain letters, digits, dashes, and underscores.
+func ValidateAliasName(name string) error {
+	if !profileNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid alias name '%s': only letters, digits, '-', and '_' are allowed", name)
+	}
+
+	return nil
+}
+
+// ReadAliases reads the aliases file. If the file does not exist, it returns an
+// empty Aliases.
+func (s *Store) ReadAliases() (Aliases, error) {
+	a := Aliases{}
+
+	filePath := filepath.Join(s.Path, aliasesFile)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return a, nil
+		}
+
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed unmarshalling %s: %w", filePath, err)
+	}
+
+	return a, nil
+}
+
+// WriteAliases writes the aliases file, replacing every alias that was stored.
+func (s *Store) WriteAliases(a Aliases) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling aliases: %w", err)
+	}
+
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(s.Path, aliasesFile)
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed writing file %s: %w", filePath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// ErrConfigModified is the error when the configuration file of a User was changed
+// by something other than the CLI, such as the public key or an encrypted key being
+// replaced, see VerifyIntegrity.
+var ErrConfigModified = errors.New("the config file was modified outside of the CLI")
+
+// ErrNoMAC is the error when the configuration file of a User has no MAC, see
+// VerifyIntegrity. It is an ErrConfigModified, the file may have been written before
+// the files were authenticated, or the MAC may have been removed. It is only
+// authenticated once the user confirmed its keys, see Authenticate.
+var ErrNoMAC = fmt.Errorf("%w: it has no mac", ErrConfigModified)
+
+// macKeySize is the size of the key that the configuration file is authenticated with.
+const macKeySize = 32
+
+// VerifyIntegrity checks that this User's configuration file was not changed since
+// the CLI wrote it. The file is authenticated with an HMAC-SHA256 over every field,
+// keyed by a random key that is encrypted with the password, so the public key or
+// an encrypted key cannot be replaced without the password. It must be called
+// before this User is changed, and the password must be this User's password.
+//
+// If the MAC does not match, or the MAC was removed but its key was not, it returns
+// ErrConfigModified. A file with neither returns ErrNoMAC, whatever its version: the
+// version is not authenticated, so a file that was modified and then written as an
+// older version must not be accepted either.
+//
+// Once verified, the file is authenticated again every time this User is written.
+func (u *User) VerifyIntegrity(aes *crypto.AES, password []byte) error {
+	if u.encryptedMACKey == "" && u.mac == "" {
+		return ErrNoMAC
+	}
+	if u.mac == "" {
+		return ErrConfigModified
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(u.encryptedMACKey)
+	if err != nil {
+		return ErrConfigModified
+	}
+	key, err := aes.DecryptWithPassword(decoded, password)
+	if err != nil {
+		return ErrConfigModified
+	}
+
+	mac, err := base64.StdEncoding.DecodeString(u.mac)
+	if err != nil {
+		key.Wipe()
+		return ErrConfigModified
+	}
+	expected, err := configMAC(key, u.configData())
+	if err != nil {
+		key.Wipe()
+		return err
+	}
+	if !hmac.Equal(mac, expected) {
+		key.Wipe()
+		return ErrConfigModified
+	}
+
+	u.macKey = key
+	return nil
+}
+
+// Authenticate authenticates this User's configuration file that has no MAC, see
+// ErrNoMAC. A MAC key is generated and encrypted with the password, and the file is
+// authenticated when it is written. It must only be called once the user confirmed
+// that the keys of the file are theirs, see 'clox config authenticate'. If the file
+// already has a MAC or a MAC key, an error is returned. The password must be this
+// User's password.
+func (u *User) Authenticate(aes *crypto.AES, password []byte) error {
+	if u.encryptedMACKey != "" || u.mac != "" {
+		return errors.New("the config file is already authenticated")
+	}
+	if err := u.VerifyPassword(password); err != nil {
+		return err
+	}
+
+	return u.newMACKey(aes, password)
+}
+
+// newMACKey generates the key that this User's configuration file is authenticated
+// with, and encrypts it with the password.
+func (u *User) newMACKey(aes *crypto.AES, password []byte) error {
+	key, err := aes.Generate()
+	if err != nil {
+		return fmt.Errorf("generating mac key: %w", err)
+	}
+	if err := u.encryptMACKey(aes, key, password); err != nil {
+		key.Wipe()
+		return err
+	}
+
+	u.macKey = key
+	return nil
+}
+
+// encryptMACKey encrypts the key that this User's configuration file is
+// authenticated with, with the password.
+func (u *User) encryptMACKey(aes *crypto.AES, key secret.Bytes, password []byte) error {
+	encrypted, err := aes.EncryptWithPassword(key, password)
+	if err != nil {
+		return fmt.Errorf("encrypting mac key: %w", err)
+	}
+
+	u.encryptedMACKey = base64.StdEncoding.EncodeToString(encrypted)
+	return nil
+}
+
+// configMAC returns the HMAC-SHA256 of the configuration data, with the key. The MAC
+// of the data itself is not authenticated.
+func configMAC(key []byte, d UserConfigData) ([]byte, error) {
+	d.MAC = ""
+	data, err := json.Marshal(&d)
+	if err != nil {
+		return nil, err
+	}
+
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil), nil
+}
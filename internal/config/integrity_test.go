@@ -0,0 +1,195 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/security"
+)
+
+// testKDF is an Argon2id KDF with the smallest parameters, so the tests derive keys
+// quickly.
+var testKDF = crypto.KDF{Name: crypto.Argon2id, Time: 1, Memory: 64, Threads: 1}
+
+var testPassword = []byte("correct horse")
+
+// testStore creates a Store in a temporary directory with the configuration file of
+// a new User, and returns it with the AES and Keys of the User.
+func testStore(t *testing.T) (*Store, *crypto.AES, *security.Keys) {
+	t.Helper()
+	aes := &crypto.AES{KDF: testKDF}
+	keys := &security.Keys{AES: aes}
+	user, err := NewUser(keys, aes, &crypto.RSA{}, testPassword, []byte("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Store{Path: t.TempDir(), Profile: DefaultProfile}
+	if err := os.MkdirAll(s.Dir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteConfigFile(user); err != nil {
+		t.Fatal(err)
+	}
+
+	return s, aes, keys
+}
+
+// editConfigFile changes the fields of the configuration file of the Store with edit,
+// as something other than the CLI would.
+func editConfigFile(t *testing.T, s *Store, edit func(fields map[string]any)) {
+	t.Helper()
+	path := filepath.Join(s.Dir(), configFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	edit(fields)
+	data, err = json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readUser(t *testing.T, s *Store) *User {
+	t.Helper()
+	user := &User{}
+	if err := s.ReadConfigFile(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	s, aes, _ := testStore(t)
+
+	user := readUser(t, s)
+	if user.version != ConfigVersion {
+		t.Errorf("version = %d, want %d", user.version, ConfigVersion)
+	}
+	if err := user.VerifyIntegrity(aes, testPassword); err != nil {
+		t.Fatalf("VerifyIntegrity() = %v, want nil", err)
+	}
+
+	// A verified User is authenticated again when it is written.
+	user.SetServer("https://clox.example.com")
+	if err := s.WriteConfigFile(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := readUser(t, s).VerifyIntegrity(aes, testPassword); err != nil {
+		t.Errorf("VerifyIntegrity() after writing = %v, want nil", err)
+	}
+}
+
+func TestVerifyIntegrityModified(t *testing.T) {
+	_, attackerKey, err := (&security.Keys{AES: &crypto.AES{KDF: testKDF}}).GenerateWithPassword([]byte("attacker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		edit  func(fields map[string]any)
+		noMAC bool
+	}{
+		{
+			name: "server",
+			edit: func(fields map[string]any) { fields["server"] = "https://attacker.example.com" },
+		},
+		{
+			name: "mac removed",
+			edit: func(fields map[string]any) { delete(fields, "mac") },
+		},
+		{
+			name: "mac key replaced",
+			edit: func(fields map[string]any) { fields["mac_key"] = fields["api_token"] },
+		},
+		{
+			// The version is not authenticated, a file that is written as the
+			// version before the files were authenticated is not trusted either.
+			name: "downgraded",
+			edit: func(fields map[string]any) {
+				fields["version"] = 1
+				delete(fields, "mac")
+				delete(fields, "mac_key")
+				fields["public_key"] = string(attackerKey)
+			},
+			noMAC: true,
+		},
+		{
+			name: "unversioned",
+			edit: func(fields map[string]any) {
+				delete(fields, "version")
+				delete(fields, "mac")
+				delete(fields, "mac_key")
+			},
+			noMAC: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, aes, _ := testStore(t)
+			editConfigFile(t, s, tt.edit)
+
+			user := readUser(t, s)
+			err := user.VerifyIntegrity(aes, testPassword)
+			if !errors.Is(err, ErrConfigModified) {
+				t.Fatalf("VerifyIntegrity() = %v, want ErrConfigModified", err)
+			}
+			if errors.Is(err, ErrNoMAC) != tt.noMAC {
+				t.Errorf("errors.Is(%v, ErrNoMAC) = %t, want %t", err, !tt.noMAC, tt.noMAC)
+			}
+
+			// A User that failed to verify is not authenticated when it is written.
+			if err := s.WriteConfigFile(user); err != nil {
+				t.Fatal(err)
+			}
+			if err := readUser(t, s).VerifyIntegrity(aes, testPassword); !errors.Is(err, ErrConfigModified) {
+				t.Errorf("VerifyIntegrity() after writing = %v, want ErrConfigModified", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, aes, _ := testStore(t)
+	editConfigFile(t, s, func(fields map[string]any) {
+		fields["version"] = 1
+		delete(fields, "mac")
+		delete(fields, "mac_key")
+	})
+
+	user := readUser(t, s)
+	if err := user.Authenticate(aes, []byte("wrong")); err == nil {
+		t.Error("Authenticate() with the wrong password succeeded")
+	}
+	if err := user.Authenticate(aes, testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteConfigFile(user); err != nil {
+		t.Fatal(err)
+	}
+
+	user = readUser(t, s)
+	if user.version != ConfigVersion {
+		t.Errorf("version = %d, want %d", user.version, ConfigVersion)
+	}
+	if err := user.VerifyIntegrity(aes, testPassword); err != nil {
+		t.Errorf("VerifyIntegrity() = %v, want nil", err)
+	}
+	if err := user.Authenticate(aes, testPassword); err == nil {
+		t.Error("Authenticate() of an authenticated file succeeded")
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/securefile"
+	"github.com/cicconee/clox-cli/internal/security"
+)
+
+// KeyBackup is the key material of a User, exported so the files of the User can be
+// decrypted on another machine. The secrets are encrypted with the password of the
+// User that exported it, the same as in the configuration file, and the backup file
+// is encrypted with it as a whole, see WriteKeyBackup.
+type KeyBackup struct {
+	// Version is the version of the format, see ConfigVersion.
+	Version int `json:"version"`
+	// PrivateKey is the RSA private key, encrypted with the password.
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	// EncryptKey is the encryption key, encrypted with the public key.
+	EncryptKey string `json:"encrypt_key"`
+	// NamesKey is the names key, encrypted with the password. It is empty if the
+	// User did not encrypt file names.
+	NamesKey string `json:"names_key,omitempty"`
+}
+
+// ExportKeys returns the key material of this User as a KeyBackup. If the password
+// is not this User's password, an error is returned. A private key on a hardware
+// token cannot be exported, ErrHardwareKey is returned.
+func (u *User) ExportKeys(password []byte) (*KeyBackup, error) {
+	if err := u.VerifyPassword(password); err != nil {
+		return nil, err
+	}
+	if u.HasHardwareKey() {
+		return nil, ErrHardwareKey
+	}
+
+	return &KeyBackup{
+		Version:    ConfigVersion,
+		PrivateKey: u.encryptedPrivateKey,
+		PublicKey:  u.publicKey,
+		EncryptKey: u.encryptedEncryptKey,
+		NamesKey:   u.encryptedNamesKey,
+	}, nil
+}
+
+// ImportKeys replaces the key material of this User with the KeyBackup. The secrets
+// of the backup are decrypted with backupPassword, the password it was exported with,
+// and encrypted with password, this User's password.
+//
+// The private key must be the key of the public key and decrypt the encryption key,
+// otherwise nothing is changed. The names key of this User is replaced even if the
+// backup has none. A private key on a hardware token is replaced by the private key
+// of the backup. The recovery codes of this User are removed, they recover the
+// replaced encryption key, see GenerateRecoveryCodes.
+func (u *User) ImportKeys(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, b *KeyBackup, backupPassword []byte, password []byte) error {
+	if err := u.VerifyPassword(password); err != nil {
+		return err
+	}
+
+	priv, err := keys.DecryptPrivateKey(b.PrivateKey, backupPassword)
+	if err != nil {
+		return fmt.Errorf("decrypting private key: %w", err)
+	}
+	defer security.WipePrivateKey(priv)
+	pub, err := keys.DecodePublicKey([]byte(b.PublicKey))
+	if err != nil {
+		return fmt.Errorf("decoding public key: %w", err)
+	}
+	if !priv.PublicKey.Equal(pub) {
+		return errors.New("private key does not match public key")
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(b.EncryptKey)
+	if err != nil {
+		return fmt.Errorf("decoding encryption key: %w", err)
+	}
+	encKey, err := rsa.Decrypt(wrapped, priv)
+	if err != nil {
+		return fmt.Errorf("decrypting encryption key: %w", err)
+	}
+	secret.Wipe(encKey)
+
+	encryptedPrivateKey, err := keys.ReencryptPrivateKey(b.PrivateKey, backupPassword, password)
+	if err != nil {
+		return fmt.Errorf("encrypting private key: %w", err)
+	}
+
+	var encryptedNamesKey string
+	if b.NamesKey != "" {
+		decoded, err := base64.StdEncoding.DecodeString(b.NamesKey)
+		if err != nil {
+			return fmt.Errorf("decoding names key: %w", err)
+		}
+		key, err := aes.DecryptWithPassword(decoded, backupPassword)
+		if err != nil {
+			return fmt.Errorf("decrypting names key: %w", err)
+		}
+		defer key.Wipe()
+		encrypted, err := aes.EncryptWithPassword(key, password)
+		if err != nil {
+			return fmt.Errorf("encrypting names key: %w", err)
+		}
+		encryptedNamesKey = base64.StdEncoding.EncodeToString(encrypted)
+	}
+
+	u.encryptedPrivateKey = string(encryptedPrivateKey)
+	u.publicKey = b.PublicKey
+	u.encryptedEncryptKey = b.EncryptKey
+	u.encryptedNamesKey = encryptedNamesKey
+	u.recoveryCodes = nil
+	u.recoveryNamesKey = ""
+	u.keyProvider = KeyProvider{}
+	return nil
+}
+
+// KeyVersion returns the version of the format that this User's private key is
+// encrypted in, see security.PrivateKeyVersion. A private key on a hardware token is
+// not stored in the configuration, ErrHardwareKey is returned.
+func (u *User) KeyVersion() (int, error) {
+	if u.HasHardwareKey() {
+		return 0, ErrHardwareKey
+	}
+
+	return security.EncryptedKeyVersion(u.encryptedPrivateKey)
+}
+
+// UpgradeKeys encrypts this User's private key again in the current format, see
+// security.PrivateKeyVersion, with the KDF and cipher of the aes. The API token,
+// names key, and MAC key are encrypted again with them as well, the same as
+// ChangePassword with the same password. The key pair and the encryption key do not
+// change. If the private key is already in the current format, it returns false and
+// nothing is changed. The password must be this User's password.
+//
+// A private key on a hardware token is not stored in the configuration,
+// ErrHardwareKey is returned.
+func (u *User) UpgradeKeys(keys *security.Keys, aes *crypto.AES, password []byte) (bool, error) {
+	if err := u.VerifyPassword(password); err != nil {
+		return false, err
+	}
+	version, err := u.KeyVersion()
+	if err != nil {
+		return false, err
+	}
+	if version >= security.PrivateKeyVersion {
+		return false, nil
+	}
+
+	if err := u.ChangePassword(keys, aes, password, password); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// WriteKeyBackup encrypts the KeyBackup with the password and writes it to the file
+// at path. Only the user can read or write the file.
+func WriteKeyBackup(path string, aes *crypto.AES, password []byte, b *KeyBackup) error {
+	return securefile.WriteJSON(path, aes, password, b)
+}
+
+// ReadKeyBackup reads the backup file at path and decrypts it with the password. If
+// the file does not exist, it returns securefile.ErrNotExist. A backup of a newer
+// version than ConfigVersion is an error.
+func ReadKeyBackup(path string, aes *crypto.AES, password []byte) (*KeyBackup, error) {
+	b := &KeyBackup{}
+	if err := securefile.ReadJSON(path, aes, password, b); err != nil {
+		return nil, err
+	}
+	if b.Version > ConfigVersion {
+		return nil, fmt.Errorf("backup version %d is not supported by this version of the CLI (%d), upgrade the CLI", b.Version, ConfigVersion)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ConfigVersion is the version of the configuration file format that is written by
+// this CLI. It is increased, and a migration is added, whenever a field of
+// UserConfigData is added or renamed in a way older files need to be upgraded for.
+const ConfigVersion = 2
+
+// migration upgrades the fields of a configuration file by one version. The fields
+// are keyed by their JSON name, a migration may add, rename, or remove fields.
+type migration func(fields map[string]json.RawMessage) error
+
+// migrations upgrade a configuration file to ConfigVersion. The migration at index i
+// upgrades a file of version i to version i+1. A file without a version is version
+// 0, it was written before the format was versioned.
+//
+// A nil migration needs the password, it is not done when the file is read. The
+// file keeps the version before it, and it is upgraded when the CLI writes it.
+var migrations = []migration{
+	// Version 0 to 1 only adds the version, the fields are unchanged.
+	func(fields map[string]json.RawMessage) error { return nil },
+	// Version 1 to 2 authenticates the file with a MAC, which is keyed by the
+	// password. A file without a MAC is never accepted, it is authenticated once the
+	// user confirmed its keys, see User.Authenticate, and written as version 2.
+	nil,
+}
+
+// migrate upgrades the configuration file data to ConfigVersion, or to the version
+// before the first nil migration. It returns the upgraded data, the version the data
+// had, and the version it was upgraded to. If no migration is done, the data is
+// returned as is. If the data is a newer version than this CLI supports, an error is
+// returned.
+func migrate(data []byte) ([]byte, int, int, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, 0, 0, err
+	}
+
+	version := 0
+	if v, ok := fields["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return nil, 0, 0, fmt.Errorf("invalid config version: %w", err)
+		}
+	}
+	if version > ConfigVersion || version < 0 {
+		return nil, version, version, fmt.Errorf("config version %d is not supported by this version of the CLI (%d), upgrade the CLI", version, ConfigVersion)
+	}
+
+	to := version
+	for ; to < ConfigVersion && migrations[to] != nil; to++ {
+		if err := migrations[to](fields); err != nil {
+			return nil, version, version, fmt.Errorf("migrating config from version %d to %d: %w", to, to+1, err)
+		}
+	}
+	if to == version {
+		return data, version, version, nil
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(to))
+
+	upgraded, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return nil, version, version, err
+	}
+
+	return upgraded, version, to, nil
+}
+
+// migrateConfigFile upgrades the configuration file at path, which has the data,
+// see migrate. Before the file is rewritten, the data is copied to a backup file next
+// to it named "config.json.v<version>.bak". The upgraded data is returned.
+func migrateConfigFile(path string, data []byte) ([]byte, error) {
+	upgraded, version, to, err := migrate(data)
+	if err != nil {
+		return nil, err
+	}
+	if to == version {
+		return data, nil
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed writing backup %s: %w", backup, err)
+	}
+	if err := writeFileAtomic(path, upgraded, 0600); err != nil {
+		return nil, fmt.Errorf("failed writing file %s: %w", path, err)
+	}
+
+	return upgraded, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the directory of path and
+// renames it to path, so the file at path is never partially written.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
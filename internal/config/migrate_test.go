@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateConfigFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantVersion int
+		wantBackup  string
+	}{
+		{
+			name:        "unversioned",
+			data:        `{"password":"hash","public_key":"key"}`,
+			wantVersion: 1,
+			wantBackup:  "config.json.v0.bak",
+		},
+		{
+			// Version 1 to 2 needs the password, the file is not changed when it is
+			// read.
+			name:        "version 1",
+			data:        `{"version":1,"password":"hash","public_key":"key"}`,
+			wantVersion: 1,
+		},
+		{
+			name:        "current",
+			data:        `{"version":2,"password":"hash","public_key":"key","mac":"mac"}`,
+			wantVersion: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, configFile)
+			if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			upgraded, err := migrateConfigFile(path, []byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var d UserConfigData
+			if err := json.Unmarshal(upgraded, &d); err != nil {
+				t.Fatal(err)
+			}
+			if d.Version != tt.wantVersion || d.PasswordHash != "hash" || d.PublicKey != "key" {
+				t.Errorf("migrateConfigFile() = %s, want version %d with the fields", upgraded, tt.wantVersion)
+			}
+
+			written, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(written, upgraded) {
+				t.Errorf("file = %s, want the upgraded data %s", written, upgraded)
+			}
+
+			backups, err := filepath.Glob(filepath.Join(dir, "*.bak"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantBackup == "" {
+				if len(backups) > 0 {
+					t.Errorf("backups = %v, want none", backups)
+				}
+				return
+			}
+			backup, err := os.ReadFile(filepath.Join(dir, tt.wantBackup))
+			if err != nil {
+				t.Fatalf("reading backup: %v", err)
+			}
+			if string(backup) != tt.data {
+				t.Errorf("backup = %s, want the original data %s", backup, tt.data)
+			}
+			fi, err := os.Stat(filepath.Join(dir, tt.wantBackup))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != 0600 {
+				t.Errorf("backup mode = %v, want 0600", fi.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestMigrateNewerVersion(t *testing.T) {
+	data := []byte(`{"version":99}`)
+	path := filepath.Join(t.TempDir(), configFile)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrateConfigFile(path, data); err == nil {
+		t.Error("migrateConfigFile() of a newer version succeeded")
+	}
+	written, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(written, data) {
+		t.Errorf("file = %s, %v, want it unchanged", written, err)
+	}
+}
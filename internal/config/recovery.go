@@ -0,0 +1,210 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/security"
+)
+
+// RecoveryCodeCount is the number of recovery codes that are generated for a User.
+const RecoveryCodeCount = 8
+
+// recoveryCodeSize is the number of random bytes of a recovery code, 80 bits.
+const recoveryCodeSize = 10
+
+// ErrInvalidRecoveryCode is the error when a recovery code is not one of the unused
+// recovery codes of a User.
+var ErrInvalidRecoveryCode = errors.New("invalid recovery code")
+
+// newRecoveryCode generates a random recovery code. It is 16 base32 characters, in
+// four groups of four separated by dashes, such as "ABCD-EFGH-IJKL-MNOP".
+func newRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	s := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// normalizeRecoveryCode returns a copy of the recovery code without dashes and
+// spaces, in upper case, so it can be entered as it was printed or not. The copy
+// must be wiped after use.
+func normalizeRecoveryCode(code []byte) secret.Bytes {
+	normalized := make(secret.Bytes, 0, len(code))
+	for _, c := range code {
+		switch {
+		case c == '-' || c == ' ':
+		case 'a' <= c && c <= 'z':
+			normalized = append(normalized, c-'a'+'A')
+		default:
+			normalized = append(normalized, c)
+		}
+	}
+	return normalized
+}
+
+// RecoveryCodes returns the number of unused recovery codes of this User.
+func (u *User) RecoveryCodes() int {
+	return len(u.recoveryCodes)
+}
+
+// GenerateRecoveryCodes generates RecoveryCodeCount recovery codes for this User and
+// returns them, the codes are not stored. Each code is used as a password to encrypt
+// the encryption key, so the encryption key can be recovered with any of them if the
+// password is lost, see Recover. The names key is encrypted with the encryption key.
+//
+// The recovery codes of this User are replaced, the codes generated before can no
+// longer be used. The password must be this User's password.
+func (u *User) GenerateRecoveryCodes(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, password []byte) ([]string, error) {
+	if err := u.VerifyPassword(password); err != nil {
+		return nil, err
+	}
+
+	encKey, err := u.EncryptKey(keys, rsa, password)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting encryption key: %w", err)
+	}
+	defer encKey.Wipe()
+
+	codes := make([]string, RecoveryCodeCount)
+	sealed := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		normalized := normalizeRecoveryCode([]byte(code))
+		encrypted, err := aes.EncryptWithPassword(encKey, normalized)
+		normalized.Wipe()
+		if err != nil {
+			return nil, fmt.Errorf("encrypting encryption key: %w", err)
+		}
+		codes[i] = code
+		sealed[i] = base64.StdEncoding.EncodeToString(encrypted)
+	}
+
+	var recoveryNamesKey string
+	if u.EncryptsNames() {
+		namesKey, err := u.NamesKey(aes, password)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting names key: %w", err)
+		}
+		defer namesKey.Wipe()
+		if recoveryNamesKey, err = sealRecoveryNamesKey(aes, namesKey, encKey); err != nil {
+			return nil, err
+		}
+	}
+
+	u.recoveryCodes = sealed
+	u.recoveryNamesKey = recoveryNamesKey
+	return codes, nil
+}
+
+// sealRecoveryNamesKey encrypts the names key with the encryption key, it is
+// decrypted by Recover.
+func sealRecoveryNamesKey(aes *crypto.AES, namesKey []byte, encKey []byte) (string, error) {
+	encrypted, err := aes.Encrypt(namesKey, encKey)
+	if err != nil {
+		return "", fmt.Errorf("encrypting names key: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// Recover sets a new password for this User with a recovery code, when the password
+// is lost. The code is removed, it is used once. If the code is not one of the unused
+// recovery codes of this User, it returns ErrInvalidRecoveryCode and nothing is
+// changed.
+//
+// The encryption key is decrypted with the code. The private key cannot be decrypted
+// without the password, so a new RSA key pair is generated, and the encryption key is
+// encrypted with the new public key, the files already uploaded are still decrypted
+// with it. A private key on a hardware token is replaced by the new key pair, so a
+// lost token is recovered the same as a lost password. The API token cannot be
+// decrypted either, the apiToken is encrypted with newPassword instead. The names key
+// is decrypted with the encryption key. The MAC key cannot be decrypted, a new one is
+// generated, so the configuration file is authenticated again when it is written,
+// see VerifyIntegrity.
+func (u *User) Recover(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, code []byte, newPassword []byte, apiToken []byte) error {
+	normalized := normalizeRecoveryCode(code)
+	defer normalized.Wipe()
+
+	index := -1
+	var encKey secret.Bytes
+	for i, sealed := range u.recoveryCodes {
+		decoded, err := base64.StdEncoding.DecodeString(sealed)
+		if err != nil {
+			continue
+		}
+		if key, err := aes.DecryptWithPassword(decoded, normalized); err == nil {
+			index, encKey = i, key
+			break
+		}
+	}
+	if index < 0 {
+		return ErrInvalidRecoveryCode
+	}
+	defer encKey.Wipe()
+
+	var encryptedNamesKey string
+	if u.EncryptsNames() {
+		decoded, err := base64.StdEncoding.DecodeString(u.recoveryNamesKey)
+		if err != nil {
+			return fmt.Errorf("decoding names key: %w", err)
+		}
+		namesKey, err := aes.Decrypt(decoded, encKey)
+		if err != nil {
+			return fmt.Errorf("decrypting names key: %w", err)
+		}
+		defer secret.Wipe(namesKey)
+		encrypted, err := aes.EncryptWithPassword(namesKey, newPassword)
+		if err != nil {
+			return fmt.Errorf("encrypting names key: %w", err)
+		}
+		encryptedNamesKey = base64.StdEncoding.EncodeToString(encrypted)
+	}
+
+	priv, pub, err := keys.GenerateWithPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("generating key pair: %w", err)
+	}
+	pubKey, err := keys.DecodePublicKey(pub)
+	if err != nil {
+		return err
+	}
+	encryptedEncryptKey, err := rsa.Encrypt(encKey, pubKey)
+	if err != nil {
+		return fmt.Errorf("encrypting encryption key: %w", err)
+	}
+
+	encryptedAPIToken, err := aes.EncryptWithPassword(apiToken, newPassword)
+	if err != nil {
+		return fmt.Errorf("encrypting api token: %w", err)
+	}
+
+	hashedPassword, err := hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := u.newMACKey(aes, newPassword); err != nil {
+		return err
+	}
+
+	u.passwordHash = string(hashedPassword)
+	u.encryptedAPIToken = base64.StdEncoding.EncodeToString(encryptedAPIToken)
+	u.encryptedPrivateKey = string(priv)
+	u.publicKey = string(pub)
+	u.encryptedEncryptKey = base64.StdEncoding.EncodeToString(encryptedEncryptKey)
+	u.encryptedNamesKey = encryptedNamesKey
+	u.keyProvider = KeyProvider{}
+	u.recoveryCodes = append(u.recoveryCodes[:index:index], u.recoveryCodes[index+1:]...)
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// settingsFile is the name of the file that stores the Settings. The settings are
+// stored within the Path of the Store, they are shared by every profile.
+const settingsFile = "settings.json"
+
+// Settings are the preferences of the CLI that are not secret and apply to every
+// profile. They are edited by hand, the file is not authenticated like the
+// configuration file of a profile.
+type Settings struct {
+	// Color is when the output is colored: auto, always, or never. If it is empty,
+	// it is auto. The color flags (--color, --no-color) replace it.
+	Color string `json:"color,omitempty"`
+	// Aliases are the aliases that are defined by hand, in addition to the aliases
+	// saved with 'clox alias set'. A saved alias with the same name replaces it.
+	Aliases Aliases `json:"aliases,omitempty"`
+	// Defaults are the defaults of the flags of the commands, see Defaults.
+	Defaults Defaults `json:"defaults,omitempty"`
+}
+
+// Defaults are the values of the flags that are used when they are not set on the
+// command line, keyed by the command and then the name of the flag. The command is
+// its command line without the leading "clox", such as "upload" or "alias list".
+// A value is a string, number, or boolean that is parsed the same as on the command
+// line, or a list of them for a flag that can be set more than once, such as
+//
+//	{"upload": {"path": "/work", "parallel": 4, "exclude": ["*.tmp", "*.log"]}}
+type Defaults map[string]map[string]any
+
+// ReadSettings reads the settings file. If the file does not exist, it returns empty
+// Settings.
+func (s *Store) ReadSettings() (Settings, error) {
+	var settings Settings
+
+	filePath := filepath.Join(s.Path, settingsFile)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return settings, nil
+		}
+
+		return settings, err
+	}
+
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return Settings{}, fmt.Errorf("failed unmarshalling %s: %w", filePath, err)
+	}
+
+	return settings, nil
+}
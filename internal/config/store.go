@@ -6,41 +6,275 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 )
 
 const (
-	configDir  = ".clox"
-	configFile = "config.json"
+	configDir   = ".clox"
+	xdgDir      = "clox"
+	configFile  = "config.json"
+	hooksDir    = "hooks"
+	profilesDir = "profiles"
+	profileFile = "profile.json"
+
+	// DefaultProfile is the name of the profile used when no other profile has been
+	// selected. The default profile is stored directly in the .clox directory.
+	DefaultProfile = "default"
 )
 
+// EnvConfigDir is the environment variable that overrides the configuration
+// directory, see ResolveDir.
+const EnvConfigDir = "CLOX_CONFIG_DIR"
+
 var ErrEmptyConfigFile = errors.New("config file is empty")
 
+// profileNameRegex matches a valid profile name.
+var profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 // Store manage the configuration IO for the Clox CLI app.
 //
+// The configuration is separated into profiles. Each profile has its own
+// "config.json" file. The default profile is stored in Path, every other profile is
+// stored in Path/profiles/<name>.
+//
 // Store should be created by calling NewStore.
 type Store struct {
-	// The path to the .clox directory. Path will always be the path to the users directory
-	// with /.clox appended at the end.
+	// The path to the configuration directory, see ResolveDir. By default it is the
+	// .clox directory in the users home directory.
 	Path string
+	// The name of the profile that this Store reads and writes.
+	Profile string
+	// Server is the base URL of the Clox API that is used instead of the server of
+	// the profile. It is never written to the configuration file.
+	Server string
+	// TLS is merged over the TLS configuration of the profile, the values that are
+	// set replace those of the profile. It is never written to the configuration
+	// file.
+	TLS TLS
+	// DebugHTTP is the path of the file that the requests of the API are logged to,
+	// "-" is standard error. If it is empty the requests are not logged. It is never
+	// written to the configuration file.
+	DebugHTTP string
+}
+
+// profilePointer is the structure of the file that stores the selected profile.
+type profilePointer struct {
+	Current  string `json:"current"`
+	Previous string `json:"previous"`
+}
+
+// NewStore creates a Store and sets the Path to path, the configuration directory
+// returned by ResolveDir.
+//
+// The Profile is set to the profile selected with SetDefaultProfile. If no profile
+// has been selected it is set to DefaultProfile.
+func NewStore(path string) (*Store, error) {
+	s := &Store{Path: path}
+	p, err := s.readProfilePointer()
+	if err != nil {
+		return nil, err
+	}
+	s.Profile = p.Current
+
+	return s, nil
 }
 
-// NewStore creates a Store and sets the Path to the users home directory joined with ".clox".
-// If it cannot get the users home directory an error is returned.
-func NewStore() (*Store, error) {
+// ResolveDir returns the path to the configuration directory. The first that is set
+// is used:
+//
+//  1. override, the value of the config flag (--config).
+//  2. CLOX_CONFIG_DIR.
+//  3. The .clox directory in the users home directory, if it exists.
+//  4. $XDG_CONFIG_HOME/clox, or ~/.config/clox if XDG_CONFIG_HOME is not set, if it
+//     exists.
+//  5. $XDG_CONFIG_HOME/clox, if XDG_CONFIG_HOME is set.
+//  6. The .clox directory in the users home directory.
+//
+// An existing ~/.clox keeps precedence over the XDG directory, so configurations
+// created before the XDG directory was supported are still found. The path is
+// returned absolute. If it cannot get the users home directory an error is
+// returned.
+func ResolveDir(override string) (string, error) {
+	if override == "" {
+		override = os.Getenv(EnvConfigDir)
+	}
+	if override != "" {
+		return filepath.Abs(override)
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed getting home directory: %w", err)
+		return "", fmt.Errorf("failed getting home directory: %w", err)
+	}
+
+	home := filepath.Join(homeDir, configDir)
+	if dirExists(home) {
+		return home, nil
+	}
+
+	xdgHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(xdgHome) {
+		// The XDG specification says a relative path is invalid and must be ignored.
+		xdgHome = ""
+	}
+	xdg := filepath.Join(homeDir, ".config", xdgDir)
+	if xdgHome != "" {
+		xdg = filepath.Join(xdgHome, xdgDir)
+	}
+	if dirExists(xdg) || xdgHome != "" {
+		return xdg, nil
+	}
+
+	return home, nil
+}
+
+// dirExists checks if path is an existing directory.
+func dirExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// ValidateProfileName checks if name can be used as a profile name. A profile name
+// can only contain letters, digits, dashes, and underscores.
+func ValidateProfileName(name string) error {
+	if !profileNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid profile name '%s': only letters, digits, '-', and '_' are allowed", name)
+	}
+
+	return nil
+}
+
+// ProfileDir returns the path to the directory of the profile.
+func (s *Store) ProfileDir(profile string) string {
+	if profile == DefaultProfile {
+		return s.Path
+	}
+
+	return filepath.Join(s.Path, profilesDir, profile)
+}
+
+// ProfileExists checks if the profile has a "config.json" file.
+func (s *Store) ProfileExists(profile string) bool {
+	fi, err := os.Stat(filepath.Join(s.ProfileDir(profile), configFile))
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// Profiles returns the names of the configured profiles, sorted by name.
+func (s *Store) Profiles() ([]string, error) {
+	profiles := []string{}
+	if s.ProfileExists(DefaultProfile) {
+		profiles = append(profiles, DefaultProfile)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.Path, profilesDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != DefaultProfile && s.ProfileExists(e.Name()) {
+			profiles = append(profiles, e.Name())
+		}
+	}
+	sort.Strings(profiles)
+
+	return profiles, nil
+}
+
+// DefaultProfile returns the selected profile and the profile that was selected
+// before it. If no profile was selected before, previous is empty.
+func (s *Store) DefaultProfile() (current string, previous string, err error) {
+	p, err := s.readProfilePointer()
+	if err != nil {
+		return "", "", err
+	}
+
+	return p.Current, p.Previous, nil
+}
+
+// SetDefaultProfile persistently selects the profile. The profile that was selected
+// before is remembered and returned by DefaultProfile. The Profile of this Store is
+// set to the profile.
+func (s *Store) SetDefaultProfile(profile string) error {
+	if err := ValidateProfileName(profile); err != nil {
+		return err
+	}
+
+	p, err := s.readProfilePointer()
+	if err != nil {
+		return err
+	}
+	if p.Current != profile {
+		p.Previous = p.Current
+		p.Current = profile
+	}
+
+	data, err := json.MarshalIndent(&p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling profile: %w", err)
 	}
 
-	return &Store{
-		Path: filepath.Join(homeDir, configDir),
-	}, nil
+	if err := os.MkdirAll(s.Path, 0700); err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(s.Path, profileFile)
+	if err := writeFileAtomic(filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed writing file %s: %w", filePath, err)
+	}
+
+	s.Profile = profile
+	return nil
 }
 
-// DirExists checks if the ".clox" directory exists on the file system. The path to the
-// ".clox" directory is the value of this Store's Path value.
+// readProfilePointer reads the file that stores the selected profile. If the file
+// does not exist, the DefaultProfile is selected.
+func (s *Store) readProfilePointer() (profilePointer, error) {
+	p := profilePointer{Current: DefaultProfile}
+
+	filePath := filepath.Join(s.Path, profileFile)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return p, nil
+		}
+
+		return p, err
+	}
+
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("failed unmarshalling %s: %w", filePath, err)
+	}
+	if p.Current == "" {
+		p.Current = DefaultProfile
+	}
+
+	return p, nil
+}
+
+// Dir returns the path to the directory of the Profile of this Store.
+func (s *Store) Dir() string {
+	return s.ProfileDir(s.Profile)
+}
+
+// Account returns the account the secrets of the Profile are stored under in the OS
+// keyring and the platform keystore. It is the absolute path of the directory of
+// the Profile, so profiles with the same name in different configuration
+// directories do not share a secret.
+func (s *Store) Account() string {
+	dir, err := filepath.Abs(s.Dir())
+	if err != nil {
+		return s.Dir()
+	}
+
+	return dir
+}
+
+// DirExists checks if the directory of the Profile exists on the file system. For the
+// default profile this is the configuration directory, the value of this Store's
+// Path.
 func (s *Store) DirExists() (bool, error) {
-	fi, err := os.Stat(s.Path)
+	fi, err := os.Stat(s.Dir())
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return false, nil
@@ -53,12 +287,13 @@ func (s *Store) DirExists() (bool, error) {
 		return true, nil
 	}
 
-	return false, fmt.Errorf("%s already exists as a file in home directory", configDir)
+	return false, fmt.Errorf("%s already exists as a file", s.Dir())
 }
 
-// FileExists checks if the "config.json" file exists within the Path of this Store.
+// FileExists checks if the "config.json" file exists within the directory of the
+// Profile.
 func (s *Store) FileExists() (bool, error) {
-	filePath := filepath.Join(s.Path, configFile)
+	filePath := filepath.Join(s.Dir(), configFile)
 	fi, err := os.Stat(filePath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -69,39 +304,78 @@ func (s *Store) FileExists() (bool, error) {
 	}
 
 	if fi.IsDir() {
-		return false, fmt.Errorf("%s exists as a directory in %s", configFile, s.Path)
+		return false, fmt.Errorf("%s exists as a directory in %s", configFile, s.Dir())
 	}
 
 	return true, nil
 }
 
-// WriteDir will write the .clox directory to the file system with the value of Path
-// in this Store.
+// File returns the path to the file named name within the directory of the Profile.
+// It is used for the files, other than the configuration file, that belong to a
+// profile.
+func (s *Store) File(name string) string {
+	return filepath.Join(s.Dir(), name)
+}
+
+// HooksDir returns the path to the directory containing the hook scripts. The
+// hooks directory is stored within the Path of this Store, hooks are shared by
+// every profile.
+func (s *Store) HooksDir() string {
+	return filepath.Join(s.Path, hooksDir)
+}
+
+// WriteDir will write the directory of the Profile to the file system. Any missing
+// parent directories, such as the configuration directory, are also written.
 func (s *Store) WriteDir() error {
-	return os.Mkdir(s.Path, 0700)
+	return os.MkdirAll(s.Dir(), 0700)
 }
 
 // WriteConfigFile marshalls the json.Marshaler and writes the result to a file "config.json".
-// The file is stored within the Path of this Store.
+// The file is stored within the directory of the Profile. The file is replaced
+// atomically, it is never left partially written.
 func (s *Store) WriteConfigFile(d json.Marshaler) error {
 	data, err := d.MarshalJSON()
 	if err != nil {
 		return fmt.Errorf("failed marshalling data to json: %w", err)
 	}
 
-	filePath := filepath.Join(s.Path, configFile)
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
+	filePath := filepath.Join(s.Dir(), configFile)
+	if err := writeFileAtomic(filePath, data, 0600); err != nil {
 		return fmt.Errorf("failed writing file %s: %w", filePath, err)
 	}
 
 	return nil
 }
 
-// ReadConfigFile reads the configuration file and unmarshalls the data into dst.
+// BackupConfigFile copies the configuration file of the Profile to a backup file next
+// to it named "config.json.<name>.bak", and returns the path of the backup. An
+// existing backup with the same name is replaced.
+func (s *Store) BackupConfigFile(name string) (string, error) {
+	filePath := filepath.Join(s.Dir(), configFile)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	backup := fmt.Sprintf("%s.%s.bak", filePath, name)
+	if err := writeFileAtomic(backup, data, 0600); err != nil {
+		return "", fmt.Errorf("failed writing backup %s: %w", backup, err)
+	}
+
+	return backup, nil
+}
+
+// ReadConfigFile reads the configuration file of the Profile and unmarshalls the
+// data into dst.
 //
 // If the file is empty it wont unmarshal the data and return ErrEmptyConfigFile.
+//
+// If the file was written by an older version of the CLI, it is upgraded in place
+// before it is unmarshalled, and the original file is kept as a backup. A migration
+// that needs the password is done when the file is written, see migrations. A file
+// of a newer version than ConfigVersion is an error.
 func (s *Store) ReadConfigFile(dst json.Unmarshaler) error {
-	filePath := filepath.Join(s.Path, configFile)
+	filePath := filepath.Join(s.Dir(), configFile)
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return err
@@ -111,5 +385,10 @@ func (s *Store) ReadConfigFile(dst json.Unmarshaler) error {
 		return ErrEmptyConfigFile
 	}
 
+	data, err = migrateConfigFile(filePath, data)
+	if err != nil {
+		return err
+	}
+
 	return dst.UnmarshalJSON(data)
 }
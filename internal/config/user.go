@@ -5,14 +5,37 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/secret"
 	"github.com/cicconee/clox-cli/internal/security"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var ErrUnsetUser = errors.New("user not configured")
 
+// ErrHardwareKey is the error when the private key of a User is needed, but it is
+// stored on a hardware token and cannot be read, see KeyProvider.
+var ErrHardwareKey = errors.New("the private key is stored on a hardware token")
+
+// KeyProviderPIV is the type of the KeyProvider of a private key on a PIV hardware
+// token, see security.PIV.
+const KeyProviderPIV = "piv"
+
+// KeyProvider is where the RSA private key of a User is stored. If the Type is not
+// set, the private key is stored in the configuration file, encrypted with the
+// password.
+type KeyProvider struct {
+	// Type is the type of the provider, KeyProviderPIV.
+	Type string `json:"type"`
+	// Slot is the PIV slot of the private key.
+	Slot string `json:"slot,omitempty"`
+	// Module is the path of the PKCS #11 module of the token. If it is not set, the
+	// OpenSC module is searched for.
+	Module string `json:"module,omitempty"`
+}
+
 // User manages the user configuration values.
 type User struct {
 	passwordHash        string
@@ -20,22 +43,108 @@ type User struct {
 	encryptedPrivateKey string
 	publicKey           string
 	encryptedEncryptKey string
+	server              string
+	replicas            []string
+	timeouts            Timeouts
+	tls                 TLS
+	proxyURL            string
+	kdf                 crypto.KDF
+	cipher              string
+	encryptedNamesKey   string
+	recoveryCodes       []string
+	recoveryNamesKey    string
+	keyProvider         KeyProvider
+	encryptedMACKey     string
+	mac                 string
+	// version is the version of the configuration file this User was read from,
+	// see ConfigVersion.
+	version int
+	// macKey is the key that the configuration file is authenticated with. It is
+	// set once the file is verified, see VerifyIntegrity.
+	macKey secret.Bytes
+}
+
+// TLS is the TLS configuration of the connections to the Clox server of a User. The
+// files are paths to PEM encoded files. A TLS that is not set verifies the server
+// with the certificate authorities of the system.
+type TLS struct {
+	// CAFile is a bundle of certificate authorities that the server is verified
+	// with, in addition to those of the system.
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile are the client certificate and its private key that are
+	// presented to a server that requires mutual TLS.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// InsecureSkipVerify disables the verification of the certificate of the
+	// server. It should only be used for testing.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// Merge returns this TLS with every value that is set in o replacing it.
+func (t TLS) Merge(o TLS) TLS {
+	if o.CAFile != "" {
+		t.CAFile = o.CAFile
+	}
+	if o.CertFile != "" {
+		t.CertFile = o.CertFile
+	}
+	if o.KeyFile != "" {
+		t.KeyFile = o.KeyFile
+	}
+	if o.InsecureSkipVerify {
+		t.InsecureSkipVerify = true
+	}
+
+	return t
+}
+
+// Timeouts are the time limits of the requests and commands of a User. Each value is
+// a duration such as "30s" or "10m", "0" is no limit. A value that is not set uses
+// the default of the CLI.
+type Timeouts struct {
+	// Request is the time limit of a request to the Clox API.
+	Request string `json:"request,omitempty"`
+	// Transfer is the time limit of a request that uploads or downloads the contents
+	// of a file.
+	Transfer string `json:"transfer,omitempty"`
+	// Command is the time limit of a command, including every request it sends.
+	Command string `json:"command,omitempty"`
 }
 
 // NewUser creates and returns a User. The public-private key pair will be generated
 // for the user. The password is hashed. The api token and private key is encrypted.
-func NewUser(k *security.Keys, aes *crypto.AES, rsa *crypto.RSA, password string, apiToken string) (*User, error) {
+// The key that the configuration file is authenticated with is generated, see
+// VerifyIntegrity.
+func NewUser(k *security.Keys, aes *crypto.AES, rsa *crypto.RSA, password []byte, apiToken []byte) (*User, error) {
 	priv, pub, err := k.GenerateWithPassword(password)
 	if err != nil {
 		return nil, err
 	}
 
+	return newUser(k, aes, rsa, priv, pub, password, apiToken)
+}
+
+// NewUserWithKey creates and returns a User with an existing private key, instead of
+// generating a key pair, see security.ParsePrivateKey. The private key is encrypted
+// with the password the same as a generated key.
+func NewUserWithKey(k *security.Keys, aes *crypto.AES, rsa *crypto.RSA, key *rsa.PrivateKey, password []byte, apiToken []byte) (*User, error) {
+	priv, pub, err := k.ImportWithPassword(key, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUser(k, aes, rsa, priv, pub, password, apiToken)
+}
+
+// newUser creates and returns a User with the encrypted private key and public key,
+// in the format of security.Keys.GenerateWithPassword.
+func newUser(k *security.Keys, aes *crypto.AES, rsa *crypto.RSA, priv []byte, pub []byte, password []byte, apiToken []byte) (*User, error) {
 	hashedPassword, err := hash(password)
 	if err != nil {
 		return nil, err
 	}
 
-	encryptedAPIToken, err := aes.EncryptWithPassword([]byte(apiToken), []byte(password))
+	encryptedAPIToken, err := aes.EncryptWithPassword(apiToken, password)
 	if err != nil {
 		return nil, err
 	}
@@ -55,13 +164,19 @@ func NewUser(k *security.Keys, aes *crypto.AES, rsa *crypto.RSA, password string
 		return nil, err
 	}
 
-	return &User{
+	u := &User{
 		passwordHash:        string(hashedPassword),
 		encryptedAPIToken:   base64.StdEncoding.EncodeToString(encryptedAPIToken),
 		encryptedPrivateKey: string(priv),
 		publicKey:           string(pub),
 		encryptedEncryptKey: base64.StdEncoding.EncodeToString(encryptedEncryptKey),
-	}, nil
+		version:             ConfigVersion,
+	}
+	if err := u.newMACKey(aes, password); err != nil {
+		return nil, err
+	}
+
+	return u, nil
 }
 
 // Validate validates that the user is completely configured. If any fields are not
@@ -75,7 +190,7 @@ func (u *User) Validate() error {
 		return errors.New("empty api token")
 	}
 
-	if u.encryptedPrivateKey == "" {
+	if u.encryptedPrivateKey == "" && u.keyProvider.Type == "" {
 		return errors.New("empty private key")
 	}
 
@@ -92,62 +207,419 @@ func (u *User) Validate() error {
 
 // VerifyPassword verifies if the password is correct. An error is returned if the password
 // is incorrect. If correct it will return nil.
-func (u *User) VerifyPassword(password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(u.passwordHash), []byte(password))
+func (u *User) VerifyPassword(password []byte) error {
+	return bcrypt.CompareHashAndPassword([]byte(u.passwordHash), password)
 }
 
 // RSAPrivateKey will decrypt this User's encrypted private key. It is returned as a
-// *rsa.PrivateKey.
-func (u *User) RSAPrivateKey(keys *security.Keys, password string) (*rsa.PrivateKey, error) {
+// *rsa.PrivateKey. If the private key is stored on a hardware token, ErrHardwareKey is
+// returned.
+func (u *User) RSAPrivateKey(keys *security.Keys, password []byte) (*rsa.PrivateKey, error) {
+	if u.HasHardwareKey() {
+		return nil, ErrHardwareKey
+	}
+
 	return keys.DecryptPrivateKey(u.encryptedPrivateKey, password)
 }
 
+// HasHardwareKey checks if this User's private key is stored on a hardware token.
+func (u *User) HasHardwareKey() bool {
+	return u.keyProvider.Type != ""
+}
+
+// KeyProvider returns where this User's private key is stored.
+func (u *User) KeyProvider() KeyProvider {
+	return u.keyProvider
+}
+
+// PrivateKey returns the security.KeyProvider of this User's private key. For a
+// private key in the configuration file, it is decrypted with the password.
+func (u *User) PrivateKey(keys *security.Keys, rsa *crypto.RSA, password []byte) security.KeyProvider {
+	if u.keyProvider.Type == KeyProviderPIV {
+		return &security.PIV{Slot: u.keyProvider.Slot, Module: u.keyProvider.Module}
+	}
+
+	return &security.PasswordKey{Keys: keys, RSA: rsa, EncryptedKey: u.encryptedPrivateKey, Password: password}
+}
+
+// SetHardwareKey moves this User's private key to the hardware token of the provider,
+// kp is how the provider is configured. The encryption key is decrypted with the
+// current private key and encrypted with the public key of the provider, and is
+// then decrypted by the provider to check that it can. The private key is removed
+// from the configuration. If the password is not this User's password, or any step
+// fails, nothing is changed.
+func (u *User) SetHardwareKey(keys *security.Keys, rsa *crypto.RSA, password []byte, provider security.KeyProvider, kp KeyProvider) error {
+	if err := u.VerifyPassword(password); err != nil {
+		return err
+	}
+
+	encKey, err := u.EncryptKey(keys, rsa, password)
+	if err != nil {
+		return fmt.Errorf("decrypting encryption key: %w", err)
+	}
+	defer encKey.Wipe()
+
+	pub, err := provider.PublicKey()
+	if err != nil {
+		return fmt.Errorf("reading public key: %w", err)
+	}
+	encrypted, err := rsa.Encrypt(encKey, pub)
+	if err != nil {
+		return fmt.Errorf("encrypting encryption key: %w", err)
+	}
+
+	decrypted, err := provider.Decrypt(encrypted)
+	if err != nil {
+		return fmt.Errorf("decrypting encryption key with the hardware key: %w", err)
+	}
+	defer secret.Wipe(decrypted)
+	if !encKey.Equal(decrypted) {
+		return errors.New("the hardware key did not decrypt the encryption key")
+	}
+
+	u.encryptedPrivateKey = ""
+	u.publicKey = string(keys.EncodePublicKey(pub))
+	u.encryptedEncryptKey = base64.StdEncoding.EncodeToString(encrypted)
+	u.keyProvider = kp
+	return nil
+}
+
 // RSAPublicKey will decode this User's public key. It is returned as a *rsa.PublicKey.
 func (u *User) RSAPublicKey(keys *security.Keys) (*rsa.PublicKey, error) {
 	return keys.DecodePublicKey([]byte(u.publicKey))
 }
 
-// APIToken decrypts this User's encrypted API token.
-func (u *User) APIToken(aes *crypto.AES, password string) (string, error) {
+// APIToken decrypts this User's encrypted API token. The token is a secret, it must
+// be wiped after use.
+func (u *User) APIToken(aes *crypto.AES, password []byte) (secret.Bytes, error) {
 	decoded, err := base64.StdEncoding.DecodeString(u.encryptedAPIToken)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	return aes.DecryptWithPassword(decoded, password)
+}
+
+// SetAPIToken encrypts the apiToken with the password and replaces this User's
+// encrypted API token. The password must be this User's password, otherwise the
+// token cannot be decrypted later.
+func (u *User) SetAPIToken(aes *crypto.AES, password []byte, apiToken []byte) error {
+	if err := u.VerifyPassword(password); err != nil {
+		return err
 	}
 
-	token, err := aes.DecryptWithPassword(decoded, []byte(password))
+	encryptedAPIToken, err := aes.EncryptWithPassword(apiToken, password)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return string(token), nil
+	u.encryptedAPIToken = base64.StdEncoding.EncodeToString(encryptedAPIToken)
+	return nil
+}
+
+// ChangePassword replaces the password of this User. The API token, private key,
+// names key, and MAC key are decrypted with oldPassword and encrypted with newPassword,
+// and the password hash is replaced. The encryption key is encrypted with the public key, it does not
+// change. If oldPassword is not this User's password, nothing is changed.
+func (u *User) ChangePassword(keys *security.Keys, aes *crypto.AES, oldPassword []byte, newPassword []byte) error {
+	if err := u.VerifyPassword(oldPassword); err != nil {
+		return err
+	}
+
+	token, err := u.APIToken(aes, oldPassword)
+	if err != nil {
+		return fmt.Errorf("decrypting api token: %w", err)
+	}
+	defer token.Wipe()
+	encryptedAPIToken, err := aes.EncryptWithPassword(token, newPassword)
+	if err != nil {
+		return fmt.Errorf("encrypting api token: %w", err)
+	}
+
+	// A private key on a hardware token is not encrypted with the password.
+	encryptedPrivateKey := []byte(u.encryptedPrivateKey)
+	if !u.HasHardwareKey() {
+		encryptedPrivateKey, err = keys.ReencryptPrivateKey(u.encryptedPrivateKey, oldPassword, newPassword)
+		if err != nil {
+			return fmt.Errorf("encrypting private key: %w", err)
+		}
+	}
+
+	hashedPassword, err := hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	var encryptedNamesKey string
+	if u.EncryptsNames() {
+		key, err := u.NamesKey(aes, oldPassword)
+		if err != nil {
+			return fmt.Errorf("decrypting names key: %w", err)
+		}
+		defer key.Wipe()
+		encrypted, err := aes.EncryptWithPassword(key, newPassword)
+		if err != nil {
+			return fmt.Errorf("encrypting names key: %w", err)
+		}
+		encryptedNamesKey = base64.StdEncoding.EncodeToString(encrypted)
+	}
+
+	// The MAC key is kept, it is only encrypted with the new password. A User that
+	// was not verified gets a new one.
+	macKey := u.macKey
+	if macKey == nil {
+		if macKey, err = aes.Generate(); err != nil {
+			return fmt.Errorf("generating mac key: %w", err)
+		}
+	}
+	encryptedMACKey, err := aes.EncryptWithPassword(macKey, newPassword)
+	if err != nil {
+		return fmt.Errorf("encrypting mac key: %w", err)
+	}
+
+	u.passwordHash = string(hashedPassword)
+	u.encryptedAPIToken = base64.StdEncoding.EncodeToString(encryptedAPIToken)
+	u.encryptedPrivateKey = string(encryptedPrivateKey)
+	u.encryptedNamesKey = encryptedNamesKey
+	u.encryptedMACKey = base64.StdEncoding.EncodeToString(encryptedMACKey)
+	u.macKey = macKey
+	return nil
 }
 
-func (u *User) EncryptKey(keys *security.Keys, rsa *crypto.RSA, password string) ([]byte, error) {
+// EncryptKey decrypts this User's encryption key with the private key, see
+// PrivateKey. A private key in the configuration file is decrypted with the
+// password. The key should be wiped after use.
+func (u *User) EncryptKey(keys *security.Keys, rsa *crypto.RSA, password []byte) (secret.Bytes, error) {
 	decoded, err := base64.StdEncoding.DecodeString(u.encryptedEncryptKey)
 	if err != nil {
 		return nil, err
 	}
 
-	privKey, err := u.RSAPrivateKey(keys, password)
+	return u.PrivateKey(keys, rsa, password).Decrypt(decoded)
+}
+
+// EncryptKeyFingerprint returns the Fingerprint of this User's encryption key as it
+// is stored, encrypted with the public key, see security.Fingerprint. It changes when
+// the encryption key is encrypted for a new key pair.
+func (u *User) EncryptKeyFingerprint() (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(u.encryptedEncryptKey)
+	if err != nil {
+		return "", err
+	}
+
+	return security.Fingerprint(decoded), nil
+}
+
+// RotateKeys replaces the RSA key pair of this User with a newly generated key pair.
+// The encryption key is decrypted with the private key, and encrypted with the new
+// public key, so the files already uploaded are still decrypted with it. The new
+// private key is encrypted with the password. If the password is not this User's
+// password, or the encryption key cannot be decrypted, nothing is changed.
+//
+// A private key on a hardware token cannot be replaced, ErrHardwareKey is returned.
+func (u *User) RotateKeys(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, password []byte) error {
+	if err := u.VerifyPassword(password); err != nil {
+		return err
+	}
+	if u.HasHardwareKey() {
+		return ErrHardwareKey
+	}
+
+	encKey, err := u.EncryptKey(keys, rsa, password)
+	if err != nil {
+		return fmt.Errorf("decrypting encryption key: %w", err)
+	}
+	defer encKey.Wipe()
+
+	priv, pub, err := keys.GenerateWithPassword(password)
+	if err != nil {
+		return fmt.Errorf("generating key pair: %w", err)
+	}
+	pubKey, err := keys.DecodePublicKey(pub)
+	if err != nil {
+		return err
+	}
+	encryptedEncryptKey, err := rsa.Encrypt(encKey, pubKey)
+	if err != nil {
+		return fmt.Errorf("encrypting encryption key: %w", err)
+	}
+
+	u.encryptedPrivateKey = string(priv)
+	u.publicKey = string(pub)
+	u.encryptedEncryptKey = base64.StdEncoding.EncodeToString(encryptedEncryptKey)
+	return nil
+}
+
+// Server returns the base URL of the Clox API of this User. It is empty if the
+// server was never set, the configuration was written before it could be.
+func (u *User) Server() string {
+	return u.server
+}
+
+// SetServer sets the base URL of the Clox API of this User.
+func (u *User) SetServer(url string) {
+	u.server = url
+}
+
+// Timeouts returns the time limits of the requests and commands of this User.
+func (u *User) Timeouts() Timeouts {
+	return u.timeouts
+}
+
+// TLS returns the TLS configuration of this User.
+func (u *User) TLS() TLS {
+	return u.tls
+}
+
+// SetTLS sets the TLS configuration of this User.
+func (u *User) SetTLS(t TLS) {
+	u.tls = t
+}
+
+// ProxyURL returns the URL of the proxy that the requests of this User are sent
+// through. It is empty if the proxy of the environment is used.
+func (u *User) ProxyURL() string {
+	return u.proxyURL
+}
+
+// KDF returns the key derivation function that this User's secrets are encrypted
+// with. It is not set if the configuration was written before it could be, the
+// default of the crypto package is used.
+func (u *User) KDF() crypto.KDF {
+	return u.kdf
+}
+
+// SetKDF sets the key derivation function that this User's secrets are encrypted
+// with.
+func (u *User) SetKDF(k crypto.KDF) {
+	u.kdf = k
+}
+
+// Cipher returns the name of the cipher that this User's files are encrypted with.
+// It is empty if it was never set, the default of the crypto package is used.
+func (u *User) Cipher() string {
+	return u.cipher
+}
+
+// SetCipher sets the name of the cipher that this User's files are encrypted with.
+func (u *User) SetCipher(name string) {
+	u.cipher = name
+}
+
+// EncryptsNames checks if the names of this User's files are encrypted on the
+// server, a names key is set.
+func (u *User) EncryptsNames() bool {
+	return u.encryptedNamesKey != ""
+}
+
+// NamesKey decrypts the key that this User's file names are encrypted with. The key
+// should be wiped after use.
+func (u *User) NamesKey(aes *crypto.AES, password []byte) (secret.Bytes, error) {
+	decoded, err := base64.StdEncoding.DecodeString(u.encryptedNamesKey)
 	if err != nil {
 		return nil, err
 	}
 
-	return rsa.Decrypt(decoded, privKey)
+	return aes.DecryptWithPassword(decoded, password)
+}
+
+// SetNamesKey encrypts the key with the password and sets it as the key that this
+// User's file names are encrypted with. The password must be this User's password. If
+// this User has recovery codes, the key is also encrypted with the encryption key, so
+// it is recovered with them, see Recover.
+func (u *User) SetNamesKey(keys *security.Keys, aes *crypto.AES, rsa *crypto.RSA, password []byte, key []byte) error {
+	if err := u.VerifyPassword(password); err != nil {
+		return err
+	}
+
+	encrypted, err := aes.EncryptWithPassword(key, password)
+	if err != nil {
+		return err
+	}
+
+	var recoveryNamesKey string
+	if u.RecoveryCodes() > 0 {
+		encKey, err := u.EncryptKey(keys, rsa, password)
+		if err != nil {
+			return fmt.Errorf("decrypting encryption key: %w", err)
+		}
+		defer encKey.Wipe()
+		if recoveryNamesKey, err = sealRecoveryNamesKey(aes, key, encKey); err != nil {
+			return err
+		}
+	}
+
+	u.encryptedNamesKey = base64.StdEncoding.EncodeToString(encrypted)
+	u.recoveryNamesKey = recoveryNamesKey
+	return nil
+}
+
+// Replicas returns the base URLs of the replica servers of this User. Read requests
+// fail over to them in order when the server cannot be reached.
+func (u *User) Replicas() []string {
+	return u.replicas
+}
+
+// AddReplica adds the base URL of a replica server to this User. If the replica is
+// already set, it returns false.
+func (u *User) AddReplica(url string) bool {
+	for _, r := range u.replicas {
+		if r == url {
+			return false
+		}
+	}
+
+	u.replicas = append(u.replicas, url)
+	return true
+}
+
+// RemoveReplica removes the base URL of a replica server from this User. If the
+// replica is not set, it returns false.
+func (u *User) RemoveReplica(url string) bool {
+	for i, r := range u.replicas {
+		if r == url {
+			u.replicas = append(u.replicas[:i], u.replicas[i+1:]...)
+			return true
+		}
+	}
+
+	return false
 }
 
 // hash hashes the password.
-func hash(password string) ([]byte, error) {
-	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func hash(password []byte) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
 }
 
 // UserConfigData is the structure used to marshal and unmarshal a User to JSON.
 type UserConfigData struct {
-	PasswordHash        string `json:"password"`
-	EncryptedAPIToken   string `json:"api_token"`
-	EncryptedPrivateKey string `json:"private_key"`
-	PublicKey           string `json:"public_key"`
-	EncryptedEncryptKey string `json:"encrypt_key"`
+	// Version is the version of the format, see ConfigVersion.
+	Version             int         `json:"version"`
+	PasswordHash        string      `json:"password"`
+	EncryptedAPIToken   string      `json:"api_token"`
+	EncryptedPrivateKey string      `json:"private_key"`
+	PublicKey           string      `json:"public_key"`
+	EncryptedEncryptKey string      `json:"encrypt_key"`
+	Server              string      `json:"server,omitempty"`
+	Replicas            []string    `json:"replicas,omitempty"`
+	Timeouts            *Timeouts   `json:"timeouts,omitempty"`
+	TLS                 *TLS        `json:"tls,omitempty"`
+	ProxyURL            string      `json:"proxy_url,omitempty"`
+	KDF                 *crypto.KDF `json:"kdf,omitempty"`
+	Cipher              string      `json:"cipher,omitempty"`
+	EncryptedNamesKey   string      `json:"names_key,omitempty"`
+	// KeyProvider is where the private key is stored, if it is not in
+	// EncryptedPrivateKey.
+	KeyProvider *KeyProvider `json:"key_provider,omitempty"`
+	// RecoveryCodes are the encryption key encrypted with each unused recovery code.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	// RecoveryNamesKey is the names key encrypted with the encryption key.
+	RecoveryNamesKey string `json:"recovery_names_key,omitempty"`
+	// MACKey is the key that the configuration file is authenticated with,
+	// encrypted with the password.
+	MACKey string `json:"mac_key,omitempty"`
+	// MAC is the HMAC-SHA256 of every other field, see User.VerifyIntegrity.
+	MAC string `json:"mac,omitempty"`
 }
 
 // UnmarshalJSON accepts a []byte which represents a users configuration and unmarshal
@@ -164,18 +636,84 @@ func (u *User) UnmarshalJSON(data []byte) error {
 	u.encryptedPrivateKey = d.EncryptedPrivateKey
 	u.publicKey = d.PublicKey
 	u.encryptedEncryptKey = d.EncryptedEncryptKey
+	u.server = d.Server
+	u.replicas = d.Replicas
+	if d.Timeouts != nil {
+		u.timeouts = *d.Timeouts
+	}
+	if d.TLS != nil {
+		u.tls = *d.TLS
+	}
+	u.proxyURL = d.ProxyURL
+	if d.KDF != nil {
+		u.kdf = *d.KDF
+	}
+	u.cipher = d.Cipher
+	u.encryptedNamesKey = d.EncryptedNamesKey
+	u.recoveryCodes = d.RecoveryCodes
+	u.recoveryNamesKey = d.RecoveryNamesKey
+	if d.KeyProvider != nil {
+		u.keyProvider = *d.KeyProvider
+	}
+	u.encryptedMACKey = d.MACKey
+	u.mac = d.MAC
+	u.version = d.Version
 	return nil
 }
 
-// MarshalJSON will marshal this user into JSON and return it as a []byte.
+// MarshalJSON will marshal this user into JSON and return it as a []byte. If the
+// configuration file of this User was verified, see VerifyIntegrity, it is
+// authenticated with a new MAC and written as ConfigVersion. Otherwise it is written
+// without one, as the version it was read from.
 func (u *User) MarshalJSON() ([]byte, error) {
+	d := u.configData()
+	if u.macKey != nil {
+		d.Version = ConfigVersion
+		mac, err := configMAC(u.macKey, d)
+		if err != nil {
+			return nil, err
+		}
+		d.MAC = base64.StdEncoding.EncodeToString(mac)
+	}
+
+	return json.MarshalIndent(&d, "", "  ")
+}
+
+// configData returns the UserConfigData of this User, without the MAC, as the
+// version it was read from.
+func (u *User) configData() UserConfigData {
 	d := UserConfigData{
+		Version:             u.version,
 		PasswordHash:        u.passwordHash,
 		EncryptedAPIToken:   u.encryptedAPIToken,
 		EncryptedPrivateKey: u.encryptedPrivateKey,
 		PublicKey:           u.publicKey,
 		EncryptedEncryptKey: u.encryptedEncryptKey,
+		Server:              u.server,
+		Replicas:            u.replicas,
+		ProxyURL:            u.proxyURL,
+		Cipher:              u.cipher,
+		EncryptedNamesKey:   u.encryptedNamesKey,
+		RecoveryCodes:       u.recoveryCodes,
+		RecoveryNamesKey:    u.recoveryNamesKey,
+		MACKey:              u.encryptedMACKey,
+	}
+	if u.timeouts != (Timeouts{}) {
+		t := u.timeouts
+		d.Timeouts = &t
+	}
+	if u.tls != (TLS{}) {
+		t := u.tls
+		d.TLS = &t
+	}
+	if u.kdf != (crypto.KDF{}) {
+		k := u.kdf
+		d.KDF = &k
+	}
+	if u.keyProvider != (KeyProvider{}) {
+		kp := u.keyProvider
+		d.KeyProvider = &kp
 	}
 
-	return json.MarshalIndent(&d, "", "  ")
+	return d
 }
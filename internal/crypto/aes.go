@@ -1,124 +1,165 @@
 package crypto
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
-	"crypto/sha256"
 	"errors"
 	"io"
 
-	"golang.org/x/crypto/pbkdf2"
+	"github.com/cicconee/clox-cli/internal/secret"
 )
 
 // AES handles the AES (Advanced Encryption Standard) with GCM (Galois/Counter Mode)
-// encryption.
-type AES struct{}
+// encryption. It can encrypt with XChaCha20-Poly1305 instead, see Cipher.
+type AES struct {
+	// KDF is the key derivation function of EncryptWithPassword. If it is not set,
+	// DefaultKDF is used. Data is always decrypted with the KDF it was encrypted
+	// with, so the KDF can change without breaking older data.
+	KDF KDF
+	// Cipher is the cipher that new data is encrypted with, AESGCM or
+	// XChaCha20Poly1305. If it is not set, AESGCM is used. Data is always decrypted
+	// with the cipher it was encrypted with.
+	Cipher string
+}
+
+// currentCipher returns the name of the cipher that new data is encrypted with.
+func (a *AES) currentCipher() string {
+	if a.Cipher == "" {
+		return AESGCM
+	}
+
+	return a.Cipher
+}
 
 // EncryptWithPassword encrypts data using the password. A unique salt is generated
-// and used with the password to create the encryption key. The encrypted data is
-// returned as a []byte. The salt is prepended to the encrypted data.
+// and used with the password to create the encryption key with the KDF. The
+// encrypted data is returned as a []byte. A header that records the KDF and its
+// parameters, and the salt, are prepended to the encrypted data. The key derived
+// from the password is wiped after use.
 func (a *AES) EncryptWithPassword(data []byte, password []byte) ([]byte, error) {
-	salt := make([]byte, 16)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		return nil, err
+	kdf := a.KDF
+	if kdf == (KDF{}) {
+		kdf = DefaultKDF
 	}
-
-	key := pbkdf2.Key(password, salt, 4096, 32, sha256.New)
-	block, err := aes.NewCipher(key)
-	if err != nil {
+	if err := kdf.Validate(); err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
 		return nil, err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	key := secret.Bytes(kdf.key(password, salt))
+	defer key.Wipe()
+
+	encrypted, err := a.Encrypt(data, key)
+	if err != nil {
 		return nil, err
 	}
 
-	encrypted := gcm.Seal(nonce, nonce, data, nil)
-	return append(salt, encrypted...), nil
+	out := append(kdf.header(), salt...)
+	return append(out, encrypted...), nil
 }
 
 // DecryptWithPassword decrypts data using the password. The salt and password
-// are used to create the encryption key. The data is decrypted and returned as
-// a []byte. Only the password used to encrypt the data will be able to decrypt it.
-func (a *AES) DecryptWithPassword(data []byte, password []byte) ([]byte, error) {
-	salt := data[:16]
-	encryptedData := data[16:]
-
-	key := pbkdf2.Key(password, salt, 4096, 32, sha256.New)
-	blockCipher, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(blockCipher)
-	if err != nil {
-		return nil, err
+// are used to create the encryption key with the KDF recorded in the header of the
+// data. The data is decrypted and returned as secret.Bytes, the data protected by a
+// password is a secret, such as a private key, and must be wiped by the caller after
+// use. Only the password used to encrypt the data will be able to decrypt it. The
+// key derived from the password is wiped after use.
+//
+// Data without a header was encrypted before the KDF was recorded, its key is
+// derived with 4096 iterations of PBKDF2.
+func (a *AES) DecryptWithPassword(data []byte, password []byte) (secret.Bytes, error) {
+	if kdf, rest, ok := parseHeader(data); ok && len(rest) >= 16 {
+		key := secret.Bytes(kdf.key(password, rest[:16]))
+		decrypted, err := a.Decrypt(rest[16:], key)
+		key.Wipe()
+		if err == nil {
+			return decrypted, nil
+		}
+		// Legacy data can start with the magic by chance, it is tried as legacy data
+		// before failing.
 	}
 
-	nonceSize := gcm.NonceSize()
-	if len(encryptedData) < nonceSize {
+	if len(data) < 16 {
 		return nil, errors.New("ciphertext too short")
 	}
 
-	nonce, ciphertext := encryptedData[:nonceSize], encryptedData[nonceSize:]
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	key := secret.Bytes(legacyKDF.key(password, data[:16]))
+	defer key.Wipe()
+	return a.Decrypt(data[16:], key)
 }
 
-// Generates a random 32-byte key for AES encryption.
-func (a *AES) Generate() ([]byte, error) {
+// EncryptedSize returns the size of n bytes of data after it is encrypted with
+// Encrypt and AESGCM. The nonce and GCM tag are added to the data.
+func EncryptedSize(n int64) int64 {
+	return n + 12 + 16
+}
+
+// Generates a random 32-byte key for AES encryption. The key is a secret, it must be
+// wiped after use.
+func (a *AES) Generate() (secret.Bytes, error) {
 	key := make([]byte, 32)
 	_, err := rand.Read(key)
 	return key, err
 }
 
-// EncryptWithPassword encrypts data using the password. A unique salt is generated
-// and used with the password to create the encryption key. The encrypted data is
-// returned as a []byte. The salt is prepended to the encrypted data.
+// Encrypt encrypts data using the key with the cipher of this AES. A random nonce
+// is prepended to the encrypted data, which is returned as a []byte.
+//
+// Data encrypted with AESGCM has no header, as it always had. Data encrypted with
+// any other cipher starts with a header that records the cipher, the header is
+// authenticated with the data.
 func (a *AES) Encrypt(data []byte, key []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+	name := a.currentCipher()
+	aead, err := newAEAD(name, key)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
+	if name == AESGCM {
+		return aead.Seal(nonce, nonce, data, nil), nil
 	}
 
-	return gcm.Seal(nonce, nonce, data, nil), nil
+	header := cipherHeader(name)
+	return aead.Seal(append(header, nonce...), nonce, data, header), nil
 }
 
-// DecryptWithPassword decrypts data using the password. The salt and password
-// are used to create the encryption key. The data is decrypted and returned as
-// a []byte. Only the password used to encrypt the data will be able to decrypt it.
+// Decrypt decrypts data that was encrypted with Encrypt using the key. The data is
+// decrypted with the cipher recorded in its header, or AESGCM if it has none, and
+// returned as a []byte.
 func (a *AES) Decrypt(encryptedData []byte, key []byte) ([]byte, error) {
-	blockCipher, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+	if name, rest, ok := parseCipherHeader(encryptedData); ok {
+		decrypted, err := open(name, key, rest, encryptedData[:cipherHeaderSize])
+		if err == nil {
+			return decrypted, nil
+		}
+		// The nonce of AES-GCM data can start with the magic by chance, it is tried
+		// as AES-GCM data before failing.
 	}
 
-	gcm, err := cipher.NewGCM(blockCipher)
+	return open(AESGCM, key, encryptedData, nil)
+}
+
+// open decrypts the nonce and ciphertext in data using the key with the cipher of
+// the name, authenticating the additional data with it.
+func open(name string, key []byte, data []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(name, key)
 	if err != nil {
 		return nil, err
 	}
 
-	nonceSize := gcm.NonceSize()
-	if len(encryptedData) < nonceSize {
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize {
 		return nil, errors.New("ciphertext too short")
 	}
 
-	nonce, ciphertext := encryptedData[:nonceSize], encryptedData[nonceSize:]
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
 }
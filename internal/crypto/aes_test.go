@@ -0,0 +1,125 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// testKDF is an Argon2id KDF with the smallest parameters, so the tests derive keys
+// quickly.
+var testKDF = KDF{Name: Argon2id, Time: 1, Memory: 64, Threads: 1}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEncryptWithPassword(t *testing.T) {
+	data := []byte("private key")
+	password := []byte("correct horse")
+
+	for _, kdf := range []KDF{testKDF, {Name: PBKDF2, Time: 1000}} {
+		for _, name := range []string{AESGCM, XChaCha20Poly1305} {
+			t.Run(kdf.Name+"/"+name, func(t *testing.T) {
+				a := &AES{KDF: kdf, Cipher: name}
+				encrypted, err := a.EncryptWithPassword(data, password)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				k, c, ok := ParsePasswordHeader(encrypted)
+				if !ok || k != kdf || c != name {
+					t.Errorf("ParsePasswordHeader() = %v, %s, %t, want %v, %s, true", k, c, ok, kdf, name)
+				}
+
+				// Data is decrypted with the KDF and cipher it was encrypted with,
+				// not the ones of the AES.
+				decrypted, err := (&AES{}).DecryptWithPassword(encrypted, password)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(decrypted, data) {
+					t.Errorf("DecryptWithPassword() = %q, want %q", decrypted, data)
+				}
+
+				if _, err := a.DecryptWithPassword(encrypted, []byte("wrong")); err == nil {
+					t.Error("DecryptWithPassword() with the wrong password succeeded")
+				}
+			})
+		}
+	}
+}
+
+func TestDecryptWithPasswordKnownAnswer(t *testing.T) {
+	password := []byte("correct horse")
+	want := []byte("legacy private key")
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			// Data encrypted before the KDF was recorded, the salt followed by the
+			// AES-GCM nonce and ciphertext, keyed with 4096 iterations of PBKDF2.
+			name: "legacy pbkdf2",
+			data: "303132333435363738396162636465666e6f6e63652d3031323334351772c611e4919e5724f300656fbf1693bfe896f1342229d6aea8410ca72fcf00ff89",
+		},
+		{
+			name: "argon2id header",
+			data: "636c780102000000010000004001303132333435363738396162636465666e6f6e63652d303132333435678367c916d2be8901bd05126ff9053d78b5930547604ff9d82048a909b82cba0ab1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decrypted, err := (&AES{}).DecryptWithPassword(mustHex(t, tt.data), password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted, want) {
+				t.Errorf("DecryptWithPassword() = %q, want %q", decrypted, want)
+			}
+		})
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	data := []byte("file name")
+
+	for _, name := range []string{AESGCM, XChaCha20Poly1305} {
+		t.Run(name, func(t *testing.T) {
+			a := &AES{Cipher: name}
+			encrypted, err := a.Encrypt(data, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, hasHeader := parseCipherHeader(encrypted)
+			if hasHeader != (name != AESGCM) {
+				t.Errorf("cipher header = %t, want %t", hasHeader, name != AESGCM)
+			}
+			if name == AESGCM && int64(len(encrypted)) != EncryptedSize(int64(len(data))) {
+				t.Errorf("len = %d, want EncryptedSize() = %d", len(encrypted), EncryptedSize(int64(len(data))))
+			}
+
+			decrypted, err := (&AES{}).Decrypt(encrypted, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Errorf("Decrypt() = %q, want %q", decrypted, data)
+			}
+
+			encrypted[len(encrypted)-1] ^= 1
+			if _, err := a.Decrypt(encrypted, key); err == nil {
+				t.Error("Decrypt() of modified data succeeded")
+			}
+		})
+	}
+}
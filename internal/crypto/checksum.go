@@ -0,0 +1,62 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrChecksumMismatch is the error when the SHA-256 digest of decrypted contents is
+// not the digest in their checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Checksum reads r until EOF and returns the checksum of the plain text read, a
+// SHA-256 digest encrypted with the key using Encrypt, base64 encoded. The digest is
+// encrypted so the server that stores the checksum cannot confirm a guess of the
+// contents.
+func (a *AES) Checksum(r io.Reader, key []byte) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return a.SealChecksum(h.Sum(nil), key)
+}
+
+// SealChecksum returns the checksum of the SHA-256 digest, see Checksum.
+func (a *AES) SealChecksum(digest []byte, key []byte) (string, error) {
+	encrypted, err := a.Encrypt(digest, key)
+	if err != nil {
+		return "", fmt.Errorf("encrypting checksum: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// VerifyChecksum checks that the SHA-256 digest is the digest in the checksum, it
+// is decrypted with the key. If the digests are different, it returns
+// ErrChecksumMismatch.
+func (a *AES) VerifyChecksum(checksum string, digest []byte, key []byte) error {
+	decoded, err := base64.StdEncoding.DecodeString(checksum)
+	if err != nil {
+		return fmt.Errorf("decoding checksum: %w", err)
+	}
+
+	want, err := a.Decrypt(decoded, key)
+	if err != nil {
+		return fmt.Errorf("decrypting checksum: %w", err)
+	}
+	if subtle.ConstantTimeCompare(want, digest) != 1 {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+// Checksum returns the checksum of the plain text read from r with AES.Checksum.
+func (c *ChunkedAES) Checksum(r io.Reader, key []byte) (string, error) {
+	return c.AES.Checksum(r, key)
+}
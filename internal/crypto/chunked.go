@@ -0,0 +1,324 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ChunkSize is the size of the plain text of every full chunk of chunked encrypted
+// data.
+const ChunkSize = 64 * 1024
+
+// chunkedMagic is the header of chunked encrypted data that is encrypted with
+// AESGCM. It separates chunked data from data encrypted with AES.Encrypt.
+var chunkedMagic = []byte("CLXC1")
+
+// chunkedMagicV2 starts the header of chunked encrypted data that is encrypted with
+// any other cipher. It is followed by the identifier of the cipher.
+var chunkedMagicV2 = []byte("CLXC2")
+
+// MaxChunkedHeaderSize is the size of the largest chunked header. The header of
+// chunked data is within its first MaxChunkedHeaderSize bytes, see ForChunked.
+const MaxChunkedHeaderSize = 5 + 1
+
+// IsChunked checks if the encrypted data was encrypted with AES.EncryptChunks.
+func IsChunked(data []byte) bool {
+	return bytes.HasPrefix(data, chunkedMagic) || bytes.HasPrefix(data, chunkedMagicV2)
+}
+
+// chunkedHeader returns the chunked header of new data encrypted with the cipher of
+// this AES.
+func (a *AES) chunkedHeader() []byte {
+	name := a.currentCipher()
+	if name == AESGCM {
+		return chunkedMagic
+	}
+
+	return append(append([]byte{}, chunkedMagicV2...), cipherIDs[name])
+}
+
+// parseChunkedHeader returns the name of the cipher recorded in the chunked header
+// at the start of data, and the size of the header.
+func parseChunkedHeader(data []byte) (string, int, error) {
+	switch {
+	case bytes.HasPrefix(data, chunkedMagic):
+		return AESGCM, len(chunkedMagic), nil
+	case bytes.HasPrefix(data, chunkedMagicV2):
+		if len(data) < MaxChunkedHeaderSize {
+			return "", 0, errors.New("truncated chunked header")
+		}
+		name, ok := cipherName(data[len(chunkedMagicV2)])
+		if !ok {
+			return "", 0, fmt.Errorf("unsupported chunked cipher %d", data[len(chunkedMagicV2)])
+		}
+		return name, MaxChunkedHeaderSize, nil
+	default:
+		return "", 0, errors.New("data is not chunked")
+	}
+}
+
+// ForChunked returns a copy of this AES that encrypts with the cipher of the chunked
+// data that starts with header. It is used to append to chunked data, or decrypt a
+// range of it, which is encrypted with the cipher it was created with. The header
+// must be the first MaxChunkedHeaderSize bytes of the data, or all of it.
+func (a *AES) ForChunked(header []byte) (*AES, error) {
+	name, _, err := parseChunkedHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	c := *a
+	c.Cipher = name
+	return &c, nil
+}
+
+// EncryptChunks encrypts data using the key, split into chunks of ChunkSize that are
+// encrypted independently. The encrypted data is returned as a []byte.
+//
+// Chunked encrypted data can be appended to without decrypting it. The offset is
+// the length of the encrypted data that the result will be appended to, or 0 for
+// new data. If offset is 0 the result starts with the chunked header.
+//
+// Every chunk is written as the length of the encrypted chunk (4 bytes, big endian)
+// followed by the nonce and the encrypted chunk. The offset of a chunk in the
+// encrypted data is authenticated with it, chunks cannot be reordered. Since chunks
+// can always be appended, removing chunks from the end can not be detected.
+func (a *AES) EncryptChunks(data []byte, key []byte, offset int64) ([]byte, error) {
+	aead, err := newAEAD(a.currentCipher(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if offset == 0 {
+		out.Write(a.chunkedHeader())
+	}
+
+	for start := 0; start < len(data); start += ChunkSize {
+		chunk := data[start:min(start+ChunkSize, len(data))]
+
+		frame, err := sealChunk(aead, chunk, offset+int64(out.Len()))
+		if err != nil {
+			return nil, err
+		}
+		out.Write(frame)
+	}
+
+	return out.Bytes(), nil
+}
+
+// EncryptChunksTo reads r until EOF, encrypts it using the key, and writes it to w.
+// The result is the same as EncryptChunks with an offset of 0, but only one chunk
+// is held in memory at a time, see NewEncryptWriter. It returns the number of plain
+// text bytes read.
+func (a *AES) EncryptChunksTo(w io.Writer, r io.Reader, key []byte) (int64, error) {
+	ew, err := a.NewEncryptWriter(w, key)
+	if err != nil {
+		return 0, err
+	}
+
+	read, err := io.Copy(ew, r)
+	if err != nil {
+		return read, err
+	}
+
+	return read, ew.Close()
+}
+
+// ChunkedSize returns the size of n bytes of data after it is encrypted with
+// EncryptChunks as new data. Every chunk adds its length, nonce, and tag.
+func (a *AES) ChunkedSize(n int64) int64 {
+	chunks := (n + ChunkSize - 1) / ChunkSize
+	return int64(len(a.chunkedHeader())) + chunks*(4+int64(nonceSize(a.currentCipher()))+16) + n
+}
+
+// DecryptChunks decrypts data that was encrypted with EncryptChunks using the key.
+// The decrypted chunks are returned as a single []byte.
+//
+// The chunks are decrypted with the cipher recorded in the chunked header.
+func (a *AES) DecryptChunks(data []byte, key []byte) ([]byte, error) {
+	name, size, err := parseChunkedHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(name, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptChunks(aead, data[size:], int64(size))
+}
+
+// decryptChunks decrypts the chunks of data with aead. The data starts at offset
+// base of the chunked encrypted data.
+func decryptChunks(aead cipher.AEAD, data []byte, base int64) ([]byte, error) {
+	var out bytes.Buffer
+	pos := 0
+	for pos < len(data) {
+		at := base + int64(pos)
+		if len(data)-pos < 4 {
+			return nil, fmt.Errorf("chunk at %d: truncated length", at)
+		}
+		size := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		sealed := data[pos+4:]
+		if size < aead.NonceSize() || size > len(sealed) {
+			return nil, fmt.Errorf("chunk at %d: invalid length %d", at, size)
+		}
+		sealed = sealed[:size]
+
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		chunk, err := aead.Open(nil, nonce, ciphertext, chunkAD(at))
+		if err != nil {
+			return nil, fmt.Errorf("chunk at %d: %w", at, err)
+		}
+		out.Write(chunk)
+
+		pos += 4 + size
+	}
+
+	return out.Bytes(), nil
+}
+
+// chunkFrameSize returns the size of a full chunk of chunked encrypted data with
+// the cipher of this AES. It is the length, nonce, encrypted chunk, and tag.
+func (a *AES) chunkFrameSize() int64 {
+	return int64(4 + nonceSize(a.currentCipher()) + ChunkSize + 16)
+}
+
+// ChunkRange returns the range of chunked encrypted data that holds the plain text
+// bytes from start to end, inclusive. An end of -1 is the end of the data. It also
+// returns the offset in the plain text of the first chunk in the range. The data
+// must be encrypted with the cipher of this AES, see ForChunked.
+//
+// The range is only correct if every chunk but the last is full, which is the case
+// if the data was never appended to. If it is not correct, DecryptChunksAt fails to
+// decrypt the range.
+func (a *AES) ChunkRange(start int64, end int64) (int64, int64, int64) {
+	header := int64(len(a.chunkedHeader()))
+	first := start / ChunkSize
+	ctStart := header + first*a.chunkFrameSize()
+	ctEnd := int64(-1)
+	if end >= 0 {
+		ctEnd = header + (end/ChunkSize+1)*a.chunkFrameSize() - 1
+	}
+
+	return ctStart, ctEnd, first * ChunkSize
+}
+
+// DecryptChunksAt decrypts the chunks of data that starts at offset pos of chunked
+// encrypted data using the key, such as a range returned by ChunkRange. The data
+// must start at a chunk and end at the end of a chunk, and be encrypted with the
+// cipher of this AES.
+func (a *AES) DecryptChunksAt(data []byte, key []byte, pos int64) ([]byte, error) {
+	aead, err := newAEAD(a.currentCipher(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptChunks(aead, data, pos)
+}
+
+// ChunkedAES encrypts data with AES.EncryptChunks. It is used where an Encrypt
+// method is expected, such as when uploading a file that will be appended to. It
+// can also encrypt a file as it is read, so large files are uploaded without being
+// held in memory.
+//
+// Every file is encrypted with a data key of its own, which is wrapped with the key
+// that is passed in, and starts with the envelope header of the data key. See
+// DataKey.
+type ChunkedAES struct {
+	AES *AES
+	// DataKey is the data key of the file that EncryptAt encrypts the parts of, see
+	// AES.NewDataKey. Encrypt and EncryptStream encrypt every file with a new data
+	// key, they do not use it.
+	DataKey *DataKey
+}
+
+// Encrypt encrypts new data with AES.EncryptChunks, using a new data key wrapped
+// with the key.
+func (c *ChunkedAES) Encrypt(data []byte, key []byte) ([]byte, error) {
+	dk, err := c.AES.NewDataKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := c.AES.EncryptChunks(data, dk.Key, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dk.Header, encrypted...), nil
+}
+
+// EncryptStream encrypts new data read from r with AES.EncryptChunksTo, using a new
+// data key wrapped with the key, and writes it to w.
+func (c *ChunkedAES) EncryptStream(w io.Writer, r io.Reader, key []byte) (int64, error) {
+	dk, err := c.AES.NewDataKey(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := w.Write(dk.Header); err != nil {
+		return 0, err
+	}
+
+	return c.AES.EncryptChunksTo(w, r, dk.Key)
+}
+
+// EncryptAt encrypts the data that starts at offset off of the plain text of new
+// data with AES.EncryptChunks, using the DataKey. It is used to encrypt each part of
+// a file on its own, the parts are the same as if the whole file was encrypted. The
+// off must be a multiple of ChunkSize, and the data must be whole chunks unless it
+// is the end of the file. The key is not used, the DataKey is already wrapped.
+func (c *ChunkedAES) EncryptAt(data []byte, key []byte, off int64) ([]byte, error) {
+	if off%ChunkSize != 0 {
+		return nil, fmt.Errorf("offset %d is not a multiple of the chunk size", off)
+	}
+	if c.DataKey == nil {
+		return nil, errors.New("encrypting in parts requires a data key")
+	}
+
+	if off == 0 {
+		encrypted, err := c.AES.EncryptChunks(data, c.DataKey.Key, 0)
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]byte{}, c.DataKey.Header...), encrypted...), nil
+	}
+
+	return c.AES.EncryptChunks(data, c.DataKey.Key, c.AES.ChunkedSize(off))
+}
+
+// EncryptedSize returns the size of n bytes of new data after it is encrypted, the
+// envelope header and ChunkedSize.
+func (c *ChunkedAES) EncryptedSize(n int64) int64 {
+	header := c.AES.envelopeHeaderSize()
+	if c.DataKey != nil {
+		header = int64(len(c.DataKey.Header))
+	}
+
+	return header + c.AES.ChunkedSize(n)
+}
+
+// newGCM creates the AES-GCM cipher.AEAD of the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// chunkAD returns the additional data of the chunk at pos in the encrypted data.
+func chunkAD(pos int64) []byte {
+	ad := make([]byte, 8)
+	binary.BigEndian.PutUint64(ad, uint64(pos))
+	return ad
+}
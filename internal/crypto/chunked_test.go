@@ -0,0 +1,203 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func randomData(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEncryptChunks(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	sizes := []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 5}
+
+	for _, name := range []string{AESGCM, XChaCha20Poly1305} {
+		a := &AES{Cipher: name}
+		for _, n := range sizes {
+			data := randomData(t, n)
+
+			encrypted, err := a.EncryptChunks(data, key, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsChunked(encrypted) {
+				t.Errorf("%s %d: IsChunked() = false", name, n)
+			}
+			if int64(len(encrypted)) != a.ChunkedSize(int64(n)) {
+				t.Errorf("%s %d: len = %d, want ChunkedSize() = %d", name, n, len(encrypted), a.ChunkedSize(int64(n)))
+			}
+
+			// The cipher is read from the chunked header.
+			decrypted, err := (&AES{}).DecryptChunks(encrypted, key)
+			if err != nil {
+				t.Fatalf("%s %d: %v", name, n, err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Errorf("%s %d: DecryptChunks() did not return the data", name, n)
+			}
+
+			var streamed bytes.Buffer
+			if _, err := a.EncryptChunksTo(&streamed, bytes.NewReader(data), key); err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err = a.DecryptChunks(streamed.Bytes(), key)
+			if err != nil || !bytes.Equal(decrypted, data) {
+				t.Errorf("%s %d: DecryptChunks() of EncryptChunksTo() = %v, want the data", name, n, err)
+			}
+		}
+	}
+}
+
+func TestEncryptChunksAppend(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	a := &AES{}
+	first := randomData(t, ChunkSize+10)
+	second := randomData(t, 20)
+
+	encrypted, err := a.EncryptChunks(first, key, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appended, err := a.EncryptChunks(second, key, int64(len(encrypted)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsChunked(appended) {
+		t.Error("appended chunks start with the chunked header")
+	}
+
+	decrypted, err := a.DecryptChunks(append(encrypted, appended...), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, append(first, second...)) {
+		t.Error("DecryptChunks() did not return the appended data")
+	}
+}
+
+func TestDecryptChunksModified(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	a := &AES{}
+	data := randomData(t, 2*ChunkSize)
+	encrypted, err := a.EncryptChunks(data, key, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := len(chunkedMagic)
+	frame := int(a.chunkFrameSize())
+	swapped := append([]byte{}, encrypted[:header]...)
+	swapped = append(swapped, encrypted[header+frame:]...)
+	swapped = append(swapped, encrypted[header:header+frame]...)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"reordered", swapped},
+		{"truncated", encrypted[:len(encrypted)-1]},
+		{"wrong key", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, k := tt.data, key
+			if data == nil {
+				data, k = encrypted, bytes.Repeat([]byte{8}, 32)
+			}
+			if _, err := a.DecryptChunks(data, k); err == nil {
+				t.Error("DecryptChunks() succeeded")
+			}
+		})
+	}
+}
+
+func TestChunkRange(t *testing.T) {
+	tests := []struct {
+		cipher     string
+		start, end int64
+		wantStart  int64
+		wantEnd    int64
+		wantOffset int64
+	}{
+		// AES-GCM has a 5 byte header and 65568 byte chunks.
+		{AESGCM, 0, 0, 5, 65572, 0},
+		{AESGCM, 0, -1, 5, -1, 0},
+		{AESGCM, ChunkSize - 1, ChunkSize, 5, 131140, 0},
+		{AESGCM, 70000, 140000, 65573, 196708, ChunkSize},
+		{AESGCM, 2 * ChunkSize, -1, 131141, -1, 2 * ChunkSize},
+		// XChaCha20-Poly1305 has a 6 byte header and 65580 byte chunks.
+		{XChaCha20Poly1305, 0, 0, 6, 65585, 0},
+		{XChaCha20Poly1305, 70000, 140000, 65586, 196745, ChunkSize},
+	}
+
+	for _, tt := range tests {
+		a := &AES{Cipher: tt.cipher}
+		start, end, off := a.ChunkRange(tt.start, tt.end)
+		if start != tt.wantStart || end != tt.wantEnd || off != tt.wantOffset {
+			t.Errorf("%s ChunkRange(%d, %d) = %d, %d, %d, want %d, %d, %d", tt.cipher, tt.start, tt.end, start, end, off, tt.wantStart, tt.wantEnd, tt.wantOffset)
+		}
+	}
+}
+
+func TestDecryptChunksAt(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	data := randomData(t, 4*ChunkSize+100)
+	ranges := [][2]int64{
+		{0, 0},
+		{0, -1},
+		{ChunkSize - 1, ChunkSize},
+		{70000, 140000},
+		{4 * ChunkSize, -1},
+		{4*ChunkSize + 99, 4*ChunkSize + 99},
+	}
+
+	for _, name := range []string{AESGCM, XChaCha20Poly1305} {
+		encrypted, err := (&AES{Cipher: name}).EncryptChunks(data, key, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := (&AES{}).ForChunked(encrypted[:MaxChunkedHeaderSize])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Cipher != name {
+			t.Fatalf("ForChunked() cipher = %s, want %s", a.Cipher, name)
+		}
+
+		for _, r := range ranges {
+			ctStart, ctEnd, off := a.ChunkRange(r[0], r[1])
+			if ctEnd < 0 || ctEnd >= int64(len(encrypted)) {
+				ctEnd = int64(len(encrypted)) - 1
+			}
+
+			decrypted, err := a.DecryptChunksAt(encrypted[ctStart:ctEnd+1], key, ctStart)
+			if err != nil {
+				t.Fatalf("%s range %v: %v", name, r, err)
+			}
+
+			end := r[1]
+			if end < 0 {
+				end = int64(len(data)) - 1
+			}
+			if r[0] < off || end-off >= int64(len(decrypted)) {
+				t.Fatalf("%s range %v: decrypted %d bytes from %d", name, r, len(decrypted), off)
+			}
+			if !bytes.Equal(decrypted[r[0]-off:end-off+1], data[r[0]:end+1]) {
+				t.Errorf("%s range %v: DecryptChunksAt() did not return the range", name, r)
+			}
+
+			// The position of the chunks is authenticated.
+			if _, err := a.DecryptChunksAt(encrypted[ctStart:ctEnd+1], key, ctStart+1); err == nil {
+				t.Errorf("%s range %v: DecryptChunksAt() at the wrong position succeeded", name, r)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The names of the ciphers that an AES can encrypt data with.
+const (
+	// AESGCM is AES-256 with GCM. It is the default, and is fast on hardware with AES
+	// instructions.
+	AESGCM = "aes-256-gcm"
+	// XChaCha20Poly1305 is XChaCha20-Poly1305 with a 24 byte nonce. It is faster than
+	// AESGCM on hardware without AES instructions.
+	XChaCha20Poly1305 = "xchacha20-poly1305"
+)
+
+// cipherIDs are the identifiers of the cipher names in a cipher header.
+var cipherIDs = map[string]byte{AESGCM: 1, XChaCha20Poly1305: 2}
+
+// ValidateCipher checks if the cipher with the name is supported.
+func ValidateCipher(name string) error {
+	if _, ok := cipherIDs[name]; !ok {
+		return fmt.Errorf("unsupported cipher '%s', must be %s or %s", name, AESGCM, XChaCha20Poly1305)
+	}
+
+	return nil
+}
+
+// cipherName returns the name of the cipher with the identifier.
+func cipherName(id byte) (string, bool) {
+	for name, i := range cipherIDs {
+		if i == id {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// newAEAD creates the cipher.AEAD of the cipher with the name and the key.
+func newAEAD(name string, key []byte) (cipher.AEAD, error) {
+	switch name {
+	case AESGCM:
+		return newGCM(key)
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	default:
+		return nil, ValidateCipher(name)
+	}
+}
+
+// nonceSize returns the size of the nonce of the cipher with the name.
+func nonceSize(name string) int {
+	if name == XChaCha20Poly1305 {
+		return chacha20poly1305.NonceSizeX
+	}
+
+	return 12
+}
+
+// cipherMagic starts the header of data encrypted with Encrypt by a cipher other
+// than AESGCM. The last byte is the version of the format.
+var cipherMagic = []byte("clxe\x01")
+
+// cipherHeaderSize is the size of a cipher header, the magic and the cipher
+// identifier.
+const cipherHeaderSize = 5 + 1
+
+// cipherHeader returns the cipher header that records the cipher with the name.
+func cipherHeader(name string) []byte {
+	return append(append([]byte{}, cipherMagic...), cipherIDs[name])
+}
+
+// parseCipherHeader returns the name of the cipher recorded in the cipher header at
+// the start of data, and the data after it. If data does not start with a valid
+// header, it returns false.
+func parseCipherHeader(data []byte) (string, []byte, bool) {
+	if len(data) < cipherHeaderSize || string(data[:len(cipherMagic)]) != string(cipherMagic) {
+		return "", nil, false
+	}
+
+	name, ok := cipherName(data[len(cipherMagic)])
+	if !ok {
+		return "", nil, false
+	}
+
+	return name, data[cipherHeaderSize:], true
+}
@@ -0,0 +1,131 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// envelopeMagic starts the envelope header of data whose contents are encrypted with
+// a data key of their own. The contents follow the header, encrypted with
+// EncryptChunks or Encrypt using the data key.
+var envelopeMagic = []byte("CLXK1")
+
+// envelopePrefixSize is the size of the start of an envelope header, the magic and
+// the length of the wrapped data key as a uint16.
+const envelopePrefixSize = 5 + 2
+
+// MaxEnvelopeHeaderSize is the size of the largest envelope header, of a data key
+// wrapped with the cipher that adds the most to it. The envelope header of data is
+// within its first MaxEnvelopeHeaderSize bytes, see OpenDataKey.
+const MaxEnvelopeHeaderSize = envelopePrefixSize + cipherHeaderSize + chacha20poly1305.NonceSizeX + 32 + chacha20poly1305.Overhead
+
+// DataKey is a random key that encrypts the contents of a single file. It is stored
+// with the file, wrapped with the key of the account, so the key of the account can
+// change without every file being encrypted again.
+type DataKey struct {
+	// Key is the key that the contents of the file are encrypted with.
+	Key secret.Bytes
+	// Header is the envelope header that is written before the encrypted contents,
+	// the magic, the length of the wrapped key, and the key encrypted with the key
+	// of the account.
+	Header []byte
+}
+
+// NewDataKey generates a random DataKey and wraps it with the key of the account
+// using Encrypt.
+func (a *AES) NewDataKey(key []byte) (*DataKey, error) {
+	dataKey, err := a.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := a.Encrypt(dataKey, key)
+	if err != nil {
+		dataKey.Wipe()
+		return nil, fmt.Errorf("wrapping data key: %w", err)
+	}
+
+	header := append([]byte{}, envelopeMagic...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	return &DataKey{Key: dataKey, Header: append(header, wrapped...)}, nil
+}
+
+// envelopeHeaderSize returns the size of the envelope header of a DataKey created by
+// NewDataKey with the cipher of this AES.
+func (a *AES) envelopeHeaderSize() int64 {
+	wrapped := EncryptedSize(32)
+	if name := a.currentCipher(); name != AESGCM {
+		wrapped = int64(cipherHeaderSize + nonceSize(name) + 32 + chacha20poly1305.Overhead)
+	}
+
+	return envelopePrefixSize + wrapped
+}
+
+// IsEnvelope checks if the encrypted data starts with an envelope header.
+func IsEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, envelopeMagic)
+}
+
+// OpenDataKey returns the DataKey of the envelope header at the start of data,
+// unwrapped with the key of the account. The data must hold the whole header, its
+// first MaxEnvelopeHeaderSize bytes or all of it.
+func (a *AES) OpenDataKey(data []byte, key []byte) (*DataKey, error) {
+	if !IsEnvelope(data) {
+		return nil, errors.New("data has no envelope header")
+	}
+	if len(data) < envelopePrefixSize {
+		return nil, errors.New("truncated envelope header")
+	}
+
+	size := envelopePrefixSize + int(binary.BigEndian.Uint16(data[len(envelopeMagic):envelopePrefixSize]))
+	if len(data) < size {
+		return nil, errors.New("truncated envelope header")
+	}
+
+	dataKey, err := a.Decrypt(data[envelopePrefixSize:size], key)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %w", err)
+	}
+
+	return &DataKey{Key: dataKey, Header: data[:size:size]}, nil
+}
+
+// OpenEnvelope returns the encrypted contents of data after its envelope header, and
+// the data key they are encrypted with. Data without an envelope header was
+// encrypted before files had a data key of their own, directly with the key of the
+// account. It is returned as is, with the key.
+func (a *AES) OpenEnvelope(data []byte, key []byte) ([]byte, []byte, error) {
+	if !IsEnvelope(data) {
+		return data, key, nil
+	}
+
+	dk, err := a.OpenDataKey(data, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data[len(dk.Header):], dk.Key, nil
+}
+
+// SealEnvelope encrypts data with Encrypt using a new data key, which is wrapped
+// with the key of the account. The encrypted data starts with the envelope header.
+// The data key is wiped after use.
+func (a *AES) SealEnvelope(data []byte, key []byte) ([]byte, error) {
+	dk, err := a.NewDataKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer dk.Key.Wipe()
+
+	encrypted, err := a.Encrypt(data, dk.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dk.Header, encrypted...), nil
+}
@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// The names of the key derivation functions that a KDF can use.
+const (
+	// Argon2id is the memory hard Argon2id function (RFC 9106). It is the default.
+	Argon2id = "argon2id"
+	// PBKDF2 is PBKDF2 with HMAC-SHA256. Data encrypted before the KDF was recorded
+	// with the ciphertext used 4096 iterations of it.
+	PBKDF2 = "pbkdf2-sha256"
+)
+
+// kdfIDs are the identifiers of the KDF names in a password header.
+var kdfIDs = map[string]byte{PBKDF2: 1, Argon2id: 2}
+
+// DefaultKDF is the KDF that EncryptWithPassword derives keys with, 1 pass over 64
+// MiB with 4 threads. Every command derives a few keys, such as for the API token and
+// the private key, so a single pass keeps the commands responsive.
+var DefaultKDF = KDF{Name: Argon2id, Time: 1, Memory: 64 * 1024, Threads: 4}
+
+// legacyKDF is the KDF of data encrypted with a password before the KDF was recorded
+// alongside the ciphertext.
+var legacyKDF = KDF{Name: PBKDF2, Time: 4096}
+
+// The largest parameters of a KDF. The parameters of a KDF are read from the header
+// of the data it decrypts, before anything is authenticated, so a crafted header
+// must not make a key take all the memory or forever to derive.
+const (
+	// MaxArgon2Memory is the most memory of Argon2id in KiB, 1 GiB. It is 16 times
+	// the memory of DefaultKDF, so a profile can be hardened on a machine with the
+	// memory to spare, but a crafted header cannot make a key take all of it.
+	MaxArgon2Memory = 1024 * 1024
+	// MaxArgon2Time is the most passes of Argon2id.
+	MaxArgon2Time = 16
+	// MaxArgon2Threads is the most threads of Argon2id.
+	MaxArgon2Threads = 64
+	// MaxPBKDF2Iterations is the most iterations of PBKDF2.
+	MaxPBKDF2Iterations = 10000000
+)
+
+// DefaultPBKDF2 is the KDF of PBKDF2 with the iterations recommended by OWASP for
+// HMAC-SHA256.
+var DefaultPBKDF2 = KDF{Name: PBKDF2, Time: 600000}
+
+// KDF is a key derivation function and its parameters. It derives the AES key from
+// a password and salt.
+type KDF struct {
+	// Name is Argon2id or PBKDF2.
+	Name string `json:"name"`
+	// Time is the number of passes of Argon2id, or the iterations of PBKDF2.
+	Time uint32 `json:"time"`
+	// Memory is the memory of Argon2id in KiB. It is not used by PBKDF2.
+	Memory uint32 `json:"memory,omitempty"`
+	// Threads is the parallelism of Argon2id. It is not used by PBKDF2.
+	Threads uint8 `json:"threads,omitempty"`
+}
+
+// NewKDF returns the KDF with the name and its default parameters, DefaultKDF for
+// Argon2id and DefaultPBKDF2 for PBKDF2.
+func NewKDF(name string) (KDF, error) {
+	switch name {
+	case Argon2id:
+		return DefaultKDF, nil
+	case PBKDF2:
+		return DefaultPBKDF2, nil
+	default:
+		return KDF{}, fmt.Errorf("unsupported kdf '%s', must be %s or %s", name, Argon2id, PBKDF2)
+	}
+}
+
+// Validate checks if the name of this KDF is supported and its parameters can
+// derive a key, and are not above the largest parameters, see MaxArgon2Memory.
+func (k KDF) Validate() error {
+	switch k.Name {
+	case Argon2id:
+		if k.Time < 1 || k.Threads < 1 {
+			return errors.New("argon2id time and threads must be at least 1")
+		}
+		if k.Memory < 8*uint32(k.Threads) {
+			return errors.New("argon2id memory must be at least 8 KiB per thread")
+		}
+		if k.Time > MaxArgon2Time || k.Threads > MaxArgon2Threads || k.Memory > MaxArgon2Memory {
+			return fmt.Errorf("argon2id time, memory, and threads can be at most %d, %d KiB, and %d", MaxArgon2Time, MaxArgon2Memory, MaxArgon2Threads)
+		}
+	case PBKDF2:
+		if k.Time < 1 || k.Time > MaxPBKDF2Iterations {
+			return fmt.Errorf("pbkdf2 iterations must be between 1 and %d", MaxPBKDF2Iterations)
+		}
+		if k.Memory != 0 || k.Threads != 0 {
+			return errors.New("pbkdf2 has no memory or threads")
+		}
+	default:
+		return fmt.Errorf("unsupported kdf '%s'", k.Name)
+	}
+
+	return nil
+}
+
+// String returns the name and parameters of this KDF, such as
+// "argon2id t=1 m=65536 p=4" or "pbkdf2-sha256 i=600000".
+func (k KDF) String() string {
+	if k.Name == Argon2id {
+		return fmt.Sprintf("%s t=%d m=%d p=%d", k.Name, k.Time, k.Memory, k.Threads)
+	}
+
+	return fmt.Sprintf("%s i=%d", k.Name, k.Time)
+}
+
+// key derives a 32 byte key from the password and salt.
+func (k KDF) key(password []byte, salt []byte) []byte {
+	if k.Name == Argon2id {
+		return argon2.IDKey(password, salt, k.Time, k.Memory, k.Threads, 32)
+	}
+
+	return pbkdf2.Key(password, salt, int(k.Time), 32, sha256.New)
+}
+
+// passwordMagic starts the header of data encrypted with EncryptWithPassword.
+var passwordMagic = []byte("clx\x01")
+
+// headerSize is the size of a password header, the magic, the KDF identifier, the
+// time and memory as uint32, and the threads.
+const headerSize = 4 + 1 + 4 + 4 + 1
+
+// header returns the password header that records this KDF.
+func (k KDF) header() []byte {
+	h := make([]byte, 0, headerSize)
+	h = append(h, passwordMagic...)
+	h = append(h, kdfIDs[k.Name])
+	h = binary.BigEndian.AppendUint32(h, k.Time)
+	h = binary.BigEndian.AppendUint32(h, k.Memory)
+	return append(h, k.Threads)
+}
+
+// parseHeader returns the KDF recorded in the password header at the start of data,
+// and the data after it. If data does not start with a valid header, it returns
+// false.
+func parseHeader(data []byte) (KDF, []byte, bool) {
+	if len(data) < headerSize || string(data[:len(passwordMagic)]) != string(passwordMagic) {
+		return KDF{}, nil, false
+	}
+
+	h := data[len(passwordMagic):headerSize]
+	k := KDF{
+		Time:    binary.BigEndian.Uint32(h[1:5]),
+		Memory:  binary.BigEndian.Uint32(h[5:9]),
+		Threads: h[9],
+	}
+	for name, id := range kdfIDs {
+		if id == h[0] {
+			k.Name = name
+		}
+	}
+	if k.Validate() != nil {
+		return KDF{}, nil, false
+	}
+
+	return k, data[headerSize:], true
+}
+
+// ParsePasswordHeader returns the KDF and the name of the cipher of data encrypted
+// with EncryptWithPassword. If data does not start with a password header, such as
+// data encrypted before the KDF was recorded, it returns false.
+func ParsePasswordHeader(data []byte) (KDF, string, bool) {
+	k, rest, ok := parseHeader(data)
+	if !ok || len(rest) < 16 {
+		return KDF{}, "", false
+	}
+
+	name := AESGCM
+	if n, _, ok := parseCipherHeader(rest[16:]); ok {
+		name = n
+	}
+
+	return k, name, true
+}
@@ -0,0 +1,113 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKDFHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		kdf  KDF
+		want []byte
+	}{
+		{
+			name: "argon2id",
+			kdf:  DefaultKDF,
+			want: []byte("clx\x01\x02\x00\x00\x00\x01\x00\x01\x00\x00\x04"),
+		},
+		{
+			name: "pbkdf2",
+			kdf:  DefaultPBKDF2,
+			want: []byte("clx\x01\x01\x00\x09\x27\xc0\x00\x00\x00\x00\x00"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.kdf.header()
+			if !bytes.Equal(h, tt.want) {
+				t.Fatalf("header() = %x, want %x", h, tt.want)
+			}
+
+			k, rest, ok := parseHeader(append(h, "rest"...))
+			if !ok {
+				t.Fatal("parseHeader() did not parse the header")
+			}
+			if k != tt.kdf {
+				t.Errorf("parseHeader() KDF = %v, want %v", k, tt.kdf)
+			}
+			if string(rest) != "rest" {
+				t.Errorf("parseHeader() rest = %q, want %q", rest, "rest")
+			}
+		})
+	}
+}
+
+func TestParseHeaderInvalid(t *testing.T) {
+	header := func(k KDF) []byte {
+		h := k.header()
+		if k.Name == "" {
+			h[4] = 9
+		}
+		return h
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short", DefaultKDF.header()[:headerSize-1]},
+		{"magic", append([]byte("clx\x02"), DefaultKDF.header()[4:]...)},
+		{"unknown kdf", header(KDF{Time: 1})},
+		{"argon2id no time", header(KDF{Name: Argon2id, Memory: 64, Threads: 1})},
+		{"argon2id low memory", header(KDF{Name: Argon2id, Time: 1, Memory: 15, Threads: 2})},
+		{"argon2id max time", header(KDF{Name: Argon2id, Time: MaxArgon2Time + 1, Memory: 64, Threads: 1})},
+		{"argon2id max memory", header(KDF{Name: Argon2id, Time: 1, Memory: MaxArgon2Memory + 1, Threads: 1})},
+		{"argon2id over 1 GiB", header(KDF{Name: Argon2id, Time: 1, Memory: 1024*1024 + 1, Threads: 1})},
+		{"argon2id 4 GiB", header(KDF{Name: Argon2id, Time: 1, Memory: 4 * 1024 * 1024, Threads: 1})},
+		{"argon2id max threads", header(KDF{Name: Argon2id, Time: 1, Memory: 1024, Threads: MaxArgon2Threads + 1})},
+		{"pbkdf2 no iterations", header(KDF{Name: PBKDF2})},
+		{"pbkdf2 max iterations", header(KDF{Name: PBKDF2, Time: MaxPBKDF2Iterations + 1})},
+		{"pbkdf2 memory", header(KDF{Name: PBKDF2, Time: 1, Memory: 64})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if k, _, ok := parseHeader(tt.data); ok {
+				t.Errorf("parseHeader(%x) = %v, want no header", tt.data, k)
+			}
+		})
+	}
+}
+
+func TestKDFValidate(t *testing.T) {
+	valid := []KDF{
+		DefaultKDF,
+		DefaultPBKDF2,
+		legacyKDF,
+		{Name: Argon2id, Time: MaxArgon2Time, Memory: MaxArgon2Memory, Threads: MaxArgon2Threads},
+		{Name: PBKDF2, Time: MaxPBKDF2Iterations},
+	}
+	for _, k := range valid {
+		if err := k.Validate(); err != nil {
+			t.Errorf("Validate() of %v = %v, want nil", k, err)
+		}
+	}
+
+	invalid := []KDF{
+		{},
+		{Name: "scrypt", Time: 1},
+		{Name: Argon2id, Time: MaxArgon2Time + 1, Memory: 64, Threads: 1},
+		{Name: Argon2id, Time: 1, Memory: MaxArgon2Memory + 1, Threads: 1},
+		{Name: Argon2id, Time: 1, Memory: 1024*1024 + 1, Threads: 1},
+		{Name: PBKDF2, Time: MaxPBKDF2Iterations + 1},
+		{Name: PBKDF2, Time: 1, Threads: 1},
+	}
+	for _, k := range invalid {
+		if err := k.Validate(); err == nil {
+			t.Errorf("Validate() of %v = nil, want an error", k)
+		}
+	}
+}
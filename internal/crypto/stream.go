@@ -0,0 +1,270 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// maxChunkFrame is the largest encrypted chunk that a chunk reader accepts, a full
+// chunk with the largest nonce and its tag. It keeps a corrupted length from
+// allocating more than a chunk.
+const maxChunkFrame = chacha20poly1305.NonceSizeX + ChunkSize + chacha20poly1305.Overhead
+
+// chunkWriter is the io.WriteCloser of NewEncryptWriter.
+type chunkWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+	buf  []byte
+	pos  int64
+	err  error
+}
+
+// NewEncryptWriter returns an io.WriteCloser that encrypts the data written to it
+// using the key, and writes it to w. The encrypted data is the same as
+// EncryptChunks of all the data with an offset of 0, but only one chunk is held in
+// memory at a time.
+//
+// The chunked header is written to w before NewEncryptWriter returns. Close must be
+// called to write the last chunk, it does not close w. After a write to w fails,
+// every call returns the error.
+func (a *AES) NewEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newAEAD(a.currentCipher(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := a.chunkedHeader()
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &chunkWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, ChunkSize),
+		pos:  int64(len(header)),
+	}, nil
+}
+
+// Write encrypts and writes every chunk of p that is filled, the rest is kept until
+// the next Write or Close.
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		take := min(ChunkSize-len(c.buf), len(p))
+		c.buf = append(c.buf, p[:take]...)
+		p = p[take:]
+		n += take
+
+		if len(c.buf) == ChunkSize {
+			if err := c.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Close encrypts and writes the data that did not fill a chunk.
+func (c *chunkWriter) Close() error {
+	if c.err != nil {
+		return c.err
+	}
+	if len(c.buf) == 0 {
+		return nil
+	}
+
+	return c.flush()
+}
+
+// flush encrypts the buffered chunk and writes it to w.
+func (c *chunkWriter) flush() error {
+	frame, err := sealChunk(c.aead, c.buf, c.pos)
+	if err != nil {
+		c.err = err
+		return err
+	}
+	if _, err := c.w.Write(frame); err != nil {
+		c.err = err
+		return err
+	}
+
+	c.pos += int64(len(frame))
+	c.buf = c.buf[:0]
+	return nil
+}
+
+// sealChunk encrypts the chunk at pos of the encrypted data with a new nonce. It
+// returns the frame of the chunk, its length followed by the nonce and the
+// encrypted chunk.
+func sealChunk(aead cipher.AEAD, chunk []byte, pos int64) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := aead.Seal(nonce, nonce, chunk, chunkAD(pos))
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(sealed)), uint32(len(sealed)))
+	return append(frame, sealed...), nil
+}
+
+// encryptReader is the io.Reader of NewEncryptReader.
+type encryptReader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	chunk []byte
+	out   bytes.Buffer
+	pos   int64
+	eof   bool
+}
+
+// NewEncryptReader returns an io.Reader of the data read from r encrypted using the
+// key. The encrypted data is the same as NewEncryptWriter writes, r is read one
+// chunk at a time as the encrypted data is read. It is used where the encrypted
+// data is read, such as the body of a request.
+func (a *AES) NewEncryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newAEAD(a.currentCipher(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := a.chunkedHeader()
+	e := &encryptReader{r: r, aead: aead, chunk: make([]byte, ChunkSize), pos: int64(len(header))}
+	e.out.Write(header)
+	return e, nil
+}
+
+// Read reads the encrypted data, encrypting the next chunk of r when the previous
+// chunk was read.
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for e.out.Len() == 0 {
+		if e.eof {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(e.r, e.chunk)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			e.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			continue
+		}
+
+		frame, err := sealChunk(e.aead, e.chunk[:n], e.pos)
+		if err != nil {
+			return 0, err
+		}
+		e.out.Write(frame)
+		e.pos += int64(len(frame))
+	}
+
+	return e.out.Read(p)
+}
+
+// decryptReader is the io.Reader of NewDecryptReader.
+type decryptReader struct {
+	r    io.Reader
+	aead cipher.AEAD
+	out  []byte
+	pos  int64
+	err  error
+}
+
+// NewDecryptReader returns an io.Reader of the chunked encrypted data read from r
+// decrypted using the key. The data is read one chunk at a time, and every chunk is
+// authenticated before it is returned.
+//
+// The chunked header is read from r before NewDecryptReader returns, if the data is
+// not chunked an error is returned. The chunks are decrypted with the cipher it
+// records. As with DecryptChunks, chunks removed from the end of the data can not
+// be detected.
+func (a *AES) NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, len(chunkedMagic), MaxChunkedHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil || !IsChunked(header) {
+		return nil, errors.New("data is not chunked")
+	}
+	if bytes.Equal(header, chunkedMagicV2) {
+		header = header[:MaxChunkedHeaderSize]
+		if _, err := io.ReadFull(r, header[len(chunkedMagicV2):]); err != nil {
+			return nil, errors.New("truncated chunked header")
+		}
+	}
+
+	name, size, err := parseChunkedHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(name, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{r: r, aead: aead, pos: int64(size)}, nil
+}
+
+// Read reads the decrypted data, decrypting the next chunk of r when the previous
+// chunk was read. An error decrypting a chunk is returned by every later call.
+func (d *decryptReader) Read(p []byte) (int, error) {
+	if len(d.out) == 0 && d.err == nil {
+		d.out, d.err = d.next()
+	}
+	if len(d.out) == 0 {
+		return 0, d.err
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// next reads and decrypts the next chunk of r. It returns io.EOF at the end of the
+// data.
+func (d *decryptReader) next() ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("chunk at %d: truncated length", d.pos)
+		}
+		return nil, err
+	}
+
+	n := int(binary.BigEndian.Uint32(size[:]))
+	if n < d.aead.NonceSize() || n > maxChunkFrame {
+		return nil, fmt.Errorf("chunk at %d: invalid length %d", d.pos, n)
+	}
+
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("chunk at %d: truncated chunk", d.pos)
+		}
+		return nil, err
+	}
+
+	nonce, ciphertext := sealed[:d.aead.NonceSize()], sealed[d.aead.NonceSize():]
+	chunk, err := d.aead.Open(nil, nonce, ciphertext, chunkAD(d.pos))
+	if err != nil {
+		return nil, fmt.Errorf("chunk at %d: %w", d.pos, err)
+	}
+
+	d.pos += int64(len(size) + n)
+	return chunk, nil
+}
@@ -0,0 +1,96 @@
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Name is the name of a hook. The hook script is the executable in the hooks
+// directory with this name.
+type Name string
+
+const (
+	// PreUpload runs before files are uploaded. The local file paths are passed as
+	// arguments. If it exits with a non-zero status the upload is aborted.
+	PreUpload Name = "pre-upload"
+	// PostUpload runs after files are uploaded. The local file paths are passed as
+	// arguments and the upload result is written to standard input as JSON.
+	PostUpload Name = "post-upload"
+	// PreDownload runs before files are downloaded. The remote targets are passed as
+	// arguments. If it exits with a non-zero status the download is aborted.
+	PreDownload Name = "pre-download"
+	// PostDownload runs after files are downloaded. The local file paths are passed as
+	// arguments and the download result is written to standard input as JSON.
+	PostDownload Name = "post-download"
+	// PostSync runs after a sync, push or pull completes. The local and remote
+	// directory are passed as arguments and the summary is written to standard input
+	// as JSON.
+	PostSync Name = "post-sync"
+)
+
+// Runner runs the hook scripts stored in a directory, similar to git hooks. A hook
+// that does not exist in the directory is skipped.
+type Runner struct {
+	// The directory containing the hook scripts.
+	Dir string
+}
+
+// Exists checks if the hook script exists and is executable.
+func (r *Runner) Exists(name Name) bool {
+	fi, err := os.Stat(r.path(name))
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+
+	return fi.Mode().Perm()&0111 != 0
+}
+
+// Run runs the hook script with args. If the hook does not exist, Run does nothing
+// and returns nil.
+//
+// If result is not nil, it is marshalled to JSON and written to the standard input
+// of the hook. The output of the hook is written to the standard output and error
+// of the current process. The CLOX_HOOK environment variable is set to the hook
+// name.
+//
+// If the hook exits with a non-zero status, an error is returned. It is up to the
+// caller to decide if the operation should be aborted.
+func (r *Runner) Run(name Name, args []string, result any) error {
+	if !r.Exists(name) {
+		return nil
+	}
+
+	var stdin bytes.Buffer
+	if result != nil {
+		if err := json.NewEncoder(&stdin).Encode(result); err != nil {
+			return fmt.Errorf("marshalling %s hook input: %w", name, err)
+		}
+	}
+
+	cmd := exec.Command(r.path(name), args...)
+	cmd.Stdin = &stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), fmt.Sprintf("CLOX_HOOK=%s", name))
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("%s hook exited with status %d", name, exitErr.ExitCode())
+		}
+
+		return fmt.Errorf("running %s hook: %w", name, err)
+	}
+
+	return nil
+}
+
+// path returns the path to the hook script.
+func (r *Runner) path(name Name) string {
+	return filepath.Join(r.Dir, string(name))
+}
@@ -0,0 +1,106 @@
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Rule checks if a path is skipped. The path is relative to the directory that is
+// transferred, separated by slashes, and isDir is set if it is a directory.
+type Rule interface {
+	Match(rel string, isDir bool) bool
+}
+
+// Rules is a Rule that skips a path if any of its rules skips it. A nil rule in
+// Rules is ignored.
+type Rules []Rule
+
+// Match checks if any of the rules skips the path.
+func (r Rules) Match(rel string, isDir bool) bool {
+	for _, rule := range r {
+		if rule != nil && rule.Match(rel, isDir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Filter selects the files of a transfer with the glob patterns of the include
+// (--include) and exclude (--exclude) flags. The patterns have the same format as
+// the patterns of an ignore file, without comments and '!'.
+//
+// If Filter has include patterns, a file is skipped unless it, or any directory
+// above it, matches one of them. A file is skipped if it, or any directory above it, matches an exclude pattern,
+// even if it matches an include pattern. Directories are only checked against the
+// exclude patterns.
+//
+// A nil *Filter skips nothing.
+type Filter struct {
+	include []pattern
+	exclude []pattern
+}
+
+// NewFilter creates and returns the Filter of the include and exclude patterns. If
+// a pattern is malformed, it returns an error.
+func NewFilter(include []string, exclude []string) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if f.include, err = parseGlobs(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = parseGlobs(exclude); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// parseGlobs parses the glob of each pattern, checking it is well formed.
+func parseGlobs(globs []string) ([]pattern, error) {
+	patterns := []pattern{}
+	for _, g := range globs {
+		p, ok := newPattern(g)
+		if !ok {
+			return nil, fmt.Errorf("invalid pattern '%s': pattern is empty", g)
+		}
+		for _, s := range p.segments {
+			if _, err := path.Match(s, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", g, err)
+			}
+		}
+		patterns = append(patterns, p)
+	}
+
+	return patterns, nil
+}
+
+// Match checks if the path is skipped by this Filter.
+func (f *Filter) Match(rel string, isDir bool) bool {
+	if f == nil {
+		return false
+	}
+
+	segments := strings.Split(path.Clean(rel), "/")
+	for i := 1; i <= len(segments); i++ {
+		for _, p := range f.exclude {
+			if p.match(segments[:i], i < len(segments) || isDir) {
+				return true
+			}
+		}
+	}
+	if isDir || len(f.include) == 0 {
+		return false
+	}
+
+	for i := 1; i <= len(segments); i++ {
+		for _, p := range f.include {
+			if p.match(segments[:i], i < len(segments)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
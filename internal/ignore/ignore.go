@@ -0,0 +1,198 @@
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// File is the name of the ignore file of a local directory.
+const File = ".cloxignore"
+
+// pattern is a single line of an ignore file.
+type pattern struct {
+	// segments are the parts of the pattern separated by slashes.
+	segments []string
+	// negate is set for a pattern that starts with '!', it re-includes a path
+	// that an earlier pattern ignored.
+	negate bool
+	// dirOnly is set for a pattern that ends with '/', it only matches
+	// directories.
+	dirOnly bool
+	// anchored is set for a pattern with a '/' before its end, it only matches
+	// paths relative to the directory of the ignore file. Any other pattern
+	// matches a name at any level.
+	anchored bool
+}
+
+// Matcher matches paths against the patterns of an ignore file. The patterns are
+// in the format of a .gitignore file:
+//
+//   - A blank line, or a line that starts with '#', is skipped.
+//   - A '*' matches anything except a '/', a '?' matches any one character
+//     except a '/', and '[a-z]' matches one character in the range.
+//   - A '**' matches any number of directories, as in '**/logs', 'logs/**', or
+//     'a/**/b'.
+//   - A pattern that ends with a '/' only matches directories.
+//   - A pattern with a '/' at the start or in the middle is relative to the
+//     directory of the ignore file. Any other pattern matches at any level.
+//   - A pattern that starts with '!' includes a path again that an earlier
+//     pattern ignored. A file cannot be included again if a directory above it
+//     is ignored.
+//   - A '\' before a leading '#' or '!' matches it literally.
+//
+// A nil *Matcher ignores nothing.
+type Matcher struct {
+	patterns []pattern
+}
+
+// Parse reads the lines of an ignore file from r and returns the Matcher of its
+// patterns.
+func Parse(r io.Reader) (*Matcher, error) {
+	m := &Matcher{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, ok := parseLine(scanner.Text()); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Load reads the ignore file in the local directory dir. If the directory has no
+// ignore file, it returns a Matcher that ignores nothing.
+func Load(dir string) (*Matcher, error) {
+	f, err := os.Open(filepath.Join(dir, File))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Matcher{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// parseLine parses a line of an ignore file. If the line has no pattern, it
+// returns false.
+func parseLine(line string) (pattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+
+	var p pattern
+	switch {
+	case strings.HasPrefix(line, "!"):
+		p.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\#`), strings.HasPrefix(line, `\!`):
+		line = line[1:]
+	}
+
+	negate := p.negate
+	p, ok := newPattern(line)
+	p.negate = negate
+	return p, ok
+}
+
+// newPattern parses the glob of a pattern, without a leading '!'. If the glob is
+// empty, it returns false.
+func newPattern(glob string) (pattern, bool) {
+	var p pattern
+	if strings.HasSuffix(glob, "/") {
+		p.dirOnly = true
+		glob = strings.TrimRight(glob, "/")
+	}
+	if strings.Contains(glob, "/") {
+		p.anchored = true
+		glob = strings.TrimLeft(glob, "/")
+	}
+	if glob == "" {
+		return pattern{}, false
+	}
+
+	p.segments = strings.Split(glob, "/")
+	return p, true
+}
+
+// Match checks if the path is ignored. The path is relative to the directory of
+// the ignore file, separated by slashes, and isDir is set if it is a directory. A
+// path is ignored if it, or any directory above it, matches the patterns.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	if m == nil || len(m.patterns) == 0 {
+		return false
+	}
+
+	segments := strings.Split(path.Clean(rel), "/")
+	for i := 1; i <= len(segments); i++ {
+		if m.match(segments[:i], i < len(segments) || isDir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match checks if the path of the segments is ignored by the patterns, without
+// checking the directories above it. The last pattern that matches decides.
+func (m *Matcher) match(segments []string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.match(segments, isDir) {
+			ignored = !p.negate
+		}
+	}
+
+	return ignored
+}
+
+// match checks if the path of the segments matches this pattern, without checking
+// the directories above it.
+func (p pattern) match(segments []string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.anchored {
+		return matchSegments(p.segments, segments)
+	}
+	return matchSegments(p.segments, segments[len(segments)-1:])
+}
+
+// matchSegments checks if the segments of a path match the segments of a pattern.
+func matchSegments(pat []string, segments []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			// A trailing '**' matches everything below, but not the directory
+			// itself.
+			if len(pat) == 1 {
+				return len(segments) > 0
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pat[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segments[0]); !ok {
+			return false
+		}
+		pat, segments = pat[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
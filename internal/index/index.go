@@ -0,0 +1,179 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/securefile"
+)
+
+// StaleAfter is the age after which an Index is considered stale and should be
+// rebuilt.
+const StaleAfter = 24 * time.Hour
+
+// ErrNoIndex is the error when the index file does not exist.
+var ErrNoIndex = errors.New("index not built")
+
+// Entry is a directory or file in the Index.
+type Entry struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Dir       bool      `json:"dir"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Index is a local copy of the names, paths, and IDs of the remote directory tree.
+// It is used to answer searches without calling the API.
+//
+// The Index is stored encrypted with the users password, the remote file names
+// never touch the disk in plain text.
+type Index struct {
+	// The time the Index was last rebuilt from the server.
+	BuiltAt time.Time `json:"built_at"`
+	// The time the Index was last changed, by a rebuild or an incremental update.
+	UpdatedAt time.Time `json:"updated_at"`
+	// The entries of the Index, keyed by ID.
+	Entries map[string]Entry `json:"entries"`
+}
+
+// New creates an empty *Index.
+func New() *Index {
+	return &Index{Entries: map[string]Entry{}}
+}
+
+// Load reads the index file at path and decrypts it with the password. If the file
+// does not exist, it returns ErrNoIndex.
+func Load(path string, aes *crypto.AES, password []byte) (*Index, error) {
+	idx := New()
+	if err := securefile.ReadJSON(path, aes, password, idx); err != nil {
+		if errors.Is(err, securefile.ErrNotExist) {
+			return nil, ErrNoIndex
+		}
+
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// Save encrypts this Index with the password and writes it to path.
+func (idx *Index) Save(path string, aes *crypto.AES, password []byte) error {
+	return securefile.WriteJSON(path, aes, password, idx)
+}
+
+// Age returns how long ago this Index was rebuilt.
+func (idx *Index) Age() time.Duration {
+	return time.Since(idx.BuiltAt)
+}
+
+// Stale checks if this Index is older than StaleAfter.
+func (idx *Index) Stale() bool {
+	return idx.Age() > StaleAfter
+}
+
+// Rebuild replaces the entries of this Index with the remote directory tree below
+// root. Every directory is listed by calling the API.
+func (idx *Index) Rebuild(ctx context.Context, dirs *api.DirService, root api.Location) error {
+	entries := map[string]Entry{}
+	err := dirs.Walk(ctx, root, func(listing *api.DirListing, depth int) error {
+		for _, d := range listing.Dirs {
+			entries[d.ID] = DirEntry(d)
+		}
+		for _, f := range listing.Files {
+			entries[f.ID] = FileEntry(f)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	idx.Entries = entries
+	idx.BuiltAt = time.Now()
+	idx.UpdatedAt = idx.BuiltAt
+	return nil
+}
+
+// Add adds the entries to this Index, replacing any entry with the same ID. This is
+// used to incrementally update the Index after a command changes the remote tree.
+func (idx *Index) Add(entries ...Entry) {
+	for _, e := range entries {
+		idx.Entries[e.ID] = e
+	}
+	idx.UpdatedAt = time.Now()
+}
+
+// Remove removes the entries with the IDs from this Index.
+func (idx *Index) Remove(ids ...string) {
+	for _, id := range ids {
+		delete(idx.Entries, id)
+	}
+	idx.UpdatedAt = time.Now()
+}
+
+// Move changes the path of the entry at oldPath, and of every entry below it, to be
+// at newPath. This is used to incrementally update the Index after a directory or
+// file is moved or renamed on the server.
+func (idx *Index) Move(oldPath string, newPath string) {
+	for id, e := range idx.Entries {
+		switch {
+		case e.Path == oldPath:
+			e.Path = newPath
+			e.Name = path.Base(newPath)
+		case strings.HasPrefix(e.Path, oldPath+"/"):
+			e.Path = newPath + strings.TrimPrefix(e.Path, oldPath)
+		default:
+			continue
+		}
+		idx.Entries[id] = e
+	}
+	idx.UpdatedAt = time.Now()
+}
+
+// Find returns the entries with a name that matches pattern, sorted by path. If the
+// pattern contains a glob character ('*', '?', or '['), the name must match the
+// glob. Otherwise the name must contain the pattern. Matching is case-insensitive.
+func (idx *Index) Find(pattern string) []Entry {
+	pattern = strings.ToLower(pattern)
+	glob := strings.ContainsAny(pattern, "*?[")
+
+	matches := []Entry{}
+	for _, e := range idx.Entries {
+		name := strings.ToLower(e.Name)
+		if glob {
+			if ok, _ := path.Match(pattern, name); !ok {
+				continue
+			}
+		} else if !strings.Contains(name, pattern) {
+			continue
+		}
+
+		matches = append(matches, e)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Path < matches[j].Path })
+
+	return matches
+}
+
+// DirEntry returns the Entry of a directory.
+func DirEntry(d api.Dir) Entry {
+	return Entry{ID: d.ID, Name: d.DirName, Path: d.DirPath, Dir: true, UpdatedAt: d.UpdatedAt}
+}
+
+// FileEntry returns the Entry of a file.
+func FileEntry(f api.File) Entry {
+	return Entry{ID: f.ID, Name: f.Name, Path: f.Path, Size: f.Size, UpdatedAt: f.UpdatedAt}
+}
+
+// UploadEntry returns the Entry of an uploaded file.
+func UploadEntry(u api.UploadFileResponse) Entry {
+	return Entry{ID: u.ID, Name: u.Name, Path: u.Path, Size: u.Size, UpdatedAt: u.UploadedAt}
+}
@@ -0,0 +1,80 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// service is the name the secrets are stored under in the OS keyring. It is not the
+// service of the biometric package, a secret in the keyring is not gated behind OS
+// user authentication.
+const service = "clox-cli-keyring"
+
+var (
+	// ErrUnsupported is the error when the platform has no OS keyring.
+	ErrUnsupported = errors.New("the OS keyring is not supported on this platform")
+	// ErrNotFound is the error when no secret is stored for the account.
+	ErrNotFound = errors.New("secret not found in the OS keyring")
+)
+
+// Keyring stores secrets in the OS keyring, the macOS Keychain, the Windows
+// Credential Manager, or the Secret Service of libsecret. The secrets are protected
+// by the OS account of the user, they are released without a prompt while the user
+// is logged in.
+//
+// The secret of each account is stored separately. Keyring should be created by
+// calling New, which returns the Keyring of the current platform.
+type Keyring interface {
+	// Available checks if the OS keyring can be used on this machine.
+	Available() bool
+
+	// Get returns the secret of the account, it must be wiped after use. If no
+	// secret is stored, ErrNotFound is returned.
+	Get(account string) (secret.Bytes, error)
+
+	// Set stores the secret for the account, replacing any existing secret.
+	Set(account string, value []byte) error
+
+	// Delete removes the secret of the account. Deleting an account that has no
+	// secret is not an error.
+	Delete(account string) error
+}
+
+// run runs the program with args and writes stdin to its standard input. The
+// standard output is returned with the trailing new line removed, it can be a secret
+// and must be wiped after use. If the program fails, the error contains its
+// standard error.
+func run(stdin []byte, name string, args ...string) (secret.Bytes, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		secret.Wipe(stdout.Bytes())
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return bytes.TrimSuffix(stdout.Bytes(), []byte("\n")), nil
+}
+
+// exitCode returns the exit code of the program that failed with err, or -1 if it
+// did not exit.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
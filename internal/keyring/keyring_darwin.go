@@ -0,0 +1,76 @@
+//go:build darwin
+
+package keyring
+
+import (
+	"strconv"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// errItemNotFound is the exit code of the security tool when the keychain has no
+// item for the account.
+const errItemNotFound = 44
+
+// keychain is the macOS Keyring. Secrets are stored in the login keychain.
+type keychain struct{}
+
+// New returns the Keyring of the current platform. On macOS secrets are stored in
+// the login keychain.
+func New() Keyring {
+	return &keychain{}
+}
+
+func (k *keychain) Available() bool {
+	_, err := run(nil, "security", "default-keychain")
+	return err == nil
+}
+
+func (k *keychain) Get(account string) (secret.Bytes, error) {
+	value, err := run(nil, "security", "find-generic-password", "-a", account, "-s", service, "-w")
+	if exitCode(err) == errItemNotFound {
+		return nil, ErrNotFound
+	}
+
+	return value, err
+}
+
+// Set adds the secret to the keychain. The command is written to the standard input
+// of the security tool, so the secret is never visible in the process list. The
+// command is wiped after.
+func (k *keychain) Set(account string, value []byte) error {
+	command := []byte("add-generic-password -U -a ")
+	command = strconv.AppendQuote(command, account)
+	command = append(command, " -s "...)
+	command = strconv.AppendQuote(command, service)
+	command = append(command, " -w "...)
+	command = appendQuote(command, value)
+	command = append(command, '\n')
+	defer secret.Wipe(command)
+
+	_, err := run(command, "security", "-i")
+	return err
+}
+
+func (k *keychain) Delete(account string) error {
+	_, err := run(nil, "security", "delete-generic-password", "-a", account, "-s", service)
+	if exitCode(err) == errItemNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// appendQuote appends b to dst as a double quoted string of the security tool, the
+// quotes and backslashes in it are escaped.
+func appendQuote(dst []byte, b []byte) []byte {
+	dst = append(dst, '"')
+	for _, c := range b {
+		if c == '"' || c == '\\' {
+			dst = append(dst, '\\')
+		}
+		dst = append(dst, c)
+	}
+
+	return append(dst, '"')
+}
@@ -0,0 +1,65 @@
+//go:build !darwin && !windows
+
+package keyring
+
+import (
+	"os/exec"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// secretService is the Keyring of Linux and other Unix platforms. Secrets are stored
+// with the Secret Service of libsecret, such as GNOME Keyring or KWallet, through
+// the secret-tool program.
+type secretService struct{}
+
+// New returns the Keyring of the current platform. On Linux and other Unix platforms
+// secrets are stored with the Secret Service, secret-tool must be installed.
+func New() Keyring {
+	return &secretService{}
+}
+
+func (s *secretService) Available() bool {
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+// Get looks up the secret. The secret-tool program exits 1 without output when no
+// secret matches the account.
+func (s *secretService) Get(account string) (secret.Bytes, error) {
+	if !s.Available() {
+		return nil, ErrUnsupported
+	}
+
+	value, err := run(nil, "secret-tool", "lookup", "service", service, "account", account)
+	if exitCode(err) == 1 && len(value) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return value, err
+}
+
+// Set stores the secret. It is written to the standard input of secret-tool, so it
+// is never visible in the process list.
+func (s *secretService) Set(account string, value []byte) error {
+	if !s.Available() {
+		return ErrUnsupported
+	}
+
+	_, err := run(value, "secret-tool", "store", "--label", "Clox CLI ("+account+")",
+		"service", service, "account", account)
+	return err
+}
+
+func (s *secretService) Delete(account string) error {
+	if !s.Available() {
+		return nil
+	}
+
+	_, err := run(nil, "secret-tool", "clear", "service", service, "account", account)
+	if exitCode(err) == 1 {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,132 @@
+//go:build windows
+
+package keyring
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	// credTypeGeneric is CRED_TYPE_GENERIC.
+	credTypeGeneric = 1
+	// credPersistLocalMachine is CRED_PERSIST_LOCAL_MACHINE, the credential is kept
+	// across log on sessions of the user on this machine.
+	credPersistLocalMachine = 2
+	// errNotFound is ERROR_NOT_FOUND, returned when no credential has the target.
+	errNotFound syscall.Errno = 1168
+)
+
+// credential is the CREDENTIALW structure of the Credential Manager.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialManager is the Windows Keyring. Secrets are stored as generic
+// credentials in the Credential Manager of the current user.
+type credentialManager struct{}
+
+// New returns the Keyring of the current platform. On Windows secrets are stored in
+// the Credential Manager.
+func New() Keyring {
+	return &credentialManager{}
+}
+
+func (m *credentialManager) Available() bool {
+	return procCredReadW.Find() == nil
+}
+
+func (m *credentialManager) Get(account string) (secret.Bytes, error) {
+	target, err := syscall.UTF16PtrFromString(targetName(account))
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, errNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	if cred.CredentialBlobSize == 0 {
+		return secret.Bytes{}, nil
+	}
+
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	value := append(secret.Bytes{}, blob...)
+	secret.Wipe(blob)
+	return value, nil
+}
+
+func (m *credentialManager) Set(account string, value []byte) error {
+	target, err := syscall.UTF16PtrFromString(targetName(account))
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	cred := credential{
+		Type:       credTypeGeneric,
+		TargetName: target,
+		Persist:    credPersistLocalMachine,
+		UserName:   user,
+	}
+	if len(value) > 0 {
+		cred.CredentialBlob = &value[0]
+		cred.CredentialBlobSize = uint32(len(value))
+	}
+
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return err
+	}
+
+	return nil
+}
+
+func (m *credentialManager) Delete(account string) error {
+	target, err := syscall.UTF16PtrFromString(targetName(account))
+	if err != nil {
+		return err
+	}
+
+	r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if r == 0 && !errors.Is(err, errNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// targetName returns the name of the credential of the account.
+func targetName(account string) string {
+	return service + ":" + account
+}
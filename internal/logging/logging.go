@@ -0,0 +1,185 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Format is the output format of the log records.
+type Format string
+
+const (
+	// FormatText writes log records as key=value pairs.
+	FormatText Format = "text"
+	// FormatJSON writes log records as line-delimited JSON objects.
+	FormatJSON Format = "json"
+)
+
+// LevelTrace is the level of the records that are more detailed than debug, such as
+// every attempt of a request and the delay before a retry. It is enabled by the
+// verbose flag given twice (-vv).
+const LevelTrace = slog.LevelDebug - 4
+
+// Redacted is the value that replaces the value of a sensitive attribute.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the attribute key fragments that identify sensitive values.
+// Any attribute whose key contains one of these (case-insensitive) is redacted.
+var sensitiveKeys = []string{"token", "password", "passwd", "secret", "key", "authorization"}
+
+// Logger is the logger shared by the Clox CLI commands.
+//
+// Logger embeds a *slog.Logger so it can be used like any other slog logger. It
+// can be reconfigured after creation with Configure, this allows commands to be
+// given a Logger before the command line flags have been parsed. Logger should
+// be created by calling New.
+//
+// The messages of a command that are not errors or results, such as the files that
+// were uploaded, are printed to standard output with Printf and Println. They are
+// not printed if the Logger is quiet, see SetQuiet.
+type Logger struct {
+	*slog.Logger
+	w     io.Writer
+	out   io.Writer
+	quiet bool
+}
+
+// New creates a *Logger that writes text records at the info level to w. Messages
+// are printed to standard output.
+func New(w io.Writer) *Logger {
+	l := &Logger{w: w, out: os.Stdout}
+	l.Configure(slog.LevelInfo, FormatText)
+	return l
+}
+
+// SetQuiet sets if this Logger is quiet. A quiet Logger does not print messages,
+// and only writes records at the error level.
+func (l *Logger) SetQuiet(quiet bool) {
+	l.quiet = quiet
+}
+
+// SetOutput sets the io.Writer that the messages of this Logger are printed to,
+// instead of standard output.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.out = w
+}
+
+// Quiet checks if this Logger is quiet, see SetQuiet.
+func (l *Logger) Quiet() bool {
+	return l.quiet
+}
+
+// Printf prints a message to standard output, formatted the same as fmt.Printf. A
+// quiet Logger prints nothing.
+func (l *Logger) Printf(format string, args ...any) {
+	if !l.quiet {
+		fmt.Fprintf(l.out, format, args...)
+	}
+}
+
+// Println prints a message to standard output, formatted the same as fmt.Println. A
+// quiet Logger prints nothing.
+func (l *Logger) Println(args ...any) {
+	if !l.quiet {
+		fmt.Fprintln(l.out, args...)
+	}
+}
+
+// Trace writes a record at LevelTrace.
+func (l *Logger) Trace(msg string, args ...any) {
+	l.Log(context.Background(), LevelTrace, msg, args...)
+}
+
+// Configure sets the minimum level and the output format of this Logger. Every
+// handler created by Configure redacts sensitive attributes.
+//
+// A quiet Logger only writes records at the error level, whatever the level is.
+func (l *Logger) Configure(level slog.Level, format Format) {
+	if l.quiet {
+		level = max(level, slog.LevelError)
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceAttr(format)}
+
+	var h slog.Handler
+	switch format {
+	case FormatJSON:
+		h = slog.NewJSONHandler(l.w, opts)
+	default:
+		h = slog.NewTextHandler(l.w, opts)
+	}
+
+	l.Logger = slog.New(h)
+}
+
+// ParseLevel parses s into a slog.Level. Valid values are "trace", "debug", "info",
+// "warn", and "error".
+func ParseLevel(s string) (slog.Level, error) {
+	if strings.EqualFold(s, "trace") {
+		return LevelTrace, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level '%s'", s)
+	}
+
+	return level, nil
+}
+
+// ParseFormat parses s into a Format. Valid values are "text" and "json".
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid log format '%s'", s)
+	}
+}
+
+// IsSensitive reports whether the attribute key identifies a sensitive value, such
+// as a token, password, or key.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replaceAttr returns the slog.HandlerOptions ReplaceAttr function for the format.
+//
+// The value of every sensitive attribute is replaced with Redacted, as is any
+// string value that carries a bearer token. Text records drop the time attribute,
+// it is noise when reading the output of a command in a terminal. Records at
+// LevelTrace are named TRACE.
+func replaceAttr(format Format) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey && format != FormatJSON {
+			return slog.Attr{}
+		}
+
+		if len(groups) == 0 && a.Key == slog.LevelKey {
+			if level, ok := a.Value.Any().(slog.Level); ok && level == LevelTrace {
+				return slog.String(a.Key, "TRACE")
+			}
+			return a
+		}
+
+		if IsSensitive(a.Key) {
+			return slog.String(a.Key, Redacted)
+		}
+
+		if a.Value.Kind() == slog.KindString && strings.HasPrefix(a.Value.String(), "Bearer ") {
+			return slog.String(a.Key, Redacted)
+		}
+
+		return a
+	}
+}
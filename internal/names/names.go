@@ -0,0 +1,198 @@
+package names
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/secret"
+	"github.com/cicconee/clox-cli/internal/securefile"
+)
+
+// Prefix starts every name that is encrypted by a Codec. A name on the server
+// without it is stored in plain text.
+const Prefix = "clxn-"
+
+// ivSize is the size of the synthetic IV at the start of an encrypted name.
+const ivSize = 16
+
+// Codec encrypts the names of files before they are sent to the server, and
+// decrypts the names the server responds with. It implements api.NameCodec.
+//
+// A name is encrypted deterministically, the same name always has the same
+// encrypted form, so a file can still be found by its path. The IV of a name is
+// the HMAC-SHA256 of the name, and the name is encrypted with AES-256 in CTR mode.
+// The encrypted name is Prefix and the base64url encoding of the IV and cipher
+// text. An encrypted name is about a third longer than the name, plus 27 bytes.
+//
+// Every name that is decrypted is recorded in the Mapping of the Codec, if it has
+// one, so it is not decrypted again.
+type Codec struct {
+	mac     []byte
+	block   cipher.Block
+	mapping *Mapping
+}
+
+// New creates and returns a *Codec for the names key. The keys that names are
+// authenticated and encrypted with are derived from it. The mapping can be nil.
+func New(key []byte, mapping *Mapping) (*Codec, error) {
+	encKey := secret.Bytes(derive(key, "clox names enc"))
+	defer encKey.Wipe()
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Codec{mac: derive(key, "clox names mac"), block: block, mapping: mapping}, nil
+}
+
+// derive derives a key for the label from the names key.
+func derive(key []byte, label string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(label))
+	return h.Sum(nil)
+}
+
+// iv returns the synthetic IV of the name.
+func (c *Codec) iv(name string) []byte {
+	h := hmac.New(sha256.New, c.mac)
+	h.Write([]byte(name))
+	return h.Sum(nil)[:ivSize]
+}
+
+// Encode returns the encrypted form of the name. An empty name is returned as is.
+func (c *Codec) Encode(name string) string {
+	if name == "" {
+		return name
+	}
+	if c.mapping != nil {
+		if encoded, ok := c.mapping.encoded[name]; ok {
+			return encoded
+		}
+	}
+
+	iv := c.iv(name)
+	out := make([]byte, ivSize+len(name))
+	copy(out, iv)
+	cipher.NewCTR(c.block, iv).XORKeyStream(out[ivSize:], []byte(name))
+
+	encoded := Prefix + base64.RawURLEncoding.EncodeToString(out)
+	c.mapping.put(encoded, name)
+	return encoded
+}
+
+// Decode returns the name that the encrypted name was encrypted from. A name that
+// was not encrypted by a Codec with the same key, such as the name of a file that
+// was uploaded before names were encrypted, is returned as is.
+func (c *Codec) Decode(name string) string {
+	if !strings.HasPrefix(name, Prefix) {
+		return name
+	}
+	if c.mapping != nil {
+		if plain, ok := c.mapping.Names[name]; ok {
+			return plain
+		}
+	}
+
+	plain, err := c.decrypt(strings.TrimPrefix(name, Prefix))
+	if err != nil {
+		return name
+	}
+
+	c.mapping.put(name, plain)
+	return plain
+}
+
+// errInvalidName is the error when a name was not encrypted by the Codec.
+var errInvalidName = errors.New("invalid encrypted name")
+
+// decrypt decrypts the base64url encoded IV and cipher text of an encrypted name,
+// and checks that the IV is the IV of the name.
+func (c *Codec) decrypt(s string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) <= ivSize {
+		return "", errInvalidName
+	}
+
+	iv := data[:ivSize]
+	plain := make([]byte, len(data)-ivSize)
+	cipher.NewCTR(c.block, iv).XORKeyStream(plain, data[ivSize:])
+	if !hmac.Equal(iv, c.iv(string(plain))) {
+		return "", errInvalidName
+	}
+
+	return string(plain), nil
+}
+
+// Mapping maps the encrypted names that were seen on the server to the names they
+// were encrypted from. It is kept next to the configuration of a profile, so the
+// names are listed without decrypting them again.
+//
+// The Mapping is stored encrypted with the users password, the file names never
+// touch the disk in plain text.
+type Mapping struct {
+	// Names are the names of the files, keyed by their encrypted name.
+	Names map[string]string `json:"names"`
+
+	encoded map[string]string
+	changed bool
+}
+
+// NewMapping creates and returns an empty Mapping.
+func NewMapping() *Mapping {
+	return &Mapping{Names: map[string]string{}, encoded: map[string]string{}}
+}
+
+// Load reads the mapping file at path and decrypts it with the password. If the
+// file does not exist, it returns an empty Mapping.
+func Load(path string, aes *crypto.AES, password []byte) (*Mapping, error) {
+	m := NewMapping()
+	if err := securefile.ReadJSON(path, aes, password, m); err != nil {
+		if errors.Is(err, securefile.ErrNotExist) {
+			return NewMapping(), nil
+		}
+
+		return nil, err
+	}
+
+	if m.Names == nil {
+		m.Names = map[string]string{}
+	}
+	for encoded, plain := range m.Names {
+		m.encoded[plain] = encoded
+	}
+
+	return m, nil
+}
+
+// Save encrypts this Mapping with the password and writes it to path. If no name was
+// added since it was loaded, nothing is written.
+func (m *Mapping) Save(path string, aes *crypto.AES, password []byte) error {
+	if !m.changed {
+		return nil
+	}
+
+	if err := securefile.WriteJSON(path, aes, password, m); err != nil {
+		return err
+	}
+
+	m.changed = false
+	return nil
+}
+
+// put records that the encrypted name is the encrypted form of the name. It does
+// nothing on a nil Mapping.
+func (m *Mapping) put(encoded string, name string) {
+	if m == nil || m.Names[encoded] == name {
+		return
+	}
+
+	m.Names[encoded] = name
+	m.encoded[name] = encoded
+	m.changed = true
+}
@@ -0,0 +1,144 @@
+// Package output renders the results of the commands in the format the user asks
+// for: the text of the result, an aligned table, JSON, YAML, or a Go template.
+//
+// A command builds a result, a struct or a slice of structs, and passes it to a
+// Renderer instead of printing it. The fields of a result are named in JSON by their
+// json tags, and in a table by their table tags, see Table.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+const (
+	// Text is the format of a result that has a text of its own, see Texter. A
+	// result without one is rendered as a Table.
+	Text = "text"
+	// Table renders a result as a table with aligned columns, see Table.
+	Table = "table"
+	// JSON renders a result as JSON, on a single line.
+	JSON = "json"
+	// YAML renders a result as YAML.
+	YAML = "yaml"
+)
+
+// Texter is implemented by a result that has a text of its own, the format the
+// commands print by default.
+type Texter interface {
+	// Text writes the result to w as text.
+	Text(w io.Writer) error
+}
+
+// Lister is implemented by a result that holds a list of items, such as the
+// revisions of a file, and other fields. A table or template of the result renders
+// its items, JSON and YAML render the whole result.
+type Lister interface {
+	// Items returns the items of the result, a slice.
+	Items() any
+}
+
+// Renderer renders results in a format, see New.
+type Renderer struct {
+	format string
+	tmpl   *template.Template
+}
+
+// New creates and returns a *Renderer for the format: Text, Table, JSON, YAML, or a
+// Go template such as '{{.ID}}'. A template is executed for every item of a result
+// that is a slice or a Lister, each on its own line, otherwise once for the result.
+// Templates have the functions json, which renders a value as JSON, and join, which
+// joins a slice of strings with a separator.
+//
+// An empty format is Text.
+func New(format string) (*Renderer, error) {
+	switch format {
+	case "":
+		return &Renderer{format: Text}, nil
+	case Text, Table, JSON, YAML:
+		return &Renderer{format: format}, nil
+	}
+	if !strings.Contains(format, "{{") {
+		return nil, fmt.Errorf("unsupported format '%s', must be %s, %s, %s, %s, or a Go template such as '{{.ID}}'", format, Text, Table, JSON, YAML)
+	}
+
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"join": strings.Join,
+	}).Option("missingkey=error").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	return &Renderer{format: "template", tmpl: tmpl}, nil
+}
+
+// Format returns the format of this Renderer, "template" for a Go template.
+func (r *Renderer) Format() string {
+	return r.format
+}
+
+// IsText checks if this Renderer renders results as their text, see Texter. A
+// command can print notices along with the text that would break other formats.
+func (r *Renderer) IsText() bool {
+	return r.format == Text
+}
+
+// Render writes the result v to w in the format of this Renderer.
+func (r *Renderer) Render(w io.Writer, v any) error {
+	switch r.format {
+	case Text:
+		if t, ok := v.(Texter); ok {
+			return t.Text(w)
+		}
+		return renderTable(w, items(v))
+	case Table:
+		return renderTable(w, items(v))
+	case JSON:
+		return json.NewEncoder(w).Encode(v)
+	case YAML:
+		return renderYAML(w, v)
+	default:
+		return r.renderTemplate(w, items(v))
+	}
+}
+
+// renderTemplate executes the template for every item of v if it is a slice,
+// otherwise once for v. Each is followed by a new line.
+func (r *Renderer) renderTemplate(w io.Writer, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		if err := r.tmpl.Execute(w, v); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		if err := r.tmpl.Execute(w, rv.Index(i).Interface()); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// items returns the items of v if it is a Lister, otherwise v.
+func items(v any) any {
+	if l, ok := v.(Lister); ok {
+		return l.Items()
+	}
+
+	return v
+}
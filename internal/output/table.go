@@ -0,0 +1,159 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// Tabler is implemented by a result that builds its own table, instead of the table
+// built from the fields of the result.
+type Tabler interface {
+	// Table returns the header and the rows of the table.
+	Table() (header []string, rows [][]string)
+}
+
+// renderTable writes v to w as a table with aligned columns.
+//
+// A Tabler is rendered as its own table. A slice of structs is rendered with a row
+// for every struct and a column for every field, named by the table tag of the
+// field, or the name of the field in upper case. A field with the table tag '-' is
+// not rendered. A single struct is rendered with a row for every field, its name and
+// value.
+func renderTable(w io.Writer, v any) error {
+	var header []string
+	var rows [][]string
+
+	if t, ok := v.(Tabler); ok {
+		header, rows = t.Table()
+	} else {
+		rv := indirect(reflect.ValueOf(v))
+		switch {
+		case !rv.IsValid():
+			return nil
+		case rv.Kind() == reflect.Slice && elemKind(rv.Type()) == reflect.Struct:
+			header = columns(indirectType(rv.Type().Elem()))
+			for i := 0; i < rv.Len(); i++ {
+				rows = append(rows, row(indirect(rv.Index(i))))
+			}
+		case rv.Kind() == reflect.Slice:
+			for i := 0; i < rv.Len(); i++ {
+				rows = append(rows, []string{cell(rv.Index(i))})
+			}
+		case rv.Kind() == reflect.Struct:
+			names := columns(rv.Type())
+			values := row(rv)
+			for i := range names {
+				rows = append(rows, []string{names[i] + ":", values[i]})
+			}
+		default:
+			rows = [][]string{{cell(rv)}}
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// columns returns the names of the columns of the struct type t.
+func columns(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name, ok := column(f); ok {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// row returns the cells of the struct v, in the order of its columns.
+func row(v reflect.Value) []string {
+	if !v.IsValid() {
+		return nil
+	}
+
+	var cells []string
+	for i := 0; i < v.NumField(); i++ {
+		if _, ok := column(v.Type().Field(i)); ok {
+			cells = append(cells, cell(v.Field(i)))
+		}
+	}
+
+	return cells
+}
+
+// column returns the column name of the field f, and false if f is not rendered.
+func column(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+
+	tag := f.Tag.Get("table")
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return strings.ToUpper(f.Name), true
+	default:
+		return tag, true
+	}
+}
+
+// cell returns the value v as the text of a cell. A nil pointer is empty, a slice is
+// joined with commas.
+func cell(v reflect.Value) string {
+	v = indirect(v)
+	if !v.IsValid() {
+		return ""
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = cell(v.Index(i))
+		}
+		return strings.Join(parts, ",")
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+// indirect follows the pointers and interfaces of v to the value they point to. A nil
+// pointer is the zero Value.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+// indirectType follows the pointers of t to the type they point to.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+// elemKind returns the kind of the elements of the slice type t, without pointers.
+func elemKind(t reflect.Type) reflect.Kind {
+	return indirectType(t.Elem()).Kind()
+}
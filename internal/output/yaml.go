@@ -0,0 +1,207 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// node is a value decoded from JSON, with the keys of objects in the order they
+// were encoded.
+type node struct {
+	// keys and values are the fields of an object.
+	keys   []string
+	values []*node
+	// items are the elements of an array.
+	items []*node
+	// scalar is a string, json.Number, bool, or nil.
+	scalar any
+	kind   byte
+}
+
+const (
+	scalarNode byte = iota
+	objectNode
+	arrayNode
+)
+
+// renderYAML writes v to w as YAML. The value is encoded as JSON first, so the
+// fields are named by their json tags and in the order of the struct.
+func renderYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	n, err := decodeNode(dec)
+	if err != nil {
+		return fmt.Errorf("decoding json: %w", err)
+	}
+
+	var b strings.Builder
+	switch {
+	case n.kind == objectNode && len(n.keys) > 0:
+		writeObject(&b, n, 0)
+	case n.kind == arrayNode && len(n.items) > 0:
+		writeArray(&b, n, 0)
+	default:
+		b.WriteString(scalarYAML(n))
+		b.WriteString("\n")
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// decodeNode decodes the next value of dec.
+func decodeNode(dec *json.Decoder) (*node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		n := &node{kind: objectNode}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key.(string))
+			n.values = append(n.values, value)
+		}
+		_, err := dec.Token()
+		return n, err
+	case json.Delim('['):
+		n := &node{kind: arrayNode}
+		for dec.More() {
+			item, err := decodeNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+		_, err := dec.Token()
+		return n, err
+	default:
+		return &node{kind: scalarNode, scalar: tok}, nil
+	}
+}
+
+// writeObject writes the fields of the object n, indented by indent levels.
+func writeObject(b *strings.Builder, n *node, indent int) {
+	for i, key := range n.keys {
+		b.WriteString(strings.Repeat("  ", indent))
+		b.WriteString(quoteYAML(key))
+		b.WriteString(":")
+		writeValue(b, n.values[i], indent+1)
+	}
+}
+
+// writeArray writes the items of the array n, indented by indent levels.
+func writeArray(b *strings.Builder, n *node, indent int) {
+	for _, item := range n.items {
+		b.WriteString(strings.Repeat("  ", indent))
+		b.WriteString("-")
+		switch {
+		case item.kind == objectNode && len(item.keys) > 0:
+			// The first field is on the line of the dash, the others are
+			// aligned with it.
+			var sub strings.Builder
+			writeObject(&sub, item, indent+1)
+			b.WriteString(" ")
+			b.WriteString(strings.TrimLeft(sub.String(), " "))
+		default:
+			writeValue(b, item, indent+1)
+		}
+	}
+}
+
+// writeValue writes the value n after a key or a dash, indented by indent levels
+// if it is an object or an array.
+func writeValue(b *strings.Builder, n *node, indent int) {
+	switch {
+	case n.kind == objectNode && len(n.keys) > 0:
+		b.WriteString("\n")
+		writeObject(b, n, indent)
+	case n.kind == arrayNode && len(n.items) > 0:
+		b.WriteString("\n")
+		writeArray(b, n, indent)
+	default:
+		b.WriteString(" ")
+		b.WriteString(scalarYAML(n))
+		b.WriteString("\n")
+	}
+}
+
+// scalarYAML returns the scalar, or the empty object or array n as YAML.
+func scalarYAML(n *node) string {
+	switch n.kind {
+	case objectNode:
+		return "{}"
+	case arrayNode:
+		return "[]"
+	}
+
+	switch s := n.scalar.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(s)
+	case json.Number:
+		return s.String()
+	case string:
+		return quoteYAML(s)
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+// quoteYAML returns s as a YAML string, quoted if it would otherwise be read as
+// something else, such as a number, a boolean, or the start of a structure.
+func quoteYAML(s string) string {
+	if s == "" || needsQuote(s) {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// needsQuote checks if the string s must be quoted in YAML.
+func needsQuote(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.TrimSpace(s) != s {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	// A ':' followed by a space or tab, or at the end, is read as the key of a
+	// mapping.
+	if strings.HasSuffix(s, ":") || strings.Contains(s, ": ") || strings.Contains(s, ":\t") || strings.Contains(s, " #") {
+		return true
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,227 @@
+package partial
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cicconee/clox-cli/api"
+)
+
+const (
+	// Ext is the extension of a partial download. It is added to the path the
+	// download is written to.
+	Ext = ".clox-partial"
+	// stateExt is the extension of the state of a partial download. It is added to
+	// the path of the partial download.
+	stateExt = ".json"
+	// BlockSize is the number of bytes covered by each checksum of a partial
+	// download.
+	BlockSize = 1 << 20
+)
+
+var (
+	// ErrNotFound is the error when there is no partial download to resume.
+	ErrNotFound = errors.New("no partial download")
+	// ErrChanged is the error when the partial download is of another version of
+	// the file on the server.
+	ErrChanged = errors.New("the file changed on the server since the partial download")
+)
+
+// State is the state of a partial download. It identifies the version of the file
+// on the server that is downloaded, and holds the checksum of every complete block
+// that is written.
+type State struct {
+	FileID string `json:"file_id"`
+	ETag   string `json:"etag,omitempty"`
+	Size   int64  `json:"size"`
+	// Checksums are the hex encoded SHA-256 checksums of the blocks of the partial
+	// download, in order.
+	Checksums []string `json:"checksums"`
+}
+
+// matches reports whether this State is of the same version of the file. If the
+// server does not expose an ETag, only the ID and size are compared.
+func (s State) matches(file api.File) bool {
+	return s.FileID == file.ID && s.ETag == file.ETag && s.Size == file.Size
+}
+
+// File is a partial download. The contents of the file are written to it as they
+// are downloaded, as they are stored on the server. The state is saved after every
+// complete block, so that a download that is interrupted can be resumed from the
+// last block that was written.
+type File struct {
+	path    string
+	f       *os.File
+	state   State
+	hash    hash.Hash
+	pending int
+}
+
+// Open opens the partial download of the file that is written to output. If a
+// partial download of the same version of the file exists, each block is verified
+// against its checksum and the download resumes after the last block that is
+// intact. Otherwise, the download starts from the beginning.
+func Open(output string, file api.File) (*File, error) {
+	path := output + Ext
+
+	state, err := readState(path)
+	if err != nil || !state.matches(file) {
+		state = State{FileID: file.ID, ETag: file.ETag, Size: file.Size}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &File{path: path, f: f, state: state, hash: sha256.New()}
+	if err := p.verify(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// CanResume checks if the partial download of the file that is written to output
+// can be resumed. If there is no partial download, it returns ErrNotFound. If the
+// partial download is of another version of the file, it returns ErrChanged. The
+// blocks are not verified until the partial download is opened.
+func CanResume(output string, file api.File) error {
+	state, err := readState(output + Ext)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !state.matches(file) {
+		return ErrChanged
+	}
+
+	return nil
+}
+
+// verify compares each block of this File with its checksum. The File is truncated
+// after the last block that matches, and the state is saved.
+func (p *File) verify() error {
+	buf := make([]byte, BlockSize)
+	valid := 0
+	for _, sum := range p.state.Checksums {
+		if _, err := io.ReadFull(p.f, buf); err != nil {
+			break
+		}
+
+		h := sha256.Sum256(buf)
+		if hex.EncodeToString(h[:]) != sum {
+			break
+		}
+		valid++
+	}
+
+	p.state.Checksums = p.state.Checksums[:valid]
+	if err := p.f.Truncate(p.Offset()); err != nil {
+		return fmt.Errorf("truncating %s: %w", p.path, err)
+	}
+	if _, err := p.f.Seek(p.Offset(), io.SeekStart); err != nil {
+		return fmt.Errorf("seeking %s: %w", p.path, err)
+	}
+
+	return p.save()
+}
+
+// Offset returns the number of bytes of this File that are verified. The download
+// resumes from this offset.
+func (p *File) Offset() int64 {
+	return int64(len(p.state.Checksums)) * BlockSize
+}
+
+// Path returns the path of this File.
+func (p *File) Path() string {
+	return p.path
+}
+
+// Write writes b to the end of this File. The state is saved each time a block is
+// complete.
+func (p *File) Write(b []byte) (int, error) {
+	n, err := p.f.Write(b)
+
+	written := b[:n]
+	for len(written) > 0 {
+		m := min(BlockSize-p.pending, len(written))
+		p.hash.Write(written[:m])
+		p.pending += m
+		written = written[m:]
+
+		if p.pending == BlockSize {
+			p.state.Checksums = append(p.state.Checksums, hex.EncodeToString(p.hash.Sum(nil)))
+			p.hash.Reset()
+			p.pending = 0
+			if err := p.save(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, err
+}
+
+// ReadAll returns the contents of this File.
+func (p *File) ReadAll() ([]byte, error) {
+	return os.ReadFile(p.path)
+}
+
+// Close closes this File. The partial download is kept so that it can be resumed.
+func (p *File) Close() error {
+	return p.f.Close()
+}
+
+// save writes the state of this File.
+func (p *File) save() error {
+	data, err := json.Marshal(p.state)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.path+stateExt, data, 0600)
+}
+
+// Remove removes the partial download of the file that is written to output, and
+// its state. It is not an error if there is no partial download.
+func Remove(output string) error {
+	path := output + Ext
+	for _, name := range []string{path, path + stateExt} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsPartial checks if the file name is of a partial download or its state.
+func IsPartial(name string) bool {
+	return strings.HasSuffix(name, Ext) || strings.HasSuffix(name, Ext+stateExt)
+}
+
+// readState reads the state of the partial download at path.
+func readState(path string) (State, error) {
+	data, err := os.ReadFile(path + stateExt)
+	if err != nil {
+		return State{}, err
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return State{}, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Prefix is the prefix of every plugin executable name. A plugin named "backup"
+// is an executable named "clox-backup".
+const Prefix = "clox-"
+
+// Plugin is an executable found on PATH that extends the Clox CLI with a sub
+// command. Plugins are discovered the same way git discovers its sub commands.
+type Plugin struct {
+	// The name of the sub command. This is the executable name without Prefix.
+	Name string
+	// The absolute path to the executable.
+	Path string
+}
+
+// Discover searches every directory in pathList for plugin executables. The
+// pathList is a list of directories separated by os.PathListSeparator, the same
+// format as the PATH environment variable.
+//
+// If more than one directory contains a plugin with the same name, the first one
+// found is used. Directories that cannot be read are skipped. The returned plugins
+// are sorted by name.
+func Discover(pathList string) []Plugin {
+	found := map[string]Plugin{}
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range entries {
+			name, ok := pluginName(e.Name())
+			if !ok {
+				continue
+			}
+			if _, exists := found[name]; exists {
+				continue
+			}
+
+			path := filepath.Join(dir, e.Name())
+			if !isExecutable(path) {
+				continue
+			}
+
+			found[name] = Plugin{Name: name, Path: path}
+		}
+	}
+
+	plugins := make([]Plugin, 0, len(found))
+	for _, p := range found {
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+
+	return plugins
+}
+
+// Run executes this Plugin with args. The standard input, output, and error of the
+// current process are passed through to the plugin.
+//
+// The env map is added to the environment of the current process and passed to the
+// plugin. If the plugin exits with a non-zero status, an *exec.ExitError is
+// returned.
+func (p Plugin) Run(args []string, env map[string]string) error {
+	cmd := exec.Command(p.Path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	return cmd.Run()
+}
+
+// pluginName returns the plugin name of the file name. If the file name is not a
+// plugin executable name, it will return false.
+func pluginName(filename string) (string, bool) {
+	if !strings.HasPrefix(filename, Prefix) {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(filename, Prefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	return name, name != ""
+}
+
+// isExecutable checks if the file at path is a regular file that can be executed.
+// On Windows every file with a ".exe", ".bat", or ".cmd" extension is executable.
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		default:
+			return false
+		}
+	}
+
+	return fi.Mode().Perm()&0111 != 0
+}
@@ -1,61 +1,304 @@
 package prompt
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strings"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// ErrNoInput is the error returned by a prompt when input is required but prompting
+// is disabled, see DisableInput.
+var ErrNoInput = errors.New("input is required, but prompting is disabled (--no-input)")
+
+// errClosed is the error returned by a prompt that loops when the input is closed
+// before a valid value is entered.
+var errClosed = errors.New("input closed before a value was entered")
+
+var (
+	// noInput is set by DisableInput.
+	noInput bool
+	// assumeYes is set by AssumeYes.
+	assumeYes bool
+	// password is set by SetPassword.
+	password secret.Bytes
 )
 
+// DisableInput disables every prompt. A prompt that requires input returns
+// ErrNoInput, and Confirm answers no, without reading anything.
+func DisableInput() {
+	noInput = true
+}
+
+// AssumeYes answers yes to every Confirm without reading anything, so a destructive
+// command can be run by a script. It takes precedence over DisableInput.
+func AssumeYes() {
+	assumeYes = true
+}
+
+// InputDisabled reports whether the prompts are disabled, see DisableInput.
+func InputDisabled() bool {
+	return noInput
+}
+
+// SetPassword sets the password that Password and ConfigurePassowrd return, they
+// will not prompt for it. The caller keeps the password, it is wiped with it.
+func SetPassword(p secret.Bytes) {
+	password = p
+}
+
 // InString prints msg and takes a string input from the user. The input value will
 // be stored in dst. The prompt is formatted as "msg: ".
 func InString(msg string, dst *string) {
+	inString(msg, dst)
+}
+
+// inSecret prints msg and reads a line of input from the user, see ReadSecret. The
+// prompt is formatted as "msg: ". If the input is closed, errClosed is returned.
+func inSecret(msg string) (secret.Bytes, error) {
 	fmt.Printf("%s: ", msg)
-	fmt.Scanln(dst)
+	s, err := ReadSecret(os.Stdin)
+	if err != nil {
+		fmt.Println()
+		return nil, errClosed
+	}
+
+	return s, nil
+}
+
+// inString is InString, it returns io.EOF if the input is closed.
+func inString(msg string, dst *string) error {
+	fmt.Printf("%s: ", msg)
+	_, err := fmt.Scanln(dst)
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+
+	return nil
+}
+
+// ReadLine reads a single line from r and returns it without the line ending, see
+// ReadSecret. A line that may be a secret is read with ReadSecret instead.
+func ReadLine(r io.Reader) (string, error) {
+	line, err := ReadSecret(r)
+	if err != nil {
+		return "", err
+	}
+	defer line.Wipe()
+
+	return string(line), nil
+}
+
+// ReadSecret reads a single line from r and returns it without the line ending, such
+// as a password. It reads one byte at a time, so nothing after the line is consumed.
+// If r is closed before anything is read, io.EOF is returned. The buffers the line
+// is read into are wiped as it grows, the line must be wiped by the caller after
+// use.
+func ReadSecret(r io.Reader) (secret.Bytes, error) {
+	line := make(secret.Bytes, 0, 128)
+	b := make([]byte, 1)
+	defer secret.Wipe(b)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			if len(line) == cap(line) {
+				// Grow the buffer here so the old one is wiped, not left for append.
+				grown := make(secret.Bytes, len(line), 2*cap(line))
+				copy(grown, line)
+				line.Wipe()
+				line = grown
+			}
+			line = append(line, b[0])
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			if len(line) == 0 {
+				return nil, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			line.Wipe()
+			return nil, err
+		}
+	}
+
+	return bytes.TrimSuffix(line, []byte("\r")), nil
+}
+
+// Password returns the password set with SetPassword, or prompts the user to enter
+// it. If prompting is disabled, ErrNoInput is returned. The password is a secret, it
+// must be wiped after use.
+func Password() (secret.Bytes, error) {
+	if password != nil {
+		return password, nil
+	}
+	if noInput {
+		return nil, ErrNoInput
+	}
+
+	return inSecret("Password")
 }
 
-func Password() string {
-	var password string
-	InString("Password", &password)
-	return password
+// Secret prompts the user to enter a secret other than the password, such as the
+// password of a backup. The prompt is formatted as "msg: ". If prompting is disabled,
+// ErrNoInput is returned. The secret must be wiped after use.
+func Secret(msg string) (secret.Bytes, error) {
+	if noInput {
+		return nil, ErrNoInput
+	}
+
+	return inSecret(msg)
 }
 
 // ConfigureAPIToken will prompt the user to enter an API token. If an empty value is
 // entered, it will loop until user enters a value. Once a valid API token is
-// entered, it will return it.
-func ConfigureAPIToken() string {
-	var token string
+// entered, it will return it, it must be wiped after use. If prompting is disabled,
+// ErrNoInput is returned.
+func ConfigureAPIToken() (secret.Bytes, error) {
+	if noInput {
+		return nil, ErrNoInput
+	}
 
 	for {
-		InString("API Token", &token)
-		token = strings.TrimSpace(token)
-		if token != "" {
-			break
+		token, err := inSecret("API Token")
+		if err != nil {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(token); len(trimmed) > 0 {
+			return trimmed, nil
 		}
 
+		token.Wipe()
 		fmt.Println("Token cannot be empty")
 	}
+}
 
-	return token
+// ConfigureServerURL will prompt the user to enter the URL of the Clox server. If an
+// empty value is entered, or prompting is disabled, def is returned.
+func ConfigureServerURL(def string) string {
+	if noInput {
+		return def
+	}
+
+	var server string
+	InString(fmt.Sprintf("Server URL (%s)", def), &server)
+
+	server = strings.TrimSpace(server)
+	if server == "" {
+		return def
+	}
+
+	return server
 }
 
 // ConfigurePassword will prompt the user to enter and confirm a password. If
-// passwords do not match, it will loop until user confirms a valid password. Once a
-// password is confirmed, it will be returned.
-func ConfigurePassowrd() string {
-	var pass string
-	var confirmPass string
+// passwords do not match, or the password is weak, it will loop until user confirms
+// a valid password. Why a password is weak is printed, see PasswordProblems. Once a
+// password is confirmed, it will be returned, it must be wiped after use.
+//
+// If a password was set with SetPassword it is returned without prompting, if it is
+// weak a *WeakPasswordError is returned instead. If prompting is disabled,
+// ErrNoInput is returned. Weak passwords are accepted if AllowWeakPasswords was
+// called.
+func ConfigurePassowrd() (secret.Bytes, error) {
+	if password != nil {
+		if err := checkPassword(password); err != nil {
+			return nil, err
+		}
+		return password, nil
+	}
+	if noInput {
+		return nil, ErrNoInput
+	}
+
+	return confirmPassword("Password", "Confirm Password")
+}
 
+// NewPassword will prompt the user to enter and confirm a new password, the same as
+// ConfigurePassowrd, a weak password is refused. The password set with SetPassword
+// is not used, it is the current password. If prompting is disabled, ErrNoInput is
+// returned.
+func NewPassword() (secret.Bytes, error) {
+	if noInput {
+		return nil, ErrNoInput
+	}
+
+	return confirmPassword("New Password", "Confirm New Password")
+}
+
+// confirmPassword prompts the user to enter a password with msg and confirm it with
+// confirmMsg, until the passwords match and it is not weak. The password must be
+// wiped after use, the other copies are wiped.
+func confirmPassword(msg string, confirmMsg string) (secret.Bytes, error) {
 	for {
-		InString("Password", &pass)
-		InString("Confirm Password", &confirmPass)
+		pass, err := inSecret(msg)
+		if err != nil {
+			return nil, err
+		}
+		confirmPass, err := inSecret(confirmMsg)
+		if err != nil {
+			pass.Wipe()
+			return nil, err
+		}
 
-		if pass == confirmPass {
-			break
+		match := pass.Equal(confirmPass)
+		confirmPass.Wipe()
+		if !match {
+			pass.Wipe()
+			fmt.Println("Passwords do not match")
+			continue
 		}
+		if err := checkPassword(pass); err != nil {
+			pass.Wipe()
+			printWeakPassword(err)
+			continue
+		}
+
+		return pass, nil
+	}
+}
+
+// Confirm prints msg as a yes or no question and returns true if the user answers
+// yes. Any answer other than "y" or "yes" is a no. The prompt is formatted as
+// "msg [y/N]: ".
+//
+// If yes is assumed, see AssumeYes, the answer is yes and the prompt is printed with
+// it. Otherwise, if prompting is disabled, the answer is no.
+func Confirm(msg string) bool {
+	if assumeYes {
+		fmt.Printf("%s [y/N]: y (--yes)\n", msg)
+		return true
+	}
+	if noInput {
+		fmt.Printf("%s [y/N]: n (--no-input)\n", msg)
+		return false
+	}
+
+	var answer string
+	InString(fmt.Sprintf("%s [y/N]", msg), &answer)
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
 
-		fmt.Println("Passwords do not match")
-		pass = ""
-		confirmPass = ""
+// ConfirmOverwrite asks the user to confirm that the local file at path is replaced,
+// see Confirm. If nothing exists at path, it returns true without asking.
+func ConfirmOverwrite(path string) bool {
+	if _, err := os.Lstat(path); err != nil {
+		return true
 	}
 
-	return pass
+	return Confirm(fmt.Sprintf("The file '%s' already exists, overwrite it?", path))
 }
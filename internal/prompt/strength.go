@@ -0,0 +1,172 @@
+package prompt
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+const (
+	// MinPasswordLength is the fewest characters a password that is not weak has.
+	MinPasswordLength = 12
+	// MinPasswordEntropy is the fewest bits of entropy, as estimated by
+	// PasswordProblems, a password that is not weak has.
+	MinPasswordEntropy = 50
+)
+
+// allowWeak is set by AllowWeakPasswords.
+var allowWeak bool
+
+// AllowWeakPasswords disables the strength check of ConfigurePassowrd and
+// NewPassword, for test environments.
+func AllowWeakPasswords() {
+	allowWeak = true
+}
+
+// WeakPasswordError is the error when a password is weak, see PasswordProblems.
+type WeakPasswordError struct {
+	// Problems are the reasons the password is weak.
+	Problems []string
+}
+
+func (e *WeakPasswordError) Error() string {
+	return "the password is too weak: " + strings.Join(e.Problems, ", ")
+}
+
+// checkPassword returns a *WeakPasswordError if the password is weak, unless weak
+// passwords are allowed.
+func checkPassword(p []byte) *WeakPasswordError {
+	if allowWeak {
+		return nil
+	}
+	if problems := PasswordProblems(p); len(problems) > 0 {
+		return &WeakPasswordError{Problems: problems}
+	}
+
+	return nil
+}
+
+// printWeakPassword prints why the password of the error is weak.
+func printWeakPassword(err *WeakPasswordError) {
+	fmt.Println("Password is too weak:")
+	for _, p := range err.Problems {
+		fmt.Printf("  - %s\n", p)
+	}
+}
+
+// PasswordProblems estimates the strength of the password, and returns the reasons
+// it is weak. A password is weak if it is shorter than MinPasswordLength, is one of
+// the common passwords or a common password with digits and symbols added, or has
+// less than MinPasswordEntropy bits of entropy. If the password is not weak, it
+// returns nil.
+//
+// The entropy is estimated from the classes of characters the password uses, a
+// character that repeats the one before it or continues a sequence, such as "abc"
+// or "321", is counted as one bit. The copies of the password that are made to
+// estimate it are wiped.
+func PasswordProblems(p []byte) []string {
+	var problems []string
+
+	if n := utf8.RuneCount(p); n < MinPasswordLength {
+		problems = append(problems, fmt.Sprintf("it has %d characters, at least %d are required", n, MinPasswordLength))
+	}
+	if isCommonPassword(p) {
+		problems = append(problems, "it is a common password")
+	}
+	if bits := passwordEntropy(p); bits < MinPasswordEntropy {
+		problems = append(problems, fmt.Sprintf("it is too predictable, about %d bits of entropy, at least %d are required", int(bits), MinPasswordEntropy))
+	}
+
+	return problems
+}
+
+// passwordEntropy estimates the bits of entropy of the password.
+func passwordEntropy(p []byte) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range bytes.Runes(p) {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, c := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if c.used {
+			pool += c.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	perChar := math.Log2(float64(pool))
+	var bits float64
+	folded := secret.Bytes(bytes.ToLower(p))
+	defer folded.Wipe()
+	runes := bytes.Runes(folded)
+	defer clear(runes)
+	for i, r := range runes {
+		if i > 0 {
+			d := r - runes[i-1]
+			if d == 0 || d == 1 || d == -1 {
+				bits++
+				continue
+			}
+		}
+		bits += perChar
+	}
+
+	return bits
+}
+
+// isCommonPassword checks if the password is a common password. Letter case is
+// ignored, as are digits and symbols at the start or end, so "Password123!" is
+// the common password "password".
+func isCommonPassword(p []byte) bool {
+	lower := secret.Bytes(bytes.ToLower(p))
+	defer lower.Wipe()
+	trimmed := bytes.TrimFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	return commonPasswords[string(lower)] || (len(trimmed) > 0 && commonPasswords[string(trimmed)])
+}
+
+// commonPasswords are the most common passwords found in breaches, and words that
+// are common in the passwords of the CLI.
+var commonPasswords = func() map[string]bool {
+	m := map[string]bool{}
+	for _, p := range strings.Fields(`
+		123456 123456789 12345678 1234567890 12345 1234567 111111 123123 000000 654321
+		password password1 passw0rd p@ssw0rd p@ssword qwerty qwertyuiop qwerty123
+		asdfghjkl zxcvbnm 1q2w3e4r 1qaz2wsx abc123 iloveyou admin administrator
+		welcome welcome1 letmein monkey dragon football baseball basketball soccer
+		master superman batman trustno1 sunshine princess shadow michael jennifer
+		jordan hunter freedom whatever starwars pokemon computer internet secret
+		changeme default login guest root test testing hello hellohello
+		charlie donald mustang access flower lovely loveme cookie summer winter
+		spring autumn michelle daniel thomas ashley hannah jessica liverpool chelsea
+		arsenal killer pepper ginger cheese butter chocolate banana orange
+		clox cloxcli clox-cli letmeinplease correcthorsebatterystaple
+	`) {
+		m[p] = true
+	}
+	return m
+}()
@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+)
+
+const (
+	metaExt    = ".json"
+	payloadExt = ".bin"
+)
+
+// Item is an upload staged in the Queue. The payload of the Item is the encrypted
+// contents of the file, ready to be uploaded.
+type Item struct {
+	// The ID of the Item within the Queue.
+	ID string `json:"id"`
+	// The local path of the file that was staged.
+	Source string `json:"source"`
+	// The name of the file on the server.
+	Filename string `json:"filename"`
+	// The MIME type of the plain text contents of the file.
+	ContentType string `json:"content_type,omitempty"`
+	// The checksum of the plain text contents of the file, see api.Checksummer.
+	Checksum string `json:"checksum,omitempty"`
+	// The directory on the server the file is uploaded to.
+	Dir api.Location `json:"dir"`
+	// The size of the encrypted payload.
+	Size int64 `json:"size"`
+	// The time the file was staged.
+	QueuedAt time.Time `json:"queued_at"`
+
+	payload string
+}
+
+// Payload returns the path to the encrypted payload of this Item.
+func (i Item) Payload() string {
+	return i.payload
+}
+
+// Queue stages encrypted uploads on the local file system, so they can be uploaded
+// when the server is reachable. Each Item is stored as a metadata file and a
+// payload file in Dir.
+type Queue struct {
+	// The directory the staged uploads are stored in.
+	Dir string
+}
+
+// Add stages the encrypted contents of the file at source to be uploaded as
+// filename to the directory dir. The contentType is the MIME type of the plain text
+// contents and checksum is their checksum, they cannot be worked out once the
+// contents are encrypted. The encrypted contents are written to the payload of the
+// Item by write, as they are encrypted, so they are never held in memory.
+func (q *Queue) Add(source string, filename string, contentType string, checksum string, dir api.Location, write func(w io.Writer) error) (Item, error) {
+	if err := os.MkdirAll(q.Dir, 0700); err != nil {
+		return Item{}, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Item{}, err
+	}
+
+	item := Item{
+		ID:          id,
+		Source:      source,
+		Filename:    filename,
+		ContentType: contentType,
+		Checksum:    checksum,
+		Dir:         dir,
+		QueuedAt:    time.Now(),
+		payload:     filepath.Join(q.Dir, id+payloadExt),
+	}
+
+	item.Size, err = writePayload(item.payload, write)
+	if err != nil {
+		os.Remove(item.payload)
+		return Item{}, fmt.Errorf("writing payload: %w", err)
+	}
+
+	meta, err := json.MarshalIndent(&item, "", "  ")
+	if err != nil {
+		os.Remove(item.payload)
+		return Item{}, fmt.Errorf("marshalling item: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(q.Dir, id+metaExt), meta, 0600); err != nil {
+		os.Remove(item.payload)
+		return Item{}, fmt.Errorf("writing item: %w", err)
+	}
+
+	return item, nil
+}
+
+// writePayload creates the payload file at path that only the user can read, and
+// writes it with write. It returns the size of the payload.
+func writePayload(path string, write func(w io.Writer) error) (int64, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, err
+	}
+
+	w := &countWriter{w: f}
+	if err := write(w); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+
+	return w.n, nil
+}
+
+// countWriter is an io.Writer that counts the bytes written to w.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Items returns every Item in the Queue, oldest first. If the Queue directory does
+// not exist, the Queue is empty.
+func (q *Queue) Items() ([]Item, error) {
+	entries, err := os.ReadDir(q.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Item{}, nil
+		}
+
+		return nil, err
+	}
+
+	items := []Item{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != metaExt {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(q.Dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var item Item
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("unmarshalling %s: %w", e.Name(), err)
+		}
+		item.ID = strings.TrimSuffix(e.Name(), metaExt)
+		item.payload = filepath.Join(q.Dir, item.ID+payloadExt)
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].QueuedAt.Before(items[j].QueuedAt) })
+
+	return items, nil
+}
+
+// Remove removes the Item and its payload from the Queue.
+func (q *Queue) Remove(item Item) error {
+	if err := os.Remove(filepath.Join(q.Dir, item.ID+metaExt)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(item.payload); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// newID generates a random ID for an Item.
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
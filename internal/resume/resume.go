@@ -0,0 +1,91 @@
+package resume
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/securefile"
+)
+
+// Session is an upload session of a local file that was not completed. It is kept
+// so the upload can be resumed by a later command.
+type Session struct {
+	// ID is the ID of the upload session on the server.
+	ID string `json:"id"`
+	// Local is the absolute path of the local file.
+	Local string `json:"local"`
+	// Dir is the directory the file is uploaded to, as a path or an ID.
+	Dir string `json:"dir"`
+	// Name is the name of the file on the server.
+	Name string `json:"name"`
+	// Size and ModTime are of the local file when the session was started. If the
+	// file changed since, the session cannot be resumed.
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Started time.Time `json:"started"`
+	// DataKey is the envelope header of the data key that the parts are encrypted
+	// with, see crypto.DataKey. A session started before files had a data key of
+	// their own has none, and cannot be resumed.
+	DataKey []byte `json:"data_key,omitempty"`
+}
+
+// Manifest is the set of Sessions of the uploads that can be resumed.
+type Manifest struct {
+	Sessions map[string]Session `json:"sessions"`
+}
+
+// New creates and returns an empty Manifest.
+func New() *Manifest {
+	return &Manifest{Sessions: map[string]Session{}}
+}
+
+// Load reads the manifest file at path and decrypts it with the password. If the
+// file does not exist, it returns an empty Manifest.
+func Load(path string, aes *crypto.AES, password []byte) (*Manifest, error) {
+	m := New()
+	if err := securefile.ReadJSON(path, aes, password, m); err != nil {
+		if errors.Is(err, securefile.ErrNotExist) {
+			return New(), nil
+		}
+
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Save encrypts this Manifest with the password and writes it to path.
+func (m *Manifest) Save(path string, aes *crypto.AES, password []byte) error {
+	return securefile.WriteJSON(path, aes, password, m)
+}
+
+// key returns the key of the Session of the upload of the local file to the dir
+// with the name.
+func key(local string, dir string, name string) string {
+	return local + "\x00" + dir + "\x00" + name
+}
+
+// Add adds the Session to this Manifest. It replaces any other Session of the same
+// upload.
+func (m *Manifest) Add(s Session) {
+	m.Sessions[key(s.Local, s.Dir, s.Name)] = s
+}
+
+// Find returns the Session of the upload of the local file to the dir with the
+// name. If there is none, or the local file no longer has the size and modTime of
+// the Session, it returns false.
+func (m *Manifest) Find(local string, dir string, name string, size int64, modTime time.Time) (Session, bool) {
+	s, ok := m.Sessions[key(local, dir, name)]
+	if !ok || s.Size != size || !s.ModTime.Equal(modTime) {
+		return Session{}, false
+	}
+
+	return s, true
+}
+
+// Remove removes the Session of the upload of the local file to the dir with the
+// name.
+func (m *Manifest) Remove(local string, dir string, name string) {
+	delete(m.Sessions, key(local, dir, name))
+}
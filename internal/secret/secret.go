@@ -0,0 +1,68 @@
+// Package secret holds sensitive material in memory that is wiped once it is no
+// longer needed, such as the encryption key, a decrypted private key, or the bytes
+// of a password that are passed to the key derivation function.
+//
+// A Go string cannot be wiped, and every conversion between a string and a []byte
+// copies it. Secrets are kept as Bytes from the point they are decrypted or read,
+// such as a password read from the terminal, so they can be wiped.
+package secret
+
+import (
+	"crypto/subtle"
+	"log/slog"
+)
+
+// redacted is what a Bytes is printed, logged, or marshalled as.
+const redacted = "[REDACTED]"
+
+// Bytes is sensitive material that can be wiped with Wipe. It can be passed
+// wherever a []byte is expected without a copy.
+//
+// A Bytes is never printed, logged, or marshalled, it is replaced with
+// "[REDACTED]", so it cannot leak into the output by mistake.
+type Bytes []byte
+
+// FromString returns a copy of s as Bytes, for a secret that is only available as a
+// string, such as an environment variable. The copy must be wiped after use.
+func FromString(s string) Bytes {
+	return Bytes(s)
+}
+
+// Wipe overwrites the Bytes with zeros. The Bytes must not be used after, a slice
+// that shares its memory is wiped with it.
+func (b Bytes) Wipe() {
+	clear(b)
+}
+
+// Equal checks if the Bytes are the same as o, in constant time.
+func (b Bytes) Equal(o []byte) bool {
+	return subtle.ConstantTimeCompare(b, o) == 1
+}
+
+// String returns "[REDACTED]", so the Bytes are not printed.
+func (b Bytes) String() string {
+	return redacted
+}
+
+// GoString returns "[REDACTED]", so the Bytes are not printed with %#v.
+func (b Bytes) GoString() string {
+	return redacted
+}
+
+// LogValue returns "[REDACTED]", so the Bytes are not logged.
+func (b Bytes) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// MarshalJSON returns "[REDACTED]", so the Bytes are not written to a file.
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
+
+// Wipe overwrites every buffer with zeros, such as the plain text of a secret that
+// is not held as Bytes.
+func Wipe(bufs ...[]byte) {
+	for _, b := range bufs {
+		clear(b)
+	}
+}
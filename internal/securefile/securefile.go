@@ -0,0 +1,84 @@
+package securefile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// ErrNotExist is the error when the file does not exist.
+var ErrNotExist = errors.New("file does not exist")
+
+// ReadJSON reads the file at path, decrypts it with the password, and unmarshals
+// the JSON into dst. The decrypted JSON is wiped after. If the file does not exist,
+// it returns ErrNotExist.
+func ReadJSON(path string, aes *crypto.AES, password []byte, dst any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotExist
+		}
+
+		return err
+	}
+
+	decrypted, err := aes.DecryptWithPassword(data, password)
+	if err != nil {
+		return fmt.Errorf("decrypting %s: %w", path, err)
+	}
+	defer decrypted.Wipe()
+
+	if err := json.Unmarshal(decrypted, dst); err != nil {
+		return fmt.Errorf("unmarshalling %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Reencrypt decrypts the file at path with oldPassword and writes it encrypted with
+// newPassword. The contents are not changed. If the file does not exist, it returns
+// ErrNotExist.
+func Reencrypt(path string, aes *crypto.AES, oldPassword []byte, newPassword []byte) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotExist
+		}
+
+		return err
+	}
+
+	decrypted, err := aes.DecryptWithPassword(data, oldPassword)
+	if err != nil {
+		return fmt.Errorf("decrypting %s: %w", path, err)
+	}
+	defer decrypted.Wipe()
+
+	encrypted, err := aes.EncryptWithPassword(decrypted, newPassword)
+	if err != nil {
+		return fmt.Errorf("encrypting %s: %w", path, err)
+	}
+
+	return os.WriteFile(path, encrypted, 0600)
+}
+
+// WriteJSON marshals v to JSON, encrypts it with the password, and writes it to the
+// file at path. Only the user can read or write the file.
+func WriteJSON(path string, aes *crypto.AES, password []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshalling %s: %w", path, err)
+	}
+	defer secret.Wipe(data)
+
+	encrypted, err := aes.EncryptWithPassword(data, password)
+	if err != nil {
+		return fmt.Errorf("encrypting %s: %w", path, err)
+	}
+
+	return os.WriteFile(path, encrypted, 0600)
+}
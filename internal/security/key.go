@@ -6,8 +6,12 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
 
 	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/secret"
 )
 
 // Keys manages the RSA (Rivest–Shamir–Adleman) key pairs.
@@ -22,7 +26,7 @@ type Keys struct {
 // GenerateWithPassword generates a password-encrypted RSA key pair. Only the private
 // key is password protected. The first []byte returned is the private key, the second
 // is the public key.
-func (k *Keys) GenerateWithPassword(password string) ([]byte, []byte, error) {
+func (k *Keys) GenerateWithPassword(password []byte) ([]byte, []byte, error) {
 	privKey, err := generateRSAKeyPair()
 	if err != nil {
 		return nil, nil, err
@@ -39,35 +43,240 @@ func (k *Keys) GenerateWithPassword(password string) ([]byte, []byte, error) {
 	return privKeyEncryted, pubKeyPEM, nil
 }
 
-// encryptPrivateKey encrypts the private key with the password.
-func (k *Keys) encryptPrivateKey(priv *rsa.PrivateKey, password string) ([]byte, error) {
-	privBytes := x509.MarshalPKCS1PrivateKey(priv)
-	encrypted, err := k.AES.EncryptWithPassword(privBytes, []byte(password))
+// ImportWithPassword encrypts the existing private key with the password, in the
+// format of GenerateWithPassword. The first []byte returned is the private key, the
+// second is the public key.
+func (k *Keys) ImportWithPassword(priv *rsa.PrivateKey, password []byte) ([]byte, []byte, error) {
+	privKeyEncrypted, err := k.encryptPrivateKey(priv, password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return privKeyEncrypted, k.EncodePublicKey(&priv.PublicKey), nil
+}
+
+// MinKeySize is the smallest RSA key, in bits, that can be imported. It is the size
+// of the keys that GenerateWithPassword generates.
+const MinKeySize = 2048
+
+// ParsePrivateKey parses a PEM encoded RSA private key that is not encrypted, in the
+// PKCS #1 ("RSA PRIVATE KEY") or PKCS #8 ("PRIVATE KEY") format. The key is
+// validated, and must be at least MinKeySize bits.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block containing the key")
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" || block.Headers["Proc-Type"] != "" {
+		return nil, errors.New("the private key is encrypted, decrypt it first, such as with 'openssl pkey -in <file> -out <file>'")
+	}
+
+	var priv *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		priv = key
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("the private key is not an RSA key")
+		}
+		priv = rsaKey
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q, must be \"RSA PRIVATE KEY\" or \"PRIVATE KEY\"", block.Type)
+	}
+
+	if err := priv.Validate(); err != nil {
+		return nil, err
+	}
+	if priv.N.BitLen() < MinKeySize {
+		return nil, fmt.Errorf("the private key is %d bits, it must be at least %d bits", priv.N.BitLen(), MinKeySize)
+	}
+
+	return priv, nil
+}
+
+// ParsePublicKey parses a PEM encoded RSA public key, in the PKCS #1
+// ("RSA PUBLIC KEY") or PKIX ("PUBLIC KEY") format.
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block containing the key")
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("the public key is not an RSA key")
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q, must be \"RSA PUBLIC KEY\" or \"PUBLIC KEY\"", block.Type)
+	}
+}
+
+// PrivateKeyVersion is the version of the format that private keys are encrypted in.
+//
+// Version 1 is a PKCS #1 key, encrypted with the password and stored in a PEM block
+// of type "RSA PRIVATE KEY" with no headers. Version 2 is a PKCS #8 key, encrypted
+// the same and stored in a PEM block of type privateKeyType, with headers that
+// declare the version, the format of the key, and the cipher and KDF it is encrypted
+// with. Both are read, keys are always encrypted in the current version.
+const PrivateKeyVersion = 2
+
+const (
+	// privateKeyType is the PEM block type of an encrypted private key of version 2
+	// or later.
+	privateKeyType = "CLOX ENCRYPTED PRIVATE KEY"
+	// legacyPrivateKeyType is the PEM block type of an encrypted private key of
+	// version 1.
+	legacyPrivateKeyType = "RSA PRIVATE KEY"
+	// pkcs8Format is the format header of a PKCS #8 private key.
+	pkcs8Format = "PKCS8"
+)
+
+// EncryptedKeyVersion returns the version of the format that the encrypted private
+// key is stored in, see PrivateKeyVersion.
+func EncryptedKeyVersion(encryptedKey string) (int, error) {
+	block, _ := pem.Decode([]byte(encryptedKey))
+	if block == nil {
+		return 0, errors.New("failed to decode PEM block containing encrypted key")
+	}
+
+	switch block.Type {
+	case legacyPrivateKeyType:
+		return 1, nil
+	case privateKeyType:
+		v, err := strconv.Atoi(block.Headers["Version"])
+		if err != nil || v < 2 {
+			return 0, fmt.Errorf("invalid encrypted key version %q", block.Headers["Version"])
+		}
+		return v, nil
+	default:
+		return 0, fmt.Errorf("unsupported PEM block type %q of encrypted key", block.Type)
+	}
+}
+
+// encryptPrivateKey encrypts the private key with the password, in the format of
+// PrivateKeyVersion. The encoded key is wiped after use.
+func (k *Keys) encryptPrivateKey(priv *rsa.PrivateKey, password []byte) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
 	if err != nil {
 		return nil, err
 	}
+	privBytes := secret.Bytes(der)
+	defer privBytes.Wipe()
 
-	return encodePEM("RSA PRIVATE KEY", encrypted), nil
+	encrypted, err := k.AES.EncryptWithPassword(privBytes, password)
+	if err != nil {
+		return nil, err
+	}
+	kdf, cipher, ok := crypto.ParsePasswordHeader(encrypted)
+	if !ok {
+		return nil, errors.New("encrypted key has no password header")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type: privateKeyType,
+		Headers: map[string]string{
+			"Version": strconv.Itoa(PrivateKeyVersion),
+			"Format":  pkcs8Format,
+			"Cipher":  cipher,
+			"KDF":     kdf.String(),
+		},
+		Bytes: encrypted,
+	}), nil
 }
 
 // DecryptPrivateKey decrypts the key with password and returns it as a *rsa.PrivateKey.
-func (k *Keys) DecryptPrivateKey(encryptedKey, password string) (*rsa.PrivateKey, error) {
+// The key can be in any version of the format, see PrivateKeyVersion. The cipher and
+// KDF that a key of version 2 declares must be the ones it is encrypted with. The
+// decrypted encoding of the key is wiped once it is parsed, the key should be wiped
+// with WipePrivateKey after use.
+func (k *Keys) DecryptPrivateKey(encryptedKey string, password []byte) (*rsa.PrivateKey, error) {
 	block, _ := pem.Decode([]byte(encryptedKey))
-	if block == nil || block.Type != "RSA PRIVATE KEY" {
+	if block == nil {
 		return nil, errors.New("failed to decode PEM block containing encrypted key")
 	}
+	version, err := EncryptedKeyVersion(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	if version > PrivateKeyVersion {
+		return nil, fmt.Errorf("encrypted key version %d is not supported by this version of the CLI (%d), upgrade the CLI", version, PrivateKeyVersion)
+	}
+	if version > 1 {
+		if err := checkKeyHeaders(block); err != nil {
+			return nil, err
+		}
+	}
+
+	decrypted, err := k.AES.DecryptWithPassword(block.Bytes, password)
+	if err != nil {
+		return nil, err
+	}
+	defer decrypted.Wipe()
 
-	decrypted, err := k.AES.DecryptWithPassword(block.Bytes, []byte(password))
+	if version == 1 {
+		return x509.ParsePKCS1PrivateKey(decrypted)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(decrypted)
 	if err != nil {
 		return nil, err
 	}
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key is not an RSA key")
+	}
+
+	return priv, nil
+}
+
+// checkKeyHeaders checks that the headers of the PEM block of an encrypted private
+// key of version 2 declare a PKCS #8 key, and the cipher and KDF that the key is
+// encrypted with.
+func checkKeyHeaders(block *pem.Block) error {
+	if f := block.Headers["Format"]; f != pkcs8Format {
+		return fmt.Errorf("unsupported encrypted key format %q", f)
+	}
+
+	kdf, cipher, ok := crypto.ParsePasswordHeader(block.Bytes)
+	if !ok {
+		return errors.New("encrypted key has no password header")
+	}
+	if block.Headers["Cipher"] != cipher || block.Headers["KDF"] != kdf.String() {
+		return errors.New("the cipher or kdf of the encrypted key does not match its headers")
+	}
+
+	return nil
+}
 
-	privKey, err := x509.ParsePKCS1PrivateKey(decrypted)
+// ReencryptPrivateKey decrypts the key with oldPassword and encrypts it with
+// newPassword. The encrypted key is returned in the same format as
+// GenerateWithPassword, a key of an older version is upgraded to PrivateKeyVersion.
+func (k *Keys) ReencryptPrivateKey(encryptedKey string, oldPassword []byte, newPassword []byte) ([]byte, error) {
+	priv, err := k.DecryptPrivateKey(encryptedKey, oldPassword)
 	if err != nil {
 		return nil, err
 	}
+	defer WipePrivateKey(priv)
 
-	return privKey, nil
+	return k.encryptPrivateKey(priv, newPassword)
 }
 
 // DecodePublicKey will decode the key and return it s a *rsa.PublicKey.
@@ -80,6 +289,26 @@ func (k *Keys) DecodePublicKey(encodedKey []byte) (*rsa.PublicKey, error) {
 	return x509.ParsePKCS1PublicKey(block.Bytes)
 }
 
+// WipePrivateKey overwrites the private exponent, the primes, and the precomputed
+// values of the private key with zeros. The key cannot be used after, the public
+// key is kept.
+func WipePrivateKey(priv *rsa.PrivateKey) {
+	if priv == nil {
+		return
+	}
+
+	ints := append([]*big.Int{priv.D, priv.Precomputed.Dp, priv.Precomputed.Dq, priv.Precomputed.Qinv}, priv.Primes...)
+	for _, crt := range priv.Precomputed.CRTValues {
+		ints = append(ints, crt.Exp, crt.Coeff, crt.R)
+	}
+	for _, n := range ints {
+		if n != nil {
+			clear(n.Bits())
+			n.SetInt64(0)
+		}
+	}
+}
+
 // generateRSAKeyPair generates a RSA key pair.
 func generateRSAKeyPair() (*rsa.PrivateKey, error) {
 	return rsa.GenerateKey(rand.Reader, 2048)
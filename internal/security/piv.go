@@ -0,0 +1,173 @@
+package security
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultPIVSlot is the PIV slot of the key that the encryption key is encrypted for,
+// the key management slot.
+const DefaultPIVSlot = "9d"
+
+// pivSlotIDs are the PKCS #11 object IDs of the PIV slots that hold RSA keys, as the
+// OpenSC PKCS #11 module exposes them.
+var pivSlotIDs = map[string]string{"9a": "01", "9c": "02", "9d": "03", "9e": "04"}
+
+// pkcs11Modules are the paths the OpenSC PKCS #11 module is installed at, searched in
+// order when no module is set.
+var pkcs11Modules = []string{
+	"/usr/lib/x86_64-linux-gnu/opensc-pkcs11.so",
+	"/usr/lib/aarch64-linux-gnu/opensc-pkcs11.so",
+	"/usr/lib64/opensc-pkcs11.so",
+	"/usr/lib/opensc-pkcs11.so",
+	"/usr/local/lib/opensc-pkcs11.so",
+	"/opt/homebrew/lib/opensc-pkcs11.so",
+	"/Library/OpenSC/lib/opensc-pkcs11.so",
+	`C:\Windows\System32\opensc-pkcs11.dll`,
+}
+
+// ErrNoPIVModule is the error when the OpenSC PKCS #11 module is not installed at a
+// known path and no module is set.
+var ErrNoPIVModule = errors.New("the OpenSC PKCS #11 module was not found, install OpenSC or set the module")
+
+// ValidatePIVSlot checks if slot is a PIV slot that can hold the key, 9a, 9c, 9d or
+// 9e.
+func ValidatePIVSlot(slot string) error {
+	if _, ok := pivSlotIDs[slot]; !ok {
+		return fmt.Errorf("invalid piv slot '%s', must be 9a, 9c, 9d, or 9e", slot)
+	}
+
+	return nil
+}
+
+// PIV is the KeyProvider of a private key on a PIV hardware token, such as a
+// YubiKey. The private key never leaves the token, the ciphertext is decrypted on
+// it with the pkcs11-tool program of OpenSC, which must be installed.
+//
+// The token asks for its PIN, and to be touched if its touch policy requires it,
+// every time a ciphertext is decrypted. The PIN is read by pkcs11-tool from the
+// terminal, it never passes through the CLI.
+type PIV struct {
+	// Slot is the PIV slot of the key. If it is not set, DefaultPIVSlot is used.
+	Slot string
+	// Module is the path of the PKCS #11 module of the token. If it is not set, the
+	// OpenSC module is searched for at the paths it is installed at.
+	Module string
+}
+
+// Available checks if pkcs11-tool and the PKCS #11 module are installed.
+func (p *PIV) Available() bool {
+	if _, err := exec.LookPath("pkcs11-tool"); err != nil {
+		return false
+	}
+
+	_, err := p.module()
+	return err == nil
+}
+
+// PublicKey reads the public key of the slot from the token.
+func (p *PIV) PublicKey() (*rsa.PublicKey, error) {
+	out, err := p.run(false, "--read-object", "--type", "pubkey")
+	if err != nil {
+		return nil, err
+	}
+
+	if pub, err := x509.ParsePKIXPublicKey(out); err == nil {
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("the key in piv slot %s is not an RSA key", p.slot())
+		}
+
+		return rsaPub, nil
+	}
+
+	return x509.ParsePKCS1PublicKey(out)
+}
+
+// Decrypt decrypts the ciphertext with the key of the slot on the token, with
+// PKCS #1 v1.5 padding, the padding of crypto.RSA. The user is prompted for the PIN
+// of the token.
+func (p *PIV) Decrypt(ciphertext []byte) ([]byte, error) {
+	in, err := os.CreateTemp("", "clox-piv-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(in.Name())
+
+	// The ciphertext is not secret, it is stored in the configuration file.
+	if _, err := in.Write(ciphertext); err != nil {
+		in.Close()
+		return nil, err
+	}
+	if err := in.Close(); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(os.Stderr, "Unlocking the key on the security key, touch it if it blinks")
+	return p.run(true, "--login", "--login-type", "user", "--decrypt", "--mechanism", "RSA-PKCS", "--input-file", in.Name())
+}
+
+// slot returns the PIV slot of the key.
+func (p *PIV) slot() string {
+	if p.Slot == "" {
+		return DefaultPIVSlot
+	}
+
+	return p.Slot
+}
+
+// module returns the path of the PKCS #11 module.
+func (p *PIV) module() (string, error) {
+	if p.Module != "" {
+		return p.Module, nil
+	}
+
+	for _, m := range pkcs11Modules {
+		if _, err := os.Stat(m); err == nil {
+			return m, nil
+		}
+	}
+
+	return "", ErrNoPIVModule
+}
+
+// run runs pkcs11-tool for the key of the slot with args, and returns its standard
+// output. If interactive is set, it is given standard input and its standard error is
+// shown, so it can prompt for the PIN. If pkcs11-tool fails, the error contains its
+// standard error.
+func (p *PIV) run(interactive bool, args ...string) ([]byte, error) {
+	id, ok := pivSlotIDs[p.slot()]
+	if !ok {
+		return nil, ValidatePIVSlot(p.slot())
+	}
+	module, err := p.module()
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("pkcs11-tool", append([]string{"--module", module, "--id", id}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if interactive {
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	}
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("pkcs11-tool: %w: %s", err, msg)
+		}
+
+		return nil, fmt.Errorf("pkcs11-tool: %w", err)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package security
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+
+	"github.com/cicconee/clox-cli/internal/crypto"
+)
+
+// KeyProvider holds the RSA private key that the encryption key of a profile is
+// encrypted for. The private key is used through the KeyProvider, so it can be kept
+// where it cannot be read, such as on a hardware token.
+type KeyProvider interface {
+	// PublicKey returns the public key of the private key.
+	PublicKey() (*rsa.PublicKey, error)
+
+	// Decrypt decrypts the ciphertext with the private key. The ciphertext was
+	// encrypted for the public key with crypto.RSA.
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// PasswordKey is the KeyProvider of a private key that is stored encrypted with a
+// password, in the format of Keys.GenerateWithPassword.
+type PasswordKey struct {
+	Keys *Keys
+	RSA  *crypto.RSA
+	// EncryptedKey is the encrypted private key.
+	EncryptedKey string
+	// Password is the password that the private key is encrypted with.
+	Password []byte
+}
+
+// PublicKey decrypts the private key and returns its public key.
+func (k *PasswordKey) PublicKey() (*rsa.PublicKey, error) {
+	priv, err := k.Keys.DecryptPrivateKey(k.EncryptedKey, k.Password)
+	if err != nil {
+		return nil, err
+	}
+	pub := priv.PublicKey
+	WipePrivateKey(priv)
+
+	return &pub, nil
+}
+
+// Decrypt decrypts the private key with the password and decrypts the ciphertext
+// with it. The private key is wiped after use.
+func (k *PasswordKey) Decrypt(ciphertext []byte) ([]byte, error) {
+	priv, err := k.Keys.DecryptPrivateKey(k.EncryptedKey, k.Password)
+	if err != nil {
+		return nil, err
+	}
+	defer WipePrivateKey(priv)
+
+	return k.RSA.Decrypt(ciphertext, priv)
+}
+
+// EncodePublicKey encodes the public key in the format of GenerateWithPassword, it
+// is decoded by DecodePublicKey.
+func (k *Keys) EncodePublicKey(pub *rsa.PublicKey) []byte {
+	return encodePEM("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(pub))
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the data, as "SHA256:" and the hex
+// encoded digest.
+func Fingerprint(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "SHA256:" + hex.EncodeToString(sum[:])
+}
+
+// PublicKeyFingerprint returns the Fingerprint of the public key in the PKIX, DER
+// encoded, format. It is the same as the SHA-256 digest of the key printed by
+// 'openssl pkey -pubin -outform DER', so it can be checked without the CLI.
+func PublicKeyFingerprint(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	return Fingerprint(der), nil
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package session
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// private checks that the file or directory at path, with the info fi from
+// os.Lstat, is owned by the user, is not a symbolic link, and cannot be read or
+// written by other users.
+func private(path string, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || int(st.Uid) != os.Getuid() || fi.Mode()&os.ModeSymlink != 0 || fi.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%s: %w", path, ErrInsecure)
+	}
+
+	return nil
+}
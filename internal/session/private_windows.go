@@ -0,0 +1,39 @@
+//go:build windows
+
+package session
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// private checks that the file or directory at path, with the info fi from
+// os.Lstat, is owned by the user and is not a symbolic link. The permissions of
+// other users are not checked, the session directory is below the local
+// application data directory of the user, which only the user can access.
+func private(path string, fi os.FileInfo) error {
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s: %w", path, ErrInsecure)
+	}
+
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return fmt.Errorf("reading owner of %s: %w", path, err)
+	}
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return fmt.Errorf("reading owner of %s: %w", path, err)
+	}
+
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return fmt.Errorf("reading current user: %w", err)
+	}
+	if !owner.Equals(user.User.Sid) {
+		return fmt.Errorf("%s: %w", path, ErrInsecure)
+	}
+
+	return nil
+}
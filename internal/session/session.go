@@ -0,0 +1,306 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/cicconee/clox-cli/internal/secret"
+)
+
+// MaxTTL is the longest time a Session can be kept.
+const MaxTTL = 24 * time.Hour
+
+var (
+	// ErrNotExist is the error when there is no Session, or it has expired.
+	ErrNotExist = errors.New("no session")
+	// ErrInsecure is the error when a session file or its directory is not private
+	// to the user, such as one owned by another user, readable by other users, or a
+	// symbolic link.
+	ErrInsecure = errors.New("session file is not private to the user")
+)
+
+// keyFile is the name of the file in the directory of a profile that holds the key
+// the password of its Session is encrypted with.
+const keyFile = "session.key"
+
+// Session is an unlocked password that is kept until it expires, so the commands of
+// a profile are not prompted for the password.
+//
+// A Session is stored in a file that only the user can read, in the runtime
+// directory of the user (XDG_RUNTIME_DIR) or the temporary directory. The password
+// is encrypted with AES-GCM under a random key that is created for the Session and
+// kept in the directory of the profile, see KeyPath, so the session file never holds
+// the password. The expiry is authenticated with the password, it cannot be
+// extended. A Session never outlives ExpiresAt, and the runtime directory is
+// cleared when the user logs out.
+//
+// The Password is a secret, it must be wiped after use.
+type Session struct {
+	Password  secret.Bytes
+	ExpiresAt time.Time
+}
+
+// file is a Session as it is stored in the session file.
+type file struct {
+	Nonce     []byte    `json:"nonce"`
+	Password  []byte    `json:"password"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired checks if this Session is no longer valid.
+func (s Session) Expired() bool {
+	return !time.Now().Before(s.ExpiresAt)
+}
+
+// Path returns the path to the session file of the profile. The sessions of each
+// configuration directory are kept apart, configDir is the path to the .clox
+// directory.
+func Path(configDir string, profile string) string {
+	sum := sha256.Sum256([]byte(configDir))
+	return filepath.Join(dir(), "clox-"+hex.EncodeToString(sum[:6]), profile+".session")
+}
+
+// KeyPath returns the path to the file that holds the key of the session of the
+// profile, profileDir is the path to the directory of the profile.
+func KeyPath(profileDir string) string {
+	return filepath.Join(profileDir, keyFile)
+}
+
+// dir returns the directory the session directories are created in.
+func dir() string {
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" {
+		return d
+	}
+	if runtime.GOOS == "windows" {
+		if d, err := os.UserCacheDir(); err == nil {
+			return d
+		}
+		return os.TempDir()
+	}
+
+	return tempDir()
+}
+
+// tempDir returns the directory of the user in the temporary directory, that the
+// session directories are created in when there is no runtime directory. Unlike the
+// runtime directory, it is created by the CLI, and it is checked the same as the
+// session directories, see sessionDirs.
+func tempDir() string {
+	return filepath.Join(os.TempDir(), "clox-"+strconv.Itoa(os.Getuid()))
+}
+
+// sessionDirs returns the directories of the session file at path that must be
+// private to the user, from the outermost: the directory of the user in the
+// temporary directory if the session is in it, and the directory of the session file.
+func sessionDirs(path string) []string {
+	d := filepath.Dir(path)
+	if runtime.GOOS != "windows" && filepath.Dir(d) == tempDir() {
+		return []string{filepath.Dir(d), d}
+	}
+
+	return []string{d}
+}
+
+// Save writes a Session with the password that expires after ttl to the file at
+// path, and its key to the file at keyPath. The files and the directories of the
+// session file, see sessionDirs, can only be read by the user, the directories are
+// created if they do not exist. If a directory exists and is not private to the
+// user, ErrInsecure is returned and nothing is written. The files are replaced
+// atomically, a command never reads half of one.
+func Save(path string, keyPath string, password []byte, ttl time.Duration) (*Session, error) {
+	if ttl < time.Second || ttl > MaxTTL {
+		return nil, fmt.Errorf("ttl must be between 1s and %s", MaxTTL)
+	}
+
+	for _, d := range sessionDirs(path) {
+		if err := privateDir(d); err != nil {
+			return nil, err
+		}
+	}
+
+	key := make(secret.Bytes, 32)
+	defer key.Wipe()
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("creating session key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{Password: password, ExpiresAt: time.Now().Add(ttl).UTC()}
+	f := file{Nonce: make([]byte, gcm.NonceSize()), ExpiresAt: s.ExpiresAt}
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return nil, fmt.Errorf("creating session nonce: %w", err)
+	}
+	f.Password = gcm.Seal(nil, f.Nonce, password, expiry(f.ExpiresAt))
+
+	data, err := json.Marshal(&f)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling session: %w", err)
+	}
+
+	if err := writeFile(keyPath, key); err != nil {
+		return nil, fmt.Errorf("writing session key: %w", err)
+	}
+	if err := writeFile(path, data); err != nil {
+		return nil, fmt.Errorf("writing session file: %w", err)
+	}
+
+	return s, nil
+}
+
+// Load reads the Session in the file at path, and decrypts its password with the key
+// in the file at keyPath. If either file does not exist or the Session has expired,
+// it returns ErrNotExist and an expired file is removed. If a file or the directory
+// of the session file is not private to the user, the session file is removed and
+// ErrInsecure is returned.
+func Load(path string, keyPath string) (*Session, error) {
+	if err := checkFiles(path, keyPath); err != nil {
+		if errors.Is(err, ErrInsecure) {
+			Remove(path, keyPath)
+		}
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("unmarshalling session: %w", err)
+	}
+	if len(f.Nonce) == 0 || !time.Now().Before(f.ExpiresAt) {
+		// A session file without a nonce was written by an earlier version, with the
+		// password in plain text.
+		Remove(path, keyPath)
+		return nil, ErrNotExist
+	}
+
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	defer secret.Wipe(key)
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("session file is corrupt")
+	}
+	password, err := gcm.Open(nil, f.Nonce, f.Password, expiry(f.ExpiresAt))
+	if err != nil {
+		// The key belongs to a newer session of another configuration directory, or
+		// the file was modified.
+		Remove(path, keyPath)
+		return nil, ErrNotExist
+	}
+
+	return &Session{Password: password, ExpiresAt: f.ExpiresAt}, nil
+}
+
+// Remove removes the session file at path and its key at keyPath. Removing a Session
+// that does not exist is not an error.
+func Remove(path string, keyPath string) error {
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	err = os.Remove(keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// newGCM returns the AES-GCM cipher of the session key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating session cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// expiry returns the expiry of a session as the additional data of its password.
+func expiry(t time.Time) []byte {
+	return []byte(t.UTC().Format(time.RFC3339Nano))
+}
+
+// privateDir checks that the directory at path is private to the user, or creates
+// it if it does not exist.
+func privateDir(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.Mkdir(path, 0700); err != nil {
+			return fmt.Errorf("creating session directory: %w", err)
+		}
+		fi, err = os.Lstat(path)
+	}
+	if err != nil {
+		return fmt.Errorf("checking session directory: %w", err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s: %w", path, ErrInsecure)
+	}
+
+	return private(path, fi)
+}
+
+// checkFiles checks that the directories of the session file, see sessionDirs, the
+// session file, and the key file all exist and are private to the user.
+func checkFiles(path string, keyPath string) error {
+	for _, p := range append(sessionDirs(path), path, keyPath) {
+		fi, err := os.Lstat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotExist
+		}
+		if err != nil {
+			return err
+		}
+		if (p == path || p == keyPath) && !fi.Mode().IsRegular() {
+			return fmt.Errorf("%s: %w", p, ErrInsecure)
+		}
+		if err := private(p, fi); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes data to a new file in the directory of path that only the user
+// can read, and renames it to path.
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
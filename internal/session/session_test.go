@@ -0,0 +1,138 @@
+//go:build !windows
+
+package session
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPaths sets the temporary directory to a new directory without a runtime
+// directory, and returns the session path and key path of a profile.
+func testPaths(t *testing.T) (string, string, string) {
+	t.Helper()
+	tmp := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("TMPDIR", tmp)
+
+	profileDir := t.TempDir()
+	return tmp, Path("/home/user/.clox", "default"), KeyPath(profileDir)
+}
+
+func TestPathWithoutRuntimeDir(t *testing.T) {
+	tmp, path, _ := testPaths(t)
+
+	userDir := filepath.Join(tmp, "clox-"+strconv.Itoa(os.Getuid()))
+	if filepath.Dir(filepath.Dir(path)) != userDir {
+		t.Errorf("Path() = %s, want it in %s", path, userDir)
+	}
+	if !strings.HasPrefix(filepath.Base(filepath.Dir(path)), "clox-") || filepath.Base(path) != "default.session" {
+		t.Errorf("Path() = %s, want clox-<hash>/default.session", path)
+	}
+	if other := Path("/home/other/.clox", "default"); other == path {
+		t.Errorf("Path() of another configuration directory = %s, want another path", other)
+	}
+
+	// Neither directory exists until a session is saved.
+	if _, err := os.Stat(userDir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Stat(%s) = %v, want it to not exist", userDir, err)
+	}
+	if _, err := Save(path, KeyPath(t.TempDir()), []byte("password"), time.Minute); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+	for _, d := range []string{userDir, filepath.Dir(path)} {
+		fi, err := os.Lstat(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.IsDir() || fi.Mode().Perm() != 0700 {
+			t.Errorf("%s mode = %v, want a 0700 directory", d, fi.Mode())
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	_, path, keyPath := testPaths(t)
+	password := []byte("correct horse")
+
+	saved, err := Save(path, keyPath, password, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, password) {
+		t.Error("session file holds the password in plain text")
+	}
+
+	s, err := Load(path, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Password.Equal(password) {
+		t.Error("Load() did not return the password")
+	}
+	if !s.ExpiresAt.Equal(saved.ExpiresAt) {
+		t.Errorf("ExpiresAt = %s, want %s", s.ExpiresAt, saved.ExpiresAt)
+	}
+
+	if err := Remove(path, keyPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, keyPath); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Load() after Remove() = %v, want ErrNotExist", err)
+	}
+}
+
+func TestSaveInsecureDir(t *testing.T) {
+	tmp, path, keyPath := testPaths(t)
+
+	// The directory of the user in the temporary directory can be created by anyone
+	// before the CLI does.
+	userDir := filepath.Join(tmp, "clox-"+strconv.Itoa(os.Getuid()))
+	if err := os.Mkdir(userDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Save(path, keyPath, []byte("password"), time.Minute); !errors.Is(err, ErrInsecure) {
+		t.Errorf("Save() in a shared directory = %v, want ErrInsecure", err)
+	}
+	if _, err := os.Stat(keyPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Save() wrote the key file, %v", err)
+	}
+
+	if err := os.Chmod(userDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Save(path, keyPath, []byte("password"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(userDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, keyPath); !errors.Is(err, ErrInsecure) {
+		t.Errorf("Load() in a shared directory = %v, want ErrInsecure", err)
+	}
+}
+
+func TestLoadExpired(t *testing.T) {
+	_, path, keyPath := testPaths(t)
+	if _, err := Save(path, keyPath, []byte("password"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	if _, err := Load(path, keyPath); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Load() of an expired session = %v, want ErrNotExist", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the expired session file was not removed, %v", err)
+	}
+}
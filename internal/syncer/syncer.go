@@ -0,0 +1,265 @@
+package syncer
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/ignore"
+	"github.com/cicconee/clox-cli/internal/partial"
+	"github.com/cicconee/clox-cli/internal/tracking"
+)
+
+// Action is what is done with a file to bring the local and remote directories up
+// to date.
+type Action int
+
+const (
+	// Upload encrypts the local file and writes it to the server.
+	Upload Action = iota
+	// Download decrypts the file on the server and writes it to the local
+	// directory.
+	Download
+	// Conflict is a file that changed on both sides, it is left as it is.
+	Conflict
+)
+
+// String returns the name of this Action.
+func (a Action) String() string {
+	switch a {
+	case Upload:
+		return "upload"
+	case Download:
+		return "download"
+	case Conflict:
+		return "conflict"
+	default:
+		return "unknown"
+	}
+}
+
+// Local is a file in the local directory.
+type Local struct {
+	// Path is the path of the file relative to the local directory, separated by
+	// slashes.
+	Path string
+	// FullPath is the path of the file on the file system.
+	FullPath string
+	Size     int64
+	ModTime  time.Time
+}
+
+// Remote is the remote directory and everything below it. The directories and
+// files are keyed by their path relative to the directory, separated by slashes.
+type Remote struct {
+	Dir   api.Dir
+	Dirs  map[string]api.Dir
+	Files map[string]api.File
+}
+
+// NewRemote creates and returns the Remote of the tree. The directories and files
+// that are skipped by the rule are left out.
+func NewRemote(tree *api.TreeNode, skip ignore.Rule) *Remote {
+	r := &Remote{Dir: tree.Dir, Dirs: map[string]api.Dir{}, Files: map[string]api.File{}}
+	r.add(tree, "", skip)
+
+	return r
+}
+
+// add adds the sub directories and files of the node to this Remote. The rel is
+// the path of the node relative to the remote directory.
+func (r *Remote) add(node *api.TreeNode, rel string, skip ignore.Rule) {
+	for _, f := range node.Files {
+		name := path.Join(rel, f.Name)
+		if skip.Match(name, false) {
+			continue
+		}
+		r.Files[name] = f
+	}
+	for _, d := range node.Dirs {
+		sub := path.Join(rel, d.Dir.DirName)
+		if skip.Match(sub, true) {
+			continue
+		}
+		r.Dirs[sub] = d.Dir
+		r.add(d, sub, skip)
+	}
+}
+
+// Scan walks the local directory at root and returns every regular file below it,
+// keyed by its path relative to root. Partial downloads, and the directories and
+// files skipped by the rule, are left out. The root should be an absolute path, the
+// FullPath of each file is compared with the local path of its tracking.Record.
+func Scan(root string, skip ignore.Rule) (map[string]Local, error) {
+	files := map[string]Local{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." && skip.Match(rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || partial.IsPartial(d.Name()) || skip.Match(rel, false) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		files[rel] = Local{Path: rel, FullPath: p, Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// Change is a file that is different in the local and remote directories. Local is
+// nil if the file is only on the server, and Remote is nil if the file is only in
+// the local directory.
+type Change struct {
+	Action Action
+	// Path is the path of the file relative to both directories, separated by
+	// slashes.
+	Path   string
+	Local  *Local
+	Remote *api.File
+	// Reason describes why the file is changed.
+	Reason string
+}
+
+// Plan is the set of Changes that bring the local and remote directories up to
+// date. The Changes are sorted by path.
+type Plan struct {
+	Changes []Change
+	// Unchanged are the paths of the files that are the same on both sides.
+	Unchanged []string
+}
+
+// Filter returns the Changes of this Plan with the Action.
+func (p *Plan) Filter(a Action) []Change {
+	changes := []Change{}
+	for _, c := range p.Changes {
+		if c.Action == a {
+			changes = append(changes, c)
+		}
+	}
+
+	return changes
+}
+
+// Resolve replaces every Conflict of this Plan with the Action. Upload keeps the
+// local files, and Download keeps the files on the server.
+func (p *Plan) Resolve(a Action) {
+	for i, c := range p.Changes {
+		if c.Action == Conflict {
+			p.Changes[i].Action = a
+		}
+	}
+}
+
+// Empty checks if this Plan has no Changes.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// NewPlan compares the local files with the remote files and returns the Plan that
+// brings them up to date. The Tracker holds the state of each file the last time
+// it was synced, which is what both sides are compared with. A Record is only used
+// if it was recorded for the same local file, see tracking.Record.Local:
+//
+//   - A local file changed if it was modified after it was recorded, unless it
+//     has the hash it had when it was last uploaded, see tracking.Record.Hash.
+//   - A remote file changed if it was written on the server after it was recorded,
+//     see tracking.Tracker.Check.
+//
+// A file that only changed on one side is copied to the other side. A file on only
+// one side is copied to the other side. A file that changed on both sides, or that
+// is on both sides but was never synced, is a Conflict.
+//
+// Deletions are not detected, a file that is deleted on one side is copied back
+// from the other.
+func NewPlan(local map[string]Local, remote *Remote, t *tracking.Tracker) *Plan {
+	plan := &Plan{Changes: []Change{}, Unchanged: []string{}}
+
+	for rel, l := range local {
+		l := l
+		f, ok := remote.Files[rel]
+		if !ok {
+			plan.Changes = append(plan.Changes, Change{Action: Upload, Path: rel, Local: &l, Reason: "new local file"})
+			continue
+		}
+
+		rec, tracked := t.Records[f.ID]
+		if !tracked || rec.Local != l.FullPath {
+			plan.Changes = append(plan.Changes, Change{
+				Action: Conflict,
+				Path:   rel,
+				Local:  &l,
+				Remote: &f,
+				Reason: "the file exists locally and on the server but was never synced",
+			})
+			continue
+		}
+
+		localChanged := l.ModTime.After(rec.Recorded) && !sameHash(l, rec)
+		_, remoteChanged := t.Check(f)
+		switch {
+		case localChanged && remoteChanged:
+			plan.Changes = append(plan.Changes, Change{
+				Action: Conflict,
+				Path:   rel,
+				Local:  &l,
+				Remote: &f,
+				Reason: "the file changed locally and on the server since it was synced",
+			})
+		case localChanged:
+			plan.Changes = append(plan.Changes, Change{Action: Upload, Path: rel, Local: &l, Remote: &f, Reason: "changed locally"})
+		case remoteChanged:
+			plan.Changes = append(plan.Changes, Change{Action: Download, Path: rel, Local: &l, Remote: &f, Reason: "changed on the server"})
+		default:
+			plan.Unchanged = append(plan.Unchanged, rel)
+		}
+	}
+
+	for rel, f := range remote.Files {
+		f := f
+		if _, ok := local[rel]; ok {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: Download, Path: rel, Remote: &f, Reason: "new remote file"})
+	}
+
+	sort.Slice(plan.Changes, func(i, j int) bool { return plan.Changes[i].Path < plan.Changes[j].Path })
+	sort.Strings(plan.Unchanged)
+
+	return plan
+}
+
+// sameHash checks if the local file has the hash of the Record. A file that cannot
+// be read, or a Record without a hash, is never the same.
+func sameHash(l Local, rec tracking.Record) bool {
+	if rec.Hash == "" {
+		return false
+	}
+
+	hash, err := tracking.HashFile(l.FullPath)
+	return err == nil && hash == rec.Hash
+}
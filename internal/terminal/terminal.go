@@ -0,0 +1,118 @@
+// Package terminal puts a terminal into raw mode and reads the keys pressed in it,
+// for the commands that draw a full screen interface.
+package terminal
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupported is the error when the platform does not support raw mode.
+var ErrUnsupported = errors.New("raw mode is not supported on this platform")
+
+// Key is a key that was pressed. A printable key is its rune, the other keys are
+// the constants below.
+type Key rune
+
+// The keys that are not printable. The keys without a control character are
+// negative, so they are never a rune that was typed.
+const (
+	KeyUnknown Key = -(iota + 1)
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyPageUp
+	KeyPageDown
+	KeyDelete
+	KeyEnter     Key = '\r'
+	KeyBackspace Key = 0x7f
+	KeyEscape    Key = 0x1b
+	KeyCtrlC     Key = 0x03
+	KeyCtrlD     Key = 0x04
+)
+
+// escapes are the escape sequences of the keys, without the leading escape. Both
+// the normal ('[') and the application ('O') cursor key modes are read.
+var escapes = map[string]Key{
+	"[A":  KeyUp,
+	"[B":  KeyDown,
+	"[C":  KeyRight,
+	"[D":  KeyLeft,
+	"OA":  KeyUp,
+	"OB":  KeyDown,
+	"OC":  KeyRight,
+	"OD":  KeyLeft,
+	"[H":  KeyHome,
+	"[F":  KeyEnd,
+	"OH":  KeyHome,
+	"OF":  KeyEnd,
+	"[1~": KeyHome,
+	"[4~": KeyEnd,
+	"[3~": KeyDelete,
+	"[5~": KeyPageUp,
+	"[6~": KeyPageDown,
+}
+
+// KeyReader reads the keys pressed in a terminal in raw mode. The bytes of a read
+// are buffered, so the keys of a paste are all read. Create it with NewKeyReader.
+type KeyReader struct {
+	r   io.Reader
+	buf []byte
+}
+
+// NewKeyReader creates a *KeyReader that reads the keys from r.
+func NewKeyReader(r io.Reader) *KeyReader {
+	return &KeyReader{r: r}
+}
+
+// ReadKey reads the next key that was pressed. An escape sequence is expected to
+// arrive in a single read, which is how a terminal sends it, and an escape that is
+// read alone is KeyEscape. A sequence that is not known is KeyUnknown.
+//
+// Line feed is read as KeyEnter, the same as carriage return, and Ctrl-H as
+// KeyBackspace.
+func (kr *KeyReader) ReadKey() (Key, error) {
+	if len(kr.buf) == 0 {
+		buf := make([]byte, 256)
+		n, err := kr.r.Read(buf)
+		if n == 0 {
+			if err == nil {
+				err = io.ErrNoProgress
+			}
+			return KeyUnknown, err
+		}
+		kr.buf = buf[:n]
+	}
+
+	b := kr.buf[0]
+	switch {
+	case b == 0x1b && len(kr.buf) == 1:
+		kr.buf = nil
+		return KeyEscape, nil
+	case b == 0x1b:
+		for seq, k := range escapes {
+			if strings.HasPrefix(string(kr.buf[1:]), seq) {
+				kr.buf = kr.buf[1+len(seq):]
+				return k, nil
+			}
+		}
+		kr.buf = nil
+		return KeyUnknown, nil
+	case b == '\n':
+		kr.buf = kr.buf[1:]
+		return KeyEnter, nil
+	case b == 0x08:
+		kr.buf = kr.buf[1:]
+		return KeyBackspace, nil
+	}
+
+	r, size := utf8.DecodeRune(kr.buf)
+	kr.buf = kr.buf[size:]
+
+	return Key(r), nil
+}
@@ -0,0 +1,24 @@
+//go:build !linux && !darwin
+
+package terminal
+
+// State is the state of a terminal before it was put into raw mode.
+type State struct{}
+
+// MakeRaw puts the terminal of fd into raw mode. This platform does not support raw
+// mode, it returns ErrUnsupported.
+func MakeRaw(fd uintptr) (*State, error) {
+	return nil, ErrUnsupported
+}
+
+// Restore restores the terminal of fd. This platform does not support raw mode, it
+// returns ErrUnsupported.
+func Restore(fd uintptr, state *State) error {
+	return ErrUnsupported
+}
+
+// Size returns the size of the terminal of fd. This platform does not support raw
+// mode, it returns ErrUnsupported.
+func Size(fd uintptr) (width int, height int, err error) {
+	return 0, 0, ErrUnsupported
+}
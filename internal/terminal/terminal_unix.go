@@ -0,0 +1,66 @@
+//go:build linux || darwin
+
+package terminal
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// State is the state of a terminal before it was put into raw mode.
+type State struct {
+	termios syscall.Termios
+}
+
+// MakeRaw puts the terminal of fd into raw mode and returns its previous state, so
+// it can be restored with Restore. The keys are read as they are pressed without
+// being echoed, and Ctrl-C is read as a key instead of sending an interrupt. The
+// output is still processed, a new line starts at the beginning of the line.
+func MakeRaw(fd uintptr) (*State, error) {
+	var old syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&old)); err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+
+	return &State{termios: old}, nil
+}
+
+// Restore restores the terminal of fd to the state it was in before MakeRaw.
+func Restore(fd uintptr, state *State) error {
+	return ioctl(fd, ioctlSetTermios, unsafe.Pointer(&state.termios))
+}
+
+// Size returns the width and height of the terminal of fd, in characters.
+func Size(fd uintptr) (width int, height int, err error) {
+	var ws struct {
+		Row    uint16
+		Col    uint16
+		Xpixel uint16
+		Ypixel uint16
+	}
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+
+	return int(ws.Col), int(ws.Row), nil
+}
+
+// ioctl calls the ioctl system call with the request and its argument.
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
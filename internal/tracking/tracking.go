@@ -0,0 +1,208 @@
+package tracking
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cicconee/clox-cli/api"
+	"github.com/cicconee/clox-cli/internal/crypto"
+	"github.com/cicconee/clox-cli/internal/securefile"
+)
+
+// Record is the state of a file on the server when the local copy was fetched or
+// uploaded.
+type Record struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	LastWrite time.Time `json:"last_write"`
+	ETag      string    `json:"etag,omitempty"`
+	Recorded  time.Time `json:"recorded"`
+	// Appended is the number of bytes of the local file that are written to the
+	// server. It is only set for files that are appended to.
+	Appended int64 `json:"appended,omitempty"`
+	// Local is the absolute path of the local copy. It is only set for files that
+	// are synced, see SetLocal.
+	Local string `json:"local,omitempty"`
+	// Hash is the SHA-256 of the local file that was last uploaded to the file, in
+	// hex. It is only set for files uploaded by a push or sync, see SetHash.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Tracker is the set of Records of the files that have a local copy. A Tracker is
+// used to detect when a file changed on the server after the local copy was
+// fetched, so that the local copy does not overwrite the change.
+type Tracker struct {
+	Records map[string]Record `json:"records"`
+}
+
+// New creates and returns an empty Tracker.
+func New() *Tracker {
+	return &Tracker{Records: map[string]Record{}}
+}
+
+// Load reads the tracking file at path and decrypts it with the password. If the
+// file does not exist, it returns an empty Tracker.
+func Load(path string, aes *crypto.AES, password []byte) (*Tracker, error) {
+	t := New()
+	if err := securefile.ReadJSON(path, aes, password, t); err != nil {
+		if errors.Is(err, securefile.ErrNotExist) {
+			return New(), nil
+		}
+
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Save encrypts this Tracker with the password and writes it to path.
+func (t *Tracker) Save(path string, aes *crypto.AES, password []byte) error {
+	return securefile.WriteJSON(path, aes, password, t)
+}
+
+// Track records the state of the file on the server with the id and path. The
+// lastWrite is the time the file was last written on the server, and etag is its
+// ETag if the server exposes one. If the file is already tracked, the number of
+// bytes appended to it is kept, but the path and hash of its local copy are not.
+func (t *Tracker) Track(id string, path string, lastWrite time.Time, etag string) {
+	t.Records[id] = Record{
+		ID:        id,
+		Path:      path,
+		LastWrite: lastWrite,
+		ETag:      etag,
+		Recorded:  time.Now(),
+		Appended:  t.Records[id].Appended,
+	}
+}
+
+// SetAppended sets the number of bytes of the local file of the tracked file with
+// the id that are written to the server. If the file is not tracked, nothing is
+// done.
+func (t *Tracker) SetAppended(id string, n int64) {
+	rec, ok := t.Records[id]
+	if !ok {
+		return
+	}
+
+	rec.Appended = n
+	t.Records[id] = rec
+}
+
+// SetLocal sets the absolute path of the local copy of the tracked file with the
+// id. The local copy is the one that was synced with the file on the server, a
+// copy at any other path is not compared with it. If the file is not tracked,
+// nothing is done.
+func (t *Tracker) SetLocal(id string, local string) {
+	rec, ok := t.Records[id]
+	if !ok {
+		return
+	}
+
+	rec.Local = local
+	t.Records[id] = rec
+}
+
+// SetHash sets the hash of the local file that was uploaded to the tracked file
+// with the id, see HashFile. If the file is not tracked, nothing is done.
+func (t *Tracker) SetHash(id string, hash string) {
+	rec, ok := t.Records[id]
+	if !ok {
+		return
+	}
+
+	rec.Hash = hash
+	t.Records[id] = rec
+}
+
+// Unchanged checks if uploading a local file with the hash to the file on the
+// server would change nothing. It is true if the file was last uploaded with the
+// same hash, and has not changed on the server since, see Check.
+func (t *Tracker) Unchanged(f api.File, hash string) bool {
+	rec, ok := t.Records[f.ID]
+	if !ok || rec.Hash == "" || rec.Hash != hash {
+		return false
+	}
+
+	_, changed := t.Check(f)
+	return !changed
+}
+
+// Untrack removes the Record of the file with the id.
+func (t *Tracker) Untrack(id string) {
+	delete(t.Records, id)
+}
+
+// Move changes the path of the Record at oldPath, and of every Record below it, to be
+// at newPath. This is used after a directory or file is moved or renamed on the
+// server.
+func (t *Tracker) Move(oldPath string, newPath string) {
+	for id, r := range t.Records {
+		switch {
+		case r.Path == oldPath:
+			r.Path = newPath
+		case strings.HasPrefix(r.Path, oldPath+"/"):
+			r.Path = newPath + strings.TrimPrefix(r.Path, oldPath)
+		default:
+			continue
+		}
+		t.Records[id] = r
+	}
+}
+
+// Conflict is a file on the server that cannot be safely overwritten by the local
+// copy.
+type Conflict struct {
+	// The file on the server.
+	File api.File
+	// The Record of the file when the local copy was fetched. It is only set if
+	// Tracked is true.
+	Record Record
+	// Tracked is set if the file has a Record. If the file is not tracked, the
+	// local copy was never fetched from the server and may not be based on it.
+	Tracked bool
+}
+
+// Reason returns a human readable description of this Conflict.
+func (c Conflict) Reason() string {
+	if !c.Tracked {
+		return "the file exists on the server but was never fetched"
+	}
+
+	return "the file changed on the server at " + LastWrite(c.File).Format(time.RFC3339) +
+		", after it was fetched at " + c.Record.LastWrite.Format(time.RFC3339)
+}
+
+// Check checks if the file on the server can be overwritten. If the file has no
+// Record, or it was written on the server after it was recorded, it returns the
+// Conflict and true. If both the file and the Record have an ETag, they are compared
+// instead of the last write times.
+func (t *Tracker) Check(f api.File) (Conflict, bool) {
+	rec, ok := t.Records[f.ID]
+	if !ok {
+		return Conflict{File: f}, true
+	}
+
+	if f.ETag != "" && rec.ETag != "" {
+		if f.ETag != rec.ETag {
+			return Conflict{File: f, Record: rec, Tracked: true}, true
+		}
+		return Conflict{}, false
+	}
+
+	if LastWrite(f).After(rec.LastWrite) {
+		return Conflict{File: f, Record: rec, Tracked: true}, true
+	}
+
+	return Conflict{}, false
+}
+
+// LastWrite returns the time the file was last written on the server. Files that
+// were never updated only have an upload time.
+func LastWrite(f api.File) time.Time {
+	if f.UpdatedAt.IsZero() {
+		return f.UploadedAt
+	}
+
+	return f.UpdatedAt
+}